@@ -17,11 +17,12 @@ import (
 type ExecutionStatus int32
 
 const (
-	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED ExecutionStatus = 0
-	ExecutionStatus_EXECUTION_STATUS_SUCCESS     ExecutionStatus = 1
-	ExecutionStatus_EXECUTION_STATUS_DENIED      ExecutionStatus = 2
-	ExecutionStatus_EXECUTION_STATUS_ERROR       ExecutionStatus = 3
-	ExecutionStatus_EXECUTION_STATUS_INVALID     ExecutionStatus = 4
+	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED  ExecutionStatus = 0
+	ExecutionStatus_EXECUTION_STATUS_SUCCESS      ExecutionStatus = 1
+	ExecutionStatus_EXECUTION_STATUS_DENIED       ExecutionStatus = 2
+	ExecutionStatus_EXECUTION_STATUS_ERROR        ExecutionStatus = 3
+	ExecutionStatus_EXECUTION_STATUS_INVALID      ExecutionStatus = 4
+	ExecutionStatus_EXECUTION_STATUS_UNKNOWN_TOOL ExecutionStatus = 5
 )
 
 func (x ExecutionStatus) String() string {
@@ -34,6 +35,8 @@ func (x ExecutionStatus) String() string {
 		return "ERROR"
 	case ExecutionStatus_EXECUTION_STATUS_INVALID:
 		return "INVALID"
+	case ExecutionStatus_EXECUTION_STATUS_UNKNOWN_TOOL:
+		return "UNKNOWN_TOOL"
 	default:
 		return "UNSPECIFIED"
 	}
@@ -51,6 +54,41 @@ func (ExecutionStatus) Type() protoreflect.EnumType {
 	return nil
 }
 
+// PolicyChangeType describes why a PolicyChangeEvent was sent.
+type PolicyChangeType int32
+
+const (
+	PolicyChangeType_POLICY_CHANGE_TYPE_UNSPECIFIED PolicyChangeType = 0
+	PolicyChangeType_POLICY_CHANGE_TYPE_INITIAL     PolicyChangeType = 1
+	PolicyChangeType_POLICY_CHANGE_TYPE_UPDATED     PolicyChangeType = 2
+	PolicyChangeType_POLICY_CHANGE_TYPE_REMOVED     PolicyChangeType = 3
+)
+
+func (x PolicyChangeType) String() string {
+	switch x {
+	case PolicyChangeType_POLICY_CHANGE_TYPE_INITIAL:
+		return "INITIAL"
+	case PolicyChangeType_POLICY_CHANGE_TYPE_UPDATED:
+		return "UPDATED"
+	case PolicyChangeType_POLICY_CHANGE_TYPE_REMOVED:
+		return "REMOVED"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+func (PolicyChangeType) Descriptor() protoreflect.EnumDescriptor {
+	return nil
+}
+
+func (x PolicyChangeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+func (PolicyChangeType) Type() protoreflect.EnumType {
+	return nil
+}
+
 
 // RequestMetadata contains identity and context from the agent.
 type RequestMetadata struct {
@@ -75,6 +113,18 @@ type RequestMetadata struct {
 
 	// Labels contains additional metadata as key-value pairs.
 	Labels map[string]string `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+
+	// Locale is the preferred BCP 47 language for user-facing messages.
+	Locale string `protobuf:"bytes,7,opt,name=locale,proto3" json:"locale,omitempty"`
+
+	// Trace asks for OPA's trace of the call's evaluation.
+	Trace bool `protobuf:"varint,8,opt,name=trace,proto3" json:"trace,omitempty"`
+
+	// ParentSessionId is the session of the agent that delegated the task.
+	ParentSessionId string `protobuf:"bytes,9,opt,name=parent_session_id,json=parentSessionId,proto3" json:"parent_session_id,omitempty"`
+
+	// Lineage lists the sessions the task was delegated through, root first.
+	Lineage []string `protobuf:"bytes,10,rep,name=lineage,proto3" json:"lineage,omitempty"`
 }
 
 func (x *RequestMetadata) Reset() {
@@ -133,6 +183,34 @@ func (x *RequestMetadata) GetLabels() map[string]string {
 	return nil
 }
 
+func (x *RequestMetadata) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *RequestMetadata) GetTrace() bool {
+	if x != nil {
+		return x.Trace
+	}
+	return false
+}
+
+func (x *RequestMetadata) GetParentSessionId() string {
+	if x != nil {
+		return x.ParentSessionId
+	}
+	return ""
+}
+
+func (x *RequestMetadata) GetLineage() []string {
+	if x != nil {
+		return x.Lineage
+	}
+	return nil
+}
+
 // ExecuteRequest represents a tool execution request from an agent.
 type ExecuteRequest struct {
 	state         protoimpl.MessageState
@@ -150,6 +228,23 @@ type ExecuteRequest struct {
 
 	// RequestId is a unique identifier for this request.
 	RequestId string `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+
+	// TypedParameters carries common parameter shapes in typed form.
+	//
+	// Types that are assignable to TypedParameters:
+	//
+	//	*ExecuteRequest_File
+	//	*ExecuteRequest_Network
+	//	*ExecuteRequest_Exec
+	TypedParameters isExecuteRequest_TypedParameters `protobuf_oneof:"typed_parameters"`
+
+	// SessionToken is a token issued by OpenSession.
+	SessionToken string `protobuf:"bytes,8,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+
+	// PlanToken is a token issued by PreAuthorize, and PlanStep the index
+	// of the plan's call this call makes.
+	PlanToken string `protobuf:"bytes,9,opt,name=plan_token,json=planToken,proto3" json:"plan_token,omitempty"`
+	PlanStep  int32  `protobuf:"varint,10,opt,name=plan_step,json=planStep,proto3" json:"plan_step,omitempty"`
 }
 
 func (x *ExecuteRequest) Reset() {
@@ -206,6 +301,250 @@ func (x *ExecuteRequest) GetRequestId() string {
 	return ""
 }
 
+func (x *ExecuteRequest) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetPlanToken() string {
+	if x != nil {
+		return x.PlanToken
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetPlanStep() int32 {
+	if x != nil {
+		return x.PlanStep
+	}
+	return 0
+}
+
+func (m *ExecuteRequest) GetTypedParameters() isExecuteRequest_TypedParameters {
+	if m != nil {
+		return m.TypedParameters
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetFile() *FileParams {
+	if x, ok := x.GetTypedParameters().(*ExecuteRequest_File); ok {
+		return x.File
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetNetwork() *NetworkParams {
+	if x, ok := x.GetTypedParameters().(*ExecuteRequest_Network); ok {
+		return x.Network
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetExec() *ExecParams {
+	if x, ok := x.GetTypedParameters().(*ExecuteRequest_Exec); ok {
+		return x.Exec
+	}
+	return nil
+}
+
+type isExecuteRequest_TypedParameters interface {
+	isExecuteRequest_TypedParameters()
+}
+
+type ExecuteRequest_File struct {
+	File *FileParams `protobuf:"bytes,5,opt,name=file,proto3,oneof"`
+}
+
+type ExecuteRequest_Network struct {
+	Network *NetworkParams `protobuf:"bytes,6,opt,name=network,proto3,oneof"`
+}
+
+type ExecuteRequest_Exec struct {
+	Exec *ExecParams `protobuf:"bytes,7,opt,name=exec,proto3,oneof"`
+}
+
+func (*ExecuteRequest_File) isExecuteRequest_TypedParameters() {}
+
+func (*ExecuteRequest_Network) isExecuteRequest_TypedParameters() {}
+
+func (*ExecuteRequest_Exec) isExecuteRequest_TypedParameters() {}
+
+// FileParams are typed parameters for file operations.
+type FileParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Path is the target file path.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+
+	// Size is the number of bytes to write.
+	Size int64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (x *FileParams) Reset() {
+	*x = FileParams{}
+}
+
+func (x *FileParams) String() string {
+	return fmt.Sprintf("FileParams{Path:%q, Size:%d}", x.Path, x.Size)
+}
+
+func (*FileParams) ProtoMessage() {}
+
+func (x *FileParams) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *FileParams) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileParams) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+// NetworkParams are typed parameters for network operations.
+type NetworkParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Domain is the target host name.
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+
+	// Port is the target port.
+	Port int32 `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+
+	// Url is the full request URL.
+	Url string `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+
+	// Method is the request method.
+	Method string `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+}
+
+func (x *NetworkParams) Reset() {
+	*x = NetworkParams{}
+}
+
+func (x *NetworkParams) String() string {
+	return fmt.Sprintf("NetworkParams{Domain:%q, Port:%d}", x.Domain, x.Port)
+}
+
+func (*NetworkParams) ProtoMessage() {}
+
+func (x *NetworkParams) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *NetworkParams) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *NetworkParams) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *NetworkParams) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *NetworkParams) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+// ExecParams are typed parameters for execution operations.
+type ExecParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Command is the program or script to run.
+	Command string `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+
+	// Args are the command arguments.
+	Args []string `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+
+	// WorkingDir is the directory to run the command in.
+	WorkingDir string `protobuf:"bytes,3,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+
+	// TimeoutMs is the requested execution timeout in milliseconds.
+	TimeoutMs int64 `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+
+	// ContentSha256 is the hex SHA-256 digest of the script or artifact the command runs.
+	ContentSha256 string `protobuf:"bytes,5,opt,name=content_sha256,json=contentSha256,proto3" json:"content_sha256,omitempty"`
+}
+
+func (x *ExecParams) Reset() {
+	*x = ExecParams{}
+}
+
+func (x *ExecParams) String() string {
+	return fmt.Sprintf("ExecParams{Command:%q, TimeoutMs:%d}", x.Command, x.TimeoutMs)
+}
+
+func (*ExecParams) ProtoMessage() {}
+
+func (x *ExecParams) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ExecParams) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ExecParams) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ExecParams) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *ExecParams) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *ExecParams) GetContentSha256() string {
+	if x != nil {
+		return x.ContentSha256
+	}
+	return ""
+}
+
 // PolicyDecision contains details about the policy evaluation.
 type PolicyDecision struct {
 	state         protoimpl.MessageState
@@ -226,6 +565,30 @@ type PolicyDecision struct {
 
 	// CacheHit indicates whether the decision was from cache.
 	CacheHit bool `protobuf:"varint,5,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+
+	// Message is the user-facing denial message of the matching tool rule.
+	Message string `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+
+	// MessageLocale is the language of Message.
+	MessageLocale string `protobuf:"bytes,7,opt,name=message_locale,json=messageLocale,proto3" json:"message_locale,omitempty"`
+
+	// Mutations describe the parameter rewrites applied before execution.
+	Mutations []string `protobuf:"bytes,8,rep,name=mutations,proto3" json:"mutations,omitempty"`
+
+	// Obligations are the duties fulfilled before execution.
+	Obligations []*Obligation `protobuf:"bytes,9,rep,name=obligations,proto3" json:"obligations,omitempty"`
+
+	// OpaEvalTimeNs is the time OPA spent evaluating the decision's queries.
+	OpaEvalTimeNs int64 `protobuf:"varint,10,opt,name=opa_eval_time_ns,json=opaEvalTimeNs,proto3" json:"opa_eval_time_ns,omitempty"`
+
+	// OpaOperations is the number of evaluation operations of the queries.
+	OpaOperations int64 `protobuf:"varint,11,opt,name=opa_operations,json=opaOperations,proto3" json:"opa_operations,omitempty"`
+
+	// Trace is OPA's trace of the decision's queries, if asked for.
+	Trace string `protobuf:"bytes,12,opt,name=trace,proto3" json:"trace,omitempty"`
+
+	// Preauthorized is set if the call executed on a plan's authorization.
+	Preauthorized bool `protobuf:"varint,13,opt,name=preauthorized,proto3" json:"preauthorized,omitempty"`
 }
 
 func (x *PolicyDecision) Reset() {
@@ -277,73 +640,1074 @@ func (x *PolicyDecision) GetCacheHit() bool {
 	return false
 }
 
-// ExecuteResponse contains the result of a tool execution.
-type ExecuteResponse struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	// Result contains the tool execution result as JSON-encoded bytes.
-	Result []byte `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
-
-	// Error contains any error message.
-	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
-
-	// Status indicates the outcome of the request.
-	Status ExecutionStatus `protobuf:"varint,3,opt,name=status,proto3,enum=agents.sandbox.v1alpha1.ExecutionStatus" json:"status,omitempty"`
-
-	// RequestId echoes the request ID for correlation.
-	RequestId string `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-
-	// PolicyDecision contains details about the policy evaluation.
-	PolicyDecision *PolicyDecision `protobuf:"bytes,5,opt,name=policy_decision,json=policyDecision,proto3" json:"policy_decision,omitempty"`
-}
-
-func (x *ExecuteResponse) Reset() {
-	*x = ExecuteResponse{}
+func (x *PolicyDecision) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
 }
 
-func (x *ExecuteResponse) String() string {
-	return fmt.Sprintf("ExecuteResponse{Status:%v, RequestId:%q, Error:%q}", x.Status, x.RequestId, x.Error)
+func (x *PolicyDecision) GetMessageLocale() string {
+	if x != nil {
+		return x.MessageLocale
+	}
+	return ""
 }
 
-func (*ExecuteResponse) ProtoMessage() {}
-
-func (x *ExecuteResponse) ProtoReflect() protoreflect.Message {
+func (x *PolicyDecision) GetMutations() []string {
+	if x != nil {
+		return x.Mutations
+	}
 	return nil
 }
 
-func (x *ExecuteResponse) GetResult() []byte {
+func (x *PolicyDecision) GetObligations() []*Obligation {
 	if x != nil {
-		return x.Result
+		return x.Obligations
 	}
 	return nil
 }
 
-func (x *ExecuteResponse) GetError() string {
+func (x *PolicyDecision) GetOpaEvalTimeNs() int64 {
 	if x != nil {
-		return x.Error
+		return x.OpaEvalTimeNs
 	}
-	return ""
+	return 0
 }
 
-func (x *ExecuteResponse) GetStatus() ExecutionStatus {
+func (x *PolicyDecision) GetOpaOperations() int64 {
 	if x != nil {
-		return x.Status
+		return x.OpaOperations
 	}
-	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+	return 0
 }
 
-func (x *ExecuteResponse) GetRequestId() string {
+func (x *PolicyDecision) GetTrace() string {
 	if x != nil {
-		return x.RequestId
+		return x.Trace
 	}
 	return ""
 }
 
-func (x *ExecuteResponse) GetPolicyDecision() *PolicyDecision {
+func (x *PolicyDecision) GetPreauthorized() bool {
 	if x != nil {
-		return x.PolicyDecision
+		return x.Preauthorized
+	}
+	return false
+}
+
+// Obligation is a duty attached to an allow decision.
+type Obligation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Type identifies the duty.
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+
+	// Params configure the duty.
+	Params map[string]string `protobuf:"bytes,2,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *Obligation) Reset() {
+	*x = Obligation{}
+}
+
+func (x *Obligation) String() string {
+	return fmt.Sprintf("Obligation{Type:%q}", x.Type)
+}
+
+func (*Obligation) ProtoMessage() {}
+
+func (x *Obligation) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *Obligation) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Obligation) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+// ExecuteResponse contains the result of a tool execution.
+type ExecuteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Result contains the tool execution result as JSON-encoded bytes.
+	Result []byte `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+
+	// Error contains any error message.
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+
+	// Status indicates the outcome of the request.
+	Status ExecutionStatus `protobuf:"varint,3,opt,name=status,proto3,enum=agents.sandbox.v1alpha1.ExecutionStatus" json:"status,omitempty"`
+
+	// RequestId echoes the request ID for correlation.
+	RequestId string `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+
+	// PolicyDecision contains details about the policy evaluation.
+	PolicyDecision *PolicyDecision `protobuf:"bytes,5,opt,name=policy_decision,json=policyDecision,proto3" json:"policy_decision,omitempty"`
+
+	// ExecutionTimeNs is how long the tool executed in nanoseconds.
+	ExecutionTimeNs int64 `protobuf:"varint,6,opt,name=execution_time_ns,json=executionTimeNs,proto3" json:"execution_time_ns,omitempty"`
+
+	// Attempts is how many times the tool was executed.
+	Attempts int32 `protobuf:"varint,7,opt,name=attempts,proto3" json:"attempts,omitempty"`
+
+	// Replayed is true if this is the response of an earlier execution
+	// with the same request ID.
+	Replayed bool `protobuf:"varint,8,opt,name=replayed,proto3" json:"replayed,omitempty"`
+}
+
+func (x *ExecuteResponse) Reset() {
+	*x = ExecuteResponse{}
+}
+
+func (x *ExecuteResponse) String() string {
+	return fmt.Sprintf("ExecuteResponse{Status:%v, RequestId:%q, Error:%q}", x.Status, x.RequestId, x.Error)
+}
+
+func (*ExecuteResponse) ProtoMessage() {}
+
+func (x *ExecuteResponse) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ExecuteResponse) GetResult() []byte {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetStatus() ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+}
+
+func (x *ExecuteResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetPolicyDecision() *PolicyDecision {
+	if x != nil {
+		return x.PolicyDecision
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetExecutionTimeNs() int64 {
+	if x != nil {
+		return x.ExecutionTimeNs
+	}
+	return 0
+}
+
+func (x *ExecuteResponse) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *ExecuteResponse) GetReplayed() bool {
+	if x != nil {
+		return x.Replayed
+	}
+	return false
+}
+
+// PreAuthorizeRequest asks for the authorization of an agent's plan.
+type PreAuthorizeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Metadata identifies the agent, unless SessionToken does.
+	Metadata     *RequestMetadata `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	SessionToken string           `protobuf:"bytes,2,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+
+	// Calls are the tool calls of the plan, in order.
+	Calls []*PlannedCall `protobuf:"bytes,3,rep,name=calls,proto3" json:"calls,omitempty"`
+
+	// TtlSeconds is the requested lifetime of the plan token.
+	TtlSeconds int64 `protobuf:"varint,4,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+}
+
+func (x *PreAuthorizeRequest) Reset() {
+	*x = PreAuthorizeRequest{}
+}
+
+func (x *PreAuthorizeRequest) String() string {
+	return fmt.Sprintf("PreAuthorizeRequest{Metadata:%v, Calls:%d}", x.Metadata, len(x.Calls))
+}
+
+func (*PreAuthorizeRequest) ProtoMessage() {}
+
+func (x *PreAuthorizeRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *PreAuthorizeRequest) GetMetadata() *RequestMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *PreAuthorizeRequest) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *PreAuthorizeRequest) GetCalls() []*PlannedCall {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+func (x *PreAuthorizeRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+// PlannedCall is a tool call of a plan.
+type PlannedCall struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ToolName string `protobuf:"bytes,1,opt,name=tool_name,json=toolName,proto3" json:"tool_name,omitempty"`
+
+	// Parameters are the JSON-encoded parameters of the call.
+	Parameters []byte `protobuf:"bytes,2,opt,name=parameters,proto3" json:"parameters,omitempty"`
+}
+
+func (x *PlannedCall) Reset() {
+	*x = PlannedCall{}
+}
+
+func (x *PlannedCall) String() string {
+	return fmt.Sprintf("PlannedCall{ToolName:%q}", x.ToolName)
+}
+
+func (*PlannedCall) ProtoMessage() {}
+
+func (x *PlannedCall) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *PlannedCall) GetToolName() string {
+	if x != nil {
+		return x.ToolName
+	}
+	return ""
+}
+
+func (x *PlannedCall) GetParameters() []byte {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+// PreAuthorizeResponse carries the decisions of a plan's calls and, if
+// policy allows all of them, the plan token.
+type PreAuthorizeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Authorized is set if policy allows every call of the plan.
+	Authorized bool `protobuf:"varint,1,opt,name=authorized,proto3" json:"authorized,omitempty"`
+
+	// PlanToken authorizes the plan's calls (empty unless authorized).
+	PlanToken string `protobuf:"bytes,2,opt,name=plan_token,json=planToken,proto3" json:"plan_token,omitempty"`
+
+	// Steps are the decisions of the plan's calls, in order.
+	Steps []*PlanStep `protobuf:"bytes,3,rep,name=steps,proto3" json:"steps,omitempty"`
+
+	// ExpiresUnixNano is when the token stops being accepted.
+	ExpiresUnixNano int64 `protobuf:"varint,4,opt,name=expires_unix_nano,json=expiresUnixNano,proto3" json:"expires_unix_nano,omitempty"`
+}
+
+func (x *PreAuthorizeResponse) Reset() {
+	*x = PreAuthorizeResponse{}
+}
+
+func (x *PreAuthorizeResponse) String() string {
+	return fmt.Sprintf("PreAuthorizeResponse{Authorized:%v, Steps:%d, ExpiresUnixNano:%d}", x.Authorized, len(x.Steps), x.ExpiresUnixNano)
+}
+
+func (*PreAuthorizeResponse) ProtoMessage() {}
+
+func (x *PreAuthorizeResponse) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *PreAuthorizeResponse) GetAuthorized() bool {
+	if x != nil {
+		return x.Authorized
+	}
+	return false
+}
+
+func (x *PreAuthorizeResponse) GetPlanToken() string {
+	if x != nil {
+		return x.PlanToken
+	}
+	return ""
+}
+
+func (x *PreAuthorizeResponse) GetSteps() []*PlanStep {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *PreAuthorizeResponse) GetExpiresUnixNano() int64 {
+	if x != nil {
+		return x.ExpiresUnixNano
+	}
+	return 0
+}
+
+// PlanStep is the decision of a call of a plan.
+type PlanStep struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Decision *PolicyDecision `protobuf:"bytes,1,opt,name=decision,proto3" json:"decision,omitempty"`
+
+	// Preauthorized is set if the call executes without being evaluated
+	// again.
+	Preauthorized bool `protobuf:"varint,2,opt,name=preauthorized,proto3" json:"preauthorized,omitempty"`
+}
+
+func (x *PlanStep) Reset() {
+	*x = PlanStep{}
+}
+
+func (x *PlanStep) String() string {
+	return fmt.Sprintf("PlanStep{Decision:%v, Preauthorized:%v}", x.Decision, x.Preauthorized)
+}
+
+func (*PlanStep) ProtoMessage() {}
+
+func (x *PlanStep) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *PlanStep) GetDecision() *PolicyDecision {
+	if x != nil {
+		return x.Decision
+	}
+	return nil
+}
+
+func (x *PlanStep) GetPreauthorized() bool {
+	if x != nil {
+		return x.Preauthorized
+	}
+	return false
+}
+
+// ExecuteAsyncRequest submits a tool execution to run in the background.
+type ExecuteAsyncRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Request is the call, as it would be made with Execute.
+	Request *ExecuteRequest `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+
+	// CallbackUrl, if set, receives the operation once it is done.
+	CallbackUrl string `protobuf:"bytes,2,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+}
+
+func (x *ExecuteAsyncRequest) Reset() {
+	*x = ExecuteAsyncRequest{}
+}
+
+func (x *ExecuteAsyncRequest) String() string {
+	return fmt.Sprintf("ExecuteAsyncRequest{Request:%v, CallbackUrl:%q}", x.Request, x.CallbackUrl)
+}
+
+func (*ExecuteAsyncRequest) ProtoMessage() {}
+
+func (x *ExecuteAsyncRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ExecuteAsyncRequest) GetRequest() *ExecuteRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *ExecuteAsyncRequest) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+// GetOperationRequest names an operation of the caller's.
+type GetOperationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OperationId string `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+
+	// Metadata or SessionToken identify the caller.
+	Metadata     *RequestMetadata `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	SessionToken string           `protobuf:"bytes,3,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+}
+
+func (x *GetOperationRequest) Reset() {
+	*x = GetOperationRequest{}
+}
+
+func (x *GetOperationRequest) String() string {
+	return fmt.Sprintf("GetOperationRequest{OperationId:%q}", x.OperationId)
+}
+
+func (*GetOperationRequest) ProtoMessage() {}
+
+func (x *GetOperationRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *GetOperationRequest) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
+func (x *GetOperationRequest) GetMetadata() *RequestMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *GetOperationRequest) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+// Operation is a tool execution submitted with ExecuteAsync.
+type Operation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// OperationId identifies the operation; empty for calls answered at
+	// submission.
+	OperationId string `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+
+	// Done is set once the call has executed, or failed to.
+	Done bool `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+
+	// Response is the call's response; set once done.
+	Response *ExecuteResponse `protobuf:"bytes,3,opt,name=response,proto3" json:"response,omitempty"`
+
+	// PolicyDecision is the decision policy made at submission.
+	PolicyDecision *PolicyDecision `protobuf:"bytes,4,opt,name=policy_decision,json=policyDecision,proto3" json:"policy_decision,omitempty"`
+
+	RequestId         string `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	ToolName          string `protobuf:"bytes,6,opt,name=tool_name,json=toolName,proto3" json:"tool_name,omitempty"`
+	SubmittedUnixNano int64  `protobuf:"varint,7,opt,name=submitted_unix_nano,json=submittedUnixNano,proto3" json:"submitted_unix_nano,omitempty"`
+	DoneUnixNano      int64  `protobuf:"varint,8,opt,name=done_unix_nano,json=doneUnixNano,proto3" json:"done_unix_nano,omitempty"`
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+}
+
+func (x *Operation) String() string {
+	return fmt.Sprintf("Operation{OperationId:%q, Done:%v, Response:%v}", x.OperationId, x.Done, x.Response)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *Operation) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
+func (x *Operation) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *Operation) GetResponse() *ExecuteResponse {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *Operation) GetPolicyDecision() *PolicyDecision {
+	if x != nil {
+		return x.PolicyDecision
+	}
+	return nil
+}
+
+func (x *Operation) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *Operation) GetToolName() string {
+	if x != nil {
+		return x.ToolName
+	}
+	return ""
+}
+
+func (x *Operation) GetSubmittedUnixNano() int64 {
+	if x != nil {
+		return x.SubmittedUnixNano
+	}
+	return 0
+}
+
+func (x *Operation) GetDoneUnixNano() int64 {
+	if x != nil {
+		return x.DoneUnixNano
+	}
+	return 0
+}
+
+// WatchPolicyRequest subscribes an agent to changes in its effective policy.
+type WatchPolicyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Metadata identifies the agent.
+	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (x *WatchPolicyRequest) Reset() {
+	*x = WatchPolicyRequest{}
+}
+
+func (x *WatchPolicyRequest) String() string {
+	return fmt.Sprintf("WatchPolicyRequest{Metadata:%v}", x.Metadata)
+}
+
+func (*WatchPolicyRequest) ProtoMessage() {}
+
+func (x *WatchPolicyRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *WatchPolicyRequest) GetMetadata() *RequestMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// PolicyChangeEvent describes the agent's effective policy.
+type PolicyChangeEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ChangeType describes why the event was sent.
+	ChangeType PolicyChangeType `protobuf:"varint,1,opt,name=change_type,json=changeType,proto3,enum=agents.sandbox.v1alpha1.PolicyChangeType" json:"change_type,omitempty"`
+
+	// AgentType is the watched agent type.
+	AgentType string `protobuf:"bytes,2,opt,name=agent_type,json=agentType,proto3" json:"agent_type,omitempty"`
+
+	// PolicyName is the name of the effective policy.
+	PolicyName string `protobuf:"bytes,3,opt,name=policy_name,json=policyName,proto3" json:"policy_name,omitempty"`
+
+	// PolicyHash is a fingerprint of the effective policy content.
+	PolicyHash string `protobuf:"bytes,4,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
+
+	// Mode is the enforcement mode.
+	Mode string `protobuf:"bytes,5,opt,name=mode,proto3" json:"mode,omitempty"`
+
+	// DefaultAction is the policy's default action.
+	DefaultAction string `protobuf:"bytes,6,opt,name=default_action,json=defaultAction,proto3" json:"default_action,omitempty"`
+
+	// TimestampUnixNano is when the change was observed.
+	TimestampUnixNano int64 `protobuf:"varint,7,opt,name=timestamp_unix_nano,json=timestampUnixNano,proto3" json:"timestamp_unix_nano,omitempty"`
+}
+
+func (x *PolicyChangeEvent) Reset() {
+	*x = PolicyChangeEvent{}
+}
+
+func (x *PolicyChangeEvent) String() string {
+	return fmt.Sprintf("PolicyChangeEvent{ChangeType:%v, AgentType:%q, PolicyName:%q, PolicyHash:%q}", x.ChangeType, x.AgentType, x.PolicyName, x.PolicyHash)
+}
+
+func (*PolicyChangeEvent) ProtoMessage() {}
+
+func (x *PolicyChangeEvent) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *PolicyChangeEvent) GetChangeType() PolicyChangeType {
+	if x != nil {
+		return x.ChangeType
+	}
+	return PolicyChangeType_POLICY_CHANGE_TYPE_UNSPECIFIED
+}
+
+func (x *PolicyChangeEvent) GetAgentType() string {
+	if x != nil {
+		return x.AgentType
+	}
+	return ""
+}
+
+func (x *PolicyChangeEvent) GetPolicyName() string {
+	if x != nil {
+		return x.PolicyName
+	}
+	return ""
+}
+
+func (x *PolicyChangeEvent) GetPolicyHash() string {
+	if x != nil {
+		return x.PolicyHash
+	}
+	return ""
+}
+
+func (x *PolicyChangeEvent) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *PolicyChangeEvent) GetDefaultAction() string {
+	if x != nil {
+		return x.DefaultAction
+	}
+	return ""
+}
+
+func (x *PolicyChangeEvent) GetTimestampUnixNano() int64 {
+	if x != nil {
+		return x.TimestampUnixNano
+	}
+	return 0
+}
+
+// OpenSessionRequest asks for a session for an agent.
+type OpenSessionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Metadata identifies the agent.
+	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+
+	// TtlSeconds is the requested session lifetime.
+	TtlSeconds int64 `protobuf:"varint,2,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+}
+
+func (x *OpenSessionRequest) Reset() {
+	*x = OpenSessionRequest{}
+}
+
+func (x *OpenSessionRequest) String() string {
+	return fmt.Sprintf("OpenSessionRequest{Metadata:%v, TtlSeconds:%d}", x.Metadata, x.TtlSeconds)
+}
+
+func (*OpenSessionRequest) ProtoMessage() {}
+
+func (x *OpenSessionRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *OpenSessionRequest) GetMetadata() *RequestMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *OpenSessionRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+// OpenSessionResponse carries the issued session.
+type OpenSessionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// SessionToken authenticates subsequent Execute calls.
+	SessionToken string `protobuf:"bytes,1,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+
+	// SessionId identifies the session in audit events.
+	SessionId string `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+
+	// ExpiresUnixNano is when the token stops being accepted.
+	ExpiresUnixNano int64 `protobuf:"varint,3,opt,name=expires_unix_nano,json=expiresUnixNano,proto3" json:"expires_unix_nano,omitempty"`
+}
+
+func (x *OpenSessionResponse) Reset() {
+	*x = OpenSessionResponse{}
+}
+
+func (x *OpenSessionResponse) String() string {
+	return fmt.Sprintf("OpenSessionResponse{SessionId:%q, ExpiresUnixNano:%d}", x.SessionId, x.ExpiresUnixNano)
+}
+
+func (*OpenSessionResponse) ProtoMessage() {}
+
+func (x *OpenSessionResponse) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *OpenSessionResponse) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *OpenSessionResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *OpenSessionResponse) GetExpiresUnixNano() int64 {
+	if x != nil {
+		return x.ExpiresUnixNano
+	}
+	return 0
+}
+
+// ListAllowedToolsRequest asks for the tools available to an agent.
+type ListAllowedToolsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Metadata identifies the agent.
+	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (x *ListAllowedToolsRequest) Reset() {
+	*x = ListAllowedToolsRequest{}
+}
+
+func (x *ListAllowedToolsRequest) String() string {
+	return fmt.Sprintf("ListAllowedToolsRequest{Metadata:%v}", x.Metadata)
+}
+
+func (*ListAllowedToolsRequest) ProtoMessage() {}
+
+func (x *ListAllowedToolsRequest) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ListAllowedToolsRequest) GetMetadata() *RequestMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// ListAllowedToolsResponse lists the tools the agent's policy allows.
+type ListAllowedToolsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// PolicyName is the effective policy.
+	PolicyName string `protobuf:"bytes,1,opt,name=policy_name,json=policyName,proto3" json:"policy_name,omitempty"`
+
+	// PolicyHash is a fingerprint of the effective policy content.
+	PolicyHash string `protobuf:"bytes,2,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
+
+	// DefaultAction applies to tools not listed.
+	DefaultAction string `protobuf:"bytes,3,opt,name=default_action,json=defaultAction,proto3" json:"default_action,omitempty"`
+
+	// Mode is the enforcement mode.
+	Mode string `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`
+
+	// Tools are the explicitly allowed tools.
+	Tools []*AllowedTool `protobuf:"bytes,5,rep,name=tools,proto3" json:"tools,omitempty"`
+}
+
+func (x *ListAllowedToolsResponse) Reset() {
+	*x = ListAllowedToolsResponse{}
+}
+
+func (x *ListAllowedToolsResponse) String() string {
+	return fmt.Sprintf("ListAllowedToolsResponse{PolicyName:%q, Tools:%d}", x.PolicyName, len(x.Tools))
+}
+
+func (*ListAllowedToolsResponse) ProtoMessage() {}
+
+func (x *ListAllowedToolsResponse) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ListAllowedToolsResponse) GetPolicyName() string {
+	if x != nil {
+		return x.PolicyName
+	}
+	return ""
+}
+
+func (x *ListAllowedToolsResponse) GetPolicyHash() string {
+	if x != nil {
+		return x.PolicyHash
+	}
+	return ""
+}
+
+func (x *ListAllowedToolsResponse) GetDefaultAction() string {
+	if x != nil {
+		return x.DefaultAction
+	}
+	return ""
+}
+
+func (x *ListAllowedToolsResponse) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *ListAllowedToolsResponse) GetTools() []*AllowedTool {
+	if x != nil {
+		return x.Tools
+	}
+	return nil
+}
+
+// AllowedTool is a tool the agent may call, subject to its constraints.
+type AllowedTool struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name is the tool name.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+
+	// Constraints summarizes the conditions calls must satisfy.
+	Constraints *ToolConstraintSummary `protobuf:"bytes,2,opt,name=constraints,proto3" json:"constraints,omitempty"`
+
+	// Description and Category describe the tool as its ToolCatalog
+	// declares it.
+	Description string `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Category    string `protobuf:"bytes,4,opt,name=category,proto3" json:"category,omitempty"`
+
+	// RiskLevel rates the tool, by its ToolCatalog or policy rule.
+	RiskLevel string `protobuf:"bytes,5,opt,name=risk_level,json=riskLevel,proto3" json:"risk_level,omitempty"`
+
+	// ParametersSchema is the JSON Schema of the tool's parameters.
+	ParametersSchema string `protobuf:"bytes,6,opt,name=parameters_schema,json=parametersSchema,proto3" json:"parameters_schema,omitempty"`
+}
+
+func (x *AllowedTool) Reset() {
+	*x = AllowedTool{}
+}
+
+func (x *AllowedTool) String() string {
+	return fmt.Sprintf("AllowedTool{Name:%q}", x.Name)
+}
+
+func (*AllowedTool) ProtoMessage() {}
+
+func (x *AllowedTool) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *AllowedTool) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AllowedTool) GetConstraints() *ToolConstraintSummary {
+	if x != nil {
+		return x.Constraints
+	}
+	return nil
+}
+
+func (x *AllowedTool) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *AllowedTool) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *AllowedTool) GetRiskLevel() string {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return ""
+}
+
+func (x *AllowedTool) GetParametersSchema() string {
+	if x != nil {
+		return x.ParametersSchema
+	}
+	return ""
+}
+
+// ToolConstraintSummary describes the parameter constraints on a tool.
+type ToolConstraintSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// PathPatterns are the allowed file path globs.
+	PathPatterns []string `protobuf:"bytes,1,rep,name=path_patterns,json=pathPatterns,proto3" json:"path_patterns,omitempty"`
+
+	// AllowedDomains are the allowed network domains.
+	AllowedDomains []string `protobuf:"bytes,2,rep,name=allowed_domains,json=allowedDomains,proto3" json:"allowed_domains,omitempty"`
+
+	// DeniedDomains are the blocked network domains.
+	DeniedDomains []string `protobuf:"bytes,3,rep,name=denied_domains,json=deniedDomains,proto3" json:"denied_domains,omitempty"`
+
+	// AllowedPorts are the allowed network ports.
+	AllowedPorts []int32 `protobuf:"varint,4,rep,packed,name=allowed_ports,json=allowedPorts,proto3" json:"allowed_ports,omitempty"`
+
+	// MaxSizeBytes is the maximum write size.
+	MaxSizeBytes int64 `protobuf:"varint,5,opt,name=max_size_bytes,json=maxSizeBytes,proto3" json:"max_size_bytes,omitempty"`
+
+	// TimeoutMs is the maximum execution timeout.
+	TimeoutMs int64 `protobuf:"varint,6,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+
+	// AllowedContentHashes are the SHA-256 digests of artifacts the tool may run.
+	AllowedContentHashes []string `protobuf:"bytes,7,rep,name=allowed_content_hashes,json=allowedContentHashes,proto3" json:"allowed_content_hashes,omitempty"`
+}
+
+func (x *ToolConstraintSummary) Reset() {
+	*x = ToolConstraintSummary{}
+}
+
+func (x *ToolConstraintSummary) String() string {
+	return fmt.Sprintf("ToolConstraintSummary{PathPatterns:%v, AllowedDomains:%v}", x.PathPatterns, x.AllowedDomains)
+}
+
+func (*ToolConstraintSummary) ProtoMessage() {}
+
+func (x *ToolConstraintSummary) ProtoReflect() protoreflect.Message {
+	return nil
+}
+
+func (x *ToolConstraintSummary) GetPathPatterns() []string {
+	if x != nil {
+		return x.PathPatterns
+	}
+	return nil
+}
+
+func (x *ToolConstraintSummary) GetAllowedDomains() []string {
+	if x != nil {
+		return x.AllowedDomains
+	}
+	return nil
+}
+
+func (x *ToolConstraintSummary) GetDeniedDomains() []string {
+	if x != nil {
+		return x.DeniedDomains
+	}
+	return nil
+}
+
+func (x *ToolConstraintSummary) GetAllowedPorts() []int32 {
+	if x != nil {
+		return x.AllowedPorts
+	}
+	return nil
+}
+
+func (x *ToolConstraintSummary) GetMaxSizeBytes() int64 {
+	if x != nil {
+		return x.MaxSizeBytes
+	}
+	return 0
+}
+
+func (x *ToolConstraintSummary) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *ToolConstraintSummary) GetAllowedContentHashes() []string {
+	if x != nil {
+		return x.AllowedContentHashes
 	}
 	return nil
 }
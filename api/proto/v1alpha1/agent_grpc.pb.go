@@ -17,6 +17,20 @@ import (
 type AgentServiceClient interface {
 	// Execute requests a tool execution.
 	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	// WatchPolicy subscribes to changes in the agent's effective policy.
+	WatchPolicy(ctx context.Context, in *WatchPolicyRequest, opts ...grpc.CallOption) (AgentService_WatchPolicyClient, error)
+	// ListAllowedTools returns the tools the agent's policy allows.
+	ListAllowedTools(ctx context.Context, in *ListAllowedToolsRequest, opts ...grpc.CallOption) (*ListAllowedToolsResponse, error)
+	// OpenSession authenticates an agent and issues a session token.
+	OpenSession(ctx context.Context, in *OpenSessionRequest, opts ...grpc.CallOption) (*OpenSessionResponse, error)
+	// PreAuthorize evaluates an agent's plan and issues a plan token.
+	PreAuthorize(ctx context.Context, in *PreAuthorizeRequest, opts ...grpc.CallOption) (*PreAuthorizeResponse, error)
+	// ExecuteAsync submits a tool execution to run in the background.
+	ExecuteAsync(ctx context.Context, in *ExecuteAsyncRequest, opts ...grpc.CallOption) (*Operation, error)
+	// GetOperation returns the state of an operation.
+	GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error)
+	// WatchOperation streams the state of an operation until it is done.
+	WatchOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (AgentService_WatchOperationClient, error)
 }
 
 type agentServiceClient struct {
@@ -37,10 +51,135 @@ func (c *agentServiceClient) Execute(ctx context.Context, in *ExecuteRequest, op
 	return out, nil
 }
 
+func (c *agentServiceClient) WatchPolicy(ctx context.Context, in *WatchPolicyRequest, opts ...grpc.CallOption) (AgentService_WatchPolicyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentService_ServiceDesc.Streams[0], "/agents.sandbox.v1alpha1.AgentService/WatchPolicy", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentServiceWatchPolicyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *agentServiceClient) ListAllowedTools(ctx context.Context, in *ListAllowedToolsRequest, opts ...grpc.CallOption) (*ListAllowedToolsResponse, error) {
+	out := new(ListAllowedToolsResponse)
+	err := c.cc.Invoke(ctx, "/agents.sandbox.v1alpha1.AgentService/ListAllowedTools", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) OpenSession(ctx context.Context, in *OpenSessionRequest, opts ...grpc.CallOption) (*OpenSessionResponse, error) {
+	out := new(OpenSessionResponse)
+	err := c.cc.Invoke(ctx, "/agents.sandbox.v1alpha1.AgentService/OpenSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) PreAuthorize(ctx context.Context, in *PreAuthorizeRequest, opts ...grpc.CallOption) (*PreAuthorizeResponse, error) {
+	out := new(PreAuthorizeResponse)
+	err := c.cc.Invoke(ctx, "/agents.sandbox.v1alpha1.AgentService/PreAuthorize", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) ExecuteAsync(ctx context.Context, in *ExecuteAsyncRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, "/agents.sandbox.v1alpha1.AgentService/ExecuteAsync", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error) {
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, "/agents.sandbox.v1alpha1.AgentService/GetOperation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) WatchOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (AgentService_WatchOperationClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentService_ServiceDesc.Streams[1], "/agents.sandbox.v1alpha1.AgentService/WatchOperation", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentServiceWatchOperationClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// AgentService_WatchPolicyClient is the client stream for WatchPolicy.
+type AgentService_WatchPolicyClient interface {
+	Recv() (*PolicyChangeEvent, error)
+	grpc.ClientStream
+}
+
+type agentServiceWatchPolicyClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentServiceWatchPolicyClient) Recv() (*PolicyChangeEvent, error) {
+	m := new(PolicyChangeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentService_WatchOperationClient is the client stream for WatchOperation.
+type AgentService_WatchOperationClient interface {
+	Recv() (*Operation, error)
+	grpc.ClientStream
+}
+
+type agentServiceWatchOperationClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentServiceWatchOperationClient) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // AgentServiceServer is the server API for AgentService.
 type AgentServiceServer interface {
 	// Execute requests a tool execution.
 	Execute(context.Context, *ExecuteRequest) (*ExecuteResponse, error)
+	// WatchPolicy subscribes to changes in the agent's effective policy.
+	WatchPolicy(*WatchPolicyRequest, AgentService_WatchPolicyServer) error
+	// ListAllowedTools returns the tools the agent's policy allows.
+	ListAllowedTools(context.Context, *ListAllowedToolsRequest) (*ListAllowedToolsResponse, error)
+	// OpenSession authenticates an agent and issues a session token.
+	OpenSession(context.Context, *OpenSessionRequest) (*OpenSessionResponse, error)
+	// PreAuthorize evaluates an agent's plan and issues a plan token.
+	PreAuthorize(context.Context, *PreAuthorizeRequest) (*PreAuthorizeResponse, error)
+	// ExecuteAsync submits a tool execution to run in the background.
+	ExecuteAsync(context.Context, *ExecuteAsyncRequest) (*Operation, error)
+	// GetOperation returns the state of an operation.
+	GetOperation(context.Context, *GetOperationRequest) (*Operation, error)
+	// WatchOperation streams the state of an operation until it is done.
+	WatchOperation(*GetOperationRequest, AgentService_WatchOperationServer) error
 	mustEmbedUnimplementedAgentServiceServer()
 }
 
@@ -51,6 +190,34 @@ func (UnimplementedAgentServiceServer) Execute(context.Context, *ExecuteRequest)
 	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
 }
 
+func (UnimplementedAgentServiceServer) WatchPolicy(*WatchPolicyRequest, AgentService_WatchPolicyServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPolicy not implemented")
+}
+
+func (UnimplementedAgentServiceServer) ListAllowedTools(context.Context, *ListAllowedToolsRequest) (*ListAllowedToolsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAllowedTools not implemented")
+}
+
+func (UnimplementedAgentServiceServer) OpenSession(context.Context, *OpenSessionRequest) (*OpenSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OpenSession not implemented")
+}
+
+func (UnimplementedAgentServiceServer) PreAuthorize(context.Context, *PreAuthorizeRequest) (*PreAuthorizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PreAuthorize not implemented")
+}
+
+func (UnimplementedAgentServiceServer) ExecuteAsync(context.Context, *ExecuteAsyncRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecuteAsync not implemented")
+}
+
+func (UnimplementedAgentServiceServer) GetOperation(context.Context, *GetOperationRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOperation not implemented")
+}
+
+func (UnimplementedAgentServiceServer) WatchOperation(*GetOperationRequest, AgentService_WatchOperationServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOperation not implemented")
+}
+
 func (UnimplementedAgentServiceServer) mustEmbedUnimplementedAgentServiceServer() {}
 
 // UnsafeAgentServiceServer may be embedded to opt out of forward compatibility.
@@ -81,6 +248,140 @@ func _AgentService_Execute_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AgentService_ListAllowedTools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAllowedToolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).ListAllowedTools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/agents.sandbox.v1alpha1.AgentService/ListAllowedTools",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).ListAllowedTools(ctx, req.(*ListAllowedToolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_OpenSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).OpenSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/agents.sandbox.v1alpha1.AgentService/OpenSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).OpenSession(ctx, req.(*OpenSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_PreAuthorize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreAuthorizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).PreAuthorize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/agents.sandbox.v1alpha1.AgentService/PreAuthorize",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).PreAuthorize(ctx, req.(*PreAuthorizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_ExecuteAsync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteAsyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).ExecuteAsync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/agents.sandbox.v1alpha1.AgentService/ExecuteAsync",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).ExecuteAsync(ctx, req.(*ExecuteAsyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_GetOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).GetOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/agents.sandbox.v1alpha1.AgentService/GetOperation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).GetOperation(ctx, req.(*GetOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_WatchPolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPolicyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServiceServer).WatchPolicy(m, &agentServiceWatchPolicyServer{stream})
+}
+
+// AgentService_WatchPolicyServer is the server stream for WatchPolicy.
+type AgentService_WatchPolicyServer interface {
+	Send(*PolicyChangeEvent) error
+	grpc.ServerStream
+}
+
+type agentServiceWatchPolicyServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentServiceWatchPolicyServer) Send(m *PolicyChangeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AgentService_WatchOperation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetOperationRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServiceServer).WatchOperation(m, &agentServiceWatchOperationServer{stream})
+}
+
+// AgentService_WatchOperationServer is the server stream for WatchOperation.
+type AgentService_WatchOperationServer interface {
+	Send(*Operation) error
+	grpc.ServerStream
+}
+
+type agentServiceWatchOperationServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentServiceWatchOperationServer) Send(m *Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // AgentService_ServiceDesc is the grpc.ServiceDesc for AgentService.
 var AgentService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "agents.sandbox.v1alpha1.AgentService",
@@ -90,7 +391,38 @@ var AgentService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Execute",
 			Handler:    _AgentService_Execute_Handler,
 		},
+		{
+			MethodName: "ListAllowedTools",
+			Handler:    _AgentService_ListAllowedTools_Handler,
+		},
+		{
+			MethodName: "OpenSession",
+			Handler:    _AgentService_OpenSession_Handler,
+		},
+		{
+			MethodName: "PreAuthorize",
+			Handler:    _AgentService_PreAuthorize_Handler,
+		},
+		{
+			MethodName: "ExecuteAsync",
+			Handler:    _AgentService_ExecuteAsync_Handler,
+		},
+		{
+			MethodName: "GetOperation",
+			Handler:    _AgentService_GetOperation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPolicy",
+			Handler:       _AgentService_WatchPolicy_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchOperation",
+			Handler:       _AgentService_WatchOperation_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/proto/agent.proto",
 }
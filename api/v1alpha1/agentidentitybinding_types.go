@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// AgentIdentityBinding Spec
+// ============================================================================
+
+// ServiceAccountReference names a Kubernetes ServiceAccount.
+type ServiceAccountReference struct {
+	// Namespace is the namespace of the ServiceAccount.
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the ServiceAccount.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// AgentIdentityBindingSpec maps a ServiceAccount to the agent identity its
+// tokens attest. When the router authenticates agents by TokenReview, an
+// agent presenting a token of the ServiceAccount is treated as this agent
+// type and tenant, whatever its request metadata claims.
+type AgentIdentityBindingSpec struct {
+	// ServiceAccount is the ServiceAccount whose tokens are bound.
+	// +kubebuilder:validation:Required
+	ServiceAccount ServiceAccountReference `json:"serviceAccount"`
+
+	// AgentType is the agent type of the ServiceAccount's agents.
+	// +kubebuilder:validation:Required
+	AgentType string `json:"agentType"`
+
+	// TenantID is the tenant of the ServiceAccount's agents.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
+
+	// MTSLabel is the Multi-Tenant Sandboxing label of the ServiceAccount's
+	// agents (e.g., "s0:c100,c200", or "s0-s1:c0.c99" for a range).
+	// +optional
+	MTSLabel string `json:"mtsLabel,omitempty"`
+
+	// Labels are the agent attributes used for selector-based policy
+	// matching. They replace any labels the agents claim.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ============================================================================
+// AgentIdentityBinding Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=aib
+// +kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".spec.serviceAccount.namespace",description="ServiceAccount namespace"
+// +kubebuilder:printcolumn:name="Service Account",type="string",JSONPath=".spec.serviceAccount.name",description="ServiceAccount name"
+// +kubebuilder:printcolumn:name="Agent Type",type="string",JSONPath=".spec.agentType",description="Bound agent type"
+// +kubebuilder:printcolumn:name="Tenant",type="string",JSONPath=".spec.tenantID",description="Bound tenant"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AgentIdentityBinding is the Schema for the agentidentitybindings API.
+// It is cluster-scoped, so that only cluster administrators, not the
+// owners of a namespace, decide which agent type and tenant its
+// ServiceAccounts act as.
+type AgentIdentityBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AgentIdentityBindingSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgentIdentityBindingList contains a list of AgentIdentityBinding resources.
+type AgentIdentityBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentIdentityBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AgentIdentityBinding{}, &AgentIdentityBindingList{})
+}
@@ -4,6 +4,7 @@
 package v1alpha1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +37,29 @@ const (
 	EnforcementModeEnforcing EnforcementMode = "enforcing"
 )
 
+// RegoTemplateVersion selects the Rego generator for a policy.
+// +kubebuilder:validation:Enum=v1;v2
+type RegoTemplateVersion string
+
+const (
+	// RegoTemplateV1 is the single-module compatibility template.
+	RegoTemplateV1 RegoTemplateVersion = "v1"
+	// RegoTemplateV2 generates per-tool packages behind an entrypoint.
+	RegoTemplateV2 RegoTemplateVersion = "v2"
+)
+
+// ProfileAction controls how calls outside a learned behavior profile are
+// handled.
+// +kubebuilder:validation:Enum=flag;deny
+type ProfileAction string
+
+const (
+	// ProfileActionFlag allows the call and records the deviation in the audit log.
+	ProfileActionFlag ProfileAction = "flag"
+	// ProfileActionDeny denies the call.
+	ProfileActionDeny ProfileAction = "deny"
+)
+
 // MTSEnforceMode controls multi-tenant sandboxing strictness.
 // +kubebuilder:validation:Enum=strict;permissive;disabled
 type MTSEnforceMode string
@@ -51,23 +75,39 @@ const (
 
 // ToolConstraints define conditional access rules for tool permissions.
 // These constraints mirror SELinux's fine-grained object class permissions.
+// +kubebuilder:validation:XValidation:rule="!has(self.allowedDomains) || !has(self.deniedDomains) || !self.allowedDomains.exists(d, d in self.deniedDomains)",message="a domain cannot be listed in both allowedDomains and deniedDomains"
+// +kubebuilder:validation:XValidation:rule="!has(self.timeout) || duration(self.timeout) >= duration('0s')",message="timeout must be a duration such as \"60s\" or \"5m\""
 type ToolConstraints struct {
 	// PathPatterns are glob patterns for file operations.
+	// Request paths are cleaned before matching, and paths containing ".."
+	// never match.
 	// Example: "/workspace/**", "/tmp/**"
 	// +optional
 	// +listType=atomic
 	PathPatterns []string `json:"pathPatterns,omitempty"`
 
+	// ResolveSymlinks resolves symlinks in request paths before matching
+	// PathPatterns. Only enable it when the policy engine shares the
+	// filesystem of the sandbox that opens the path.
+	// +optional
+	ResolveSymlinks bool `json:"resolveSymlinks,omitempty"`
+
 	// AllowedDomains are permitted domains for network operations.
 	// Supports wildcards: "*.github.com"
+	// Domains are compared case-insensitively, without a trailing dot, and
+	// with internationalized names in punycode ("xn--") form.
 	// +optional
 	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=100
+	// +kubebuilder:validation:items:MaxLength=253
 	AllowedDomains []string `json:"allowedDomains,omitempty"`
 
 	// DeniedDomains are explicitly blocked domains for network operations.
 	// Takes precedence over AllowedDomains.
 	// +optional
 	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=100
+	// +kubebuilder:validation:items:MaxLength=253
 	DeniedDomains []string `json:"deniedDomains,omitempty"`
 
 	// AllowedPorts are permitted ports for network operations.
@@ -82,15 +122,236 @@ type ToolConstraints struct {
 	// +kubebuilder:validation:Minimum=0
 	MaxSizeBytes *int64 `json:"maxSizeBytes,omitempty"`
 
-	// Timeout is the maximum execution time for operations.
+	// Timeout is the maximum execution time for operations. The router
+	// cancels a call still executing at the deadline and fails it.
 	// Example: "60s", "5m"
 	// +optional
 	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(s|m|h))+$`
+	// +kubebuilder:validation:MaxLength=32
 	Timeout string `json:"timeout,omitempty"`
+
+	// MaxConcurrent is the maximum number of executions of the tool in
+	// flight at once for each sandbox (or, for calls without a sandbox ID,
+	// each session). The router rejects calls past it with
+	// RESOURCE_EXHAUSTED.
+	// Example: 4
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrent *int32 `json:"maxConcurrent,omitempty"`
+
+	// Retry executes calls again whose execution fails with a transient
+	// error, such as a dropped connection. Each attempt is bounded by
+	// Timeout. Every attempt carries the call's request ID, which
+	// executors use to avoid applying a write twice.
+	// +optional
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// RequiredAgentLabels are labels the requesting agent must carry
+	// (from RequestMetadata.labels) for the permission to apply.
+	// Example: {"environment": "production"}
+	// +optional
+	RequiredAgentLabels map[string]string `json:"requiredAgentLabels,omitempty"`
+
+	// AllowedContentHashes are SHA-256 digests of the scripts or artifacts
+	// the tool may run (allow-by-artifact, e.g. for code.execute). Requests
+	// must carry a matching content_sha256 parameter.
+	// Example: ["sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"]
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(sha256:)?[a-fA-F0-9]{64}$`
+	AllowedContentHashes []string `json:"allowedContentHashes,omitempty"`
+
+	// Conditions restrict the permission to the environment the router
+	// runs in and, for network tools, to destinations in given places.
+	// +optional
+	Conditions *ToolConditions `json:"conditions,omitempty"`
+
+	// Modbus limits Modbus requests by function code and register address.
+	// +optional
+	Modbus *ModbusConstraints `json:"modbus,omitempty"`
+
+	// OPCUA limits OPC-UA requests by node ID and operation.
+	// +optional
+	OPCUA *OPCUAConstraints `json:"opcua,omitempty"`
+
+	// Custom configures constraint kinds implemented by checkers compiled
+	// into the router, keyed by kind. Values are passed to the checker as
+	// JSON. A kind the router has no checker for denies the request.
+	// Example: {"git-branch": {"allowed": ["main", "release/*"]}}
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Custom map[string]apiextensionsv1.JSON `json:"custom,omitempty"`
+
+	// ValuesFrom adds values held in ConfigMaps and Secrets of the
+	// policy's namespace to the list constraints above, such as the
+	// internal domains a network team maintains. The controller reads them
+	// when it compiles the policy, and compiles it again when they change.
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	ValuesFrom []ConstraintValuesSource `json:"valuesFrom,omitempty"`
+}
+
+// RetryPolicy configures the retries of a tool's transiently failed
+// executions.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a call is executed at most, the first
+	// attempt included.
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=10
+	MaxAttempts int32 `json:"maxAttempts"`
+
+	// Backoff is the delay before the first retry, doubled before each
+	// further one (default "100ms").
+	// Example: "250ms"
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ms|s|m))+$`
+	// +kubebuilder:validation:MaxLength=32
+	Backoff string `json:"backoff,omitempty"`
+}
+
+// ConstraintValuesSource adds the values of a key of a ConfigMap or Secret
+// to a list constraint. The key holds one value per line; blank lines and
+// lines starting with "#" are skipped.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="exactly one of configMapKeyRef and secretKeyRef must be set"
+type ConstraintValuesSource struct {
+	// Field is the list constraint the values are added to.
+	// +kubebuilder:validation:Enum=pathPatterns;allowedDomains;deniedDomains;allowedContentHashes
+	Field string `json:"field"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *ValuesKeyRef `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret. The policy's rendered Rego
+	// is not written to a ConfigMap, and the values are redacted from its
+	// status.
+	// +optional
+	SecretKeyRef *ValuesKeyRef `json:"secretKeyRef,omitempty"`
+}
+
+// ValuesKeyRef selects a key of a ConfigMap or Secret in the namespace of
+// the AgentPolicy.
+type ValuesKeyRef struct {
+	// Name is the name of the ConfigMap or Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the values.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// Optional adds no values if the object or key does not exist, rather
+	// than failing the compilation of the policy.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ModbusConstraints restrict Modbus requests for OT deployments. Requests
+// carry function_code, address, and quantity parameters; missing
+// parameters a constraint needs are denied.
+type ModbusConstraints struct {
+	// AllowedFunctionCodes are the permitted Modbus function codes.
+	// Example: [3, 4] (read holding and input registers)
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=127
+	AllowedFunctionCodes []int32 `json:"allowedFunctionCodes,omitempty"`
+
+	// AllowedRegisters are the address ranges a request may cover, in the
+	// addressing convention the tool uses.
+	// Example: [{start: 40001, end: 40100}]
+	// +optional
+	// +listType=atomic
+	AllowedRegisters []RegisterRange `json:"allowedRegisters,omitempty"`
+
+	// ReadOnly limits requests to the read function codes 1-4.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// RegisterRange is an inclusive range of Modbus addresses.
+// +kubebuilder:validation:XValidation:rule="self.start <= self.end",message="start must not be greater than end"
+type RegisterRange struct {
+	// Start is the first address in the range.
+	// +kubebuilder:validation:Minimum=0
+	Start int32 `json:"start"`
+
+	// End is the last address in the range.
+	// +kubebuilder:validation:Minimum=0
+	End int32 `json:"end"`
+}
+
+// OPCUAConstraints restrict OPC-UA requests for OT deployments. Requests
+// carry node_id or node_ids and operation parameters.
+type OPCUAConstraints struct {
+	// AllowedNodeIDs are the node IDs a request may address. A trailing "*"
+	// matches by prefix.
+	// Example: ["ns=2;s=Line1/*", "i=2258"]
+	// +optional
+	// +listType=set
+	AllowedNodeIDs []string `json:"allowedNodeIds,omitempty"`
+
+	// ReadOnly limits requests to the read, browse, subscribe, and
+	// history_read operations.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// ToolConditions restrict a permission to the environment the router runs
+// in (its --cluster-name, --environment, and --node-zone) and, for network
+// tools, to destinations in given places, looked up in the router's GeoIP
+// table. Every set field must be satisfied; lists match any entry.
+// Example, to only allow network.fetch to EU endpoints from EU clusters:
+// {"zones": ["eu-*"], "destinationContinents": ["EU"]}
+type ToolConditions struct {
+	// Clusters are the cluster names (glob patterns) the router must run in.
+	// +optional
+	// +listType=set
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Environments are the environments the router must run in.
+	// Example: ["production"]
+	// +optional
+	// +listType=set
+	Environments []string `json:"environments,omitempty"`
+
+	// Zones are the node zones (glob patterns) the router must run in.
+	// Example: ["eu-west-*"]
+	// +optional
+	// +listType=set
+	Zones []string `json:"zones,omitempty"`
+
+	// DestinationCountries are the ISO 3166-1 alpha-2 codes of the
+	// countries every address of the destination must be in.
+	// Example: ["DE", "FR"]
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z]{2}$`
+	DestinationCountries []string `json:"destinationCountries,omitempty"`
+
+	// DestinationContinents are the continent codes every address of the
+	// destination must be in.
+	// Example: ["EU"]
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z]{2}$`
+	DestinationContinents []string `json:"destinationContinents,omitempty"`
+
+	// DestinationASNs are the autonomous systems every address of the
+	// destination must be announced by.
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=4294967295
+	DestinationASNs []int64 `json:"destinationASNs,omitempty"`
 }
 
 // ToolPermission defines access rules for a specific tool.
 // This is analogous to SELinux type enforcement rules.
+// +kubebuilder:validation:XValidation:rule="self.action == 'allow' || !has(self.constraints)",message="constraints are only valid when action is allow"
 type ToolPermission struct {
 	// Tool is the name of the tool being controlled.
 	// Examples: "file.read", "file.write", "network.fetch", "code.execute"
@@ -104,9 +365,116 @@ type ToolPermission struct {
 	Action DecisionAction `json:"action"`
 
 	// Constraints are optional conditions that must be met for the permission.
-	// Only applies when Action is "allow".
+	// Only valid when Action is "allow".
 	// +optional
 	Constraints *ToolConstraints `json:"constraints,omitempty"`
+
+	// DenyMessage is a user-facing message returned to the agent's end user
+	// when this rule denies a request. It is separate from the audit reason.
+	// Placeholders are filled from the request: {tool}, {agent_type},
+	// {tenant}, or any request parameter such as {path} or {domain}.
+	// Example: "You can only edit files under /workspace, not {path}"
+	// +optional
+	// +kubebuilder:validation:MaxLength=1024
+	DenyMessage string `json:"denyMessage,omitempty"`
+
+	// LocalizedDenyMessages are translations of DenyMessage keyed by BCP 47
+	// language tag, chosen by the locale in the request metadata.
+	// Example: {"de": "Sie dürfen nur Dateien unter /workspace bearbeiten"}
+	// +optional
+	LocalizedDenyMessages map[string]string `json:"localizedDenyMessages,omitempty"`
+
+	// Mutators rewrite the request parameters of allowed calls before they
+	// are checked against Constraints and executed, in order.
+	// Only applies when Action is "allow".
+	// +optional
+	Mutators []ParameterMutator `json:"mutators,omitempty"`
+
+	// Obligations are duties the router must fulfil before it executes an
+	// allowed call, such as logging the full payload or notifying a channel.
+	// If any obligation cannot be fulfilled, the call fails.
+	// Only applies when Action is "allow".
+	// +optional
+	Obligations []Obligation `json:"obligations,omitempty"`
+
+	// Cost is what each allowed call to the tool is charged against the
+	// policy's budget, in the policy's unit of spend.
+	// Example: 100 for gpu.run, 1 for file.read
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Cost int64 `json:"cost,omitempty"`
+
+	// RiskLevel rates the tool for the router's risk rules, such as
+	// requiring approval of high-risk tools. It may raise the level the
+	// tool's ToolCatalog declares, but not lower it.
+	// +optional
+	RiskLevel ToolRiskLevel `json:"riskLevel,omitempty"`
+}
+
+// Obligation is a duty attached to an allowed tool call.
+type Obligation struct {
+	// Type identifies the duty. Well-known types are logPayload, notify
+	// (requires params.channel), readOnlySandbox, and injectCredential
+	// (requires params.path, the credential broker path); other types must
+	// be supported by the router's tool executor.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Type string `json:"type"`
+
+	// Params configure the duty.
+	// Example: {"channel": "#security-alerts"}
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// MutatorType is the kind of parameter rewrite a mutator performs.
+// +kubebuilder:validation:Enum=set;clamp;prefixRelativePath;removeKey
+type MutatorType string
+
+const (
+	// MutatorSet assigns Value to the parameter (e.g., force read_only: true).
+	MutatorSet MutatorType = "set"
+	// MutatorClamp caps a numeric parameter at Max (e.g., size).
+	MutatorClamp MutatorType = "clamp"
+	// MutatorPrefixRelativePath joins a relative path onto Root.
+	MutatorPrefixRelativePath MutatorType = "prefixRelativePath"
+	// MutatorRemoveKey deletes Key from a map parameter (case-insensitive),
+	// or the parameter itself if Key is empty (e.g., Authorization from headers).
+	MutatorRemoveKey MutatorType = "removeKey"
+)
+
+// ParameterMutator rewrites one request parameter of an allowed tool call.
+type ParameterMutator struct {
+	// Type is the kind of rewrite.
+	// +kubebuilder:validation:Required
+	Type MutatorType `json:"type"`
+
+	// Param is the request parameter to rewrite.
+	// Examples: "path", "size", "read_only", "headers"
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Param string `json:"param"`
+
+	// Value is the JSON-encoded value assigned by a set mutator.
+	// Examples: "true", "1024", "\"ro\""
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Max is the upper bound applied by a clamp mutator.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Max *int64 `json:"max,omitempty"`
+
+	// Root is the absolute directory a prefixRelativePath mutator joins
+	// relative paths onto.
+	// Example: "/workspace"
+	// +optional
+	Root string `json:"root,omitempty"`
+
+	// Key is the entry a removeKey mutator deletes from a map parameter.
+	// Example: "Authorization"
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // ============================================================================
@@ -117,9 +485,10 @@ type ToolPermission struct {
 // This is analogous to SELinux's Multi-Category Security (MCS).
 type MTSConfig struct {
 	// MTSLabel is the security label for tenant isolation.
-	// Format follows SELinux MCS convention: "s0:c100,c200"
+	// Format follows SELinux MCS convention: "s0:c100,c200", with optional
+	// sensitivity and category ranges: "s0-s2:c10.c20,c42"
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern=`^s[0-9]+(:c[0-9]+(,c[0-9]+)*)?$`
+	// +kubebuilder:validation:Pattern=`^s[0-9]+(-s[0-9]+)?(:c[0-9]+(\.c[0-9]+)?(,c[0-9]+(\.c[0-9]+)?)*)?$`
 	MTSLabel string `json:"mtsLabel"`
 
 	// EnforceMode controls how MTS violations are handled.
@@ -144,6 +513,120 @@ type PolicyReference struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// ProfileEnforcement judges calls the policy allows against the learned
+// behavior baseline of the agent type (its AgentProfile).
+type ProfileEnforcement struct {
+	// Action is applied to calls using a tool, parameter, or parameter value
+	// the profile never observed.
+	// +kubebuilder:default=flag
+	Action ProfileAction `json:"action,omitempty"`
+
+	// MinSamples is the number of calls a profile must have been learned
+	// from before it is enforced. Agent types without a profile, or with a
+	// smaller one, are not judged.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinSamples int64 `json:"minSamples,omitempty"`
+}
+
+// RateLimitSpec caps the request rate of each client (sandbox or tenant,
+// as configured on the router) an AgentPolicy applies to, overriding the
+// router's default limit.
+type RateLimitSpec struct {
+	// RequestsPerSecond is the sustained request rate of each client.
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int32 `json:"requestsPerSecond"`
+
+	// Burst is the number of requests a client may make at once.
+	// Defaults to requestsPerSecond.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// BudgetSpec caps the cumulative cost of the calls an AgentPolicy allows,
+// as weighted by the cost of their tool rules.
+type BudgetSpec struct {
+	// Session limits the spend of each session, including that of the
+	// sub-agents it delegates to (see the lineage of request metadata).
+	// +optional
+	Session *BudgetLimit `json:"session,omitempty"`
+
+	// Tenant limits the spend of each tenant, across all its sessions.
+	// +optional
+	Tenant *BudgetLimit `json:"tenant,omitempty"`
+
+	// Period is how often spend starts over, e.g. "24h" for daily budgets.
+	// Spend never starts over if unset.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(s|m|h))+$`
+	// +kubebuilder:validation:MaxLength=32
+	Period string `json:"period,omitempty"`
+}
+
+// BudgetLimit is the spend thresholds of one budget scope.
+type BudgetLimit struct {
+	// Limit is the spend past which calls are denied.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Limit int64 `json:"limit,omitempty"`
+
+	// ApprovalThreshold is the spend past which calls require a human's
+	// approval (an "approval" obligation) before they execute.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ApprovalThreshold int64 `json:"approvalThreshold,omitempty"`
+}
+
+// DecisionCacheSpec tunes the decision cache of an AgentPolicy.
+type DecisionCacheSpec struct {
+	// TTL is how long routers cache the policy's decisions, e.g. "5m",
+	// overriding the router's --cache-ttl. Policy updates invalidate
+	// cached decisions regardless.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ms|s|m|h))+$`
+	// +kubebuilder:validation:MaxLength=32
+	TTL string `json:"ttl,omitempty"`
+
+	// Warmup lists calls whose decisions routers compute and cache as soon
+	// as they load the policy, and again whenever another policy update
+	// invalidates them. Calls are warmed for the policy's exact agent
+	// types, not its patterns.
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	Warmup []CacheWarmupCall `json:"warmup,omitempty"`
+}
+
+// CacheWarmupCall is a call whose decision is cached ahead of time.
+type CacheWarmupCall struct {
+	// Tool is the tool called.
+	// +kubebuilder:validation:MinLength=1
+	Tool string `json:"tool"`
+
+	// Parameters is the JSON object of the call's parameters. Decisions
+	// are cached per tool, so parameters only matter through the ones
+	// mutators rewrite and the content_sha256 of exec calls.
+	// +optional
+	Parameters *apiextensionsv1.JSON `json:"parameters,omitempty"`
+}
+
+// CanarySpec makes an AgentPolicy the new version of another, rolled out to
+// a share of the sandboxes the other applies to.
+type CanarySpec struct {
+	// Stable is the name of the AgentPolicy, in the same namespace, that
+	// this policy is a new version of.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Stable string `json:"stable"`
+
+	// Percent is the share of sandboxes (or, for calls without a sandbox
+	// ID, sessions) on this version. Sandboxes are bucketed by a hash of
+	// their ID, so raising it only moves more of them onto this version.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percent int32 `json:"percent"`
+}
+
 // ============================================================================
 // AgentPolicy Spec and Status
 // ============================================================================
@@ -155,12 +638,19 @@ type AgentPolicySpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// AgentTypes is a list of agent types this policy applies to.
-	// Example: ["coding-assistant", "code-reviewer"]
+	// Entries may be glob patterns; the most specific match wins.
+	// Example: ["coding-assistant", "code-reviewer", "coding-*"]
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinItems=1
 	// +listType=set
 	AgentTypes []string `json:"agentTypes"`
 
+	// AgentSelector restricts the policy to agents whose labels
+	// (RequestMetadata.labels) match the selector.
+	// Example: {"matchLabels": {"environment": "production"}}
+	// +optional
+	AgentSelector *metav1.LabelSelector `json:"agentSelector,omitempty"`
+
 	// DefaultAction for tools not explicitly listed in ToolPermissions.
 	// +kubebuilder:validation:Required
 	// +kubebuilder:default=deny
@@ -173,16 +663,73 @@ type AgentPolicySpec struct {
 	Mode EnforcementMode `json:"mode,omitempty"`
 
 	// ToolPermissions is the list of explicit tool permission rules.
-	// Rules are evaluated in order; first match wins.
+	// Rules match tool names exactly, so each tool may be listed once and
+	// the order of rules does not matter. A tool listed twice is rejected
+	// when the policy is compiled, rather than one rule silently winning.
 	// +optional
 	// +listType=map
 	// +listMapKey=tool
+	// +kubebuilder:validation:MaxItems=256
 	ToolPermissions []ToolPermission `json:"toolPermissions,omitempty"`
 
 	// TenantIsolation configures Multi-Tenant Sandboxing (MTS).
 	// When set, cross-tenant access is controlled based on MTS labels.
 	// +optional
 	TenantIsolation *MTSConfig `json:"tenantIsolation,omitempty"`
+
+	// Fallback designates this policy as the cluster fallback.
+	// The fallback policy applies to any agent type with no specific policy,
+	// giving new agent types a safe baseline instead of blanket denial.
+	// Only one AgentPolicy should be marked as the fallback: if several
+	// are, the oldest is, and the others report a Conflict in their
+	// Fallback condition.
+	// +optional
+	Fallback bool `json:"fallback,omitempty"`
+
+	// Priority ranks this policy among the policies that apply to an
+	// agent, such as the policy of its agent type and the policy of an
+	// agent type pattern, when the router combines them by priority
+	// (--policy-combining=priority): the highest-priority policy with a
+	// rule for the tool decides. Other combining algorithms ignore it.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// RegoTemplate selects the Rego generator used when OPA is enabled.
+	// "v2" generates one package per tool behind an entrypoint; "v1" is the
+	// original single-module template, kept for compatibility.
+	// +kubebuilder:validation:Enum=v1;v2
+	// +kubebuilder:default=v2
+	// +optional
+	RegoTemplate RegoTemplateVersion `json:"regoTemplate,omitempty"`
+
+	// Profile enforces the learned behavior baseline of the agent type on
+	// calls the policy allows.
+	// +optional
+	Profile *ProfileEnforcement `json:"profile,omitempty"`
+
+	// RateLimit caps the request rate of each client the policy applies
+	// to, overriding the router's default limit.
+	// +optional
+	RateLimit *RateLimitSpec `json:"rateLimit,omitempty"`
+
+	// Budget caps the cumulative cost of the calls the policy allows per
+	// session and tenant, denying calls or requiring approval past its
+	// thresholds.
+	// +optional
+	Budget *BudgetSpec `json:"budget,omitempty"`
+
+	// Cache tunes how routers cache the policy's decisions, for agents
+	// whose first call after a policy update must not wait on evaluation.
+	// +optional
+	Cache *DecisionCacheSpec `json:"cache,omitempty"`
+
+	// Canary makes this policy the new version of another AgentPolicy,
+	// enforced on a percentage of the sandboxes the other applies to while
+	// the rest stay on it. A canary is not bound to its own agentTypes and
+	// cannot be the fallback. To complete a rollout, copy its spec into the
+	// stable policy and delete it.
+	// +optional
+	Canary *CanarySpec `json:"canary,omitempty"`
 }
 
 // AgentPolicyStatus defines the observed state of AgentPolicy.
@@ -196,6 +743,11 @@ type AgentPolicyStatus struct {
 	// +optional
 	CompiledHash string `json:"compiledHash,omitempty"`
 
+	// LastChangeSummary is a human-readable summary of the last semantic
+	// change to the policy (e.g., "1 rule added, 1 constraint loosened").
+	// +optional
+	LastChangeSummary string `json:"lastChangeSummary,omitempty"`
+
 	// LastUpdated is the timestamp of the last policy compilation.
 	// +optional
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
@@ -214,6 +766,77 @@ type AgentPolicyStatus struct {
 	// ObservedGeneration is the most recent generation observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// AgentTypes reports, for each agent type in the spec, whether the
+	// policy was loaded into the engine under it.
+	// +optional
+	// +listType=map
+	// +listMapKey=agentType
+	AgentTypes []AgentTypeStatus `json:"agentTypes,omitempty"`
+
+	// Replicas lists the live router replicas that have loaded the policy,
+	// with the generation each loaded. Replicas report through heartbeat
+	// Leases; a replica missing here has not loaded any generation.
+	// +optional
+	// +listType=map
+	// +listMapKey=identity
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+
+	// OPAEnabled is true if the policy was compiled to Rego and is
+	// evaluated with OPA.
+	// +optional
+	OPAEnabled bool `json:"opaEnabled,omitempty"`
+
+	// LoadedAgentTypes is the number of agent types in the spec the policy
+	// is loaded under (see AgentTypes).
+	// +optional
+	LoadedAgentTypes int32 `json:"loadedAgentTypes,omitempty"`
+
+	// LastError is the error of the last reconcile, if it failed.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// RenderedRegoRef points at the ConfigMap holding the Rego generated
+	// for the policy, if the controller renders it for inspection.
+	// +optional
+	RenderedRegoRef *RenderedRegoRef `json:"renderedRegoRef,omitempty"`
+}
+
+// RenderedRegoRef references the generated Rego of a policy: a key of a
+// ConfigMap in the policy's namespace.
+type RenderedRegoRef struct {
+	// Name is the name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Key is the ConfigMap key holding the Rego.
+	Key string `json:"key"`
+}
+
+// AgentTypeStatus is the load result of a policy for one agent type.
+type AgentTypeStatus struct {
+	// AgentType is the agent type or pattern from the spec.
+	AgentType string `json:"agentType"`
+
+	// Loaded is true if the policy is bound to the agent type in the engine.
+	Loaded bool `json:"loaded"`
+
+	// Reason is a CamelCase reason for the result (e.g., "Loaded",
+	// "InvalidPattern").
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human-readable explanation of the result.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ReplicaStatus records that a router replica loaded the policy.
+type ReplicaStatus struct {
+	// Identity is the replica's heartbeat identity (its pod name).
+	Identity string `json:"identity"`
+
+	// ObservedGeneration is the policy generation the replica loaded.
+	ObservedGeneration int64 `json:"observedGeneration"`
 }
 
 // ============================================================================
@@ -225,7 +848,12 @@ type AgentPolicyStatus struct {
 // +kubebuilder:resource:shortName=ap;agpol
 // +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode",description="Enforcement mode"
 // +kubebuilder:printcolumn:name="Default",type="string",JSONPath=".spec.defaultAction",description="Default action"
+// +kubebuilder:printcolumn:name="Fallback",type="boolean",JSONPath=".spec.fallback",description="Cluster fallback policy",priority=1
+// +kubebuilder:printcolumn:name="OPA",type="boolean",JSONPath=".status.opaEnabled",description="Evaluated with OPA"
+// +kubebuilder:printcolumn:name="Agent Types",type="integer",JSONPath=".status.loadedAgentTypes",description="Agent types the policy is loaded under"
 // +kubebuilder:printcolumn:name="Bindings",type="integer",JSONPath=".status.activeBindings",description="Active sandbox bindings"
+// +kubebuilder:printcolumn:name="Hash",type="string",JSONPath=".status.compiledHash",description="Hash of the generated Rego",priority=1
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.lastError",description="Error of the last reconcile",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AgentPolicy is the Schema for the agentpolicies API.
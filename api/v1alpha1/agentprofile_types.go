@@ -0,0 +1,135 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// Behavior Baseline Types
+// ============================================================================
+
+// ValueCount is an observed parameter value and the number of calls that
+// used it.
+type ValueCount struct {
+	// Value is the observed value. Paths are recorded by directory and
+	// domains in canonical form.
+	Value string `json:"value"`
+
+	// Count is the number of calls that used the value.
+	Count int64 `json:"count"`
+}
+
+// ParameterBaseline is the learned distribution of one tool parameter.
+type ParameterBaseline struct {
+	// Name is the parameter name (e.g., "path", "domain").
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Count is the number of calls that carried the parameter.
+	Count int64 `json:"count"`
+
+	// Values are the observed string and boolean values.
+	// +optional
+	// +listType=map
+	// +listMapKey=value
+	Values []ValueCount `json:"values,omitempty"`
+
+	// Unbounded is set when too many distinct values were observed to
+	// record; any value is then within the baseline.
+	// +optional
+	Unbounded bool `json:"unbounded,omitempty"`
+
+	// Min is the smallest integer value observed.
+	// +optional
+	Min *int64 `json:"min,omitempty"`
+
+	// Max is the largest integer value observed.
+	// +optional
+	Max *int64 `json:"max,omitempty"`
+}
+
+// ToolBaseline is the learned usage of one tool.
+type ToolBaseline struct {
+	// Tool is the name of the tool (e.g., "file.read").
+	// +kubebuilder:validation:Required
+	Tool string `json:"tool"`
+
+	// Count is the number of calls observed.
+	Count int64 `json:"count"`
+
+	// Parameters are the baselines of the parameters observed.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Parameters []ParameterBaseline `json:"parameters,omitempty"`
+}
+
+// ============================================================================
+// AgentProfile Spec and Status
+// ============================================================================
+
+// AgentProfileSpec is the learned behavior baseline of an agent type.
+// It is generated from audit traffic ("apctl profile") and may be reviewed
+// and edited before it is applied.
+type AgentProfileSpec struct {
+	// AgentType is the agent type the profile describes.
+	// +kubebuilder:validation:Required
+	AgentType string `json:"agentType"`
+
+	// Samples is the number of calls the profile was learned from.
+	// +kubebuilder:validation:Minimum=0
+	Samples int64 `json:"samples"`
+
+	// Tools are the baselines of the tools observed. Tools not listed are
+	// outside the baseline.
+	// +optional
+	// +listType=map
+	// +listMapKey=tool
+	Tools []ToolBaseline `json:"tools,omitempty"`
+}
+
+// AgentProfileStatus defines the observed state of AgentProfile.
+type AgentProfileStatus struct {
+	// LoadedAt is when the profile was last loaded into the policy engine.
+	// +optional
+	LoadedAt *metav1.Time `json:"loadedAt,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last loaded.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// ============================================================================
+// AgentProfile Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=aprof
+// +kubebuilder:printcolumn:name="Agent Type",type="string",JSONPath=".spec.agentType",description="Profiled agent type"
+// +kubebuilder:printcolumn:name="Samples",type="integer",JSONPath=".spec.samples",description="Calls learned from"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AgentProfile is the Schema for the agentprofiles API.
+// It captures the learned behavior baseline of an agent type, which
+// AgentPolicies with profile enforcement apply to the calls they allow.
+type AgentProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AgentProfileSpec   `json:"spec,omitempty"`
+	Status AgentProfileStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgentProfileList contains a list of AgentProfile resources.
+type AgentProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AgentProfile{}, &AgentProfileList{})
+}
@@ -0,0 +1,90 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// Tenant Spec and Status
+// ============================================================================
+
+// TenantSpec places a tenant in the tenant hierarchy.
+type TenantSpec struct {
+	// Parent is the name of the parent Tenant. Root tenants have none.
+	// +optional
+	Parent string `json:"parent,omitempty"`
+
+	// DelegateToParent grants the agents of the parent tenant access to the
+	// objects of this tenant: the parent's MTS label dominates the labels
+	// of the children that delegate to it, and of their delegating
+	// descendants. Tenants are isolated from their parent by default.
+	// +optional
+	DelegateToParent bool `json:"delegateToParent,omitempty"`
+}
+
+// TenantStatus defines the observed state of Tenant.
+type TenantStatus struct {
+	// Category is the MTS category allocated to the tenant. No other
+	// tenant is allocated the same category.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1023
+	Category *int32 `json:"category,omitempty"`
+
+	// ObjectLabel is the MTS label of the tenant's objects, which the
+	// AgentPolicies of the tenant set as their tenantIsolation.mtsLabel.
+	// +optional
+	ObjectLabel string `json:"objectLabel,omitempty"`
+
+	// MTSLabel is the MTS label the tenant's agents are evaluated with: the
+	// tenant's category and those of its delegating descendants.
+	// +optional
+	MTSLabel string `json:"mtsLabel,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last allocated.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the tenant's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ============================================================================
+// Tenant Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tn
+// +kubebuilder:printcolumn:name="Parent",type="string",JSONPath=".spec.parent",description="Parent tenant"
+// +kubebuilder:printcolumn:name="Delegated",type="boolean",JSONPath=".spec.delegateToParent",description="Delegated to the parent tenant"
+// +kubebuilder:printcolumn:name="Label",type="string",JSONPath=".status.mtsLabel",description="Agent MTS label"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Tenant is the Schema for the tenants API.
+// It registers a tenant, named by its tenant ID, in the tenant hierarchy,
+// and is allocated the tenant's MTS category, which the router resolves the
+// MTS labels of the tenant's calls from.
+type Tenant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantSpec   `json:"spec,omitempty"`
+	Status TenantStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TenantList contains a list of Tenant resources.
+type TenantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Tenant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Tenant{}, &TenantList{})
+}
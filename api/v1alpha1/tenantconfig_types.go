@@ -0,0 +1,66 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// TenantConfig Spec and Status
+// ============================================================================
+
+// TenantConfigSpec configures how the router treats the calls of a tenant.
+type TenantConfigSpec struct {
+	// Mode overrides the router's enforcement mode for the tenant's calls,
+	// such as to keep a tenant that is still onboarding permissive while
+	// the others are enforced. Unset, the tenant follows the router's mode.
+	// +optional
+	Mode EnforcementMode `json:"mode,omitempty"`
+}
+
+// TenantConfigStatus defines the observed state of TenantConfig.
+type TenantConfigStatus struct {
+	// ObservedGeneration is the generation of the spec last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the config's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ============================================================================
+// TenantConfig Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tcfg
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode",description="Enforcement mode"
+// +kubebuilder:printcolumn:name="Accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status",description="Config applied"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TenantConfig is the Schema for the tenantconfigs API.
+// It configures the router for one tenant, named by its tenant ID, over
+// the router-wide defaults.
+type TenantConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantConfigSpec   `json:"spec,omitempty"`
+	Status TenantConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TenantConfigList contains a list of TenantConfig resources.
+type TenantConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantConfig{}, &TenantConfigList{})
+}
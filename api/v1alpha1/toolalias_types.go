@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// ToolAlias Spec and Status
+// ============================================================================
+
+// ToolAliasSpec maps the raw names agents call a tool by to its canonical
+// name.
+type ToolAliasSpec struct {
+	// Tool is the canonical tool name policies are written in (e.g.,
+	// "file.read"). It must normalize to itself under the router's tool
+	// name normalization.
+	// +kubebuilder:validation:MinLength=1
+	Tool string `json:"tool"`
+
+	// Aliases are the raw names calls to Tool are made by (e.g.,
+	// "readFile" or "fs_read"), matched exactly and before normalization.
+	// An alias may be the alias of one tool only.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Aliases []string `json:"aliases"`
+}
+
+// ToolAliasStatus defines the observed state of ToolAlias.
+type ToolAliasStatus struct {
+	// ObservedGeneration is the generation of the spec last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the aliases' state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ============================================================================
+// ToolAlias Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=ta
+// +kubebuilder:printcolumn:name="Tool",type="string",JSONPath=".spec.tool",description="Canonical tool name"
+// +kubebuilder:printcolumn:name="Accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status",description="Aliases applied"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ToolAlias is the Schema for the toolaliases API.
+// It declares the raw names of a tool that the router normalizes calls to
+// the tool's canonical name from, for names its tool name normalization
+// rule does not rewrite as policies expect.
+type ToolAlias struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ToolAliasSpec   `json:"spec,omitempty"`
+	Status ToolAliasStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ToolAliasList contains a list of ToolAlias resources.
+type ToolAliasList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ToolAlias `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ToolAlias{}, &ToolAliasList{})
+}
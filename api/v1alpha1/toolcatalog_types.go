@@ -0,0 +1,107 @@
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// ToolCatalog Spec and Status
+// ============================================================================
+
+// ToolRiskLevel rates how much harm calls to a tool can do.
+// +kubebuilder:validation:Enum=low;medium;high;critical
+type ToolRiskLevel string
+
+const (
+	ToolRiskLow      ToolRiskLevel = "low"
+	ToolRiskMedium   ToolRiskLevel = "medium"
+	ToolRiskHigh     ToolRiskLevel = "high"
+	ToolRiskCritical ToolRiskLevel = "critical"
+)
+
+// CatalogTool describes a tool known to the cluster.
+type CatalogTool struct {
+	// Name is the canonical tool name policies are written in.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[a-z][a-z0-9]*(\.[a-z][a-z0-9]*)*$`
+	Name string `json:"name"`
+
+	// Description says what the tool does, for agents discovering it.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Category groups related tools (e.g., "filesystem" or "network").
+	// +optional
+	Category string `json:"category,omitempty"`
+
+	// RiskLevel rates how much harm calls to the tool can do.
+	// +optional
+	RiskLevel ToolRiskLevel `json:"riskLevel,omitempty"`
+
+	// Parameters is the JSON Schema of the tool's parameters.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Parameters *apiextensionsv1.JSON `json:"parameters,omitempty"`
+}
+
+// ToolCatalogSpec declares the tools a platform team provides.
+type ToolCatalogSpec struct {
+	// Tools are the tools of the catalog. A tool may be declared by one
+	// ToolCatalog only.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Tools []CatalogTool `json:"tools"`
+}
+
+// ToolCatalogStatus defines the observed state of ToolCatalog.
+type ToolCatalogStatus struct {
+	// ObservedGeneration is the generation of the spec last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the catalog's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ============================================================================
+// ToolCatalog Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tc
+// +kubebuilder:printcolumn:name="Accepted",type="string",JSONPath=".status.conditions[?(@.type==\"Accepted\")].status",description="Tools applied"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ToolCatalog is the Schema for the toolcatalogs API.
+// It declares known tools with their categories, risk levels, and
+// parameter schemas. AgentPolicies referencing tools no catalog declares
+// are warned of at admission, and agents discovering their allowed tools
+// are told what the catalog knows of them.
+type ToolCatalog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ToolCatalogSpec   `json:"spec,omitempty"`
+	Status ToolCatalogStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ToolCatalogList contains a list of ToolCatalog resources.
+type ToolCatalogList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ToolCatalog `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ToolCatalog{}, &ToolCatalogList{})
+}
@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ============================================================================
+// ToolKillSwitch Spec and Status
+// ============================================================================
+
+// ToolKillSwitchSpec names the tool a ToolKillSwitch disables.
+type ToolKillSwitchSpec struct {
+	// Tool is the canonical name of the tool to disable (e.g.,
+	// "network.fetch").
+	// +kubebuilder:validation:MinLength=1
+	Tool string `json:"tool"`
+
+	// Reason explains the switch. It is the reason of the denials, in
+	// audit events and in the message returned to agents.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// ExpiresAt lifts the switch once passed. Unset, the switch holds
+	// until the ToolKillSwitch is deleted.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// ToolKillSwitchStatus defines the observed state of ToolKillSwitch.
+type ToolKillSwitchStatus struct {
+	// ObservedGeneration is the generation of the spec last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the switch's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ============================================================================
+// ToolKillSwitch Resource Definition
+// ============================================================================
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tks
+// +kubebuilder:printcolumn:name="Tool",type="string",JSONPath=".spec.tool",description="Disabled tool"
+// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.conditions[?(@.type==\"Active\")].status",description="Tool disabled"
+// +kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".spec.expiresAt"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ToolKillSwitch is the Schema for the toolkillswitches API.
+// It disables a tool for every agent, overriding every AgentPolicy and the
+// enforcement mode, until it is deleted or expires. It is meant for
+// incidents, when editing each policy that allows the tool would be too
+// slow.
+type ToolKillSwitch struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ToolKillSwitchSpec   `json:"spec,omitempty"`
+	Status ToolKillSwitchStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ToolKillSwitchList contains a list of ToolKillSwitch resources.
+type ToolKillSwitchList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ToolKillSwitch `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ToolKillSwitch{}, &ToolKillSwitchList{})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/gitops"
+	"github.com/golden-agent/golden-agent/pkg/offline"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// runBundle implements "apctl bundle -key-file KEY -o BUNDLE.yaml
+// POLICY.yaml...": it checks that the policies compile, writes them to a
+// bundle for offline routers, and signs it next to it.
+func runBundle(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "snapshot":
+			return runBundleSnapshot(args[1:])
+		case "verify":
+			return runBundleVerify(args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "PEM Ed25519 private key to sign the bundle with (required)")
+	out := fs.String("o", "", "path of the bundle; the signature is written next to it with a .sig suffix (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl bundle -key-file KEY -o BUNDLE.yaml POLICY.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *keyFile == "" || *out == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	key, err := readPrivateKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle: %v\n", err)
+		return exitError
+	}
+
+	var bundle bytes.Buffer
+	seen := make(map[string]string)
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl bundle: %v\n", err)
+			return exitError
+		}
+		policies, err := gitops.ParseManifests(data, "default")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl bundle: %s: %v\n", path, err)
+			return exitError
+		}
+		for _, ap := range policies {
+			ref := ap.Namespace + "/" + ap.Name
+			if other, ok := seen[ref]; ok {
+				fmt.Fprintf(os.Stderr, "apctl bundle: %s: AgentPolicy %s is also defined in %s\n", path, ref, other)
+				return exitError
+			}
+			seen[ref] = path
+			if _, err := compile.AgentPolicy(ap, false); err != nil {
+				fmt.Fprintf(os.Stderr, "apctl bundle: %s: AgentPolicy %s: %v\n", path, ref, err)
+				return exitError
+			}
+		}
+		if bundle.Len() > 0 {
+			bundle.WriteString("---\n")
+		}
+		bundle.Write(data)
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			bundle.WriteString("\n")
+		}
+	}
+
+	if err := writeSignedBundle(*out, bundle.Bytes(), key); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "apctl bundle: wrote %d policies to %s\n", len(seen), *out)
+	return exitOK
+}
+
+// runBundleSnapshot implements "apctl bundle snapshot -from SNAPSHOT
+// -key-file KEY -o BUNDLE.yaml": it writes the policies of a router's
+// engine snapshot to a signed bundle, to back them up or to promote them
+// to another cluster. The snapshot is read from a file, or from the
+// /snapshot page of the router's inspection UI, which is not
+// authenticated and must only be reachable by operators (see
+// router.Server.InspectHandler). The router has no API to export or
+// import policies.
+func runBundleSnapshot(args []string) int {
+	fs := flag.NewFlagSet("bundle snapshot", flag.ContinueOnError)
+	from := fs.String("from", "", "URL of a router's /snapshot page, or a snapshot file (required)")
+	keyFile := fs.String("key-file", "", "PEM Ed25519 private key to sign the bundle with (required)")
+	out := fs.String("o", "", "path of the bundle; the signature is written next to it with a .sig suffix (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl bundle snapshot -from SNAPSHOT -key-file KEY -o BUNDLE.yaml")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *from == "" || *keyFile == "" || *out == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	key, err := readPrivateKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: %v\n", err)
+		return exitError
+	}
+	data, err := readSnapshot(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: failed to read snapshot from %s: %v\n", *from, err)
+		return exitError
+	}
+	var snapshot policy.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: invalid snapshot from %s: %v\n", *from, err)
+		return exitError
+	}
+	bundle, err := offline.ExportBundle(&snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: %v\n", err)
+		return exitError
+	}
+	if err := writeSignedBundle(*out, bundle, key); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: %v\n", err)
+		return exitError
+	}
+
+	unresolved := 0
+	for _, p := range snapshot.Policies {
+		if p.Unresolved {
+			unresolved++
+		}
+	}
+	if unresolved > 0 {
+		fmt.Fprintf(os.Stderr, "apctl bundle snapshot: %d policies read constraint values from Secrets, which the cluster they are applied to must hold\n", unresolved)
+	}
+	fmt.Fprintf(os.Stderr, "apctl bundle snapshot: wrote %d policies to %s\n", len(snapshot.Policies), *out)
+	return exitOK
+}
+
+// runBundleVerify implements "apctl bundle verify -public-key-file KEY
+// BUNDLE.yaml": it verifies the signature of a bundle, and that its
+// policies compile to the fingerprints they were snapshotted with, and
+// prints it. It loads nothing: the policies are enforced once applied to
+// the cluster, e.g. with "kubectl apply -f -", or once an offline router
+// loads the bundle.
+func runBundleVerify(args []string) int {
+	fs := flag.NewFlagSet("bundle verify", flag.ContinueOnError)
+	keyFile := fs.String("public-key-file", "", "PEM Ed25519 public key to verify the bundle with (required)")
+	useOPA := fs.Bool("opa", true, "compile policies as a router evaluating them with OPA does")
+	namespace := fs.String("namespace", "default", "namespace of policies without one")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl bundle verify -public-key-file KEY BUNDLE.yaml")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *keyFile == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	path := fs.Arg(0)
+
+	pem, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %v\n", err)
+		return exitError
+	}
+	key, err := offline.ParsePublicKey(pem)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %s: %v\n", *keyFile, err)
+		return exitError
+	}
+
+	// The bytes verified are the bytes printed
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %v\n", err)
+		return exitError
+	}
+	signature, err := os.ReadFile(path + offline.SignatureSuffix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: failed to read bundle signature: %v\n", err)
+		return exitError
+	}
+	if err := offline.Verify(data, signature, key); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %s: %v\n", path, err)
+		return exitError
+	}
+	policies, err := gitops.ParseManifests(data, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %s: %v\n", path, err)
+		return exitError
+	}
+	if err := offline.CheckFingerprints(policies, *useOPA); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %s: %v\n", path, err)
+		return exitError
+	}
+
+	if _, err := os.Stdout.Write(data); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl bundle verify: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "apctl bundle verify: verified %d policies of %s\n", len(policies), path)
+	return exitOK
+}
+
+// readPrivateKey reads the PEM Ed25519 private key bundles are signed with.
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := offline.ParsePrivateKey(pem)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// writeSignedBundle writes a bundle to path, and its signature with key
+// next to it.
+func writeSignedBundle(path string, bundle []byte, key ed25519.PrivateKey) error {
+	if err := os.WriteFile(path, bundle, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+offline.SignatureSuffix, offline.Sign(bundle, key), 0o644)
+}
+
+// readSnapshot reads an engine snapshot from an http(s) URL or a file.
+func readSnapshot(from string) ([]byte, error) {
+	if !strings.HasPrefix(from, "http://") && !strings.HasPrefix(from, "https://") {
+		return os.ReadFile(from)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, from, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runCompare implements "apctl compare -baseline OLD -candidate NEW AUDIT.log...".
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	baselinePath := fs.String("baseline", "", "AgentPolicy manifest of the current version (required)")
+	candidatePath := fs.String("candidate", "", "AgentPolicy manifest of the new version, e.g. a canary (required)")
+	since := fs.Duration("since", 0, "only compare events newer than this (e.g. 24h; 0 compares everything)")
+	interval := fs.Duration("interval", time.Hour, "length of the timeline's intervals (0 omits the timeline)")
+	asJSON := fs.Bool("json", false, "print the report as JSON, for review before promotion")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl compare -baseline OLD.yaml -candidate NEW.yaml [-since 24h] [-interval 1h] [-json] AUDIT.log...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *baselinePath == "" || *candidatePath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	baseline, err := compileManifest(*baselinePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl compare: %v\n", err)
+		return exitError
+	}
+	candidate, err := compileManifest(*candidatePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl compare: %v\n", err)
+		return exitError
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+
+	var events []policy.AuditEvent
+	for _, path := range fs.Args() {
+		fileEvents, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl compare: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl compare: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	comparison, err := replay.Compare(context.Background(), events, baseline, candidate, *interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl compare: %v\n", err)
+		return exitError
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(comparison); err != nil {
+			fmt.Fprintf(os.Stderr, "apctl compare: %v\n", err)
+			return exitError
+		}
+	} else {
+		fmt.Print(comparison)
+	}
+
+	if comparison.Diverged > 0 {
+		return exitChanged
+	}
+	return exitOK
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compliance"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runCompliance implements "apctl compliance [-framework F] [-audit LOG]...
+// POLICY.yaml...": it assesses the policies, and the audit logs if any,
+// against the controls of a compliance framework and writes the report.
+func runCompliance(args []string) int {
+	fs := flag.NewFlagSet("compliance", flag.ContinueOnError)
+	framework := fs.String("framework", string(compliance.IEC62443), fmt.Sprintf("framework to assess against: %v", compliance.Frameworks))
+	format := fs.String("format", "markdown", "report format: markdown, json, or pdf")
+	out := fs.String("o", "", "file to write the report to (default: stdout)")
+	since := fs.Duration("since", 0, "only use audit events newer than this (e.g. 720h; 0 uses everything)")
+	var audits stringList
+	fs.Var(&audits, "audit", "audit log whose decisions are evidence (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl compliance [-framework iec62443|soc2|nist] [-format markdown|json|pdf] [-o FILE] [-audit AUDIT.log]... POLICY.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	if *format != "markdown" && *format != "json" && *format != "pdf" {
+		fmt.Fprintf(os.Stderr, "apctl compliance: invalid -format %q: must be markdown, json, or pdf\n", *format)
+		return exitError
+	}
+
+	var policies []*agentsv1alpha1.AgentPolicy
+	for _, path := range fs.Args() {
+		ap, err := loadManifest(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl compliance: %v\n", err)
+			return exitError
+		}
+		policies = append(policies, ap)
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+	var events []policy.AuditEvent
+	for _, path := range audits {
+		fileEvents, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl compliance: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl compliance: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	report, err := compliance.Generate(compliance.Framework(*framework), policies, events, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl compliance: %v\n", err)
+		return exitError
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl compliance: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "json":
+		err = report.WriteJSON(w)
+	case "pdf":
+		err = report.WritePDF(w)
+	default:
+		err = report.WriteMarkdown(w)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl compliance: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy/conftest"
+)
+
+// runConftest implements "apctl conftest -o DIR POLICY.yaml...": it writes
+// the conftest policy pack that checks agent task plans against the
+// policies, and reports on stderr what it does not check.
+func runConftest(args []string) int {
+	fs := flag.NewFlagSet("conftest", flag.ContinueOnError)
+	out := fs.String("o", "", "directory to write the policy pack to, for conftest test --policy (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl conftest -o DIR POLICY.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *out == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var policies []*agentsv1alpha1.AgentPolicy
+	for _, path := range fs.Args() {
+		ap, err := loadManifest(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl conftest: %v\n", err)
+			return exitError
+		}
+		policies = append(policies, ap)
+	}
+
+	pack, findings, err := conftest.Export(policies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl conftest: %v\n", err)
+		return exitError
+	}
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "apctl conftest: %s\n", f)
+	}
+	if err := conftest.Write(*out, pack); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl conftest: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
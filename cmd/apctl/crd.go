@@ -0,0 +1,315 @@
+package main
+
+//go:generate go test -run TestCRDValidations -update
+
+import (
+	"reflect"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// crdEnums are the allowed values of the enum types, taken from their
+// constants so the schema follows the API.
+var crdEnums = map[reflect.Type][]string{
+	reflect.TypeOf(agentsv1alpha1.DecisionAction("")): {
+		string(agentsv1alpha1.DecisionAllow), string(agentsv1alpha1.DecisionDeny)},
+	reflect.TypeOf(agentsv1alpha1.EnforcementMode("")): {
+		string(agentsv1alpha1.EnforcementModePermissive), string(agentsv1alpha1.EnforcementModeEnforcing)},
+	reflect.TypeOf(agentsv1alpha1.RegoTemplateVersion("")): {
+		string(agentsv1alpha1.RegoTemplateV1), string(agentsv1alpha1.RegoTemplateV2)},
+	reflect.TypeOf(agentsv1alpha1.ProfileAction("")): {
+		string(agentsv1alpha1.ProfileActionFlag), string(agentsv1alpha1.ProfileActionDeny)},
+	reflect.TypeOf(agentsv1alpha1.MTSEnforceMode("")): {
+		string(agentsv1alpha1.MTSEnforceModeStrict), string(agentsv1alpha1.MTSEnforceModePermissive), string(agentsv1alpha1.MTSEnforceModeDisabled)},
+	reflect.TypeOf(agentsv1alpha1.ToolRiskLevel("")): {
+		string(agentsv1alpha1.ToolRiskLow), string(agentsv1alpha1.ToolRiskMedium), string(agentsv1alpha1.ToolRiskHigh), string(agentsv1alpha1.ToolRiskCritical)},
+}
+
+// crdLimit bounds a field: its number of items, its length, or the
+// length of its string items.
+type crdLimit struct {
+	maxItems, maxLength, maxItemLength int64
+}
+
+// crdLimits mirror the MaxItems and MaxLength markers of the fields the
+// CEL rules read, by struct type and JSON field name. The API server
+// estimates the cost of each rule from these bounds and refuses a CRD
+// whose rules could exceed its budget on unbounded input.
+var crdLimits = map[reflect.Type]map[string]crdLimit{
+	reflect.TypeOf(agentsv1alpha1.AgentPolicySpec{}): {
+		"toolPermissions": {maxItems: 256},
+	},
+	reflect.TypeOf(agentsv1alpha1.ToolConstraints{}): {
+		"allowedDomains": {maxItems: 100, maxItemLength: 253},
+		"deniedDomains":  {maxItems: 100, maxItemLength: 253},
+		"timeout":        {maxLength: 32},
+	},
+}
+
+var (
+	timeType = reflect.TypeOf(metav1.Time{})
+	jsonType = reflect.TypeOf(apiextensionsv1.JSON{})
+)
+
+// crdResource describes a custom resource of the agents.sandbox.io group.
+type crdResource struct {
+	kind, plural string
+	shortNames   []string
+	object       interface{}
+	columns      []apiextensionsv1.CustomResourceColumnDefinition
+
+	// clusterScoped resources are not namespaced
+	clusterScoped bool
+}
+
+// crdResources are the custom resources the router watches. Printer
+// columns mirror the kubebuilder markers on the API types.
+var crdResources = []crdResource{
+	{
+		kind: "AgentPolicy", plural: "agentpolicies", shortNames: []string{"ap", "agpol"},
+		object: agentsv1alpha1.AgentPolicy{},
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Mode", Type: "string", JSONPath: ".spec.mode", Description: "Enforcement mode"},
+			{Name: "Default", Type: "string", JSONPath: ".spec.defaultAction", Description: "Default action"},
+			{Name: "Fallback", Type: "boolean", JSONPath: ".spec.fallback", Description: "Cluster fallback policy", Priority: 1},
+			{Name: "Bindings", Type: "integer", JSONPath: ".status.activeBindings", Description: "Active sandbox bindings"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "AgentProfile", plural: "agentprofiles", shortNames: []string{"aprof"},
+		object: agentsv1alpha1.AgentProfile{},
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Agent Type", Type: "string", JSONPath: ".spec.agentType", Description: "Profiled agent type"},
+			{Name: "Samples", Type: "integer", JSONPath: ".spec.samples", Description: "Calls learned from"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "AgentIdentityBinding", plural: "agentidentitybindings", shortNames: []string{"aib"},
+		object:        agentsv1alpha1.AgentIdentityBinding{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Namespace", Type: "string", JSONPath: ".spec.serviceAccount.namespace", Description: "ServiceAccount namespace"},
+			{Name: "Service Account", Type: "string", JSONPath: ".spec.serviceAccount.name", Description: "ServiceAccount name"},
+			{Name: "Agent Type", Type: "string", JSONPath: ".spec.agentType", Description: "Bound agent type"},
+			{Name: "Tenant", Type: "string", JSONPath: ".spec.tenantID", Description: "Bound tenant"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "Tenant", plural: "tenants", shortNames: []string{"tn"},
+		object:        agentsv1alpha1.Tenant{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Parent", Type: "string", JSONPath: ".spec.parent", Description: "Parent tenant"},
+			{Name: "Delegated", Type: "boolean", JSONPath: ".spec.delegateToParent", Description: "Delegated to the parent tenant"},
+			{Name: "Label", Type: "string", JSONPath: ".status.mtsLabel", Description: "Agent MTS label"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "TenantConfig", plural: "tenantconfigs", shortNames: []string{"tcfg"},
+		object:        agentsv1alpha1.TenantConfig{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Mode", Type: "string", JSONPath: ".spec.mode", Description: "Enforcement mode"},
+			{Name: "Accepted", Type: "string", JSONPath: `.status.conditions[?(@.type=="Accepted")].status`, Description: "Config applied"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "ToolAlias", plural: "toolaliases", shortNames: []string{"ta"},
+		object:        agentsv1alpha1.ToolAlias{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Tool", Type: "string", JSONPath: ".spec.tool", Description: "Canonical tool name"},
+			{Name: "Accepted", Type: "string", JSONPath: `.status.conditions[?(@.type=="Accepted")].status`, Description: "Aliases applied"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "ToolCatalog", plural: "toolcatalogs", shortNames: []string{"tc"},
+		object:        agentsv1alpha1.ToolCatalog{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Accepted", Type: "string", JSONPath: `.status.conditions[?(@.type=="Accepted")].status`, Description: "Tools applied"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+	{
+		kind: "ToolKillSwitch", plural: "toolkillswitches", shortNames: []string{"tks"},
+		object:        agentsv1alpha1.ToolKillSwitch{},
+		clusterScoped: true,
+		columns: []apiextensionsv1.CustomResourceColumnDefinition{
+			{Name: "Tool", Type: "string", JSONPath: ".spec.tool", Description: "Disabled tool"},
+			{Name: "Active", Type: "string", JSONPath: `.status.conditions[?(@.type=="Active")].status`, Description: "Tool disabled"},
+			{Name: "Expires", Type: "date", JSONPath: ".spec.expiresAt"},
+			{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+		},
+	},
+}
+
+// customResourceDefinition builds the CRD of a resource, with its schema
+// derived from the Go type.
+func customResourceDefinition(r crdResource) *apiextensionsv1.CustomResourceDefinition {
+	group := agentsv1alpha1.GroupVersion.Group
+	schema := schemaFor(reflect.TypeOf(r.object), map[reflect.Type]bool{})
+	// Metadata is validated by the API server
+	schema.Properties["metadata"] = apiextensionsv1.JSONSchemaProps{Type: "object"}
+
+	scope := apiextensionsv1.NamespaceScoped
+	if r.clusterScoped {
+		scope = apiextensionsv1.ClusterScoped
+	}
+	var subresources *apiextensionsv1.CustomResourceSubresources
+	if _, ok := reflect.TypeOf(r.object).FieldByName("Status"); ok {
+		subresources = &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}}
+	}
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+		ObjectMeta: metav1.ObjectMeta{Name: r.plural + "." + group},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:       r.kind,
+				ListKind:   r.kind + "List",
+				Plural:     r.plural,
+				Singular:   strings.ToLower(r.kind),
+				ShortNames: r.shortNames,
+			},
+			Scope: scope,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:                     agentsv1alpha1.GroupVersion.Version,
+				Served:                   true,
+				Storage:                  true,
+				Schema:                   &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: schema},
+				Subresources:             subresources,
+				AdditionalPrinterColumns: r.columns,
+			}},
+		},
+	}
+}
+
+// schemaFor returns the structural schema of a Go API type, following its
+// JSON encoding: fields without omitempty are required.
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) *apiextensionsv1.JSONSchemaProps {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &apiextensionsv1.JSONSchemaProps{Type: "string", Format: "date-time"}
+	case jsonType:
+		return &apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+	}
+	if values, ok := crdEnums[t]; ok {
+		s := &apiextensionsv1.JSONSchemaProps{Type: "string"}
+		for _, v := range values {
+			s.Enum = append(s.Enum, apiextensionsv1.JSON{Raw: []byte(`"` + v + `"`)})
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &apiextensionsv1.JSONSchemaProps{Type: "string"}
+	case reflect.Bool:
+		return &apiextensionsv1.JSONSchemaProps{Type: "boolean"}
+	case reflect.Int32, reflect.Uint32:
+		return &apiextensionsv1.JSONSchemaProps{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return &apiextensionsv1.JSONSchemaProps{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &apiextensionsv1.JSONSchemaProps{Type: "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &apiextensionsv1.JSONSchemaProps{Type: "string", Format: "byte"}
+		}
+		return &apiextensionsv1.JSONSchemaProps{
+			Type:  "array",
+			Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: schemaFor(t.Elem(), visiting)},
+		}
+	case reflect.Map:
+		return &apiextensionsv1.JSONSchemaProps{
+			Type:                 "object",
+			AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: schemaFor(t.Elem(), visiting)},
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive types cannot be expressed structurally
+			return &apiextensionsv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: boolPtr(true)}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &apiextensionsv1.JSONSchemaProps{Type: "object", Properties: map[string]apiextensionsv1.JSONSchemaProps{}}
+		addFields(s, t, visiting)
+		s.XValidations = crdValidations[t]
+		return s
+	default:
+		return &apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+	}
+}
+
+// addFields adds the JSON fields of a struct to its schema, flattening
+// inline fields.
+func addFields(s *apiextensionsv1.JSONSchemaProps, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && (f.Anonymous || strings.Contains(opts, "inline")) {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			addFields(s, ft, visiting)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaFor(f.Type, visiting)
+		if limit, ok := crdLimits[t][name]; ok {
+			limit.apply(prop)
+		}
+		s.Properties[name] = *prop
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// apply sets the bounds of the limit on a field schema.
+func (l crdLimit) apply(s *apiextensionsv1.JSONSchemaProps) {
+	if l.maxItems > 0 {
+		s.MaxItems = int64Ptr(l.maxItems)
+	}
+	if l.maxLength > 0 {
+		s.MaxLength = int64Ptr(l.maxLength)
+	}
+	if l.maxItemLength > 0 && s.Items != nil && s.Items.Schema != nil {
+		s.Items.Schema.MaxLength = int64Ptr(l.maxItemLength)
+	}
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
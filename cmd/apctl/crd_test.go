@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate "+crdValidationsFile+" from the API types")
+
+const (
+	// apiDir holds the API types whose markers crdValidations follows
+	apiDir = "../../api/v1alpha1"
+
+	// crdValidationsFile is generated from the XValidation markers
+	crdValidationsFile = "zz_generated.crdvalidations.go"
+
+	xValidationMarker = "+kubebuilder:validation:XValidation:"
+)
+
+// xValidation is the rule and message of an XValidation marker.
+type xValidation struct {
+	rule, message string
+}
+
+// TestCRDValidations compares crdValidations, as generated in
+// zz_generated.crdvalidations.go, against the XValidation markers of the
+// API types. Run with -update (or go generate) to regenerate it after
+// changing the markers.
+func TestCRDValidations(t *testing.T) {
+	types, err := parseXValidations(apiDir)
+	if err != nil {
+		t.Fatalf("failed to read XValidation markers: %v", err)
+	}
+	if len(types) == 0 {
+		t.Fatalf("no XValidation markers in %s", apiDir)
+	}
+	got, err := renderCRDValidations(types)
+	if err != nil {
+		t.Fatalf("failed to render %s: %v", crdValidationsFile, err)
+	}
+
+	if *update {
+		if err := os.WriteFile(crdValidationsFile, got, 0644); err != nil {
+			t.Fatalf("failed to update %s: %v", crdValidationsFile, err)
+		}
+	}
+
+	want, err := os.ReadFile(crdValidationsFile)
+	if err != nil {
+		t.Fatalf("failed to read %s (run with -update to create): %v", crdValidationsFile, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match the XValidation markers of %s (run with -update to accept):\n%s", crdValidationsFile, apiDir, got)
+	}
+}
+
+// parseXValidations reads the XValidation markers of the types declared
+// in the Go files of dir, by type name. Markers on fields are rejected:
+// crdValidations only holds those of types.
+func parseXValidations(dir string) (map[string][]xValidation, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && !strings.HasPrefix(fi.Name(), "zz_generated")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string][]xValidation)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					doc := ts.Doc
+					if doc == nil && len(gen.Specs) == 1 {
+						doc = gen.Doc
+					}
+					rules, err := markerValidations(doc)
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", ts.Name.Name, err)
+					}
+					if len(rules) > 0 {
+						types[ts.Name.Name] = rules
+					}
+
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						if rules, _ := markerValidations(field.Doc); len(rules) > 0 {
+							return nil, fmt.Errorf("%s: XValidation markers on fields are not supported", fset.Position(field.Pos()))
+						}
+					}
+				}
+			}
+		}
+	}
+	return types, nil
+}
+
+// markerValidations parses the XValidation markers of a doc comment, in
+// order.
+func markerValidations(doc *ast.CommentGroup) ([]xValidation, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var rules []xValidation
+	for _, c := range doc.List {
+		args, ok := strings.CutPrefix(strings.TrimSpace(strings.TrimPrefix(c.Text, "//")), xValidationMarker)
+		if !ok {
+			continue
+		}
+		var v xValidation
+		for args != "" {
+			key, rest, ok := strings.Cut(args, "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed marker argument %q", args)
+			}
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("marker argument %s: %w", key, err)
+			}
+			value, _ := strconv.Unquote(quoted)
+			switch key {
+			case "rule":
+				v.rule = value
+			case "message":
+				v.message = value
+			default:
+				return nil, fmt.Errorf("unsupported marker argument %s", key)
+			}
+			args = strings.TrimPrefix(rest[len(quoted):], ",")
+		}
+		if v.rule == "" {
+			return nil, fmt.Errorf("XValidation marker without a rule")
+		}
+		rules = append(rules, v)
+	}
+	return rules, nil
+}
+
+// renderCRDValidations renders zz_generated.crdvalidations.go.
+func renderCRDValidations(types map[string][]xValidation) ([]byte, error) {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by TestCRDValidations from the XValidation markers of
+// api/v1alpha1. DO NOT EDIT.
+
+package main
+
+import (
+	"reflect"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// crdValidations are the CEL rules of the API types, from their
+// XValidation markers, so the API server rejects invalid specs even
+// without an admission webhook.
+var crdValidations = map[reflect.Type]apiextensionsv1.ValidationRules{
+`)
+	for _, name := range names {
+		fmt.Fprintf(&b, "reflect.TypeOf(agentsv1alpha1.%s{}): {\n", name)
+		for _, v := range types[name] {
+			fmt.Fprintf(&b, "{Rule: %s, Message: %s},\n", strconv.Quote(v.rule), strconv.Quote(v.message))
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
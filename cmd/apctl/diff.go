@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	policydiff "github.com/golden-agent/golden-agent/pkg/policy/diff"
+)
+
+// runDiff implements "apctl diff OLD NEW".
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	summary := fs.Bool("summary", false, "print a one-line summary instead of each change")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl diff [-summary] OLD.yaml NEW.yaml")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	oldPolicy, err := compileManifest(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl diff: %v\n", err)
+		return exitError
+	}
+	newPolicy, err := compileManifest(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl diff: %v\n", err)
+		return exitError
+	}
+
+	d := policydiff.Compare(oldPolicy, newPolicy)
+	if d.Empty() {
+		fmt.Println("no changes")
+		return exitOK
+	}
+
+	if *summary {
+		fmt.Println(d.Summary())
+	} else {
+		fmt.Print(d)
+	}
+	return exitChanged
+}
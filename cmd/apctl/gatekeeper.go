@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy/gatekeeper"
+)
+
+// runGatekeeper implements "apctl gatekeeper POLICY.yaml...": it prints the
+// Gatekeeper ConstraintTemplate and the Constraints mirroring the tool
+// rules of the policies, and reports on stderr what they do not enforce.
+func runGatekeeper(args []string) int {
+	fs := flag.NewFlagSet("gatekeeper", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl gatekeeper POLICY.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var policies []*agentsv1alpha1.AgentPolicy
+	for _, path := range fs.Args() {
+		ap, err := loadManifest(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl gatekeeper: %v\n", err)
+			return exitError
+		}
+		policies = append(policies, ap)
+	}
+
+	objects, findings := gatekeeper.Export(policies)
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "apctl gatekeeper: %s\n", f)
+	}
+	if err := gatekeeper.Write(os.Stdout, objects); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl gatekeeper: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
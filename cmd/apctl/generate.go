@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/controller"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runGenerate implements "apctl generate -from-audit AUDIT.log -agent-type X":
+// it synthesizes a default-deny AgentPolicy allowing exactly the tools,
+// path prefixes, domains, and ports the agent type used in the recorded
+// traffic. Every call counts, including ones the active policy denied,
+// since permissive-mode traffic executed them; -allowed-only restricts the
+// policy to calls that were allowed.
+func runGenerate(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	var auditLogs stringList
+	fs.Var(&auditLogs, "from-audit", "JSON audit log to learn from (required, repeatable)")
+	agentType := fs.String("agent-type", "", "agent type to generate a policy for (required)")
+	name := fs.String("name", "", "policy name (default: <agent-type>-generated)")
+	mode := fs.String("mode", string(agentsv1alpha1.EnforcementModeEnforcing), "enforcement mode of the generated policy (permissive or enforcing)")
+	since := fs.Duration("since", 0, "only learn from events newer than this (e.g. 24h; 0 uses everything)")
+	allowedOnly := fs.Bool("allowed-only", false, "ignore calls the recorded policy denied")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl generate -from-audit AUDIT.log -agent-type TYPE [-name NAME] [-mode enforcing] [-since 24h] [-allowed-only]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	auditLogs = append(auditLogs, fs.Args()...)
+	if len(auditLogs) == 0 || *agentType == "" {
+		fs.Usage()
+		return exitError
+	}
+	if *mode != string(agentsv1alpha1.EnforcementModeEnforcing) && *mode != string(agentsv1alpha1.EnforcementModePermissive) {
+		fmt.Fprintf(os.Stderr, "apctl generate: invalid mode %q\n", *mode)
+		return exitError
+	}
+	if *name == "" {
+		*name = *agentType + "-generated"
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+
+	learner := policy.NewProfileLearner()
+	learner.IncludeDenials = !*allowedOnly
+	withParameters := 0
+	for _, path := range auditLogs {
+		events, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl generate: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl generate: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		for i := range events {
+			if events[i].Agent.AgentType != *agentType {
+				continue
+			}
+			if events[i].Parameters != nil {
+				withParameters++
+			}
+			learner.Log(&events[i])
+		}
+	}
+
+	profile, ok := learner.Profile(*agentType)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "apctl generate: no events for agent type %q\n", *agentType)
+		return exitError
+	}
+	if withParameters == 0 {
+		fmt.Fprintln(os.Stderr, "apctl generate: warning: events carry no parameters (audit without WithAuditParameters); only the tool list is constrained")
+	}
+
+	ap, warnings := controller.GeneratePolicy(*name, profile, agentsv1alpha1.EnforcementMode(*mode))
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "apctl generate: warning: %s\n", w)
+	}
+
+	data, err := yaml.Marshal(ap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl generate: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("# Generated by apctl generate from %d calls of %s\n", profile.Samples, *agentType)
+	fmt.Print(string(data))
+	return exitOK
+}
+
+// stringList is a flag.Value collecting repeated string flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
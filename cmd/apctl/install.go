@@ -0,0 +1,536 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/router"
+)
+
+const (
+	// grpcPort is the router's AgentService port
+	grpcPort = 50051
+
+	// auditDir is where the file audit sink writes in the router pod
+	auditDir = "/var/log/golden-agent"
+
+	// tlsDir is where the TLS secret is mounted in the router pod
+	tlsDir = "/etc/golden-agent/tls"
+)
+
+// installValues are the settings of "apctl install manifests".
+type installValues struct {
+	namespace    string
+	name         string
+	image        string
+	replicas     int
+	mode         string
+	opa          bool
+	auditSink    string
+	auditFormat  string
+	tlsSecret    string
+	mtls         bool
+	invalidation bool
+	heartbeat    bool
+	tokenReview  bool
+	claims       bool
+	valuesFrom   bool
+	renderRego   bool
+	nodeLocal    bool
+	tenants      bool
+	tenantConfig bool
+	toolAliases  bool
+	toolCatalog  bool
+	killSwitches bool
+	drainTimeout time.Duration
+}
+
+// runInstall implements "apctl install manifests": it prints the manifests
+// that run the router with its embedded controller: the CRDs, RBAC, the
+// Deployment (a DaemonSet with -node-local), and its Service. The CRD schemas are derived from the API
+// types and the router's ports from its default configuration, so the
+// output follows the code it deploys. The router's AgentPolicy webhook
+// needs a serving certificate, so it is not configured.
+func runInstall(args []string) int {
+	if len(args) == 0 || args[0] != "manifests" {
+		fmt.Fprintln(os.Stderr, "Usage: apctl install manifests [flags]")
+		return exitError
+	}
+
+	fs := flag.NewFlagSet("install manifests", flag.ContinueOnError)
+	var v installValues
+	fs.StringVar(&v.namespace, "namespace", "golden-agent", "namespace of the router")
+	fs.StringVar(&v.name, "name", "agent-router", "name of the router Deployment, Service, and RBAC objects")
+	fs.StringVar(&v.image, "image", "ghcr.io/golden-agent/router:latest", "router container image")
+	fs.IntVar(&v.replicas, "replicas", 2, "router replicas")
+	fs.StringVar(&v.mode, "mode", string(agentsv1alpha1.EnforcementModePermissive), "enforcement mode (permissive or enforcing)")
+	fs.BoolVar(&v.opa, "opa", true, "evaluate policies with OPA")
+	fs.StringVar(&v.auditSink, "audit-sink", "json", "audit sink: stdout, json, file, or none")
+	fs.StringVar(&v.auditFormat, "audit-format", "json", "audit format (with -audit-sink=json or file): json or cloudevents, or avc for files")
+	fs.StringVar(&v.tlsSecret, "tls-secret", "", "kubernetes.io/tls Secret to serve gRPC over TLS")
+	fs.BoolVar(&v.mtls, "mtls", true, "with -tls-secret, require client certificates signed by the Secret's ca.crt")
+	fs.BoolVar(&v.invalidation, "invalidation", true, "broadcast cache invalidations between replicas (when replicas > 1)")
+	fs.BoolVar(&v.heartbeat, "heartbeat", true, "record in policy status which replicas loaded each policy")
+	fs.BoolVar(&v.tokenReview, "token-review", false, "authenticate agents by ServiceAccount tokens for the router's audience, mapped by AgentIdentityBindings")
+	fs.BoolVar(&v.claims, "sandbox-claims", false, "cross-check calls against the SandboxClaim of their sandbox (requires the SandboxClaim CRD)")
+	fs.BoolVar(&v.valuesFrom, "values-from", false, "read the constraint values policies take from ConfigMaps and Secrets (valuesFrom)")
+	fs.BoolVar(&v.renderRego, "render-rego", false, "write the Rego generated for each AgentPolicy to the ConfigMap NAME-rego in its namespace, for inspection (requires -opa)")
+	fs.BoolVar(&v.nodeLocal, "node-local", false, "run a router per node, as a DaemonSet, loading only the policies of the node's SandboxClaims (requires -sandbox-claims)")
+	fs.BoolVar(&v.tenants, "tenants", false, "allocate Tenants exclusive MTS categories and evaluate calls with their tenant's label")
+	fs.BoolVar(&v.tenantConfig, "tenant-configs", false, "evaluate the calls of tenants with a TenantConfig in its enforcement mode")
+	fs.BoolVar(&v.toolAliases, "tool-aliases", false, "normalize the raw tool names of ToolAliases to their tools")
+	fs.BoolVar(&v.toolCatalog, "tool-catalog", false, "describe the tools of ToolCatalogs to agents discovering their allowed tools")
+	fs.BoolVar(&v.killSwitches, "kill-switches", true, "deny every call to the tools disabled by ToolKillSwitches")
+	fs.DurationVar(&v.drainTimeout, "drain-timeout", 25*time.Second, "how long a terminating router waits for in-flight calls")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl install manifests [-namespace NS] [-image IMAGE] [-mode enforcing] [-opa] [-audit-sink json] [-tls-secret NAME]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitError
+	}
+	if v.nodeLocal && !v.claims {
+		fmt.Fprintln(os.Stderr, "apctl install: -node-local requires -sandbox-claims")
+		return exitError
+	}
+	if v.renderRego && !v.opa {
+		fmt.Fprintln(os.Stderr, "apctl install: -render-rego requires -opa")
+		return exitError
+	}
+	if v.mode != string(agentsv1alpha1.EnforcementModeEnforcing) && v.mode != string(agentsv1alpha1.EnforcementModePermissive) {
+		fmt.Fprintf(os.Stderr, "apctl install: invalid mode %q\n", v.mode)
+		return exitError
+	}
+	switch v.auditSink {
+	case "stdout", "json", "file", "none":
+	default:
+		fmt.Fprintf(os.Stderr, "apctl install: invalid audit sink %q\n", v.auditSink)
+		return exitError
+	}
+	switch v.auditFormat {
+	case policy.AuditFormatJSON, policy.AuditFormatCloudEvents:
+	case policy.AuditFormatAVC:
+		if v.auditSink == "json" {
+			fmt.Fprintln(os.Stderr, "apctl install: audit format avc requires -audit-sink=file")
+			return exitError
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "apctl install: invalid audit format %q\n", v.auditFormat)
+		return exitError
+	}
+
+	objects, err := installManifests(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl install: %v\n", err)
+		return exitError
+	}
+
+	fmt.Println("# Generated by apctl install manifests")
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl install: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("---\n%s", data)
+	}
+	return exitOK
+}
+
+// installManifests builds the objects that run the router.
+func installManifests(v installValues) ([]interface{}, error) {
+	defaults := router.DefaultServerConfig()
+	metricsPort, err := addrPort(defaults.PolicyConfig.MetricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics address: %w", err)
+	}
+	healthPort, err := addrPort(defaults.PolicyConfig.HealthProbeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("health probe address: %w", err)
+	}
+
+	var objects []interface{}
+	for _, r := range crdResources {
+		objects = append(objects, customResourceDefinition(r))
+	}
+
+	labels := map[string]string{"app.kubernetes.io/name": v.name}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: v.namespace, Labels: labels}
+	}
+
+	clusterRules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+			Resources: []string{"agentpolicies", "agentprofiles"},
+			Verbs:     []string{"get", "list", "watch"},
+		},
+		{
+			APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+			Resources: []string{"agentpolicies/status", "agentprofiles/status"},
+			Verbs:     []string{"get", "update", "patch"},
+		},
+	}
+	if v.tokenReview {
+		// The router reviews agent tokens and reads the bindings that map
+		// them to agent identities
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"agentidentitybindings"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"authentication.k8s.io"},
+				Resources: []string{"tokenreviews"},
+				Verbs:     []string{"create"},
+			},
+		)
+	}
+
+	if v.claims {
+		// The router reads the claims of sandboxes
+		clusterRules = append(clusterRules, rbacv1.PolicyRule{
+			APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+			Resources: []string{"sandboxclaims"},
+			Verbs:     []string{"get", "list", "watch"},
+		})
+	}
+	if v.valuesFrom {
+		// The router reads the constraint values of policies, and watches
+		// the metadata of the objects holding them
+		clusterRules = append(clusterRules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps", "secrets"},
+			Verbs:     []string{"get", "list", "watch"},
+		})
+	}
+	if v.renderRego {
+		// The router writes the Rego of each policy to a ConfigMap next to
+		// it, which it reads uncached
+		clusterRules = append(clusterRules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "create", "update", "patch"},
+		})
+	}
+	if v.tenants {
+		// The router allocates the categories of tenants
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"tenants"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"tenants/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+		)
+	}
+	if v.tenantConfig {
+		// The router loads the modes of tenants
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"tenantconfigs"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"tenantconfigs/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+		)
+	}
+	if v.toolAliases {
+		// The router loads the aliases of tools
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolaliases"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolaliases/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+		)
+	}
+	if v.toolCatalog {
+		// The router loads the known tools
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolcatalogs"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolcatalogs/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+		)
+	}
+	if v.killSwitches {
+		// The router loads the disabled tools
+		clusterRules = append(clusterRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolkillswitches"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{agentsv1alpha1.GroupVersion.Group},
+				Resources: []string{"toolkillswitches/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+		)
+	}
+
+	objects = append(objects,
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: v.namespace},
+		},
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta(v.name),
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: v.name, Labels: labels},
+			Rules:      clusterRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: v.name, Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: v.name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: v.name, Namespace: v.namespace}},
+		},
+	)
+
+	// Node-local routers load different policies, and serve only the
+	// agents of their node, so they share neither invalidations nor
+	// heartbeats
+	if v.nodeLocal {
+		v.invalidation, v.heartbeat = false, false
+	}
+
+	var rules []rbacv1.PolicyRule
+	invalidation := v.invalidation && v.replicas > 1
+	if invalidation {
+		// The invalidation bus creates and updates its ConfigMap
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "list", "watch", "create", "update"},
+		})
+	}
+	if v.heartbeat {
+		// Each replica creates and renews its own heartbeat Lease
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"coordination.k8s.io"},
+			Resources: []string{"leases"},
+			Verbs:     []string{"get", "list", "create", "update"},
+		})
+	}
+	if len(rules) > 0 {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: meta(v.name),
+				Rules:      rules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: meta(v.name),
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: v.name},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: v.name, Namespace: v.namespace}},
+			},
+		)
+	}
+
+	container := corev1.Container{
+		Name:  "router",
+		Image: v.image,
+		Args: []string{
+			"--listen=:" + strconv.Itoa(grpcPort),
+			"--mode=" + v.mode,
+			"--opa=" + strconv.FormatBool(v.opa),
+			"--controller=true",
+			"--metrics-addr=" + defaults.PolicyConfig.MetricsAddr,
+			"--health-addr=" + defaults.PolicyConfig.HealthProbeAddr,
+			"--audit-sink=" + v.auditSink,
+			"--drain-timeout=" + v.drainTimeout.String(),
+		},
+		Ports: []corev1.ContainerPort{
+			{Name: "grpc", ContainerPort: grpcPort},
+			{Name: "metrics", ContainerPort: metricsPort},
+			{Name: "health", ContainerPort: healthPort},
+		},
+		LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("health")},
+		}},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/readyz", Port: intstr.FromString("health")},
+			},
+			PeriodSeconds: 5,
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: boolPtr(false),
+			ReadOnlyRootFilesystem:   boolPtr(true),
+			RunAsNonRoot:             boolPtr(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+	var volumes []corev1.Volume
+
+	if invalidation {
+		container.Args = append(container.Args, "--invalidation-configmap="+v.namespace+"/"+v.name+"-invalidation")
+	}
+	if v.heartbeat {
+		container.Args = append(container.Args, "--heartbeat="+v.namespace+"/"+v.name)
+	}
+	if v.tokenReview {
+		container.Args = append(container.Args, "--token-review", "--token-audiences="+v.name)
+	}
+	if v.claims {
+		container.Args = append(container.Args, "--sandbox-claims")
+	}
+	if v.valuesFrom {
+		container.Args = append(container.Args, "--values-from")
+	}
+	if v.renderRego {
+		container.Args = append(container.Args, "--render-rego")
+	}
+	if v.nodeLocal {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+		})
+		container.Args = append(container.Args, "--node-name=$(NODE_NAME)")
+	}
+	if v.tenants {
+		container.Args = append(container.Args, "--tenants")
+	}
+	if v.tenantConfig {
+		container.Args = append(container.Args, "--tenant-configs")
+	}
+	if v.toolAliases {
+		container.Args = append(container.Args, "--tool-aliases")
+	}
+	if v.toolCatalog {
+		container.Args = append(container.Args, "--tool-catalog")
+	}
+	if v.killSwitches {
+		container.Args = append(container.Args, "--kill-switches")
+	}
+	if v.auditSink == "json" && v.auditFormat != policy.AuditFormatJSON {
+		container.Args = append(container.Args, "--audit-format="+v.auditFormat)
+	}
+	if v.auditSink == "file" {
+		container.Args = append(container.Args, "--audit-file="+auditDir+"/audit.log", "--audit-format="+v.auditFormat)
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "audit", MountPath: auditDir})
+		volumes = append(volumes, corev1.Volume{Name: "audit", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	}
+	if v.tlsSecret != "" {
+		container.Args = append(container.Args, "--tls-cert="+tlsDir+"/tls.crt", "--tls-key="+tlsDir+"/tls.key")
+		if v.mtls {
+			container.Args = append(container.Args, "--tls-client-ca="+tlsDir+"/ca.crt")
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "tls", MountPath: tlsDir, ReadOnly: true})
+		volumes = append(volumes, corev1.Volume{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: v.tlsSecret}}})
+	}
+
+	// Kubernetes kills the pod this long after SIGTERM; leave the router
+	// time to drain and flush its audit sinks
+	grace := int64((v.drainTimeout + 5*time.Second) / time.Second)
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			ServiceAccountName:            v.name,
+			TerminationGracePeriodSeconds: &grace,
+			Containers:                    []corev1.Container{container},
+			Volumes:                       volumes,
+		},
+	}
+	service := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: meta(v.name),
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports: []corev1.ServicePort{
+				{Name: "grpc", Port: grpcPort, TargetPort: intstr.FromString("grpc"), AppProtocol: strPtr("grpc")},
+				{Name: "metrics", Port: metricsPort, TargetPort: intstr.FromString("metrics")},
+			},
+		},
+	}
+
+	if v.nodeLocal {
+		// Agents reach the router of their own node, the only one that
+		// loaded their sandbox's policy
+		local := corev1.ServiceInternalTrafficPolicyLocal
+		service.Spec.InternalTrafficPolicy = &local
+		objects = append(objects,
+			&appsv1.DaemonSet{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+				ObjectMeta: meta(v.name),
+				Spec: appsv1.DaemonSetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: template,
+				},
+			},
+			service,
+		)
+		return objects, nil
+	}
+
+	replicas := int32(v.replicas)
+	objects = append(objects,
+		&appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: meta(v.name),
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: template,
+			},
+		},
+		service,
+	)
+	return objects, nil
+}
+
+// addrPort returns the port of a listen address such as ":8080".
+func addrPort(addr string) (int32, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(port, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(n), nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// routerWatches are the resources the router watches with each of its
+// flags, which its ClusterRole must let it get, list, and watch.
+var routerWatches = map[string][]string{
+	"--controller":     {"agentpolicies", "agentprofiles"},
+	"--token-review":   {"agentidentitybindings"},
+	"--sandbox-claims": {"sandboxclaims"},
+	"--tenants":        {"tenants"},
+	"--tenant-configs": {"tenantconfigs"},
+	"--tool-aliases":   {"toolaliases"},
+	"--tool-catalog":   {"toolcatalogs"},
+	"--kill-switches":  {"toolkillswitches"},
+	"--values-from":    {"configmaps", "secrets"},
+}
+
+// routerUnwatched are the router flags that watch no resource, or only
+// namespaced ones granted by the router's Role.
+var routerUnwatched = map[string]bool{
+	"--listen": true, "--mode": true, "--opa": true, "--metrics-addr": true, "--health-addr": true,
+	"--audit-sink": true, "--audit-format": true, "--audit-file": true, "--drain-timeout": true,
+	"--tls-cert": true, "--tls-key": true, "--tls-client-ca": true, "--token-audiences": true,
+	"--invalidation-configmap": true, "--heartbeat": true, "--node-name": true, "--render-rego": true,
+}
+
+// TestInstallClusterRole verifies the ClusterRole of the install manifests
+// lets the router watch every resource its flags make it watch.
+func TestInstallClusterRole(t *testing.T) {
+	base := installValues{
+		namespace: "golden-agent", name: "agent-router", image: "router:test", replicas: 2,
+		mode: "permissive", opa: true, auditSink: "file", auditFormat: "json",
+		drainTimeout: 25 * time.Second,
+	}
+	all := base
+	all.tlsSecret, all.mtls = "router-tls", true
+	all.invalidation, all.heartbeat, all.tokenReview, all.claims, all.valuesFrom, all.renderRego = true, true, true, true, true, true
+	all.tenants, all.tenantConfig, all.toolAliases, all.toolCatalog, all.killSwitches = true, true, true, true, true
+	nodeLocal := all
+	nodeLocal.nodeLocal = true
+
+	for name, v := range map[string]installValues{"defaults": base, "all": all, "node-local": nodeLocal} {
+		t.Run(name, func(t *testing.T) {
+			objects, err := installManifests(v)
+			if err != nil {
+				t.Fatalf("installManifests failed: %v", err)
+			}
+			var role *rbacv1.ClusterRole
+			var pod *corev1.PodSpec
+			for _, obj := range objects {
+				switch o := obj.(type) {
+				case *rbacv1.ClusterRole:
+					role = o
+				case *appsv1.Deployment:
+					pod = &o.Spec.Template.Spec
+				case *appsv1.DaemonSet:
+					pod = &o.Spec.Template.Spec
+				}
+			}
+			if role == nil || pod == nil {
+				t.Fatal("expected a ClusterRole and a router workload")
+			}
+
+			for _, arg := range pod.Containers[0].Args {
+				flag, _, _ := strings.Cut(arg, "=")
+				resources, ok := routerWatches[flag]
+				if !ok {
+					if !routerUnwatched[flag] {
+						t.Errorf("router flag %s is not known to watch or not watch resources", flag)
+					}
+					continue
+				}
+				for _, resource := range resources {
+					if !grants(role.Rules, resource, "get", "list", "watch") {
+						t.Errorf("%s watches %s, which the ClusterRole does not grant", flag, resource)
+					}
+				}
+			}
+			if !v.valuesFrom && grants(role.Rules, "secrets", "get") {
+				t.Error("expected no access to Secrets without -values-from")
+			}
+			if v.renderRego != grants(role.Rules, "configmaps", "get", "create", "update", "patch") {
+				t.Errorf("expected the ClusterRole to let the router write ConfigMaps only with -render-rego")
+			}
+		})
+	}
+}
+
+// grants reports whether rules grant every verb on a resource.
+func grants(rules []rbacv1.PolicyRule, resource string, verbs ...string) bool {
+	granted := make(map[string]bool)
+	for _, rule := range rules {
+		for _, r := range rule.Resources {
+			if r != resource {
+				continue
+			}
+			for _, verb := range rule.Verbs {
+				granted[verb] = true
+			}
+		}
+	}
+	for _, verb := range verbs {
+		if !granted[verb] {
+			return false
+		}
+	}
+	return true
+}
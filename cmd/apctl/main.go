@@ -0,0 +1,102 @@
+// Command apctl is the command-line tool for working with AgentPolicy
+// manifests outside the cluster.
+//
+// Usage:
+//
+//	apctl diff [-summary] old.yaml new.yaml
+//	apctl replay -policy new.yaml [-since 24h] [-v] audit.log...
+//	apctl compare -baseline old.yaml -candidate new.yaml [-since 24h] [-interval 1h] [-json] audit.log...
+//	apctl sarif [-policy new.yaml] [-since 24h] [-policy-uri PATH] [-source-root DIR] audit.log...
+//	apctl profile [-agent-type TYPE] [-since 24h] [-include-denials] audit.log...
+//	apctl generate -from-audit audit.log -agent-type TYPE [-mode enforcing] [-allowed-only]
+//	apctl gatekeeper policy.yaml...
+//	apctl conftest -o DIR policy.yaml...
+//	apctl compliance [-framework iec62443] [-format markdown] [-audit audit.log]... policy.yaml...
+//	apctl bundle -key-file KEY -o bundle.yaml policy.yaml...
+//	apctl bundle snapshot -from SNAPSHOT -key-file KEY -o bundle.yaml
+//	apctl bundle verify -public-key-file KEY bundle.yaml
+//	apctl install manifests [-namespace NS] [-mode enforcing] [-audit-sink json]
+//	apctl verify-audit -key-file KEY [-restarts any|marked] audit.log...
+//
+// Policies are compiled the same way the controller compiles them, so the
+// output reflects what the router would enforce.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Exit codes follow diff(1): 0 no differences, 1 differences, 2 trouble.
+const (
+	exitOK      = 0
+	exitChanged = 1
+	exitError   = 2
+)
+
+const usage = `apctl - AgentPolicy command-line tool
+
+Usage:
+  apctl diff [-summary] OLD.yaml NEW.yaml   Show semantic policy changes
+  apctl replay -policy NEW.yaml AUDIT.log   Replay recorded traffic against a policy
+  apctl compare -baseline OLD.yaml -candidate NEW.yaml AUDIT.log
+                                            Report where two policy versions diverge on recorded traffic
+  apctl sarif [-policy NEW.yaml] AUDIT.log  Export denials (or replay changes) as SARIF for CI
+  apctl profile AUDIT.log                   Learn AgentProfile baselines from recorded traffic
+  apctl generate -from-audit AUDIT.log -agent-type TYPE
+                                            Generate a tight AgentPolicy from recorded traffic
+  apctl gatekeeper POLICY.yaml...           Export policies as Gatekeeper admission constraints
+  apctl conftest -o DIR POLICY.yaml...      Export policies as a conftest pack checking agent task plans
+  apctl compliance [-audit AUDIT.log] POLICY.yaml...
+                                            Report policies against IEC 62443, SOC 2, or NIST controls
+  apctl bundle -key-file KEY -o BUNDLE.yaml POLICY.yaml...
+                                            Write a signed policy bundle for offline routers
+  apctl bundle snapshot -from SNAPSHOT -key-file KEY -o BUNDLE.yaml
+                                            Write the policies of a router snapshot as a signed bundle
+  apctl bundle verify -public-key-file KEY BUNDLE.yaml
+                                            Verify a bundle's signature and fingerprints and print it
+  apctl install manifests                   Print the manifests that deploy the router
+  apctl verify-audit -key-file KEY AUDIT.log
+                                            Verify the integrity chain of audit logs
+
+Run "apctl <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitError)
+	}
+
+	switch os.Args[1] {
+	case "diff":
+		os.Exit(runDiff(os.Args[2:]))
+	case "replay":
+		os.Exit(runReplay(os.Args[2:]))
+	case "compare":
+		os.Exit(runCompare(os.Args[2:]))
+	case "sarif":
+		os.Exit(runSARIF(os.Args[2:]))
+	case "profile":
+		os.Exit(runProfile(os.Args[2:]))
+	case "generate":
+		os.Exit(runGenerate(os.Args[2:]))
+	case "gatekeeper":
+		os.Exit(runGatekeeper(os.Args[2:]))
+	case "conftest":
+		os.Exit(runConftest(os.Args[2:]))
+	case "compliance":
+		os.Exit(runCompliance(os.Args[2:]))
+	case "bundle":
+		os.Exit(runBundle(os.Args[2:]))
+	case "install":
+		os.Exit(runInstall(os.Args[2:]))
+	case "verify-audit":
+		os.Exit(runVerifyAudit(os.Args[2:]))
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "apctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(exitError)
+	}
+}
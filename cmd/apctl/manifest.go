@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// manifest is the part of an AgentPolicy manifest apctl reads.
+// Status is ignored: it is owned by the controller and manifests often
+// carry placeholder values that do not decode (e.g., lastUpdated: "").
+type manifest struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        metav1.ObjectMeta              `json:"metadata,omitempty"`
+	Spec            agentsv1alpha1.AgentPolicySpec `json:"spec"`
+}
+
+// loadManifest reads an AgentPolicy manifest from a YAML or JSON file.
+func loadManifest(path string) (*agentsv1alpha1.AgentPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Kind != "" && m.Kind != "AgentPolicy" {
+		return nil, fmt.Errorf("%s: expected kind AgentPolicy, got %q", path, m.Kind)
+	}
+
+	return &agentsv1alpha1.AgentPolicy{
+		TypeMeta:   m.TypeMeta,
+		ObjectMeta: m.Metadata,
+		Spec:       m.Spec,
+	}, nil
+}
+
+// compileManifest loads and compiles an AgentPolicy manifest.
+func compileManifest(path string) (*policy.CompiledPolicy, error) {
+	ap, err := loadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := compile.AgentPolicy(ap, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return result.Policy, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/golden-agent/golden-agent/pkg/controller"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runProfile implements "apctl profile AUDIT.log...": it learns the
+// behavior baseline of each agent type in the logs and prints it as
+// AgentProfile manifests. Logs must be written by an engine built
+// WithAuditParameters for parameter baselines to be learned.
+func runProfile(args []string) int {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	agentType := fs.String("agent-type", "", "only profile this agent type (default: every agent type in the logs)")
+	since := fs.Duration("since", 0, "only learn from events newer than this (e.g. 24h; 0 uses everything)")
+	includeDenials := fs.Bool("include-denials", false, "learn from denied calls too (for permissive-mode traffic)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl profile [-agent-type TYPE] [-since 24h] [-include-denials] AUDIT.log...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+
+	learner := policy.NewProfileLearner()
+	learner.IncludeDenials = *includeDenials
+	for _, path := range fs.Args() {
+		events, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl profile: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl profile: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		for i := range events {
+			if *agentType == "" || events[i].Agent.AgentType == *agentType {
+				learner.Log(&events[i])
+			}
+		}
+	}
+
+	agentTypes := learner.AgentTypes()
+	if len(agentTypes) == 0 {
+		fmt.Fprintln(os.Stderr, "apctl profile: no matching events")
+		return exitError
+	}
+
+	for i, t := range agentTypes {
+		p, _ := learner.Profile(t)
+		data, err := yaml.Marshal(controller.NewAgentProfile(t+"-profile", p))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl profile: %v\n", err)
+			return exitError
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(data))
+	}
+	return exitOK
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runReplay implements "apctl replay -policy NEW AUDIT.log...".
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "proposed AgentPolicy manifest (required)")
+	since := fs.Duration("since", 0, "only replay events newer than this (e.g. 24h; 0 replays everything)")
+	verbose := fs.Bool("v", false, "list every call whose decision would change")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl replay -policy NEW.yaml [-since 24h] [-v] AUDIT.log...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *policyPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	proposed, err := compileManifest(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl replay: %v\n", err)
+		return exitError
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+
+	var events []policy.AuditEvent
+	for _, path := range fs.Args() {
+		fileEvents, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl replay: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl replay: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	report, err := replay.Run(context.Background(), events, proposed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl replay: %v\n", err)
+		return exitError
+	}
+
+	fmt.Print(report)
+	if *verbose {
+		for _, flip := range report.Flips {
+			fmt.Printf("  %s %s %s: %s -> %s (%s)\n",
+				flip.Event.Timestamp.Format(time.RFC3339), flip.Event.Agent.AgentType, flip.Event.Tool,
+				flip.Event.Decision, flip.Decision, flip.Reason)
+		}
+	}
+
+	if len(report.Flips) > 0 {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// readAuditLog reads the events of one audit log file within the window.
+func readAuditLog(path string, window replay.Window) ([]policy.AuditEvent, replay.ReadStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, replay.ReadStats{}, err
+	}
+	defer f.Close()
+
+	events, stats, err := replay.ReadEvents(f, window)
+	if err != nil {
+		return nil, stats, fmt.Errorf("%s: %w", path, err)
+	}
+	return events, stats, nil
+}
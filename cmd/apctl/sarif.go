@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+	"github.com/golden-agent/golden-agent/pkg/policy/sarif"
+)
+
+// runSARIF implements "apctl sarif [-policy NEW] AUDIT.log...".
+func runSARIF(args []string) int {
+	fs := flag.NewFlagSet("sarif", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "proposed AgentPolicy manifest: report the calls it would change instead of recorded denials")
+	since := fs.Duration("since", 0, "only export events newer than this (e.g. 24h; 0 exports everything)")
+	policyURI := fs.String("policy-uri", "", "repository path of the policy manifest, where results without a file are reported (default: -policy)")
+	sourceRoot := fs.String("source-root", "", "directory the repository was mounted at in the agent's sandbox (e.g. /workspace)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl sarif [-policy NEW.yaml] [-since 24h] [-policy-uri PATH] [-source-root DIR] AUDIT.log...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var window replay.Window
+	if *since > 0 {
+		window.Since = time.Now().Add(-*since)
+	}
+
+	var events []policy.AuditEvent
+	for _, path := range fs.Args() {
+		fileEvents, stats, err := readAuditLog(path, window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl sarif: %v\n", err)
+			return exitError
+		}
+		if stats.Malformed > 0 {
+			fmt.Fprintf(os.Stderr, "apctl sarif: %s: skipped %d non-JSON lines\n", path, stats.Malformed)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	opts := sarif.Options{PolicyURI: *policyURI, SourceRoot: *sourceRoot}
+	var log *sarif.Log
+	if *policyPath != "" {
+		proposed, err := compileManifest(*policyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl sarif: %v\n", err)
+			return exitError
+		}
+		report, err := replay.Run(context.Background(), events, proposed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl sarif: %v\n", err)
+			return exitError
+		}
+		if opts.PolicyURI == "" {
+			opts.PolicyURI = *policyPath
+		}
+		log = sarif.FromReport(report, opts)
+	} else {
+		log = sarif.FromDenials(events, opts)
+	}
+
+	if err := log.Write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "apctl sarif: %v\n", err)
+		return exitError
+	}
+	if len(log.Runs[0].Results) > 0 {
+		return exitChanged
+	}
+	return exitOK
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// runVerifyAudit implements "apctl verify-audit -key-file KEY [-restarts
+// none|any|marked] AUDIT.log...".
+func runVerifyAudit(args []string) int {
+	fs := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "HMAC key the router chained the audit log with (--audit-hmac-key-file)")
+	restarts := fs.String("restarts", "none", "chain restarts to accept: none, any (logs of --audit-sink=json, restarted with the router), or marked (logs of --audit-sink=file, restarted with a restart marker)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apctl verify-audit -key-file KEY [-restarts none|any|marked] AUDIT.log...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *keyFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	var opts policy.AuditChainOptions
+	switch *restarts {
+	case "none":
+	case "any":
+		opts.AllowRestarts = true
+	case "marked":
+		opts.AllowRestarts, opts.RequireRestartMarkers = true, true
+	default:
+		fmt.Fprintf(os.Stderr, "apctl verify-audit: invalid -restarts %q: must be none, any, or marked\n", *restarts)
+		return exitError
+	}
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apctl verify-audit: %v\n", err)
+		return exitError
+	}
+	key = bytes.TrimSpace(key)
+
+	status := exitOK
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl verify-audit: %v\n", err)
+			return exitError
+		}
+		report, err := policy.VerifyAuditChain(f, key, opts)
+		f.Close()
+		if errors.Is(err, policy.ErrAuditChainBroken) {
+			fmt.Printf("%s: FAILED: %v\n", path, err)
+			status = exitChanged
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apctl verify-audit: %s: %v\n", path, err)
+			return exitError
+		}
+		fmt.Printf("%s: OK: %d records, seq %d-%d", path, report.Records, report.FirstSeq, report.LastSeq)
+		if len(report.Restarts) > 0 {
+			fmt.Printf(", %d restarts", len(report.Restarts))
+		}
+		fmt.Println()
+		for _, restart := range report.Restarts {
+			if restart.AfterSeq > 0 {
+				fmt.Printf("%s: line %d: chain restarts after seq %d\n", path, restart.Line, restart.AfterSeq)
+			} else {
+				fmt.Printf("%s: line %d: chain restarts after an unchained line\n", path, restart.Line)
+			}
+		}
+	}
+	return status
+}
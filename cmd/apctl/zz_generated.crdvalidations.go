@@ -0,0 +1,31 @@
+// Code generated by TestCRDValidations from the XValidation markers of
+// api/v1alpha1. DO NOT EDIT.
+
+package main
+
+import (
+	"reflect"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// crdValidations are the CEL rules of the API types, from their
+// XValidation markers, so the API server rejects invalid specs even
+// without an admission webhook.
+var crdValidations = map[reflect.Type]apiextensionsv1.ValidationRules{
+	reflect.TypeOf(agentsv1alpha1.ConstraintValuesSource{}): {
+		{Rule: "has(self.configMapKeyRef) != has(self.secretKeyRef)", Message: "exactly one of configMapKeyRef and secretKeyRef must be set"},
+	},
+	reflect.TypeOf(agentsv1alpha1.RegisterRange{}): {
+		{Rule: "self.start <= self.end", Message: "start must not be greater than end"},
+	},
+	reflect.TypeOf(agentsv1alpha1.ToolConstraints{}): {
+		{Rule: "!has(self.allowedDomains) || !has(self.deniedDomains) || !self.allowedDomains.exists(d, d in self.deniedDomains)", Message: "a domain cannot be listed in both allowedDomains and deniedDomains"},
+		{Rule: "!has(self.timeout) || duration(self.timeout) >= duration('0s')", Message: "timeout must be a duration such as \"60s\" or \"5m\""},
+	},
+	reflect.TypeOf(agentsv1alpha1.ToolPermission{}): {
+		{Rule: "self.action == 'allow' || !has(self.constraints)", Message: "constraints are only valid when action is allow"},
+	},
+}
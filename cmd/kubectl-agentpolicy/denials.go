@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// runDenials implements "kubectl agentpolicy denials POLICY".
+func runDenials(args []string) int {
+	fs := flag.NewFlagSet("denials", flag.ContinueOnError)
+	c := addClusterFlags(fs)
+	since := fs.Duration("since", time.Hour, "list denials newer than this (0 lists the whole log)")
+	summary := fs.Bool("summary", false, "count denials per agent type and tool instead of listing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kubectl agentpolicy denials POLICY [-since 1h] [-summary]")
+		fs.PrintDefaults()
+	}
+	name, err := parsePolicyArgs(fs, args)
+	if err != nil {
+		fs.Usage()
+		return exitError
+	}
+
+	ctx := context.Background()
+	_, compiled, err := c.compilePolicy(ctx, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy denials: %v\n", err)
+		return exitError
+	}
+
+	events, _, err := c.auditEvents(ctx, *since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy denials: %v\n", err)
+		return exitError
+	}
+
+	// Events record the agent, not the policy, so attribute each denial to
+	// the policy the agent resolves to
+	engine := newEngine(compiled)
+	var denials []policy.AuditEvent
+	for _, event := range events {
+		if event.Decision == policy.Deny && governs(engine, compiled, event.Agent) {
+			denials = append(denials, event)
+		}
+	}
+	// Logs of several router pods are concatenated, not interleaved
+	sort.SliceStable(denials, func(i, j int) bool {
+		return denials[i].Timestamp.Before(denials[j].Timestamp)
+	})
+
+	if len(denials) == 0 {
+		fmt.Printf("policy %s: no denials\n", name)
+		return exitOK
+	}
+
+	if *summary {
+		counts := make(map[[2]string]int)
+		var keys [][2]string
+		total := 0
+		for _, event := range denials {
+			key := [2]string{event.Agent.AgentType, event.Tool}
+			if counts[key] == 0 {
+				keys = append(keys, key)
+			}
+			// Coalesced lines stand for the denials they count
+			n := max(event.Repeated, 1)
+			counts[key] += n
+			total += n
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if counts[keys[i]] != counts[keys[j]] {
+				return counts[keys[i]] > counts[keys[j]]
+			}
+			return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1]
+		})
+		fmt.Printf("policy %s: %d denials\n", name, total)
+		for _, key := range keys {
+			fmt.Printf("  %6d  %s %s\n", counts[key], key[0], key[1])
+		}
+		return exitOK
+	}
+
+	for _, event := range denials {
+		repeated := ""
+		if event.Repeated > 0 {
+			repeated = fmt.Sprintf(" (repeated %d times)", event.Repeated)
+		}
+		fmt.Printf("%s %s sandbox=%s %s: %s%s\n",
+			event.Timestamp.Format(time.RFC3339), event.Agent.AgentType, event.Agent.SandboxID, event.Tool, event.Reason, repeated)
+	}
+	return exitOK
+}
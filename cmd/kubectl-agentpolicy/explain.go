@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// runExplain implements "kubectl agentpolicy explain POLICY".
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	c := addClusterFlags(fs)
+	tool := fs.String("tool", "", "only explain how the policy decides calls of this tool")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kubectl agentpolicy explain POLICY [-tool TOOL]")
+		fs.PrintDefaults()
+	}
+	name, err := parsePolicyArgs(fs, args)
+	if err != nil {
+		fs.Usage()
+		return exitError
+	}
+
+	ap, compiled, err := c.compilePolicy(context.Background(), name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy explain: %v\n", err)
+		return exitError
+	}
+
+	if *tool != "" {
+		perm, ok := compiled.ToolTable[*tool]
+		if !ok {
+			fmt.Printf("%s: no rule; the default action %s applies\n", *tool, compiled.DefaultAction)
+			return exitOK
+		}
+		printRule(perm)
+		return exitOK
+	}
+
+	fmt.Printf("Policy:         %s/%s\n", ap.Namespace, ap.Name)
+	fmt.Printf("Agent types:    %s\n", strings.Join(compiled.AgentTypes, ", "))
+	if s := compiled.AgentSelector; s != nil {
+		fmt.Printf("Agent selector: %s\n", formatSelector(s))
+	}
+	fmt.Printf("Mode:           %s\n", compiled.Mode)
+	fmt.Printf("Default action: %s\n", compiled.DefaultAction)
+	if compiled.MTSLabel != "" {
+		fmt.Printf("MTS label:      %s\n", compiled.MTSLabel)
+	}
+	if rl := compiled.RateLimit; rl != nil {
+		fmt.Printf("Rate limit:     %g/s, burst %d\n", rl.RequestsPerSecond, rl.Burst)
+	}
+	if compiled.ProfileAction != policy.ProfileOff {
+		fmt.Printf("Profile:        %s after %d samples\n", compiled.ProfileAction, compiled.ProfileMinSamples)
+	}
+	if b := compiled.Budget; b != nil {
+		printBudget(b)
+	}
+
+	tools := make([]string, 0, len(compiled.ToolTable))
+	for t := range compiled.ToolTable {
+		tools = append(tools, t)
+	}
+	sort.Strings(tools)
+	fmt.Printf("Rules:          %d\n", len(tools))
+	for _, t := range tools {
+		printRule(compiled.ToolTable[t])
+	}
+
+	status := ap.Status
+	if status.ObservedGeneration != 0 && status.ObservedGeneration != ap.Generation {
+		fmt.Printf("Status:         stale (observed generation %d, current %d)\n", status.ObservedGeneration, ap.Generation)
+	}
+	if status.LastChangeSummary != "" {
+		fmt.Printf("Last change:    %s\n", status.LastChangeSummary)
+	}
+	if status.LastUpdated != nil {
+		fmt.Printf("Last compiled:  %s\n", status.LastUpdated.Format(time.RFC3339))
+	}
+	for _, at := range status.AgentTypes {
+		fmt.Printf("Agent type:     %s loaded=%t %s: %s\n", at.AgentType, at.Loaded, at.Reason, at.Message)
+	}
+	for _, replica := range status.Replicas {
+		fmt.Printf("Replica:        %s (generation %d)\n", replica.Identity, replica.ObservedGeneration)
+	}
+	for _, cond := range status.Conditions {
+		fmt.Printf("Condition:      %s=%s %s: %s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+	}
+	return exitOK
+}
+
+// printBudget prints the spend budget of a policy.
+func printBudget(b *policy.Budget) {
+	period := "total"
+	if b.Period > 0 {
+		period = "per " + b.Period.String()
+	}
+	for _, scope := range []struct {
+		name  string
+		limit *policy.BudgetLimit
+	}{{"session", b.Session}, {"tenant", b.Tenant}} {
+		if l := scope.limit; l != nil {
+			fmt.Printf("Budget:         %s limit %d, approval past %d (%s)\n", scope.name, l.Limit, l.ApprovalThreshold, period)
+		}
+	}
+}
+
+// printRule prints a tool rule and its constraints.
+func printRule(perm *policy.ToolPermission) {
+	fmt.Printf("  %s: %s\n", perm.Tool, perm.Action)
+	if perm.Cost > 0 {
+		fmt.Printf("    cost:            %d\n", perm.Cost)
+	}
+	if cons := perm.Constraints; cons != nil {
+		if len(cons.PathPatterns) > 0 {
+			fmt.Printf("    paths:           %s\n", strings.Join(cons.PathPatterns, ", "))
+		}
+		if len(cons.AllowedDomains) > 0 {
+			fmt.Printf("    allowed domains: %s\n", strings.Join(cons.AllowedDomains, ", "))
+		}
+		if len(cons.DeniedDomains) > 0 {
+			fmt.Printf("    denied domains:  %s\n", strings.Join(cons.DeniedDomains, ", "))
+		}
+		if len(cons.AllowedPorts) > 0 {
+			fmt.Printf("    ports:           %v\n", cons.AllowedPorts)
+		}
+		if cons.MaxSizeBytes > 0 {
+			fmt.Printf("    max size:        %d bytes\n", cons.MaxSizeBytes)
+		}
+		if cons.Timeout > 0 {
+			fmt.Printf("    timeout:         %s\n", cons.Timeout)
+		}
+		if cons.MaxConcurrent > 0 {
+			fmt.Printf("    max concurrent:  %d\n", cons.MaxConcurrent)
+		}
+		if len(cons.RequiredAgentLabels) > 0 {
+			fmt.Printf("    agent labels:    %s\n", formatLabels(cons.RequiredAgentLabels))
+		}
+		if len(cons.AllowedContentHashes) > 0 {
+			fmt.Printf("    content hashes:  %d\n", len(cons.AllowedContentHashes))
+		}
+		if c := cons.Conditions; c != nil {
+			printConditions(c)
+		}
+		for _, ext := range cons.Extensions {
+			fmt.Printf("    extension:       %s\n", ext.Name())
+		}
+		for kind := range cons.Custom {
+			fmt.Printf("    custom:          %s\n", kind)
+		}
+	}
+	for _, m := range perm.Mutators {
+		fmt.Printf("    mutator:         %s %s\n", m.Type, m.Param)
+	}
+	for _, o := range perm.Obligations {
+		fmt.Printf("    obligation:      %s\n", o.Type)
+	}
+	if perm.DenyMessage != "" {
+		fmt.Printf("    deny message:    %s\n", perm.DenyMessage)
+	}
+}
+
+// formatSelector formats a label selector like kubectl does.
+func formatSelector(s *policy.LabelSelector) string {
+	parts := []string{}
+	if len(s.MatchLabels) > 0 {
+		parts = append(parts, formatLabels(s.MatchLabels))
+	}
+	for _, req := range s.MatchExpressions {
+		switch {
+		case len(req.Values) > 0:
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", req.Key, req.Operator, strings.Join(req.Values, ",")))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %s", req.Key, req.Operator))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatLabels formats labels as sorted key=value pairs.
+// printConditions prints the environment and destination conditions of a rule.
+func printConditions(c *policy.ToolConditions) {
+	if len(c.Clusters) > 0 {
+		fmt.Printf("    clusters:        %s\n", strings.Join(c.Clusters, ", "))
+	}
+	if len(c.Environments) > 0 {
+		fmt.Printf("    environments:    %s\n", strings.Join(c.Environments, ", "))
+	}
+	if len(c.Zones) > 0 {
+		fmt.Printf("    zones:           %s\n", strings.Join(c.Zones, ", "))
+	}
+	if len(c.DestinationCountries) > 0 {
+		fmt.Printf("    dest countries:  %s\n", strings.Join(c.DestinationCountries, ", "))
+	}
+	if len(c.DestinationContinents) > 0 {
+		fmt.Printf("    dest continents: %s\n", strings.Join(c.DestinationContinents, ", "))
+	}
+	if len(c.DestinationASNs) > 0 {
+		fmt.Printf("    dest ASNs:       %v\n", c.DestinationASNs)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// cluster runs kubectl against the current cluster. Going through kubectl
+// keeps the plugin on the user's kubeconfig, context, and credential
+// plugins, exactly as the parent command would use them.
+type cluster struct {
+	kubectl   string
+	context   string
+	namespace string
+
+	// routerNamespace and routerSelector locate the router pods whose
+	// audit log is read
+	routerNamespace string
+	routerSelector  string
+}
+
+// addClusterFlags registers the flags shared by every command.
+func addClusterFlags(fs *flag.FlagSet) *cluster {
+	c := &cluster{}
+	fs.StringVar(&c.kubectl, "kubectl", "kubectl", "kubectl binary")
+	fs.StringVar(&c.context, "context", "", "kubeconfig context (default: current context)")
+	fs.StringVar(&c.namespace, "n", "", "namespace of the policy (default: the context's namespace)")
+	fs.StringVar(&c.routerNamespace, "router-namespace", "golden-agent", "namespace of the router")
+	fs.StringVar(&c.routerSelector, "router-selector", "app.kubernetes.io/name=agent-router", "label selector of the router pods")
+	return c
+}
+
+// run runs kubectl with args and returns its output.
+func (c *cluster) run(ctx context.Context, args ...string) ([]byte, error) {
+	if c.context != "" {
+		args = append([]string{"--context", c.context}, args...)
+	}
+	cmd := exec.CommandContext(ctx, c.kubectl, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("kubectl %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("kubectl %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// getPolicy fetches an AgentPolicy.
+func (c *cluster) getPolicy(ctx context.Context, name string) (*agentsv1alpha1.AgentPolicy, error) {
+	args := []string{"get", "agentpolicies.agents.sandbox.io", name, "-o", "json"}
+	if c.namespace != "" {
+		args = append(args, "-n", c.namespace)
+	}
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ap agentsv1alpha1.AgentPolicy
+	if err := json.Unmarshal(out, &ap); err != nil {
+		return nil, fmt.Errorf("decoding AgentPolicy %s: %w", name, err)
+	}
+	return &ap, nil
+}
+
+// compilePolicy fetches and compiles an AgentPolicy.
+func (c *cluster) compilePolicy(ctx context.Context, name string) (*agentsv1alpha1.AgentPolicy, *policy.CompiledPolicy, error) {
+	ap, err := c.getPolicy(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := compile.AgentPolicy(ap, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AgentPolicy %s: %w", name, err)
+	}
+	return ap, result.Policy, nil
+}
+
+// auditEvents reads the audit events the router pods logged within the
+// last since (0 reads the containers' whole log).
+func (c *cluster) auditEvents(ctx context.Context, since time.Duration) ([]policy.AuditEvent, replay.ReadStats, error) {
+	// kubectl limits selector logs to the last 10 lines unless told otherwise
+	args := []string{"logs", "-n", c.routerNamespace, "-l", c.routerSelector, "--tail=-1", "--max-log-requests=20"}
+	var window replay.Window
+	if since > 0 {
+		args = append(args, "--since="+since.String())
+		window.Since = time.Now().Add(-since)
+	}
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, replay.ReadStats{}, err
+	}
+	return replay.ReadEvents(bytes.NewReader(out), window)
+}
+
+// newEngine returns an isolated enforcing engine with the policy loaded
+// under each of its agent types, so decisions are the policy's own rather
+// than softened by permissive mode.
+func newEngine(p *policy.CompiledPolicy) *policy.Engine {
+	engine := policy.NewEngine(
+		policy.WithMode(policy.Enforcing),
+		policy.WithAuditSink(&policy.NullAuditSink{}),
+		policy.WithOPA(p.OPAEnabled),
+	)
+	for _, agentType := range p.AgentTypes {
+		engine.LoadPolicy(agentType, p)
+	}
+	return engine
+}
+
+// governs reports whether the router would resolve agent to the policy
+// loaded into engine.
+func governs(engine *policy.Engine, p *policy.CompiledPolicy, agent policy.AgentContext) bool {
+	resolved, ok := engine.ResolvePolicy(agent)
+	return ok && resolved == p
+}
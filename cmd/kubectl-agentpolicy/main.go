@@ -0,0 +1,65 @@
+// Command kubectl-agentpolicy is a kubectl plugin for inspecting the
+// AgentPolicies of a cluster and the decisions the router makes with them.
+// Installed on the PATH, it runs as "kubectl agentpolicy".
+//
+// Usage:
+//
+//	kubectl agentpolicy test POLICY -agent-type TYPE -tool TOOL [-params JSON]
+//	kubectl agentpolicy simulate POLICY -f NEW.yaml [-since 24h] [-v]
+//	kubectl agentpolicy explain POLICY [-agent-type TYPE -tool TOOL]
+//	kubectl agentpolicy denials POLICY [-since 1h]
+//
+// Policies are read from the cluster and compiled the same way the
+// controller compiles them; recent decisions are read from the router's
+// JSON audit log (its container output with -audit-sink=json). The router
+// has no admin API yet, so decisions are evaluated locally rather than
+// asked of a running router.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Exit codes: 0 allowed or unchanged, 1 denied or changed, 2 trouble.
+const (
+	exitOK      = 0
+	exitChanged = 1
+	exitError   = 2
+)
+
+const usage = `kubectl agentpolicy - inspect AgentPolicies and their decisions
+
+Usage:
+  kubectl agentpolicy test POLICY -agent-type TYPE -tool TOOL
+                                       Evaluate a tool call against a live policy
+  kubectl agentpolicy simulate POLICY -f NEW.yaml
+                                       Replay the router's recent traffic against a proposed change
+  kubectl agentpolicy explain POLICY   Show the compiled rules and status of a policy
+  kubectl agentpolicy denials POLICY   List recent calls the policy denied
+
+Run "kubectl agentpolicy <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitError)
+	}
+
+	switch os.Args[1] {
+	case "test":
+		os.Exit(runTest(os.Args[2:]))
+	case "simulate":
+		os.Exit(runSimulate(os.Args[2:]))
+	case "explain":
+		os.Exit(runExplain(os.Args[2:]))
+	case "denials":
+		os.Exit(runDenials(os.Args[2:]))
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// runSimulate implements "kubectl agentpolicy simulate POLICY -f NEW.yaml".
+func runSimulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	c := addClusterFlags(fs)
+	file := fs.String("f", "", "proposed AgentPolicy manifest (required)")
+	since := fs.Duration("since", 24*time.Hour, "replay router traffic newer than this (0 replays the whole log)")
+	verbose := fs.Bool("v", false, "list every call whose decision would change")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kubectl agentpolicy simulate POLICY -f NEW.yaml [-since 24h] [-v]")
+		fs.PrintDefaults()
+	}
+	name, err := parsePolicyArgs(fs, args)
+	if err != nil || *file == "" {
+		fs.Usage()
+		return exitError
+	}
+
+	ctx := context.Background()
+	// The live policy must exist: simulate previews a change, not a new policy
+	if _, err := c.getPolicy(ctx, name); err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: %v\n", err)
+		return exitError
+	}
+
+	ap, err := loadManifest(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: %v\n", err)
+		return exitError
+	}
+	if ap.Name != "" && ap.Name != name {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: warning: %s defines policy %q, not %q\n", *file, ap.Name, name)
+	}
+	ap.Name = name
+	result, err := compile.AgentPolicy(ap, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: %s: %v\n", *file, err)
+		return exitError
+	}
+
+	events, stats, err := c.auditEvents(ctx, *since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: %v\n", err)
+		return exitError
+	}
+	if stats.Lines > 0 && len(events) == 0 {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: no JSON audit events in %d router log lines (is the router run with -audit-sink=json?)\n", stats.Lines)
+	}
+
+	report, err := replay.Run(ctx, events, result.Policy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy simulate: %v\n", err)
+		return exitError
+	}
+
+	fmt.Print(report)
+	if *verbose {
+		for _, flip := range report.Flips {
+			fmt.Printf("  %s %s %s: %s -> %s (%s)\n",
+				flip.Event.Timestamp.Format(time.RFC3339), flip.Event.Agent.AgentType, flip.Event.Tool,
+				flip.Event.Decision, flip.Decision, flip.Reason)
+		}
+	}
+
+	if len(report.Flips) > 0 {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// manifest is the part of an AgentPolicy manifest simulate reads. Status
+// is ignored, as manifests often carry placeholder values that do not
+// decode.
+type manifest struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        metav1.ObjectMeta              `json:"metadata,omitempty"`
+	Spec            agentsv1alpha1.AgentPolicySpec `json:"spec"`
+}
+
+// loadManifest reads an AgentPolicy manifest from a YAML or JSON file.
+func loadManifest(path string) (*agentsv1alpha1.AgentPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Kind != "" && m.Kind != "AgentPolicy" {
+		return nil, fmt.Errorf("%s: expected kind AgentPolicy, got %q", path, m.Kind)
+	}
+
+	return &agentsv1alpha1.AgentPolicy{
+		TypeMeta:   m.TypeMeta,
+		ObjectMeta: m.Metadata,
+		Spec:       m.Spec,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// runTest implements "kubectl agentpolicy test POLICY -agent-type TYPE -tool TOOL".
+func runTest(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := addClusterFlags(fs)
+	agentType := fs.String("agent-type", "", "agent type making the call (default: the policy's first exact agent type)")
+	tool := fs.String("tool", "", "tool to call (required)")
+	params := fs.String("params", "", "tool parameters as a JSON object")
+	var labels stringMap
+	fs.Var(&labels, "label", "agent label key=value (repeatable)")
+	tenant := fs.String("tenant", "", "tenant ID of the agent")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kubectl agentpolicy test POLICY -tool TOOL [-agent-type TYPE] [-params JSON] [-label k=v]")
+		fs.PrintDefaults()
+	}
+	name, err := parsePolicyArgs(fs, args)
+	if err != nil || *tool == "" {
+		fs.Usage()
+		return exitError
+	}
+
+	var request map[string]interface{}
+	if *params != "" {
+		if err := json.Unmarshal([]byte(*params), &request); err != nil {
+			fmt.Fprintf(os.Stderr, "kubectl agentpolicy test: invalid -params: %v\n", err)
+			return exitError
+		}
+	}
+
+	ctx := context.Background()
+	_, compiled, err := c.compilePolicy(ctx, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy test: %v\n", err)
+		return exitError
+	}
+
+	agent := policy.AgentContext{AgentType: *agentType, TenantID: *tenant, Labels: labels}
+	if agent.AgentType == "" {
+		for _, t := range compiled.AgentTypes {
+			if !policy.IsAgentTypePattern(t) {
+				agent.AgentType = t
+				break
+			}
+		}
+		if agent.AgentType == "" {
+			fmt.Fprintln(os.Stderr, "kubectl agentpolicy test: policy has only agent type patterns; set -agent-type")
+			return exitError
+		}
+	}
+
+	engine := newEngine(compiled)
+	if !governs(engine, compiled, agent) {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy test: policy %s does not govern agent type %q with these labels\n", name, agent.AgentType)
+		return exitError
+	}
+
+	result, err := engine.EvaluateWithResult(ctx, agent, *tool, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubectl agentpolicy test: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("%s %s: %s (%s)\n", agent.AgentType, *tool, result.Decision, result.Reason)
+	if result.Message != "" {
+		fmt.Printf("  message: %s\n", result.Message)
+	}
+	for _, m := range result.Mutations {
+		fmt.Printf("  mutation: %s\n", m)
+	}
+	if result.Decision == policy.Deny && compiled.Mode == policy.Permissive {
+		fmt.Println("  (policy is permissive: the router logs this denial but allows the call)")
+	}
+
+	if result.Decision == policy.Deny {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// parsePolicyArgs parses the flags of a command whose first argument is a
+// policy name, allowing flags before or after the name.
+func parsePolicyArgs(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() == 0 {
+		return "", fmt.Errorf("policy name is required")
+	}
+	name := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return name, nil
+}
+
+// stringMap is a repeatable key=value flag.
+type stringMap map[string]string
+
+func (m *stringMap) String() string {
+	pairs := make([]string, 0, len(*m))
+	for k, v := range *m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m *stringMap) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[key] = value
+	return nil
+}
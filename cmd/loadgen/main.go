@@ -0,0 +1,271 @@
+// Command loadgen drives a router's AgentService at a fixed rate with a
+// configurable mix of agents, tools, and parameters, and reports the
+// decision latency percentiles and error rate, so that capacity and
+// latency regressions can be measured against a real deployment.
+//
+// Usage:
+//
+//	loadgen -target router:50051 -qps 500 -duration 1m
+//	loadgen -target router:50051 -workload mix.yaml -json > result.json
+//	loadgen -target router:50051 -max-p99 10ms -max-error-rate 0.001
+//
+// Calls are sent open-loop: at the target rate whether or not earlier
+// calls have completed, up to -concurrency in flight; calls beyond that
+// are dropped and counted, which indicates the router is saturated.
+// Policy denials are outcomes, not errors; errors are calls the router
+// did not answer with a decision (e.g., UNAVAILABLE, DEADLINE_EXCEEDED).
+//
+// The "round trip" latency is measured by the client, the "decision"
+// latency is the policy evaluation time the router reports for the calls
+// it answered with a response.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/client/grpc"
+)
+
+// Exit codes: 0 the run met its thresholds, 1 it did not, 2 trouble.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+// options are the settings of a run.
+type options struct {
+	targets      []string
+	qps          float64
+	duration     time.Duration
+	concurrency  int
+	timeout      time.Duration
+	seed         int64
+	token        string
+	maxP99       time.Duration
+	maxErrorRate float64
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	target := fs.String("target", "localhost:50051", "router endpoints, comma-separated (e.g. unix:///run/golden-agent/router.sock)")
+	qps := fs.Float64("qps", 100, "calls per second")
+	duration := fs.Duration("duration", 30*time.Second, "how long to send calls")
+	concurrency := fs.Int("concurrency", 64, "maximum calls in flight")
+	timeout := fs.Duration("timeout", 5*time.Second, "deadline of each call")
+	workloadPath := fs.String("workload", "", "workload file (YAML or JSON) with the agent, tool, and parameter mix (default: a coding-agent mix)")
+	agents := fs.String("agents", "", "agent mix, overriding the workload's: TYPE[=WEIGHT],... (e.g. coding-assistant=3,data-analyst=1)")
+	seed := fs.Int64("seed", 0, "random seed (0 for time-based)")
+	tlsCA := fs.String("tls-ca", "", "CA bundle to verify the router's certificate; enables TLS")
+	tokenFile := fs.String("token-file", "", "ServiceAccount token sent as authorization metadata (for routers with --token-review)")
+	jsonOutput := fs.Bool("json", false, "print the report as JSON")
+	maxP99 := fs.Duration("max-p99", 0, "fail if the p99 round-trip latency exceeds this (0 for no limit)")
+	maxErrorRate := fs.Float64("max-error-rate", -1, "fail if the error rate exceeds this fraction (negative for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: loadgen [-target ADDR] [-qps N] [-duration D] [-workload FILE] [-json] [-max-p99 D] [-max-error-rate F]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *qps <= 0 || *concurrency <= 0 || *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "loadgen: -qps, -concurrency, and -duration must be positive")
+		return exitError
+	}
+
+	w := defaultWorkload()
+	if *workloadPath != "" {
+		var err error
+		if w, err = loadWorkload(*workloadPath); err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+			return exitError
+		}
+	}
+	if *agents != "" {
+		mix, err := parseAgents(*agents)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: -agents: %v\n", err)
+			return exitError
+		}
+		w.Agents = mix
+	}
+	if err := w.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+
+	opts := options{
+		targets:      strings.Split(*target, ","),
+		qps:          *qps,
+		duration:     *duration,
+		concurrency:  *concurrency,
+		timeout:      *timeout,
+		seed:         *seed,
+		maxP99:       *maxP99,
+		maxErrorRate: *maxErrorRate,
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+	if *tokenFile != "" {
+		data, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+			return exitError
+		}
+		opts.token = strings.TrimSpace(string(data))
+	}
+
+	config := grpc.Config{
+		Targets:        opts.targets,
+		DefaultTimeout: opts.timeout,
+		// Retries would hide the errors the run is measuring
+		MaxRetries: -1,
+	}
+	if *tlsCA != "" {
+		pem, err := os.ReadFile(*tlsCA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+			return exitError
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			fmt.Fprintf(os.Stderr, "loadgen: no certificates in %s\n", *tlsCA)
+			return exitError
+		}
+		config.DialOptions = []grpclib.DialOption{
+			grpclib.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})),
+		}
+	}
+	client, err := grpc.New(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rep, err := generate(ctx, client, w, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+
+	if *jsonOutput {
+		err = rep.writeJSON(os.Stdout)
+	} else {
+		err = rep.writeText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+
+	code := exitOK
+	if opts.maxP99 > 0 && rep.Latency.P99 > opts.maxP99 {
+		fmt.Fprintf(os.Stderr, "loadgen: p99 latency %s exceeds %s\n", rep.Latency.P99, opts.maxP99)
+		code = exitFailed
+	}
+	if opts.maxErrorRate >= 0 && rep.ErrorRate > opts.maxErrorRate {
+		fmt.Fprintf(os.Stderr, "loadgen: error rate %.4f exceeds %.4f\n", rep.ErrorRate, opts.maxErrorRate)
+		code = exitFailed
+	}
+	return code
+}
+
+// generate sends calls at the target rate until the duration elapses or
+// ctx is done, waits for the calls in flight, and reports them.
+func generate(ctx context.Context, client *grpc.Client, w *workload, opts options) (*report, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	limiter := rate.NewLimiter(rate.Limit(opts.qps), 1)
+	inFlight := make(chan struct{}, opts.concurrency)
+	rec := newRecorder()
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for limiter.Wait(ctx) == nil {
+		req, err := w.sample(rng)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			rec.drop()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			call(client, req, opts, rec)
+		}()
+	}
+	wg.Wait()
+	return rec.report(time.Since(start)), nil
+}
+
+// call sends one request and records its outcome. Calls in flight when
+// the run ends are allowed to complete.
+func call(client *grpc.Client, req *agentpb.ExecuteRequest, opts options, rec *recorder) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	if opts.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.token)
+	}
+
+	begin := time.Now()
+	resp, err := client.Execute(ctx, req)
+	latency := time.Since(begin)
+
+	switch code := status.Code(err); {
+	case code == codes.PermissionDenied:
+		// Enforcing routers deny with a status
+		rec.record(outcomeDenied, latency, 0, false, false)
+	case errors.Is(err, grpc.ErrCircuitOpen):
+		// The client stopped sending to a failing router
+		rec.record(outcomeCircuitOpen, latency, 0, false, true)
+	case err != nil:
+		rec.record(code.String(), latency, 0, false, true)
+	default:
+		outcome := outcomeToolError
+		switch resp.GetStatus() {
+		case agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS:
+			outcome = outcomeAllowed
+		case agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED:
+			outcome = outcomeDenied
+		}
+		decision := resp.GetPolicyDecision()
+		rec.record(outcome, latency, time.Duration(decision.GetEvaluationTimeNs()), decision.GetCacheHit(), false)
+	}
+}
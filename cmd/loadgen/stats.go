@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Outcomes of a call
+const (
+	outcomeAllowed   = "allowed"
+	outcomeDenied    = "denied"
+	outcomeToolError = "tool_error"
+
+	// outcomeCircuitOpen is a call the client refused to send because
+	// every router endpoint kept failing
+	outcomeCircuitOpen = "circuit_open"
+)
+
+// recorder accumulates the results of the calls of a run.
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration // round trip of answered calls
+	decisions []time.Duration // policy evaluation time the router reported
+	cacheHits int
+	outcomes  map[string]int // outcome or gRPC error code -> calls
+	errors    int
+	dropped   int
+}
+
+func newRecorder() *recorder {
+	return &recorder{outcomes: make(map[string]int)}
+}
+
+// record adds a call's outcome. Errors are calls the router did not answer
+// with a decision (any gRPC error but PERMISSION_DENIED).
+func (r *recorder) record(outcome string, latency, decision time.Duration, cacheHit, isError bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes[outcome]++
+	if isError {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+	if decision > 0 {
+		r.decisions = append(r.decisions, decision)
+	}
+	if cacheHit {
+		r.cacheHits++
+	}
+}
+
+// drop counts a call not sent because the concurrency limit was reached.
+func (r *recorder) drop() {
+	r.mu.Lock()
+	r.dropped++
+	r.mu.Unlock()
+}
+
+// report is the summary of a run.
+type report struct {
+	Duration     time.Duration  `json:"duration"`
+	Calls        int            `json:"calls"`
+	QPS          float64        `json:"qps"`
+	Dropped      int            `json:"dropped"`
+	Errors       int            `json:"errors"`
+	ErrorRate    float64        `json:"errorRate"`
+	CacheHitRate float64        `json:"cacheHitRate"`
+	Outcomes     map[string]int `json:"outcomes"`
+	Latency      percentiles    `json:"latency"`
+	Decision     percentiles    `json:"decision"`
+}
+
+// percentiles summarize a latency distribution.
+type percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// report summarizes the calls recorded over a run of the given duration.
+func (r *recorder) report(elapsed time.Duration) *report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := 0
+	outcomes := make(map[string]int, len(r.outcomes))
+	for outcome, n := range r.outcomes {
+		calls += n
+		outcomes[outcome] = n
+	}
+	rep := &report{
+		Duration: elapsed,
+		Calls:    calls,
+		Dropped:  r.dropped,
+		Errors:   r.errors,
+		Outcomes: outcomes,
+		Latency:  percentilesOf(r.latencies),
+		Decision: percentilesOf(r.decisions),
+	}
+	if elapsed > 0 {
+		rep.QPS = float64(calls) / elapsed.Seconds()
+	}
+	if calls > 0 {
+		rep.ErrorRate = float64(r.errors) / float64(calls)
+	}
+	if answered := len(r.latencies); answered > 0 {
+		rep.CacheHitRate = float64(r.cacheHits) / float64(answered)
+	}
+	return rep
+}
+
+// percentilesOf computes the percentiles of a distribution, nearest-rank.
+func percentilesOf(samples []time.Duration) percentiles {
+	if len(samples) == 0 {
+		return percentiles{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return sorted[i]
+	}
+	return percentiles{P50: at(0.50), P95: at(0.95), P99: at(0.99), Max: sorted[len(sorted)-1]}
+}
+
+// writeText writes the report as a table.
+func (rep *report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "duration\t%s\n", rep.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "calls\t%d (%.1f/s)\n", rep.Calls, rep.QPS)
+	fmt.Fprintf(tw, "dropped\t%d\n", rep.Dropped)
+	fmt.Fprintf(tw, "errors\t%d (%.2f%%)\n", rep.Errors, 100*rep.ErrorRate)
+	fmt.Fprintf(tw, "cache hits\t%.1f%%\n", 100*rep.CacheHitRate)
+
+	outcomes := make([]string, 0, len(rep.Outcomes))
+	for outcome := range rep.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		fmt.Fprintf(tw, "  %s\t%d\n", outcome, rep.Outcomes[outcome])
+	}
+
+	fmt.Fprintf(tw, "\t p50\t p95\t p99\t max\n")
+	for _, row := range []struct {
+		name string
+		p    percentiles
+	}{{"round trip", rep.Latency}, {"decision", rep.Decision}} {
+		fmt.Fprintf(tw, "%s\t %s\t %s\t %s\t %s\n", row.name, row.p.P50, row.p.P95, row.p.P99, row.p.Max)
+	}
+	return tw.Flush()
+}
+
+// writeJSON writes the report as indented JSON, with durations in
+// nanoseconds.
+func (rep *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
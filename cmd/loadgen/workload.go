@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+)
+
+// workload is the traffic mix of a run: which agents call, which tools
+// they call, and the parameters they pass, each picked by weight.
+//
+//	agents:
+//	- {agentType: coding-assistant, tenantID: team-a, weight: 3}
+//	- {agentType: data-analyst, weight: 1}
+//	calls:
+//	- tool: file.read
+//	  weight: 6
+//	  params:
+//	    path: {values: ["/workspace/src/file-{n}.go"], cardinality: 1000}
+type workload struct {
+	Agents []agentMix `json:"agents"`
+	Calls  []callMix  `json:"calls"`
+}
+
+// agentMix is an agent identity and its share of the calls.
+type agentMix struct {
+	AgentType string            `json:"agentType"`
+	TenantID  string            `json:"tenantID,omitempty"`
+	MTSLabel  string            `json:"mtsLabel,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Weight    int               `json:"weight,omitempty"`
+}
+
+// callMix is a tool, its share of the calls, and the distributions of its
+// parameters.
+type callMix struct {
+	Tool   string               `json:"tool"`
+	Weight int                  `json:"weight,omitempty"`
+	Params map[string]paramSpec `json:"params,omitempty"`
+}
+
+// paramSpec is the distribution of a parameter: one of Values, picked
+// uniformly. In string values, "{n}" is replaced by a random number below
+// Cardinality (default: 1000), which controls how often calls repeat and
+// so the decision cache hit rate.
+type paramSpec struct {
+	Values      []interface{} `json:"values"`
+	Cardinality int           `json:"cardinality,omitempty"`
+}
+
+// defaultWorkload is a coding-agent mix of mostly allowed reads, writes,
+// and fetches, with some calls most policies deny.
+func defaultWorkload() *workload {
+	return &workload{
+		Agents: []agentMix{{AgentType: "coding-assistant", Weight: 1}},
+		Calls: []callMix{
+			{Tool: "file.read", Weight: 6, Params: map[string]paramSpec{
+				"path": {Values: []interface{}{"/workspace/src/file-{n}.go"}, Cardinality: 1000},
+			}},
+			{Tool: "file.write", Weight: 2, Params: map[string]paramSpec{
+				"path": {Values: []interface{}{"/workspace/out/file-{n}.txt", "/etc/passwd"}, Cardinality: 100},
+			}},
+			{Tool: "network.fetch", Weight: 2, Params: map[string]paramSpec{
+				"url":    {Values: []interface{}{"https://api.github.com/repos/{n}", "https://evil.example.com/{n}"}, Cardinality: 100},
+				"method": {Values: []interface{}{"GET"}},
+			}},
+		},
+	}
+}
+
+// loadWorkload reads a workload file (YAML or JSON).
+func loadWorkload(path string) (*workload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var w workload
+	if err := yaml.UnmarshalStrict(data, &w); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &w, nil
+}
+
+// parseAgents parses an agent mix flag, "TYPE[=WEIGHT],...".
+func parseAgents(s string) ([]agentMix, error) {
+	var agents []agentMix
+	for _, field := range strings.Split(s, ",") {
+		agentType, weight, hasWeight := strings.Cut(strings.TrimSpace(field), "=")
+		if agentType == "" {
+			continue
+		}
+		a := agentMix{AgentType: agentType, Weight: 1}
+		if hasWeight {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for agent type %s", weight, agentType)
+			}
+			a.Weight = w
+		}
+		agents = append(agents, a)
+	}
+	return agents, nil
+}
+
+// validate checks the workload can generate calls.
+func (w *workload) validate() error {
+	if len(w.Agents) == 0 {
+		return fmt.Errorf("workload has no agents")
+	}
+	if len(w.Calls) == 0 {
+		return fmt.Errorf("workload has no calls")
+	}
+	for _, a := range w.Agents {
+		if a.AgentType == "" {
+			return fmt.Errorf("workload agent without agentType")
+		}
+	}
+	for _, c := range w.Calls {
+		if c.Tool == "" {
+			return fmt.Errorf("workload call without tool")
+		}
+		for name, p := range c.Params {
+			if len(p.Values) == 0 {
+				return fmt.Errorf("%s parameter %s has no values", c.Tool, name)
+			}
+		}
+	}
+	return nil
+}
+
+// sample picks the agent and call of one request and builds it.
+func (w *workload) sample(rng *rand.Rand) (*agentpb.ExecuteRequest, error) {
+	agent := w.Agents[pick(rng, len(w.Agents), func(i int) int { return w.Agents[i].Weight })]
+	call := w.Calls[pick(rng, len(w.Calls), func(i int) int { return w.Calls[i].Weight })]
+
+	params := make(map[string]interface{}, len(call.Params))
+	for name, p := range call.Params {
+		params[name] = p.sample(rng)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s parameters: %w", call.Tool, err)
+	}
+
+	return &agentpb.ExecuteRequest{
+		ToolName:   call.Tool,
+		Parameters: data,
+		Metadata: &agentpb.RequestMetadata{
+			AgentType: agent.AgentType,
+			TenantId:  agent.TenantID,
+			MtsLabel:  agent.MTSLabel,
+			Labels:    agent.Labels,
+			SandboxId: fmt.Sprintf("loadgen-%d", rng.Intn(100)),
+		},
+	}, nil
+}
+
+// sample picks a value of the parameter.
+func (p paramSpec) sample(rng *rand.Rand) interface{} {
+	v := p.Values[rng.Intn(len(p.Values))]
+	s, ok := v.(string)
+	if !ok || !strings.Contains(s, "{n}") {
+		return v
+	}
+	cardinality := p.Cardinality
+	if cardinality <= 0 {
+		cardinality = 1000
+	}
+	return strings.ReplaceAll(s, "{n}", strconv.Itoa(rng.Intn(cardinality)))
+}
+
+// pick returns an index in [0, n) with probability proportional to its
+// weight; unset weights count as 1.
+func pick(rng *rand.Rand, n int, weight func(int) int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += max(weight(i), 1)
+	}
+	r := rng.Intn(total)
+	for i := 0; i < n; i++ {
+		if r -= max(weight(i), 1); r < 0 {
+			return i
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/golden-agent/golden-agent/pkg/controller"
+	"github.com/golden-agent/golden-agent/pkg/gitops"
+	"github.com/golden-agent/golden-agent/pkg/offline"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/redact"
+	"github.com/golden-agent/golden-agent/pkg/router"
+)
+
+// recentDenials is the number of recent denials the inspection UI lists.
+const recentDenials = 100
+
+// config is the resolved configuration of the router process.
+type config struct {
+	server router.ServerConfig
+
+	listen       string
+	unixSocket   string
+	healthAddr   string
+	dataAPIAddr  string
+	diagAddr     string
+	drainTimeout time.Duration
+
+	tlsCert     string
+	tlsKey      string
+	tlsClientCA string
+
+	auditSink        string
+	auditFile        string
+	auditFormat      string
+	auditOnlyDenials bool
+	auditCoalesce    time.Duration
+	auditHMACKey     []byte
+
+	recordFile       string
+	recordRedactKeys []string
+
+	// credentials issues the credentials of injectCredential obligations,
+	// if configured
+	credentials router.CredentialBroker
+
+	// redact configures the redaction of audit parameters, responses, and
+	// recordings
+	redact redact.Config
+}
+
+// configFromViper validates the settings and builds the server
+// configuration. TLS material, audit sinks, and recordings are opened by
+// run.
+func configFromViper(v *viper.Viper) (*config, error) {
+	c := &config{
+		server:           router.DefaultServerConfig(),
+		listen:           v.GetString("listen"),
+		unixSocket:       v.GetString("unix-socket"),
+		healthAddr:       v.GetString("health-addr"),
+		dataAPIAddr:      v.GetString("data-api-addr"),
+		diagAddr:         v.GetString("diagnostics-addr"),
+		drainTimeout:     v.GetDuration("drain-timeout"),
+		tlsCert:          v.GetString("tls-cert"),
+		tlsKey:           v.GetString("tls-key"),
+		tlsClientCA:      v.GetString("tls-client-ca"),
+		auditSink:        v.GetString("audit-sink"),
+		auditFile:        v.GetString("audit-file"),
+		auditFormat:      v.GetString("audit-format"),
+		auditOnlyDenials: v.GetBool("audit-only-denials"),
+		auditCoalesce:    v.GetDuration("audit-coalesce-window"),
+		recordFile:       v.GetString("record"),
+		recordRedactKeys: v.GetStringSlice("record-redact-keys"),
+	}
+	if c.listen == "" && c.unixSocket == "" {
+		return nil, fmt.Errorf("one of --listen or --unix-socket is required")
+	}
+	if (c.tlsCert == "") != (c.tlsKey == "") {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+	if c.tlsClientCA != "" && c.tlsCert == "" {
+		return nil, fmt.Errorf("--tls-client-ca requires --tls-cert and --tls-key")
+	}
+
+	pc := &c.server.PolicyConfig
+	logger, err := newLogger(v.GetString("log-level"), v.GetString("log-format"))
+	if err != nil {
+		return nil, err
+	}
+	pc.Logger = logger
+
+	switch mode := v.GetString("mode"); mode {
+	case "permissive":
+		pc.Mode = policy.Permissive
+	case "enforcing":
+		pc.Mode = policy.Enforcing
+	default:
+		return nil, fmt.Errorf("invalid --mode %q: must be permissive or enforcing", mode)
+	}
+	pc.CacheTTL = v.GetDuration("cache-ttl")
+	pc.EvaluationTimeout = v.GetDuration("evaluation-timeout")
+	pc.LatencyGuard.Threshold = v.GetDuration("latency-slo")
+	pc.LatencyGuard.Cooldown = v.GetDuration("latency-cooldown")
+	if pc.LatencyGuard.Fallback, err = policy.ParseLatencyFallback(v.GetString("latency-fallback")); err != nil {
+		return nil, fmt.Errorf("invalid --latency-fallback: %w", err)
+	}
+	if pc.CombiningAlgorithm, err = policy.ParseCombiningAlgorithm(v.GetString("policy-combining")); err != nil {
+		return nil, fmt.Errorf("invalid --policy-combining: %w", err)
+	}
+	if pc.ToolNameRule, err = policy.ParseToolNameRule(v.GetString("tool-name-normalization")); err != nil {
+		return nil, fmt.Errorf("invalid --tool-name-normalization: %w", err)
+	}
+	pc.ToolAliases = v.GetBool("tool-aliases")
+	if v.GetBool("tool-catalog") {
+		pc.ToolCatalog = policy.NewToolCatalog()
+	}
+	pc.KillSwitches = v.GetBool("kill-switches")
+	for _, s := range v.GetStringSlice("risk-rules") {
+		rule, err := policy.ParseRiskRule(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --risk-rules: %w", err)
+		}
+		pc.RiskRules = append(pc.RiskRules, rule)
+	}
+	pc.WebhookPort = v.GetInt("webhook-port")
+	pc.WebhookCertDir = v.GetString("webhook-cert-dir")
+	pc.UseOPA = v.GetBool("opa")
+	pc.OPAMemoTTL = v.GetDuration("opa-memo-ttl")
+	pc.PartialEval = v.GetBool("opa-partial-eval")
+	pc.OPAMetrics = v.GetBool("opa-metrics")
+	pc.TraceAgentTypes = v.GetStringSlice("trace-agent-types")
+	pc.EnableController = v.GetBool("controller")
+	pc.RenderRego = v.GetBool("render-rego")
+	pc.ValuesFrom = v.GetBool("values-from")
+	pc.ReconcileOptions = controller.ReconcileOptions{
+		MaxConcurrentReconciles: v.GetInt("controller-concurrency"),
+		BaseDelay:               v.GetDuration("controller-backoff-base"),
+		MaxDelay:                v.GetDuration("controller-backoff-max"),
+		QPS:                     v.GetFloat64("controller-qps"),
+		Burst:                   v.GetInt("controller-burst"),
+	}
+	pc.SnapshotFrom = v.GetString("snapshot-from")
+	pc.MetricsAddr = v.GetString("metrics-addr")
+	pc.HealthProbeAddr = c.healthAddr
+	pc.InvalidationConfigMap = v.GetString("invalidation-configmap")
+	pc.Heartbeat = v.GetString("heartbeat")
+	pc.ReplicaIdentity = v.GetString("replica-identity")
+	pc.SandboxClaims = v.GetBool("sandbox-claims")
+	pc.Tenants = v.GetBool("tenants")
+	pc.TenantConfigs = v.GetBool("tenant-configs")
+	pc.TenantPartitions = v.GetBool("tenant-partitions")
+	pc.MaxTenants = v.GetInt("max-tenants")
+	pc.MTSAllocations = v.GetString("mts-allocations")
+	pc.NodeName = v.GetString("node-name")
+	pc.AuditParameters = v.GetBool("audit-parameters")
+	if c.diagAddr != "" {
+		pc.RecentDenials = recentDenials
+	}
+	if err := c.configureRedaction(v); err != nil {
+		return nil, err
+	}
+	if err := c.configureExternalAuthorizer(v); err != nil {
+		return nil, err
+	}
+	if err := c.configureCredentials(v); err != nil {
+		return nil, err
+	}
+	pc.Environment = policy.Environment{
+		Cluster:     v.GetString("cluster-name"),
+		Environment: v.GetString("environment"),
+		Zone:        v.GetString("node-zone"),
+	}
+	if path := v.GetString("geoip-file"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --geoip-file: %w", err)
+		}
+		provider, err := policy.LoadCIDRGeoProvider(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid --geoip-file: %w", err)
+		}
+		pc.EnrichmentProvider = provider
+	}
+	if err := c.configureContextEnrichers(v); err != nil {
+		return nil, err
+	}
+	if err := c.configureGitOps(v); err != nil {
+		return nil, err
+	}
+	if err := c.configureOffline(v); err != nil {
+		return nil, err
+	}
+	if pc.OPAMemoTTL > 0 && !pc.UseOPA {
+		return nil, fmt.Errorf("--opa-memo-ttl requires --opa")
+	}
+	if pc.PartialEval && !pc.UseOPA {
+		return nil, fmt.Errorf("--opa-partial-eval requires --opa")
+	}
+	if pc.OPAMetrics && !pc.UseOPA {
+		return nil, fmt.Errorf("--opa-metrics requires --opa")
+	}
+	if pc.InvalidationConfigMap != "" && !pc.EnableController {
+		return nil, fmt.Errorf("--invalidation-configmap requires --controller")
+	}
+	if pc.Heartbeat != "" && !pc.EnableController {
+		return nil, fmt.Errorf("--heartbeat requires --controller")
+	}
+	if pc.SandboxClaims && !pc.EnableController {
+		return nil, fmt.Errorf("--sandbox-claims requires --controller")
+	}
+	if pc.MTSAllocations != "" && !pc.SandboxClaims {
+		return nil, fmt.Errorf("--mts-allocations requires --sandbox-claims")
+	}
+	if pc.NodeName != "" && !pc.SandboxClaims {
+		return nil, fmt.Errorf("--node-name requires --sandbox-claims")
+	}
+	if pc.NodeName != "" && pc.Heartbeat != "" {
+		// Node-local routers load different policies, so none would
+		// converge on every replica
+		return nil, fmt.Errorf("--node-name cannot be used with --heartbeat")
+	}
+	if pc.Tenants && !pc.EnableController {
+		return nil, fmt.Errorf("--tenants requires --controller")
+	}
+	if pc.TenantConfigs && !pc.EnableController {
+		return nil, fmt.Errorf("--tenant-configs requires --controller")
+	}
+	if pc.ToolAliases && !pc.EnableController {
+		return nil, fmt.Errorf("--tool-aliases requires --controller")
+	}
+	if pc.ToolCatalog != nil && !pc.EnableController {
+		return nil, fmt.Errorf("--tool-catalog requires --controller")
+	}
+	if pc.KillSwitches && !pc.EnableController {
+		return nil, fmt.Errorf("--kill-switches requires --controller")
+	}
+	if pc.ReconcileOptions != (controller.ReconcileOptions{}) && !pc.EnableController {
+		return nil, fmt.Errorf("--controller-concurrency, --controller-backoff-*, --controller-qps, and --controller-burst require --controller")
+	}
+	if pc.RenderRego && (!pc.EnableController || !pc.UseOPA) {
+		return nil, fmt.Errorf("--render-rego requires --controller and --opa")
+	}
+	if pc.ValuesFrom && !pc.EnableController {
+		return nil, fmt.Errorf("--values-from requires --controller")
+	}
+	if ro := pc.ReconcileOptions; ro.MaxDelay > 0 && ro.MaxDelay < ro.BaseDelay {
+		return nil, fmt.Errorf("--controller-backoff-max must be at least --controller-backoff-base")
+	}
+	if pc.WebhookPort > 0 && pc.ToolCatalog == nil {
+		return nil, fmt.Errorf("--webhook-port requires --tool-catalog")
+	}
+	switch labels := v.GetString("tenant-labels"); labels {
+	case "":
+		if pc.Tenants {
+			pc.TenantRegistry = policy.NewTenantRegistry(policy.LabelMismatchFlag)
+		}
+	case "flag", "deny":
+		if !pc.SandboxClaims && !pc.Tenants {
+			return nil, fmt.Errorf("--tenant-labels requires --sandbox-claims or --tenants")
+		}
+		action := policy.LabelMismatchFlag
+		if labels == "deny" {
+			action = policy.LabelMismatchDeny
+		}
+		pc.TenantRegistry = policy.NewTenantRegistry(action)
+	default:
+		return nil, fmt.Errorf("invalid --tenant-labels %q: must be flag or deny", labels)
+	}
+	if v.GetBool("token-review") {
+		if !pc.EnableController {
+			return nil, fmt.Errorf("--token-review requires --controller")
+		}
+		pc.TokenReview = &controller.TokenReviewConfig{
+			Audiences:           v.GetStringSlice("token-audiences"),
+			SubjectAccessReview: v.GetBool("token-review-sar"),
+			CacheTTL:            v.GetDuration("token-review-cache-ttl"),
+		}
+	}
+
+	switch c.auditSink {
+	case "stdout", "json", "none":
+	case "file":
+		if c.auditFile == "" {
+			return nil, fmt.Errorf("--audit-sink=file requires --audit-file")
+		}
+	default:
+		return nil, fmt.Errorf("invalid --audit-sink %q: must be stdout, json, file, or none", c.auditSink)
+	}
+	switch c.auditFormat {
+	case policy.AuditFormatJSON, policy.AuditFormatCloudEvents:
+	case policy.AuditFormatAVC:
+		if c.auditSink == "json" {
+			return nil, fmt.Errorf("--audit-format=avc requires --audit-sink=file")
+		}
+	default:
+		return nil, fmt.Errorf("invalid --audit-format %q: must be json, cloudevents, or avc", c.auditFormat)
+	}
+	pc.AuditEnabled = c.auditSink != "none"
+	if path := v.GetString("audit-hmac-key-file"); path != "" {
+		if (c.auditSink != "json" && c.auditSink != "file") || c.auditFormat == policy.AuditFormatAVC {
+			return nil, fmt.Errorf("--audit-hmac-key-file requires --audit-sink=json or file with a JSON --audit-format")
+		}
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit HMAC key: %w", err)
+		}
+		c.auditHMACKey = bytes.TrimSpace(key)
+	}
+
+	if path := v.GetString("session-key-file"); path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session key: %w", err)
+		}
+		c.server.SessionKey = key
+	}
+	c.server.SessionTTL = v.GetDuration("session-ttl")
+	c.server.RequireSession = v.GetBool("require-session")
+	c.server.PlanTTL = v.GetDuration("plan-ttl")
+
+	c.server.RateLimit = router.RateLimitConfig{
+		RequestsPerSecond: v.GetFloat64("rate-limit"),
+		Burst:             v.GetInt("rate-limit-burst"),
+		Key:               router.RateLimitKey(v.GetString("rate-limit-key")),
+	}
+	if key := c.server.RateLimit.Key; key != router.RateLimitBySandbox && key != router.RateLimitByTenant {
+		return nil, fmt.Errorf("invalid --rate-limit-key %q: must be sandbox or tenant", key)
+	}
+	c.server.RequestLimits = router.RequestLimits{
+		MaxParameterBytes: v.GetInt("max-parameter-bytes"),
+		MaxDepth:          v.GetInt("max-parameter-depth"),
+		MaxKeys:           v.GetInt("max-parameter-keys"),
+	}
+	c.server.CircuitBreaker = router.CircuitBreakerConfig{
+		FailureRate: v.GetFloat64("breaker-failure-rate"),
+		SlowCall:    v.GetDuration("breaker-slow-call"),
+		Window:      v.GetInt("breaker-window"),
+		Cooldown:    v.GetDuration("breaker-cooldown"),
+	}
+	if rate := c.server.CircuitBreaker.FailureRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid --breaker-failure-rate %v: must be between 0 and 1", rate)
+	}
+	c.server.IdempotencyWindow = v.GetDuration("idempotency-window")
+	c.server.OperationTTL = v.GetDuration("operation-ttl")
+	c.server.CallbackHosts = v.GetStringSlice("operation-callback-hosts")
+	return c, nil
+}
+
+// configureRedaction builds the redaction configuration and the redactors
+// of audit parameters and responses.
+func (c *config) configureRedaction(v *viper.Viper) error {
+	c.redact = redact.DefaultConfig()
+	if keys := v.GetStringSlice("redact-keys"); len(keys) > 0 {
+		c.redact.Keys = keys
+	}
+	c.redact.Patterns = append(append([]string(nil), redact.DefaultPatterns...), v.GetStringSlice("redact-patterns")...)
+	c.redact.Allow = append(append([]string(nil), redact.DefaultAllow...), v.GetStringSlice("redact-allow")...)
+	c.redact.MinEntropy = v.GetFloat64("redact-min-entropy")
+	if c.redact.MinEntropy < 0 {
+		return fmt.Errorf("--redact-min-entropy must not be negative")
+	}
+	redactor, err := redact.New(c.redact)
+	if err != nil {
+		return fmt.Errorf("invalid --redact-patterns or --redact-allow: %w", err)
+	}
+
+	if v.GetBool("redact-audit-parameters") {
+		if !c.server.PolicyConfig.AuditParameters {
+			return fmt.Errorf("--redact-audit-parameters requires --audit-parameters")
+		}
+		c.server.PolicyConfig.AuditRedactor = redactor
+	}
+	if v.GetBool("redact-responses") {
+		c.server.ResponseRedactor = redactor
+	}
+	return nil
+}
+
+// configureContextEnrichers sets up the context enrichers, in the order
+// they are listed.
+func (c *config) configureContextEnrichers(v *viper.Viper) error {
+	pc := &c.server.PolicyConfig
+	for _, name := range v.GetStringSlice("context-enrichers") {
+		switch name {
+		case "pod-labels":
+			enricher, err := router.NewPodLabelsEnricher(v.GetString("pod-labels-file"))
+			if err != nil {
+				return fmt.Errorf("invalid --pod-labels-file: %w", err)
+			}
+			pc.ContextEnrichers = append(pc.ContextEnrichers, enricher)
+		case "spiffe":
+			if c.tlsClientCA == "" {
+				return fmt.Errorf("--context-enrichers=spiffe requires --tls-client-ca")
+			}
+			pc.ContextEnrichers = append(pc.ContextEnrichers, router.SPIFFEEnricher{})
+		case "geoip":
+			if pc.EnrichmentProvider == nil {
+				return fmt.Errorf("--context-enrichers=geoip requires --geoip-file")
+			}
+			pc.ContextEnrichers = append(pc.ContextEnrichers, router.GeoIPEnricher{Provider: pc.EnrichmentProvider})
+		default:
+			return fmt.Errorf("invalid --context-enrichers entry %q: must be pod-labels, spiffe, or geoip", name)
+		}
+	}
+	return nil
+}
+
+// configureExternalAuthorizer sets up the client of the external
+// authorizer, if any. gRPC connections are established lazily.
+func (c *config) configureExternalAuthorizer(v *viper.Viper) error {
+	target := v.GetString("external-authorizer")
+	if target == "" {
+		return nil
+	}
+	pc := &c.server.PolicyConfig
+	combination, err := policy.ParseExternalCombination(v.GetString("external-combination"))
+	if err != nil {
+		return fmt.Errorf("invalid --external-combination: %w", err)
+	}
+	pc.ExternalCombination = combination
+	pc.ExternalTimeout = v.GetDuration("external-timeout")
+	if pc.ExternalTimeout <= 0 {
+		return fmt.Errorf("--external-timeout must be positive")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid --external-authorizer: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		pc.ExternalAuthorizer = router.NewHTTPAuthorizer(target, nil)
+	case "grpc", "grpcs":
+		creds := insecure.NewCredentials()
+		if u.Scheme == "grpcs" {
+			creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		conn, err := grpc.Dial(u.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return fmt.Errorf("invalid --external-authorizer: %w", err)
+		}
+		pc.ExternalAuthorizer = router.NewGRPCAuthorizer(conn, v.GetString("external-authorizer-method"))
+	default:
+		return fmt.Errorf("invalid --external-authorizer %q: must be an http, https, grpc, or grpcs URL", target)
+	}
+	return nil
+}
+
+// configureGitOps sets up the Git policy source, if any.
+func (c *config) configureGitOps(v *viper.Viper) error {
+	repo := v.GetString("git-repo")
+	if repo == "" {
+		return nil
+	}
+	pc := &c.server.PolicyConfig
+	pc.GitOps = &gitops.Config{
+		Repository:         repo,
+		Ref:                v.GetString("git-ref"),
+		Commit:             v.GetString("git-commit"),
+		Path:               v.GetString("git-path"),
+		Namespace:          v.GetString("git-namespace"),
+		PollInterval:       v.GetDuration("git-poll-interval"),
+		RequireSignature:   v.GetBool("git-require-signature"),
+		AllowedSignersFile: v.GetString("git-allowed-signers"),
+		Prune:              v.GetBool("git-prune"),
+		WebhookAddr:        v.GetString("git-webhook-addr"),
+		CacheDir:           v.GetString("git-cache-dir"),
+	}
+	pc.GitOpsApply = v.GetBool("git-apply")
+	if pc.GitOpsApply && !pc.EnableController {
+		return fmt.Errorf("--git-apply requires --controller")
+	}
+	if path := v.GetString("git-webhook-secret-file"); path != "" {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read --git-webhook-secret-file: %w", err)
+		}
+		pc.GitOps.WebhookSecret = bytes.TrimSpace(secret)
+	}
+	return nil
+}
+
+// configureOffline sets up offline operation, if --offline-bundle is set.
+func (c *config) configureOffline(v *viper.Viper) error {
+	bundle := v.GetString("offline-bundle")
+	if bundle == "" {
+		for _, flag := range []string{"offline-spool-dir", "offline-forward-url"} {
+			if v.GetString(flag) != "" {
+				return fmt.Errorf("--%s requires --offline-bundle", flag)
+			}
+		}
+		return nil
+	}
+	pc := &c.server.PolicyConfig
+	if pc.EnableController {
+		return fmt.Errorf("--offline-bundle does not use the Kubernetes API; it is incompatible with --controller")
+	}
+	if pc.GitOps != nil {
+		return fmt.Errorf("--offline-bundle is incompatible with --git-repo")
+	}
+	keyFile := v.GetString("offline-bundle-key")
+	if keyFile == "" {
+		return fmt.Errorf("--offline-bundle requires --offline-bundle-key")
+	}
+	pem, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read --offline-bundle-key: %w", err)
+	}
+	key, err := offline.ParsePublicKey(pem)
+	if err != nil {
+		return fmt.Errorf("invalid --offline-bundle-key: %w", err)
+	}
+	pc.Offline = &router.OfflineConfig{
+		Bundle:          bundle,
+		PublicKey:       key,
+		Namespace:       v.GetString("offline-namespace"),
+		ReloadInterval:  v.GetDuration("offline-reload-interval"),
+		SpoolDir:        v.GetString("offline-spool-dir"),
+		MaxSpoolBytes:   v.GetInt64("offline-spool-max-bytes"),
+		ForwardURL:      v.GetString("offline-forward-url"),
+		ForwardInterval: v.GetDuration("offline-forward-interval"),
+	}
+	if pc.Offline.ForwardURL != "" && pc.Offline.SpoolDir == "" {
+		return fmt.Errorf("--offline-forward-url requires --offline-spool-dir")
+	}
+	return nil
+}
+
+// configureCredentials sets up the credential broker of injectCredential
+// obligations.
+func (c *config) configureCredentials(v *viper.Viper) error {
+	switch broker := v.GetString("credential-broker"); broker {
+	case "":
+	case "vault":
+		addr := v.GetString("vault-addr")
+		if addr == "" {
+			return fmt.Errorf("--credential-broker=vault requires --vault-addr")
+		}
+		if v.GetString("vault-token-file") == "" && v.GetString("vault-auth-role") == "" {
+			return fmt.Errorf("--credential-broker=vault requires --vault-token-file or --vault-auth-role")
+		}
+		c.credentials = router.NewVaultBroker(router.VaultConfig{
+			Address:   addr,
+			Namespace: v.GetString("vault-namespace"),
+			TokenFile: v.GetString("vault-token-file"),
+			AuthMount: v.GetString("vault-auth-mount"),
+			AuthRole:  v.GetString("vault-auth-role"),
+		}, &http.Client{Timeout: 10 * time.Second})
+	case "files":
+		dir := v.GetString("credentials-dir")
+		if dir == "" {
+			return fmt.Errorf("--credential-broker=files requires --credentials-dir")
+		}
+		c.credentials = router.NewFileBroker(dir)
+	default:
+		return fmt.Errorf("invalid --credential-broker %q: must be vault or files", broker)
+	}
+	return nil
+}
+
+// newRecorder returns the recorder of calls to sink, which redacts the
+// values of --record-redact-keys, or else of --redact-keys.
+func (c *config) newRecorder(sink router.RecordSink) (*router.Recorder, error) {
+	cfg := c.redact
+	if len(c.recordRedactKeys) > 0 {
+		cfg.Keys = c.recordRedactKeys
+	}
+	redactor, err := redact.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return router.NewRedactingRecorder(sink, redactor), nil
+}
+
+// newLogger builds the process logger, which writes to stderr so that it
+// does not mix with the stdout audit sink.
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid --log-level %q: must be debug, info, warn, or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q: must be text or json", format)
+	}
+}
+
+// openAuditSink opens the configured audit sink. The returned function
+// closes it.
+func (c *config) openAuditSink() (policy.AuditSink, func() error, error) {
+	noop := func() error { return nil }
+	switch c.auditSink {
+	case "stdout":
+		sink := policy.NewStdoutAuditSink(c.auditOnlyDenials)
+		sink.CoalesceDenials(c.auditCoalesce)
+		return sink, sink.Close, nil
+	case "json":
+		sink := policy.NewJSONAuditSink(os.Stdout, c.auditOnlyDenials)
+		sink.Format = c.auditFormat
+		if c.auditHMACKey != nil {
+			sink.Chain = policy.NewAuditChain(c.auditHMACKey)
+		}
+		return sink, noop, nil
+	case "file":
+		sink, err := policy.NewFileAuditSink(c.auditFile, c.auditFormat, c.auditOnlyDenials)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink.CoalesceDenials(c.auditCoalesce)
+		if c.auditHMACKey != nil {
+			if err := sink.SetChain(policy.NewAuditChain(c.auditHMACKey)); err != nil {
+				sink.Close()
+				return nil, nil, err
+			}
+		}
+		return sink, sink.Close, nil
+	default:
+		return nil, noop, nil
+	}
+}
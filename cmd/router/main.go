@@ -0,0 +1,249 @@
+// Command router runs the agent tool router: the AgentService gRPC server
+// with its embedded policy engine, the AgentPolicy controller, and audit
+// sinks.
+//
+// Usage:
+//
+//	router [--config router.yaml] [flags]
+//
+// Every flag can also be set in the config file (YAML, JSON, or TOML,
+// keyed by flag name) or in the environment as GOLDEN_AGENT_<FLAG>, with
+// dashes as underscores (e.g. GOLDEN_AGENT_MODE=enforcing). Flags take
+// precedence over the environment, and the environment over the file.
+//
+// On SIGTERM or SIGINT the router drains: it stops accepting calls, waits
+// up to --drain-timeout for in-flight ones, flushes audit sinks, and exits.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/golden-agent/golden-agent/pkg/offline"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// envPrefix is the prefix of environment variables that set flags.
+const envPrefix = "GOLDEN_AGENT"
+
+func main() {
+	if err := newCommand().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "router: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newCommand builds the router command, with viper bound to its flags.
+func newCommand() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:           "router",
+		Short:         "Agent tool router with embedded policy enforcement",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(v, cmd); err != nil {
+				return err
+			}
+			config, err := configFromViper(v)
+			if err != nil {
+				return err
+			}
+			return run(cmd.Context(), config)
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("config", "", "config file (YAML, JSON, or TOML)")
+
+	// Listeners
+	f.String("listen", ":50051", "gRPC listen address (empty to disable TCP)")
+	f.String("unix-socket", "", "also serve on this Unix socket, for sidecar agents")
+	f.String("health-addr", ":8081", "health probe address (/healthz, /readyz)")
+	f.String("metrics-addr", ":8080", "metrics address (with --controller)")
+	f.String("data-api-addr", "", "serve decisions through OPA's REST data API (POST /v1/data/agentpolicy/decision) on this address, for trusted clients")
+	f.String("diagnostics-addr", "", "serve a read-only web UI of the loaded policies, cache, recent denials, and a decision simulator on this address, for operators")
+	f.Duration("drain-timeout", 25*time.Second, "how long to wait for in-flight calls on shutdown")
+
+	// Logging
+	f.String("log-level", "info", "log level: debug (includes every decision), info, warn, or error")
+	f.String("log-format", "text", "log format on stderr: text or json")
+
+	// TLS
+	f.String("tls-cert", "", "TLS certificate file; enables TLS on --listen")
+	f.String("tls-key", "", "TLS private key file")
+	f.String("tls-client-ca", "", "CA bundle for verifying client certificates; enables mTLS")
+
+	// Policy
+	f.String("mode", "permissive", "enforcement mode: permissive or enforcing")
+	f.Duration("cache-ttl", 60*time.Second, "decision cache TTL (0 to disable)")
+	f.Duration("evaluation-timeout", 0, "bound on each policy evaluation (0 for only the caller's deadline)")
+	f.Duration("latency-slo", 0, "p99 evaluation latency past which evaluation degrades to --latency-fallback (0 to disable)")
+	f.String("latency-fallback", "legacy", "what evaluation degrades to past --latency-slo: legacy (tool tables instead of OPA) or cached-only (deny cache misses)")
+	f.Duration("latency-cooldown", time.Minute, "how long evaluation stays degraded before it is measured afresh")
+	f.String("policy-combining", "first-applicable", "how the policies that apply to an agent combine: first-applicable (the most specific decides), deny-overrides, or priority")
+	f.String("tool-name-normalization", "convert", "how called tool names are normalized to those of policies: convert (CamelCase and snake_case to dotted), lowercase, or exact")
+	f.Bool("tool-aliases", false, "normalize the raw tool names of ToolAliases to their tools before --tool-name-normalization applies")
+	f.Bool("tool-catalog", false, "load the tools of ToolCatalogs and describe them to agents discovering their allowed tools")
+	f.Bool("kill-switches", false, "deny every call to the tools disabled by ToolKillSwitches, whatever the policies and mode")
+	f.StringSlice("risk-rules", nil, "act on the calls of every policy by the risk level of their tool, e.g. tier>=high:approval,tier==critical:approval+audit-parameters")
+	f.Int("webhook-port", 0, "with --tool-catalog, serve the AgentPolicy validating webhook, which warns of undeclared tools, on this port (0 to disable)")
+	f.String("webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "directory of the webhook's tls.crt and tls.key")
+	f.Bool("opa", false, "evaluate policies with OPA")
+	f.Bool("opa-partial-eval", false, "specialize OPA queries for each agent type when policies load")
+	f.StringSlice("trace-agent-types", nil, "agent types (or patterns) whose calls may ask for the OPA trace of their evaluation (RequestMetadata.trace)")
+	f.Bool("opa-metrics", false, "collect OPA evaluation metrics per policy rule (slows evaluation)")
+	f.Duration("opa-memo-ttl", 0, "reuse OPA results of exactly repeated calls the decision cache cannot hold for this long (0 to disable)")
+	f.Bool("controller", true, "sync AgentPolicy resources from the cluster")
+	f.Bool("values-from", false, "read the constraint values AgentPolicies take from ConfigMaps and Secrets (valuesFrom); requires get, list, and watch on both")
+	f.Bool("render-rego", false, "write the Rego generated for each AgentPolicy to the ConfigMap NAME-rego in its namespace, for inspection (with --opa)")
+	f.Int("controller-concurrency", 0, "AgentPolicies reconciled at once (default 1)")
+	f.Duration("controller-backoff-base", 0, "first retry delay of a failed AgentPolicy reconcile, doubled on each failure (default 5ms)")
+	f.Duration("controller-backoff-max", 0, "longest retry delay of a failed AgentPolicy reconcile (default 1000s)")
+	f.Float64("controller-qps", 0, "AgentPolicy reconciles per second, to spread the compiles of a restart (default 10)")
+	f.Int("controller-burst", 0, "AgentPolicy reconciles allowed in a burst above --controller-qps (default 100)")
+	f.String("snapshot-from", "", "before serving, restore the policies, kill switches, tenant modes, and cached decisions of a running router from its /snapshot diagnostics URL, or from a snapshot file, for blue/green deploys")
+	f.String("invalidation-configmap", "", "namespace/name of the ConfigMap that broadcasts cache invalidations between replicas")
+	f.String("heartbeat", "", "namespace/name of the Leases through which replicas report the policies they loaded")
+	f.String("replica-identity", "", "unique name of this replica's heartbeat Lease (default: hostname)")
+	f.Bool("sandbox-claims", false, "cross-check the tenant, MTS label, and policy of calls against the SandboxClaim of their sandbox")
+	f.String("tenant-labels", "", "with --sandbox-claims or --tenants, evaluate calls with the MTS label of their tenant, and flag or deny claimed labels that differ: flag or deny")
+	f.String("node-name", "", "with --sandbox-claims, run node-local: load only the SandboxClaims scheduled on this node and the AgentPolicies they reference (set from the downward API)")
+	f.String("mts-allocations", "", "with --sandbox-claims, allocate the tenants of claims whose policy sets no MTS label a label of their own, persisted in this ConfigMap (namespace/name)")
+	f.Bool("tenants", false, "allocate Tenants exclusive MTS categories and evaluate calls with the label of their tenant in the tenant hierarchy (default --tenant-labels=flag)")
+	f.Bool("tenant-configs", false, "evaluate the calls of tenants with a TenantConfig in its enforcement mode instead of --mode")
+	f.Bool("tenant-partitions", false, "evaluate the calls of each tenant with a decision cache of its own")
+	f.Int("max-tenants", policy.DefaultMaxTenants, "cap on the tenant partitions of --tenant-partitions; later tenants share the default partition (-1 for no cap)")
+
+	// External authorizer
+	f.String("external-authorizer", "", "consult this authorizer after local evaluation: an http(s):// URL taking JSON, or grpc://host:port or grpcs://host:port")
+	f.String("external-authorizer-method", "", "gRPC method of a grpc:// or grpcs:// external authorizer (default: /agents.sandbox.v1alpha1.ExternalAuthorizer/Authorize)")
+	f.String("external-combination", "deny-overrides", "how external decisions combine with local ones: deny-overrides, local-first, or external-only")
+	f.Duration("external-timeout", 500*time.Millisecond, "bound on external authorizer calls, which deny when exceeded")
+
+	// Conditions
+	f.String("cluster-name", "", "name of the cluster the router runs in, for the cluster conditions of tool rules")
+	f.String("environment", "", "environment the router runs in (e.g., production), for the environment conditions of tool rules")
+	f.String("node-zone", "", "zone of the node the router runs on (e.g., eu-west-1a), for the zone conditions of tool rules")
+	f.String("geoip-file", "", "CSV of network,country,continent,asn lines to look up destinations in, for the destination conditions of tool rules")
+
+	// Context enrichment
+	f.StringSlice("context-enrichers", nil, "enrich the agent context of every call, in order, with: pod-labels (the router pod's labels), spiffe (the client certificate's SPIFFE ID), geoip (the caller's address in --geoip-file)")
+	f.String("pod-labels-file", "/etc/podinfo/labels", "downward API file of the router pod's labels, for the pod-labels context enricher")
+
+	// Git policy source
+	f.String("git-repo", "", "sync AgentPolicy manifests from this Git repository URL or path")
+	f.String("git-ref", "HEAD", "branch or tag of --git-repo to sync")
+	f.String("git-commit", "", "only sync this commit of --git-repo")
+	f.String("git-path", "", "directory of --git-repo to read manifests from (default: the whole repository)")
+	f.String("git-namespace", "default", "namespace of manifests in --git-repo without one")
+	f.Duration("git-poll-interval", time.Minute, "how often to fetch --git-repo (0 to only sync at start and on webhooks)")
+	f.Bool("git-require-signature", false, "refuse commits of --git-repo without a valid signature")
+	f.String("git-allowed-signers", "", "allowed signers file SSH commit signatures are verified against (implies --git-require-signature)")
+	f.Bool("git-prune", true, "remove policies deleted from --git-repo")
+	f.Bool("git-apply", false, "apply the policies of --git-repo to the cluster instead of loading them directly (with --controller)")
+	f.String("git-webhook-addr", "", "serve push webhooks that trigger a sync of --git-repo on this address")
+	f.String("git-webhook-secret-file", "", "secret push webhooks are authenticated with (GitHub HMAC or GitLab token)")
+	f.String("git-cache-dir", "", "directory --git-repo is fetched to (default: under the temporary directory)")
+
+	// Offline operation
+	f.String("offline-bundle", "", "run offline: load policies from this signed bundle (see apctl bundle) instead of the Kubernetes API")
+	f.String("offline-bundle-key", "", "PEM Ed25519 public key the signature of --offline-bundle is verified with")
+	f.String("offline-namespace", "default", "namespace of the policies of --offline-bundle without one")
+	f.Duration("offline-reload-interval", 30*time.Second, "how often --offline-bundle is checked for changes (0 to only load it at start)")
+	f.String("offline-spool-dir", "", "spool audit events to this directory until they are forwarded (with --offline-bundle)")
+	f.Int64("offline-spool-max-bytes", offline.DefaultMaxSpoolBytes, "bound on --offline-spool-dir, past which audit events are dropped")
+	f.String("offline-forward-url", "", "POST spooled audit events to this URL as NDJSON when it is reachable")
+	f.Duration("offline-forward-interval", 30*time.Second, "how often spooled audit events are forwarded to --offline-forward-url")
+
+	// Audit
+	f.String("audit-sink", "stdout", "audit sink: stdout, json, file, or none")
+	f.String("audit-file", "", "audit log path (with --audit-sink=file)")
+	f.String("audit-format", "json", "audit format of the json and file sinks: json or cloudevents, or avc for files")
+	f.Bool("audit-only-denials", false, "only audit denied calls")
+	f.Duration("audit-coalesce-window", 0, "write identical denials (same agent, tool, and reason) within this window of the first as one line counting them, with the stdout and file sinks (0 to disable)")
+	f.String("audit-hmac-key-file", "", "make json and file audit lines tamper-evident with an HMAC and hash chain keyed by this file (verify with apctl verify-audit)")
+	f.Bool("audit-parameters", false, "record request parameters in audit events")
+
+	// Redaction
+	f.Bool("redact-audit-parameters", false, "redact PII and secrets from the request parameters of audit events (with --audit-parameters)")
+	f.Bool("redact-responses", false, "redact PII and secrets from tool results returned to agents")
+	f.StringSlice("redact-keys", nil, "keys whose values are redacted from audit parameters, results, and recordings (default: common credential keys)")
+	f.StringSlice("redact-patterns", nil, "regular expressions redacted from audit parameters, results, and recordings, in addition to common PII and credential formats")
+	f.StringSlice("redact-allow", nil, "regular expressions of values never redacted by pattern or entropy, in addition to hashes and UUIDs")
+	f.Float64("redact-min-entropy", 3.5, "entropy in bits per character from which long tokens are redacted as secrets (0 disables)")
+
+	// Credentials
+	f.String("credential-broker", "", "inject the credentials of injectCredential obligations from: vault (--vault-addr) or files (--credentials-dir); empty fails such calls")
+	f.String("vault-addr", "", "Vault address of --credential-broker=vault")
+	f.String("vault-namespace", "", "Vault Enterprise namespace of --credential-broker=vault")
+	f.String("vault-token-file", "", "Vault token file, read for each credential (default: log in with Kubernetes auth)")
+	f.String("vault-auth-mount", "kubernetes", "mount of Vault's Kubernetes auth method")
+	f.String("vault-auth-role", "", "Vault role the router logs in as with Kubernetes auth")
+	f.String("credentials-dir", "", "directory of mounted Secrets (e.g., synced by External Secrets) of --credential-broker=files, one directory per path")
+
+	// Recording
+	f.String("record", "", "append every call and its response to this file, for replay testing")
+	f.StringSlice("record-redact-keys", nil, "parameter and result keys whose values are redacted from recordings (default: --redact-keys)")
+
+	// Sessions
+	f.String("session-key-file", "", "key for signing session tokens; share it between replicas")
+	f.Duration("session-ttl", time.Hour, "default session lifetime")
+	f.Bool("require-session", false, "reject calls without a session token")
+	f.Duration("plan-ttl", 5*time.Minute, "maximum lifetime of PreAuthorize plan tokens")
+
+	// Agent identity
+	f.Bool("token-review", false, "authenticate agents by ServiceAccount token and AgentIdentityBinding (with --controller)")
+	f.StringSlice("token-audiences", nil, "audiences agent tokens must be issued for")
+	f.Bool("token-review-sar", false, "also require RBAC permission to use the AgentIdentityBinding")
+	f.Duration("token-review-cache-ttl", time.Minute, "how long an authenticated token is trusted before it is reviewed again")
+
+	// Request limits
+	f.Float64("rate-limit", 0, "default requests per second per client (0 for no limit)")
+	f.Int("rate-limit-burst", 0, "default burst per client (default: the rate)")
+	f.String("rate-limit-key", "sandbox", "rate limit clients by sandbox or tenant")
+	f.Int("max-parameter-bytes", 1024*1024, "maximum size of call parameters")
+	f.Int("max-parameter-depth", 32, "maximum nesting depth of call parameters")
+	f.Int("max-parameter-keys", 10000, "maximum number of keys in call parameters")
+
+	// Circuit breaking
+	f.Float64("breaker-failure-rate", 0, "share of a tool's recent executions that must fail to open its circuit, which rejects its calls with UNAVAILABLE (0 to disable)")
+	f.Duration("breaker-slow-call", 0, "execution time past which an execution counts as failed (0: only errors)")
+	f.Int("breaker-window", 20, "recent executions of each tool the failure rate is computed over")
+	f.Duration("breaker-cooldown", 30*time.Second, "how long an open circuit rejects calls before probing the tool again")
+
+	// Idempotency
+	f.Duration("idempotency-window", 0, "how long the outcome of an executed call is replayed to calls with its request ID instead of executing them again (0 to disable)")
+
+	// Asynchronous execution
+	f.Duration("operation-ttl", time.Hour, "how long the operations of ExecuteAsync calls are kept once done")
+	f.StringSlice("operation-callback-hosts", nil, "hosts ExecuteAsync calls may have their operations POSTed to when done (none: callbacks disabled)")
+
+	return cmd
+}
+
+// loadConfig binds viper to the command's flags, the environment, and the
+// config file, if any.
+func loadConfig(v *viper.Viper, cmd *cobra.Command) error {
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	if path := v.GetString("config"); path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	return nil
+}
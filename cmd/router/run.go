@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/router"
+)
+
+// run serves the router until it is signalled to stop, then drains it.
+func run(ctx context.Context, c *config) error {
+	slog.SetDefault(c.server.PolicyConfig.Logger)
+
+	sink, closeSink, err := c.openAuditSink()
+	if err != nil {
+		return err
+	}
+	defer closeSink()
+	if sink != nil {
+		c.server.PolicyConfig.AuditSink = sink
+	}
+
+	if c.tlsCert != "" {
+		if c.server.TLS, err = c.loadTLSConfig(); err != nil {
+			return err
+		}
+	}
+
+	server := router.NewServer(c.server)
+	if c.credentials != nil {
+		server.SetObligationHandler(policy.ObligationInjectCredential, router.NewCredentialHandler(c.credentials))
+	}
+
+	if c.recordFile != "" {
+		recording, err := router.NewRecordFile(c.recordFile)
+		if err != nil {
+			return err
+		}
+		defer recording.Close()
+		recorder, err := c.newRecorder(recording)
+		if err != nil {
+			return err
+		}
+		server.SetRecorder(recorder)
+		slog.Info("recording calls", "file", c.recordFile)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Restore the snapshot first, so that the policies the controller and
+	// policy sources sync replace it
+	if err := server.RestoreSnapshot(ctx); err != nil {
+		return err
+	}
+
+	// The controller's manager serves the health probes and metrics;
+	// without it, the router serves the probes itself
+	if c.server.PolicyConfig.EnableController {
+		if err := server.StartController(ctx); err != nil {
+			return err
+		}
+	} else if c.healthAddr != "" {
+		health := newHealthServer(c.healthAddr, server)
+		go func() {
+			if err := health.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("health server stopped", "error", err)
+			}
+		}()
+		defer health.Close()
+	}
+
+	if err := server.StartPolicySource(ctx); err != nil {
+		return err
+	}
+
+	if c.dataAPIAddr != "" {
+		dataAPI := &http.Server{Addr: c.dataAPIAddr, Handler: server.DataAPIHandler(), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := dataAPI.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("data API server stopped", "error", err)
+			}
+		}()
+		defer dataAPI.Close()
+		slog.Info("serving OPA data API", "addr", c.dataAPIAddr)
+	}
+
+	if c.diagAddr != "" {
+		diag := &http.Server{Addr: c.diagAddr, Handler: server.InspectHandler(), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := diag.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("diagnostics server stopped", "error", err)
+			}
+		}()
+		defer diag.Close()
+		slog.Info("serving inspection UI", "addr", c.diagAddr)
+	}
+
+	serveErrs := make(chan error, 2)
+	if c.listen != "" {
+		lis, err := net.Listen("tcp", c.listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", c.listen, err)
+		}
+		slog.Info("serving AgentService", "addr", lis.Addr().String(), "tls", c.server.TLS != nil)
+		go func() { serveErrs <- server.Serve(lis) }()
+	}
+	if c.unixSocket != "" {
+		slog.Info("serving AgentService", "addr", "unix:"+c.unixSocket)
+		go func() { serveErrs <- server.ServeUnix(c.unixSocket) }()
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- server.DrainOnSignal(ctx, c.drainTimeout) }()
+
+	select {
+	case err := <-drained:
+		return err
+	case err := <-serveErrs:
+		// A listener failed; stop the others
+		cancel()
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), c.drainTimeout)
+		defer cancelDrain()
+		server.Drain(drainCtx)
+		return fmt.Errorf("serve failed: %w", err)
+	}
+}
+
+// newHealthServer serves liveness on /healthz and readiness on /readyz,
+// which fails while the router drains.
+func newHealthServer(addr string, server *router.Server) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := server.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves a key pair from disk, reloading it when the
+// certificate file changes, so that rotated certificates (e.g. from
+// cert-manager) are picked up without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// getCertificate implements tls.Config.GetCertificate. If reloading fails,
+// the last good certificate is kept.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.certFile)
+	if err == nil && !info.ModTime().Equal(r.modTime) {
+		if cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile); err == nil {
+			r.cert, r.modTime = &cert, info.ModTime()
+		}
+	}
+	if r.cert == nil {
+		return nil, fmt.Errorf("no TLS certificate loaded from %s", r.certFile)
+	}
+	return r.cert, nil
+}
+
+// loadTLSConfig builds the server TLS configuration. With a client CA,
+// clients must present a certificate it signed.
+func (c *config) loadTLSConfig() (*tls.Config, error) {
+	reloader := &certReloader{certFile: c.tlsCert, keyFile: c.tlsKey}
+	// Fail at startup rather than on the first handshake
+	cert, err := tls.LoadX509KeyPair(c.tlsCert, c.tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	if info, err := os.Stat(c.tlsCert); err == nil {
+		reloader.modTime = info.ModTime()
+	}
+	reloader.cert = &cert
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	if c.tlsClientCA != "" {
+		pem, err := os.ReadFile(c.tlsClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.tlsClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,608 @@
+// Package grpc provides a managed gRPC client for agents calling the router.
+//
+// The client wraps the raw AgentService stubs with what every agent needs
+// in production:
+//
+//   - connection pooling: a fixed number of connections per router endpoint,
+//     used round-robin
+//   - failover: requests go to the first healthy endpoint in order, moving
+//     on to the next when one is UNAVAILABLE
+//   - retries: UNAVAILABLE calls are retried with jittered exponential backoff
+//   - circuit breaking: an endpoint that keeps failing is skipped until a
+//     cooldown elapses, then probed with a single request
+//   - deadlines: calls without a deadline get a default one
+//   - typed helpers (ExecuteFileRead, ExecuteNetworkFetch) that build the
+//     request and turn denials and failures into Go errors
+//
+// Usage:
+//
+//	c, err := grpc.New(grpc.Config{
+//		Targets:  []string{"router-0:50051", "router-1:50051"},
+//		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxID: "sb-1"},
+//	})
+//	defer c.Close()
+//	data, err := c.ExecuteFileRead(ctx, "/workspace/main.go")
+//	var denied *grpc.DeniedError
+//	if errors.As(err, &denied) { ... }
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// ErrCircuitOpen is returned when every router endpoint's circuit breaker
+// is open.
+var ErrCircuitOpen = errors.New("all router endpoints unavailable (circuit open)")
+
+// ErrToolUnavailable is returned for calls to a tool the router's circuit
+// breaker rejects because the tool keeps failing. Other tools, and the
+// router, remain available; retry the tool later.
+var ErrToolUnavailable = errors.New("tool unavailable (circuit open)")
+
+// ErrClosed is returned by calls on a closed client.
+var ErrClosed = errors.New("client is closed")
+
+// Config configures a Client.
+type Config struct {
+	// Targets are the router endpoints in failover order (required). A
+	// node-local router's Unix socket is addressed as
+	// "unix:///run/golden-agent/router.sock".
+	Targets []string
+
+	// PoolSize is the number of connections per target (default: 4)
+	PoolSize int
+
+	// DialOptions are passed to every connection. Default: insecure
+	// transport credentials, for in-cluster or UDS use.
+	DialOptions []grpclib.DialOption
+
+	// Metadata is the agent identity sent with requests that do not set
+	// their own
+	Metadata *agentpb.RequestMetadata
+
+	// DefaultTimeout is the deadline of calls whose context has none
+	// (default: 30s)
+	DefaultTimeout time.Duration
+
+	// MaxRetries is the number of retries of UNAVAILABLE calls (default: 3;
+	// negative disables retries)
+	MaxRetries int
+
+	// RetryBaseDelay and RetryMaxDelay bound the exponential backoff
+	// between retries (default: 50ms and 2s). Each delay is jittered
+	// between half and all of its value.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// BreakerThreshold is the number of consecutive UNAVAILABLE failures
+	// that opens an endpoint's circuit (default: 5)
+	BreakerThreshold int
+
+	// BreakerCooldown is how long an open circuit skips its endpoint
+	// before probing it again (default: 30s)
+	BreakerCooldown time.Duration
+}
+
+// withDefaults returns the config with unset fields defaulted.
+func (c Config) withDefaults() Config {
+	if c.PoolSize <= 0 {
+		c.PoolSize = 4
+	}
+	if len(c.DialOptions) == 0 {
+		c.DialOptions = []grpclib.DialOption{grpclib.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	if c.DefaultTimeout <= 0 {
+		c.DefaultTimeout = 30 * time.Second
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBaseDelay <= 0 {
+		c.RetryBaseDelay = 50 * time.Millisecond
+	}
+	if c.RetryMaxDelay <= 0 {
+		c.RetryMaxDelay = 2 * time.Second
+	}
+	if c.BreakerThreshold <= 0 {
+		c.BreakerThreshold = 5
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = 30 * time.Second
+	}
+	return c
+}
+
+// dialFunc opens one connection to a target.
+type dialFunc func(target string, opts []grpclib.DialOption) (grpclib.ClientConnInterface, io.Closer, error)
+
+// dial opens a gRPC connection. Connections are established lazily, so an
+// unreachable target surfaces as UNAVAILABLE on first use rather than here.
+func dial(target string, opts []grpclib.DialOption) (grpclib.ClientConnInterface, io.Closer, error) {
+	conn, err := grpclib.Dial(target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn, nil
+}
+
+// Client is a managed AgentService client. It is safe for concurrent use.
+type Client struct {
+	config    Config
+	endpoints []*endpoint
+	closed    atomic.Bool
+
+	// sessionToken is the token of the session opened with OpenSession
+	sessionToken atomic.Value
+}
+
+// New creates a client with a connection pool per target.
+func New(config Config) (*Client, error) {
+	return newClient(config, dial)
+}
+
+func newClient(config Config, dial dialFunc) (*Client, error) {
+	if len(config.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+	config = config.withDefaults()
+
+	c := &Client{config: config}
+	for _, target := range config.Targets {
+		ep := &endpoint{target: target}
+		for i := 0; i < config.PoolSize; i++ {
+			conn, closer, err := dial(target, config.DialOptions)
+			if err != nil {
+				c.Close()
+				return nil, fmt.Errorf("dial %s: %w", target, err)
+			}
+			ep.conns = append(ep.conns, agentpb.NewAgentServiceClient(conn))
+			ep.closers = append(ep.closers, closer)
+		}
+		c.endpoints = append(c.endpoints, ep)
+	}
+	return c, nil
+}
+
+// Close closes every pooled connection.
+func (c *Client) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	var errs []error
+	for _, ep := range c.endpoints {
+		for _, closer := range ep.closers {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Execute sends a raw request, with a generated request ID if it has none,
+// so that retries of the same call share an ID. A request without
+// metadata carries the open session's token, or else the client's
+// metadata. UNAVAILABLE errors are retried on the next healthy endpoint;
+// any other outcome, including a policy denial, is returned as is.
+//
+// Retrying is safe because the router reports UNAVAILABLE only for calls
+// it did not accept; a call that reached the tool executor fails with an
+// ExecuteResponse status instead.
+func (c *Client) Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*agentpb.ExecuteResponse, error) {
+	if req.Metadata == nil && req.SessionToken == "" {
+		if token, _ := c.sessionToken.Load().(string); token != "" {
+			req.SessionToken = token
+		} else {
+			req.Metadata = c.config.Metadata
+		}
+	}
+	if req.RequestId == "" {
+		req.RequestId = newRequestID()
+	}
+
+	var resp *agentpb.ExecuteResponse
+	err := c.invoke(ctx, func(ctx context.Context, client agentpb.AgentServiceClient) error {
+		var err error
+		resp, err = client.Execute(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// OpenSession opens a session with the client's metadata. Later calls
+// without their own metadata carry only the session token. For the
+// session to survive failover, the routers must share a session key.
+// A ttl of 0 requests the router's default lifetime.
+func (c *Client) OpenSession(ctx context.Context, ttl time.Duration) (*agentpb.OpenSessionResponse, error) {
+	req := &agentpb.OpenSessionRequest{Metadata: c.config.Metadata, TtlSeconds: int64(ttl / time.Second)}
+
+	var resp *agentpb.OpenSessionResponse
+	err := c.invoke(ctx, func(ctx context.Context, client agentpb.AgentServiceClient) error {
+		var err error
+		resp, err = client.OpenSession(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.sessionToken.Store(resp.GetSessionToken())
+	return resp, nil
+}
+
+// invoke runs a call with the default deadline, retrying UNAVAILABLE
+// errors with backoff on the next endpoint whose circuit allows it.
+func (c *Client) invoke(ctx context.Context, call func(ctx context.Context, client agentpb.AgentServiceClient) error) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DefaultTimeout)
+		defer cancel()
+	}
+
+	tried := make(map[*endpoint]bool, len(c.endpoints))
+	var lastErr error
+	for attempt := 0; attempt <= max(c.config.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return lastErr
+			}
+		}
+
+		ep := c.pick(tried)
+		if ep == nil {
+			if lastErr != nil {
+				return fmt.Errorf("%w: %v", ErrCircuitOpen, lastErr)
+			}
+			return ErrCircuitOpen
+		}
+		tried[ep] = true
+
+		err := call(ctx, ep.client())
+		if status.Code(err) == codes.Unavailable && !toolCircuitOpen(err) {
+			ep.failure(c.config.BreakerThreshold, c.config.BreakerCooldown)
+			lastErr = err
+			continue
+		}
+		ep.success()
+		return err
+	}
+	return lastErr
+}
+
+// pick returns the first endpoint whose circuit allows a call, preferring
+// those not yet tried by the current call. Returns nil if every circuit
+// is open.
+func (c *Client) pick(tried map[*endpoint]bool) *endpoint {
+	for _, untriedOnly := range []bool{true, false} {
+		for _, ep := range c.endpoints {
+			if untriedOnly && tried[ep] {
+				continue
+			}
+			if ep.allow() {
+				return ep
+			}
+		}
+	}
+	return nil
+}
+
+// backoff returns the jittered delay before a retry.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.config.RetryBaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > c.config.RetryMaxDelay {
+		delay = c.config.RetryMaxDelay
+	}
+	return delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ExecuteTool runs a tool with JSON parameters and returns its JSON result.
+// Denials are returned as *DeniedError and failed executions as
+// *ExecutionError.
+func (c *Client) ExecuteTool(ctx context.Context, toolName string, params map[string]interface{}) ([]byte, error) {
+	req, err := newRequest(toolName, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.call(ctx, req)
+}
+
+// ExecuteFileRead reads a file through the router's file.read tool.
+func (c *Client) ExecuteFileRead(ctx context.Context, path string) ([]byte, error) {
+	req, err := newRequest("file.read", map[string]interface{}{"path": path})
+	if err != nil {
+		return nil, err
+	}
+	req.TypedParameters = &agentpb.ExecuteRequest_File{File: &agentpb.FileParams{Path: path}}
+	return c.call(ctx, req)
+}
+
+// ExecuteNetworkFetch fetches a URL with GET through the router's
+// network.fetch tool.
+func (c *Client) ExecuteNetworkFetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL %q: host is required", rawURL)
+	}
+
+	port := 443
+	if u.Scheme == "http" {
+		port = 80
+	}
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: bad port", rawURL)
+		}
+	}
+
+	req, err := newRequest("network.fetch", map[string]interface{}{"url": rawURL, "method": "GET"})
+	if err != nil {
+		return nil, err
+	}
+	req.TypedParameters = &agentpb.ExecuteRequest_Network{Network: &agentpb.NetworkParams{
+		Domain: u.Hostname(),
+		Port:   int32(port),
+		Url:    rawURL,
+		Method: "GET",
+	}}
+	return c.call(ctx, req)
+}
+
+// newRequest builds a request with JSON-encoded parameters.
+func newRequest(toolName string, params map[string]interface{}) (*agentpb.ExecuteRequest, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode parameters: %w", err)
+	}
+	return &agentpb.ExecuteRequest{ToolName: toolName, Parameters: data}, nil
+}
+
+// call executes a request and converts unsuccessful outcomes to errors.
+func (c *Client) call(ctx context.Context, req *agentpb.ExecuteRequest) ([]byte, error) {
+	resp, err := c.Execute(ctx, req)
+	if err != nil {
+		if denied := deniedError(req.GetToolName(), err); denied != nil {
+			return nil, denied
+		}
+		if toolCircuitOpen(err) {
+			return nil, fmt.Errorf("%w: %q", ErrToolUnavailable, req.GetToolName())
+		}
+		return nil, err
+	}
+
+	switch resp.GetStatus() {
+	case agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS:
+		return resp.GetResult(), nil
+	case agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED:
+		// Permissive-mode routers and older routers report denials
+		// in the response rather than the status
+		return nil, &DeniedError{
+			Tool:    req.GetToolName(),
+			Policy:  resp.GetPolicyDecision().GetPolicyName(),
+			Message: resp.GetPolicyDecision().GetMessage(),
+			Locale:  resp.GetPolicyDecision().GetMessageLocale(),
+		}
+	default:
+		return nil, &ExecutionError{
+			Tool:      req.GetToolName(),
+			Status:    resp.GetStatus(),
+			Message:   resp.GetError(),
+			RequestID: resp.GetRequestId(),
+		}
+	}
+}
+
+// DeniedError reports a tool call denied by policy.
+type DeniedError struct {
+	// Tool is the denied tool
+	Tool string
+
+	// AgentType is the agent type the policy was resolved for
+	AgentType string
+
+	// Policy is the name of the deciding policy
+	Policy string
+
+	// Message is the rule's user-facing denial message, if any, in Locale
+	Message string
+	Locale  string
+
+	// Err is the typed cause of the denial reported by the router, if
+	// any: policyerrors.ErrNoPolicy, an *policyerrors.ErrConstraintViolation
+	// (whose Value is the rejected parameter as a string), or
+	// policyerrors.ErrMTSViolation
+	Err error
+}
+
+// Unwrap returns the typed cause of the denial, so callers can match it
+// with errors.Is and errors.As.
+func (e *DeniedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DeniedError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("tool %q denied by policy: %s", e.Tool, e.Message)
+	}
+	return fmt.Sprintf("tool %q denied by policy", e.Tool)
+}
+
+// deniedError converts a PERMISSION_DENIED status to a DeniedError, reading
+// the ErrorInfo and LocalizedMessage details the router attaches. Returns
+// nil for other errors.
+func deniedError(toolName string, err error) *DeniedError {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.PermissionDenied {
+		return nil
+	}
+
+	denied := &DeniedError{Tool: toolName}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetReason() != "POLICY_DENIED" {
+				continue
+			}
+			denied.AgentType = d.GetMetadata()["agent_type"]
+			denied.Policy = d.GetMetadata()["policy"]
+			denied.Err = denialCause(d.GetMetadata())
+		case *errdetails.LocalizedMessage:
+			denied.Message = d.GetMessage()
+			denied.Locale = d.GetLocale()
+		}
+	}
+	return denied
+}
+
+// toolCircuitOpen reports whether err is the UNAVAILABLE status of a call
+// the open circuit breaker of its tool rejected, which says nothing of the
+// router endpoint.
+func toolCircuitOpen(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unavailable {
+		return false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == "TOOL_CIRCUIT_OPEN" {
+			return true
+		}
+	}
+	return false
+}
+
+// denialCause reconstructs the typed cause of a denial from the "cause"
+// ErrorInfo metadata the router attaches. Returns nil for unknown causes.
+func denialCause(metadata map[string]string) error {
+	switch metadata["cause"] {
+	case "NO_POLICY":
+		return policyerrors.ErrNoPolicy
+	case "CONSTRAINT_VIOLATION":
+		violation := &policyerrors.ErrConstraintViolation{Constraint: metadata["constraint"]}
+		if value, ok := metadata["value"]; ok {
+			violation.Value = value
+		}
+		return violation
+	case "MTS_VIOLATION":
+		return policyerrors.ErrMTSViolation
+	case "SANDBOX_MISMATCH":
+		return policyerrors.ErrSandboxMismatch
+	default:
+		return nil
+	}
+}
+
+// ExecutionError reports a call the router accepted but could not carry
+// out: an invalid request or a failed execution.
+type ExecutionError struct {
+	Tool      string
+	Status    agentpb.ExecutionStatus
+	Message   string
+	RequestID string
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("tool %q: %s: %s", e.Tool, e.Status, e.Message)
+}
+
+// endpoint is a router target with its connection pool and circuit breaker.
+type endpoint struct {
+	target  string
+	conns   []agentpb.AgentServiceClient
+	closers []io.Closer
+	next    atomic.Uint32
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// client returns the next pooled connection, round-robin.
+func (e *endpoint) client() agentpb.AgentServiceClient {
+	n := e.next.Add(1)
+	return e.conns[int(n)%len(e.conns)]
+}
+
+// allow reports whether the circuit lets a call through. Once the cooldown
+// of an open circuit elapses, a single probe call is let through
+// (half-open); its outcome closes or reopens the circuit.
+func (e *endpoint) allow() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(e.openUntil) || e.probing {
+		return false
+	}
+	e.probing = true
+	return true
+}
+
+// success closes the circuit.
+func (e *endpoint) success() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.openUntil = time.Time{}
+	e.probing = false
+}
+
+// failure records an UNAVAILABLE call, opening the circuit at threshold
+// consecutive failures or when a probe fails.
+func (e *endpoint) failure(threshold int, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures >= threshold || e.probing {
+		e.openUntil = time.Now().Add(cooldown)
+	}
+	e.probing = false
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
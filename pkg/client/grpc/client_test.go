@@ -0,0 +1,241 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+	"github.com/golden-agent/golden-agent/pkg/router"
+)
+
+// NOTE: the hand-written proto stubs cannot be marshaled, so these tests
+// route calls to an in-process router.Server instead of a gRPC transport.
+
+// fakeConn is a connection to an in-process router, or to an unreachable
+// one if server is nil.
+type fakeConn struct {
+	server *router.Server
+
+	mu         sync.Mutex
+	requestIDs []string
+}
+
+func (f *fakeConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpclib.CallOption) error {
+	if open, ok := args.(*agentpb.OpenSessionRequest); ok {
+		if f.server == nil {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		resp, err := f.server.OpenSession(ctx, open)
+		if err != nil {
+			return err
+		}
+		out := reply.(*agentpb.OpenSessionResponse)
+		out.SessionToken, out.SessionId, out.ExpiresUnixNano = resp.SessionToken, resp.SessionId, resp.ExpiresUnixNano
+		return nil
+	}
+
+	req := args.(*agentpb.ExecuteRequest)
+	f.mu.Lock()
+	f.requestIDs = append(f.requestIDs, req.GetRequestId())
+	f.mu.Unlock()
+
+	if f.server == nil {
+		return status.Error(codes.Unavailable, "connection refused")
+	}
+	resp, err := f.server.Execute(ctx, req)
+	if err != nil {
+		return err
+	}
+	out := reply.(*agentpb.ExecuteResponse)
+	out.Result, out.Error, out.Status = resp.Result, resp.Error, resp.Status
+	out.RequestId, out.PolicyDecision = resp.RequestId, resp.PolicyDecision
+	return nil
+}
+
+func (f *fakeConn) NewStream(ctx context.Context, desc *grpclib.StreamDesc, method string, opts ...grpclib.CallOption) (grpclib.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "streams not supported")
+}
+
+func (f *fakeConn) Close() error { return nil }
+
+func (f *fakeConn) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requestIDs...)
+}
+
+// newTestClient creates a client whose targets are the given connections.
+func newTestClient(t *testing.T, config Config, conns map[string]*fakeConn) *Client {
+	t.Helper()
+	config.PoolSize = 1
+	config.RetryBaseDelay = time.Millisecond
+	config.Metadata = &agentpb.RequestMetadata{AgentType: "coding-assistant"}
+	c, err := newClient(config, func(target string, _ []grpclib.DialOption) (grpclib.ClientConnInterface, io.Closer, error) {
+		return conns[target], conns[target], nil
+	})
+	if err != nil {
+		t.Fatalf("newClient failed: %v", err)
+	}
+	return c
+}
+
+func newTestServer() *router.Server {
+	config := router.DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := router.NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+	return server
+}
+
+// TestClientFailover verifies UNAVAILABLE calls fail over to the next
+// target with the same request ID, and that the failed target's circuit
+// opens
+func TestClientFailover(t *testing.T) {
+	down, up := &fakeConn{}, &fakeConn{server: newTestServer()}
+	c := newTestClient(t, Config{Targets: []string{"router-0", "router-1"}, BreakerThreshold: 1},
+		map[string]*fakeConn{"router-0": down, "router-1": up})
+	defer c.Close()
+
+	if _, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go"); err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if d, u := down.calls(), up.calls(); len(d) != 1 || len(u) != 1 || d[0] == "" || d[0] != u[0] {
+		t.Errorf("expected one call per target with the same request ID, got %v and %v", d, u)
+	}
+
+	// The open circuit skips router-0
+	if _, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go"); err != nil {
+		t.Fatalf("ExecuteFileRead failed: %v", err)
+	}
+	if len(down.calls()) != 1 {
+		t.Errorf("expected open circuit to skip router-0, got %d calls", len(down.calls()))
+	}
+}
+
+// TestClientCircuitOpen verifies calls fail fast once every circuit is open
+func TestClientCircuitOpen(t *testing.T) {
+	down := &fakeConn{}
+	c := newTestClient(t, Config{Targets: []string{"router-0"}, MaxRetries: 2, BreakerThreshold: 2},
+		map[string]*fakeConn{"router-0": down})
+	defer c.Close()
+
+	_, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go")
+	if !errors.Is(err, ErrCircuitOpen) || len(down.calls()) != 2 {
+		t.Errorf("expected circuit to open after 2 failures, got %v after %d calls", err, len(down.calls()))
+	}
+	if _, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go"); !errors.Is(err, ErrCircuitOpen) || len(down.calls()) != 2 {
+		t.Errorf("expected fast failure, got %v", err)
+	}
+}
+
+// TestClientToolUnavailable verifies calls rejected by a tool's circuit
+// breaker fail with ErrToolUnavailable, without failing over or opening
+// the endpoint's circuit
+func TestClientToolUnavailable(t *testing.T) {
+	config := router.DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.CircuitBreaker = router.CircuitBreakerConfig{FailureRate: 1, Window: 1, Cooldown: time.Minute}
+	server := router.NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "db.query", Action: policy.Allow}, {Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+	server.RegisterTool("db.query", router.ToolHandlerFunc(func(ctx context.Context, call *router.ToolCall) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	}), nil)
+	server.RegisterTool("file.read", router.ToolHandlerFunc(func(ctx context.Context, call *router.ToolCall) (interface{}, error) {
+		return "ok", nil
+	}), nil)
+
+	up := &fakeConn{server: server}
+	c := newTestClient(t, Config{Targets: []string{"router-0", "router-1"}, BreakerThreshold: 1},
+		map[string]*fakeConn{"router-0": up, "router-1": {}})
+	defer c.Close()
+
+	var failed *ExecutionError
+	if _, err := c.ExecuteTool(context.Background(), "db.query", nil); !errors.As(err, &failed) {
+		t.Fatalf("expected the execution to fail, got %v", err)
+	}
+	if _, err := c.ExecuteTool(context.Background(), "db.query", nil); !errors.Is(err, ErrToolUnavailable) || len(up.calls()) != 2 {
+		t.Errorf("expected ErrToolUnavailable from the first endpoint, got %v after %d calls", err, len(up.calls()))
+	}
+	if _, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go"); err != nil {
+		t.Errorf("expected the endpoint to stay available, got %v", err)
+	}
+}
+
+// TestClientNoRetries verifies negative MaxRetries sends each call once
+func TestClientNoRetries(t *testing.T) {
+	down := &fakeConn{}
+	c := newTestClient(t, Config{Targets: []string{"router-0"}, MaxRetries: -1},
+		map[string]*fakeConn{"router-0": down})
+	defer c.Close()
+
+	if _, err := c.ExecuteFileRead(context.Background(), "/workspace/main.go"); status.Code(err) != codes.Unavailable || len(down.calls()) != 1 {
+		t.Errorf("expected one UNAVAILABLE call, got %v after %d calls", err, len(down.calls()))
+	}
+}
+
+// TestClientTypedErrors verifies denials and failed executions are
+// returned as typed errors
+func TestClientTypedErrors(t *testing.T) {
+	server := newTestServer()
+	c := newTestClient(t, Config{Targets: []string{"router-0"}}, map[string]*fakeConn{"router-0": {server: server}})
+	defer c.Close()
+
+	_, err := c.ExecuteNetworkFetch(context.Background(), "https://example.com/data")
+	var denied *DeniedError
+	if !errors.As(err, &denied) || denied.Policy != "coding-policy" || denied.AgentType != "coding-assistant" {
+		t.Errorf("expected DeniedError from coding-policy, got %v", err)
+	}
+
+	_, err = c.ExecuteTool(context.Background(), "", nil)
+	var failed *ExecutionError
+	if !errors.As(err, &failed) || failed.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID {
+		t.Errorf("expected ExecutionError for invalid request, got %v", err)
+	}
+
+	if _, err := c.ExecuteNetworkFetch(context.Background(), "/no-host"); err == nil {
+		t.Error("expected error for URL without host")
+	}
+
+	// The router's typed cause of a denial is unwrapped from the DeniedError
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow, Constraints: &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}}}},
+		policy.Enforcing, ""))
+	_, err = c.ExecuteFileRead(context.Background(), "/etc/passwd")
+	var violation *policyerrors.ErrConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != "pathPatterns" || violation.Value != "/etc/passwd" {
+		t.Errorf("expected pathPatterns violation of /etc/passwd, got %v", err)
+	}
+}
+
+// TestClientSession verifies calls after OpenSession carry only the
+// session token
+func TestClientSession(t *testing.T) {
+	conn := &fakeConn{server: newTestServer()}
+	c := newTestClient(t, Config{Targets: []string{"router-0"}}, map[string]*fakeConn{"router-0": conn})
+	defer c.Close()
+
+	if _, err := c.OpenSession(context.Background(), time.Minute); err != nil {
+		t.Fatalf("OpenSession failed: %v", err)
+	}
+
+	req, _ := newRequest("file.read", map[string]interface{}{"path": "/workspace/main.go"})
+	resp, err := c.Execute(context.Background(), req)
+	if err != nil || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("expected session call to succeed, got %v (%v)", resp, err)
+	}
+	if req.GetSessionToken() == "" || req.GetMetadata() != nil {
+		t.Errorf("expected request to carry only the session token, got token %q metadata %v", req.GetSessionToken(), req.GetMetadata())
+	}
+}
@@ -16,17 +16,33 @@ package controller
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
 	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+	policydiff "github.com/golden-agent/golden-agent/pkg/policy/diff"
 	regotempl "github.com/golden-agent/golden-agent/pkg/policy/rego"
 )
 
@@ -45,6 +61,64 @@ type AgentPolicyReconciler struct {
 	// When true, policies are compiled to Rego and use PreparedQuery.
 	// When false, policies use legacy ToolTable evaluation.
 	UseOPA bool
+
+	// ImpactCheck, when set, replays recorded audit traffic against each
+	// changed policy before it is loaded, and blocks changes that would
+	// deny too many previously allowed calls.
+	ImpactCheck *ImpactCheckConfig
+
+	// Heartbeat, when set, reports the policies this replica loaded to the
+	// other replicas, and records in each policy's status which live
+	// replicas have loaded it.
+	Heartbeat *ReplicaHeartbeat
+
+	// ValuesFrom reads the constraint values policies take from the
+	// ConfigMaps and Secrets of their namespace (valuesFrom), and compiles
+	// them again when those change. It watches only the metadata of
+	// ConfigMaps and Secrets, and reads the objects through APIReader, so
+	// that Secrets are not cached. Policies reading values fail without
+	// it.
+	ValuesFrom bool
+
+	// APIReader reads objects uncached (optional; default: the client).
+	APIReader client.Reader
+
+	// NodeName, when set, loads only the policies that apply to the
+	// sandboxes scheduled on the node, for node-local routers: the
+	// fallback, the policies SandboxClaims on the node reference, and
+	// their canaries. The status of the others is left to the routers
+	// loading them. It requires the SandboxClaimNodeField index.
+	NodeName string
+
+	// Faults injects policy.FaultControllerSync into reconciles, for
+	// failure-mode tests (optional).
+	Faults *policy.FaultInjector
+
+	// Options tune the concurrency and rate of reconciles (optional).
+	Options ReconcileOptions
+
+	// RenderRego, when set, writes the Rego generated for each policy to
+	// the ConfigMap "<name>-rego" in its namespace, for inspection, and
+	// references it in the policy's status (OPA mode only).
+	RenderRego bool
+
+	// loaded maps each AgentPolicy this reconciler loaded to the UID it
+	// loaded, so that deletions, which only carry the name, remove the
+	// right policy
+	loadedMu sync.Mutex
+	loaded   map[types.NamespacedName]types.UID
+
+	// artifacts keeps the compile result of each AgentPolicy by the hash
+	// of its spec, so that reconciles of an unchanged spec, such as
+	// requeues, do not compile it again
+	artifactsMu sync.Mutex
+	artifacts   map[types.NamespacedName]compileArtifact
+}
+
+// compileArtifact is the compile result of an AgentPolicy spec.
+type compileArtifact struct {
+	specHash string
+	result   *CompileResult
 }
 
 // Reconcile handles AgentPolicy create/update/delete events.
@@ -52,14 +126,26 @@ type AgentPolicyReconciler struct {
 //
 // The reconciliation flow:
 //  1. Fetch the AgentPolicy CRD
-//  2. If deleted: remove policy from engine
-//  3. Convert AgentPolicySpec to Rego (if OPA enabled)
-//  4. Compile to CompiledPolicy
-//  5. Load into engine for each agent type
-//  6. Update CRD status
+//  2. If deleted, or no sandbox of NodeName uses it: remove policy from
+//     engine
+//  3. Read the constraint values it references from ConfigMaps and Secrets
+//  4. Convert AgentPolicySpec to Rego (if OPA enabled) and compile it to a
+//     CompiledPolicy
+//  5. Replay recorded traffic against changes (if ImpactCheck is set)
+//  6. Load into engine for each agent type
+//  7. Acknowledge the load through the replica heartbeat (if set)
+//  8. Render the generated Rego to a ConfigMap (if RenderRego is set)
+//  9. Update CRD status, with per-agent-type results and acked replicas
 func (r *AgentPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// An injected disconnect fails like an unreachable API server, which
+	// leaves the loaded policy in force
+	if err := r.Faults.Inject(ctx, policy.FaultControllerSync); err != nil {
+		log.Error(err, "unable to fetch AgentPolicy")
+		return ctrl.Result{}, err
+	}
+
 	// Fetch the AgentPolicy
 	var agentPolicy agentsv1alpha1.AgentPolicy
 	if err := r.Get(ctx, req.NamespacedName, &agentPolicy); err != nil {
@@ -68,187 +154,501 @@ func (r *AgentPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, err
 		}
 		// Policy deleted - remove from engine
-		r.handleDeletion(ctx, req.Name)
+		r.handleDeletion(ctx, req.NamespacedName)
+		r.forgetArtifact(req.NamespacedName)
+		if r.Heartbeat != nil {
+			r.Heartbeat.Forget(req.NamespacedName)
+		}
 		return ctrl.Result{}, nil
 	}
 
+	// A node-local router unloads the policies no sandbox of its node uses
+	if r.NodeName != "" {
+		used, err := r.usedOnNode(ctx, &agentPolicy)
+		if err != nil {
+			log.Error(err, "unable to list SandboxClaims on node", "node", r.NodeName)
+			return ctrl.Result{}, err
+		}
+		if !used {
+			r.handleDeletion(ctx, req.NamespacedName)
+			if r.Heartbeat != nil {
+				r.Heartbeat.Forget(req.NamespacedName)
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+
 	log.Info("reconciling AgentPolicy", "name", agentPolicy.Name, "agentTypes", agentPolicy.Spec.AgentTypes)
 
-	// Compile the policy
-	compiled, regoModule, err := r.compilePolicy(&agentPolicy)
+	// Read the values its constraints reference. A missing ConfigMap or
+	// Secret fails like a spec that does not compile: creating it queues
+	// the policy again. Other read errors are retried.
+	resolved, secretValues, err := r.resolveValuesFrom(ctx, &agentPolicy)
 	if err != nil {
+		log.Error(err, "failed to read constraint values")
+		if statusErr := r.updateStatus(ctx, &agentPolicy, "", "", err); statusErr != nil {
+			log.Error(statusErr, "failed to update status")
+			return ctrl.Result{}, statusErr
+		}
+		if errors.Is(err, errValuesFromDisabled) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	// Compile the policy. A spec that fails to compile fails again until
+	// it changes, which queues the policy again, so it is not requeued.
+	result, err := r.compilePolicy(resolved)
+	if err != nil {
+		if len(secretValues) > 0 {
+			err = errors.New(redactSecrets(err.Error(), secretValues))
+		}
 		log.Error(err, "failed to compile policy")
-		r.updateStatus(ctx, &agentPolicy, "", err)
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		if statusErr := r.updateStatus(ctx, &agentPolicy, "", "", err); statusErr != nil {
+			log.Error(statusErr, "failed to update status")
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, nil
+	}
+	compiled := result.Policy
+	if err := compile.WithholdSecrets(compiled, &agentPolicy, secretValues); err != nil {
+		log.Error(err, "failed to withhold constraint values read from Secrets")
+		return ctrl.Result{}, err
+	}
+
+	// Surface Rego lint warnings (OPA mode only)
+	if result.RegoModule != "" {
+		setLintCondition(&agentPolicy, result.LintWarnings)
+		for _, w := range result.LintWarnings {
+			log.Info("generated Rego lint warning", "policy", agentPolicy.Name, "finding", w.String())
+		}
 	}
 
-	// Load into engine for each agent type
-	for _, agentType := range agentPolicy.Spec.AgentTypes {
-		r.PolicyEngine.LoadPolicy(agentType, compiled)
-		log.Info("loaded policy", "agentType", agentType, "policy", agentPolicy.Name, "opaEnabled", compiled.OPAEnabled)
+	// Surface tool rules that tool name normalization keeps calls from
+	// matching
+	collisions := r.PolicyEngine.ToolNameCollisions(compiled)
+	setToolNamesCondition(&agentPolicy, collisions)
+	for _, c := range collisions {
+		log.Info("tool rule collides with tool name normalization", "policy", agentPolicy.Name, "collision", c.String())
+	}
+
+	// Summarize what changed relative to the version currently loaded
+	changeSummary := redactSecrets(r.changeSummary(&agentPolicy, compiled), secretValues)
+	if changeSummary != "" {
+		log.Info("policy changed", "policy", agentPolicy.Name, "changes", changeSummary)
+	}
+
+	// Check the change against recorded traffic before activating it
+	if changeSummary != "" && r.ImpactCheck != nil {
+		if err := r.checkImpact(ctx, &agentPolicy, compiled); err != nil {
+			log.Info("policy change blocked by impact check", "policy", agentPolicy.Name, "reason", err.Error())
+			if statusErr := r.updateStatus(ctx, &agentPolicy, "", "", err); statusErr != nil {
+				log.Error(statusErr, "failed to update status")
+			}
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+	}
+
+	// Load into engine for each agent type, or, for a canary, as the new
+	// version of its stable policy
+	var res ctrl.Result
+	if agentPolicy.Spec.Canary != nil {
+		agentPolicy.Status.AgentTypes = nil
+		r.loadCanary(ctx, &agentPolicy, compiled)
+		res.RequeueAfter = canaryReportInterval
+	} else {
+		r.removeCanary(ctx, &agentPolicy)
+		agentPolicy.Status.AgentTypes = r.loadAgentTypes(ctx, &agentPolicy, compiled)
+	}
+
+	// Load or release the fallback designation, which only the oldest of
+	// the policies that set it holds
+	var holder *agentsv1alpha1.AgentPolicy
+	if isFallback(&agentPolicy) {
+		var policies agentsv1alpha1.AgentPolicyList
+		if err := r.List(ctx, &policies); err != nil {
+			log.Error(err, "unable to list AgentPolicies")
+			return ctrl.Result{}, err
+		}
+		holder = fallbackHolder(policies.Items)
+	}
+	designated := setFallbackCondition(&agentPolicy, holder)
+	if isFallback(&agentPolicy) && !designated {
+		log.Info("fallback designation conflicts", "policy", agentPolicy.Name, "fallback", holder.Namespace+"/"+holder.Name)
+	}
+	r.syncFallback(ctx, &agentPolicy, compiled, designated)
+
+	// Release the bindings the policy no longer has
+	r.releaseStale(ctx, req.NamespacedName, &agentPolicy, designated)
+
+	// Report the load to the other replicas and collect theirs. Until every
+	// live replica has loaded this generation, requeue to refresh the status.
+	if r.Heartbeat != nil {
+		r.Heartbeat.Ack(req.NamespacedName, agentPolicy.Generation)
+		replicas, converged, err := r.Heartbeat.Replicas(ctx, req.NamespacedName, agentPolicy.Generation)
+		if err != nil {
+			log.Error(err, "failed to read replica heartbeats")
+		} else {
+			agentPolicy.Status.Replicas = replicas
+		}
+		if err != nil || !converged {
+			res.RequeueAfter = r.Heartbeat.interval()
+		}
+	}
+
+	// Render the generated Rego for inspection and review, unless it holds
+	// values read from Secrets
+	hash := computeHash(result.RegoModule)
+	agentPolicy.Status.RenderedRegoRef = nil
+	if r.RenderRego && result.RegoModule != "" && len(secretValues) > 0 {
+		log.Info("not rendering Rego that holds values read from Secrets", "policy", agentPolicy.Name)
+	} else if r.RenderRego && result.RegoModule != "" {
+		ref, err := r.renderRego(ctx, &agentPolicy, result.RegoModule, hash)
+		if err != nil {
+			log.Error(err, "failed to render Rego", "policy", agentPolicy.Name)
+		}
+		agentPolicy.Status.RenderedRegoRef = ref
 	}
 
 	// Update status
-	hash := computeHash(regoModule)
-	if err := r.updateStatus(ctx, &agentPolicy, hash, nil); err != nil {
+	agentPolicy.Status.OPAEnabled = compiled.OPAEnabled
+	if err := r.updateStatus(ctx, &agentPolicy, hash, changeSummary, nil); err != nil {
 		log.Error(err, "failed to update status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return res, nil
 }
 
-// handleDeletion removes a policy from the engine when the CRD is deleted.
-// We don't know which agent types were affected, so we need to check
-// all loaded policies and remove the ones matching this policy name.
-func (r *AgentPolicyReconciler) handleDeletion(ctx context.Context, policyName string) {
+// loadAgentTypes loads the policy under each of its agent types and
+// returns the result for each. Patterns that cannot match any agent type
+// are not loaded; a policy that replaces another policy's binding is
+// loaded, and the replacement is reported.
+func (r *AgentPolicyReconciler) loadAgentTypes(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy) []agentsv1alpha1.AgentTypeStatus {
 	log := log.FromContext(ctx)
 
-	// Remove policy for all agent types that had this policy
-	for _, agentType := range r.PolicyEngine.ListPolicies() {
-		if policy, ok := r.PolicyEngine.GetPolicy(agentType); ok {
-			if policy.Name == policyName {
-				r.PolicyEngine.RemovePolicy(agentType)
-				log.Info("removed policy", "agentType", agentType, "policy", policyName)
-			}
+	results := make([]agentsv1alpha1.AgentTypeStatus, 0, len(ap.Spec.AgentTypes))
+	for _, agentType := range ap.Spec.AgentTypes {
+		status := agentsv1alpha1.AgentTypeStatus{AgentType: agentType}
+
+		if _, err := path.Match(agentType, ""); err != nil {
+			status.Reason = "InvalidPattern"
+			status.Message = fmt.Sprintf("agent type pattern is malformed: %v", err)
+			results = append(results, status)
+			log.Info("skipped malformed agent type pattern", "agentType", agentType, "policy", ap.Name)
+			continue
 		}
+
+		// A policy already bound is not loaded again, which would prepare
+		// its queries and invalidate its cached decisions anew
+		previous, hadPrevious := r.PolicyEngine.GetPolicy(agentType)
+		if !hadPrevious || previous != compiled {
+			r.PolicyEngine.LoadPolicy(agentType, compiled)
+			log.Info("loaded policy", "agentType", agentType, "policy", ap.Name, "opaEnabled", compiled.OPAEnabled)
+		}
+
+		if loaded, ok := r.PolicyEngine.GetPolicy(agentType); !ok || loaded != compiled {
+			status.Reason = "NotLoaded"
+			status.Message = "engine did not retain the policy binding"
+		} else if hadPrevious && previous.UID != string(ap.UID) {
+			status.Loaded = true
+			status.Reason = "Replaced"
+			status.Message = fmt.Sprintf("replaced the binding of policy %q", policyRef(previous))
+		} else {
+			status.Loaded = true
+			status.Reason = "Loaded"
+			status.Message = "policy loaded"
+		}
+		results = append(results, status)
 	}
+	return results
 }
 
-// compilePolicy converts an AgentPolicy CRD to a CompiledPolicy.
-// Returns the compiled policy, the Rego module (if OPA enabled), and any error.
-func (r *AgentPolicyReconciler) compilePolicy(ap *agentsv1alpha1.AgentPolicy) (*policy.CompiledPolicy, string, error) {
-	// Convert CRD types to internal types
-	defaultAction := policy.Deny
-	if ap.Spec.DefaultAction == agentsv1alpha1.DecisionAllow {
-		defaultAction = policy.Allow
+// handleDeletion removes a policy from the engine when the CRD is deleted.
+// The deleted object is gone, so its UID is the one last loaded under its
+// name; a policy of the same name in another namespace, or one that
+// replaced its bindings, is not touched. Deleting it again does nothing.
+func (r *AgentPolicyReconciler) handleDeletion(ctx context.Context, name types.NamespacedName) {
+	log := log.FromContext(ctx)
+
+	uid, ok := r.loadedUID(name)
+	if !ok {
+		return
+	}
+	for _, agentType := range r.PolicyEngine.RemovePolicyUID(string(uid)) {
+		log.Info("removed policy", "agentType", agentType, "policy", name.String())
 	}
+	for _, stable := range r.PolicyEngine.RemoveCanaryUID(string(uid)) {
+		log.Info("removed canary", "stable", stable, "policy", name.String())
+	}
+	r.setLoadedUID(name, "")
+}
 
-	mode := policy.Enforcing
-	if ap.Spec.Mode == agentsv1alpha1.EnforcementModePermissive {
-		mode = policy.Permissive
+// releaseStale removes the bindings of an AgentPolicy that its spec no
+// longer has: agent types removed from it, the fallback unless it is
+// designated, and, if it was recreated under the same name, the bindings
+// of the deleted object. Releasing them again does nothing.
+func (r *AgentPolicyReconciler) releaseStale(ctx context.Context, name types.NamespacedName, ap *agentsv1alpha1.AgentPolicy, designated bool) {
+	log := log.FromContext(ctx)
+
+	var keep []string
+	if ap.Spec.Canary == nil {
+		keep = append(keep, ap.Spec.AgentTypes...)
+	}
+	if designated {
+		keep = append(keep, policy.FallbackAgentType)
+	}
+	for _, agentType := range r.PolicyEngine.RemovePolicyUID(string(ap.UID), keep...) {
+		log.Info("removed policy", "agentType", agentType, "policy", name.String())
 	}
 
-	// Build tool permissions
-	permissions := make([]policy.ToolPermission, 0, len(ap.Spec.ToolPermissions))
-	for _, tp := range ap.Spec.ToolPermissions {
-		action := policy.Deny
-		if tp.Action == agentsv1alpha1.DecisionAllow {
-			action = policy.Allow
+	if previous, ok := r.loadedUID(name); ok && previous != ap.UID {
+		for _, agentType := range r.PolicyEngine.RemovePolicyUID(string(previous)) {
+			log.Info("removed policy of a deleted object", "agentType", agentType, "policy", name.String(), "uid", previous)
 		}
-
-		perm := policy.ToolPermission{
-			Tool:   tp.Tool,
-			Action: action,
+		for _, stable := range r.PolicyEngine.RemoveCanaryUID(string(previous)) {
+			log.Info("removed canary of a deleted object", "stable", stable, "policy", name.String(), "uid", previous)
 		}
+	}
+	r.setLoadedUID(name, ap.UID)
+}
 
-		if tp.Constraints != nil {
-			perm.Constraints = convertConstraints(tp.Constraints)
-		}
+// loadedUID returns the UID of the AgentPolicy last loaded under name.
+func (r *AgentPolicyReconciler) loadedUID(name types.NamespacedName) (types.UID, bool) {
+	r.loadedMu.Lock()
+	defer r.loadedMu.Unlock()
+	uid, ok := r.loaded[name]
+	return uid, ok
+}
 
-		permissions = append(permissions, perm)
+// setLoadedUID records the UID loaded under name, or forgets name if uid
+// is empty.
+func (r *AgentPolicyReconciler) setLoadedUID(name types.NamespacedName, uid types.UID) {
+	r.loadedMu.Lock()
+	defer r.loadedMu.Unlock()
+	if uid == "" {
+		delete(r.loaded, name)
+		return
 	}
+	if r.loaded == nil {
+		r.loaded = make(map[types.NamespacedName]types.UID)
+	}
+	r.loaded[name] = uid
+}
 
-	// Get MTS label
-	mtsLabel := ""
-	mtsEnforceMode := "strict"
-	if ap.Spec.TenantIsolation != nil {
-		mtsLabel = ap.Spec.TenantIsolation.MTSLabel
-		if ap.Spec.TenantIsolation.EnforceMode != "" {
-			mtsEnforceMode = string(ap.Spec.TenantIsolation.EnforceMode)
+// policyRef names a compiled policy by namespace and name, if it has a
+// namespace.
+func policyRef(p *policy.CompiledPolicy) string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
+
+// changeSummary describes how a newly compiled policy differs from the
+// version of the same policy currently loaded in the engine.
+// Returns "" when nothing changed, so the last recorded summary is kept.
+func (r *AgentPolicyReconciler) changeSummary(ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy) string {
+	if ap.Spec.Canary != nil {
+		return r.canaryChangeSummary(ap, compiled)
+	}
+
+	var previous *policy.CompiledPolicy
+	for _, agentType := range append(append([]string{}, ap.Spec.AgentTypes...), policy.FallbackAgentType) {
+		if loaded, ok := r.PolicyEngine.GetPolicy(agentType); ok && loaded.UID == string(ap.UID) {
+			previous = loaded
+			break
 		}
 	}
 
-	// Compile with or without OPA
-	if r.UseOPA {
-		// Generate Rego module
-		spec := &regotempl.PolicySpec{
-			Name:           ap.Name,
-			AgentTypes:     ap.Spec.AgentTypes,
-			DefaultAction:  string(ap.Spec.DefaultAction),
-			Mode:           string(ap.Spec.Mode),
-			MTSLabel:       mtsLabel,
-			MTSEnforceMode: mtsEnforceMode,
+	if previous == nil {
+		// Not loaded yet: either a new policy or a router restart, in which
+		// case the summary recorded by the previous run is still accurate
+		if ap.Status.LastChangeSummary != "" {
+			return ""
 		}
+		return "initial version: " + policydiff.Compare(nil, compiled).Summary()
+	}
 
-		// Convert tool permissions to Rego spec
-		for _, tp := range ap.Spec.ToolPermissions {
-			tpSpec := regotempl.ToolPermissionSpec{
-				Tool:   tp.Tool,
-				Action: string(tp.Action),
-			}
+	d := policydiff.Compare(previous, compiled)
+	if d.Empty() {
+		return ""
+	}
+	return d.Summary()
+}
 
-			if tp.Constraints != nil {
-				tpSpec.Constraints = &regotempl.ConstraintSpec{
-					PathPatterns:   tp.Constraints.PathPatterns,
-					AllowedDomains: tp.Constraints.AllowedDomains,
-					DeniedDomains:  tp.Constraints.DeniedDomains,
-					AllowedPorts:   tp.Constraints.AllowedPorts,
-				}
-				if tp.Constraints.MaxSizeBytes != nil {
-					tpSpec.Constraints.MaxSizeBytes = *tp.Constraints.MaxSizeBytes
-				}
-			}
+// syncFallback loads the policy under the wildcard fallback key when it is
+// designated as the cluster fallback, and removes it from that key when the
+// designation has been dropped or is held by another policy.
+func (r *AgentPolicyReconciler) syncFallback(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy, designated bool) {
+	log := log.FromContext(ctx)
 
-			spec.ToolPermissions = append(spec.ToolPermissions, tpSpec)
-		}
+	current, hasFallback := r.PolicyEngine.FallbackPolicy()
 
-		// Compile to Rego
-		regoModule, err := regotempl.CompileToRego(spec)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to generate Rego: %w", err)
+	if designated {
+		if hasFallback && current == compiled {
+			return
 		}
-
-		// Compile with OPA
-		compiled, err := policy.CompilePolicyWithOPA(ap.Name, ap.Spec.AgentTypes, defaultAction, permissions, mode, mtsLabel, regoModule)
-		if err != nil {
-			return nil, regoModule, fmt.Errorf("failed to compile OPA policy: %w", err)
+		if hasFallback && current.UID != string(ap.UID) {
+			log.Info("replacing fallback policy", "previous", policyRef(current), "policy", ap.Name)
 		}
+		r.PolicyEngine.LoadPolicy(policy.FallbackAgentType, compiled)
+		log.Info("loaded fallback policy", "policy", ap.Name)
+		return
+	}
 
-		return compiled, regoModule, nil
+	if hasFallback && current.UID == string(ap.UID) {
+		r.PolicyEngine.RemovePolicy(policy.FallbackAgentType)
+		log.Info("removed fallback policy", "policy", ap.Name)
 	}
+}
 
-	// Legacy compilation (no OPA)
-	compiled := policy.CompilePolicy(ap.Name, ap.Spec.AgentTypes, defaultAction, permissions, mode, mtsLabel)
-	return compiled, "", nil
+// isFallback reports whether an AgentPolicy is the cluster fallback. A
+// canary is not, even if its spec says so: it only applies where its
+// stable policy does.
+func isFallback(ap *agentsv1alpha1.AgentPolicy) bool {
+	return ap.Spec.Fallback && ap.Spec.Canary == nil
 }
 
-// convertConstraints converts CRD constraints to internal constraints.
-func convertConstraints(c *agentsv1alpha1.ToolConstraints) *policy.ToolConstraints {
-	if c == nil {
+// fallbackHolder returns the AgentPolicy that holds the cluster fallback
+// designation: the oldest of those that set it, or nil if none does.
+func fallbackHolder(policies []agentsv1alpha1.AgentPolicy) *agentsv1alpha1.AgentPolicy {
+	var candidates []*agentsv1alpha1.AgentPolicy
+	for i := range policies {
+		if isFallback(&policies[i]) && policies[i].DeletionTimestamp == nil {
+			candidates = append(candidates, &policies[i])
+		}
+	}
+	if len(candidates) == 0 {
 		return nil
 	}
+	sortOldestFirst(candidates)
+	return candidates[0]
+}
 
-	tc := &policy.ToolConstraints{
-		PathPatterns:   c.PathPatterns,
-		AllowedDomains: c.AllowedDomains,
-		DeniedDomains:  c.DeniedDomains,
+// setFallbackCondition records, for a policy that sets Fallback, whether
+// it holds the designation as the Fallback condition, and reports whether
+// it does. A designation another policy holds is rejected as a Conflict.
+// A nil holder, such as one not listed yet, leaves it to the policy.
+func setFallbackCondition(ap, holder *agentsv1alpha1.AgentPolicy) bool {
+	if !isFallback(ap) {
+		meta.RemoveStatusCondition(&ap.Status.Conditions, conditionFallback)
+		return false
+	}
+	condition := metav1.Condition{
+		Type:               conditionFallback,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Designated",
+		Message:            "Policy is the cluster fallback",
+		ObservedGeneration: ap.Generation,
+	}
+	if holder != nil && holder.UID != ap.UID {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Conflict"
+		condition.Message = fmt.Sprintf("AgentPolicy %s/%s, created earlier, is the cluster fallback", holder.Namespace, holder.Name)
 	}
+	setCondition(ap, condition)
+	return condition.Status == metav1.ConditionTrue
+}
 
-	// Convert int32 ports to int
-	if len(c.AllowedPorts) > 0 {
-		tc.AllowedPorts = make([]int, len(c.AllowedPorts))
-		for i, p := range c.AllowedPorts {
-			tc.AllowedPorts[i] = int(p)
+// fallbackPolicies queues, when an AgentPolicy that sets Fallback changes
+// or is deleted, the other policies that set it, so that the oldest of
+// them takes over a designation the changed policy dropped.
+func (r *AgentPolicyReconciler) fallbackPolicies(ctx context.Context, obj client.Object) []reconcile.Request {
+	ap, ok := obj.(*agentsv1alpha1.AgentPolicy)
+	if !ok || !isFallback(ap) {
+		return nil
+	}
+	var policies agentsv1alpha1.AgentPolicyList
+	if err := r.List(ctx, &policies); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list fallback AgentPolicies", "policy", ap.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		if other := &policies.Items[i]; other.UID != ap.UID && isFallback(other) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(other)})
 		}
 	}
+	return requests
+}
+
+// CompileResult is the output of compiling an AgentPolicy.
+type CompileResult = compile.Result
 
-	if c.MaxSizeBytes != nil {
-		tc.MaxSizeBytes = *c.MaxSizeBytes
+// compilePolicy converts an AgentPolicy CRD to a CompiledPolicy. The
+// result of the last compile of the policy is returned if its spec has not
+// changed since, and the engine is left with the same policy loaded.
+func (r *AgentPolicyReconciler) compilePolicy(ap *agentsv1alpha1.AgentPolicy) (*CompileResult, error) {
+	name := client.ObjectKeyFromObject(ap)
+	hash, err := specHash(ap)
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse timeout duration
-	if c.Timeout != "" {
-		if d, err := time.ParseDuration(c.Timeout); err == nil {
-			tc.Timeout = d
-		}
+	r.artifactsMu.Lock()
+	artifact, ok := r.artifacts[name]
+	r.artifactsMu.Unlock()
+	if ok && artifact.specHash == hash {
+		return artifact.result, nil
 	}
 
-	return tc
+	result, err := CompileAgentPolicy(ap, r.UseOPA)
+	r.artifactsMu.Lock()
+	defer r.artifactsMu.Unlock()
+	if err != nil {
+		delete(r.artifacts, name)
+		return nil, err
+	}
+	if r.artifacts == nil {
+		r.artifacts = make(map[types.NamespacedName]compileArtifact)
+	}
+	r.artifacts[name] = compileArtifact{specHash: hash, result: result}
+	return result, nil
 }
 
-// updateStatus updates the AgentPolicy status subresource.
-func (r *AgentPolicyReconciler) updateStatus(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, hash string, reconcileErr error) error {
+// forgetArtifact drops the compile result of a deleted AgentPolicy.
+func (r *AgentPolicyReconciler) forgetArtifact(name types.NamespacedName) {
+	r.artifactsMu.Lock()
+	defer r.artifactsMu.Unlock()
+	delete(r.artifacts, name)
+}
+
+// specHash hashes what an AgentPolicy is compiled from: its spec and UID.
+func specHash(ap *agentsv1alpha1.AgentPolicy) (string, error) {
+	spec, err := json.Marshal(ap.Spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash spec: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(ap.UID))
+	h.Write([]byte{0})
+	h.Write(spec)
+	return fmt.Sprintf("%x", h.Sum(nil)[:8]), nil
+}
+
+// CompileAgentPolicy converts an AgentPolicy CRD to a CompiledPolicy, the
+// same way the controller does before loading it into the engine.
+//
+// Deprecated: use compile.AgentPolicy, which does not depend on
+// controller-runtime.
+func CompileAgentPolicy(ap *agentsv1alpha1.AgentPolicy, useOPA bool) (*CompileResult, error) {
+	return compile.AgentPolicy(ap, useOPA)
+}
+
+// Conditions set by the AgentPolicy controller. Status patches only write
+// these, so conditions set by other controllers are preserved.
+const (
+	conditionReady          = "Ready"
+	conditionRegoLintClean  = "RegoLintClean"
+	conditionImpactAnalyzed = "ImpactAnalyzed"
+	conditionToolNames      = "ToolNamesCanonical"
+	conditionFallback       = "Fallback"
+)
+
+// updateStatus records the result of a reconcile in the AgentPolicy status
+// and patches the status subresource.
+func (r *AgentPolicyReconciler) updateStatus(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, hash, changeSummary string, reconcileErr error) error {
 	// Update status fields
 	now := metav1.Now()
 	ap.Status.LastUpdated = &now
@@ -258,14 +658,78 @@ func (r *AgentPolicyReconciler) updateStatus(ctx context.Context, ap *agentsv1al
 		ap.Status.CompiledHash = hash
 	}
 
-	// Update conditions
+	if changeSummary != "" {
+		ap.Status.LastChangeSummary = changeSummary
+	}
+
+	ap.Status.LoadedAgentTypes = int32(loadedAgentTypes(ap.Status.AgentTypes))
+	ap.Status.LastError = ""
+	if reconcileErr != nil {
+		ap.Status.LastError = reconcileErr.Error()
+	}
+
+	setCondition(ap, readyCondition(ap, reconcileErr))
+
+	return r.patchStatus(ctx, ap)
+}
+
+// patchStatus writes the status of ap with a merge patch on the status
+// subresource. The patch is made against the latest version of the object,
+// with its resourceVersion, so a concurrent status write fails it with a
+// conflict rather than being overwritten; on conflict the latest version is
+// fetched again and the patch retried.
+func (r *AgentPolicyReconciler) patchStatus(ctx context.Context, ap *agentsv1alpha1.AgentPolicy) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var latest agentsv1alpha1.AgentPolicy
+		if err := r.Get(ctx, client.ObjectKeyFromObject(ap), &latest); err != nil {
+			return err
+		}
+		base := latest.DeepCopy()
+		mergeStatus(&latest.Status, &ap.Status)
+		return r.Status().Patch(ctx, &latest, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// mergeStatus copies the status fields the controller owns from src to dst.
+// Of the conditions, only the ones the controller sets are copied, and
+// with meta.SetStatusCondition, so the others are kept and each keeps its
+// LastTransitionTime unless its status changes. The Fallback condition is
+// removed with the designation.
+func mergeStatus(dst, src *agentsv1alpha1.AgentPolicyStatus) {
+	dst.CompiledHash = src.CompiledHash
+	dst.LastChangeSummary = src.LastChangeSummary
+	dst.LastUpdated = src.LastUpdated
+	dst.ObservedGeneration = src.ObservedGeneration
+	dst.AgentTypes = src.AgentTypes
+	dst.Replicas = src.Replicas
+	dst.OPAEnabled = src.OPAEnabled
+	dst.LoadedAgentTypes = src.LoadedAgentTypes
+	dst.LastError = src.LastError
+	dst.RenderedRegoRef = src.RenderedRegoRef
+
+	for _, conditionType := range []string{conditionReady, conditionRegoLintClean, conditionImpactAnalyzed, conditionToolNames, conditionFallback} {
+		if c := meta.FindStatusCondition(src.Conditions, conditionType); c != nil {
+			meta.SetStatusCondition(&dst.Conditions, *c)
+		}
+	}
+	if meta.FindStatusCondition(src.Conditions, conditionFallback) == nil {
+		meta.RemoveStatusCondition(&dst.Conditions, conditionFallback)
+	}
+}
+
+// readyCondition returns the Ready condition for the result of a reconcile.
+func readyCondition(ap *agentsv1alpha1.AgentPolicy, reconcileErr error) metav1.Condition {
 	condition := metav1.Condition{
-		Type:               "Ready",
-		LastTransitionTime: now,
+		Type:               conditionReady,
 		ObservedGeneration: ap.Generation,
 	}
 
-	if reconcileErr != nil {
+	var impactErr *impactCheckError
+	if errors.As(reconcileErr, &impactErr) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ImpactCheckFailed"
+		condition.Message = reconcileErr.Error()
+	} else if reconcileErr != nil {
 		condition.Status = metav1.ConditionFalse
 		condition.Reason = "CompilationFailed"
 		condition.Message = reconcileErr.Error()
@@ -273,22 +737,77 @@ func (r *AgentPolicyReconciler) updateStatus(ctx context.Context, ap *agentsv1al
 		condition.Status = metav1.ConditionTrue
 		condition.Reason = "PolicyCompiled"
 		condition.Message = "Policy successfully compiled and loaded"
+		if loaded := loadedAgentTypes(ap.Status.AgentTypes); loaded < len(ap.Status.AgentTypes) {
+			condition.Reason = "PartiallyLoaded"
+			condition.Message = fmt.Sprintf("Policy compiled and loaded for %d of %d agent types", loaded, len(ap.Status.AgentTypes))
+		}
 	}
+	return condition
+}
 
-	// Update or add condition
-	found := false
-	for i, c := range ap.Status.Conditions {
-		if c.Type == "Ready" {
-			ap.Status.Conditions[i] = condition
-			found = true
-			break
+// loadedAgentTypes counts the agent types the policy is loaded for.
+func loadedAgentTypes(statuses []agentsv1alpha1.AgentTypeStatus) int {
+	n := 0
+	for _, s := range statuses {
+		if s.Loaded {
+			n++
+		}
+	}
+	return n
+}
+
+// setLintCondition records the Rego lint result as the RegoLintClean condition.
+func setLintCondition(ap *agentsv1alpha1.AgentPolicy, warnings []regotempl.Finding) {
+	condition := metav1.Condition{
+		Type:               conditionRegoLintClean,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NoFindings",
+		Message:            "Generated Rego passed lint",
+		ObservedGeneration: ap.Generation,
+	}
+
+	if len(warnings) > 0 {
+		messages := make([]string, len(warnings))
+		for i, w := range warnings {
+			messages[i] = w.String()
 		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "LintWarnings"
+		condition.Message = strings.Join(messages, "; ")
 	}
-	if !found {
-		ap.Status.Conditions = append(ap.Status.Conditions, condition)
+
+	setCondition(ap, condition)
+}
+
+// setToolNamesCondition records the tool rules of the policy that calls
+// cannot match as the ToolNamesCanonical condition.
+func setToolNamesCondition(ap *agentsv1alpha1.AgentPolicy, collisions []policy.ToolNameCollision) {
+	condition := metav1.Condition{
+		Type:               conditionToolNames,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NoCollisions",
+		Message:            "Every tool rule is named as calls are normalized",
+		ObservedGeneration: ap.Generation,
+	}
+
+	if len(collisions) > 0 {
+		messages := make([]string, len(collisions))
+		for i, c := range collisions {
+			messages[i] = c.String()
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ToolNameCollisions"
+		condition.Message = strings.Join(messages, "; ")
 	}
 
-	return r.Status().Update(ctx, ap)
+	setCondition(ap, condition)
+}
+
+// setCondition updates the condition of the same type, or adds it. The
+// condition keeps its LastTransitionTime unless its status changes, in
+// which case it is set to now.
+func setCondition(ap *agentsv1alpha1.AgentPolicy, condition metav1.Condition) {
+	meta.SetStatusCondition(&ap.Status.Conditions, condition)
 }
 
 // computeHash generates a hash of the Rego module for change detection.
@@ -301,9 +820,28 @@ func computeHash(regoModule string) string {
 }
 
 // SetupWithManager sets up the controller with the Manager.
-// This registers the controller to watch AgentPolicy CRDs.
+// This registers the controller to watch AgentPolicy CRDs. Updates that
+// leave the generation unchanged, such as the controller's own status
+// updates, are not reconciled: only spec changes can change the policy.
+// With ValuesFrom, changes to the ConfigMaps and Secrets policies read
+// constraint values from reconcile the policies reading them; only their
+// metadata is watched. With NodeName, SandboxClaims
+// scheduled on or off the node reconcile the policies they reference.
+// Changes to a fallback policy reconcile the other fallback policies. The
+// queue is worked through as r.Options set.
 func (r *AgentPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&agentsv1alpha1.AgentPolicy{}).
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.AgentPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&agentsv1alpha1.AgentPolicy{}, handler.EnqueueRequestsFromMapFunc(r.fallbackPolicies), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	if r.ValuesFrom {
+		b = b.Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.policiesReadingValues(compile.ValuesKindConfigMap)), builder.OnlyMetadata).
+			Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.policiesReadingValues(compile.ValuesKindSecret)), builder.OnlyMetadata)
+	}
+	if r.NodeName != "" {
+		claim := &unstructured.Unstructured{}
+		claim.SetGroupVersionKind(SandboxClaimGVK)
+		b = b.Watches(claim, handler.EnqueueRequestsFromMapFunc(r.policiesOfClaim), builder.WithPredicates(onNode(r.NodeName)))
+	}
+	return b.WithOptions(r.Options.controllerOptions()).
 		Complete(r)
 }
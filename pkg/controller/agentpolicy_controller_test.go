@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// TestReadyCondition tests the Ready condition reported for each reconcile
+// result.
+func TestReadyCondition(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{}
+	ap.Generation = 3
+
+	tests := []struct {
+		name       string
+		agentTypes []agentsv1alpha1.AgentTypeStatus
+		err        error
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{"loaded", []agentsv1alpha1.AgentTypeStatus{{AgentType: "a", Loaded: true}}, nil, metav1.ConditionTrue, "PolicyCompiled"},
+		{"partially loaded", []agentsv1alpha1.AgentTypeStatus{{AgentType: "a", Loaded: true}, {AgentType: "[", Reason: "InvalidPattern"}}, nil, metav1.ConditionTrue, "PartiallyLoaded"},
+		{"compilation failed", nil, errors.New("bad rule"), metav1.ConditionFalse, "CompilationFailed"},
+		{"impact check failed", nil, &impactCheckError{report: &replay.Report{NewlyDenied: 2}, limit: 1}, metav1.ConditionFalse, "ImpactCheckFailed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ap.Status.AgentTypes = tt.agentTypes
+			c := readyCondition(ap, tt.err)
+			if c.Type != conditionReady || c.Status != tt.wantStatus || c.Reason != tt.wantReason {
+				t.Errorf("expected %s %s, got %s %s %s", tt.wantStatus, tt.wantReason, c.Type, c.Status, c.Reason)
+			}
+			if c.ObservedGeneration != 3 {
+				t.Errorf("expected observed generation 3, got %d", c.ObservedGeneration)
+			}
+		})
+	}
+}
+
+// TestConditionTransitions tests that conditions keep their transition time
+// until their status changes.
+func TestConditionTransitions(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{}
+	ap.Generation = 1
+
+	setCondition(ap, readyCondition(ap, nil))
+	first := meta.FindStatusCondition(ap.Status.Conditions, conditionReady)
+	if first == nil || first.LastTransitionTime.IsZero() {
+		t.Fatalf("expected Ready to be set with a transition time, got %+v", first)
+	}
+
+	// Same status, new generation: the transition time is kept
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	first.LastTransitionTime = past
+	ap.Generation = 2
+	setCondition(ap, readyCondition(ap, nil))
+	c := meta.FindStatusCondition(ap.Status.Conditions, conditionReady)
+	if !c.LastTransitionTime.Equal(&past) {
+		t.Errorf("expected the transition time to be kept, got %v", c.LastTransitionTime)
+	}
+	if c.ObservedGeneration != 2 {
+		t.Errorf("expected observed generation 2, got %d", c.ObservedGeneration)
+	}
+
+	// Status change: the transition time moves
+	setCondition(ap, readyCondition(ap, errors.New("bad rule")))
+	c = meta.FindStatusCondition(ap.Status.Conditions, conditionReady)
+	if c.Status != metav1.ConditionFalse || c.Reason != "CompilationFailed" {
+		t.Errorf("expected Ready to be False, got %s %s", c.Status, c.Reason)
+	}
+	if c.LastTransitionTime.Equal(&past) {
+		t.Error("expected the transition time to move on a status change")
+	}
+	if len(ap.Status.Conditions) != 1 {
+		t.Errorf("expected one condition, got %d", len(ap.Status.Conditions))
+	}
+}
+
+// TestMergeStatus tests that status patches keep the conditions other
+// controllers set.
+func TestMergeStatus(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	latest := agentsv1alpha1.AgentPolicyStatus{
+		Conditions: []metav1.Condition{
+			{Type: "Bound", Status: metav1.ConditionTrue, Reason: "ClaimsBound", LastTransitionTime: past},
+			{Type: conditionReady, Status: metav1.ConditionTrue, Reason: "PolicyCompiled", LastTransitionTime: past},
+		},
+	}
+
+	ap := &agentsv1alpha1.AgentPolicy{}
+	ap.Generation = 4
+	ap.Status.CompiledHash = "abc"
+	ap.Status.ObservedGeneration = 4
+	ap.Status.OPAEnabled, ap.Status.LoadedAgentTypes = true, 2
+	ap.Status.RenderedRegoRef = &agentsv1alpha1.RenderedRegoRef{Name: "coding-policy-rego", Key: renderedRegoKey}
+	setCondition(ap, readyCondition(ap, nil))
+	setLintCondition(ap, nil)
+
+	mergeStatus(&latest, &ap.Status)
+
+	if latest.CompiledHash != "abc" || latest.ObservedGeneration != 4 {
+		t.Errorf("expected the owned fields to be copied, got %q %d", latest.CompiledHash, latest.ObservedGeneration)
+	}
+	if !latest.OPAEnabled || latest.LoadedAgentTypes != 2 || latest.RenderedRegoRef.Name != "coding-policy-rego" {
+		t.Errorf("expected the printed fields to be copied, got %+v", latest)
+	}
+	if len(latest.Conditions) != 3 {
+		t.Fatalf("expected 3 conditions, got %+v", latest.Conditions)
+	}
+	if c := meta.FindStatusCondition(latest.Conditions, "Bound"); c == nil || c.Reason != "ClaimsBound" {
+		t.Errorf("expected the Bound condition to be kept, got %+v", c)
+	}
+	ready := meta.FindStatusCondition(latest.Conditions, conditionReady)
+	if !ready.LastTransitionTime.Equal(&past) || ready.ObservedGeneration != 4 {
+		t.Errorf("expected Ready to keep its transition time at generation 4, got %+v", ready)
+	}
+	if !meta.IsStatusConditionTrue(latest.Conditions, conditionRegoLintClean) {
+		t.Error("expected the RegoLintClean condition to be added")
+	}
+}
+
+// TestFallbackConflict tests that the oldest AgentPolicy that sets
+// Fallback holds the designation, whatever the list order, and that the
+// others are rejected with a Conflict.
+func TestFallbackConflict(t *testing.T) {
+	created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	later := metav1.NewTime(created.Add(time.Minute))
+	fallback := func(name string, uid types.UID, at metav1.Time) agentsv1alpha1.AgentPolicy {
+		return agentsv1alpha1.AgentPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "agents", UID: uid, Generation: 1, CreationTimestamp: at},
+			Spec:       agentsv1alpha1.AgentPolicySpec{AgentTypes: []string{name}, Fallback: true},
+		}
+	}
+	baseline := fallback("baseline", "uid-baseline", created)
+	team := fallback("team", "uid-team", later)
+	canary := fallback("baseline-canary", "uid-canary", metav1.NewTime(created.Add(-time.Minute)))
+	canary.Spec.Canary = &agentsv1alpha1.CanarySpec{Stable: "baseline"}
+
+	for _, order := range [][]agentsv1alpha1.AgentPolicy{{baseline, team, canary}, {canary, team, baseline}} {
+		if holder := fallbackHolder(order); holder == nil || holder.UID != baseline.UID {
+			t.Errorf("expected the oldest fallback to hold the designation, got %v", holder)
+		}
+	}
+	deleting := baseline
+	deleting.DeletionTimestamp = &later
+	if holder := fallbackHolder([]agentsv1alpha1.AgentPolicy{deleting, team}); holder == nil || holder.UID != team.UID {
+		t.Errorf("expected the fallback being deleted to hand over the designation, got %v", holder)
+	}
+
+	r := &AgentPolicyReconciler{PolicyEngine: policy.NewEngine()}
+	holder := fallbackHolder([]agentsv1alpha1.AgentPolicy{team, baseline})
+	for _, ap := range []*agentsv1alpha1.AgentPolicy{&baseline, &team} {
+		designated := setFallbackCondition(ap, holder)
+		r.syncFallback(context.Background(), ap, &policy.CompiledPolicy{Name: ap.Name, UID: string(ap.UID)}, designated)
+	}
+	if c := meta.FindStatusCondition(baseline.Status.Conditions, conditionFallback); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected baseline to be designated, got %+v", c)
+	}
+	c := meta.FindStatusCondition(team.Status.Conditions, conditionFallback)
+	if c == nil || c.Status != metav1.ConditionFalse || c.Reason != "Conflict" {
+		t.Errorf("expected team to be rejected with a Conflict, got %+v", c)
+	}
+	if loaded, ok := r.PolicyEngine.FallbackPolicy(); !ok || loaded.UID != string(baseline.UID) {
+		t.Errorf("expected baseline to stay the loaded fallback, got %v", loaded)
+	}
+
+	// Dropping the designation removes the condition, also from the status
+	// patched
+	latest := *team.Status.DeepCopy()
+	team.Spec.Fallback = false
+	setFallbackCondition(&team, nil)
+	mergeStatus(&latest, &team.Status)
+	if meta.FindStatusCondition(latest.Conditions, conditionFallback) != nil {
+		t.Errorf("expected the Fallback condition to be removed, got %+v", latest.Conditions)
+	}
+}
+
+// TestCompileArtifacts tests that a policy is compiled again only when its
+// spec or UID changes, and that a policy already bound is not loaded again.
+func TestCompileArtifacts(t *testing.T) {
+	r := &AgentPolicyReconciler{PolicyEngine: policy.NewEngine()}
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy", Namespace: "agents", UID: "6f1c2a", Generation: 1},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:      []string{"coding-assistant"},
+			DefaultAction:   agentsv1alpha1.DecisionDeny,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow}},
+		},
+	}
+
+	first, err := r.compilePolicy(ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap.Status.CompiledHash, ap.Status.ObservedGeneration = "abc", 1
+	if again, _ := r.compilePolicy(ap); again != first {
+		t.Error("expected a status change to reuse the compiled policy")
+	}
+
+	agent := policy.AgentContext{AgentType: "coding-assistant"}
+	r.loadAgentTypes(context.Background(), ap, first.Policy)
+	r.PolicyEngine.Evaluate(context.Background(), agent, "file.read", nil)
+	statuses := r.loadAgentTypes(context.Background(), ap, first.Policy)
+	if len(statuses) != 1 || !statuses[0].Loaded || statuses[0].Reason != "Loaded" {
+		t.Errorf("expected the policy to stay loaded, got %+v", statuses)
+	}
+	r.PolicyEngine.Evaluate(context.Background(), agent, "file.read", nil)
+	if hits, _, _ := r.PolicyEngine.CacheStats(); hits != 1 {
+		t.Errorf("expected reloading the bound policy to keep its cached decisions, got %d hits", hits)
+	}
+
+	ap.Spec.ToolPermissions[0].Action = agentsv1alpha1.DecisionDeny
+	changed, err := r.compilePolicy(ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Error("expected a spec change to compile the policy again")
+	}
+	ap.UID = "9d3e4b"
+	if recreated, _ := r.compilePolicy(ap); recreated == changed || recreated.Policy.UID != "9d3e4b" {
+		t.Error("expected a recreated policy to be compiled again")
+	}
+
+	r.forgetArtifact(client.ObjectKeyFromObject(ap))
+	if len(r.artifacts) != 0 {
+		t.Errorf("expected the artifact of a deleted policy to be dropped, got %v", r.artifacts)
+	}
+}
+
+// TestReconcileOptions tests that only tuned reconcile options replace
+// controller-runtime's rate limiter.
+func TestReconcileOptions(t *testing.T) {
+	if options := (ReconcileOptions{MaxConcurrentReconciles: 4}).controllerOptions(); options.MaxConcurrentReconciles != 4 || options.RateLimiter != nil {
+		t.Errorf("expected 4 concurrent reconciles with the default rate limiter, got %+v", options)
+	}
+	if options := (ReconcileOptions{QPS: 2}).controllerOptions(); options.RateLimiter == nil {
+		t.Error("expected a tuned rate limiter")
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// AgentPolicyValidator is the validating admission webhook of
+// AgentPolicies. It admits every policy the API server's schema accepts,
+// and warns of the tools its rules and warm-up calls reference that the
+// ToolCatalog does not declare, such as misspelled tools, whose rules no
+// call would match. kubectl prints the warnings on apply.
+type AgentPolicyValidator struct {
+	// Catalog is the catalog of known tools.
+	Catalog *policy.ToolCatalog
+}
+
+var _ admission.CustomValidator = &AgentPolicyValidator{}
+
+// ValidateCreate warns of the unknown tools of a new policy.
+func (v *AgentPolicyValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(obj)
+}
+
+// ValidateUpdate warns of the unknown tools of an updated policy.
+func (v *AgentPolicyValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(newObj)
+}
+
+// ValidateDelete admits deletions.
+func (v *AgentPolicyValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// warnings returns a warning for each unknown tool of a policy.
+func (v *AgentPolicyValidator) warnings(obj runtime.Object) (admission.Warnings, error) {
+	ap, ok := obj.(*agentsv1alpha1.AgentPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected an AgentPolicy, got %T", obj)
+	}
+	return unknownToolWarnings(ap, v.Catalog), nil
+}
+
+// unknownToolWarnings returns a warning for each tool of the rules and
+// warm-up calls of ap that catalog does not declare.
+func unknownToolWarnings(ap *agentsv1alpha1.AgentPolicy, catalog *policy.ToolCatalog) admission.Warnings {
+	var tools []string
+	for _, perm := range ap.Spec.ToolPermissions {
+		tools = append(tools, perm.Tool)
+	}
+	if ap.Spec.Cache != nil {
+		for _, call := range ap.Spec.Cache.Warmup {
+			tools = append(tools, call.Tool)
+		}
+	}
+
+	var warnings admission.Warnings
+	for _, tool := range catalog.Unknown(tools) {
+		warnings = append(warnings, fmt.Sprintf("tool %q is not declared by any ToolCatalog", tool))
+	}
+	return warnings
+}
+
+// SetupWebhookWithManager registers the webhook with the Manager's webhook
+// server, at /validate-agents-sandbox-io-v1alpha1-agentpolicy.
+func (v *AgentPolicyValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&agentsv1alpha1.AgentPolicy{}).
+		WithValidator(v).
+		Complete()
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestAgentPolicyValidator tests that policies referencing tools the
+// catalog does not declare are admitted with warnings.
+func TestAgentPolicyValidator(t *testing.T) {
+	tc := &agentsv1alpha1.ToolCatalog{}
+	tc.Name = "platform"
+	tc.Spec.Tools = []agentsv1alpha1.CatalogTool{
+		{Name: "file.read", RiskLevel: agentsv1alpha1.ToolRiskLow},
+		{Name: "shell.exec", Parameters: &apiextensionsv1.JSON{Raw: []byte(`["not", "a", "schema"]`)}},
+	}
+	set, err := catalogSet(tc)
+	if err == nil || len(set.Tools) != 1 {
+		t.Fatalf("expected the invalid schema to be left out, got %v and %v", set.Tools, err)
+	}
+	catalog := policy.NewToolCatalog()
+	catalog.Replace([]policy.ToolCatalogSet{set})
+
+	ap := &agentsv1alpha1.AgentPolicy{}
+	ap.Spec.ToolPermissions = []agentsv1alpha1.ToolPermission{
+		{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow},
+		{Tool: "file.raed", Action: agentsv1alpha1.DecisionAllow},
+	}
+	ap.Spec.Cache = &agentsv1alpha1.DecisionCacheSpec{Warmup: []agentsv1alpha1.CacheWarmupCall{{Tool: "shell.exec"}}}
+
+	validator := &AgentPolicyValidator{Catalog: catalog}
+	warnings, err := validator.ValidateUpdate(context.Background(), ap, ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || warnings[0] != `tool "file.raed" is not declared by any ToolCatalog` ||
+		warnings[1] != `tool "shell.exec" is not declared by any ToolCatalog` {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	if _, err := validator.ValidateCreate(context.Background(), tc); err == nil {
+		t.Error("expected objects other than AgentPolicies to be rejected")
+	}
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// AgentProfileReconciler reconciles AgentProfile objects, loading the
+// learned behavior baselines into the embedded policy engine. Policies
+// enforce them only if they set spec.profile.
+type AgentProfileReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// PolicyEngine is the embedded policy engine to sync profiles to.
+	PolicyEngine *policy.Engine
+
+	// loaded maps AgentProfile names to the agent type they were loaded
+	// for, so that deletions can be applied
+	mu     sync.Mutex
+	loaded map[string]string
+}
+
+// Reconcile handles AgentProfile create/update/delete events.
+func (r *AgentProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var ap agentsv1alpha1.AgentProfile
+	if err := r.Get(ctx, req.NamespacedName, &ap); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch AgentProfile")
+			return ctrl.Result{}, err
+		}
+		// Profile deleted - remove from engine
+		r.handleDeletion(ctx, req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	profile := ConvertAgentProfile(&ap)
+
+	// A renamed agent type leaves no profile behind for the old one
+	r.mu.Lock()
+	if r.loaded == nil {
+		r.loaded = make(map[string]string)
+	}
+	if previous, ok := r.loaded[ap.Name]; ok && previous != profile.AgentType {
+		r.PolicyEngine.RemoveProfile(previous)
+	}
+	r.loaded[ap.Name] = profile.AgentType
+	r.mu.Unlock()
+
+	r.PolicyEngine.LoadProfile(profile)
+	log.Info("loaded behavior profile", "agentType", profile.AgentType, "profile", ap.Name, "samples", profile.Samples)
+
+	now := metav1.Now()
+	ap.Status.LoadedAt = &now
+	ap.Status.ObservedGeneration = ap.Generation
+	if err := r.Status().Update(ctx, &ap); err != nil {
+		log.Error(err, "failed to update status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// handleDeletion removes the profile loaded from a deleted AgentProfile.
+func (r *AgentProfileReconciler) handleDeletion(ctx context.Context, name string) {
+	r.mu.Lock()
+	agentType, ok := r.loaded[name]
+	delete(r.loaded, name)
+	r.mu.Unlock()
+
+	if ok {
+		r.PolicyEngine.RemoveProfile(agentType)
+		log.FromContext(ctx).Info("removed behavior profile", "agentType", agentType, "profile", name)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch AgentProfile CRDs.
+func (r *AgentProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.AgentProfile{}).
+		Complete(r)
+}
+
+// ConvertAgentProfile converts an AgentProfile CRD to the engine's
+// behavior profile.
+func ConvertAgentProfile(ap *agentsv1alpha1.AgentProfile) *policy.BehaviorProfile {
+	p := &policy.BehaviorProfile{
+		AgentType: ap.Spec.AgentType,
+		Samples:   ap.Spec.Samples,
+		Tools:     make(map[string]*policy.ToolBaseline, len(ap.Spec.Tools)),
+	}
+
+	for _, t := range ap.Spec.Tools {
+		tool := &policy.ToolBaseline{
+			Count:      t.Count,
+			Parameters: make(map[string]*policy.ParameterBaseline, len(t.Parameters)),
+		}
+		for _, pb := range t.Parameters {
+			param := &policy.ParameterBaseline{Count: pb.Count, Unbounded: pb.Unbounded}
+			if len(pb.Values) > 0 {
+				param.Values = make(map[string]int64, len(pb.Values))
+				for _, v := range pb.Values {
+					param.Values[v.Value] = v.Count
+				}
+			}
+			if pb.Min != nil && pb.Max != nil {
+				param.Numeric = true
+				param.Min, param.Max = *pb.Min, *pb.Max
+			}
+			tool.Parameters[pb.Name] = param
+		}
+		p.Tools[t.Tool] = tool
+	}
+	return p
+}
+
+// NewAgentProfile builds an AgentProfile resource from a learned behavior
+// profile, with tools, parameters, and values sorted for stable output.
+func NewAgentProfile(name string, p *policy.BehaviorProfile) *agentsv1alpha1.AgentProfile {
+	ap := &agentsv1alpha1.AgentProfile{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: agentsv1alpha1.GroupVersion.String(),
+			Kind:       "AgentProfile",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: agentsv1alpha1.AgentProfileSpec{
+			AgentType: p.AgentType,
+			Samples:   p.Samples,
+		},
+	}
+
+	for toolName, tool := range p.Tools {
+		tb := agentsv1alpha1.ToolBaseline{Tool: toolName, Count: tool.Count}
+
+		for paramName, param := range tool.Parameters {
+			pb := agentsv1alpha1.ParameterBaseline{Name: paramName, Count: param.Count, Unbounded: param.Unbounded}
+			for value, count := range param.Values {
+				pb.Values = append(pb.Values, agentsv1alpha1.ValueCount{Value: value, Count: count})
+			}
+			sort.Slice(pb.Values, func(i, j int) bool { return pb.Values[i].Value < pb.Values[j].Value })
+			if param.Numeric {
+				lo, hi := param.Min, param.Max
+				pb.Min, pb.Max = &lo, &hi
+			}
+			tb.Parameters = append(tb.Parameters, pb)
+		}
+		sort.Slice(tb.Parameters, func(i, j int) bool { return tb.Parameters[i].Name < tb.Parameters[j].Name })
+
+		ap.Spec.Tools = append(ap.Spec.Tools, tb)
+	}
+	sort.Slice(ap.Spec.Tools, func(i, j int) bool { return ap.Spec.Tools[i].Tool < ap.Spec.Tools[j].Tool })
+	return ap
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	policydiff "github.com/golden-agent/golden-agent/pkg/policy/diff"
+)
+
+// canaryReportInterval is how often a canary is requeued to log the
+// decision counts of its rollout.
+const canaryReportInterval = time.Minute
+
+// canaryStable returns the stable policy a canary AgentPolicy rolls out a
+// new version of, as "namespace/name".
+func canaryStable(ap *agentsv1alpha1.AgentPolicy) string {
+	return types.NamespacedName{Namespace: ap.Namespace, Name: ap.Spec.Canary.Stable}.String()
+}
+
+// loadCanary rolls a canary AgentPolicy out as the new version of its
+// stable policy, replacing the rollouts it had of another stable policy,
+// and logs the decision counts of the rollout so far.
+func (r *AgentPolicyReconciler) loadCanary(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy) {
+	log := log.FromContext(ctx)
+
+	stable := canaryStable(ap)
+	for _, previous := range r.PolicyEngine.RemoveCanaryUID(string(ap.UID)) {
+		if previous != stable {
+			log.Info("removed canary", "stable", previous, "policy", ap.Name)
+		}
+	}
+	if current, _, ok := r.PolicyEngine.Canary(stable); ok && current.UID != string(ap.UID) {
+		log.Info("replacing canary", "stable", stable, "previous", policyRef(current), "policy", ap.Name)
+	}
+	r.PolicyEngine.LoadCanary(stable, compiled, int(ap.Spec.Canary.Percent))
+	log.Info("loaded canary", "stable", stable, "policy", ap.Name, "percent", ap.Spec.Canary.Percent)
+
+	if stats, ok := r.PolicyEngine.CanaryStats(stable); ok {
+		log.Info("canary decisions", "stable", stable, "policy", ap.Name, "percent", stats.Percent,
+			"stableAllowed", stats.StableAllowed, "stableDenied", stats.StableDenied,
+			"canaryAllowed", stats.CanaryAllowed, "canaryDenied", stats.CanaryDenied)
+	}
+}
+
+// removeCanary ends the rollouts of an AgentPolicy that is no longer a
+// canary.
+func (r *AgentPolicyReconciler) removeCanary(ctx context.Context, ap *agentsv1alpha1.AgentPolicy) {
+	log := log.FromContext(ctx)
+
+	for _, stable := range r.PolicyEngine.RemoveCanaryUID(string(ap.UID)) {
+		log.Info("removed canary", "stable", stable, "policy", ap.Name)
+	}
+}
+
+// canaryChangeSummary describes how a canary differs from the version of
+// it currently rolled out or, when it is not rolled out yet, from its
+// stable policy. Returns "" when nothing changed.
+func (r *AgentPolicyReconciler) canaryChangeSummary(ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy) string {
+	stable := canaryStable(ap)
+	if previous, _, ok := r.PolicyEngine.Canary(stable); ok && previous.UID == string(ap.UID) {
+		d := policydiff.Compare(previous, compiled)
+		if d.Empty() {
+			return ""
+		}
+		return d.Summary()
+	}
+
+	var current *policy.CompiledPolicy
+	for _, agentType := range r.PolicyEngine.ListPolicies() {
+		if loaded, ok := r.PolicyEngine.GetPolicy(agentType); ok && policyRef(loaded) == stable {
+			current = loaded
+			break
+		}
+	}
+	if current == nil {
+		if ap.Status.LastChangeSummary != "" {
+			return ""
+		}
+		return "canary of " + stable + ": " + policydiff.Compare(nil, compiled).Summary()
+	}
+	return "canary of " + stable + ": " + policydiff.Compare(current, compiled).Summary()
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// GeneratePolicy synthesizes the tightest AgentPolicy that allows the
+// behavior in a learned profile: default deny, one allow rule per tool
+// observed, and constraints limited to the path prefixes, domains, ports,
+// and sizes actually used. This closes the observe -> generate -> enforce
+// loop for traffic recorded in permissive mode.
+//
+// Parameters too varied to pin down (see ParameterBaseline.Unbounded) are
+// left unconstrained; the returned warnings name them for review.
+func GeneratePolicy(name string, p *policy.BehaviorProfile, mode agentsv1alpha1.EnforcementMode) (*agentsv1alpha1.AgentPolicy, []string) {
+	ap := &agentsv1alpha1.AgentPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: agentsv1alpha1.GroupVersion.String(),
+			Kind:       "AgentPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{p.AgentType},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Mode:          mode,
+		},
+	}
+
+	tools := make([]string, 0, len(p.Tools))
+	for tool := range p.Tools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	var warnings []string
+	for _, tool := range tools {
+		constraints, unbounded := generateConstraints(p.Tools[tool])
+		for _, param := range unbounded {
+			warnings = append(warnings, fmt.Sprintf("%s: %s values too varied to constrain", tool, param))
+		}
+		ap.Spec.ToolPermissions = append(ap.Spec.ToolPermissions, agentsv1alpha1.ToolPermission{
+			Tool:        tool,
+			Action:      agentsv1alpha1.DecisionAllow,
+			Constraints: constraints,
+		})
+	}
+	return ap, warnings
+}
+
+// generateConstraints derives constraints from the parameters of a tool
+// baseline. Returns nil constraints if none apply, and the constrainable
+// parameters that were left open because they were unbounded.
+func generateConstraints(tool *policy.ToolBaseline) (*agentsv1alpha1.ToolConstraints, []string) {
+	c := &agentsv1alpha1.ToolConstraints{}
+	var unbounded []string
+
+	if param, ok := tool.Parameters["path"]; ok {
+		if param.Unbounded {
+			unbounded = append(unbounded, "path")
+		} else {
+			c.PathPatterns = pathPrefixes(param.Values)
+		}
+	}
+
+	if param, ok := tool.Parameters["domain"]; ok {
+		if param.Unbounded {
+			unbounded = append(unbounded, "domain")
+		} else {
+			c.AllowedDomains = sortedValues(param.Values)
+		}
+	}
+
+	if param, ok := tool.Parameters["port"]; ok {
+		if param.Unbounded {
+			unbounded = append(unbounded, "port")
+		}
+		for _, v := range sortedValues(param.Values) {
+			if port, err := strconv.ParseInt(v, 10, 32); err == nil {
+				c.AllowedPorts = append(c.AllowedPorts, int32(port))
+			}
+		}
+		sort.Slice(c.AllowedPorts, func(i, j int) bool { return c.AllowedPorts[i] < c.AllowedPorts[j] })
+	}
+
+	if param, ok := tool.Parameters["size"]; ok && param.Numeric && param.Max > 0 {
+		limit := param.Max
+		c.MaxSizeBytes = &limit
+	}
+
+	if len(c.PathPatterns) == 0 && len(c.AllowedDomains) == 0 && len(c.AllowedPorts) == 0 && c.MaxSizeBytes == nil {
+		return nil, unbounded
+	}
+	return c, unbounded
+}
+
+// pathPrefixes converts the directories a tool used into "dir/**" patterns,
+// dropping directories already covered by an ancestor.
+func pathPrefixes(dirs map[string]int64) []string {
+	var prefixes []string
+	for _, dir := range sortedValues(dirs) {
+		covered := false
+		for _, p := range prefixes {
+			if p == "/" || dir == p || strings.HasPrefix(dir, p+"/") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			prefixes = append(prefixes, dir)
+		}
+	}
+
+	patterns := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		patterns[i] = strings.TrimSuffix(p, "/") + "/**"
+	}
+	return patterns
+}
+
+// sortedValues returns the observed values of a parameter, sorted.
+func sortedValues(values map[string]int64) []string {
+	sorted := make([]string, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
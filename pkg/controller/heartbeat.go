@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+const (
+	// heartbeatLabel selects the heartbeat Leases of a router deployment;
+	// its value is the heartbeat name
+	heartbeatLabel = "agents.sandbox.io/heartbeat"
+
+	// loadedPoliciesAnnotation holds the policies a replica has loaded, as
+	// a JSON object of "namespace/name" to generation
+	loadedPoliciesAnnotation = "agents.sandbox.io/loaded-policies"
+)
+
+// ReplicaHeartbeat reports the policies loaded by a router replica through
+// a coordination.k8s.io Lease, one per replica,
+//
+//	agent-router-7d9f8-x2x4q:
+//	  labels:      agents.sandbox.io/heartbeat: agent-router
+//	  annotations: agents.sandbox.io/loaded-policies: {"default/coding-assistant-policy": 3}
+//
+// Each replica renews its Lease every Interval and immediately after
+// loading a policy; Leases not renewed within three intervals are treated
+// as replicas that have gone away. The AgentPolicyReconciler reads the
+// Leases to record in each policy's status which replicas loaded it.
+//
+// Ack only records the load; the heartbeat writes once it has been added
+// to a manager with SetupWithManager.
+type ReplicaHeartbeat struct {
+	// Interval is how often the Lease is renewed (default: 10s)
+	Interval time.Duration
+
+	namespace string
+	name      string
+	identity  string
+
+	client client.Client
+	reader client.Reader
+
+	mu     sync.Mutex
+	loaded map[string]int64 // "namespace/name" -> loaded generation
+	wake   chan struct{}
+}
+
+// NewReplicaHeartbeat creates the heartbeat of one replica. Leases are
+// created in namespace, labeled with name, and named after the identity,
+// which must be unique among the replicas (e.g., the pod name).
+func NewReplicaHeartbeat(namespace, name, identity string) *ReplicaHeartbeat {
+	return &ReplicaHeartbeat{
+		Interval:  10 * time.Second,
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		loaded:    make(map[string]int64),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Identity returns the identity of this replica.
+func (h *ReplicaHeartbeat) Identity() string {
+	return h.identity
+}
+
+// Ack records that this replica loaded a generation of a policy.
+func (h *ReplicaHeartbeat) Ack(key client.ObjectKey, generation int64) {
+	h.mu.Lock()
+	h.loaded[key.String()] = generation
+	h.mu.Unlock()
+	h.notify()
+}
+
+// Forget records that this replica no longer has a policy loaded.
+func (h *ReplicaHeartbeat) Forget(key client.ObjectKey) {
+	h.mu.Lock()
+	delete(h.loaded, key.String())
+	h.mu.Unlock()
+	h.notify()
+}
+
+func (h *ReplicaHeartbeat) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+		// Renewal already pending
+	}
+}
+
+// SetupWithManager adds the heartbeat to the manager, which runs it on
+// every replica. Leases are read directly from the API server, so the
+// router needs Lease access in the heartbeat namespace only.
+func (h *ReplicaHeartbeat) SetupWithManager(mgr ctrl.Manager) error {
+	h.client = mgr.GetClient()
+	h.reader = mgr.GetAPIReader()
+	return mgr.Add(h)
+}
+
+// NeedLeaderElection returns false: every replica reports its own loads.
+func (h *ReplicaHeartbeat) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the Lease until ctx is done.
+func (h *ReplicaHeartbeat) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("lease", h.namespace+"/"+h.identity)
+
+	ticker := time.NewTicker(h.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := h.renew(ctx); err != nil {
+			log.Error(err, "failed to renew heartbeat lease")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *ReplicaHeartbeat) interval() time.Duration {
+	if h.Interval <= 0 {
+		return 10 * time.Second
+	}
+	return h.Interval
+}
+
+// renew writes the loaded policies and the renewal time to the Lease,
+// creating it if needed.
+func (h *ReplicaHeartbeat) renew(ctx context.Context) error {
+	h.mu.Lock()
+	loaded, err := json.Marshal(h.loaded)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var lease coordinationv1.Lease
+	err = h.reader.Get(ctx, client.ObjectKey{Namespace: h.namespace, Name: h.identity}, &lease)
+	if apierrors.IsNotFound(err) {
+		lease = coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: h.namespace, Name: h.identity}}
+	} else if err != nil {
+		return err
+	}
+
+	if lease.Labels == nil {
+		lease.Labels = make(map[string]string, 1)
+	}
+	lease.Labels[heartbeatLabel] = h.name
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string, 1)
+	}
+	lease.Annotations[loadedPoliciesAnnotation] = string(loaded)
+
+	duration := int32(3 * h.interval() / time.Second)
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.HolderIdentity = &h.identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &now
+
+	if lease.ResourceVersion == "" {
+		return h.client.Create(ctx, &lease)
+	}
+	return h.client.Update(ctx, &lease)
+}
+
+// Replicas returns the live replicas that have loaded the policy, sorted
+// by identity, and whether every live replica has loaded the given
+// generation. This replica's own loads are taken from memory, so they are
+// reported before its Lease is next renewed.
+func (h *ReplicaHeartbeat) Replicas(ctx context.Context, key client.ObjectKey, generation int64) ([]agentsv1alpha1.ReplicaStatus, bool, error) {
+	var leases coordinationv1.LeaseList
+	if err := h.reader.List(ctx, &leases, client.InNamespace(h.namespace), client.MatchingLabels{heartbeatLabel: h.name}); err != nil {
+		return nil, false, fmt.Errorf("listing heartbeat leases: %w", err)
+	}
+
+	now := time.Now()
+	policyKey := key.String()
+	converged := true
+	var replicas []agentsv1alpha1.ReplicaStatus
+
+	h.mu.Lock()
+	own, ownLoaded := h.loaded[policyKey]
+	h.mu.Unlock()
+	if ownLoaded {
+		replicas = append(replicas, agentsv1alpha1.ReplicaStatus{Identity: h.identity, ObservedGeneration: own})
+		converged = own == generation
+	}
+
+	for _, lease := range leases.Items {
+		if lease.Name == h.identity || !leaseLive(&lease, now) {
+			continue
+		}
+
+		var loaded map[string]int64
+		_ = json.Unmarshal([]byte(lease.Annotations[loadedPoliciesAnnotation]), &loaded)
+		observed, ok := loaded[policyKey]
+		if !ok || observed != generation {
+			converged = false
+		}
+		if ok {
+			replicas = append(replicas, agentsv1alpha1.ReplicaStatus{Identity: lease.Name, ObservedGeneration: observed})
+		}
+	}
+
+	sort.Slice(replicas, func(i, j int) bool {
+		return replicas[i].Identity < replicas[j].Identity
+	})
+	return replicas, converged, nil
+}
+
+// leaseLive reports whether a Lease was renewed within its duration.
+func leaseLive(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// ImpactCheckConfig configures the pre-activation impact check.
+// When a policy changes, the controller replays recorded audit traffic
+// against the new version before loading it into the engine.
+type ImpactCheckConfig struct {
+	// AuditLogPath is the JSON audit log to replay (FileAuditSink "json" format)
+	AuditLogPath string
+
+	// Window is how far back to replay (0 replays the whole log)
+	Window time.Duration
+
+	// MaxNewlyDenied is the number of previously allowed calls the new
+	// version may deny before activation is blocked. Negative values only
+	// report the impact and never block.
+	MaxNewlyDenied int
+}
+
+// impactCheckError reports a policy change blocked by the impact check.
+// The previously loaded version stays active.
+type impactCheckError struct {
+	report *replay.Report
+	limit  int
+}
+
+func (e *impactCheckError) Error() string {
+	return fmt.Sprintf("activation blocked: %s (limit %d); previous version remains active",
+		e.report.Summary(), e.limit)
+}
+
+// checkImpact replays recorded traffic against a changed policy, records
+// the result as the ImpactAnalyzed condition, and returns an
+// *impactCheckError if the change exceeds the configured limit.
+func (r *AgentPolicyReconciler) checkImpact(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, compiled *policy.CompiledPolicy) error {
+	cfg := r.ImpactCheck
+
+	condition := metav1.Condition{
+		Type:               conditionImpactAnalyzed,
+		ObservedGeneration: ap.Generation,
+	}
+
+	report, err := replayAuditLog(ctx, cfg, compiled)
+	if err != nil {
+		// A missing or unreadable log must not block policy updates
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReplayFailed"
+		condition.Message = err.Error()
+		setCondition(ap, condition)
+		return nil
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = "TrafficReplayed"
+	condition.Message = report.Summary()
+	setCondition(ap, condition)
+
+	if cfg.MaxNewlyDenied >= 0 && report.NewlyDenied > cfg.MaxNewlyDenied {
+		return &impactCheckError{report: report, limit: cfg.MaxNewlyDenied}
+	}
+	return nil
+}
+
+// replayAuditLog reads the configured window of the audit log and replays it.
+func replayAuditLog(ctx context.Context, cfg *ImpactCheckConfig, compiled *policy.CompiledPolicy) (*replay.Report, error) {
+	f, err := os.Open(cfg.AuditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var window replay.Window
+	if cfg.Window > 0 {
+		window.Since = time.Now().Add(-cfg.Window)
+	}
+
+	events, _, err := replay.ReadEvents(f, window)
+	if err != nil {
+		return nil, err
+	}
+	return replay.Run(ctx, events, compiled)
+}
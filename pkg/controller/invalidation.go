@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// invalidateAllKey is the ConfigMap key of invalidations that clear the
+// entire cache: patterns, the fallback policy, and agent types that are not
+// valid ConfigMap keys.
+const invalidateAllKey = "_all"
+
+// configMapKeyPattern matches valid ConfigMap data keys.
+var configMapKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// ConfigMapInvalidationBus is a policy.InvalidationBus shared by router
+// replicas through a ConfigMap. Each agent type has a data entry holding a
+// generation counter and the origin of its last bump,
+//
+//	coding-assistant: "42/3f9a0c1d2b4e5f60"
+//
+// Publishing bumps the generation; every replica polls the ConfigMap and
+// invalidates the agent types whose generation changed. With the manager's
+// client, polls are served from its informer cache, so they cost no API
+// calls between changes.
+//
+// Publish only queues the invalidation; the bus writes and polls once it
+// has been added to a manager with SetupWithManager.
+type ConfigMapInvalidationBus struct {
+	// PollInterval is how often the ConfigMap is checked for remote
+	// invalidations (default: 2s)
+	PollInterval time.Duration
+
+	key    client.ObjectKey
+	client client.Client
+
+	mu       sync.Mutex
+	pending  map[string]string // ConfigMap key -> origin of the last publish
+	handlers map[int]func(policy.Invalidation)
+	next     int
+	wake     chan struct{}
+
+	// seen is the last generation observed per ConfigMap key; it is only
+	// used by the Start goroutine
+	seen   map[string]int64
+	primed bool
+}
+
+// NewConfigMapInvalidationBus creates a bus backed by the named ConfigMap,
+// which is created on first publish if it does not exist.
+func NewConfigMapInvalidationBus(namespace, name string) *ConfigMapInvalidationBus {
+	return &ConfigMapInvalidationBus{
+		PollInterval: 2 * time.Second,
+		key:          client.ObjectKey{Namespace: namespace, Name: name},
+		pending:      make(map[string]string),
+		handlers:     make(map[int]func(policy.Invalidation)),
+		wake:         make(chan struct{}, 1),
+		seen:         make(map[string]int64),
+	}
+}
+
+// Publish queues an invalidation for the next write to the ConfigMap.
+// Invalidations of the same agent type are coalesced.
+func (b *ConfigMapInvalidationBus) Publish(ctx context.Context, inv policy.Invalidation) error {
+	b.mu.Lock()
+	b.pending[invalidationKey(inv.AgentType)] = inv.Origin
+	b.mu.Unlock()
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+		// Write already pending
+	}
+	return nil
+}
+
+// Subscribe registers a handler for invalidations read from the ConfigMap.
+func (b *ConfigMapInvalidationBus) Subscribe(handler func(policy.Invalidation)) func() {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.handlers, id)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// SetupWithManager adds the bus to the manager, which runs it on every
+// replica with the manager's client.
+func (b *ConfigMapInvalidationBus) SetupWithManager(mgr ctrl.Manager) error {
+	b.client = mgr.GetClient()
+	return mgr.Add(b)
+}
+
+// NeedLeaderElection returns false: every replica must receive
+// invalidations, not just the leader.
+func (b *ConfigMapInvalidationBus) NeedLeaderElection() bool {
+	return false
+}
+
+// Start writes queued invalidations and polls for remote ones until ctx
+// is done.
+func (b *ConfigMapInvalidationBus) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("configMap", b.key.String())
+
+	interval := b.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.flush(ctx); err != nil {
+			log.Error(err, "failed to publish cache invalidations")
+		}
+		if err := b.poll(ctx); err != nil {
+			log.Error(err, "failed to read cache invalidations")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-b.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush bumps the generation of every queued key in a single update,
+// retrying on conflicts with other replicas. Keys stay queued on failure.
+func (b *ConfigMapInvalidationBus) flush(ctx context.Context) error {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string]string)
+	b.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if err = b.bump(ctx, pending); err == nil || !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			break
+		}
+	}
+	if err != nil {
+		// Requeue, without overwriting newer publishes
+		b.mu.Lock()
+		for key, origin := range pending {
+			if _, ok := b.pending[key]; !ok {
+				b.pending[key] = origin
+			}
+		}
+		b.mu.Unlock()
+	}
+	return err
+}
+
+// bump increments the generations of the keys in the ConfigMap.
+func (b *ConfigMapInvalidationBus) bump(ctx context.Context, keys map[string]string) error {
+	var cm corev1.ConfigMap
+	err := b.client.Get(ctx, b.key, &cm)
+	if apierrors.IsNotFound(err) {
+		cm = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: b.key.Namespace, Name: b.key.Name}}
+	} else if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, len(keys))
+	}
+	for key, origin := range keys {
+		generation, _ := parseInvalidation(cm.Data[key])
+		cm.Data[key] = fmt.Sprintf("%d/%s", generation+1, origin)
+	}
+
+	if cm.ResourceVersion == "" {
+		return b.client.Create(ctx, &cm)
+	}
+	return b.client.Update(ctx, &cm)
+}
+
+// poll reads the ConfigMap and delivers an invalidation for every key
+// whose generation changed since the last poll. The first poll only
+// records the current generations.
+func (b *ConfigMapInvalidationBus) poll(ctx context.Context) error {
+	var cm corev1.ConfigMap
+	if err := b.client.Get(ctx, b.key, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			b.primed = true
+			return nil
+		}
+		return err
+	}
+
+	var changed []policy.Invalidation
+	for key, value := range cm.Data {
+		generation, origin := parseInvalidation(value)
+		last, ok := b.seen[key]
+		if ok && generation == last {
+			continue
+		}
+		b.seen[key] = generation
+		if !b.primed {
+			continue
+		}
+		// The origin is only known if this is the sole bump since the
+		// last poll; otherwise another replica's may have been missed
+		if generation != last+1 {
+			origin = ""
+		}
+		changed = append(changed, policy.Invalidation{AgentType: invalidationAgentType(key), Origin: origin})
+	}
+	b.primed = true
+
+	if len(changed) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	handlers := make([]func(policy.Invalidation), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+
+	for _, inv := range changed {
+		for _, h := range handlers {
+			h(inv)
+		}
+	}
+	return nil
+}
+
+// invalidationKey returns the ConfigMap key for an agent type.
+func invalidationKey(agentType string) string {
+	if policy.IsAgentTypePattern(agentType) || agentType == invalidateAllKey || !configMapKeyPattern.MatchString(agentType) {
+		return invalidateAllKey
+	}
+	return agentType
+}
+
+// invalidationAgentType returns the agent type of a ConfigMap key.
+func invalidationAgentType(key string) string {
+	if key == invalidateAllKey {
+		return policy.FallbackAgentType
+	}
+	return key
+}
+
+// parseInvalidation parses a "<generation>/<origin>" ConfigMap value.
+// Malformed values have generation 0.
+func parseInvalidation(value string) (int64, string) {
+	genStr, origin, _ := strings.Cut(value, "/")
+	generation, err := strconv.ParseInt(genStr, 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	return generation, origin
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// ConfigMapLabelAllocator allocates tenants MTS labels of their own (see
+// policy.LabelAllocator) and persists them in a ConfigMap, one data entry
+// per tenant:
+//
+//	team-a: "s0:c17,c902"
+//
+// Every allocation rereads the ConfigMap and writes it back with optimistic
+// concurrency, so router replicas sharing it never allocate one label
+// twice, and tenants keep their labels across restarts. Labels found held
+// by several tenants are reallocated to all but one of them.
+type ConfigMapLabelAllocator struct {
+	// WarnThreshold is the share of the label space allocated beyond which
+	// allocations log a warning (default: policy.DefaultLabelWarnThreshold)
+	WarnThreshold float64
+
+	key    client.ObjectKey
+	logger *slog.Logger
+}
+
+// NewConfigMapLabelAllocator creates an allocator backed by the named
+// ConfigMap, which is created on the first allocation if it does not exist.
+// Collisions and exhaustion warnings are logged to logger (slog.Default if
+// nil).
+func NewConfigMapLabelAllocator(namespace, name string, logger *slog.Logger) *ConfigMapLabelAllocator {
+	return &ConfigMapLabelAllocator{
+		WarnThreshold: policy.DefaultLabelWarnThreshold,
+		key:           client.ObjectKey{Namespace: namespace, Name: name},
+		logger:        logger,
+	}
+}
+
+// Allocate returns the label of a tenant, allocating and persisting one if
+// the ConfigMap holds none for it. Tenant IDs must be valid ConfigMap keys.
+func (a *ConfigMapLabelAllocator) Allocate(ctx context.Context, c client.Client, tenantID string) (string, error) {
+	if !configMapKeyPattern.MatchString(tenantID) {
+		return "", fmt.Errorf("tenant %q is not a valid ConfigMap key", tenantID)
+	}
+
+	var label string
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cm corev1.ConfigMap
+		err := c.Get(ctx, a.key, &cm)
+		if apierrors.IsNotFound(err) {
+			cm = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: a.key.Namespace, Name: a.key.Name}}
+		} else if err != nil {
+			return err
+		}
+
+		alloc := policy.NewLabelAllocator(a.logger)
+		alloc.WarnThreshold = a.WarnThreshold
+		alloc.Load(cm.Data)
+
+		// A tenant whose stored label another holds is allocated a new one
+		if label, err = alloc.Allocate(tenantID); err != nil || cm.Data[tenantID] == label {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[tenantID] = label
+		if cm.ResourceVersion == "" {
+			err = c.Create(ctx, &cm)
+			if apierrors.IsAlreadyExists(err) {
+				// Created by another replica: retry against its copy
+				return apierrors.NewConflict(corev1.Resource("configmaps"), a.key.Name, err)
+			}
+			return err
+		}
+		return c.Update(ctx, &cm)
+	})
+	if err != nil {
+		return "", err
+	}
+	return label, nil
+}
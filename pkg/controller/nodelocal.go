@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// SandboxClaimNodeField indexes SandboxClaims by the node their sandbox is
+// scheduled on (spec.nodeName), for node-local routers to list the claims
+// of their node by (see IndexSandboxClaimNodes).
+const SandboxClaimNodeField = "spec.nodeName"
+
+// IndexSandboxClaimNodes registers the SandboxClaimNodeField index of
+// SandboxClaims with the manager's cache. Node-local routers call it once,
+// before setting up the controllers that list claims by it.
+func IndexSandboxClaimNodes(ctx context.Context, mgr ctrl.Manager) error {
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(SandboxClaimGVK)
+	return mgr.GetFieldIndexer().IndexField(ctx, claim, SandboxClaimNodeField, func(obj client.Object) []string {
+		if node := claimNode(obj); node != "" {
+			return []string{node}
+		}
+		return nil
+	})
+}
+
+// claimNode returns the node the sandbox of a SandboxClaim is scheduled
+// on, or "" if it is not scheduled yet.
+func claimNode(obj client.Object) string {
+	claim, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return ""
+	}
+	node, _, _ := unstructured.NestedString(claim.Object, "spec", "nodeName")
+	return node
+}
+
+// onNode filters the events of SandboxClaims to those of claims scheduled
+// on node, including claims that move on or off it.
+func onNode(node string) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return claimNode(e.Object) == node },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return claimNode(e.ObjectOld) == node || claimNode(e.ObjectNew) == node
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return claimNode(e.Object) == node },
+		GenericFunc: func(e event.GenericEvent) bool { return claimNode(e.Object) == node },
+	}
+}
+
+// claimsReference reports whether any of claims references the AgentPolicy
+// namespace/name. Claims whose policyRef is invalid reference none.
+func claimsReference(claims []unstructured.Unstructured, namespace, name string) bool {
+	for i := range claims {
+		_, ref, err := sandboxContext(&claims[i])
+		if err == nil && ref.Namespace == namespace && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// usedOnNode reports whether an AgentPolicy applies to the sandboxes of
+// r.NodeName: it is the fallback, or a SandboxClaim on the node references
+// it. A canary is used where its stable policy is.
+func (r *AgentPolicyReconciler) usedOnNode(ctx context.Context, ap *agentsv1alpha1.AgentPolicy) (bool, error) {
+	if isFallback(ap) {
+		return true, nil
+	}
+	name := ap.Name
+	if ap.Spec.Canary != nil {
+		name = ap.Spec.Canary.Stable
+		var stable agentsv1alpha1.AgentPolicy
+		err := r.Get(ctx, types.NamespacedName{Namespace: ap.Namespace, Name: name}, &stable)
+		if client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		if err == nil && isFallback(&stable) {
+			return true, nil
+		}
+	}
+
+	claims := &unstructured.UnstructuredList{}
+	claims.SetGroupVersionKind(SandboxClaimGVK.GroupVersion().WithKind(SandboxClaimGVK.Kind + "List"))
+	if err := r.List(ctx, claims, client.MatchingFields{SandboxClaimNodeField: r.NodeName}); err != nil {
+		return false, err
+	}
+	return claimsReference(claims.Items, ap.Namespace, name), nil
+}
+
+// policiesOfClaim maps a SandboxClaim to the AgentPolicy it references and
+// the canaries of that policy, so that claims scheduled on or off the node
+// load or unload them.
+func (r *AgentPolicyReconciler) policiesOfClaim(ctx context.Context, obj client.Object) []reconcile.Request {
+	claim, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	_, ref, err := sandboxContext(claim)
+	if err != nil || ref.Name == "" {
+		return nil
+	}
+
+	requests := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}}}
+	var policies agentsv1alpha1.AgentPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(ref.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list canaries of claimed AgentPolicy", "policy", ref.Namespace+"/"+ref.Name)
+		return requests
+	}
+	for i := range policies.Items {
+		if canary := policies.Items[i].Spec.Canary; canary != nil && canary.Stable == ref.Name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+		}
+	}
+	return requests
+}
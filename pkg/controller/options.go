@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// ReconcileOptions tune how fast the AgentPolicy controller works through
+// its queue. After a restart every AgentPolicy is queued at once; with
+// thousands of them, the limits keep the controller from compiling them
+// all at once and starving the router of CPU. Zero values keep
+// controller-runtime's defaults.
+type ReconcileOptions struct {
+	// MaxConcurrentReconciles is how many AgentPolicies are reconciled
+	// at once (default: 1)
+	MaxConcurrentReconciles int
+
+	// BaseDelay and MaxDelay bound the exponential backoff of a policy
+	// whose reconcile failed, which doubles on each failure
+	// (default: 5ms and 1000s)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// QPS and Burst limit the rate of reconciles of all policies
+	// (default: 10 and 100)
+	QPS   float64
+	Burst int
+}
+
+// controllerOptions returns the controller-runtime options of o.
+func (o ReconcileOptions) controllerOptions() crcontroller.Options {
+	options := crcontroller.Options{MaxConcurrentReconciles: o.MaxConcurrentReconciles}
+	if o.BaseDelay == 0 && o.MaxDelay == 0 && o.QPS == 0 && o.Burst == 0 {
+		return options
+	}
+
+	baseDelay, maxDelay := o.BaseDelay, o.MaxDelay
+	if baseDelay <= 0 {
+		baseDelay = 5 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 1000 * time.Second
+	}
+	qps, burst := o.QPS, o.Burst
+	if qps <= 0 {
+		qps = 10
+	}
+	if burst <= 0 {
+		burst = 100
+	}
+	options.RateLimiter = workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+	return options
+}
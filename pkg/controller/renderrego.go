@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+const (
+	// renderedRegoKey is the ConfigMap key of a policy's rendered Rego, and
+	// previousRegoKey that of the Rego it replaced
+	renderedRegoKey = "policy.rego"
+	previousRegoKey = "previous.rego"
+
+	// renderedPolicyLabel selects the rendered Rego ConfigMaps; its value
+	// is the name of the policy
+	renderedPolicyLabel = "agents.sandbox.io/rendered-policy"
+
+	// renderedGenerationAnnotation and renderedHashAnnotation record the
+	// policy generation and the status.compiledHash of the rendered Rego
+	renderedGenerationAnnotation = "agents.sandbox.io/generation"
+	renderedHashAnnotation       = "agents.sandbox.io/compiled-hash"
+)
+
+// renderRego writes the Rego generated for an AgentPolicy to the ConfigMap
+// "<name>-rego" in its namespace, and returns a reference to it:
+//
+//	coding-assistant-policy-rego:
+//	  labels:      agents.sandbox.io/rendered-policy: coding-assistant-policy
+//	  annotations: agents.sandbox.io/generation: "3"
+//	               agents.sandbox.io/compiled-hash: 9f2c4e1ab03d5c77
+//	  data:        policy.rego: <the Rego evaluated>
+//	               previous.rego: <the Rego it replaced>
+//
+// Security reviewers granted read access to the ConfigMaps of a namespace
+// can so read, and diff, the code the routers evaluate, without access to
+// the routers. The ConfigMap is owned by the policy, so it is deleted with
+// it, and only written when the Rego changed, since every replica renders
+// it.
+func (r *AgentPolicyReconciler) renderRego(ctx context.Context, ap *agentsv1alpha1.AgentPolicy, module, hash string) (*agentsv1alpha1.RenderedRegoRef, error) {
+	key := client.ObjectKey{Namespace: ap.Namespace, Name: ap.Name + "-rego"}
+	controller := true
+	owner := metav1.OwnerReference{
+		APIVersion: agentsv1alpha1.GroupVersion.String(),
+		Kind:       "AgentPolicy",
+		Name:       ap.Name,
+		UID:        ap.UID,
+		Controller: &controller,
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Read uncached, so the router needs no access to every ConfigMap
+		var cm corev1.ConfigMap
+		err := r.reader().Get(ctx, key, &cm)
+		if apierrors.IsNotFound(err) {
+			cm = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+		} else if err != nil {
+			return err
+		} else if cm.Labels[renderedPolicyLabel] != ap.Name {
+			return fmt.Errorf("ConfigMap %s exists and does not hold the Rego of policy %s", key, ap.Name)
+		}
+		current := cm.Data[renderedRegoKey]
+		if current == module && len(cm.OwnerReferences) == 1 && cm.OwnerReferences[0].UID == ap.UID {
+			return nil
+		}
+
+		data := map[string]string{renderedRegoKey: module}
+		if current != "" && current != module {
+			data[previousRegoKey] = current
+		} else if previous, ok := cm.Data[previousRegoKey]; ok {
+			data[previousRegoKey] = previous
+		}
+		cm.Data = data
+		cm.OwnerReferences = []metav1.OwnerReference{owner}
+		if cm.Labels == nil {
+			cm.Labels = make(map[string]string, 1)
+		}
+		cm.Labels[renderedPolicyLabel] = ap.Name
+		if cm.Annotations == nil {
+			cm.Annotations = make(map[string]string, 2)
+		}
+		cm.Annotations[renderedGenerationAnnotation] = strconv.FormatInt(ap.Generation, 10)
+		cm.Annotations[renderedHashAnnotation] = hash
+
+		if cm.ResourceVersion == "" {
+			err = r.Create(ctx, &cm)
+			if apierrors.IsAlreadyExists(err) {
+				// Created by another replica: retry against its copy
+				return apierrors.NewConflict(corev1.Resource("configmaps"), key.Name, err)
+			}
+			return err
+		}
+		return r.Update(ctx, &cm)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &agentsv1alpha1.RenderedRegoRef{Name: key.Name, Key: renderedRegoKey}, nil
+}
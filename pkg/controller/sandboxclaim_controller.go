@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// SandboxClaimGVK is the kind of the SandboxClaims the
+// SandboxClaimReconciler watches. SandboxClaims are defined by the sandbox
+// controller, not by this module, so they are read as unstructured objects.
+var SandboxClaimGVK = schema.GroupVersionKind{
+	Group:   agentsv1alpha1.GroupVersion.Group,
+	Version: agentsv1alpha1.GroupVersion.Version,
+	Kind:    "SandboxClaim",
+}
+
+// SandboxClaimReconciler reconciles SandboxClaim objects, loading the
+// authoritative context of each claimed sandbox into the embedded policy
+// engine, so that calls from the sandbox are cross-checked against its
+// claim instead of trusting their metadata:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: SandboxClaim
+//	metadata:
+//	  name: sandbox-1
+//	  namespace: team-a
+//	spec:
+//	  policyRef: {name: coding-assistant-policy}
+//	  tenantID: team-a
+//
+// The sandbox ID is the claim's name. The tenant defaults to the claim's
+// namespace, the policy's namespace to the claim's, and the MTS label is
+// the referenced AgentPolicy's, if it sets one, or else the label the
+// LabelAllocator, if set, allocated to the tenant. Claims with an MTS label
+// also record it as their tenant's in the TenantRegistry, if set.
+type SandboxClaimReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// PolicyEngine is the embedded policy engine to sync sandbox contexts to.
+	PolicyEngine *policy.Engine
+
+	// TenantRegistry, when set, is the server-side authority for the MTS
+	// labels of tenants, filled from their claims (optional).
+	TenantRegistry *policy.TenantRegistry
+
+	// LabelAllocator, when set, allocates the tenants of claims whose
+	// policy sets no MTS label a label of their own (optional).
+	LabelAllocator *ConfigMapLabelAllocator
+
+	// NodeName, when set, loads only the claims of sandboxes scheduled on
+	// the node, for node-local routers; claims that move off it are
+	// unloaded (optional).
+	NodeName string
+
+	// loaded maps SandboxClaims to the sandbox context they loaded, so
+	// that deletions can be applied
+	mu     sync.Mutex
+	loaded map[types.NamespacedName]policy.SandboxContext
+}
+
+// Reconcile handles SandboxClaim create/update/delete events.
+func (r *SandboxClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(SandboxClaimGVK)
+	if err := r.Get(ctx, req.NamespacedName, claim); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch SandboxClaim")
+			return ctrl.Result{}, err
+		}
+		// Claim deleted - remove the sandbox context from engine
+		r.handleDeletion(ctx, req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+	if claim.GetDeletionTimestamp() != nil || (r.NodeName != "" && claimNode(claim) != r.NodeName) {
+		r.handleDeletion(ctx, req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	sc, ref, err := sandboxContext(claim)
+	if err != nil {
+		// A claim without a usable policy reference constrains nothing
+		// but its tenant
+		log.Info("invalid SandboxClaim policy reference", "claim", req.NamespacedName.String(), "reason", err.Error())
+	}
+
+	// Resolve the MTS label of the referenced policy. Until the policy
+	// exists, the sandbox is held to the reference alone, which denies its
+	// calls under any other policy.
+	var res ctrl.Result
+	if ref.Name != "" {
+		var ap agentsv1alpha1.AgentPolicy
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &ap); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to fetch AgentPolicy", "policy", sc.PolicyRef)
+				return ctrl.Result{}, err
+			}
+			log.Info("SandboxClaim references a missing AgentPolicy", "claim", req.NamespacedName.String(), "policy", sc.PolicyRef)
+			res.RequeueAfter = time.Minute
+		} else if ap.Spec.TenantIsolation != nil {
+			sc.MTSLabel = ap.Spec.TenantIsolation.MTSLabel
+		}
+	}
+	if sc.MTSLabel == "" && r.LabelAllocator != nil {
+		label, err := r.LabelAllocator.Allocate(ctx, r.Client, sc.TenantID)
+		if err != nil {
+			log.Error(err, "unable to allocate MTS label", "tenant", sc.TenantID)
+			return ctrl.Result{}, err
+		}
+		sc.MTSLabel = label
+	}
+
+	r.mu.Lock()
+	if r.loaded == nil {
+		r.loaded = make(map[types.NamespacedName]policy.SandboxContext)
+	}
+	previous, hadPrevious := r.loaded[req.NamespacedName]
+	r.loaded[req.NamespacedName] = sc
+	r.mu.Unlock()
+
+	if hadPrevious && previous.TenantID != sc.TenantID {
+		r.releaseTenant(previous.TenantID)
+	}
+	if r.TenantRegistry != nil && sc.MTSLabel != "" {
+		if current, ok := r.TenantRegistry.Label(sc.TenantID); ok && current != sc.MTSLabel {
+			log.Info("SandboxClaim changes the MTS label of its tenant", "claim", req.NamespacedName.String(), "tenant", sc.TenantID, "previous", current, "label", sc.MTSLabel)
+		}
+		if err := r.TenantRegistry.SetLabel(sc.TenantID, sc.MTSLabel); err != nil {
+			log.Error(err, "invalid MTS label", "claim", req.NamespacedName.String())
+		}
+	}
+
+	r.PolicyEngine.SetSandboxContext(sc)
+	log.Info("loaded sandbox context", "sandbox", sc.SandboxID, "tenant", sc.TenantID, "policy", sc.PolicyRef)
+	return res, nil
+}
+
+// handleDeletion removes the sandbox context loaded from a deleted
+// SandboxClaim.
+func (r *SandboxClaimReconciler) handleDeletion(ctx context.Context, name types.NamespacedName) {
+	r.mu.Lock()
+	sc, ok := r.loaded[name]
+	delete(r.loaded, name)
+	r.mu.Unlock()
+
+	if ok {
+		r.PolicyEngine.RemoveSandboxContext(sc.SandboxID)
+		r.releaseTenant(sc.TenantID)
+		log.FromContext(ctx).Info("removed sandbox context", "sandbox", sc.SandboxID, "claim", name.String())
+	}
+}
+
+// releaseTenant removes the MTS label of a tenant from the TenantRegistry
+// once none of the loaded claims is for it.
+func (r *SandboxClaimReconciler) releaseTenant(tenantID string) {
+	if r.TenantRegistry == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sc := range r.loaded {
+		if sc.TenantID == tenantID {
+			return
+		}
+	}
+	r.TenantRegistry.RemoveLabel(tenantID)
+}
+
+// sandboxContext reads the sandbox context of a SandboxClaim and its
+// policy reference, with the namespace defaulted. A malformed reference is
+// reported, and left out of the context.
+func sandboxContext(claim *unstructured.Unstructured) (policy.SandboxContext, agentsv1alpha1.PolicyReference, error) {
+	sc := policy.SandboxContext{
+		SandboxID: claim.GetName(),
+		TenantID:  claim.GetNamespace(),
+	}
+	if tenant, _, _ := unstructured.NestedString(claim.Object, "spec", "tenantID"); tenant != "" {
+		sc.TenantID = tenant
+	}
+
+	var ref agentsv1alpha1.PolicyReference
+	raw, found, err := unstructured.NestedMap(claim.Object, "spec", "policyRef")
+	if err != nil {
+		return sc, ref, fmt.Errorf("spec.policyRef: %w", err)
+	}
+	if !found {
+		return sc, ref, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &ref); err != nil {
+		return sc, agentsv1alpha1.PolicyReference{}, fmt.Errorf("spec.policyRef: %w", err)
+	}
+	if ref.Name == "" {
+		return sc, ref, fmt.Errorf("spec.policyRef: name is required")
+	}
+	if ref.Namespace == "" {
+		ref.Namespace = claim.GetNamespace()
+	}
+	sc.PolicyRef = ref.Namespace + "/" + ref.Name
+	return sc, ref, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch SandboxClaims, which must be
+// installed in the cluster; with NodeName, only those scheduled on the node.
+func (r *SandboxClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(SandboxClaimGVK)
+	if r.NodeName != "" {
+		return ctrl.NewControllerManagedBy(mgr).
+			For(claim, builder.WithPredicates(onNode(r.NodeName))).
+			Complete(r)
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(claim).
+		Complete(r)
+}
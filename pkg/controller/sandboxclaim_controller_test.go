@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestSandboxContext tests reading the sandbox context of a SandboxClaim.
+func TestSandboxContext(t *testing.T) {
+	claim := func(spec map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(SandboxClaimGVK)
+		u.SetNamespace("team-a")
+		u.SetName("sandbox-1")
+		return u
+	}
+
+	tests := []struct {
+		name       string
+		spec       map[string]interface{}
+		wantTenant string
+		wantPolicy string
+		wantErr    bool
+	}{
+		{"defaults", map[string]interface{}{"policyRef": map[string]interface{}{"name": "coding"}}, "team-a", "team-a/coding", false},
+		{"explicit", map[string]interface{}{"tenantID": "acme", "policyRef": map[string]interface{}{"name": "coding", "namespace": "shared"}}, "acme", "shared/coding", false},
+		{"no policy", map[string]interface{}{}, "team-a", "", false},
+		{"no policy name", map[string]interface{}{"policyRef": map[string]interface{}{"namespace": "shared"}}, "team-a", "", true},
+		{"malformed policy", map[string]interface{}{"policyRef": "coding"}, "team-a", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, _, err := sandboxContext(claim(tt.spec))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if sc.SandboxID != "sandbox-1" || sc.TenantID != tt.wantTenant || sc.PolicyRef != tt.wantPolicy {
+				t.Errorf("expected sandbox-1 %q %q, got %+v", tt.wantTenant, tt.wantPolicy, sc)
+			}
+		})
+	}
+}
+
+// TestClaimsReference tests finding the AgentPolicies the SandboxClaims of
+// a node reference.
+func TestClaimsReference(t *testing.T) {
+	claim := func(node string, policyRef interface{}) unstructured.Unstructured {
+		spec := map[string]interface{}{"policyRef": policyRef}
+		if node != "" {
+			spec["nodeName"] = node
+		}
+		u := unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(SandboxClaimGVK)
+		u.SetNamespace("team-a")
+		u.SetName("sandbox-1")
+		return u
+	}
+
+	claims := []unstructured.Unstructured{
+		claim("node-1", map[string]interface{}{"name": "coding"}),
+		claim("node-1", map[string]interface{}{"name": "review", "namespace": "shared"}),
+		claim("node-1", "malformed"),
+	}
+	if node := claimNode(&claims[0]); node != "node-1" {
+		t.Errorf("expected node-1, got %q", node)
+	}
+	if unscheduled := claim("", nil); claimNode(&unscheduled) != "" {
+		t.Errorf("expected an unscheduled claim on no node, got %q", claimNode(&unscheduled))
+	}
+
+	tests := []struct {
+		namespace, name string
+		want            bool
+	}{
+		{"team-a", "coding", true},
+		{"shared", "review", true},
+		{"team-a", "review", false},
+		{"shared", "coding", false},
+		{"team-a", "malformed", false},
+	}
+	for _, tt := range tests {
+		if got := claimsReference(claims, tt.namespace, tt.name); got != tt.want {
+			t.Errorf("claimsReference(%s/%s) = %v, want %v", tt.namespace, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// conditionAllocated reports whether a Tenant was allocated an MTS
+// category.
+const conditionAllocated = "Allocated"
+
+// TenantReconciler reconciles Tenant objects: it allocates each tenant an
+// MTS category no other tenant holds, derives the labels of the tenant
+// hierarchy, records them in the Tenants' status, and loads the agents'
+// labels into the TenantRegistry:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: Tenant
+//	metadata:
+//	  name: team-a-frontend
+//	spec:
+//	  parent: team-a
+//	  delegateToParent: true
+//
+// Categories, once allocated, are kept across reconciles; on a conflict the
+// older tenant keeps its category. The hierarchy is reconciled as a whole
+// on every event, since a tenant's label depends on its descendants.
+type TenantReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// TenantRegistry is the registry to load the tenants' labels into.
+	TenantRegistry *policy.TenantRegistry
+
+	// loaded are the tenants with a label in the registry, so that
+	// deletions can be applied
+	mu     sync.Mutex
+	loaded map[string]bool
+}
+
+// Reconcile handles Tenant create/update/delete events.
+func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var list agentsv1alpha1.TenantList
+	if err := r.List(ctx, &list); err != nil {
+		log.Error(err, "unable to list Tenants")
+		return ctrl.Result{}, err
+	}
+	tenants := make([]agentsv1alpha1.Tenant, 0, len(list.Items))
+	for _, t := range list.Items {
+		if t.DeletionTimestamp == nil {
+			tenants = append(tenants, t)
+		}
+	}
+
+	statuses := tenantStatuses(tenants)
+
+	var errs []error
+	for i := range tenants {
+		t := &tenants[i]
+		status := statuses[t.Name]
+		if equality.Semantic.DeepEqual(t.Status, status) {
+			continue
+		}
+		base := t.DeepCopy()
+		t.Status = status
+		if err := r.Status().Patch(ctx, t, client.MergeFrom(base)); err != nil {
+			log.Error(err, "failed to update Tenant status", "tenant", t.Name)
+			errs = append(errs, err)
+			continue
+		}
+		if status.Category == nil {
+			log.Info("MTS categories exhausted", "tenant", t.Name)
+		} else if base.Status.Category == nil || *base.Status.Category != *status.Category {
+			log.Info("allocated MTS category", "tenant", t.Name, "category", *status.Category, "label", status.MTSLabel)
+		}
+	}
+
+	r.loadLabels(ctx, statuses)
+	return ctrl.Result{}, errors.Join(errs...)
+}
+
+// loadLabels loads the agents' labels of the tenants into the registry,
+// and removes those of deleted tenants.
+func (r *TenantReconciler) loadLabels(ctx context.Context, statuses map[string]agentsv1alpha1.TenantStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded := make(map[string]bool, len(statuses))
+	for tenant, status := range statuses {
+		if status.MTSLabel == "" {
+			continue
+		}
+		if err := r.TenantRegistry.SetLabel(tenant, status.MTSLabel); err != nil {
+			log.FromContext(ctx).Error(err, "invalid MTS label", "tenant", tenant)
+			continue
+		}
+		loaded[tenant] = true
+	}
+	for tenant := range r.loaded {
+		if !loaded[tenant] {
+			r.TenantRegistry.RemoveLabel(tenant)
+		}
+	}
+	r.loaded = loaded
+}
+
+// tenantStatuses allocates the categories of tenants, oldest first, and
+// returns their statuses by name. Tenants keep the category of their
+// status unless an older tenant holds it.
+func tenantStatuses(tenants []agentsv1alpha1.Tenant) map[string]agentsv1alpha1.TenantStatus {
+	sorted := make([]*agentsv1alpha1.Tenant, len(tenants))
+	for i := range tenants {
+		sorted[i] = &tenants[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].CreationTimestamp, sorted[j].CreationTimestamp
+		if !a.Equal(&b) {
+			return a.Before(&b)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	alloc := policy.NewCategoryAllocator()
+	var pending []*agentsv1alpha1.Tenant
+	for _, t := range sorted {
+		if t.Status.Category == nil || alloc.Reserve(t.Name, int(*t.Status.Category)) != nil {
+			pending = append(pending, t)
+		}
+	}
+	allocErrs := make(map[string]error)
+	for _, t := range pending {
+		if _, err := alloc.Allocate(t.Name); err != nil {
+			allocErrs[t.Name] = err
+		}
+	}
+
+	nodes := make([]policy.TenantNode, 0, len(sorted))
+	for _, t := range sorted {
+		nodes = append(nodes, policy.TenantNode{
+			ID:               t.Name,
+			Parent:           t.Spec.Parent,
+			DelegateToParent: t.Spec.DelegateToParent,
+		})
+	}
+	labels := policy.TenantLabels(nodes, alloc)
+
+	statuses := make(map[string]agentsv1alpha1.TenantStatus, len(sorted))
+	for _, t := range sorted {
+		status := *t.Status.DeepCopy()
+		status.ObservedGeneration = t.Generation
+		condition := metav1.Condition{
+			Type:               conditionAllocated,
+			ObservedGeneration: t.Generation,
+		}
+		if category, ok := alloc.Category(t.Name); ok {
+			c := int32(category)
+			status.Category = &c
+			status.ObjectLabel = policy.TenantObjectLabel(category).String()
+			status.MTSLabel = labels[t.Name].String()
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "CategoryAllocated"
+			condition.Message = fmt.Sprintf("allocated MTS category c%d", category)
+		} else {
+			status.Category = nil
+			status.ObjectLabel = ""
+			status.MTSLabel = ""
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "CategoriesExhausted"
+			condition.Message = allocErrs[t.Name].Error()
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+		statuses[t.Name] = status
+	}
+	return statuses
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch Tenant CRDs.
+func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.Tenant{}).
+		Complete(r)
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// TestTenantStatuses tests the allocation of tenant categories and the
+// labels of the hierarchy.
+func TestTenantStatuses(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	tenant := func(name, parent string, delegate bool, category *int32, age time.Duration) agentsv1alpha1.Tenant {
+		tn := agentsv1alpha1.Tenant{}
+		tn.Name = name
+		tn.Generation = 1
+		tn.CreationTimestamp = metav1.NewTime(created.Add(age))
+		tn.Spec = agentsv1alpha1.TenantSpec{Parent: parent, DelegateToParent: delegate}
+		tn.Status.Category = category
+		return tn
+	}
+	seven := int32(7)
+
+	tenants := []agentsv1alpha1.Tenant{
+		// team-b claims the category org was allocated first
+		tenant("team-b", "org", false, &seven, time.Minute),
+		tenant("org", "", false, &seven, 0),
+		tenant("team-a", "org", true, nil, time.Second),
+	}
+	statuses := tenantStatuses(tenants)
+
+	org, teamA, teamB := statuses["org"], statuses["team-a"], statuses["team-b"]
+	if org.Category == nil || *org.Category != 7 {
+		t.Fatalf("expected the older tenant to keep c7, got %v", org.Category)
+	}
+	if teamA.Category == nil || teamB.Category == nil {
+		t.Fatalf("expected every tenant to be allocated, got %v %v", teamA.Category, teamB.Category)
+	}
+	if *teamB.Category == 7 || *teamA.Category == *teamB.Category || *teamA.Category == 7 {
+		t.Errorf("expected distinct categories, got c7 c%d c%d", *teamA.Category, *teamB.Category)
+	}
+
+	if org.ObjectLabel != "s0:c7" {
+		t.Errorf("expected object label s0:c7, got %q", org.ObjectLabel)
+	}
+	if org.MTSLabel == org.ObjectLabel || org.MTSLabel != statusLabel(7, *teamA.Category) {
+		t.Errorf("expected org's label to include the delegating team-a, got %q", org.MTSLabel)
+	}
+	if teamB.MTSLabel != teamB.ObjectLabel {
+		t.Errorf("expected team-b's label to be its own, got %q", teamB.MTSLabel)
+	}
+	for name, status := range statuses {
+		if !meta.IsStatusConditionTrue(status.Conditions, conditionAllocated) || status.ObservedGeneration != 1 {
+			t.Errorf("%s: expected the Allocated condition at generation 1, got %+v", name, status)
+		}
+	}
+
+	// Statuses are stable once allocated
+	for i := range tenants {
+		tenants[i].Status = statuses[tenants[i].Name]
+	}
+	again := tenantStatuses(tenants)
+	for name, status := range statuses {
+		if *again[name].Category != *status.Category || again[name].MTSLabel != status.MTSLabel {
+			t.Errorf("%s: expected a stable allocation, got %+v then %+v", name, status, again[name])
+		}
+	}
+}
+
+// statusLabel returns the label of two categories, as TenantStatus records
+// it.
+func statusLabel(a, b int32) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("s0:c%d,c%d", a, b)
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TenantConfigReconciler reconciles TenantConfig objects: it loads the
+// enforcement modes of all TenantConfigs into the engine, which applies
+// them to the calls of their tenants instead of its own mode, and records
+// in their status that they were accepted:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: TenantConfig
+//	metadata:
+//	  name: tenant-b
+//	spec:
+//	  mode: permissive
+//
+// Deleting a TenantConfig returns its tenant to the router's mode. Like
+// ToolAliases, configs are reconciled as a whole on every event.
+type TenantConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Engine is the engine to load the modes into.
+	Engine *policy.Engine
+}
+
+// Reconcile handles TenantConfig create/update/delete events.
+func (r *TenantConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var list agentsv1alpha1.TenantConfigList
+	if err := r.List(ctx, &list); err != nil {
+		log.Error(err, "unable to list TenantConfigs")
+		return ctrl.Result{}, err
+	}
+	configs := make([]*agentsv1alpha1.TenantConfig, 0, len(list.Items))
+	modes := make(map[string]policy.EnforcementMode)
+	for i := range list.Items {
+		tc := &list.Items[i]
+		if tc.DeletionTimestamp != nil {
+			continue
+		}
+		configs = append(configs, tc)
+		switch tc.Spec.Mode {
+		case agentsv1alpha1.EnforcementModeEnforcing:
+			modes[tc.Name] = policy.Enforcing
+		case agentsv1alpha1.EnforcementModePermissive:
+			modes[tc.Name] = policy.Permissive
+		}
+	}
+	r.Engine.ReplaceTenantModes(modes)
+
+	var errs []error
+	for _, tc := range configs {
+		status := *tc.Status.DeepCopy()
+		status.ObservedGeneration = tc.Generation
+		message := "tenant follows the router's enforcement mode"
+		if mode, ok := modes[tc.Name]; ok {
+			message = fmt.Sprintf("tenant calls are evaluated in %s mode", mode)
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               conditionAccepted,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: tc.Generation,
+			Reason:             "Applied",
+			Message:            message,
+		})
+		if equality.Semantic.DeepEqual(tc.Status, status) {
+			continue
+		}
+		base := tc.DeepCopy()
+		tc.Status = status
+		if err := r.Status().Patch(ctx, tc, client.MergeFrom(base)); err != nil {
+			log.Error(err, "failed to update TenantConfig status", "tenantConfig", tc.Name)
+			errs = append(errs, err)
+		}
+	}
+	return ctrl.Result{}, errors.Join(errs...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch TenantConfig CRDs.
+func (r *TenantConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.TenantConfig{}).
+		Complete(r)
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+const (
+	// serviceAccountUserPrefix prefixes the usernames of ServiceAccounts,
+	// "system:serviceaccount:<namespace>:<name>"
+	serviceAccountUserPrefix = "system:serviceaccount:"
+
+	// podNameExtra is the user extra of tokens bound to a pod that names it
+	podNameExtra = "authentication.kubernetes.io/pod-name"
+
+	// maxCachedTokens bounds the authenticated tokens kept before expired
+	// ones are swept
+	maxCachedTokens = 1024
+)
+
+var (
+	// ErrTokenInvalid reports a token the API server did not authenticate,
+	// or one that does not belong to a ServiceAccount.
+	ErrTokenInvalid = errors.New("invalid service account token")
+
+	// ErrIdentityNotBound reports a ServiceAccount that no
+	// AgentIdentityBinding maps to an agent identity, or that is not
+	// allowed to use its binding.
+	ErrIdentityNotBound = errors.New("service account is not bound to an agent identity")
+)
+
+// TokenReviewConfig configures agent authentication by TokenReview.
+type TokenReviewConfig struct {
+	// Audiences are the audiences tokens must be issued for (e.g.,
+	// "agent-router"); agents should present projected tokens with one of
+	// them. Default: none (tokens for the API server are accepted)
+	Audiences []string
+
+	// SubjectAccessReview additionally requires that the ServiceAccount be
+	// allowed to "use" its AgentIdentityBinding, so that RBAC can revoke a
+	// binding without deleting it.
+	SubjectAccessReview bool
+
+	// CacheTTL is how long an authenticated token is trusted before it is
+	// reviewed again. It bounds how long revoked tokens and changed
+	// bindings keep their previous identity. Default: 1m
+	CacheTTL time.Duration
+}
+
+// AgentIdentity is the agent identity a ServiceAccount token attests.
+type AgentIdentity struct {
+	// ServiceAccount is the ServiceAccount the token belongs to
+	ServiceAccount client.ObjectKey
+
+	// PodName is the pod the token is bound to, if it is a projected token
+	PodName string
+
+	// Binding is the name of the AgentIdentityBinding that mapped it
+	Binding string
+
+	// AgentType, TenantID, MTSLabel, and Labels are the bound identity
+	AgentType string
+	TenantID  string
+	MTSLabel  string
+	Labels    map[string]string
+}
+
+// TokenReviewAuthenticator authenticates agents by their ServiceAccount
+// tokens: it validates each token with a TokenReview and maps its
+// ServiceAccount to an agent identity through the AgentIdentityBinding
+// that names it,
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: AgentIdentityBinding
+//	metadata:
+//	  name: coding-assistant
+//	spec:
+//	  serviceAccount: {namespace: team-a, name: coding-agent}
+//	  agentType: coding-assistant
+//	  tenantID: team-a
+//
+// Authenticated tokens are cached for CacheTTL. The authenticator reviews
+// tokens once it has been added to a manager with SetupWithManager.
+type TokenReviewAuthenticator struct {
+	config TokenReviewConfig
+	client client.Client
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]cachedIdentity
+}
+
+// cachedIdentity is an authenticated token's identity and when it must be
+// reviewed again.
+type cachedIdentity struct {
+	identity  AgentIdentity
+	expiresAt time.Time
+}
+
+// NewTokenReviewAuthenticator creates an authenticator with the given
+// configuration.
+func NewTokenReviewAuthenticator(config TokenReviewConfig) *TokenReviewAuthenticator {
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = time.Minute
+	}
+	return &TokenReviewAuthenticator{
+		config: config,
+		tokens: make(map[[sha256.Size]byte]cachedIdentity),
+	}
+}
+
+// SetupWithManager connects the authenticator to the manager's client.
+// Bindings are read from the manager's cache, so the router needs to list
+// and watch AgentIdentityBindings, and to create TokenReviews (and
+// SubjectAccessReviews, if enabled).
+func (a *TokenReviewAuthenticator) SetupWithManager(mgr ctrl.Manager) error {
+	a.mu.Lock()
+	a.client = mgr.GetClient()
+	a.mu.Unlock()
+	return nil
+}
+
+// Authenticate returns the agent identity a token attests. Tokens the API
+// server rejects fail with ErrTokenInvalid, and ServiceAccounts without a
+// usable binding with ErrIdentityNotBound; other errors are failures to
+// reach the API server.
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (*AgentIdentity, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	a.mu.Lock()
+	c := a.client
+	cached, ok := a.tokens[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		identity := cached.identity
+		return &identity, nil
+	}
+	if c == nil {
+		return nil, errors.New("token review authenticator is not started")
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.config.Audiences},
+	}
+	if err := c.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, review.Status.Error)
+		}
+		return nil, ErrTokenInvalid
+	}
+	if len(a.config.Audiences) > 0 && !intersects(a.config.Audiences, review.Status.Audiences) {
+		return nil, fmt.Errorf("%w: token not issued for audiences %v", ErrTokenInvalid, a.config.Audiences)
+	}
+
+	user := review.Status.User
+	sa, ok := serviceAccountOf(user.Username)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a service account", ErrTokenInvalid, user.Username)
+	}
+
+	binding, err := a.binding(ctx, c, sa)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.SubjectAccessReview {
+		if err := a.reviewAccess(ctx, c, user, binding.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := AgentIdentity{
+		ServiceAccount: sa,
+		Binding:        binding.Name,
+		AgentType:      binding.Spec.AgentType,
+		TenantID:       binding.Spec.TenantID,
+		MTSLabel:       binding.Spec.MTSLabel,
+		Labels:         binding.Spec.Labels,
+	}
+	if pods := user.Extra[podNameExtra]; len(pods) == 1 {
+		identity.PodName = pods[0]
+	}
+
+	a.mu.Lock()
+	if len(a.tokens) >= maxCachedTokens {
+		for k, v := range a.tokens {
+			if !now.Before(v.expiresAt) {
+				delete(a.tokens, k)
+			}
+		}
+	}
+	a.tokens[key] = cachedIdentity{identity: identity, expiresAt: now.Add(a.config.CacheTTL)}
+	a.mu.Unlock()
+	return &identity, nil
+}
+
+// binding returns the AgentIdentityBinding of a ServiceAccount. A
+// ServiceAccount named by several bindings is refused rather than given
+// an arbitrary one of their identities.
+func (a *TokenReviewAuthenticator) binding(ctx context.Context, c client.Client, sa client.ObjectKey) (*agentsv1alpha1.AgentIdentityBinding, error) {
+	var bindings agentsv1alpha1.AgentIdentityBindingList
+	if err := c.List(ctx, &bindings); err != nil {
+		return nil, fmt.Errorf("listing agent identity bindings: %w", err)
+	}
+
+	var found *agentsv1alpha1.AgentIdentityBinding
+	for i := range bindings.Items {
+		b := &bindings.Items[i]
+		if b.Spec.ServiceAccount.Namespace != sa.Namespace || b.Spec.ServiceAccount.Name != sa.Name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: %s is named by bindings %s and %s", ErrIdentityNotBound, sa, found.Name, b.Name)
+		}
+		found = b
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrIdentityNotBound, sa)
+	}
+	return found, nil
+}
+
+// reviewAccess checks that the token's user may use its binding.
+func (a *TokenReviewAuthenticator) reviewAccess(ctx context.Context, c client.Client, user authenticationv1.UserInfo, binding string) error {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "use",
+				Group:    agentsv1alpha1.GroupVersion.Group,
+				Resource: "agentidentitybindings",
+				Name:     binding,
+			},
+		},
+	}
+	if len(user.Extra) > 0 {
+		review.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+		for k, v := range user.Extra {
+			review.Spec.Extra[k] = authorizationv1.ExtraValue(v)
+		}
+	}
+	if err := c.Create(ctx, review); err != nil {
+		return fmt.Errorf("subject access review: %w", err)
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("%w: %s may not use AgentIdentityBinding %s", ErrIdentityNotBound, user.Username, binding)
+	}
+	return nil
+}
+
+// serviceAccountOf parses a ServiceAccount username.
+func serviceAccountOf(username string) (client.ObjectKey, bool) {
+	rest, ok := strings.CutPrefix(username, serviceAccountUserPrefix)
+	if !ok {
+		return client.ObjectKey{}, false
+	}
+	namespace, name, ok := strings.Cut(rest, ":")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, ":") {
+		return client.ObjectKey{}, false
+	}
+	return client.ObjectKey{Namespace: namespace, Name: name}, true
+}
+
+// intersects reports whether two lists share a value.
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
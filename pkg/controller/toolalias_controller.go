@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// conditionAccepted reports whether the aliases of a ToolAlias were
+// applied.
+const conditionAccepted = "Accepted"
+
+// ToolAliasReconciler reconciles ToolAlias objects: it loads the aliases of
+// all ToolAliases into the router's ToolNormalizer and records in their
+// status whether they were accepted:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: ToolAlias
+//	metadata:
+//	  name: file-read
+//	spec:
+//	  tool: file.read
+//	  aliases: [readFile, fs_read]
+//
+// On a conflict the older ToolAlias keeps its aliases and the newer one is
+// not applied. The aliases are reconciled as a whole on every event, so
+// that a ToolAlias is applied once the one it conflicted with is deleted.
+type ToolAliasReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Normalizer is the normalizer to load the aliases into.
+	Normalizer *policy.ToolNormalizer
+}
+
+// Reconcile handles ToolAlias create/update/delete events.
+func (r *ToolAliasReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var list agentsv1alpha1.ToolAliasList
+	if err := r.List(ctx, &list); err != nil {
+		log.Error(err, "unable to list ToolAliases")
+		return ctrl.Result{}, err
+	}
+	aliases := make([]*agentsv1alpha1.ToolAlias, 0, len(list.Items))
+	for i := range list.Items {
+		if list.Items[i].DeletionTimestamp == nil {
+			aliases = append(aliases, &list.Items[i])
+		}
+	}
+	sortOldestFirst(aliases)
+
+	sets := make([]policy.ToolAliasSet, len(aliases))
+	for i, ta := range aliases {
+		sets[i] = policy.ToolAliasSet{Source: ta.Name, Tool: ta.Spec.Tool, Aliases: ta.Spec.Aliases}
+	}
+	setErrs := r.Normalizer.ReplaceAliases(sets)
+
+	var errs []error
+	for i, ta := range aliases {
+		status := *ta.Status.DeepCopy()
+		status.ObservedGeneration = ta.Generation
+		condition := metav1.Condition{
+			Type:               conditionAccepted,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: ta.Generation,
+			Reason:             "Applied",
+			Message:            fmt.Sprintf("%d aliases of %s applied", len(ta.Spec.Aliases), ta.Spec.Tool),
+		}
+		if setErrs[i] != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Conflict"
+			condition.Message = setErrs[i].Error()
+			log.Info("ToolAlias not applied", "toolAlias", ta.Name, "reason", setErrs[i].Error())
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+		if equality.Semantic.DeepEqual(ta.Status, status) {
+			continue
+		}
+		base := ta.DeepCopy()
+		ta.Status = status
+		if err := r.Status().Patch(ctx, ta, client.MergeFrom(base)); err != nil {
+			log.Error(err, "failed to update ToolAlias status", "toolAlias", ta.Name)
+			errs = append(errs, err)
+		}
+	}
+	return ctrl.Result{}, errors.Join(errs...)
+}
+
+// sortOldestFirst sorts objects by creation, and objects created at once
+// by name.
+func sortOldestFirst[T client.Object](objects []T) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i].GetCreationTimestamp(), objects[j].GetCreationTimestamp()
+		if !a.Equal(&b) {
+			return a.Before(&b)
+		}
+		return objects[i].GetName() < objects[j].GetName()
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch ToolAlias CRDs.
+func (r *ToolAliasReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.ToolAlias{}).
+		Complete(r)
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// ToolCatalogReconciler reconciles ToolCatalog objects: it loads the tools
+// of all ToolCatalogs into the router's ToolCatalog and records in their
+// status whether every tool was accepted:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: ToolCatalog
+//	metadata:
+//	  name: platform-tools
+//	spec:
+//	  tools:
+//	    - name: file.read
+//	      category: filesystem
+//	      riskLevel: low
+//	      parameters:
+//	        type: object
+//	        properties: {path: {type: string}}
+//
+// A tool declared by two catalogs is kept by the older one. Like
+// ToolAliases, catalogs are reconciled as a whole on every event.
+type ToolCatalogReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Catalog is the catalog to load the tools into.
+	Catalog *policy.ToolCatalog
+}
+
+// Reconcile handles ToolCatalog create/update/delete events.
+func (r *ToolCatalogReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var list agentsv1alpha1.ToolCatalogList
+	if err := r.List(ctx, &list); err != nil {
+		log.Error(err, "unable to list ToolCatalogs")
+		return ctrl.Result{}, err
+	}
+	catalogs := make([]*agentsv1alpha1.ToolCatalog, 0, len(list.Items))
+	for i := range list.Items {
+		if list.Items[i].DeletionTimestamp == nil {
+			catalogs = append(catalogs, &list.Items[i])
+		}
+	}
+	sortOldestFirst(catalogs)
+
+	sets := make([]policy.ToolCatalogSet, len(catalogs))
+	convertErrs := make([]error, len(catalogs))
+	for i, tc := range catalogs {
+		sets[i], convertErrs[i] = catalogSet(tc)
+	}
+	setErrs := r.Catalog.Replace(sets)
+
+	var errs []error
+	for i, tc := range catalogs {
+		status := *tc.Status.DeepCopy()
+		status.ObservedGeneration = tc.Generation
+		condition := metav1.Condition{
+			Type:               conditionAccepted,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: tc.Generation,
+			Reason:             "Applied",
+			Message:            fmt.Sprintf("%d tools applied", len(tc.Spec.Tools)),
+		}
+		if err := errors.Join(convertErrs[i], setErrs[i]); err != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "InvalidTools"
+			condition.Message = err.Error()
+			log.Info("ToolCatalog tools not applied", "toolCatalog", tc.Name, "reason", err.Error())
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+		if equality.Semantic.DeepEqual(tc.Status, status) {
+			continue
+		}
+		base := tc.DeepCopy()
+		tc.Status = status
+		if err := r.Status().Patch(ctx, tc, client.MergeFrom(base)); err != nil {
+			log.Error(err, "failed to update ToolCatalog status", "toolCatalog", tc.Name)
+			errs = append(errs, err)
+		}
+	}
+	return ctrl.Result{}, errors.Join(errs...)
+}
+
+// catalogSet converts the tools of a ToolCatalog. Tools whose parameter
+// schema is not a JSON object are left out, and reported in the error.
+func catalogSet(tc *agentsv1alpha1.ToolCatalog) (policy.ToolCatalogSet, error) {
+	set := policy.ToolCatalogSet{Source: tc.Name}
+	var errs []error
+	for i, t := range tc.Spec.Tools {
+		tool := policy.CatalogTool{
+			Name:        t.Name,
+			Description: t.Description,
+			Category:    t.Category,
+			RiskLevel:   policy.RiskLevel(t.RiskLevel),
+		}
+		if t.Parameters != nil {
+			if err := json.Unmarshal(t.Parameters.Raw, &tool.Parameters); err != nil || tool.Parameters == nil {
+				errs = append(errs, fmt.Errorf("tools[%d]: parameters must be a JSON Schema object", i))
+				continue
+			}
+		}
+		set.Tools = append(set.Tools, tool)
+	}
+	return set, errors.Join(errs...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch ToolCatalog CRDs.
+func (r *ToolCatalogReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.ToolCatalog{}).
+		Complete(r)
+}
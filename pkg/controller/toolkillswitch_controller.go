@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// conditionActive reports whether a ToolKillSwitch disables its tool.
+const conditionActive = "Active"
+
+// ToolKillSwitchReconciler reconciles ToolKillSwitch objects: it loads the
+// switches of all ToolKillSwitches into the engine, which denies every call
+// to their tools, and records in their status whether they are in effect:
+//
+//	apiVersion: agents.sandbox.io/v1alpha1
+//	kind: ToolKillSwitch
+//	metadata:
+//	  name: incident-4711
+//	spec:
+//	  tool: network.fetch
+//	  reason: "INC-4711: exfiltration via fetch"
+//	  expiresAt: "2025-06-01T12:00:00Z"
+//
+// Deleting the ToolKillSwitch lifts it. The engine lifts expired switches
+// by itself; the reconciler requeues at the next expiry to update their
+// status.
+type ToolKillSwitchReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Engine is the engine to load the switches into.
+	Engine *policy.Engine
+}
+
+// Reconcile handles ToolKillSwitch create/update/delete events.
+func (r *ToolKillSwitchReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var list agentsv1alpha1.ToolKillSwitchList
+	if err := r.List(ctx, &list); err != nil {
+		log.Error(err, "unable to list ToolKillSwitches")
+		return ctrl.Result{}, err
+	}
+	now := time.Now()
+	switches := make([]policy.KillSwitch, 0, len(list.Items))
+	var requeue time.Duration
+	for i := range list.Items {
+		tks := &list.Items[i]
+		if tks.DeletionTimestamp != nil {
+			continue
+		}
+		k := policy.KillSwitch{Source: tks.Name, Tool: tks.Spec.Tool, Reason: tks.Spec.Reason}
+		if tks.Spec.ExpiresAt != nil {
+			k.ExpiresAt = tks.Spec.ExpiresAt.Time
+			if wait := k.ExpiresAt.Sub(now); wait > 0 && (requeue == 0 || wait < requeue) {
+				requeue = wait
+			}
+		}
+		switches = append(switches, k)
+	}
+	r.Engine.ReplaceKillSwitches(switches)
+
+	var errs []error
+	for i := range list.Items {
+		tks := &list.Items[i]
+		if tks.DeletionTimestamp != nil {
+			continue
+		}
+		status := *tks.Status.DeepCopy()
+		status.ObservedGeneration = tks.Generation
+		condition := metav1.Condition{
+			Type:               conditionActive,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: tks.Generation,
+			Reason:             "Disabled",
+			Message:            fmt.Sprintf("calls to %s are denied", tks.Spec.Tool),
+		}
+		if tks.Spec.ExpiresAt != nil && !now.Before(tks.Spec.ExpiresAt.Time) {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Expired"
+			condition.Message = fmt.Sprintf("expired at %s", tks.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+		if equality.Semantic.DeepEqual(tks.Status, status) {
+			continue
+		}
+		base := tks.DeepCopy()
+		tks.Status = status
+		if err := r.Status().Patch(ctx, tks, client.MergeFrom(base)); err != nil {
+			log.Error(err, "failed to update ToolKillSwitch status", "toolKillSwitch", tks.Name)
+			errs = append(errs, err)
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, errors.Join(errs...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// This registers the controller to watch ToolKillSwitch CRDs.
+func (r *ToolKillSwitchReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentsv1alpha1.ToolKillSwitch{}).
+		Complete(r)
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// redactedValue replaces the values read from Secrets in what the
+// controller writes to status and logs.
+const redactedValue = "<redacted>"
+
+// errValuesFromDisabled fails the policies whose constraints read values
+// when the reconciler does not read them (see ValuesFrom).
+var errValuesFromDisabled = errors.New("constraints read values from ConfigMaps or Secrets, which requires the router's --values-from")
+
+// resolveValuesFrom returns ap with the values its constraints read from
+// the ConfigMaps and Secrets of its namespace added to them, and the
+// values read from Secrets (see compile.ResolveValuesFrom). A missing
+// object or key reads as absent, so that its optional flag applies. The
+// objects are read uncached, through r.APIReader if set.
+func (r *AgentPolicyReconciler) resolveValuesFrom(ctx context.Context, ap *agentsv1alpha1.AgentPolicy) (*agentsv1alpha1.AgentPolicy, []string, error) {
+	if !r.ValuesFrom {
+		if len(compile.ValuesFromRefs(ap)) > 0 {
+			return nil, nil, errValuesFromDisabled
+		}
+		return ap, nil, nil
+	}
+	reader := r.reader()
+	return compile.ResolveValuesFrom(ap, func(kind, name, key string) (string, bool, error) {
+		objKey := client.ObjectKey{Namespace: ap.Namespace, Name: name}
+		if kind == compile.ValuesKindSecret {
+			var secret corev1.Secret
+			if err := reader.Get(ctx, objKey, &secret); apierrors.IsNotFound(err) {
+				return "", false, nil
+			} else if err != nil {
+				return "", false, err
+			}
+			data, ok := secret.Data[key]
+			return string(data), ok, nil
+		}
+
+		var cm corev1.ConfigMap
+		if err := reader.Get(ctx, objKey, &cm); apierrors.IsNotFound(err) {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, err
+		}
+		data, ok := cm.Data[key]
+		return data, ok, nil
+	})
+}
+
+// reader returns the reader of objects the cache does not hold: the
+// APIReader, or the client.
+func (r *AgentPolicyReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// policiesReadingValues returns a map function that queues the
+// AgentPolicies whose constraints read values from a changed ConfigMap or
+// Secret, of the given kind, so that they are compiled again.
+func (r *AgentPolicyReconciler) policiesReadingValues(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var policies agentsv1alpha1.AgentPolicyList
+		if err := r.List(ctx, &policies, client.InNamespace(obj.GetNamespace())); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list AgentPolicies reading values", "kind", kind, "name", obj.GetName())
+			return nil
+		}
+
+		ref := kind + "/" + obj.GetName()
+		var requests []reconcile.Request
+		for i := range policies.Items {
+			for _, read := range compile.ValuesFromRefs(&policies.Items[i]) {
+				if read == ref {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// redactSecrets replaces the values read from Secrets in s.
+func redactSecrets(s string, secret []string) string {
+	for _, v := range secret {
+		s = strings.ReplaceAll(s, v, redactedValue)
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// repository is a bare clone of a Git repository, fetched with the git
+// command. Commits are read from its object store, never checked out.
+type repository struct {
+	url string
+	dir string
+}
+
+func newRepository(url, dir string) *repository {
+	if dir == "" {
+		sum := sha256.Sum256([]byte(url))
+		dir = filepath.Join(os.TempDir(), "golden-agent-gitops-"+hex.EncodeToString(sum[:8]))
+	}
+	return &repository{url: url, dir: dir}
+}
+
+// git runs a git command in the clone and returns its output.
+func (r *repository) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// fetch fetches ref and returns the commit to sync: commit, if pinned,
+// else the commit ref points to.
+func (r *repository) fetch(ctx context.Context, ref, commit string) (string, error) {
+	if _, err := os.Stat(filepath.Join(r.dir, "HEAD")); err != nil {
+		if err := os.MkdirAll(r.dir, 0o700); err != nil {
+			return "", fmt.Errorf("failed to create repository cache: %w", err)
+		}
+		if _, err := r.git(ctx, "init", "--quiet", "--bare"); err != nil {
+			return "", err
+		}
+	}
+
+	if commit != "" {
+		// A pinned commit already fetched needs no fetch
+		if revision, err := r.resolve(ctx, commit); err == nil {
+			return revision, nil
+		}
+		if _, err := r.git(ctx, "fetch", "--quiet", "--no-tags", r.url, ref); err != nil {
+			return "", err
+		}
+		if revision, err := r.resolve(ctx, commit); err == nil {
+			return revision, nil
+		}
+		// Not on ref: servers that allow it serve commits by ID
+		if _, err := r.git(ctx, "fetch", "--quiet", "--no-tags", r.url, commit); err != nil {
+			return "", fmt.Errorf("pinned commit %s not found: %w", commit, err)
+		}
+		return r.resolve(ctx, commit)
+	}
+
+	if _, err := r.git(ctx, "fetch", "--quiet", "--no-tags", r.url, ref); err != nil {
+		return "", err
+	}
+	return r.resolve(ctx, "FETCH_HEAD")
+}
+
+// resolve returns the full ID of a commit.
+func (r *repository) resolve(ctx context.Context, rev string) (string, error) {
+	out, err := r.git(ctx, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// verify checks the signature of a commit, against the allowed signers
+// file for SSH signatures if one is given.
+func (r *repository) verify(ctx context.Context, revision, allowedSigners string) error {
+	args := []string{"verify-commit", revision}
+	if allowedSigners != "" {
+		path, err := filepath.Abs(allowedSigners)
+		if err != nil {
+			return err
+		}
+		args = append([]string{"-c", "gpg.ssh.allowedSignersFile=" + path}, args...)
+	}
+	if _, err := r.git(ctx, args...); err != nil {
+		return fmt.Errorf("commit %s has no valid signature: %w", revision, err)
+	}
+	return nil
+}
+
+// files lists the YAML and JSON files under dir in a commit, sorted.
+func (r *repository) files(ctx context.Context, revision, dir string) ([]string, error) {
+	args := []string{"ls-tree", "-r", "-z", "--name-only", revision}
+	if dir = strings.Trim(dir, "/"); dir != "" && dir != "." {
+		args = append(args, "--", dir+"/")
+	}
+	out, err := r.git(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		switch filepath.Ext(name) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// read returns the contents of a file in a commit.
+func (r *repository) read(ctx context.Context, revision, name string) ([]byte, error) {
+	return r.git(ctx, "cat-file", "blob", revision+":"+name)
+}
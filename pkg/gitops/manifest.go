@@ -0,0 +1,58 @@
+package gitops
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// documentSeparator splits multi-document YAML files.
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// manifest is the part of an AgentPolicy manifest the source reads.
+// Status is ignored: it is owned by the controller.
+type manifest struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        metav1.ObjectMeta              `json:"metadata,omitempty"`
+	Spec            agentsv1alpha1.AgentPolicySpec `json:"spec"`
+}
+
+// ParseManifests returns the AgentPolicies of a YAML or JSON file, which
+// may hold several documents. Documents of other kinds are skipped, so the
+// directory may hold other resources (e.g., a kustomization). Policies
+// without a namespace are put in namespace, and get a UID derived from
+// their name, so that engines can track them across syncs.
+func ParseManifests(data []byte, namespace string) ([]*agentsv1alpha1.AgentPolicy, error) {
+	var policies []*agentsv1alpha1.AgentPolicy
+	for _, doc := range documentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var m manifest
+		if err := yaml.Unmarshal([]byte(doc), &m); err != nil {
+			return nil, err
+		}
+		if m.Kind != "AgentPolicy" {
+			continue
+		}
+		if m.Metadata.Name == "" {
+			return nil, fmt.Errorf("AgentPolicy without a name")
+		}
+		if m.Metadata.Namespace == "" {
+			m.Metadata.Namespace = namespace
+		}
+		m.Metadata.UID = types.UID("gitops/" + m.Metadata.Namespace + "/" + m.Metadata.Name)
+		policies = append(policies, &agentsv1alpha1.AgentPolicy{
+			TypeMeta:   m.TypeMeta,
+			ObjectMeta: m.Metadata,
+			Spec:       m.Spec,
+		})
+	}
+	return policies, nil
+}
@@ -0,0 +1,308 @@
+// Package gitops syncs AgentPolicy manifests from a Git repository.
+//
+// A Source polls a repository, or is triggered by a push webhook, reads
+// the AgentPolicy YAML under a directory of one commit, and hands the set
+// to a Target: an EngineTarget loads them straight into a policy engine,
+// without the CRD, and a ClusterTarget applies them to the cluster, where
+// the AgentPolicy controller picks them up. Policies removed from the
+// directory are pruned.
+//
+// Commits can be pinned, so the source only ever syncs one reviewed
+// commit, and required to carry a valid signature, so a compromised Git
+// host cannot push policy. Any failure (fetch, signature, or a manifest
+// that does not parse or compile) leaves the last synced commit in force.
+//
+// The repository is fetched with the git command, which must be on PATH;
+// signatures are checked with git verify-commit, against the GnuPG keyring
+// or, for SSH signatures, an allowed signers file.
+package gitops
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// Config configures a Git policy source.
+type Config struct {
+	// Name identifies the source; a ClusterTarget labels the policies it
+	// applies with it (default "default")
+	Name string
+
+	// Repository is the URL or path of the Git repository
+	Repository string
+
+	// Ref is the branch or tag to sync (default HEAD, the remote's
+	// default branch)
+	Ref string
+
+	// Commit pins the source to one commit: only it is synced, whatever
+	// Ref points to
+	Commit string
+
+	// Path is the directory of the repository to read manifests from,
+	// recursively (default: the whole repository)
+	Path string
+
+	// Namespace is the namespace of manifests without one (default
+	// "default")
+	Namespace string
+
+	// PollInterval is how often the repository is fetched; 0 only syncs
+	// at start and on webhooks
+	PollInterval time.Duration
+
+	// RequireSignature refuses commits without a signature git
+	// verify-commit accepts
+	RequireSignature bool
+
+	// AllowedSignersFile is the allowed signers file SSH signatures are
+	// verified against; it implies RequireSignature
+	AllowedSignersFile string
+
+	// Prune removes the policies of a previous sync that a commit no
+	// longer has
+	Prune bool
+
+	// WebhookAddr is the address a push webhook is served on, at any
+	// path; empty serves none
+	WebhookAddr string
+
+	// WebhookSecret, if set, is the secret webhooks are authenticated
+	// with: GitHub's X-Hub-Signature-256 HMAC or GitLab's X-Gitlab-Token
+	WebhookSecret []byte
+
+	// CacheDir is where the repository is fetched to (default: a
+	// directory under os.TempDir named for the repository)
+	CacheDir string
+}
+
+// Target receives the policies of each synced commit.
+type Target interface {
+	// Sync makes policies, read from revision, the policies of the source.
+	// If prune is set, policies of earlier syncs missing from policies are
+	// removed. An error leaves the previous policies in force.
+	Sync(ctx context.Context, revision string, policies []*agentsv1alpha1.AgentPolicy, prune bool) error
+}
+
+// Source syncs the AgentPolicy manifests of a Git repository to a Target.
+type Source struct {
+	config Config
+	target Target
+	repo   *repository
+	log    *slog.Logger
+
+	trigger chan struct{}
+
+	mu       sync.Mutex // serializes syncs
+	revision string
+	lastErr  error
+}
+
+// NewSource returns a source of the policies in the repository of config.
+// It syncs once Started.
+func NewSource(config Config, target Target, logger *slog.Logger) (*Source, error) {
+	if config.Repository == "" {
+		return nil, errors.New("gitops: no repository")
+	}
+	if config.Name == "" {
+		config.Name = "default"
+	}
+	if config.Ref == "" {
+		config.Ref = "HEAD"
+	}
+	if config.Namespace == "" {
+		config.Namespace = "default"
+	}
+	if config.AllowedSignersFile != "" {
+		config.RequireSignature = true
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Source{
+		config:  config,
+		target:  target,
+		repo:    newRepository(config.Repository, config.CacheDir),
+		log:     logger.With("source", config.Name, "repository", config.Repository),
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+// Revision returns the commit last synced, and the error of the last sync
+// attempt, if it failed.
+func (s *Source) Revision() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision, s.lastErr
+}
+
+// Sync fetches the repository and syncs the policies of its current (or
+// pinned) commit, unless that commit is already synced.
+func (s *Source) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revision, err := s.sync(ctx)
+	s.lastErr = err
+	if err != nil {
+		return err
+	}
+	s.revision = revision
+	return nil
+}
+
+func (s *Source) sync(ctx context.Context) (string, error) {
+	revision, err := s.repo.fetch(ctx, s.config.Ref, s.config.Commit)
+	if err != nil {
+		return "", err
+	}
+	if revision == s.revision && s.lastErr == nil {
+		return revision, nil
+	}
+
+	if s.config.RequireSignature {
+		if err := s.repo.verify(ctx, revision, s.config.AllowedSignersFile); err != nil {
+			return "", err
+		}
+	}
+
+	files, err := s.repo.files(ctx, revision, s.config.Path)
+	if err != nil {
+		return "", err
+	}
+	var policies []*agentsv1alpha1.AgentPolicy
+	seen := make(map[string]string)
+	for _, file := range files {
+		data, err := s.repo.read(ctx, revision, file)
+		if err != nil {
+			return "", err
+		}
+		parsed, err := ParseManifests(data, s.config.Namespace)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+		for _, ap := range parsed {
+			ref := ap.Namespace + "/" + ap.Name
+			if other, ok := seen[ref]; ok {
+				return "", fmt.Errorf("%s: AgentPolicy %s is also defined in %s", file, ref, other)
+			}
+			seen[ref] = file
+			policies = append(policies, ap)
+		}
+	}
+
+	if err := s.target.Sync(ctx, revision, policies, s.config.Prune); err != nil {
+		return "", err
+	}
+	s.log.Info("synced policies", "revision", revision, "policies", len(policies))
+	return revision, nil
+}
+
+// Start syncs the repository, then again every PollInterval and on
+// webhooks, until ctx is done. Failed syncs are logged and retried on
+// the next poll or webhook.
+func (s *Source) Start(ctx context.Context) error {
+	if s.config.WebhookAddr != "" {
+		server := &http.Server{Addr: s.config.WebhookAddr, Handler: s, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("webhook server stopped", "error", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	var tick <-chan time.Time
+	if s.config.PollInterval > 0 {
+		ticker := time.NewTicker(s.config.PollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("failed to sync policies; the last synced commit stays in force", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-s.trigger:
+		}
+	}
+}
+
+// SetupWithManager adds the source to the manager, which starts it.
+func (s *Source) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(s)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every
+// replica syncs.
+func (s *Source) NeedLeaderElection() bool {
+	return false
+}
+
+// maxWebhookBytes bounds the webhook payloads read to authenticate them.
+const maxWebhookBytes = 1 << 20
+
+// ServeHTTP handles push webhooks by triggering a sync.
+func (s *Source) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if !s.authenticWebhook(r.Header, body) {
+		http.Error(w, "unauthenticated webhook", http.StatusUnauthorized)
+		return
+	}
+
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+		// A sync is already pending
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authenticWebhook reports whether a webhook carries the configured
+// secret, as a GitHub payload HMAC or a GitLab token.
+func (s *Source) authenticWebhook(header http.Header, body []byte) bool {
+	secret := s.config.WebhookSecret
+	if len(secret) == 0 {
+		return true
+	}
+	if token := header.Get("X-Gitlab-Token"); token != "" {
+		return hmac.Equal([]byte(token), secret)
+	}
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
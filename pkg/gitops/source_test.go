@@ -0,0 +1,246 @@
+package gitops
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// testRepo is a Git repository of policy manifests.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch=main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-C", r.dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files (removing those with empty contents) and commits
+// them, returning the commit ID.
+func (r *testRepo) commit(files map[string]string, args ...string) string {
+	r.t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(r.dir, name)
+		if contents == "" {
+			r.git("rm", "--quiet", name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+		r.git("add", name)
+	}
+	r.git(append([]string{"commit", "--quiet", "-m", "update"}, args...)...)
+	return r.git("rev-parse", "HEAD")
+}
+
+func agentPolicy(name, agentType, tool string) string {
+	return `apiVersion: agents.sandbox.io/v1alpha1
+kind: AgentPolicy
+metadata:
+  name: ` + name + `
+spec:
+  agentTypes: [` + agentType + `]
+  defaultAction: deny
+  mode: enforcing
+  toolPermissions:
+    - tool: ` + tool + `
+      action: allow
+`
+}
+
+func allowed(engine *policy.Engine, agentType, tool string) bool {
+	decision, _ := engine.Evaluate(context.Background(), policy.AgentContext{AgentType: agentType}, tool, nil)
+	return decision == policy.Allow
+}
+
+// TestSourceSync tests that policies are loaded from the directory of the
+// latest commit and pruned once removed.
+func TestSourceSync(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{
+		"policies/coding.yaml":   agentPolicy("coding", "coding-assistant", "file.read"),
+		"policies/research.yaml": agentPolicy("research", "research-agent", "web.search") + "---\nkind: ConfigMap\n",
+		"other/ignored.yaml":     agentPolicy("ignored", "other-agent", "file.read"),
+	})
+
+	engine := policy.NewEngine(policy.WithMode(policy.Enforcing))
+	source, err := NewSource(Config{Repository: repo.dir, Path: "policies", Prune: true, CacheDir: t.TempDir()},
+		NewEngineTarget(engine, false, nil), nil)
+	if err != nil {
+		t.Fatalf("NewSource failed: %v", err)
+	}
+	if err := source.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !allowed(engine, "coding-assistant", "file.read") || !allowed(engine, "research-agent", "web.search") {
+		t.Error("expected the policies of the directory to be loaded")
+	}
+	if _, ok := engine.GetPolicy("other-agent"); ok {
+		t.Error("expected policies outside the directory to be ignored")
+	}
+
+	head := repo.commit(map[string]string{
+		"policies/coding.yaml":   agentPolicy("coding", "coding-assistant", "file.write"),
+		"policies/research.yaml": "",
+	})
+	if err := source.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if allowed(engine, "coding-assistant", "file.read") || !allowed(engine, "coding-assistant", "file.write") {
+		t.Error("expected the changed policy to be loaded")
+	}
+	if _, ok := engine.GetPolicy("research-agent"); ok {
+		t.Error("expected the removed policy to be pruned")
+	}
+	if revision, err := source.Revision(); revision != head || err != nil {
+		t.Errorf("expected revision %s, got %s (%v)", head, revision, err)
+	}
+
+	// A commit that does not compile leaves the last synced one in force
+	repo.commit(map[string]string{"policies/coding.yaml": "kind: AgentPolicy\nmetadata: {name: coding}\nspec: {defaultAction: [}\n"})
+	if err := source.Sync(context.Background()); err == nil {
+		t.Fatal("expected a malformed manifest to fail the sync")
+	}
+	if !allowed(engine, "coding-assistant", "file.write") {
+		t.Error("expected the last synced policy to stay in force")
+	}
+	if revision, err := source.Revision(); revision != head || err == nil {
+		t.Errorf("expected revision %s and the sync error, got %s (%v)", head, revision, err)
+	}
+}
+
+// TestSourcePinned tests that a pinned source only syncs its commit.
+func TestSourcePinned(t *testing.T) {
+	repo := newTestRepo(t)
+	pinned := repo.commit(map[string]string{"coding.yaml": agentPolicy("coding", "coding-assistant", "file.read")})
+	repo.commit(map[string]string{"coding.yaml": agentPolicy("coding", "coding-assistant", "file.write")})
+
+	engine := policy.NewEngine(policy.WithMode(policy.Enforcing))
+	source, err := NewSource(Config{Repository: repo.dir, Commit: pinned[:12], CacheDir: t.TempDir()},
+		NewEngineTarget(engine, false, nil), nil)
+	if err != nil {
+		t.Fatalf("NewSource failed: %v", err)
+	}
+	if err := source.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !allowed(engine, "coding-assistant", "file.read") || allowed(engine, "coding-assistant", "file.write") {
+		t.Error("expected the pinned commit's policy")
+	}
+	if revision, _ := source.Revision(); revision != pinned {
+		t.Errorf("expected revision %s, got %s", pinned, revision)
+	}
+}
+
+// TestSourceSignatures tests that unsigned commits and commits by signers
+// not allowed are refused.
+func TestSourceSignatures(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	repo := newTestRepo(t)
+	key := filepath.Join(t.TempDir(), "key")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v: %s", err, out)
+	}
+	public, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := filepath.Join(t.TempDir(), "allowed_signers")
+	if err := os.WriteFile(signers, []byte("test@example.com "+string(public)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := policy.NewEngine(policy.WithMode(policy.Enforcing))
+	source, err := NewSource(Config{Repository: repo.dir, AllowedSignersFile: signers, CacheDir: t.TempDir()},
+		NewEngineTarget(engine, false, nil), nil)
+	if err != nil {
+		t.Fatalf("NewSource failed: %v", err)
+	}
+
+	repo.commit(map[string]string{"coding.yaml": agentPolicy("coding", "coding-assistant", "file.read")})
+	if err := source.Sync(context.Background()); err == nil {
+		t.Fatal("expected an unsigned commit to be refused")
+	}
+	if _, ok := engine.GetPolicy("coding-assistant"); ok {
+		t.Error("expected nothing loaded from an unsigned commit")
+	}
+
+	repo.git("-c", "gpg.format=ssh", "-c", "user.signingkey="+key, "commit", "--quiet", "--amend", "--no-edit", "-S")
+	if err := source.Sync(context.Background()); err != nil {
+		t.Fatalf("expected a signed commit to sync, got %v", err)
+	}
+	if !allowed(engine, "coding-assistant", "file.read") {
+		t.Error("expected the signed commit's policy to be loaded")
+	}
+}
+
+// TestSourceWebhook tests that webhooks trigger syncs only with the secret.
+func TestSourceWebhook(t *testing.T) {
+	source, err := NewSource(Config{Repository: "unused", WebhookSecret: []byte("s3cret")}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewSource failed: %v", err)
+	}
+	body := `{"ref":"refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+
+	for _, tt := range []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"unsigned", "", "", http.StatusUnauthorized},
+		{"wrong signature", "X-Hub-Signature-256", "sha256=00", http.StatusUnauthorized},
+		{"github", "X-Hub-Signature-256", "sha256=" + hex.EncodeToString(mac.Sum(nil)), http.StatusAccepted},
+		{"gitlab", "X-Gitlab-Token", "s3cret", http.StatusAccepted},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		source.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+	select {
+	case <-source.trigger:
+	default:
+		t.Error("expected an accepted webhook to trigger a sync")
+	}
+}
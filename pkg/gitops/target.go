@@ -0,0 +1,197 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// EngineTarget loads synced policies straight into a policy engine, for
+// routers that run without the AgentPolicy CRD. Policies are bound as the
+// controller binds them: under their agent types, as the fallback, or as
+// the canary of their stable policy.
+type EngineTarget struct {
+	engine *policy.Engine
+	useOPA bool
+	log    *slog.Logger
+
+	mu     sync.Mutex
+	loaded map[string]bool // UIDs of the policies synced
+}
+
+// NewEngineTarget returns a target that loads policies into engine,
+// compiled to Rego if useOPA is set.
+func NewEngineTarget(engine *policy.Engine, useOPA bool, logger *slog.Logger) *EngineTarget {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &EngineTarget{engine: engine, useOPA: useOPA, log: logger, loaded: make(map[string]bool)}
+}
+
+// Sync implements Target. Every policy is compiled before any is loaded,
+// so a commit with a policy that does not compile changes nothing.
+func (t *EngineTarget) Sync(ctx context.Context, revision string, policies []*agentsv1alpha1.AgentPolicy, prune bool) error {
+	compiled := make([]*policy.CompiledPolicy, len(policies))
+	for i, ap := range policies {
+		result, err := compile.AgentPolicy(ap, t.useOPA)
+		if err != nil {
+			return fmt.Errorf("AgentPolicy %s/%s: %w", ap.Namespace, ap.Name, err)
+		}
+		compiled[i] = result.Policy
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	synced := make(map[string]bool, len(policies))
+	for i, ap := range policies {
+		uid := string(ap.UID)
+		synced[uid] = true
+
+		if ap.Spec.Canary != nil {
+			stable := types.NamespacedName{Namespace: ap.Namespace, Name: ap.Spec.Canary.Stable}.String()
+			t.engine.RemovePolicyUID(uid)
+			t.engine.RemoveCanaryUID(uid)
+			t.engine.LoadCanary(stable, compiled[i], int(ap.Spec.Canary.Percent))
+			continue
+		}
+
+		t.engine.RemoveCanaryUID(uid)
+		keep := append([]string{}, ap.Spec.AgentTypes...)
+		for _, agentType := range ap.Spec.AgentTypes {
+			t.engine.LoadPolicy(agentType, compiled[i])
+		}
+		if ap.Spec.Fallback {
+			t.engine.LoadPolicy(policy.FallbackAgentType, compiled[i])
+			keep = append(keep, policy.FallbackAgentType)
+		}
+		t.engine.RemovePolicyUID(uid, keep...)
+	}
+
+	for uid := range t.loaded {
+		if synced[uid] {
+			continue
+		}
+		if !prune {
+			synced[uid] = true
+			continue
+		}
+		t.engine.RemovePolicyUID(uid)
+		t.engine.RemoveCanaryUID(uid)
+		t.log.Info("pruned policy", "uid", uid, "revision", revision)
+	}
+	t.loaded = synced
+	return nil
+}
+
+const (
+	// SourceLabel is the label of the AgentPolicies a ClusterTarget
+	// applies, naming their source.
+	SourceLabel = "agents.sandbox.io/gitops-source"
+
+	// RevisionAnnotation is the annotation of the commit an AgentPolicy
+	// was applied from.
+	RevisionAnnotation = "agents.sandbox.io/gitops-revision"
+)
+
+// ClusterTarget applies synced policies to the cluster as AgentPolicy
+// resources, labelled with their source. It does not touch AgentPolicies
+// of the same name that another source, or a person, created.
+type ClusterTarget struct {
+	client client.Client
+	source string
+}
+
+// NewClusterTarget returns a target that applies the policies of the
+// named source with c.
+func NewClusterTarget(c client.Client, source string) *ClusterTarget {
+	if source == "" {
+		source = "default"
+	}
+	return &ClusterTarget{client: c, source: source}
+}
+
+// Sync implements Target.
+func (t *ClusterTarget) Sync(ctx context.Context, revision string, policies []*agentsv1alpha1.AgentPolicy, prune bool) error {
+	synced := make(map[types.NamespacedName]bool, len(policies))
+	for _, ap := range policies {
+		key := types.NamespacedName{Namespace: ap.Namespace, Name: ap.Name}
+		synced[key] = true
+		if err := t.apply(ctx, key, ap, revision); err != nil {
+			return fmt.Errorf("failed to apply AgentPolicy %s: %w", key, err)
+		}
+	}
+	if !prune {
+		return nil
+	}
+
+	var list agentsv1alpha1.AgentPolicyList
+	if err := t.client.List(ctx, &list, client.MatchingLabels{SourceLabel: t.source}); err != nil {
+		return fmt.Errorf("failed to list applied AgentPolicies: %w", err)
+	}
+	for i := range list.Items {
+		ap := &list.Items[i]
+		if synced[types.NamespacedName{Namespace: ap.Namespace, Name: ap.Name}] {
+			continue
+		}
+		if err := t.client.Delete(ctx, ap); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to prune AgentPolicy %s/%s: %w", ap.Namespace, ap.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply creates or updates one AgentPolicy.
+func (t *ClusterTarget) apply(ctx context.Context, key types.NamespacedName, ap *agentsv1alpha1.AgentPolicy, revision string) error {
+	var current agentsv1alpha1.AgentPolicy
+	err := t.client.Get(ctx, key, &current)
+	if apierrors.IsNotFound(err) {
+		desired := &agentsv1alpha1.AgentPolicy{Spec: ap.Spec}
+		desired.Name, desired.Namespace = key.Name, key.Namespace
+		desired.Labels, desired.Annotations = t.metadata(ap, revision)
+		return t.client.Create(ctx, desired)
+	}
+	if err != nil {
+		return err
+	}
+	if current.Labels[SourceLabel] != t.source {
+		return fmt.Errorf("exists and is not managed by source %q", t.source)
+	}
+	current.Spec = ap.Spec
+	labels, annotations := t.metadata(ap, revision)
+	if current.Annotations == nil {
+		current.Annotations = make(map[string]string)
+	}
+	for k, v := range labels {
+		current.Labels[k] = v
+	}
+	for k, v := range annotations {
+		current.Annotations[k] = v
+	}
+	return t.client.Update(ctx, &current)
+}
+
+// metadata returns the labels and annotations of an applied AgentPolicy:
+// those of its manifest, with its source and revision.
+func (t *ClusterTarget) metadata(ap *agentsv1alpha1.AgentPolicy, revision string) (map[string]string, map[string]string) {
+	labels := make(map[string]string, len(ap.Labels)+1)
+	for k, v := range ap.Labels {
+		labels[k] = v
+	}
+	labels[SourceLabel] = t.source
+	annotations := make(map[string]string, len(ap.Annotations)+1)
+	for k, v := range ap.Annotations {
+		annotations[k] = v
+	}
+	annotations[RevisionAnnotation] = revision
+	return labels, annotations
+}
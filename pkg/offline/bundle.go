@@ -0,0 +1,190 @@
+// Package offline runs the router at air-gapped sites, without the
+// Kubernetes API or network connectivity: policies are loaded from a
+// signed bundle on local storage, and audit events are spooled to local
+// storage and forwarded when connectivity returns.
+package offline
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/gitops"
+)
+
+// SignatureSuffix is appended to the path of a bundle to name the file of
+// its detached signature.
+const SignatureSuffix = ".sig"
+
+// ErrBadSignature is returned for bundles whose signature does not verify
+// with the key.
+var ErrBadSignature = errors.New("bundle signature does not verify")
+
+// Bundle is a verified policy bundle: a YAML file of AgentPolicy manifests.
+type Bundle struct {
+	// Digest is the SHA-256 of the file, in hex
+	Digest string
+
+	// Policies are the AgentPolicies of the file
+	Policies []*agentsv1alpha1.AgentPolicy
+}
+
+// Sign returns the detached signature of a bundle with key, to be written
+// next to it with SignatureSuffix.
+func Sign(data []byte, key ed25519.PrivateKey) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n")
+}
+
+// Verify checks the detached signature of a bundle with key.
+func Verify(data, signature []byte, key ed25519.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid bundle signature: %w", err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// ReadBundle reads the bundle at path, verifies its signature (at path
+// with SignatureSuffix) with key, and parses its policies. Policies
+// without a namespace are put in namespace.
+func ReadBundle(path string, key ed25519.PublicKey, namespace string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle signature: %w", err)
+	}
+	if err := Verify(data, signature, key); err != nil {
+		return nil, err
+	}
+	policies, err := gitops.ParseManifests(data, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return &Bundle{Digest: hex.EncodeToString(sum[:]), Policies: policies}, nil
+}
+
+// ParsePublicKey parses a PEM-encoded Ed25519 public key, as written by
+// "openssl pkey -pubout".
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is a %T, not Ed25519", key)
+	}
+	return public, nil
+}
+
+// ParsePrivateKey parses a PEM-encoded Ed25519 private key, as written by
+// "openssl genpkey -algorithm ed25519".
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, not Ed25519", key)
+	}
+	return private, nil
+}
+
+// BundleSource loads a policy bundle into a target, and reloads it when
+// the file changes. A bundle that does not verify or compile is refused,
+// and the policies last loaded stay in force.
+type BundleSource struct {
+	path      string
+	key       ed25519.PublicKey
+	namespace string
+	target    gitops.Target
+	log       *slog.Logger
+
+	mu     sync.Mutex
+	digest string // of the bundle last loaded
+}
+
+// NewBundleSource returns a source that loads the bundle at path,
+// verified with key, into target. Policies are put in namespace (default:
+// "default") if they have none.
+func NewBundleSource(path string, key ed25519.PublicKey, namespace string, target gitops.Target, logger *slog.Logger) *BundleSource {
+	if namespace == "" {
+		namespace = "default"
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &BundleSource{path: path, key: key, namespace: namespace, target: target, log: logger}
+}
+
+// Digest returns the digest of the bundle last loaded, or "" if none was.
+func (s *BundleSource) Digest() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.digest
+}
+
+// Load loads the bundle if it changed since it was last loaded. Policies
+// removed from the bundle are removed from the target.
+func (s *BundleSource) Load(ctx context.Context) error {
+	bundle, err := ReadBundle(s.path, s.key, s.namespace)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bundle.Digest == s.digest {
+		return nil
+	}
+	if err := s.target.Sync(ctx, bundle.Digest, bundle.Policies, true); err != nil {
+		return err
+	}
+	s.digest = bundle.Digest
+	s.log.Info("loaded policy bundle", "path", s.path, "digest", bundle.Digest, "policies", len(bundle.Policies))
+	return nil
+}
+
+// Start reloads the bundle every interval until ctx is done.
+func (s *BundleSource) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Load(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("failed to load policy bundle; the bundle last loaded stays in force", "path", s.path, "error", err)
+		}
+	}
+}
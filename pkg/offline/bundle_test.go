@@ -0,0 +1,207 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golden-agent/golden-agent/pkg/gitops"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+func agentPolicy(name, agentType, tool string) string {
+	return `apiVersion: agents.sandbox.io/v1alpha1
+kind: AgentPolicy
+metadata:
+  name: ` + name + `
+spec:
+  agentTypes: [` + agentType + `]
+  defaultAction: deny
+  mode: enforcing
+  toolPermissions:
+    - tool: ` + tool + `
+      action: allow
+`
+}
+
+func allowed(engine *policy.Engine, agentType, tool string) bool {
+	decision, _ := engine.Evaluate(context.Background(), policy.AgentContext{AgentType: agentType}, tool, nil)
+	return decision == policy.Allow
+}
+
+// writeBundle writes a bundle of contents and its signature with key.
+func writeBundle(t *testing.T, path, contents string, key ed25519.PrivateKey) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+SignatureSuffix, Sign([]byte(contents), key), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBundleSource tests that a signed bundle is loaded, reloaded when it
+// changes, and refused when its signature does not verify.
+func TestBundleSource(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	writeBundle(t, path, agentPolicy("coding", "coding-assistant", "file.read")+"---\n"+
+		agentPolicy("research", "research-agent", "web.search"), private)
+
+	engine := policy.NewEngine(policy.WithMode(policy.Enforcing))
+	source := NewBundleSource(path, public, "", gitops.NewEngineTarget(engine, false, nil), nil)
+	if err := source.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !allowed(engine, "coding-assistant", "file.read") || !allowed(engine, "research-agent", "web.search") {
+		t.Error("expected the policies of the bundle to be loaded")
+	}
+	loaded := source.Digest()
+
+	writeBundle(t, path, agentPolicy("coding", "coding-assistant", "file.write"), private)
+	if err := source.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if allowed(engine, "coding-assistant", "file.read") || !allowed(engine, "coding-assistant", "file.write") {
+		t.Error("expected the changed policy to be loaded")
+	}
+	if _, ok := engine.GetPolicy("research-agent"); ok {
+		t.Error("expected the policy removed from the bundle to be removed")
+	}
+	if source.Digest() == loaded {
+		t.Error("expected the digest to change")
+	}
+
+	// A bundle signed with another key leaves the last one in force
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	writeBundle(t, path, agentPolicy("coding", "coding-assistant", "shell.exec"), other)
+	if err := source.Load(context.Background()); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+	if allowed(engine, "coding-assistant", "shell.exec") || !allowed(engine, "coding-assistant", "file.write") {
+		t.Error("expected the last verified bundle to stay in force")
+	}
+
+	// So does a bundle without a signature
+	os.Remove(path + SignatureSuffix)
+	if err := source.Load(context.Background()); err == nil {
+		t.Error("expected a bundle without a signature to be refused")
+	}
+}
+
+// TestExportBundle tests that the policies of a router's snapshot are
+// exported as a bundle that loads into another router, and that the
+// fingerprints they were exported with are checked.
+func TestExportBundle(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	staging := policy.NewEngine(policy.WithMode(policy.Enforcing), policy.WithOPA(true))
+	writeBundle(t, filepath.Join(dir, "staging.yaml"), agentPolicy("coding", "coding-assistant", "file.read")+`      constraints:
+        pathPatterns: ["/workspace/**"]
+---
+`+agentPolicy("research", "research-agent", "web.search"), private)
+	if err := NewBundleSource(filepath.Join(dir, "staging.yaml"), public, "", gitops.NewEngineTarget(staging, true, nil), nil).Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	snapshot, err := staging.Snapshot(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ExportBundle(snapshot)
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+	if bytes.Contains(data, []byte("uid:")) || !bytes.Contains(data, []byte(FingerprintAnnotation)) {
+		t.Errorf("expected manifests without UIDs, annotated with fingerprints, got:\n%s", data)
+	}
+	path := filepath.Join(dir, "exported.yaml")
+	writeBundle(t, path, string(data), private)
+
+	bundle, err := ReadBundle(path, public, "default")
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+	if len(bundle.Policies) != 2 {
+		t.Fatalf("expected 2 policies, got %d", len(bundle.Policies))
+	}
+	if err := CheckFingerprints(bundle.Policies, true); err != nil {
+		t.Errorf("expected the exported fingerprints to match, got %v", err)
+	}
+	production := policy.NewEngine(policy.WithMode(policy.Enforcing), policy.WithOPA(true))
+	if err := NewBundleSource(path, public, "", gitops.NewEngineTarget(production, true, nil), nil).Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, agentType := range []string{"coding-assistant", "research-agent"} {
+		want, _ := staging.GetPolicy(agentType)
+		got, ok := production.GetPolicy(agentType)
+		if !ok || got.Fingerprint() != want.Fingerprint() {
+			t.Errorf("%s: expected the imported policy to be enforced the same", agentType)
+		}
+	}
+
+	bundle.Policies[0].Spec.DefaultAction = "allow"
+	if err := CheckFingerprints(bundle.Policies, true); err == nil {
+		t.Error("expected a policy that compiles differently to fail the check")
+	}
+}
+
+// TestExportBundleAnnotations tests that exported policies keep their
+// annotations next to their fingerprints.
+func TestExportBundleAnnotations(t *testing.T) {
+	snapshot := &policy.Snapshot{Policies: []policy.SnapshotPolicy{{
+		Source:      json.RawMessage(`{"apiVersion":"agents.sandbox.io/v1alpha1","kind":"AgentPolicy","metadata":{"name":"coding","uid":"1234","annotations":{"team":"platform"}},"spec":{"agentTypes":["coding-assistant"]}}`),
+		Fingerprint: "abc123",
+	}}}
+	data, err := ExportBundle(snapshot)
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+	policies, err := gitops.ParseManifests(data, "default")
+	if err != nil || len(policies) != 1 {
+		t.Fatalf("expected 1 policy, got %d (%v)", len(policies), err)
+	}
+	want := map[string]string{"team": "platform", FingerprintAnnotation: "abc123"}
+	if got := policies[0].Annotations; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected annotations %v, got %v", want, got)
+	}
+}
+
+// TestParseKeys tests that PEM-encoded Ed25519 keys round-trip.
+func TestParseKeys(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPrivate, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || !parsedPrivate.Equal(private) {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	der, err = x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPublic, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || !parsedPublic.Equal(public) {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Error("expected an error for a malformed key")
+	}
+}
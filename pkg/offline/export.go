@@ -0,0 +1,80 @@
+package offline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// FingerprintAnnotation is the annotation of an exported policy with its
+// fingerprint on the router it was exported from (see
+// policy.CompiledPolicy.Fingerprint).
+const FingerprintAnnotation = "agents.sandbox.io/fingerprint"
+
+// ExportBundle writes the policies of an engine snapshot as a bundle, to
+// back them up or to promote the policy set of one router to another: the
+// manifests they were compiled from, without their UIDs, annotated with
+// their fingerprints, next to the annotations they have. Policies that read constraint values from Secrets
+// are exported with their valuesFrom references and no fingerprint, for
+// the controller of the cluster they are imported to to resolve. Sign
+// the bundle with Sign.
+func ExportBundle(snapshot *policy.Snapshot) ([]byte, error) {
+	var bundle bytes.Buffer
+	for i, p := range snapshot.Policies {
+		var manifest map[string]interface{}
+		if err := json.Unmarshal(p.Source, &manifest); err != nil {
+			return nil, fmt.Errorf("policies[%d]: invalid manifest: %w", i, err)
+		}
+		metadata, _ := manifest["metadata"].(map[string]interface{})
+		if metadata == nil {
+			return nil, fmt.Errorf("policies[%d]: manifest has no metadata", i)
+		}
+		delete(metadata, "uid")
+		if p.Fingerprint != "" {
+			annotations, _ := metadata["annotations"].(map[string]interface{})
+			if annotations == nil {
+				annotations = make(map[string]interface{})
+			}
+			annotations[FingerprintAnnotation] = p.Fingerprint
+			metadata["annotations"] = annotations
+		}
+
+		data, err := yaml.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("policies[%d]: %w", i, err)
+		}
+		if bundle.Len() > 0 {
+			bundle.WriteString("---\n")
+		}
+		bundle.Write(data)
+	}
+	return bundle.Bytes(), nil
+}
+
+// CheckFingerprints compiles the policies of a bundle, as a router with
+// OPA enabled or not would, and checks that each compiles to the
+// fingerprint it was exported with, so that an imported policy set is
+// enforced the same as where it was exported. Policies without a
+// fingerprint are not checked.
+func CheckFingerprints(policies []*agentsv1alpha1.AgentPolicy, useOPA bool) error {
+	for _, ap := range policies {
+		want := ap.Annotations[FingerprintAnnotation]
+		if want == "" {
+			continue
+		}
+		result, err := compile.AgentPolicy(ap, useOPA)
+		if err != nil {
+			return fmt.Errorf("AgentPolicy %s/%s: %w", ap.Namespace, ap.Name, err)
+		}
+		if got := result.Policy.Fingerprint(); got != want {
+			return fmt.Errorf("AgentPolicy %s/%s compiles to fingerprint %s, not %s as where it was exported", ap.Namespace, ap.Name, got, want)
+		}
+	}
+	return nil
+}
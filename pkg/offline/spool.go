@@ -0,0 +1,375 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+const (
+	// DefaultMaxSpoolBytes bounds the spool when no bound is configured.
+	DefaultMaxSpoolBytes = 256 << 20
+
+	// segmentBytes is the size past which the spool starts a new segment.
+	segmentBytes = 1 << 20
+
+	// segmentExt is the extension of segment files.
+	segmentExt = ".jsonl"
+)
+
+// Forwarder delivers spooled audit events upstream.
+type Forwarder interface {
+	// Forward delivers events, JSON lines in the audit JSON format. An
+	// error leaves them spooled, to be forwarded again.
+	Forward(ctx context.Context, events []byte) error
+}
+
+// SpoolStats reports the state of a spool.
+type SpoolStats struct {
+	// PendingBytes is the size of the events not yet forwarded
+	PendingBytes int64
+
+	// Segments is the number of segment files not yet forwarded
+	Segments int
+
+	// Forwarded counts the segments forwarded since the spool was created
+	Forwarded uint64
+
+	// Dropped counts the events dropped since the spool was created,
+	// because the spool was full or could not be written
+	Dropped uint64
+}
+
+// Spool is an audit sink that stores events as JSON lines on local
+// storage, in segment files of a directory, until they are forwarded.
+// Events spooled before a restart are forwarded after it. Delivery is at
+// least once: a segment whose forwarding fails midway is forwarded again.
+//
+// When the spool holds its maximum size, new events are dropped and
+// counted (see Stats), so that a long outage cannot fill the disk.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	log      *slog.Logger
+
+	mu        sync.Mutex
+	opened    bool
+	file      *os.File // active segment, nil until the next event
+	fileBytes int64
+	pending   int64 // bytes of all segments
+	segments  int
+	last      int64 // timestamp of the last segment name
+	forwarded uint64
+	dropped   uint64
+	full      bool // whether drops were logged since the last write
+
+	// forwardMu serializes Forward calls
+	forwardMu sync.Mutex
+}
+
+// NewSpool returns a spool of the directory dir, bounded to maxBytes
+// (default: DefaultMaxSpoolBytes). The directory is created on Open, or on
+// the first event.
+func NewSpool(dir string, maxBytes int64, logger *slog.Logger) *Spool {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxSpoolBytes
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Spool{dir: dir, maxBytes: maxBytes, log: logger}
+}
+
+// Open creates the directory of the spool and counts the events spooled
+// before, so that storage problems surface at startup. Events are logged
+// to a spool that failed to open by opening it again.
+func (s *Spool) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openLocked()
+}
+
+func (s *Spool) openLocked() error {
+	if s.opened {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create audit spool: %w", err)
+	}
+	names, err := s.segmentNames()
+	if err != nil {
+		return fmt.Errorf("failed to read audit spool: %w", err)
+	}
+	s.pending, s.segments = 0, len(names)
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(s.dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read audit spool: %w", err)
+		}
+		s.pending += info.Size()
+	}
+	s.opened = true
+	return nil
+}
+
+// Log implements policy.AuditSink.
+func (s *Spool) Log(event *policy.AuditEvent) {
+	data, err := policy.EncodeAuditEvent(event, policy.AuditFormatJSON, "")
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(data); err != nil {
+		s.dropped++
+		if !s.full {
+			s.full = true
+			s.log.Error("dropping audit events", "spool", s.dir, "error", err)
+		}
+		return
+	}
+	s.full = false
+}
+
+// write appends a line to the active segment, starting a new one if
+// there is none or it is full.
+func (s *Spool) write(data []byte) error {
+	if err := s.openLocked(); err != nil {
+		return err
+	}
+	if s.pending+int64(len(data)) > s.maxBytes {
+		return fmt.Errorf("spool holds its maximum of %d bytes", s.maxBytes)
+	}
+	if s.file != nil && s.fileBytes >= segmentBytes {
+		s.sealLocked()
+	}
+	if s.file == nil {
+		name := s.nextName()
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+		if err != nil {
+			return fmt.Errorf("failed to create spool segment: %w", err)
+		}
+		s.file, s.fileBytes = f, 0
+		s.segments++
+	}
+	n, err := s.file.Write(data)
+	s.fileBytes += int64(n)
+	s.pending += int64(n)
+	if err != nil {
+		// Start a new segment with the next event
+		s.sealLocked()
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	return nil
+}
+
+// nextName names a new segment by the time, so that segments sort in the
+// order they were written.
+func (s *Spool) nextName() string {
+	ts := time.Now().UnixNano()
+	if ts <= s.last {
+		ts = s.last + 1
+	}
+	s.last = ts
+	return fmt.Sprintf("%020d%s", ts, segmentExt)
+}
+
+// sealLocked closes the active segment; the next event starts a new one.
+func (s *Spool) sealLocked() {
+	if s.file == nil {
+		return
+	}
+	s.file.Sync()
+	s.file.Close()
+	s.file = nil
+}
+
+// segmentNames returns the names of the segment files, oldest first.
+func (s *Spool) segmentNames() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), segmentExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Flush implements policy.AuditFlusher, committing the events spooled so
+// far to stable storage.
+func (s *Spool) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Sync()
+}
+
+// Close closes the active segment.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealLocked()
+	return nil
+}
+
+// Stats returns the state of the spool.
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpoolStats{
+		PendingBytes: s.pending,
+		Segments:     s.segments,
+		Forwarded:    s.forwarded,
+		Dropped:      s.dropped,
+	}
+}
+
+// Forward forwards the spooled segments with f, oldest first, removing
+// each once delivered. The active segment is only sealed and forwarded
+// once every older one was, so that a long outage does not leave a trail
+// of small segments. It stops at the first failure, and returns the
+// number of segments forwarded.
+func (s *Spool) Forward(ctx context.Context, f Forwarder) (int, error) {
+	s.forwardMu.Lock()
+	defer s.forwardMu.Unlock()
+
+	forwarded := 0
+	for sealActive := false; ; sealActive = true {
+		s.mu.Lock()
+		if err := s.openLocked(); err != nil {
+			s.mu.Unlock()
+			return forwarded, err
+		}
+		if sealActive {
+			s.sealLocked()
+		}
+		var active string
+		if s.file != nil {
+			active = filepath.Base(s.file.Name())
+		}
+		names, err := s.segmentNames()
+		s.mu.Unlock()
+		if err != nil {
+			return forwarded, fmt.Errorf("failed to read audit spool: %w", err)
+		}
+
+		for _, name := range names {
+			if name == active {
+				break
+			}
+			if err := s.forwardSegment(ctx, f, name); err != nil {
+				return forwarded, err
+			}
+			forwarded++
+		}
+		if active == "" || sealActive {
+			return forwarded, nil
+		}
+	}
+}
+
+// forwardSegment forwards a sealed segment and removes it.
+func (s *Spool) forwardSegment(ctx context.Context, f Forwarder, name string) error {
+	path := filepath.Join(s.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read spool segment: %w", err)
+	}
+	if len(data) > 0 {
+		if err := f.Forward(ctx, data); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove forwarded spool segment: %w", err)
+	}
+
+	s.mu.Lock()
+	s.pending -= int64(len(data))
+	s.segments--
+	s.forwarded++
+	s.mu.Unlock()
+	return nil
+}
+
+// Run forwards the spool with f every interval until ctx is done, logging
+// when forwarding fails and when it resumes.
+func (s *Spool) Run(ctx context.Context, f Forwarder, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		n, err := s.Forward(ctx, f)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			if !failing {
+				s.log.Warn("failed to forward audit events; they stay spooled", "spool", s.dir, "error", err)
+			}
+			failing = true
+		case err == nil && failing:
+			stats := s.Stats()
+			s.log.Info("resumed forwarding audit events", "spool", s.dir, "segments", n, "dropped", stats.Dropped)
+			failing = false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HTTPForwarder forwards audit events by POSTing them to a URL as
+// newline-delimited JSON.
+type HTTPForwarder struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPForwarder returns a forwarder to url. A nil client uses one with
+// a 30s timeout.
+func NewHTTPForwarder(url string, client *http.Client) *HTTPForwarder {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &HTTPForwarder{url: url, client: client}
+}
+
+// Forward implements Forwarder. Any 2xx status is a delivery.
+func (f *HTTPForwarder) Forward(ctx context.Context, events []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(events))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+// collector is a Forwarder that records events, or fails while down.
+type collector struct {
+	mu     sync.Mutex
+	down   bool
+	events []byte
+}
+
+func (c *collector) Forward(ctx context.Context, events []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return errors.New("unreachable")
+	}
+	c.events = append(c.events, events...)
+	return nil
+}
+
+func (c *collector) lines() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Count(c.events, []byte("\n"))
+}
+
+func auditEvent(tool string) *policy.AuditEvent {
+	return &policy.AuditEvent{
+		Timestamp: time.Now(),
+		Decision:  policy.Deny,
+		Tool:      tool,
+		Agent:     policy.AgentContext{AgentType: "plc-agent", SandboxID: "sb-1"},
+	}
+}
+
+// TestSpoolStoreAndForward tests that events are kept while the collector
+// is unreachable, survive a restart, and are forwarded once it returns.
+func TestSpoolStoreAndForward(t *testing.T) {
+	dir := t.TempDir()
+	c := &collector{down: true}
+
+	spool := NewSpool(dir, 0, nil)
+	if err := spool.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		spool.Log(auditEvent("plc.write"))
+	}
+	if _, err := spool.Forward(context.Background(), c); err == nil {
+		t.Fatal("expected forwarding to fail while the collector is down")
+	}
+	if stats := spool.Stats(); stats.PendingBytes == 0 || stats.Segments != 1 {
+		t.Errorf("expected the events to stay spooled, got %+v", stats)
+	}
+
+	// A restart keeps the spooled events
+	spool.Close()
+	spool = NewSpool(dir, 0, nil)
+	if err := spool.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	spool.Log(auditEvent("plc.read"))
+	if stats := spool.Stats(); stats.Segments != 2 {
+		t.Errorf("expected the segment of the first run and a new one, got %+v", stats)
+	}
+
+	c.down = false
+	n, err := spool.Forward(context.Background(), c)
+	if err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	if n != 2 || c.lines() != 4 {
+		t.Errorf("expected 2 segments of 4 events forwarded, got %d segments of %d events", n, c.lines())
+	}
+	events, stats, err := replay.ReadEvents(bytes.NewReader(c.events), replay.Window{})
+	if err != nil || len(events) != 4 || stats.Malformed != 0 || events[3].Tool != "plc.read" {
+		t.Errorf("expected the events in order in the audit JSON format, got %v (%+v, %v)", events, stats, err)
+	}
+	if stats := spool.Stats(); stats.PendingBytes != 0 || stats.Segments != 0 || stats.Forwarded != 2 {
+		t.Errorf("expected an empty spool, got %+v", stats)
+	}
+
+	// Later events go to a new segment
+	spool.Log(auditEvent("plc.read"))
+	if _, err := spool.Forward(context.Background(), c); err != nil || c.lines() != 5 {
+		t.Errorf("expected the later event to be forwarded, got %d events (%v)", c.lines(), err)
+	}
+}
+
+// TestSpoolFull tests that events past the bound of the spool are dropped
+// and counted.
+func TestSpoolFull(t *testing.T) {
+	spool := NewSpool(t.TempDir(), 1, nil)
+	spool.Log(auditEvent("plc.write"))
+	if stats := spool.Stats(); stats.Dropped != 1 || stats.PendingBytes != 0 {
+		t.Errorf("expected the event to be dropped, got %+v", stats)
+	}
+}
+
+// TestHTTPForwarder tests that events are POSTed as NDJSON, and that
+// failed responses leave them spooled.
+func TestHTTPForwarder(t *testing.T) {
+	var mu sync.Mutex
+	var received []byte
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		if status == http.StatusOK {
+			received, _ = io.ReadAll(r.Body)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	spool := NewSpool(t.TempDir(), 0, nil)
+	spool.Log(auditEvent("plc.write"))
+	forwarder := NewHTTPForwarder(server.URL, nil)
+	if _, err := spool.Forward(context.Background(), forwarder); err == nil {
+		t.Fatal("expected a 503 to fail forwarding")
+	}
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	if _, err := spool.Forward(context.Background(), forwarder); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if bytes.Count(received, []byte("\n")) != 1 {
+		t.Errorf("expected one event, got %q", received)
+	}
+}
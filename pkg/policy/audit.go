@@ -3,10 +3,13 @@
 package policy
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -43,7 +46,21 @@ func (e *AuditEmitter) AddSink(sink AuditSink) {
 // Log sends an audit event to all registered sinks.
 // Implements the AuditSink interface.
 func (e *AuditEmitter) Log(event *AuditEvent) {
-	// Update stats
+	if event.Execution == nil {
+		e.countDecision(event)
+	}
+
+	// Send to all sinks
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, sink := range e.sinks {
+		sink.Log(event)
+	}
+}
+
+// countDecision counts a decision event in the emitter's stats.
+func (e *AuditEmitter) countDecision(event *AuditEvent) {
 	e.statsMu.Lock()
 	e.totalEvents++
 	if event.Decision == Allow {
@@ -55,14 +72,23 @@ func (e *AuditEmitter) Log(event *AuditEvent) {
 		e.cachedEvents++
 	}
 	e.statsMu.Unlock()
+}
 
-	// Send to all sinks
+// Flush flushes every sink that buffers events.
+// Implements the AuditFlusher interface.
+func (e *AuditEmitter) Flush() error {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	var errs []error
 	for _, sink := range e.sinks {
-		sink.Log(event)
+		if f, ok := sink.(AuditFlusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
+	return errors.Join(errs...)
 }
 
 // Stats returns audit statistics.
@@ -79,6 +105,8 @@ func (e *AuditEmitter) Stats() (total, allow, deny, cached uint64) {
 type StdoutAuditSink struct {
 	// OnlyDenials filters to only log deny events (like ausearch --message AVC)
 	OnlyDenials bool
+
+	coalescer *denialCoalescer
 }
 
 // NewStdoutAuditSink creates a sink that logs to stdout.
@@ -86,17 +114,41 @@ func NewStdoutAuditSink(onlyDenials bool) *StdoutAuditSink {
 	return &StdoutAuditSink{OnlyDenials: onlyDenials}
 }
 
+// CoalesceDenials makes the sink coalesce identical denials, those of an
+// agent's calls to a tool denied for the same reason, within window of
+// the first: they are written as one line counting them (repeated=N) when
+// the window closes.
+func (s *StdoutAuditSink) CoalesceDenials(window time.Duration) {
+	s.coalescer = newDenialCoalescer(window, s.write)
+}
+
 // Log writes the event to stdout in AVC-style format.
 func (s *StdoutAuditSink) Log(event *AuditEvent) {
 	if s.OnlyDenials && event.Decision == Allow {
 		return
 	}
+	if s.coalescer.admit(event) {
+		s.write(event)
+	}
+}
+
+func (s *StdoutAuditSink) write(event *AuditEvent) {
 	fmt.Fprintln(os.Stdout, formatAVC(event))
 }
 
+// Close writes the denials coalesced so far.
+func (s *StdoutAuditSink) Close() error {
+	s.coalescer.flush()
+	return nil
+}
+
 // formatAVC formats an audit event like SELinux AVC logs:
 // type=AVC msg=audit(timestamp): avc: denied { tool_call } for tool="file.read" agent="coding-assistant" reason="no permission"
 func formatAVC(event *AuditEvent) string {
+	if event.Execution != nil {
+		return formatExecution(event)
+	}
+
 	action := "granted"
 	if event.Decision == Deny {
 		action = "denied"
@@ -107,8 +159,44 @@ func formatAVC(event *AuditEvent) string {
 		cached = " cached=1"
 	}
 
+	labels := ""
+	if len(event.Agent.Labels) > 0 {
+		labels = fmt.Sprintf(" labels=%q", formatLabels(event.Agent.Labels))
+	}
+
+	combining := ""
+	if event.Combining != "" {
+		combining = " combining=" + string(event.Combining)
+	}
+
+	rawTool := ""
+	if event.RawTool != "" {
+		rawTool = fmt.Sprintf(" raw_tool=%q", event.RawTool)
+	}
+
+	risk := ""
+	if event.RiskLevel != "" {
+		risk = " risk=" + string(event.RiskLevel)
+	}
+
+	repeated := ""
+	if event.Repeated > 0 {
+		repeated = fmt.Sprintf(" repeated=%d", event.Repeated)
+	}
+
+	source := ""
+	if event.Source != "" {
+		source = " source=" + event.Source
+	}
+
+	// Like SELinux, denials the mode allowed are marked permissive=1
+	permissive := ""
+	if event.Decision == Deny && event.EnforcedDecision == Allow {
+		permissive = " permissive=1"
+	}
+
 	return fmt.Sprintf(
-		"type=AVC msg=audit(%d.%03d:%s): avc: %s { tool_call } for tool=%q agent_type=%q sandbox=%q tenant=%q mts=%q reason=%q%s",
+		"type=AVC msg=audit(%d.%03d:%s): avc: %s { tool_call } for tool=%q agent_type=%q sandbox=%q tenant=%q mts=%q reason=%q mode=%s%s%s%s%s%s%s%s%s",
 		event.Timestamp.Unix(),
 		event.Timestamp.Nanosecond()/1e6, // milliseconds
 		event.RequestID,
@@ -119,10 +207,59 @@ func formatAVC(event *AuditEvent) string {
 		event.Agent.TenantID,
 		event.Agent.MTSLabel,
 		event.Reason,
+		event.Mode,
+		permissive,
+		labels,
 		cached,
+		combining,
+		rawTool,
+		risk,
+		repeated,
+		source,
+	)
+}
+
+// formatExecution formats an execution event like the records following
+// an AVC record of the same msg=audit(timestamp:request):
+// type=TOOL_EXEC msg=audit(timestamp:request): tool="file.write" agent_type="coding-assistant" status=success duration_ms=12 result_bytes=42 attempts=1
+func formatExecution(event *AuditEvent) string {
+	x := event.Execution
+	errMsg := ""
+	if x.Error != "" {
+		errMsg = fmt.Sprintf(" error=%q", x.Error)
+	}
+	return fmt.Sprintf(
+		"type=TOOL_EXEC msg=audit(%d.%03d:%s): tool=%q agent_type=%q sandbox=%q tenant=%q status=%s duration_ms=%d result_bytes=%d attempts=%d%s",
+		event.Timestamp.Unix(),
+		event.Timestamp.Nanosecond()/1e6, // milliseconds
+		event.RequestID,
+		event.Tool,
+		event.Agent.AgentType,
+		event.Agent.SandboxID,
+		event.Agent.TenantID,
+		x.Status,
+		x.Duration.Milliseconds(),
+		x.ResultBytes,
+		x.Attempts,
+		errMsg,
 	)
 }
 
+// formatLabels renders labels as a sorted "k=v,k=v" string.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
 // JSONAuditSink logs events as JSON lines to a writer.
 // Suitable for structured logging systems (ELK, Splunk, CloudWatch).
 type JSONAuditSink struct {
@@ -131,25 +268,66 @@ type JSONAuditSink struct {
 
 	// OnlyDenials filters to only log deny events
 	OnlyDenials bool
+
+	// Format is AuditFormatJSON (the default) or AuditFormatCloudEvents
+	Format string
+
+	// Source is the CloudEvents source (default: DefaultCloudEventSource)
+	Source string
+
+	// Chain, when set, makes the lines tamper-evident (see AuditChain)
+	Chain *AuditChain
 }
 
-// JSONAuditEvent is the JSON representation of an audit event.
+// JSONAuditEvent is the JSON representation of an audit event. Its schema
+// is versioned by AuditSchemaVersion. RawDecision and EnforcedDecision are
+// the decision before and after the enforcement mode is applied; Decision
+// is the raw decision, as before they were recorded.
 type JSONAuditEvent struct {
-	Type      string `json:"type"`
-	Timestamp string `json:"timestamp"`
-	RequestID string `json:"request_id"`
-	Decision  string `json:"decision"`
-	Tool      string `json:"tool"`
-	Agent     struct {
-		Type      string `json:"type"`
-		SandboxID string `json:"sandbox_id"`
-		TenantID  string `json:"tenant_id"`
-		SessionID string `json:"session_id"`
-		MTSLabel  string `json:"mts_label"`
-		PolicyRef string `json:"policy_ref"`
+	SchemaVersion    string `json:"schema_version"`
+	Type             string `json:"type"`
+	Timestamp        string `json:"timestamp"`
+	RequestID        string `json:"request_id"`
+	Decision         string `json:"decision"`
+	RawDecision      string `json:"raw_decision,omitempty"`
+	EnforcedDecision string `json:"enforced_decision,omitempty"`
+	Tool             string `json:"tool"`
+	RawTool          string `json:"raw_tool,omitempty"`
+	RiskLevel        string `json:"risk_level,omitempty"`
+	Mode             string `json:"mode,omitempty"`
+	Agent            struct {
+		Type      string            `json:"type"`
+		SandboxID string            `json:"sandbox_id"`
+		TenantID  string            `json:"tenant_id"`
+		SessionID string            `json:"session_id"`
+		MTSLabel  string            `json:"mts_label"`
+		PolicyRef string            `json:"policy_ref"`
+		Labels    map[string]string `json:"labels,omitempty"`
+
+		ParentSessionID string   `json:"parent_session_id,omitempty"`
+		RootSessionID   string   `json:"root_session_id,omitempty"`
+		Lineage         []string `json:"lineage,omitempty"`
 	} `json:"agent"`
-	Reason string `json:"reason"`
-	Cached bool   `json:"cached"`
+	Reason     string                 `json:"reason"`
+	Cached     bool                   `json:"cached"`
+	Combining  string                 `json:"combining,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Repeated   int                    `json:"repeated,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+
+	// Execution is set on events of type "EXECUTION", which record the
+	// outcome of the execution of the call decided by the "AVC" event of
+	// the same request_id
+	Execution *JSONExecution `json:"execution,omitempty"`
+}
+
+// JSONExecution is the JSON representation of an ExecutionRecord.
+type JSONExecution struct {
+	Status      string `json:"status"`
+	DurationNs  int64  `json:"duration_ns"`
+	ResultBytes int    `json:"result_bytes"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
 }
 
 // NewJSONAuditSink creates a sink that writes JSON lines.
@@ -160,34 +338,68 @@ func NewJSONAuditSink(w io.Writer, onlyDenials bool) *JSONAuditSink {
 	}
 }
 
-// Log writes the event as a JSON line.
-func (s *JSONAuditSink) Log(event *AuditEvent) {
-	if s.OnlyDenials && event.Decision == Allow {
-		return
-	}
-
+// newJSONAuditEvent converts an audit event to its JSON representation.
+func newJSONAuditEvent(event *AuditEvent) JSONAuditEvent {
 	jsonEvent := JSONAuditEvent{
-		Type:      "AVC",
-		Timestamp: event.Timestamp.Format(time.RFC3339Nano),
-		RequestID: event.RequestID,
-		Decision:  event.Decision.String(),
-		Tool:      event.Tool,
-		Reason:    event.Reason,
-		Cached:    event.Cached,
+		SchemaVersion: AuditSchemaVersion,
+		Type:          "AVC",
+		Timestamp:     event.Timestamp.Format(time.RFC3339Nano),
+		RequestID:     event.RequestID,
+		Decision:      event.Decision.String(),
+		RawDecision:   event.Decision.String(),
+		Tool:          event.Tool,
+		RawTool:       event.RawTool,
+		RiskLevel:     string(event.RiskLevel),
+		Mode:          event.Mode.String(),
+		Reason:        event.Reason,
+		Cached:        event.Cached,
+		Combining:     string(event.Combining),
+		Parameters:    event.Parameters,
+		Repeated:      event.Repeated,
+		Source:        event.Source,
 	}
+	jsonEvent.EnforcedDecision = event.EnforcedDecision.String()
 	jsonEvent.Agent.Type = event.Agent.AgentType
 	jsonEvent.Agent.SandboxID = event.Agent.SandboxID
 	jsonEvent.Agent.TenantID = event.Agent.TenantID
 	jsonEvent.Agent.SessionID = event.Agent.SessionID
 	jsonEvent.Agent.MTSLabel = event.Agent.MTSLabel
 	jsonEvent.Agent.PolicyRef = event.Agent.PolicyRef
+	jsonEvent.Agent.Labels = event.Agent.Labels
+	if len(event.Agent.Lineage) > 0 || event.Agent.ParentSessionID != "" {
+		jsonEvent.Agent.ParentSessionID = event.Agent.ParentSessionID
+		jsonEvent.Agent.RootSessionID = event.Agent.RootSessionID()
+		jsonEvent.Agent.Lineage = event.Agent.Lineage
+	}
+	if x := event.Execution; x != nil {
+		jsonEvent.Type = "EXECUTION"
+		jsonEvent.Execution = &JSONExecution{
+			Status:      string(x.Status),
+			DurationNs:  x.Duration.Nanoseconds(),
+			ResultBytes: x.ResultBytes,
+			Attempts:    x.Attempts,
+			Error:       x.Error,
+		}
+	}
+	return jsonEvent
+}
 
-	data, err := json.Marshal(jsonEvent)
+// Log writes the event as a JSON line in the sink's format.
+func (s *JSONAuditSink) Log(event *AuditEvent) {
+	if s.OnlyDenials && event.Decision == Allow {
+		return
+	}
+
+	data, err := EncodeAuditEvent(event, s.Format, s.Source)
 	if err != nil {
 		return // Silently drop on marshal error
 	}
 
 	s.mu.Lock()
+	if s.Chain != nil {
+		// Sealed under the lock, so that lines are chained in write order
+		data = s.Chain.Seal(data)
+	}
 	s.writer.Write(data)
 	s.writer.Write([]byte("\n"))
 	s.mu.Unlock()
@@ -199,19 +411,22 @@ type FileAuditSink struct {
 	file        *os.File
 	mu          sync.Mutex
 	onlyDenials bool
-	format      string // "avc" or "json"
+	format      string // AuditFormatAVC, AuditFormatJSON, or AuditFormatCloudEvents
+	chain       *AuditChain
+	coalescer   *denialCoalescer
 }
 
 // NewFileAuditSink creates a sink that writes to a file.
-// Format can be "avc" for SELinux-style or "json" for structured logs.
+// Format can be "avc" for SELinux-style, "json" for structured logs, or
+// "cloudevents" for CloudEvents (see CloudEvent).
 func NewFileAuditSink(path string, format string, onlyDenials bool) (*FileAuditSink, error) {
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open audit log: %w", err)
 	}
 
-	if format != "avc" && format != "json" {
-		format = "avc" // Default to AVC format
+	if format != AuditFormatAVC && format != AuditFormatJSON && format != AuditFormatCloudEvents {
+		format = AuditFormatAVC // Default to AVC format
 	}
 
 	return &FileAuditSink{
@@ -222,33 +437,31 @@ func NewFileAuditSink(path string, format string, onlyDenials bool) (*FileAuditS
 	}, nil
 }
 
+// CoalesceDenials makes the sink coalesce identical denials, as
+// StdoutAuditSink.CoalesceDenials does.
+func (s *FileAuditSink) CoalesceDenials(window time.Duration) {
+	s.coalescer = newDenialCoalescer(window, s.write)
+}
+
 // Log writes the event to the file.
 func (s *FileAuditSink) Log(event *AuditEvent) {
 	if s.onlyDenials && event.Decision == Allow {
 		return
 	}
+	if s.coalescer.admit(event) {
+		s.write(event)
+	}
+}
 
+func (s *FileAuditSink) write(event *AuditEvent) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.format == "json" {
-		jsonEvent := JSONAuditEvent{
-			Type:      "AVC",
-			Timestamp: event.Timestamp.Format(time.RFC3339Nano),
-			RequestID: event.RequestID,
-			Decision:  event.Decision.String(),
-			Tool:      event.Tool,
-			Reason:    event.Reason,
-			Cached:    event.Cached,
+	if s.format != AuditFormatAVC {
+		data, _ := EncodeAuditEvent(event, s.format, "")
+		if s.chain != nil {
+			data = s.chain.Seal(data)
 		}
-		jsonEvent.Agent.Type = event.Agent.AgentType
-		jsonEvent.Agent.SandboxID = event.Agent.SandboxID
-		jsonEvent.Agent.TenantID = event.Agent.TenantID
-		jsonEvent.Agent.SessionID = event.Agent.SessionID
-		jsonEvent.Agent.MTSLabel = event.Agent.MTSLabel
-		jsonEvent.Agent.PolicyRef = event.Agent.PolicyRef
-
-		data, _ := json.Marshal(jsonEvent)
 		s.file.Write(data)
 		s.file.Write([]byte("\n"))
 	} else {
@@ -256,8 +469,43 @@ func (s *FileAuditSink) Log(event *AuditEvent) {
 	}
 }
 
-// Close closes the file.
+// SetChain makes the lines of the file tamper-evident with chain,
+// continuing the chain of the file's last line. If that line is not
+// chained, a new chain is started with a restart marker. The format must
+// be a JSON one.
+func (s *FileAuditSink) SetChain(chain *AuditChain) error {
+	if s.format == AuditFormatAVC {
+		return fmt.Errorf("audit chaining requires a JSON format, not %q", s.format)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, terminated, err := lastLine(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if !chain.Resume(last) && len(bytes.TrimSpace(last)) > 0 {
+		marker := chain.Seal(restartMarker(last, time.Now()))
+		if !terminated {
+			marker = append([]byte("\n"), marker...)
+		}
+		if _, err := s.file.Write(append(marker, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	s.chain = chain
+	return nil
+}
+
+// Flush commits the events written so far to stable storage.
+func (s *FileAuditSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Sync()
+}
+
+// Close writes the denials coalesced so far, and closes the file.
 func (s *FileAuditSink) Close() error {
+	s.coalescer.flush()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.file.Close()
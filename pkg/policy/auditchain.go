@@ -0,0 +1,313 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrAuditChainBroken is returned by VerifyAuditChain for a record that was
+// altered, forged, removed, or reordered.
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// maxAuditLine bounds the audit lines the chain reads back.
+const maxAuditLine = 1 << 20
+
+// auditRestartType is the type of restart markers.
+const auditRestartType = "AUDIT_CHAIN_RESTART"
+
+// AuditChain makes JSON audit lines tamper-evident. It appends to each line
+// an "integrity" object holding the record's sequence number, the SHA-256
+// of the previous line, and an HMAC-SHA256 of the line up to the HMAC:
+//
+//	{..., "integrity": {"seq": 42, "prev": "9f86d0...", "hmac": "2cf24d..."}}
+//
+// The HMAC proves each record was written by a holder of the key; the
+// hashes link each record to its predecessor, so that removed and
+// reordered records break the chain. Chains start at sequence 1 with no
+// previous hash, such as when the router restarts on stdout. Records
+// appended to a chained file continue the chain of its last line; if that
+// line is not chained, such as one torn by a crash, the new chain starts
+// with a restart marker that names it:
+//
+//	{"type": "AUDIT_CHAIN_RESTART", "timestamp": "...", "after": "<sha256>", "integrity": {...}}
+//
+// Only the JSON audit formats can be chained, and only lines written
+// byte-for-byte as the chain sealed them verify.
+type AuditChain struct {
+	key []byte
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// auditIntegrity is the "integrity" object of a chained line.
+type auditIntegrity struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev"`
+	HMAC string `json:"hmac"`
+}
+
+// NewAuditChain returns a chain that signs records with key.
+func NewAuditChain(key []byte) *AuditChain {
+	return &AuditChain{key: key}
+}
+
+// Seal returns a JSON object line, as EncodeAuditEvent encodes it, with
+// the record's integrity appended.
+func (c *AuditChain) Seal(line []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	unsigned := unsignedAuditLine(line, c.seq, c.prev)
+	sealed := append(unsigned, `,"hmac":"`...)
+	sealed = append(sealed, auditHMAC(c.key, unsigned)...)
+	sealed = append(sealed, `"}}`...)
+
+	sum := sha256.Sum256(sealed)
+	c.prev = hex.EncodeToString(sum[:])
+	return sealed
+}
+
+// Resume continues the chain after line, the last line written, and
+// reports whether it could. After an empty or unchained line, a new chain
+// is started.
+func (c *AuditChain) Resume(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	integrity, ok := parseAuditIntegrity(line)
+	if !ok {
+		c.seq, c.prev = 0, ""
+		return false
+	}
+	sum := sha256.Sum256(line)
+	c.seq, c.prev = integrity.Seq, hex.EncodeToString(sum[:])
+	return true
+}
+
+// auditRestartMarker is the record that starts a new chain after a line
+// the chain could not continue.
+type auditRestartMarker struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+
+	// After is the SHA-256 of the line the marker follows
+	After string `json:"after"`
+}
+
+// restartMarker returns the unsealed restart marker that follows line.
+func restartMarker(line []byte, now time.Time) []byte {
+	sum := sha256.Sum256(bytes.TrimSpace(line))
+	data, _ := json.Marshal(auditRestartMarker{
+		Type:      auditRestartType,
+		Timestamp: now.UTC().Format(time.RFC3339Nano),
+		After:     hex.EncodeToString(sum[:]),
+	})
+	return data
+}
+
+// isRestartMarker reports whether line is a restart marker following the
+// line whose SHA-256 is after.
+func isRestartMarker(line []byte, after string) bool {
+	var marker auditRestartMarker
+	if err := json.Unmarshal(line, &marker); err != nil {
+		return false
+	}
+	return marker.Type == auditRestartType && marker.After == after
+}
+
+// IsAuditRestartMarker reports whether an audit line is a restart marker
+// of a chained file rather than an audit event.
+func IsAuditRestartMarker(line []byte) bool {
+	var marker struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(line, &marker) == nil && marker.Type == auditRestartType
+}
+
+// unsignedAuditLine returns line, a JSON object, with the integrity object
+// opened and filled up to the HMAC.
+func unsignedAuditLine(line []byte, seq uint64, prev string) []byte {
+	line = bytes.TrimRight(line, " \n")
+	out := make([]byte, 0, len(line)+160)
+	out = append(out, line[:len(line)-1]...)
+	if len(line) > 2 {
+		out = append(out, ',')
+	}
+	out = append(out, `"integrity":{"seq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	out = append(out, `,"prev":"`...)
+	out = append(out, prev...)
+	out = append(out, '"')
+	return out
+}
+
+// auditHMAC returns the hex HMAC-SHA256 of data.
+func auditHMAC(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseAuditIntegrity returns the integrity object of a chained line.
+func parseAuditIntegrity(line []byte) (auditIntegrity, bool) {
+	var record struct {
+		Integrity *auditIntegrity `json:"integrity"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil || record.Integrity == nil {
+		return auditIntegrity{}, false
+	}
+	return *record.Integrity, true
+}
+
+// AuditChainReport summarizes a verified audit chain.
+type AuditChainReport struct {
+	// Records is the number of records verified
+	Records int
+
+	// FirstSeq and LastSeq are the sequence numbers of the first and last
+	// records
+	FirstSeq, LastSeq uint64
+
+	// Restarts are the chains started after the first record, at
+	// sequence 1 with no previous hash
+	Restarts []AuditChainRestart
+}
+
+// AuditChainRestart is a chain started after the first record.
+type AuditChainRestart struct {
+	// Line is the line number of the record that starts the chain
+	Line int
+
+	// AfterSeq is the sequence number of the last record of the previous
+	// chain, or 0 if the chain starts after a line that is not chained
+	AfterSeq uint64
+}
+
+// AuditChainOptions are the restarts VerifyAuditChain accepts. By default,
+// none are: every record after the first must continue its chain.
+type AuditChainOptions struct {
+	// AllowRestarts accepts chains started after the first record, as the
+	// json sink starts one each time the router starts
+	AllowRestarts bool
+
+	// RequireRestartMarkers only accepts the restarts that begin with a
+	// restart marker, as the file sink writes, and then also the
+	// unchained line (such as one torn by a crash) a marker names
+	RequireRestartMarkers bool
+}
+
+// VerifyAuditChain verifies the chained JSON audit lines read from r with
+// key: every record must carry a valid HMAC, and follow the previous one in
+// sequence and by hash, unless it starts a new chain that opts accept. The
+// records before the first one read cannot be verified, so the report
+// gives its sequence number. Returns ErrAuditChainBroken, with the line
+// number, for the first record that fails.
+func VerifyAuditChain(r io.Reader, key []byte, opts AuditChainOptions) (AuditChainReport, error) {
+	var report AuditChainReport
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+
+	var prev string
+	lineNum := 0
+	unchained := 0 // the line number of an unchained line a marker must follow
+	broken := func(lineNum int, format string, args ...interface{}) (AuditChainReport, error) {
+		return report, fmt.Errorf("%w: line %d: %s", ErrAuditChainBroken, lineNum, fmt.Sprintf(format, args...))
+	}
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		sum := sha256.Sum256(line)
+
+		integrity, ok := parseAuditIntegrity(line)
+		if !ok {
+			if unchained > 0 || !opts.AllowRestarts || !opts.RequireRestartMarkers {
+				return broken(lineNum, "record is not chained")
+			}
+			unchained = lineNum
+			prev = hex.EncodeToString(sum[:])
+			continue
+		}
+		// The HMAC covers the line up to itself, which is last
+		i := bytes.LastIndex(line, []byte(`,"hmac":"`))
+		if i < 0 || !hmac.Equal(line[i:], []byte(`,"hmac":"`+auditHMAC(key, line[:i])+`"}}`)) {
+			return broken(lineNum, "HMAC mismatch at seq %d", integrity.Seq)
+		}
+
+		restart := integrity.Seq == 1 && integrity.Prev == ""
+		switch {
+		case unchained > 0:
+			if !restart || !isRestartMarker(line, prev) {
+				return broken(unchained, "record is not chained")
+			}
+			if report.Records == 0 {
+				report.FirstSeq = integrity.Seq
+			}
+			report.Restarts = append(report.Restarts, AuditChainRestart{Line: lineNum})
+			unchained = 0
+		case report.Records == 0:
+			report.FirstSeq = integrity.Seq
+		case restart && !opts.AllowRestarts:
+			return broken(lineNum, "chain restarts after seq %d", report.LastSeq)
+		case restart && opts.RequireRestartMarkers && !isRestartMarker(line, prev):
+			return broken(lineNum, "chain restarts after seq %d without a restart marker", report.LastSeq)
+		case restart:
+			report.Restarts = append(report.Restarts, AuditChainRestart{Line: lineNum, AfterSeq: report.LastSeq})
+		case integrity.Seq != report.LastSeq+1:
+			return broken(lineNum, "seq %d follows seq %d", integrity.Seq, report.LastSeq)
+		case integrity.Prev != prev:
+			return broken(lineNum, "previous hash mismatch at seq %d", integrity.Seq)
+		}
+		report.Records++
+		report.LastSeq = integrity.Seq
+		prev = hex.EncodeToString(sum[:])
+	}
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+	if unchained > 0 {
+		return broken(unchained, "record is not chained")
+	}
+	return report, nil
+}
+
+// lastLine returns the last line of a file, up to maxAuditLine bytes, and
+// whether the file ends with a newline.
+func lastLine(path string) ([]byte, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	offset := info.Size() - maxAuditLine
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	terminated := len(buf) == 0 || buf[len(buf)-1] == '\n'
+	buf = bytes.TrimRight(buf, "\n")
+	return buf[bytes.LastIndexByte(buf, '\n')+1:], terminated, nil
+}
@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chainedLines writes n events through a chained JSON sink and returns the
+// lines.
+func chainedLines(t *testing.T, key []byte, n int) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	sink := NewJSONAuditSink(&buf, false)
+	sink.Chain = NewAuditChain(key)
+	for i := 0; i < n; i++ {
+		sink.Log(&AuditEvent{
+			Timestamp: time.Unix(int64(i), 0),
+			Agent:     AgentContext{AgentType: "coding-assistant"},
+			Tool:      "file.read",
+			Decision:  Allow,
+			RequestID: "req-" + string(rune('a'+i)),
+		})
+	}
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+// TestAuditChain tests that tampering with chained audit lines is
+// detected.
+func TestAuditChain(t *testing.T) {
+	key := []byte("audit-key")
+	lines := chainedLines(t, key, 4)
+
+	report, err := VerifyAuditChain(strings.NewReader(strings.Join(lines, "\n")), key, AuditChainOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Records != 4 || report.FirstSeq != 1 || report.LastSeq != 4 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// The chained lines stay audit events
+	var event JSONAuditEvent
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil || event.RequestID != "req-a" {
+		t.Errorf("expected a decodable event, got %+v, %v", event, err)
+	}
+
+	tampered := func(lines []string) string { return strings.Join(lines, "\n") }
+	altered := append([]string(nil), lines...)
+	altered[1] = strings.Replace(altered[1], `"ALLOW"`, `"DENY"`, 1)
+	removed := append(append([]string(nil), lines[:1]...), lines[2:]...)
+	reordered := []string{lines[0], lines[2], lines[1], lines[3]}
+	unchained := append(append([]string(nil), lines...), `{"decision":"allow"}`)
+
+	tests := []struct {
+		name  string
+		input string
+		key   []byte
+	}{
+		{"altered", tampered(altered), key},
+		{"removed", tampered(removed), key},
+		{"reordered", tampered(reordered), key},
+		{"unchained", tampered(unchained), key},
+		{"wrong key", tampered(lines), []byte("other-key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyAuditChain(strings.NewReader(tt.input), tt.key, AuditChainOptions{}); !errors.Is(err, ErrAuditChainBroken) {
+				t.Errorf("expected ErrAuditChainBroken, got %v", err)
+			}
+		})
+	}
+
+	// A tail of the log verifies from its first record, and a restarted
+	// chain only when restarts are accepted, which reports it
+	restarted := append(append([]string(nil), lines[2:]...), chainedLines(t, key, 2)...)
+	if _, err := VerifyAuditChain(strings.NewReader(tampered(restarted)), key, AuditChainOptions{}); !errors.Is(err, ErrAuditChainBroken) {
+		t.Errorf("expected an unaccepted restart to break the chain, got %v", err)
+	}
+	report, err = VerifyAuditChain(strings.NewReader(tampered(restarted)), key, AuditChainOptions{AllowRestarts: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Records != 4 || report.FirstSeq != 3 || report.LastSeq != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if want := []AuditChainRestart{{Line: 3, AfterSeq: 4}}; !reflect.DeepEqual(report.Restarts, want) {
+		t.Errorf("expected restarts %+v, got %+v", want, report.Restarts)
+	}
+
+	// Restarts without a marker are rejected where markers are required
+	marked := AuditChainOptions{AllowRestarts: true, RequireRestartMarkers: true}
+	if _, err := VerifyAuditChain(strings.NewReader(tampered(restarted)), key, marked); !errors.Is(err, ErrAuditChainBroken) {
+		t.Errorf("expected an unmarked restart to break the chain, got %v", err)
+	}
+}
+
+// TestFileAuditSinkChain tests that a reopened file continues its chain.
+func TestFileAuditSinkChain(t *testing.T) {
+	key := []byte("audit-key")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	event := &AuditEvent{Timestamp: time.Now(), Agent: AgentContext{AgentType: "coding-assistant"}, Tool: "file.read", Decision: Deny}
+
+	for run := 0; run < 2; run++ {
+		sink, err := NewFileAuditSink(path, AuditFormatCloudEvents, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sink.SetChain(NewAuditChain(key)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sink.Log(event)
+		sink.Log(event)
+		sink.Close()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	report, err := VerifyAuditChain(f, key, AuditChainOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Records != 4 || report.LastSeq != 4 || len(report.Restarts) != 0 {
+		t.Errorf("expected one chain of 4 records, got %+v", report)
+	}
+
+	// After a torn line, a reopened file starts a new chain with a marker
+	torn, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	torn.WriteString(`{"timestamp":"2026-`)
+	torn.Close()
+	sink, err := NewFileAuditSink(path, AuditFormatCloudEvents, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.SetChain(NewAuditChain(key)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink.Log(event)
+	sink.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, opts := range []AuditChainOptions{{}, {AllowRestarts: true}} {
+		if _, err := VerifyAuditChain(bytes.NewReader(data), key, opts); !errors.Is(err, ErrAuditChainBroken) {
+			t.Errorf("expected the torn line to break the chain with %+v, got %v", opts, err)
+		}
+	}
+	report, err = VerifyAuditChain(bytes.NewReader(data), key, AuditChainOptions{AllowRestarts: true, RequireRestartMarkers: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []AuditChainRestart{{Line: 6}}; report.Records != 6 || report.LastSeq != 2 || !reflect.DeepEqual(report.Restarts, want) {
+		t.Errorf("expected a marked restart at line 6, got %+v", report)
+	}
+
+	// A marker accepts only the line it names
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	lines[4] = `{"timestamp":"2027-`
+	if _, err := VerifyAuditChain(strings.NewReader(strings.Join(lines, "\n")), key, AuditChainOptions{AllowRestarts: true, RequireRestartMarkers: true}); !errors.Is(err, ErrAuditChainBroken) {
+		t.Errorf("expected a replaced torn line to break the chain, got %v", err)
+	}
+
+	avc, err := NewFileAuditSink(filepath.Join(t.TempDir(), "avc.log"), AuditFormatAVC, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer avc.Close()
+	if err := avc.SetChain(NewAuditChain(key)); err == nil {
+		t.Error("expected AVC files not to be chainable")
+	}
+}
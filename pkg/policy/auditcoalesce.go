@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+// denialKey identifies the denials a sink coalesces: those of an agent's
+// calls to a tool, denied for the same reason.
+type denialKey struct {
+	agentType string
+	sandboxID string
+	tenantID  string
+	tool      string
+	reason    string
+	enforced  Decision
+}
+
+// denialWindow counts the identical denials seen since the first of a
+// window, which was written.
+type denialWindow struct {
+	last       *AuditEvent
+	suppressed int
+	timer      *time.Timer
+}
+
+// denialCoalescer coalesces identical denials, so that an agent retrying a
+// denied call in a loop does not flood its sink. The first denial of a
+// window is written as it occurs; the identical ones that follow within
+// the window are counted, and written as a single event, the last of them
+// with its Repeated count, when the window closes.
+type denialCoalescer struct {
+	window time.Duration
+	write  func(*AuditEvent)
+
+	mu      sync.Mutex
+	windows map[denialKey]*denialWindow
+}
+
+// newDenialCoalescer returns a coalescer writing the summaries of its
+// windows with write, or nil if window is not positive.
+func newDenialCoalescer(window time.Duration, write func(*AuditEvent)) *denialCoalescer {
+	if window <= 0 {
+		return nil
+	}
+	return &denialCoalescer{
+		window:  window,
+		write:   write,
+		windows: make(map[denialKey]*denialWindow),
+	}
+}
+
+// admit reports whether the sink should write event now, or whether it is
+// counted in the window of an identical denial.
+func (c *denialCoalescer) admit(event *AuditEvent) bool {
+	if c == nil || event.Decision != Deny {
+		return true
+	}
+	key := denialKey{
+		agentType: event.Agent.AgentType,
+		sandboxID: event.Agent.SandboxID,
+		tenantID:  event.Agent.TenantID,
+		tool:      event.Tool,
+		reason:    event.Reason,
+		enforced:  event.EnforcedDecision,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w, ok := c.windows[key]; ok {
+		w.last = event
+		w.suppressed++
+		return false
+	}
+	w := &denialWindow{}
+	w.timer = time.AfterFunc(c.window, func() { c.close(key, w) })
+	c.windows[key] = w
+	return true
+}
+
+// close closes the window w of key, writing its summary if it counted any
+// denials, unless it was flushed already.
+func (c *denialCoalescer) close(key denialKey, w *denialWindow) {
+	c.mu.Lock()
+	if c.windows[key] != w {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.windows, key)
+	c.mu.Unlock()
+	c.summarize(w)
+}
+
+// flush closes every open window, such as when the sink is closed.
+func (c *denialCoalescer) flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	windows := c.windows
+	c.windows = make(map[denialKey]*denialWindow)
+	c.mu.Unlock()
+
+	for _, w := range windows {
+		w.timer.Stop()
+		c.summarize(w)
+	}
+}
+
+// summarize writes the summary of a window that counted denials.
+func (c *denialCoalescer) summarize(w *denialWindow) {
+	if w.suppressed == 0 {
+		return
+	}
+	summary := *w.last
+	summary.Repeated = w.suppressed
+	c.write(&summary)
+}
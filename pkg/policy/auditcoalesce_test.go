@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCoalesceDenials tests that identical denials within a window are
+// written as the first of them and one summary counting the rest, and
+// that closing the sink writes the summaries of open windows.
+func TestCoalesceDenials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileAuditSink(path, AuditFormatJSON, false)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink failed: %v", err)
+	}
+	sink.CoalesceDenials(100 * time.Millisecond)
+
+	agent := AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1"}
+	denial := func(requestID, reason string) *AuditEvent {
+		return &AuditEvent{Timestamp: time.Now(), Agent: agent, Tool: "network.fetch", Decision: Deny, EnforcedDecision: Deny, Reason: reason, RequestID: requestID}
+	}
+	lines := func() []JSONAuditEvent {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read audit log: %v", err)
+		}
+		var events []JSONAuditEvent
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var event JSONAuditEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("invalid audit line %q: %v", line, err)
+			}
+			events = append(events, event)
+		}
+		return events
+	}
+
+	for i := 0; i < 5; i++ {
+		sink.Log(denial("req-"+string(rune('a'+i)), "no permission"))
+	}
+	sink.Log(denial("req-f", "domain not allowed"))
+	sink.Log(&AuditEvent{Timestamp: time.Now(), Agent: agent, Tool: "network.fetch", Decision: Allow, EnforcedDecision: Allow})
+	if events := lines(); len(events) != 3 || events[0].RequestID != "req-a" || events[0].Repeated != 0 {
+		t.Fatalf("expected the first of the identical denials and the other events, got %+v", events)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	events := lines()
+	if len(events) != 4 {
+		t.Fatalf("expected a summary once the window closed, got %+v", events)
+	}
+	if summary := events[3]; summary.RequestID != "req-e" || summary.Repeated != 4 || summary.Reason != "no permission" {
+		t.Errorf("expected the last denial counting the 4 coalesced, got %+v", summary)
+	}
+
+	sink.Log(denial("req-g", "no permission"))
+	sink.Log(denial("req-h", "no permission"))
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	events = lines()
+	if len(events) != 6 || events[5].RequestID != "req-h" || events[5].Repeated != 1 {
+		t.Errorf("expected Close to write the open window's summary, got %+v", events)
+	}
+
+	if line := formatAVC(&AuditEvent{Decision: Deny, Repeated: 4}); !strings.HasSuffix(line, " repeated=4") {
+		t.Errorf("expected the AVC line to count the coalesced denials, got %q", line)
+	}
+}
@@ -0,0 +1,236 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// Budget caps the cost of the calls a policy allows, as weighted by the
+// Cost of their tool rules, per session and per tenant. Spend is tracked
+// across policies: a tenant's calls under every policy count against each
+// policy's tenant budget.
+type Budget struct {
+	// Session limits the spend of each session
+	Session *BudgetLimit
+
+	// Tenant limits the spend of each tenant
+	Tenant *BudgetLimit
+
+	// Period is how often spend starts over, aligned to the Unix epoch
+	// (e.g., 24h for daily budgets); 0 means never
+	Period time.Duration
+}
+
+// BudgetLimit is the spend thresholds of one budget scope. A zero
+// threshold does not apply.
+type BudgetLimit struct {
+	// Limit is the spend calls are denied past
+	Limit int64
+
+	// ApprovalThreshold is the spend past which allowed calls carry an
+	// ObligationApproval, so that a human approves them before they run
+	ApprovalThreshold int64
+}
+
+// SpendTracker tracks the cumulative spend of budget scopes (see Budget).
+// It must be safe for concurrent use. The engine's default tracker is in
+// memory, so each router replica tracks its own spend; a tracker backed by
+// a shared store enforces budgets across replicas.
+type SpendTracker interface {
+	// Charge adds cost to the spend of key unless the sum would exceed
+	// limit (0 means no limit), and returns the spend after the charge,
+	// or without it if refused. The spend of key starts over from zero
+	// at expires, unless it is zero. A negative cost refunds a charge.
+	Charge(key string, cost, limit int64, expires time.Time) (spent int64, ok bool)
+
+	// Spent returns the spend of key.
+	Spent(key string) int64
+}
+
+// WithSpendTracker tracks the spend of budgets with tracker instead of in
+// memory.
+func WithSpendTracker(tracker SpendTracker) Option {
+	return func(e *Engine) {
+		e.spend = tracker
+	}
+}
+
+// MemorySpendTracker is a SpendTracker in memory.
+type MemorySpendTracker struct {
+	mu      sync.Mutex
+	entries map[string]*spendEntry
+	charges int
+}
+
+type spendEntry struct {
+	spent   int64
+	expires time.Time
+}
+
+// pruneEvery is how many charges pass between sweeps of expired spend.
+const pruneEvery = 1024
+
+// NewMemorySpendTracker returns an empty in-memory spend tracker.
+func NewMemorySpendTracker() *MemorySpendTracker {
+	return &MemorySpendTracker{entries: make(map[string]*spendEntry)}
+}
+
+// Charge implements SpendTracker.
+func (t *MemorySpendTracker) Charge(key string, cost, limit int64, expires time.Time) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.charges++
+	if t.charges%pruneEvery == 0 {
+		for k, entry := range t.entries {
+			if entry.expired(now) {
+				delete(t.entries, k)
+			}
+		}
+	}
+
+	entry, ok := t.entries[key]
+	if !ok || entry.expired(now) {
+		entry = &spendEntry{expires: expires}
+		t.entries[key] = entry
+	}
+	if limit > 0 && cost > 0 && entry.spent+cost > limit {
+		return entry.spent, false
+	}
+	entry.spent += cost
+	if entry.spent < 0 {
+		entry.spent = 0
+	}
+	return entry.spent, true
+}
+
+// Spent implements SpendTracker.
+func (t *MemorySpendTracker) Spent(key string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		return 0
+	}
+	return entry.spent
+}
+
+func (e *spendEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// budgetScope is a budget limit applied to the spend of one key.
+type budgetScope struct {
+	name    string
+	key     string
+	limit   *BudgetLimit
+	expires time.Time
+}
+
+// budgetScopes returns the scopes of a policy's budget that apply to
+// agent's calls, or an error if a scope cannot be identified.
+func budgetScopes(budget *Budget, agent AgentContext, now time.Time) ([]budgetScope, error) {
+	var window string
+	var expires time.Time
+	if budget.Period > 0 {
+		start := now.Truncate(budget.Period)
+		window = "@" + strconv.FormatInt(start.Unix(), 10)
+		expires = start.Add(budget.Period)
+	}
+
+	var scopes []budgetScope
+	if budget.Tenant != nil {
+		if agent.TenantID == "" {
+			return nil, fmt.Errorf("tenant budget requires a tenant")
+		}
+		scopes = append(scopes, budgetScope{name: "tenant", key: "tenant/" + agent.TenantID + window, limit: budget.Tenant, expires: expires})
+	}
+	if budget.Session != nil {
+		// Sub-agents spend their root task's budget
+		session := agent.RootSessionID()
+		if session == "" {
+			return nil, fmt.Errorf("session budget requires a session")
+		}
+		key := "session/" + agent.TenantID + "/" + session + window
+		scopes = append(scopes, budgetScope{name: "session", key: key, limit: budget.Session, expires: expires})
+	}
+	return scopes, nil
+}
+
+// charging reports whether the evaluation run with ctx charges budgets:
+// calls do, queries do not (see ContextWithQuery).
+func charging(ctx context.Context) bool {
+	return querySource(ctx) == ""
+}
+
+// ruleCost returns the cost of a call to a tool under a policy.
+func ruleCost(policy *CompiledPolicy, toolName string) int64 {
+	if perm, ok := policy.ToolTable[toolName]; ok {
+		return perm.Cost
+	}
+	return 0
+}
+
+// checkBudget charges an allowed call's cost to the budgets of its policy.
+// It denies the call if a budget would be exceeded, and attaches an
+// ObligationApproval if the call takes a budget past its approval
+// threshold. If charge is false, the budgets are checked but not charged.
+// Spend is tracked by spend, the engine's tracker but for plans (see
+// EvaluatePlan).
+func (e *Engine) checkBudget(spend SpendTracker, policy *CompiledPolicy, agent AgentContext, toolName string, decision Decision, reason string, denyErr error, obligations []Obligation, charge bool) (Decision, string, error, []Obligation) {
+	if decision != Allow || policy == nil || policy.Budget == nil {
+		return decision, reason, denyErr, obligations
+	}
+	scopes, err := budgetScopes(policy.Budget, agent, time.Now())
+	if err != nil {
+		return Deny, err.Error(), fmt.Errorf("%w: %w", policyerrors.ErrBudgetExceeded, err), obligations
+	}
+	cost := ruleCost(policy, toolName)
+
+	var charged []budgetScope
+	var approvals []Obligation
+	for _, scope := range scopes {
+		var spent int64
+		ok := true
+		if charge {
+			spent, ok = spend.Charge(scope.key, cost, scope.limit.Limit, scope.expires)
+		} else {
+			spent = spend.Spent(scope.key)
+			ok = scope.limit.Limit <= 0 || spent+cost <= scope.limit.Limit
+		}
+		if !ok {
+			// Refund the scopes already charged for the denied call
+			for _, c := range charged {
+				spend.Charge(c.key, -cost, 0, c.expires)
+			}
+			return Deny, fmt.Sprintf("%s budget exceeded: spent %d of %d, call costs %d", scope.name, spent, scope.limit.Limit, cost),
+				fmt.Errorf("%w: %s", policyerrors.ErrBudgetExceeded, scope.name), obligations
+		}
+		if charge {
+			charged = append(charged, scope)
+		} else {
+			spent += cost
+		}
+		if t := scope.limit.ApprovalThreshold; t > 0 && spent > t {
+			approvals = append(approvals, Obligation{
+				Type: ObligationApproval,
+				Params: map[string]string{
+					"budget":    scope.name,
+					"spent":     strconv.FormatInt(spent, 10),
+					"threshold": strconv.FormatInt(t, 10),
+				},
+			})
+		}
+	}
+	if len(approvals) > 0 {
+		obligations = append(append([]Obligation(nil), obligations...), approvals...)
+	}
+	return decision, reason, denyErr, obligations
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+func budgetPolicy(budget *Budget) *CompiledPolicy {
+	policy := CompilePolicy(
+		"budget-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{Tool: "gpu.run", Action: Allow, Cost: 40},
+			{Tool: "file.read", Action: Allow},
+		},
+		Enforcing,
+		"",
+	)
+	policy.Budget = budget
+	return policy
+}
+
+// TestBudget tests that spend past a budget's thresholds requires
+// approval and then denies.
+func TestBudget(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{
+		Tenant: &BudgetLimit{Limit: 100, ApprovalThreshold: 60},
+	}))
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "s1"}
+
+	tests := []struct {
+		tool     string
+		want     Decision
+		approval bool
+	}{
+		{"gpu.run", Allow, false}, // 40
+		{"gpu.run", Allow, true},  // 80
+		{"file.read", Allow, true},
+		{"gpu.run", Deny, false}, // 120 > 100
+		{"file.read", Allow, true},
+	}
+	for i, tt := range tests {
+		result, err := engine.EvaluateWithResult(context.Background(), agent, tt.tool, nil)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if result.Decision != tt.want {
+			t.Fatalf("%d %s: expected %v, got %v (%s)", i, tt.tool, tt.want, result.Decision, result.Reason)
+		}
+		if tt.want == Deny && !errors.Is(result.Err, policyerrors.ErrBudgetExceeded) {
+			t.Errorf("%d: expected ErrBudgetExceeded, got %v", i, result.Err)
+		}
+		approval := len(result.Obligations) == 1 && result.Obligations[0].Type == ObligationApproval
+		if approval != tt.approval {
+			t.Errorf("%d %s: expected approval %v, got obligations %v", i, tt.tool, tt.approval, result.Obligations)
+		}
+	}
+
+	// Other tenants have budgets of their own
+	other := AgentContext{AgentType: "coding-assistant", TenantID: "team-b"}
+	if decision, _ := engine.Evaluate(context.Background(), other, "gpu.run", nil); decision != Allow {
+		t.Errorf("expected another tenant to be allowed, got %v", decision)
+	}
+}
+
+// TestBudgetSessions tests session budgets and the refund of a tenant
+// charge for calls a session budget denies.
+func TestBudgetSessions(t *testing.T) {
+	tracker := NewMemorySpendTracker()
+	engine := NewEngine(WithMode(Enforcing), WithSpendTracker(tracker))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{
+		Tenant:  &BudgetLimit{Limit: 1000},
+		Session: &BudgetLimit{Limit: 50},
+	}))
+
+	s1 := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "s1"}
+	s2 := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "s2"}
+	for _, tt := range []struct {
+		agent AgentContext
+		want  Decision
+	}{
+		{s1, Allow},
+		{s1, Deny},
+		{s2, Allow},
+		{AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}, Deny}, // no session
+	} {
+		if decision, _ := engine.Evaluate(context.Background(), tt.agent, "gpu.run", nil); decision != tt.want {
+			t.Errorf("session %q: expected %v, got %v", tt.agent.SessionID, tt.want, decision)
+		}
+	}
+	if spent := tracker.Spent("tenant/team-a"); spent != 80 {
+		t.Errorf("expected the tenant to have spent 80, got %d", spent)
+	}
+}
+
+// TestBudgetLineage tests that sub-agents spend the session budget of
+// their root task, and audit events record their lineage.
+func TestBudgetLineage(t *testing.T) {
+	tracker := NewMemorySpendTracker()
+	var events []*AuditEvent
+	engine := NewEngine(WithMode(Enforcing), WithSpendTracker(tracker), WithAuditSink(&testAuditSink{events: &events}))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{
+		Session: &BudgetLimit{Limit: 100},
+	}))
+
+	root := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "root"}
+	child := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "child", ParentSessionID: "root", Lineage: []string{"root"}}
+	grandchild := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", SessionID: "grandchild", ParentSessionID: "child", Lineage: []string{"root", "child"}}
+	for i, tt := range []struct {
+		agent AgentContext
+		want  Decision
+	}{
+		{root, Allow},
+		{child, Allow},
+		{grandchild, Deny}, // 120 > 100
+	} {
+		if decision, _ := engine.Evaluate(context.Background(), tt.agent, "gpu.run", nil); decision != tt.want {
+			t.Errorf("%d %s: expected %v, got %v", i, tt.agent.SessionID, tt.want, decision)
+		}
+	}
+	if spent := tracker.Spent("session/team-a/root"); spent != 80 {
+		t.Errorf("expected the root session to have spent 80, got %d", spent)
+	}
+
+	je := newJSONAuditEvent(events[len(events)-1])
+	if je.Agent.ParentSessionID != "child" || je.Agent.RootSessionID != "root" || len(je.Agent.Lineage) != 2 {
+		t.Errorf("expected the denial to record the lineage, got %+v", je.Agent)
+	}
+	if je := newJSONAuditEvent(events[0]); je.Agent.RootSessionID != "" {
+		t.Errorf("expected no lineage for the root task, got %+v", je.Agent)
+	}
+}
+
+// TestBudgetPeriod tests that spend starts over each period.
+func TestBudgetPeriod(t *testing.T) {
+	now := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	budget := &Budget{Tenant: &BudgetLimit{Limit: 100}, Period: 24 * time.Hour}
+	agent := AgentContext{TenantID: "team-a"}
+
+	today, err := budgetScopes(budget, agent, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tomorrow, _ := budgetScopes(budget, agent, now.Add(2*time.Hour))
+	if today[0].key == tomorrow[0].key {
+		t.Errorf("expected the spend of another day under another key, got %s", today[0].key)
+	}
+	if want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); !today[0].expires.Equal(want) {
+		t.Errorf("expected spend to expire at %v, got %v", want, today[0].expires)
+	}
+
+	tracker := NewMemorySpendTracker()
+	if _, ok := tracker.Charge("k", 80, 100, time.Now().Add(-time.Second)); !ok {
+		t.Fatal("expected the charge to succeed")
+	}
+	if spent := tracker.Spent("k"); spent != 0 {
+		t.Errorf("expected expired spend to start over, got %d", spent)
+	}
+}
+
+// TestExplainBudget tests that Explain checks budgets without charging them.
+func TestExplainBudget(t *testing.T) {
+	tracker := NewMemorySpendTracker()
+	engine := NewEngine(WithMode(Enforcing), WithSpendTracker(tracker))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{Tenant: &BudgetLimit{Limit: 30}}))
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}
+
+	explanation, err := engine.Explain(context.Background(), agent, "gpu.run", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if explanation.Decision != Deny {
+		t.Errorf("expected a denial past the budget, got %v", explanation.Decision)
+	}
+	if _, err := engine.Explain(context.Background(), agent, "file.read", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spent := tracker.Spent("tenant/team-a"); spent != 0 {
+		t.Errorf("expected Explain to charge nothing, got %d", spent)
+	}
+}
+
+// TestEvaluateQuery tests that evaluations with a context from
+// ContextWithQuery check budgets without charging them, cached or not.
+func TestEvaluateQuery(t *testing.T) {
+	tracker := NewMemorySpendTracker()
+	engine := NewEngine(WithMode(Enforcing), WithSpendTracker(tracker))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{Tenant: &BudgetLimit{Limit: 100}}))
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}
+	query := ContextWithQuery(context.Background(), "test")
+
+	for i := 0; i < 3; i++ {
+		result, err := engine.EvaluateWithResult(query, agent, "gpu.run", nil)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if result.Decision != Allow {
+			t.Fatalf("%d: expected an allow, got %v (%s)", i, result.Decision, result.Reason)
+		}
+	}
+	if spent := tracker.Spent("tenant/team-a"); spent != 0 {
+		t.Fatalf("expected queries to charge nothing, got %d", spent)
+	}
+
+	// Calls still charge, and queries see their spend
+	for i := 0; i < 2; i++ {
+		if decision, _ := engine.Evaluate(context.Background(), agent, "gpu.run", nil); decision != Allow {
+			t.Fatalf("%d: expected an allow, got %v", i, decision)
+		}
+	}
+	if spent := tracker.Spent("tenant/team-a"); spent != 80 {
+		t.Errorf("expected a spend of 80, got %d", spent)
+	}
+	if decision, _ := engine.Evaluate(query, agent, "gpu.run", nil); decision != Deny {
+		t.Errorf("expected a query past the budget to be denied, got %v", decision)
+	}
+	if spent := tracker.Spent("tenant/team-a"); spent != 80 {
+		t.Errorf("expected the denied query to charge nothing, got %d", spent)
+	}
+}
@@ -25,6 +25,7 @@ type DecisionCache struct {
 type cacheEntry struct {
 	decision  Decision
 	reason    string
+	err       error
 	expiresAt time.Time
 }
 
@@ -45,10 +46,19 @@ func CacheKey(agentType, toolName string) string {
 // Get retrieves a cached decision.
 // Returns (decision, reason, true) on hit, (Deny, "", false) on miss/expired.
 func (c *DecisionCache) Get(key string) (Decision, string, bool) {
+	entry, ok := c.lookup(key)
+	if !ok {
+		return Deny, "", false
+	}
+	return entry.decision, entry.reason, true
+}
+
+// lookup retrieves a cached entry, recording the hit or miss.
+func (c *DecisionCache) lookup(key string) (cacheEntry, bool) {
 	val, ok := c.entries.Load(key)
 	if !ok {
 		c.recordMiss()
-		return Deny, "", false
+		return cacheEntry{}, false
 	}
 
 	entry := val.(cacheEntry)
@@ -56,19 +66,34 @@ func (c *DecisionCache) Get(key string) (Decision, string, bool) {
 		// Entry expired, delete it
 		c.entries.Delete(key)
 		c.recordMiss()
-		return Deny, "", false
+		return cacheEntry{}, false
 	}
 
 	c.recordHit()
-	return entry.decision, entry.reason, true
+	return entry, true
 }
 
 // Set stores a decision in the cache.
 func (c *DecisionCache) Set(key string, decision Decision, reason string) {
+	c.store(key, decision, reason, nil)
+}
+
+// store stores a decision and the typed error of a denial in the cache.
+func (c *DecisionCache) store(key string, decision Decision, reason string, err error) {
+	c.storeFor(key, decision, reason, err, 0)
+}
+
+// storeFor stores a decision like store, for ttl instead of the cache's
+// TTL if ttl is positive.
+func (c *DecisionCache) storeFor(key string, decision Decision, reason string, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
 	c.entries.Store(key, cacheEntry{
 		decision:  decision,
 		reason:    reason,
-		expiresAt: time.Now().Add(c.ttl),
+		err:       err,
+		expiresAt: time.Now().Add(ttl),
 	})
 }
 
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+)
+
+// CacheWarmupCall is a call whose decision the engine computes and caches
+// as soon as the policy is loaded, so that latency-critical agents do not
+// pay for an evaluation (an OPA query, for OPA policies) on their first
+// call after a policy update.
+//
+// Decisions are cached by agent type and tool, and by the content hash of
+// exec calls, so Parameters only matter to the cache through the mutators
+// and content hash they carry. Calls the cache cannot hold (see
+// checksParameters and behavior profiles) are evaluated, which only
+// memoizes their OPA query result for identical inputs (see WithOPAMemo).
+type CacheWarmupCall struct {
+	// Tool is the tool called
+	Tool string
+
+	// Parameters are the call's parameters (nil for none)
+	Parameters map[string]interface{}
+}
+
+// warmCache computes and caches the decisions of the warm-up calls of the
+// policies whose cached decisions an invalidation of agentType dropped:
+// the policy of agentType, or every policy bound to an exact agent type
+// for patterns, including FallbackAgentType. Calls are warmed as an agent
+// of the bound type without labels, sandbox, or tenant, whose decisions
+// share the cache entries of every such agent.
+func (e *Engine) warmCache(agentType string) {
+	keys := []string{agentType}
+	if IsAgentTypePattern(agentType) {
+		keys = e.resolver.Keys()
+	}
+	for _, key := range keys {
+		if IsAgentTypePattern(key) {
+			continue
+		}
+		if policy, ok := e.resolver.Get(key); ok && len(policy.CacheWarmup) > 0 {
+			warmed := e.warmPolicy(key, policy.CacheWarmup)
+			e.log.Debug("warmed decision cache", LogKeyAgentType, key, "policy", policy.Name, "calls", warmed)
+		}
+	}
+}
+
+// warmPolicy caches the decisions of calls by an agent of agentType, as
+// evaluate would reach them, and returns how many it cached. Nothing is
+// audited and no budget is charged.
+func (e *Engine) warmPolicy(agentType string, calls []CacheWarmupCall) int {
+	agent := AgentContext{AgentType: agentType}
+	warmed := 0
+	for _, call := range calls {
+		if e.warmCall(context.Background(), agent, call) {
+			warmed++
+		}
+	}
+	return warmed
+}
+
+// warmCall evaluates one warm-up call and caches its decision, reporting
+// whether it did. Like evaluations, it is bounded by the evaluation
+// timeout.
+func (e *Engine) warmCall(ctx context.Context, agent AgentContext, call CacheWarmupCall) bool {
+	if e.evalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.evalTimeout)
+		defer cancel()
+	}
+
+	policy, others, ok := e.resolveCombined(agent, call.Tool)
+	if !ok {
+		return false
+	}
+	policy, _, canary := e.selectCanary(policy, agent)
+
+	var request interface{}
+	if call.Parameters != nil {
+		request = call.Parameters
+	}
+	request, _ = mutateRequest(policy, call.Tool, request)
+
+	cacheable := !checksParameters(policy, call.Tool) && policy.ProfileAction == ProfileOff && cacheableAll(others, call.Tool)
+	decision, reason, _, denyErr := e.decide(ctx, policy, agent, call.Tool, request, !cacheable)
+	if decision == Allow && len(others) > 0 && ctx.Err() == nil {
+		if _, overReason, overErr, ok := e.overrideDeny(ctx, others, agent, call.Tool, request); ok {
+			decision, reason, denyErr = Deny, overReason, overErr
+		}
+	}
+	if !cacheable || ctx.Err() != nil {
+		return false
+	}
+
+	cacheKey := agentCacheKey(agent, call.Tool) + contentHashKey(request)
+	if canary {
+		cacheKey += "~canary"
+	}
+	e.cache.storeFor(cacheKey, decision, reason, denyErr, policy.CacheTTL)
+	return true
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCacheWarmup tests that warm-up calls are cached when their policy is
+// loaded, and again after a pattern update invalidates them, for the
+// policy's TTL.
+func TestCacheWarmup(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	policy := CompilePolicy("coding-policy", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "file.read", Action: Allow},
+	}, Enforcing, "")
+	policy.CacheTTL = time.Hour
+	policy.CacheWarmup = []CacheWarmupCall{{Tool: "file.read"}, {Tool: "shell.exec"}}
+
+	engine.LoadPolicy("coding-assistant", policy)
+	if size := engine.Cache().Size(); size != 2 {
+		t.Fatalf("expected both warm-up calls to be cached, got %d entries", size)
+	}
+
+	agent := AgentContext{AgentType: "coding-assistant", SandboxID: "sb-1"}
+	for tool, want := range map[string]Decision{"file.read": Allow, "shell.exec": Deny} {
+		result, err := engine.EvaluateWithResult(context.Background(), agent, tool, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Cached || result.Decision != want {
+			t.Errorf("%s: expected a cached %s, got cached=%v %s", tool, want, result.Cached, result.Decision)
+		}
+	}
+
+	// The policy's TTL outlasts the cache's
+	entry, _ := engine.Cache().lookup(CacheKey("coding-assistant", "file.read"))
+	if time.Until(entry.expiresAt) < 59*time.Minute {
+		t.Errorf("expected the policy's TTL, got an entry expiring at %v", entry.expiresAt)
+	}
+
+	// A pattern update clears the cache, and the warm-up calls are cached again
+	engine.LoadPolicy("coding-*", CompilePolicy("broad", []string{"coding-*"}, Allow, nil, Enforcing, ""))
+	if size := engine.Cache().Size(); size != 2 {
+		t.Errorf("expected the warm-up calls to be cached again, got %d entries", size)
+	}
+
+	engine.RemovePolicy("coding-assistant")
+	if size := engine.Cache().Size(); size != 0 {
+		t.Errorf("expected nothing warmed for a removed policy, got %d entries", size)
+	}
+}
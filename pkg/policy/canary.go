@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// CanaryStats reports the decisions of a canary rollout by policy version.
+type CanaryStats struct {
+	// Stable is the policy the canary rolls out a new version of, as
+	// "namespace/name" (or the name, for policies without a namespace)
+	Stable string
+
+	// Canary is the name of the new version
+	Canary string
+
+	// Percent is the share of sandboxes and sessions on the new version
+	Percent int
+
+	// StableAllowed and StableDenied count the decisions of the stable
+	// version since the canary was loaded
+	StableAllowed uint64
+	StableDenied  uint64
+
+	// CanaryAllowed and CanaryDenied count the decisions of the new version
+	CanaryAllowed uint64
+	CanaryDenied  uint64
+}
+
+// canaryRollout is a new version of a policy rolled out to a share of the
+// calls the stable version applies to.
+type canaryRollout struct {
+	policy  *CompiledPolicy
+	percent int
+
+	stableAllowed, stableDenied atomic.Uint64
+	canaryAllowed, canaryDenied atomic.Uint64
+}
+
+// record counts a decision of the stable or the canary version.
+func (r *canaryRollout) record(canary bool, decision Decision) {
+	switch {
+	case canary && decision == Allow:
+		r.canaryAllowed.Add(1)
+	case canary:
+		r.canaryDenied.Add(1)
+	case decision == Allow:
+		r.stableAllowed.Add(1)
+	default:
+		r.stableDenied.Add(1)
+	}
+}
+
+// canaryStore holds the canary rollouts of an engine by stable policy.
+type canaryStore struct {
+	mu       sync.RWMutex
+	rollouts map[string]*canaryRollout
+}
+
+// LoadCanary rolls policy out as the new version of the stable policy
+// (named "namespace/name", or by name for policies without a namespace)
+// to percent of the sandboxes it applies to, which are bucketed by a hash
+// of their sandbox ID, or of their session ID for calls without one. The
+// rest stay on the stable version, as do calls with neither ID. Raising
+// the percentage only moves sandboxes onto the new version, never back.
+//
+// The canary follows the stable policy's bindings, and replaces any
+// canary of the same stable policy. Decisions of each version are counted
+// (see CanaryStats). Replace the stable policy with the new version, and
+// remove the canary, to complete the rollout.
+func (e *Engine) LoadCanary(stable string, policy *CompiledPolicy, percent int) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	e.canaries.mu.Lock()
+	if e.canaries.rollouts == nil {
+		e.canaries.rollouts = make(map[string]*canaryRollout)
+	}
+	previous := e.canaries.rollouts[stable]
+	rollout := &canaryRollout{policy: policy, percent: percent}
+	if previous != nil && previous.policy.UID == policy.UID && policy.UID != "" {
+		// A new generation or percentage of the same canary keeps counting
+		rollout.stableAllowed.Store(previous.stableAllowed.Load())
+		rollout.stableDenied.Store(previous.stableDenied.Load())
+		rollout.canaryAllowed.Store(previous.canaryAllowed.Load())
+		rollout.canaryDenied.Store(previous.canaryDenied.Load())
+	}
+	e.canaries.rollouts[stable] = rollout
+	e.canaries.mu.Unlock()
+
+	e.log.Debug("loaded canary", "stable", stable, "policy", policy.Name, "percent", percent)
+	e.invalidateCanary()
+}
+
+// RemoveCanary ends the canary rollout of the stable policy, if any.
+func (e *Engine) RemoveCanary(stable string) {
+	e.canaries.mu.Lock()
+	_, ok := e.canaries.rollouts[stable]
+	delete(e.canaries.rollouts, stable)
+	e.canaries.mu.Unlock()
+	if ok {
+		e.invalidateCanary()
+	}
+}
+
+// RemoveCanaryUID ends the canary rollouts of the policy compiled from the
+// resource with uid, and returns the stable policies they were of.
+func (e *Engine) RemoveCanaryUID(uid string) []string {
+	if uid == "" {
+		return nil
+	}
+	e.canaries.mu.Lock()
+	var removed []string
+	for stable, rollout := range e.canaries.rollouts {
+		if rollout.policy.UID == uid {
+			delete(e.canaries.rollouts, stable)
+			removed = append(removed, stable)
+		}
+	}
+	e.canaries.mu.Unlock()
+	if len(removed) > 0 {
+		e.invalidateCanary()
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Canary returns the policy rolled out as the new version of the stable
+// policy, and its percentage.
+func (e *Engine) Canary(stable string) (*CompiledPolicy, int, bool) {
+	e.canaries.mu.RLock()
+	defer e.canaries.mu.RUnlock()
+	rollout, ok := e.canaries.rollouts[stable]
+	if !ok {
+		return nil, 0, false
+	}
+	return rollout.policy, rollout.percent, true
+}
+
+// CanaryStats returns the decision counts of the canary rollout of the
+// stable policy.
+func (e *Engine) CanaryStats(stable string) (CanaryStats, bool) {
+	e.canaries.mu.RLock()
+	rollout, ok := e.canaries.rollouts[stable]
+	e.canaries.mu.RUnlock()
+	if !ok {
+		return CanaryStats{}, false
+	}
+	return CanaryStats{
+		Stable:        stable,
+		Canary:        rollout.policy.Name,
+		Percent:       rollout.percent,
+		StableAllowed: rollout.stableAllowed.Load(),
+		StableDenied:  rollout.stableDenied.Load(),
+		CanaryAllowed: rollout.canaryAllowed.Load(),
+		CanaryDenied:  rollout.canaryDenied.Load(),
+	}, true
+}
+
+// selectCanary returns the version of the resolved policy that applies to
+// the agent, the rollout if the policy has a canary, and whether the agent
+// is on the canary.
+func (e *Engine) selectCanary(policy *CompiledPolicy, agent AgentContext) (*CompiledPolicy, *canaryRollout, bool) {
+	e.canaries.mu.RLock()
+	if len(e.canaries.rollouts) == 0 {
+		e.canaries.mu.RUnlock()
+		return policy, nil, false
+	}
+	stable := policyRef(policy)
+	rollout, ok := e.canaries.rollouts[stable]
+	e.canaries.mu.RUnlock()
+	if !ok {
+		return policy, nil, false
+	}
+
+	id := agent.SandboxID
+	if id == "" {
+		id = agent.SessionID
+	}
+	if id == "" || canaryBucket(stable, id) >= rollout.percent {
+		return policy, rollout, false
+	}
+	return rollout.policy, rollout, true
+}
+
+// canaryBucket hashes an ID into one of 100 buckets. The stable policy is
+// part of the hash, so that each rollout samples different sandboxes.
+func canaryBucket(stable, id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(stable))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return int(h.Sum32() % 100)
+}
+
+// policyRef names a compiled policy by namespace and name, if it has a
+// namespace.
+func policyRef(p *CompiledPolicy) string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
+
+// invalidateCanary drops cached decisions after a rollout changes, as they
+// may be of the other version.
+func (e *Engine) invalidateCanary() {
+	e.cache.InvalidateAll()
+	if e.opaEval != nil && e.opaEval.memo != nil {
+		e.opaEval.memo.invalidate(FallbackAgentType)
+	}
+	e.warmCache(FallbackAgentType)
+	e.notifier.notify()
+	e.hooks.call(FallbackAgentType)
+}
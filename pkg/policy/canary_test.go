@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func canaryPolicies() (stable, canary *CompiledPolicy) {
+	stable = CompilePolicy("tools", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}, {Tool: "file.write", Action: Allow}}, Enforcing, "")
+	stable.Namespace, stable.UID = "default", "stable-uid"
+	canary = CompilePolicy("tools-v2", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, "")
+	canary.Namespace, canary.UID = "default", "canary-uid"
+	return stable, canary
+}
+
+// TestCanaryRollout tests that a canary applies to its share of sandboxes,
+// that raising the share only moves sandboxes onto it, and that decisions
+// are counted by version.
+func TestCanaryRollout(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	stable, canary := canaryPolicies()
+	engine.LoadPolicy("coding-assistant", stable)
+
+	onCanary := func() map[string]bool {
+		on := make(map[string]bool)
+		for i := 0; i < 200; i++ {
+			agent := AgentContext{AgentType: "coding-assistant", SandboxID: fmt.Sprintf("sandbox-%d", i)}
+			if decision, _ := engine.Evaluate(context.Background(), agent, "file.write", nil); decision == Deny {
+				on[agent.SandboxID] = true
+			}
+		}
+		return on
+	}
+
+	engine.LoadCanary("default/tools", canary, 0)
+	if on := onCanary(); len(on) != 0 {
+		t.Errorf("expected no sandboxes on a 0%% canary, got %d", len(on))
+	}
+
+	engine.LoadCanary("default/tools", canary, 30)
+	thirty := onCanary()
+	if len(thirty) < 30 || len(thirty) > 90 {
+		t.Errorf("expected about 60 of 200 sandboxes on a 30%% canary, got %d", len(thirty))
+	}
+	engine.LoadCanary("default/tools", canary, 60)
+	sixty := onCanary()
+	for id := range thirty {
+		if !sixty[id] {
+			t.Errorf("expected %s to stay on the canary as it widens", id)
+		}
+	}
+
+	stats, ok := engine.CanaryStats("default/tools")
+	if !ok {
+		t.Fatal("expected canary stats")
+	}
+	if stats.Canary != "tools-v2" || stats.Percent != 60 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.CanaryDenied != uint64(len(thirty)+len(sixty)) || stats.StableAllowed != uint64(600-len(thirty)-len(sixty)) {
+		t.Errorf("expected decisions counted across percentages, got %+v", stats)
+	}
+	if stats.CanaryAllowed != 0 || stats.StableDenied != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Queries are not counted
+	query := ContextWithQuery(context.Background(), "test")
+	engine.Evaluate(query, AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-0"}, "file.write", nil)
+	if queried, _ := engine.CanaryStats("default/tools"); queried != stats {
+		t.Errorf("expected queries not to be counted, got %+v", queried)
+	}
+
+	engine.LoadCanary("default/tools", canary, 100)
+	if on := onCanary(); len(on) != 200 {
+		t.Errorf("expected every sandbox on a 100%% canary, got %d", len(on))
+	}
+
+	// Calls with neither a sandbox nor a session stay on the stable version
+	if decision, _ := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.write", nil); decision != Allow {
+		t.Errorf("expected an unidentified call on the stable version, got %v", decision)
+	}
+
+	if removed := engine.RemoveCanaryUID("canary-uid"); len(removed) != 1 || removed[0] != "default/tools" {
+		t.Errorf("expected the canary of default/tools removed, got %v", removed)
+	}
+	if on := onCanary(); len(on) != 0 {
+		t.Errorf("expected no sandboxes on a removed canary, got %d", len(on))
+	}
+}
+
+// TestCanarySandboxClaim tests that a sandbox claimed for the stable policy
+// may be on its canary.
+func TestCanarySandboxClaim(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	stable, canary := canaryPolicies()
+	engine.LoadPolicy("coding-assistant", stable)
+	engine.LoadCanary("default/tools", canary, 100)
+	engine.SetSandboxContext(SandboxContext{SandboxID: "sandbox-1", PolicyRef: "default/tools"})
+
+	agent := AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1"}
+	result, err := engine.EvaluateWithResult(context.Background(), agent, "file.write", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Decision != Deny || result.Policy != "tools-v2" {
+		t.Errorf("expected the canary to deny, got %v by %q (%s)", result.Decision, result.Policy, result.Reason)
+	}
+}
@@ -0,0 +1,386 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// ConstraintChecker enforces one kind of tool constraint.
+//
+// The built-in constraint fields of ToolConstraints are each enforced by a
+// checker registered under the field's name (see BuiltinConstraintKinds).
+// Deployments add their own kinds (e.g., "git-branch", "jira-project") by
+// registering a checker from an init function of a package compiled into the
+// router binary; policies configure them through ToolConstraints.Custom,
+// keyed by kind. A policy naming a kind no checker is registered for denies.
+type ConstraintChecker interface {
+	// Check returns an error describing why the request violates the
+	// constraint, or nil if it satisfies it. Checkers may return a
+	// *policyerrors.ErrConstraintViolation to report the rejected value.
+	Check(in *ConstraintInput) error
+}
+
+// ConstraintCheckerFunc adapts a function to the ConstraintChecker interface.
+type ConstraintCheckerFunc func(in *ConstraintInput) error
+
+// Check implements ConstraintChecker.
+func (f ConstraintCheckerFunc) Check(in *ConstraintInput) error {
+	return f(in)
+}
+
+// ConstraintInput is a request as seen by a ConstraintChecker.
+type ConstraintInput struct {
+	// Agent is the identity making the request
+	Agent AgentContext
+
+	// ToolName is the tool being called
+	ToolName string
+
+	// Constraints are the constraints of the matched tool rule
+	Constraints *ToolConstraints
+
+	// Request is the request as passed to Evaluate (after mutators)
+	Request interface{}
+
+	// Config is the kind's entry in Constraints.Custom (nil for built-ins)
+	Config json.RawMessage
+
+	ctx        context.Context
+	conditions *conditionContext
+	params     map[string]interface{}
+	typed      typedParams
+	typedOK    bool
+}
+
+// newConstraintInput builds the checker input for a request. Typed
+// parameters are derived once and shared by all checkers.
+func newConstraintInput(ctx context.Context, constraints *ToolConstraints, agent AgentContext, toolName string, request interface{}) *ConstraintInput {
+	in := &ConstraintInput{
+		ctx:         ctx,
+		Agent:       agent,
+		ToolName:    toolName,
+		Constraints: constraints,
+		Request:     request,
+	}
+	in.typed, in.typedOK = toTypedParams(request)
+	return in
+}
+
+// Context returns the context of the evaluation. Checkers that block,
+// e.g. on a lookup, must give up when it is done; the engine then discards
+// their result.
+func (in *ConstraintInput) Context() context.Context {
+	if in.ctx == nil {
+		return context.Background()
+	}
+	return in.ctx
+}
+
+// Params returns the request parameters as a flat map with numeric
+// parameters normalized, as generated Rego sees them.
+func (in *ConstraintInput) Params() map[string]interface{} {
+	if in.params == nil {
+		in.params = requestParameterMap(in.Request)
+	}
+	return in.params
+}
+
+// DecodeConfig decodes the kind's configuration from Constraints.Custom
+// into v.
+func (in *ConstraintInput) DecodeConfig(v interface{}) error {
+	if len(in.Config) == 0 {
+		return errors.New("missing constraint configuration")
+	}
+	if err := json.Unmarshal(in.Config, v); err != nil {
+		return fmt.Errorf("invalid constraint configuration: %w", err)
+	}
+	return nil
+}
+
+// builtinCheckers enforce the ToolConstraints fields, in evaluation order.
+// Extensions run before the checks that pass unstructured requests, since
+// they fail closed on missing parameters.
+var builtinCheckers = []struct {
+	kind    string
+	checker ConstraintChecker
+}{
+	{"requiredAgentLabels", ConstraintCheckerFunc(checkRequiredLabels)},
+	{"conditions", ConstraintCheckerFunc(checkConditions)},
+	{"extensions", ConstraintCheckerFunc(checkExtensionsConstraint)},
+	{"allowedContentHashes", ConstraintCheckerFunc(checkContentHashes)},
+	{"pathPatterns", ConstraintCheckerFunc(checkPathPatterns)},
+	{"domains", ConstraintCheckerFunc(checkDomains)},
+	{"allowedPorts", ConstraintCheckerFunc(checkPorts)},
+	{"maxSizeBytes", ConstraintCheckerFunc(checkMaxSize)},
+	{"timeout", ConstraintCheckerFunc(checkTimeout)},
+}
+
+// constraintParams are the request parameters checked by the built-in
+// kinds that check a single parameter, reported as the violation's value.
+var constraintParams = map[string]string{
+	"allowedContentHashes": ContentHashParam,
+	"pathPatterns":         "path",
+	"domains":              "domain",
+	"allowedPorts":         "port",
+	"maxSizeBytes":         "size",
+	"timeout":              "timeout_ms",
+}
+
+var (
+	checkersMu     sync.RWMutex
+	customCheckers = make(map[string]ConstraintChecker)
+)
+
+// RegisterConstraintChecker registers the checker for a custom constraint
+// kind. It is meant to be called from init functions and panics if kind is
+// empty, is a built-in kind, or is already registered.
+func RegisterConstraintChecker(kind string, checker ConstraintChecker) {
+	if kind == "" || checker == nil {
+		panic("policy: RegisterConstraintChecker requires a kind and a checker")
+	}
+	for _, b := range builtinCheckers {
+		if b.kind == kind {
+			panic(fmt.Sprintf("policy: constraint kind %q is built in", kind))
+		}
+	}
+
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	if _, dup := customCheckers[kind]; dup {
+		panic(fmt.Sprintf("policy: constraint kind %q registered twice", kind))
+	}
+	customCheckers[kind] = checker
+}
+
+// UnregisterConstraintChecker removes a custom constraint kind. It exists
+// for tests; policies naming the kind deny afterwards.
+func UnregisterConstraintChecker(kind string) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	delete(customCheckers, kind)
+}
+
+// ConstraintCheckerKinds returns the registered custom constraint kinds,
+// sorted.
+func ConstraintCheckerKinds() []string {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	kinds := make([]string, 0, len(customCheckers))
+	for kind := range customCheckers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// BuiltinConstraintKinds returns the kinds enforced for the ToolConstraints
+// fields, in evaluation order.
+func BuiltinConstraintKinds() []string {
+	kinds := make([]string, len(builtinCheckers))
+	for i, b := range builtinCheckers {
+		kinds[i] = b.kind
+	}
+	return kinds
+}
+
+// checksParameters reports whether the rule for toolName in p has
+// constraints decided on request parameters outside the decision cache key:
+// paths, domains, ports, sizes, timeouts, extensions, custom kinds, or
+// destination conditions. Only agent labels and the content hash are in
+// the key (see agentCacheKey and contentHashKey).
+func checksParameters(p *CompiledPolicy, toolName string) bool {
+	perm, ok := p.ToolTable[toolName]
+	if !ok || perm.Constraints == nil {
+		return false
+	}
+	c := perm.Constraints
+	return len(c.PathPatterns) > 0 || len(c.AllowedDomains) > 0 || len(c.DeniedDomains) > 0 ||
+		len(c.AllowedPorts) > 0 || c.MaxSizeBytes > 0 || c.Timeout > 0 ||
+		len(c.Extensions) > 0 || len(c.Custom) > 0 || c.Conditions.checksDestination()
+}
+
+// constraintViolation wraps the error of the checker of a kind as a
+// *policyerrors.ErrConstraintViolation, whose message is prefixed with the
+// kind. The value is the one the checker reported, if any, or else the
+// parameter the built-in kind checks.
+func constraintViolation(kind string, in *ConstraintInput, err error) error {
+	violation := &policyerrors.ErrConstraintViolation{Constraint: kind, Err: err}
+	if reported, ok := err.(*policyerrors.ErrConstraintViolation); ok {
+		violation.Value, violation.Err = reported.Value, reported.Err
+	} else if param, ok := constraintParams[kind]; ok {
+		violation.Value = in.Params()[param]
+	}
+	return violation
+}
+
+// runBuiltinCheckers runs the built-in checkers, returning the first
+// violation as a *policyerrors.ErrConstraintViolation.
+func runBuiltinCheckers(in *ConstraintInput) error {
+	for _, b := range builtinCheckers {
+		if err := in.Context().Err(); err != nil {
+			return err
+		}
+		if err := b.checker.Check(in); err != nil {
+			return constraintViolation(b.kind, in, err)
+		}
+	}
+	return nil
+}
+
+// runCustomCheckers runs the checkers for the custom kinds configured in
+// in.Constraints, in kind order, returning the first violation as a
+// *policyerrors.ErrConstraintViolation. Kinds without a registered checker
+// are violations.
+func runCustomCheckers(in *ConstraintInput) error {
+	custom := in.Constraints.Custom
+	if len(custom) == 0 {
+		return nil
+	}
+
+	kinds := make([]string, 0, len(custom))
+	for kind := range custom {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		if err := in.Context().Err(); err != nil {
+			return err
+		}
+		checkersMu.RLock()
+		checker, ok := customCheckers[kind]
+		checkersMu.RUnlock()
+		if !ok {
+			return constraintViolation(kind, in, errors.New("no checker registered for constraint kind"))
+		}
+
+		in.Config = custom[kind]
+		err := checker.Check(in)
+		in.Config = nil
+		if err != nil {
+			return constraintViolation(kind, in, err)
+		}
+	}
+	return nil
+}
+
+// --- Built-in checkers ---
+
+func checkRequiredLabels(in *ConstraintInput) error {
+	if !hasLabels(in.Agent.Labels, in.Constraints.RequiredAgentLabels) {
+		return errors.New("agent is missing required labels")
+	}
+	return nil
+}
+
+func checkExtensionsConstraint(in *ConstraintInput) error {
+	if len(in.Constraints.Extensions) == 0 {
+		return nil
+	}
+	return checkExtensions(in.Constraints, in.Agent, in.ToolName, in.Params())
+}
+
+// checkContentHashes fails closed: the artifact must be identified.
+func checkContentHashes(in *ConstraintInput) error {
+	c := in.Constraints
+	if len(c.AllowedContentHashes) > 0 && (!in.typedOK || !c.AllowsContentHash(in.typed.exec.ContentSHA256)) {
+		return errors.New("content hash is not allowed")
+	}
+	return nil
+}
+
+// The remaining built-ins cannot check requests without structured
+// parameters and pass them.
+
+func checkPathPatterns(in *ConstraintInput) error {
+	c := in.Constraints
+	if !in.typedOK || len(c.PathPatterns) == 0 || in.typed.file.Path == "" {
+		return nil
+	}
+
+	path, err := canonicalPath(c, in.typed.file.Path)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range c.PathPatterns {
+		if match, _ := filepath.Match(pattern, path); match {
+			return nil
+		}
+		// Also check if path is under pattern directory
+		if matchPrefix(pattern, path) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %q does not match any pattern", path)
+}
+
+func checkDomains(in *ConstraintInput) error {
+	c := in.Constraints
+	domain := in.typed.network.Domain
+	if !in.typedOK || domain == "" || (len(c.AllowedDomains) == 0 && len(c.DeniedDomains) == 0) {
+		return nil
+	}
+
+	// Compare canonical names; a domain that has none fails closed
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	if len(c.AllowedDomains) > 0 && !domainMatches(c.AllowedDomains, domain) {
+		return fmt.Errorf("domain %q is not allowed", domain)
+	}
+	if len(c.DeniedDomains) > 0 && domainMatches(c.DeniedDomains, domain) {
+		return fmt.Errorf("domain %q is denied", domain)
+	}
+	return nil
+}
+
+func checkPorts(in *ConstraintInput) error {
+	c := in.Constraints
+	if !in.typedOK || len(c.AllowedPorts) == 0 {
+		return nil
+	}
+	if in.typed.malformed["port"] {
+		return errors.New("malformed port")
+	}
+	port := in.typed.network.Port
+	if port <= 0 {
+		return nil
+	}
+	for _, p := range c.AllowedPorts {
+		if p == port {
+			return nil
+		}
+	}
+	return fmt.Errorf("port %d is not allowed", port)
+}
+
+func checkMaxSize(in *ConstraintInput) error {
+	c := in.Constraints
+	if !in.typedOK || c.MaxSizeBytes <= 0 {
+		return nil
+	}
+	if in.typed.malformed["size"] || in.typed.file.Size > c.MaxSizeBytes {
+		return fmt.Errorf("size exceeds %d bytes", c.MaxSizeBytes)
+	}
+	return nil
+}
+
+// checkTimeout limits the requested execution timeout.
+func checkTimeout(in *ConstraintInput) error {
+	c := in.Constraints
+	if !in.typedOK || c.Timeout <= 0 {
+		return nil
+	}
+	if in.typed.malformed["timeout_ms"] || in.typed.exec.Timeout > c.Timeout {
+		return fmt.Errorf("timeout exceeds %s", c.Timeout)
+	}
+	return nil
+}
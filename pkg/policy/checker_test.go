@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+)
+
+// branchConfig is the configuration of the test "git-branch" constraint kind
+type branchConfig struct {
+	Allowed []string `json:"allowed"`
+}
+
+// gitBranchChecker limits the "branch" parameter to glob patterns
+var gitBranchChecker = ConstraintCheckerFunc(func(in *ConstraintInput) error {
+	var cfg branchConfig
+	if err := in.DecodeConfig(&cfg); err != nil {
+		return err
+	}
+	branch, _ := in.Params()["branch"].(string)
+	for _, pattern := range cfg.Allowed {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("branch %q is not allowed", branch)
+})
+
+// TestCustomConstraintChecker verifies registered checkers are applied to
+// the kinds configured in ToolConstraints.Custom, and unknown kinds deny
+func TestCustomConstraintChecker(t *testing.T) {
+	RegisterConstraintChecker("git-branch", gitBranchChecker)
+	defer UnregisterConstraintChecker("git-branch")
+
+	constraints := &ToolConstraints{
+		Custom: map[string]json.RawMessage{"git-branch": json.RawMessage(`{"allowed": ["main", "feature/*"]}`)},
+	}
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "git.push", Action: Allow, Constraints: constraints}}, Enforcing, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	tests := []struct {
+		name     string
+		custom   map[string]json.RawMessage
+		branch   string
+		expected Decision
+	}{
+		{"allowed branch", nil, "main", Allow},
+		{"allowed pattern", nil, "feature/login", Allow},
+		{"denied branch after allow", nil, "release/1.0", Deny},
+		{"missing branch", nil, "", Deny},
+		{"malformed config", map[string]json.RawMessage{"git-branch": json.RawMessage(`["main"]`)}, "main", Deny},
+		{"unknown kind", map[string]json.RawMessage{"jira-project": json.RawMessage(`{}`)}, "main", Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := constraints.Custom
+			if tt.custom != nil {
+				constraints.Custom = tt.custom
+				defer func() { constraints.Custom = saved }()
+			}
+
+			// Custom constraints bypass the decision cache, so no reset is
+			// needed between requests that differ only in parameters
+			request := map[string]interface{}{"branch": tt.branch}
+			result, _ := engine.EvaluateWithResult(context.Background(), agent, "git.push", request)
+			if result.Decision != tt.expected {
+				t.Errorf("expected %v, got %v: %s", tt.expected, result.Decision, result.Reason)
+			}
+			if result.Cached {
+				t.Error("custom constraint decisions must not be cached")
+			}
+		})
+	}
+}
+
+// TestRegisterConstraintChecker verifies registration rules
+func TestRegisterConstraintChecker(t *testing.T) {
+	RegisterConstraintChecker("jira-project", gitBranchChecker)
+	defer UnregisterConstraintChecker("jira-project")
+
+	if kinds := ConstraintCheckerKinds(); len(kinds) != 1 || kinds[0] != "jira-project" {
+		t.Errorf("expected [jira-project], got %v", kinds)
+	}
+
+	for _, kind := range []string{"", "jira-project", "pathPatterns"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic registering %q", kind)
+				}
+			}()
+			RegisterConstraintChecker(kind, gitBranchChecker)
+		}()
+	}
+}
+
+// TestBuiltinConstraintReasons verifies built-in checkers name the
+// violated constraint kind
+func TestBuiltinConstraintReasons(t *testing.T) {
+	tests := []struct {
+		constraints *ToolConstraints
+		request     map[string]interface{}
+		kind        string
+	}{
+		{&ToolConstraints{PathPatterns: []string{"/workspace/**"}}, map[string]interface{}{"path": "/etc/passwd"}, "pathPatterns"},
+		{&ToolConstraints{AllowedDomains: []string{"github.com"}}, map[string]interface{}{"domain": "evil.com"}, "domains"},
+		{&ToolConstraints{AllowedPorts: []int{443}}, map[string]interface{}{"port": 22}, "allowedPorts"},
+		{&ToolConstraints{MaxSizeBytes: 10}, map[string]interface{}{"size": 11}, "maxSizeBytes"},
+		{&ToolConstraints{RequiredAgentLabels: map[string]string{"env": "prod"}}, map[string]interface{}{}, "requiredAgentLabels"},
+	}
+
+	engine := NewEngine()
+	for _, tt := range tests {
+		err := engine.checkConstraints(context.Background(), tt.constraints, AgentContext{}, "tool", tt.request)
+		if err == nil {
+			t.Errorf("%s: expected violation", tt.kind)
+			continue
+		}
+		if got := err.Error(); !strings.HasPrefix(got, tt.kind+":") {
+			t.Errorf("expected violation of %s, got %q", tt.kind, got)
+		}
+	}
+}
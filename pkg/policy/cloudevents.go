@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit output formats, selected with the Format of JSONAuditSink and the
+// format of NewFileAuditSink.
+const (
+	// AuditFormatAVC writes SELinux AVC-style lines (file sink only)
+	AuditFormatAVC = "avc"
+
+	// AuditFormatJSON writes JSONAuditEvent lines
+	AuditFormatJSON = "json"
+
+	// AuditFormatCloudEvents writes CloudEvents 1.0 structured-mode JSON
+	// lines whose data is the JSONAuditEvent
+	AuditFormatCloudEvents = "cloudevents"
+)
+
+// AuditSchemaVersion is the version of the JSONAuditEvent schema. It is
+// bumped on incompatible changes (renamed or removed fields, changed
+// meanings); added fields do not change it. Consumers should check it
+// before relying on a field.
+const AuditSchemaVersion = "1"
+
+// CloudEvent attributes of audit events
+const (
+	// CloudEventType is the type of policy decision events
+	CloudEventType = "io.sandbox.agents.policy.decision"
+
+	// CloudEventExecutionType is the type of execution events, whose id
+	// is their call's request ID with an "/execution" suffix
+	CloudEventExecutionType = "io.sandbox.agents.policy.execution"
+
+	// DefaultCloudEventSource is the source of audit events when the sink
+	// does not set one
+	DefaultCloudEventSource = "//agents.sandbox.io/router"
+
+	cloudEventSpecVersion = "1.0"
+)
+
+// CloudEvent is an audit event in the CloudEvents 1.0 JSON format
+// (structured mode). The subject is the tool, the id is the request ID,
+// and the schemaversion extension attribute repeats the data's
+// AuditSchemaVersion, so consumers can route on it without decoding the
+// data.
+type CloudEvent struct {
+	SpecVersion     string         `json:"specversion"`
+	ID              string         `json:"id"`
+	Source          string         `json:"source"`
+	Type            string         `json:"type"`
+	Subject         string         `json:"subject,omitempty"`
+	Time            string         `json:"time"`
+	DataContentType string         `json:"datacontenttype"`
+	SchemaVersion   string         `json:"schemaversion"`
+	Data            JSONAuditEvent `json:"data"`
+}
+
+// NewCloudEvent wraps an audit event in a CloudEvent from source
+// (DefaultCloudEventSource if empty).
+func NewCloudEvent(event *AuditEvent, source string) CloudEvent {
+	if source == "" {
+		source = DefaultCloudEventSource
+	}
+	id := event.RequestID
+	if id == "" {
+		// The id must be unique per source; unrouted events have none
+		id = generateRequestID()
+	}
+	eventType := CloudEventType
+	if event.Execution != nil {
+		// The decision event of the call has its request ID
+		eventType = CloudEventExecutionType
+		id += "/execution"
+	}
+	return CloudEvent{
+		SpecVersion:     cloudEventSpecVersion,
+		ID:              id,
+		Source:          source,
+		Type:            eventType,
+		Subject:         event.Tool,
+		Time:            event.Timestamp.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		SchemaVersion:   AuditSchemaVersion,
+		Data:            newJSONAuditEvent(event),
+	}
+}
+
+// EncodeAuditEvent encodes an audit event as a JSON line in a JSON format:
+// AuditFormatCloudEvents, or AuditFormatJSON for any other format.
+func EncodeAuditEvent(event *AuditEvent, format, source string) ([]byte, error) {
+	if format == AuditFormatCloudEvents {
+		return json.Marshal(NewCloudEvent(event, source))
+	}
+	return json.Marshal(newJSONAuditEvent(event))
+}
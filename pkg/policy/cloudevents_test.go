@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJSONAuditSinkCloudEvents verifies the CloudEvents attributes and the
+// versioned audit event in the data
+func TestJSONAuditSinkCloudEvents(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONAuditSink(&buf, false)
+	sink.Format = AuditFormatCloudEvents
+	sink.Source = "//agents.sandbox.io/router/router-0"
+
+	ts := time.Date(2024, 1, 2, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	sink.Log(&AuditEvent{
+		Timestamp: ts,
+		Agent:     AgentContext{AgentType: "coding-assistant", SandboxID: "sb-1"},
+		Tool:      "file.write",
+		Decision:  Deny,
+		Reason:    "constraint violation",
+		RequestID: "req-1",
+	})
+
+	var ce CloudEvent
+	if err := json.Unmarshal(buf.Bytes(), &ce); err != nil {
+		t.Fatalf("invalid CloudEvent %q: %v", buf.String(), err)
+	}
+	if ce.SpecVersion != "1.0" || ce.Type != CloudEventType || ce.ID != "req-1" || ce.Subject != "file.write" ||
+		ce.Source != "//agents.sandbox.io/router/router-0" || ce.Time != "2024-01-02T09:00:00Z" || ce.DataContentType != "application/json" {
+		t.Errorf("unexpected CloudEvent attributes: %+v", ce)
+	}
+	if ce.SchemaVersion != AuditSchemaVersion || ce.Data.SchemaVersion != AuditSchemaVersion {
+		t.Errorf("expected schema version %s, got %q and %q", AuditSchemaVersion, ce.SchemaVersion, ce.Data.SchemaVersion)
+	}
+	if ce.Data.Decision != "DENY" || ce.Data.Agent.SandboxID != "sb-1" || ce.Data.Reason != "constraint violation" {
+		t.Errorf("unexpected data: %+v", ce.Data)
+	}
+
+	// Events without a request ID still get an id, and the default source
+	data, err := EncodeAuditEvent(&AuditEvent{Timestamp: ts, Tool: "file.read"}, AuditFormatCloudEvents, "")
+	if err != nil {
+		t.Fatalf("EncodeAuditEvent failed: %v", err)
+	}
+	ce = CloudEvent{}
+	if err := json.Unmarshal(data, &ce); err != nil || ce.ID == "" || ce.Source != DefaultCloudEventSource {
+		t.Errorf("expected generated id and default source, got %s (%v)", data, err)
+	}
+}
+
+// TestExecutionEventFormats tests that execution events are told apart
+// from decision events, and correlated with them by request ID, in every
+// audit format.
+func TestExecutionEventFormats(t *testing.T) {
+	event := &AuditEvent{
+		Timestamp: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		Agent:     AgentContext{AgentType: "coding-assistant", SandboxID: "sb-1"},
+		Tool:      "file.write",
+		Decision:  Allow,
+		RequestID: "req-1",
+		Execution: &ExecutionRecord{Status: ExecutionFailed, Duration: 12 * time.Millisecond, Attempts: 2, Error: "disk full"},
+	}
+
+	if line := formatAVC(event); !strings.HasPrefix(line, "type=TOOL_EXEC msg=audit(1704186000.000:req-1): ") ||
+		!strings.Contains(line, ` status=error duration_ms=12 result_bytes=0 attempts=2 error="disk full"`) {
+		t.Errorf("unexpected execution record: %q", line)
+	}
+
+	var je JSONAuditEvent
+	data, err := EncodeAuditEvent(event, AuditFormatJSON, "")
+	if err != nil || json.Unmarshal(data, &je) != nil {
+		t.Fatalf("invalid JSON execution event %s: %v", data, err)
+	}
+	if je.Type != "EXECUTION" || je.RequestID != "req-1" || je.Execution == nil || je.Execution.Status != "error" ||
+		je.Execution.DurationNs != int64(12*time.Millisecond) || je.Execution.Attempts != 2 {
+		t.Errorf("unexpected JSON execution event: %s", data)
+	}
+
+	var ce CloudEvent
+	data, err = EncodeAuditEvent(event, AuditFormatCloudEvents, "")
+	if err != nil || json.Unmarshal(data, &ce) != nil {
+		t.Fatalf("invalid execution CloudEvent %s: %v", data, err)
+	}
+	if ce.Type != CloudEventExecutionType || ce.ID != "req-1/execution" || ce.Data.Execution == nil {
+		t.Errorf("unexpected execution CloudEvent: %s", data)
+	}
+}
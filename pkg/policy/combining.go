@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CombiningAlgorithm is how the engine decides a call when several
+// policies apply to the agent: the binding of its exact agent type, and
+// the bindings of agent type patterns, such as an organization-wide
+// "*-assistant" guardrail next to a team's own policy. The fallback policy
+// only applies when no other policy does. Policies bound to the same
+// agent type or pattern still replace each other: algorithms combine the
+// policies of different bindings.
+type CombiningAlgorithm string
+
+const (
+	// FirstApplicable lets the most specific policy decide (see
+	// PolicyResolver). It is the default.
+	FirstApplicable CombiningAlgorithm = "first-applicable"
+
+	// DenyOverrides lets the most specific policy decide, and denies the
+	// calls it allows if any other applicable policy denies them. The
+	// mutators and obligations are the most specific policy's.
+	DenyOverrides CombiningAlgorithm = "deny-overrides"
+
+	// PriorityOrdered lets the applicable policy with the highest
+	// Priority that has a rule for the tool decide, the more specific
+	// policy winning ties. If none has a rule for the tool, the default
+	// action of the highest-priority policy applies.
+	PriorityOrdered CombiningAlgorithm = "priority"
+)
+
+// ParseCombiningAlgorithm parses a CombiningAlgorithm.
+func ParseCombiningAlgorithm(s string) (CombiningAlgorithm, error) {
+	switch a := CombiningAlgorithm(s); a {
+	case FirstApplicable, DenyOverrides, PriorityOrdered:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid policy combining algorithm %q: must be first-applicable, deny-overrides, or priority", s)
+}
+
+// WithCombiningAlgorithm sets how the policies that apply to an agent are
+// combined (FirstApplicable if empty). The algorithm is recorded in the
+// audit event of every decision.
+func WithCombiningAlgorithm(algorithm CombiningAlgorithm) Option {
+	return func(e *Engine) {
+		if algorithm == "" {
+			algorithm = FirstApplicable
+		}
+		e.combining = algorithm
+	}
+}
+
+// CombiningAlgorithm returns how the engine combines applicable policies.
+func (e *Engine) CombiningAlgorithm() CombiningAlgorithm {
+	return e.combining
+}
+
+// resolveCombined returns the policy that decides a call under the
+// engine's combining algorithm and, under DenyOverrides, the other
+// applicable policies, which may deny what it allows.
+func (e *Engine) resolveCombined(agent AgentContext, toolName string) (*CompiledPolicy, []*CompiledPolicy, bool) {
+	switch e.combining {
+	case DenyOverrides:
+		policies := e.resolver.Matching(agent)
+		if len(policies) == 0 {
+			return nil, nil, false
+		}
+		return policies[0], policies[1:], true
+
+	case PriorityOrdered:
+		policies := e.resolver.Matching(agent)
+		if len(policies) == 0 {
+			return nil, nil, false
+		}
+		// Matching orders by specificity, which the stable sort keeps
+		// among policies of equal priority
+		sort.SliceStable(policies, func(i, j int) bool {
+			return policies[i].Priority > policies[j].Priority
+		})
+		for _, policy := range policies {
+			if _, ok := policy.ToolTable[toolName]; ok {
+				return policy, nil, true
+			}
+		}
+		return policies[0], nil, true
+
+	default:
+		policy, ok := e.resolver.Resolve(agent)
+		return policy, nil, ok
+	}
+}
+
+// overrideDeny evaluates a call the deciding policy allowed against the
+// other applicable policies, and returns the first that denies it, with
+// its reason and typed cause.
+func (e *Engine) overrideDeny(ctx context.Context, others []*CompiledPolicy, agent AgentContext, toolName string, request interface{}) (*CompiledPolicy, string, error, bool) {
+	for _, other := range others {
+		decision, reason, _, denyErr := e.decide(ctx, other, agent, toolName, request, false)
+		if decision == Deny {
+			return other, fmt.Sprintf("%s (policy %s, deny-overrides)", reason, other.Name), denyErr, true
+		}
+	}
+	return nil, "", nil, false
+}
+
+// cacheableAll reports whether the decisions of policies on calls to the
+// tool may be cached (see checksParameters).
+func cacheableAll(policies []*CompiledPolicy, toolName string) bool {
+	for _, policy := range policies {
+		if checksParameters(policy, toolName) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestCombiningAlgorithms tests how each algorithm decides calls that a
+// team policy, an organization-wide pattern guardrail, and the fallback
+// apply to.
+func TestCombiningAlgorithms(t *testing.T) {
+	team := CompilePolicy("team", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "shell.exec", Action: Allow},
+		{Tool: "file.read", Action: Allow},
+	}, Enforcing, "")
+	guardrail := CompilePolicy("guardrail", []string{"coding-*"}, Allow, []ToolPermission{
+		{Tool: "shell.exec", Action: Deny},
+	}, Enforcing, "")
+	guardrail.Priority = 10
+	fallback := CompilePolicy("fallback", []string{FallbackAgentType}, Deny, nil, Enforcing, "")
+
+	agent := AgentContext{AgentType: "coding-assistant", SandboxID: "sb-1"}
+	for _, tc := range []struct {
+		algorithm CombiningAlgorithm
+		want      map[string]Decision
+	}{
+		{FirstApplicable, map[string]Decision{"shell.exec": Allow, "file.read": Allow, "db.query": Deny}},
+		// The fallback does not apply next to the other policies, so its
+		// default deny does not override
+		{DenyOverrides, map[string]Decision{"shell.exec": Deny, "file.read": Allow, "db.query": Deny}},
+		// The guardrail outranks the team policy where it has a rule, and
+		// its default action applies to tools neither lists
+		{PriorityOrdered, map[string]Decision{"shell.exec": Deny, "file.read": Allow, "db.query": Allow}},
+	} {
+		t.Run(string(tc.algorithm), func(t *testing.T) {
+			sink := NewChannelAuditSink(10)
+			engine := NewEngine(WithMode(Enforcing), WithCombiningAlgorithm(tc.algorithm), WithAuditSink(sink))
+			engine.LoadPolicy("coding-assistant", team)
+			engine.LoadPolicy("coding-*", guardrail)
+			engine.LoadPolicy(FallbackAgentType, fallback)
+
+			for tool, want := range tc.want {
+				// Twice, so that the cached decision is checked too
+				for i := 0; i < 2; i++ {
+					result, err := engine.EvaluateWithResult(context.Background(), agent, tool, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result.Decision != want {
+						t.Errorf("%s: expected %s, got %s (%s)", tool, want, result.Decision, result.Reason)
+					}
+					if event := <-sink.Events(); event.Combining != tc.algorithm {
+						t.Errorf("%s: expected the audit event to record %s, got %q", tool, tc.algorithm, event.Combining)
+					}
+				}
+			}
+
+			explanation, err := engine.Explain(context.Background(), agent, "shell.exec", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if explanation.Decision != tc.want["shell.exec"] {
+				t.Errorf("expected Explain to agree with Evaluate, got %s", explanation.Decision)
+			}
+		})
+	}
+
+	// Deny-overrides names the overriding policy
+	engine := NewEngine(WithMode(Enforcing), WithCombiningAlgorithm(DenyOverrides))
+	engine.LoadPolicy("coding-assistant", team)
+	engine.LoadPolicy("coding-*", guardrail)
+	result, _ := engine.EvaluateWithResult(context.Background(), agent, "shell.exec", nil)
+	if result.Policy != "guardrail" || !strings.Contains(result.Reason, "policy guardrail, deny-overrides") {
+		t.Errorf("expected the guardrail to deny, got %s: %s", result.Policy, result.Reason)
+	}
+
+	// Only the fallback applies to other agent types
+	result, _ = engine.EvaluateWithResult(context.Background(), AgentContext{AgentType: "chat-assistant"}, "file.read", nil)
+	if result.Decision != Deny || result.Reason != "no policy defined for agent type" {
+		t.Errorf("expected no policy to apply, got %s: %s", result.Decision, result.Reason)
+	}
+
+	if _, err := ParseCombiningAlgorithm("permit-overrides"); err == nil {
+		t.Error("expected an unknown algorithm to be rejected")
+	}
+}
+
+// TestResolverMatching tests that matching policies are ranked like
+// Resolve ranks them, once each, with the fallback only on its own.
+func TestResolverMatching(t *testing.T) {
+	r := NewPolicyResolver()
+	exact := &CompiledPolicy{Name: "exact"}
+	broad := &CompiledPolicy{Name: "broad"}
+	fallback := &CompiledPolicy{Name: "fallback"}
+	r.Set("coding-assistant", exact)
+	r.Set("coding-*", broad)
+	r.Set("*-assistant", broad)
+	r.Set("c*", &CompiledPolicy{Name: "selective", AgentSelector: &LabelSelector{MatchLabels: map[string]string{"team": "a"}}})
+	r.Set(FallbackAgentType, fallback)
+
+	var names []string
+	for _, p := range r.Matching(AgentContext{AgentType: "coding-assistant"}) {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "exact,broad" {
+		t.Errorf("expected exact,broad, got %s", got)
+	}
+
+	policies := r.Matching(AgentContext{AgentType: "review-bot"})
+	if len(policies) != 1 || policies[0] != fallback {
+		t.Errorf("expected only the fallback, got %v", policies)
+	}
+}
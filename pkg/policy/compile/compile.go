@@ -0,0 +1,550 @@
+// Package compile converts AgentPolicy resources to the policies the
+// engine evaluates. It is the compiler of the AgentPolicy controller,
+// without its dependencies on controller-runtime and client-go, so that
+// programs that embed the engine, and offline tooling, can load policy
+// manifests:
+//
+//	var ap agentsv1alpha1.AgentPolicy
+//	if err := yaml.UnmarshalStrict(manifest, &ap); err != nil {
+//		return err
+//	}
+//	result, err := compile.AgentPolicy(&ap, true)
+//	if err != nil {
+//		return err
+//	}
+//	for _, agentType := range ap.Spec.AgentTypes {
+//		engine.LoadPolicy(agentType, result.Policy)
+//	}
+package compile
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	regotempl "github.com/golden-agent/golden-agent/pkg/policy/rego"
+)
+
+// Result is the output of compiling an AgentPolicy.
+type Result struct {
+	// Policy is the compiled policy ready to load into the engine
+	Policy *policy.CompiledPolicy
+
+	// RegoModule is the formatted generated Rego (empty without OPA).
+	// With the v2 template it is the bundle of all modules.
+	RegoModule string
+
+	// LintWarnings are non-fatal findings from vetting the generated Rego
+	LintWarnings []regotempl.Finding
+}
+
+// AgentPolicy converts an AgentPolicy to a CompiledPolicy, the same way
+// the controller does before loading it into the engine.
+//
+// With useOPA, the generated Rego is linted and formatted (opa fmt) before
+// it is prepared; lint errors fail compilation, warnings are returned.
+func AgentPolicy(ap *agentsv1alpha1.AgentPolicy, useOPA bool) (*Result, error) {
+	// Convert CRD types to internal types
+	defaultAction := policy.Deny
+	if ap.Spec.DefaultAction == agentsv1alpha1.DecisionAllow {
+		defaultAction = policy.Allow
+	}
+
+	mode := policy.Enforcing
+	if ap.Spec.Mode == agentsv1alpha1.EnforcementModePermissive {
+		mode = policy.Permissive
+	}
+
+	if err := checkToolConflicts(ap.Spec.ToolPermissions); err != nil {
+		return nil, err
+	}
+
+	// Constraints compiled without the values they read would be looser
+	// (deniedDomains) or tighter than the policy's author meant
+	if hasValuesFrom(ap.Spec.ToolPermissions) {
+		return nil, fmt.Errorf("constraint valuesFrom must be resolved before the policy is compiled (see ResolveValuesFrom)")
+	}
+
+	source, err := manifestOf(ap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build tool permissions
+	permissions := make([]policy.ToolPermission, 0, len(ap.Spec.ToolPermissions))
+	for _, tp := range ap.Spec.ToolPermissions {
+		action := policy.Deny
+		if tp.Action == agentsv1alpha1.DecisionAllow {
+			action = policy.Allow
+		}
+
+		perm := policy.ToolPermission{
+			Tool:   tp.Tool,
+			Action: action,
+
+			DenyMessage:           tp.DenyMessage,
+			LocalizedDenyMessages: tp.LocalizedDenyMessages,
+
+			Cost:      tp.Cost,
+			RiskLevel: policy.RiskLevel(tp.RiskLevel),
+		}
+
+		if tp.Constraints != nil {
+			perm.Constraints = convertConstraints(tp.Constraints)
+		}
+
+		mutators, err := convertMutators(tp.Mutators)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mutators for tool %s: %w", tp.Tool, err)
+		}
+		perm.Mutators = mutators
+
+		obligations, err := convertObligations(tp.Obligations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid obligations for tool %s: %w", tp.Tool, err)
+		}
+		perm.Obligations = obligations
+
+		permissions = append(permissions, perm)
+	}
+
+	budget, err := convertBudget(ap.Spec.Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTTL, warmup, err := convertDecisionCache(ap.Spec.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get MTS label
+	mtsLabel := ""
+	mtsEnforceMode := "strict"
+	if ap.Spec.TenantIsolation != nil {
+		mtsLabel = ap.Spec.TenantIsolation.MTSLabel
+		if ap.Spec.TenantIsolation.EnforceMode != "" {
+			mtsEnforceMode = string(ap.Spec.TenantIsolation.EnforceMode)
+		}
+	}
+
+	// Compile with or without OPA
+	if useOPA {
+		// Generate Rego module
+		spec := &regotempl.PolicySpec{
+			Name:            ap.Name,
+			AgentTypes:      ap.Spec.AgentTypes,
+			DefaultAction:   string(ap.Spec.DefaultAction),
+			Mode:            string(ap.Spec.Mode),
+			MTSLabel:        mtsLabel,
+			MTSEnforceMode:  mtsEnforceMode,
+			TemplateVersion: string(ap.Spec.RegoTemplate),
+		}
+
+		// Convert tool permissions to Rego spec
+		for _, tp := range ap.Spec.ToolPermissions {
+			tpSpec := regotempl.ToolPermissionSpec{
+				Tool:   tp.Tool,
+				Action: string(tp.Action),
+			}
+			for _, o := range tp.Obligations {
+				tpSpec.Obligations = append(tpSpec.Obligations, regotempl.ObligationSpec{Type: o.Type, Params: o.Params})
+			}
+
+			if tp.Constraints != nil {
+				tpSpec.Constraints = &regotempl.ConstraintSpec{
+					PathPatterns:   tp.Constraints.PathPatterns,
+					AllowedDomains: policy.NormalizeDomainPatterns(tp.Constraints.AllowedDomains),
+					DeniedDomains:  policy.NormalizeDomainPatterns(tp.Constraints.DeniedDomains),
+					AllowedPorts:   tp.Constraints.AllowedPorts,
+
+					RequiredAgentLabels:  tp.Constraints.RequiredAgentLabels,
+					AllowedContentHashes: normalizeContentHashes(tp.Constraints.AllowedContentHashes),
+				}
+				if tp.Constraints.MaxSizeBytes != nil {
+					tpSpec.Constraints.MaxSizeBytes = *tp.Constraints.MaxSizeBytes
+				}
+			}
+
+			spec.ToolPermissions = append(spec.ToolPermissions, tpSpec)
+		}
+
+		// Compile to Rego with the selected template version
+		generated, err := regotempl.CompileToModules(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Rego: %w", err)
+		}
+
+		// Lint and format before preparing the query
+		modules, warnings, err := regotempl.VetModules(generated)
+		if err != nil {
+			return nil, err
+		}
+
+		// Compile with OPA; v1 keeps its single-module form
+		var compiled *policy.CompiledPolicy
+		if legacy, ok := modules[regotempl.LegacyModule]; ok && len(modules) == 1 {
+			compiled, err = policy.CompilePolicyWithOPA(ap.Name, ap.Spec.AgentTypes, defaultAction, permissions, mode, mtsLabel, legacy)
+		} else {
+			compiled, err = policy.CompilePolicyWithOPAModules(ap.Name, ap.Spec.AgentTypes, defaultAction, permissions, mode, mtsLabel, modules)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile OPA policy: %w", err)
+		}
+		compiled.Namespace, compiled.UID = ap.Namespace, string(ap.UID)
+		compiled.AgentSelector = convertAgentSelector(ap.Spec.AgentSelector)
+		applyProfileEnforcement(compiled, ap.Spec.Profile)
+		compiled.RateLimit = convertRateLimit(ap.Spec.RateLimit)
+		compiled.Budget = budget
+		compiled.Priority = int(ap.Spec.Priority)
+		compiled.CacheTTL, compiled.CacheWarmup = cacheTTL, warmup
+		compiled.Source = source
+
+		return &Result{Policy: compiled, RegoModule: compiled.RegoModule, LintWarnings: warnings}, nil
+	}
+
+	// Legacy compilation (no OPA)
+	compiled := policy.CompilePolicy(ap.Name, ap.Spec.AgentTypes, defaultAction, permissions, mode, mtsLabel)
+	compiled.Namespace, compiled.UID = ap.Namespace, string(ap.UID)
+	compiled.AgentSelector = convertAgentSelector(ap.Spec.AgentSelector)
+	applyProfileEnforcement(compiled, ap.Spec.Profile)
+	compiled.RateLimit = convertRateLimit(ap.Spec.RateLimit)
+	compiled.Budget = budget
+	compiled.Priority = int(ap.Spec.Priority)
+	compiled.CacheTTL, compiled.CacheWarmup = cacheTTL, warmup
+	compiled.Source = source
+	return &Result{Policy: compiled}, nil
+}
+
+// Manifest compiles the JSON manifest of an AgentPolicy, such as the
+// source of a policy in an engine snapshot (see policy.Engine.Snapshot).
+func Manifest(source []byte, useOPA bool) (*policy.CompiledPolicy, error) {
+	var ap agentsv1alpha1.AgentPolicy
+	if err := json.Unmarshal(source, &ap); err != nil {
+		return nil, fmt.Errorf("invalid AgentPolicy manifest: %w", err)
+	}
+	result, err := AgentPolicy(&ap, useOPA)
+	if err != nil {
+		return nil, err
+	}
+	return result.Policy, nil
+}
+
+// manifestOf returns the JSON manifest of the parts of an AgentPolicy
+// compilation reads: its identity and spec, without status.
+func manifestOf(ap *agentsv1alpha1.AgentPolicy) ([]byte, error) {
+	source, err := json.Marshal(&agentsv1alpha1.AgentPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: agentsv1alpha1.GroupVersion.String(), Kind: "AgentPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ap.Name,
+			Namespace: ap.Namespace,
+			UID:       ap.UID,
+		},
+		Spec: ap.Spec,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode AgentPolicy manifest: %w", err)
+	}
+	return source, nil
+}
+
+// checkToolConflicts rejects tool permissions that list a tool more than
+// once. The legacy engine keeps one rule per tool while generated Rego
+// combines every rule for a tool, so a duplicate would make the two
+// evaluation paths disagree.
+func checkToolConflicts(perms []agentsv1alpha1.ToolPermission) error {
+	first := make(map[string]int, len(perms))
+	for i, tp := range perms {
+		if j, ok := first[tp.Tool]; ok {
+			return fmt.Errorf("toolPermissions[%d]: tool %s is already listed at toolPermissions[%d]; a tool may have only one rule", i, tp.Tool, j)
+		}
+		first[tp.Tool] = i
+	}
+	return nil
+}
+
+// applyProfileEnforcement sets the behavior profile enforcement of a
+// compiled policy. Profiles are judged in Go after OPA or legacy
+// evaluation, so the generated Rego does not change.
+func applyProfileEnforcement(compiled *policy.CompiledPolicy, p *agentsv1alpha1.ProfileEnforcement) {
+	if p == nil {
+		return
+	}
+	compiled.ProfileAction = policy.ProfileFlag
+	if p.Action == agentsv1alpha1.ProfileActionDeny {
+		compiled.ProfileAction = policy.ProfileDeny
+	}
+	compiled.ProfileMinSamples = p.MinSamples
+}
+
+// convertRateLimit converts a policy's rate limit override, defaulting the
+// burst to the rate. Returns nil if the policy has none.
+func convertRateLimit(rl *agentsv1alpha1.RateLimitSpec) *policy.RateLimit {
+	if rl == nil {
+		return nil
+	}
+	burst := rl.Burst
+	if burst <= 0 {
+		burst = rl.RequestsPerSecond
+	}
+	return &policy.RateLimit{RequestsPerSecond: float64(rl.RequestsPerSecond), Burst: int(burst)}
+}
+
+// convertDecisionCache converts a policy's decision cache TTL and warm-up
+// calls, decoding their parameters.
+func convertDecisionCache(c *agentsv1alpha1.DecisionCacheSpec) (time.Duration, []policy.CacheWarmupCall, error) {
+	if c == nil {
+		return 0, nil, nil
+	}
+	var ttl time.Duration
+	if c.TTL != "" {
+		d, err := time.ParseDuration(c.TTL)
+		if err != nil || d <= 0 {
+			return 0, nil, fmt.Errorf("invalid cache ttl %q", c.TTL)
+		}
+		ttl = d
+	}
+	var warmup []policy.CacheWarmupCall
+	for i, w := range c.Warmup {
+		call := policy.CacheWarmupCall{Tool: w.Tool}
+		if w.Parameters != nil && len(w.Parameters.Raw) > 0 {
+			if err := json.Unmarshal(w.Parameters.Raw, &call.Parameters); err != nil {
+				return 0, nil, fmt.Errorf("cache.warmup[%d]: parameters must be a JSON object: %w", i, err)
+			}
+		}
+		warmup = append(warmup, call)
+	}
+	return ttl, warmup, nil
+}
+
+// convertBudget converts a policy's budget. Returns nil if the policy has
+// none.
+func convertBudget(b *agentsv1alpha1.BudgetSpec) (*policy.Budget, error) {
+	if b == nil {
+		return nil, nil
+	}
+	budget := &policy.Budget{
+		Session: convertBudgetLimit(b.Session),
+		Tenant:  convertBudgetLimit(b.Tenant),
+	}
+	if b.Period != "" {
+		period, err := time.ParseDuration(b.Period)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("invalid budget period %q", b.Period)
+		}
+		budget.Period = period
+	}
+	return budget, nil
+}
+
+func convertBudgetLimit(l *agentsv1alpha1.BudgetLimit) *policy.BudgetLimit {
+	if l == nil {
+		return nil
+	}
+	return &policy.BudgetLimit{Limit: l.Limit, ApprovalThreshold: l.ApprovalThreshold}
+}
+
+// convertAgentSelector converts a Kubernetes label selector to the engine's selector.
+func convertAgentSelector(s *metav1.LabelSelector) *policy.LabelSelector {
+	if s == nil {
+		return nil
+	}
+
+	sel := &policy.LabelSelector{
+		MatchLabels: s.MatchLabels,
+	}
+	for _, expr := range s.MatchExpressions {
+		sel.MatchExpressions = append(sel.MatchExpressions, policy.LabelSelectorRequirement{
+			Key:      expr.Key,
+			Operator: string(expr.Operator),
+			Values:   expr.Values,
+		})
+	}
+	return sel
+}
+
+// convertConstraints converts CRD constraints to internal constraints.
+func convertConstraints(c *agentsv1alpha1.ToolConstraints) *policy.ToolConstraints {
+	if c == nil {
+		return nil
+	}
+
+	tc := &policy.ToolConstraints{
+		PathPatterns:    c.PathPatterns,
+		ResolveSymlinks: c.ResolveSymlinks,
+		AllowedDomains:  policy.NormalizeDomainPatterns(c.AllowedDomains),
+		DeniedDomains:   policy.NormalizeDomainPatterns(c.DeniedDomains),
+
+		RequiredAgentLabels: c.RequiredAgentLabels,
+	}
+
+	// Convert int32 ports to int
+	if len(c.AllowedPorts) > 0 {
+		tc.AllowedPorts = make([]int, len(c.AllowedPorts))
+		for i, p := range c.AllowedPorts {
+			tc.AllowedPorts[i] = int(p)
+		}
+	}
+
+	if c.MaxSizeBytes != nil {
+		tc.MaxSizeBytes = *c.MaxSizeBytes
+	}
+	if c.MaxConcurrent != nil {
+		tc.MaxConcurrent = int(*c.MaxConcurrent)
+	}
+	if c.Retry != nil {
+		tc.Retry = &policy.RetryPolicy{MaxAttempts: int(c.Retry.MaxAttempts), Backoff: 100 * time.Millisecond}
+		if d, err := time.ParseDuration(c.Retry.Backoff); err == nil && d > 0 {
+			tc.Retry.Backoff = d
+		}
+	}
+
+	tc.AllowedContentHashes = normalizeContentHashes(c.AllowedContentHashes)
+	tc.Conditions = convertConditions(c.Conditions)
+	tc.Extensions = convertProtocolConstraints(c)
+	tc.Custom = convertCustomConstraints(c.Custom)
+
+	// Parse timeout duration
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err == nil {
+			tc.Timeout = d
+		}
+	}
+
+	return tc
+}
+
+// convertConditions converts CRD conditions to internal conditions.
+func convertConditions(c *agentsv1alpha1.ToolConditions) *policy.ToolConditions {
+	if c == nil {
+		return nil
+	}
+	tc := &policy.ToolConditions{
+		Clusters:              c.Clusters,
+		Environments:          c.Environments,
+		Zones:                 c.Zones,
+		DestinationCountries:  c.DestinationCountries,
+		DestinationContinents: c.DestinationContinents,
+	}
+	for _, asn := range c.DestinationASNs {
+		tc.DestinationASNs = append(tc.DestinationASNs, uint32(asn))
+	}
+	return tc
+}
+
+// convertProtocolConstraints converts industrial protocol constraints to
+// constraint extensions.
+func convertProtocolConstraints(c *agentsv1alpha1.ToolConstraints) []policy.ConstraintExtension {
+	var extensions []policy.ConstraintExtension
+
+	if m := c.Modbus; m != nil {
+		mc := policy.ModbusConstraint{ReadOnly: m.ReadOnly}
+		for _, fc := range m.AllowedFunctionCodes {
+			mc.AllowedFunctionCodes = append(mc.AllowedFunctionCodes, int(fc))
+		}
+		for _, r := range m.AllowedRegisters {
+			mc.AllowedRegisters = append(mc.AllowedRegisters, policy.RegisterRange{Start: int(r.Start), End: int(r.End)})
+		}
+		extensions = append(extensions, mc)
+	}
+
+	if o := c.OPCUA; o != nil {
+		extensions = append(extensions, policy.OPCUAConstraint{
+			AllowedNodeIDs: o.AllowedNodeIDs,
+			ReadOnly:       o.ReadOnly,
+		})
+	}
+
+	return extensions
+}
+
+// convertCustomConstraints converts custom constraint configuration to the
+// raw JSON passed to registered constraint checkers. Unknown kinds are kept
+// so that evaluation denies them rather than silently dropping the constraint.
+func convertCustomConstraints(custom map[string]apiextensionsv1.JSON) map[string]json.RawMessage {
+	if len(custom) == 0 {
+		return nil
+	}
+	out := make(map[string]json.RawMessage, len(custom))
+	for kind, v := range custom {
+		out[kind] = json.RawMessage(v.Raw)
+	}
+	return out
+}
+
+// convertMutators converts CRD mutators to internal mutators, decoding
+// set values from JSON and validating each mutator.
+func convertMutators(ms []agentsv1alpha1.ParameterMutator) ([]policy.ParameterMutator, error) {
+	if len(ms) == 0 {
+		return nil, nil
+	}
+
+	mutators := make([]policy.ParameterMutator, 0, len(ms))
+	for _, m := range ms {
+		pm := policy.ParameterMutator{
+			Type:  policy.MutatorType(m.Type),
+			Param: m.Param,
+			Root:  m.Root,
+			Key:   m.Key,
+		}
+
+		if m.Type == agentsv1alpha1.MutatorSet {
+			if err := json.Unmarshal([]byte(m.Value), &pm.Value); err != nil {
+				return nil, fmt.Errorf("set mutator for %s: value must be JSON: %w", m.Param, err)
+			}
+		}
+		if m.Type == agentsv1alpha1.MutatorClamp {
+			if m.Max == nil {
+				return nil, fmt.Errorf("clamp mutator for %s: max is required", m.Param)
+			}
+			pm.Max = *m.Max
+		}
+
+		if err := pm.Validate(); err != nil {
+			return nil, err
+		}
+		mutators = append(mutators, pm)
+	}
+	return mutators, nil
+}
+
+// convertObligations converts CRD obligations to internal obligations,
+// validating each one.
+func convertObligations(obs []agentsv1alpha1.Obligation) ([]policy.Obligation, error) {
+	if len(obs) == 0 {
+		return nil, nil
+	}
+
+	obligations := make([]policy.Obligation, 0, len(obs))
+	for _, o := range obs {
+		po := policy.Obligation{Type: o.Type, Params: o.Params}
+		if err := po.Validate(); err != nil {
+			return nil, err
+		}
+		obligations = append(obligations, po)
+	}
+	return obligations, nil
+}
+
+// normalizeContentHashes converts digests to the canonical form the engine
+// and generated Rego compare against. Malformed digests are kept as-is
+// (they never match), so a typo cannot silently drop the allowlist.
+func normalizeContentHashes(hashes []string) []string {
+	if len(hashes) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(hashes))
+	for i, h := range hashes {
+		if d, ok := policy.NormalizeContentHash(h); ok {
+			normalized[i] = d
+		} else {
+			normalized[i] = h
+		}
+	}
+	return normalized
+}
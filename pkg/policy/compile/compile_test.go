@@ -0,0 +1,238 @@
+package compile
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestAgentPolicy verifies an AgentPolicy compiles to the engine's policy
+func TestAgentPolicy(t *testing.T) {
+	size := int64(1024)
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy", Namespace: "agents", UID: "6f1c2a"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Mode:          agentsv1alpha1.EnforcementModeEnforcing,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{
+				Tool:   "network.fetch",
+				Action: agentsv1alpha1.DecisionAllow,
+				Constraints: &agentsv1alpha1.ToolConstraints{
+					AllowedDomains: []string{"API.GitHub.com."},
+					AllowedPorts:   []int32{443},
+					MaxSizeBytes:   &size,
+					Timeout:        "5s",
+					Retry:          &agentsv1alpha1.RetryPolicy{MaxAttempts: 3, Backoff: "250ms"},
+				},
+			}},
+		},
+	}
+
+	result, err := AgentPolicy(ap, false)
+	if err != nil {
+		t.Fatalf("AgentPolicy failed: %v", err)
+	}
+	compiled := result.Policy
+	if compiled.Name != "coding-policy" || compiled.Namespace != "agents" || compiled.UID != "6f1c2a" || compiled.DefaultAction != policy.Deny || compiled.Mode != policy.Enforcing || compiled.OPAEnabled {
+		t.Errorf("unexpected policy %+v", compiled)
+	}
+	perm, ok := compiled.ToolTable["network.fetch"]
+	if !ok || perm.Action != policy.Allow || perm.Constraints == nil {
+		t.Fatalf("expected constrained network.fetch rule, got %+v", perm)
+	}
+	c := perm.Constraints
+	if len(c.AllowedDomains) != 1 || c.AllowedDomains[0] != "api.github.com" {
+		t.Errorf("expected normalized domain, got %v", c.AllowedDomains)
+	}
+	if len(c.AllowedPorts) != 1 || c.AllowedPorts[0] != 443 || c.MaxSizeBytes != 1024 || c.Timeout.String() != "5s" ||
+		c.Retry == nil || c.Retry.MaxAttempts != 3 || c.Retry.Backoff != 250*time.Millisecond {
+		t.Errorf("unexpected constraints %+v", c)
+	}
+
+	ap.Spec.ToolPermissions[0].Mutators = []agentsv1alpha1.ParameterMutator{{Type: agentsv1alpha1.MutatorSet, Param: "method", Value: "not json"}}
+	if _, err := AgentPolicy(ap, false); err == nil {
+		t.Error("expected invalid mutator to fail compilation")
+	}
+}
+
+// TestAgentPolicyDuplicateTools tests that a tool listed twice fails
+// compilation on both paths instead of one rule silently winning.
+func TestAgentPolicyDuplicateTools(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{
+				{Tool: "shell.exec", Action: agentsv1alpha1.DecisionDeny},
+				{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow},
+				{Tool: "shell.exec", Action: agentsv1alpha1.DecisionAllow},
+			},
+		},
+	}
+	for _, useOPA := range []bool{false, true} {
+		_, err := AgentPolicy(ap, useOPA)
+		if err == nil || !strings.Contains(err.Error(), "toolPermissions[2]: tool shell.exec is already listed at toolPermissions[0]") {
+			t.Errorf("useOPA=%v: expected a duplicate tool error, got %v", useOPA, err)
+		}
+	}
+}
+
+// TestAgentPolicyPathTraversal tests that path constraints compile to Rego
+// that survives formatting and rejects traversal and NUL bytes.
+func TestAgentPolicyPathTraversal(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Mode:          agentsv1alpha1.EnforcementModeEnforcing,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{
+				Tool:        "file.read",
+				Action:      agentsv1alpha1.DecisionAllow,
+				Constraints: &agentsv1alpha1.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			}},
+		},
+	}
+	result, err := AgentPolicy(ap, true)
+	if err != nil {
+		t.Fatalf("AgentPolicy failed: %v", err)
+	}
+	engine := policy.NewEngine(policy.WithMode(policy.Enforcing), policy.WithOPA(true))
+	engine.LoadPolicy("coding-assistant", result.Policy)
+	for path, want := range map[string]policy.Decision{
+		"/workspace//src/./main.go": policy.Allow,
+		"/workspace/../etc/passwd":  policy.Deny,
+		"/workspace/a\x00.go":       policy.Deny,
+	} {
+		decision, err := engine.Evaluate(context.Background(), policy.AgentContext{AgentType: "coding-assistant"}, "file.read", map[string]interface{}{"path": path})
+		if err != nil || decision != want {
+			t.Errorf("%q: expected %v, got %v (%v)", path, want, decision, err)
+		}
+	}
+}
+
+// TestAgentPolicyDecisionCache tests the conversion of a policy's cache TTL
+// and warm-up calls.
+func TestAgentPolicyDecisionCache(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Cache: &agentsv1alpha1.DecisionCacheSpec{
+				TTL: "5m",
+				Warmup: []agentsv1alpha1.CacheWarmupCall{
+					{Tool: "file.read"},
+					{Tool: "shell.exec", Parameters: &apiextensionsv1.JSON{Raw: []byte(`{"command": "make"}`)}},
+				},
+			},
+		},
+	}
+	result, err := AgentPolicy(ap, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := result.Policy
+	if p.CacheTTL != 5*time.Minute || len(p.CacheWarmup) != 2 || p.CacheWarmup[1].Parameters["command"] != "make" {
+		t.Errorf("unexpected cache settings %v %+v", p.CacheTTL, p.CacheWarmup)
+	}
+
+	ap.Spec.Cache.Warmup[1].Parameters.Raw = []byte(`["make"]`)
+	if _, err := AgentPolicy(ap, false); err == nil || !strings.Contains(err.Error(), "cache.warmup[1]") {
+		t.Errorf("expected non-object parameters to be rejected, got %v", err)
+	}
+	ap.Spec.Cache = &agentsv1alpha1.DecisionCacheSpec{TTL: "0s"}
+	if _, err := AgentPolicy(ap, false); err == nil {
+		t.Error("expected a zero TTL to be rejected")
+	}
+}
+
+// TestResolveValuesFrom tests that constraint values read from ConfigMaps
+// and Secrets are added to the constraints before compilation.
+func TestResolveValuesFrom(t *testing.T) {
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy", Namespace: "agents"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{
+				Tool:   "network.fetch",
+				Action: agentsv1alpha1.DecisionAllow,
+				Constraints: &agentsv1alpha1.ToolConstraints{
+					AllowedDomains: []string{"api.github.com"},
+					ValuesFrom: []agentsv1alpha1.ConstraintValuesSource{
+						{Field: "allowedDomains", ConfigMapKeyRef: &agentsv1alpha1.ValuesKeyRef{Name: "netsec", Key: "domains"}},
+						{Field: "deniedDomains", SecretKeyRef: &agentsv1alpha1.ValuesKeyRef{Name: "netsec", Key: "denied"}},
+						{Field: "allowedDomains", ConfigMapKeyRef: &agentsv1alpha1.ValuesKeyRef{Name: "extra", Key: "domains", Optional: true}},
+					},
+				},
+			}},
+		},
+	}
+	objects := map[string]string{
+		"ConfigMap/netsec/domains": "# internal\nwiki.corp.example\n\n  git.corp.example\n",
+		"Secret/netsec/denied":     "vault.corp.example",
+	}
+	lookup := func(kind, name, key string) (string, bool, error) {
+		data, ok := objects[kind+"/"+name+"/"+key]
+		return data, ok, nil
+	}
+
+	if _, err := AgentPolicy(ap, false); err == nil || !strings.Contains(err.Error(), "must be resolved") {
+		t.Errorf("expected unresolved valuesFrom to be rejected, got %v", err)
+	}
+	if refs := ValuesFromRefs(ap); strings.Join(refs, ",") != "ConfigMap/netsec,Secret/netsec,ConfigMap/extra" {
+		t.Errorf("unexpected refs %v", refs)
+	}
+
+	resolved, secret, err := ResolveValuesFrom(ap, lookup)
+	if err != nil {
+		t.Fatalf("ResolveValuesFrom failed: %v", err)
+	}
+	c := resolved.Spec.ToolPermissions[0].Constraints
+	if strings.Join(c.AllowedDomains, ",") != "api.github.com,wiki.corp.example,git.corp.example" || strings.Join(c.DeniedDomains, ",") != "vault.corp.example" || c.ValuesFrom != nil {
+		t.Errorf("unexpected constraints %+v", c)
+	}
+	if len(secret) != 1 || secret[0] != "vault.corp.example" {
+		t.Errorf("expected the Secret's values to be returned, got %v", secret)
+	}
+	if len(ap.Spec.ToolPermissions[0].Constraints.AllowedDomains) != 1 {
+		t.Error("expected the policy not to be modified")
+	}
+	result, err := AgentPolicy(resolved, false)
+	if err != nil {
+		t.Fatalf("expected the resolved policy to compile, got %v", err)
+	}
+	if !strings.Contains(string(result.Policy.Source), "vault.corp.example") {
+		t.Fatal("expected the resolved policy's source to hold the values")
+	}
+	if err := WithholdSecrets(result.Policy, ap, secret); err != nil {
+		t.Fatalf("WithholdSecrets failed: %v", err)
+	}
+	if source := string(result.Policy.Source); strings.Contains(source, "vault.corp.example") || !strings.Contains(source, `"secretKeyRef"`) {
+		t.Errorf("expected the source to keep the Secret reference, not its value: %s", source)
+	}
+
+	delete(objects, "Secret/netsec/denied")
+	if _, _, err := ResolveValuesFrom(ap, lookup); err == nil || !strings.Contains(err.Error(), `Secret netsec has no key "denied"`) {
+		t.Errorf("expected a missing required key to fail, got %v", err)
+	}
+
+	// A source emptied of values must not leave an allow list that allows
+	// any domain
+	objects["Secret/netsec/denied"] = ""
+	objects["ConfigMap/netsec/domains"] = "# none\n"
+	ap.Spec.ToolPermissions[0].Constraints.AllowedDomains = nil
+	if _, _, err := ResolveValuesFrom(ap, lookup); err == nil || !strings.Contains(err.Error(), "left allowedDomains empty") {
+		t.Errorf("expected an empty allow list to fail, got %v", err)
+	}
+}
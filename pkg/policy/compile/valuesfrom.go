@@ -0,0 +1,167 @@
+package compile
+
+import (
+	"fmt"
+	"strings"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// Kinds of the objects constraint values are read from.
+const (
+	ValuesKindConfigMap = "ConfigMap"
+	ValuesKindSecret    = "Secret"
+)
+
+// ValuesLookup returns the data held under a key of a ConfigMap or Secret
+// of the policy's namespace, and whether the object and key exist.
+type ValuesLookup func(kind, name, key string) (string, bool, error)
+
+// ValuesFromRefs returns the ConfigMaps and Secrets the constraints of ap
+// read values from, as "Kind/name".
+func ValuesFromRefs(ap *agentsv1alpha1.AgentPolicy) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, tp := range ap.Spec.ToolPermissions {
+		if tp.Constraints == nil {
+			continue
+		}
+		for _, src := range tp.Constraints.ValuesFrom {
+			kind, ref := valuesSourceRef(src)
+			if ref == nil {
+				continue
+			}
+			if name := kind + "/" + ref.Name; !seen[name] {
+				seen[name] = true
+				refs = append(refs, name)
+			}
+		}
+	}
+	return refs
+}
+
+// ResolveValuesFrom returns a copy of ap with the values its constraints
+// read from ConfigMaps and Secrets added to them, and the valuesFrom
+// sources removed, so that it compiles with AgentPolicy, and the values
+// read from Secrets, which must not be disclosed. ap is returned as is if
+// its constraints read no values.
+func ResolveValuesFrom(ap *agentsv1alpha1.AgentPolicy, lookup ValuesLookup) (*agentsv1alpha1.AgentPolicy, []string, error) {
+	if !hasValuesFrom(ap.Spec.ToolPermissions) {
+		return ap, nil, nil
+	}
+
+	var secret []string
+	out := ap.DeepCopy()
+	for i := range out.Spec.ToolPermissions {
+		tp := &out.Spec.ToolPermissions[i]
+		if tp.Constraints == nil {
+			continue
+		}
+		read := make(map[string]bool)
+		for j, src := range tp.Constraints.ValuesFrom {
+			read[src.Field] = true
+			kind, ref := valuesSourceRef(src)
+			if ref == nil {
+				return nil, nil, fmt.Errorf("tool %s: valuesFrom[%d] sets neither configMapKeyRef nor secretKeyRef", tp.Tool, j)
+			}
+			data, ok, err := lookup(kind, ref.Name, ref.Key)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tool %s: valuesFrom[%d]: failed to read %s %s: %w", tp.Tool, j, kind, ref.Name, err)
+			}
+			if !ok {
+				if ref.Optional {
+					continue
+				}
+				return nil, nil, fmt.Errorf("tool %s: valuesFrom[%d]: %s %s has no key %q", tp.Tool, j, kind, ref.Name, ref.Key)
+			}
+
+			values := parseValues(data)
+			if kind == ValuesKindSecret {
+				secret = append(secret, values...)
+			}
+			field, err := constraintList(tp.Constraints, src.Field)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tool %s: valuesFrom[%d]: %w", tp.Tool, j, err)
+			}
+			*field = append(*field, values...)
+		}
+		tp.Constraints.ValuesFrom = nil
+
+		// An empty allow list allows any value, which a missing or emptied
+		// source must not turn the constraint into
+		for _, name := range []string{"pathPatterns", "allowedDomains", "allowedContentHashes"} {
+			if field, _ := constraintList(tp.Constraints, name); read[name] && len(*field) == 0 {
+				return nil, nil, fmt.Errorf("tool %s: valuesFrom left %s empty, which would allow any value", tp.Tool, name)
+			}
+		}
+	}
+	return out, secret, nil
+}
+
+// WithholdSecrets records the values read from Secrets on a policy
+// compiled from the resolution of ap (see ResolveValuesFrom), and replaces
+// its Source with the manifest of ap, whose valuesFrom references are
+// unresolved, so that engine snapshots do not disclose the values.
+func WithholdSecrets(compiled *policy.CompiledPolicy, ap *agentsv1alpha1.AgentPolicy, secret []string) error {
+	if len(secret) == 0 {
+		return nil
+	}
+	source, err := manifestOf(ap)
+	if err != nil {
+		return err
+	}
+	compiled.Source, compiled.SecretValues = source, secret
+	return nil
+}
+
+// hasValuesFrom reports whether any constraint of perms reads values from
+// ConfigMaps or Secrets.
+func hasValuesFrom(perms []agentsv1alpha1.ToolPermission) bool {
+	for _, tp := range perms {
+		if tp.Constraints != nil && len(tp.Constraints.ValuesFrom) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesSourceRef returns the kind and key reference of a values source.
+func valuesSourceRef(src agentsv1alpha1.ConstraintValuesSource) (string, *agentsv1alpha1.ValuesKeyRef) {
+	switch {
+	case src.ConfigMapKeyRef != nil:
+		return ValuesKindConfigMap, src.ConfigMapKeyRef
+	case src.SecretKeyRef != nil:
+		return ValuesKindSecret, src.SecretKeyRef
+	}
+	return "", nil
+}
+
+// constraintList returns the list constraint named field.
+func constraintList(c *agentsv1alpha1.ToolConstraints, field string) (*[]string, error) {
+	switch field {
+	case "pathPatterns":
+		return &c.PathPatterns, nil
+	case "allowedDomains":
+		return &c.AllowedDomains, nil
+	case "deniedDomains":
+		return &c.DeniedDomains, nil
+	case "allowedContentHashes":
+		return &c.AllowedContentHashes, nil
+	}
+	return nil, fmt.Errorf("unknown list constraint %q", field)
+}
+
+// parseValues splits the data of a key into its values, one per line,
+// skipping blank lines and "#" comments.
+func parseValues(data string) []string {
+	var values []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	return values
+}
@@ -0,0 +1,527 @@
+// Package compliance maps AgentPolicies and audit statistics to the
+// control statements of compliance frameworks (IEC 62443-3-3, SOC 2, and
+// NIST SP 800-53), and renders the assessment as a Markdown, JSON, or PDF
+// report for auditors.
+//
+// Each control is assessed from what the policies configure (enforcement,
+// default deny, constraints, tenant isolation, rate limits, profiles) and,
+// when audit logs are given, from what the router recorded. A control is
+// satisfied when every policy provides it, partial when some do, and not
+// satisfied when none do; the gaps name the policies that fall short.
+// The report is evidence for an assessment, not a certification.
+//
+// Usage:
+//
+//	report, err := compliance.Generate(compliance.IEC62443, policies, events, time.Now())
+//	report.WriteMarkdown(os.Stdout)
+package compliance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// Framework names a compliance framework.
+type Framework string
+
+const (
+	// IEC62443 is IEC 62443-3-3, system security requirements
+	IEC62443 Framework = "iec62443"
+
+	// SOC2 is the SOC 2 Trust Services Criteria
+	SOC2 Framework = "soc2"
+
+	// NIST is NIST SP 800-53 Rev. 5
+	NIST Framework = "nist"
+)
+
+// Frameworks lists the supported frameworks.
+var Frameworks = []Framework{IEC62443, SOC2, NIST}
+
+// Status is the assessed status of a control.
+type Status string
+
+const (
+	// Satisfied means every policy provides the control
+	Satisfied Status = "satisfied"
+
+	// Partial means some policies provide the control
+	Partial Status = "partial"
+
+	// NotSatisfied means no policy provides the control, or there is no
+	// evidence of it
+	NotSatisfied Status = "not-satisfied"
+)
+
+// Control is the assessment of a control statement.
+type Control struct {
+	// ID is the control's identifier in its framework (e.g., "SR 2.1")
+	ID string `json:"id"`
+
+	// Title is the control's title in its framework
+	Title string `json:"title"`
+
+	// Status is the assessed status
+	Status Status `json:"status"`
+
+	// Statement says how the policies address the control
+	Statement string `json:"statement"`
+
+	// Evidence lists the facts the status rests on
+	Evidence []string `json:"evidence,omitempty"`
+
+	// Gaps lists what keeps the control from being satisfied
+	Gaps []string `json:"gaps,omitempty"`
+}
+
+// PolicySummary describes an assessed policy.
+type PolicySummary struct {
+	Name          string   `json:"name"`
+	Namespace     string   `json:"namespace,omitempty"`
+	AgentTypes    []string `json:"agentTypes,omitempty"`
+	Mode          string   `json:"mode"`
+	DefaultAction string   `json:"defaultAction"`
+	Rules         int      `json:"rules"`
+}
+
+// AuditSummary summarizes the audit events the report rests on.
+type AuditSummary struct {
+	Events     int       `json:"events"`
+	Allowed    int       `json:"allowed"`
+	Denied     int       `json:"denied"`
+	AgentTypes int       `json:"agentTypes"`
+	Tenants    int       `json:"tenants"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+}
+
+// Summary counts the controls by status.
+type Summary struct {
+	Satisfied    int `json:"satisfied"`
+	Partial      int `json:"partial"`
+	NotSatisfied int `json:"notSatisfied"`
+}
+
+// Report is the compliance assessment of a set of policies.
+type Report struct {
+	Framework Framework       `json:"framework"`
+	Title     string          `json:"title"`
+	Generated time.Time       `json:"generated"`
+	Policies  []PolicySummary `json:"policies"`
+	Audit     *AuditSummary   `json:"audit,omitempty"`
+	Controls  []Control       `json:"controls"`
+	Summary   Summary         `json:"summary"`
+}
+
+// Generate assesses policies, and the audit events if any, against the
+// controls of framework.
+func Generate(framework Framework, policies []*agentsv1alpha1.AgentPolicy, events []policy.AuditEvent, now time.Time) (*Report, error) {
+	catalog, ok := catalogs[framework]
+	if !ok {
+		return nil, fmt.Errorf("unknown framework %q: must be one of %v", framework, Frameworks)
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no policies to assess")
+	}
+
+	f := newFacts(policies, events)
+	report := &Report{
+		Framework: framework,
+		Title:     catalog.title,
+		Generated: now.UTC(),
+		Audit:     f.audit,
+	}
+	for _, ap := range policies {
+		report.Policies = append(report.Policies, PolicySummary{
+			Name:          ap.Name,
+			Namespace:     ap.Namespace,
+			AgentTypes:    ap.Spec.AgentTypes,
+			Mode:          string(mode(ap)),
+			DefaultAction: string(ap.Spec.DefaultAction),
+			Rules:         len(ap.Spec.ToolPermissions),
+		})
+	}
+	for _, c := range catalog.controls {
+		control := c.assess(f)
+		control.ID, control.Title = c.id, c.title
+		switch control.Status {
+		case Satisfied:
+			report.Summary.Satisfied++
+		case Partial:
+			report.Summary.Partial++
+		default:
+			report.Summary.NotSatisfied++
+		}
+		report.Controls = append(report.Controls, control)
+	}
+	return report, nil
+}
+
+// facts are what the policies and audit events show, shared by the
+// assessments of all frameworks.
+type facts struct {
+	policies []*agentsv1alpha1.AgentPolicy
+	audit    *AuditSummary
+
+	// identified counts events with an agent type and a sandbox or
+	// session ID; complete counts events with every field of an audit
+	// record (time, request ID, identity, tool, decision)
+	identified, complete int
+}
+
+func newFacts(policies []*agentsv1alpha1.AgentPolicy, events []policy.AuditEvent) *facts {
+	f := &facts{policies: policies}
+	if len(events) == 0 {
+		return f
+	}
+	a := &AuditSummary{Events: len(events)}
+	agentTypes := make(map[string]bool)
+	tenants := make(map[string]bool)
+	for _, e := range events {
+		if e.Decision == policy.Allow {
+			a.Allowed++
+		} else {
+			a.Denied++
+		}
+		if e.Agent.AgentType != "" {
+			agentTypes[e.Agent.AgentType] = true
+		}
+		if e.Agent.TenantID != "" {
+			tenants[e.Agent.TenantID] = true
+		}
+		identified := e.Agent.AgentType != "" && (e.Agent.SandboxID != "" || e.Agent.SessionID != "")
+		if identified {
+			f.identified++
+		}
+		if identified && !e.Timestamp.IsZero() && e.RequestID != "" && e.Tool != "" {
+			f.complete++
+		}
+		if a.From.IsZero() || e.Timestamp.Before(a.From) {
+			a.From = e.Timestamp
+		}
+		if e.Timestamp.After(a.To) {
+			a.To = e.Timestamp
+		}
+	}
+	a.AgentTypes, a.Tenants = len(agentTypes), len(tenants)
+	f.audit = a
+	return f
+}
+
+// mode returns the enforcement mode of a policy; policies without one are
+// enforcing, as the compiler treats them.
+func mode(ap *agentsv1alpha1.AgentPolicy) agentsv1alpha1.EnforcementMode {
+	if ap.Spec.Mode == "" {
+		return agentsv1alpha1.EnforcementModeEnforcing
+	}
+	return ap.Spec.Mode
+}
+
+// count returns the names of the policies that provide a control and of
+// those that do not.
+func (f *facts) count(provides func(*agentsv1alpha1.AgentPolicy) bool) (with, without []string) {
+	for _, ap := range f.policies {
+		if provides(ap) {
+			with = append(with, ap.Name)
+		} else {
+			without = append(without, ap.Name)
+		}
+	}
+	return with, without
+}
+
+// status returns the status of a control provided by with of the policies.
+func status(with, without []string) Status {
+	switch {
+	case len(without) == 0:
+		return Satisfied
+	case len(with) > 0:
+		return Partial
+	default:
+		return NotSatisfied
+	}
+}
+
+// gap describes the policies that fall short of a control.
+func gap(without []string, what string) []string {
+	if len(without) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %s", strings.Join(without, ", "), what)}
+}
+
+// ratio formats n of total events.
+func ratio(n, total int) string {
+	return fmt.Sprintf("%d of %d audit events (%.1f%%)", n, total, 100*float64(n)/float64(total))
+}
+
+// assessIdentification checks that every policy binds agents by an
+// identity, and that the audit events identify them.
+func assessIdentification(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		return len(ap.Spec.AgentTypes) > 0 || ap.Spec.AgentSelector != nil
+	})
+	c := Control{
+		Status:    status(with, without),
+		Statement: "Agents are identified by their agent type and sandbox, and policies apply to identified agent types only.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies bind agent types or an agent selector", len(with), len(f.policies))},
+		Gaps:      gap(without, "bind no agent type, so they only apply as a fallback"),
+	}
+	if f.audit != nil {
+		c.Evidence = append(c.Evidence, ratio(f.identified, f.audit.Events)+" identify the agent type and sandbox or session")
+		if f.identified < f.audit.Events {
+			c.Gaps = append(c.Gaps, fmt.Sprintf("%d audit events lack an agent identity", f.audit.Events-f.identified))
+			if c.Status == Satisfied {
+				c.Status = Partial
+			}
+		}
+	}
+	return c
+}
+
+// assessAuthorization checks that every policy enforces its decisions and
+// denies what it does not allow.
+func assessAuthorization(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		return mode(ap) == agentsv1alpha1.EnforcementModeEnforcing && ap.Spec.DefaultAction == agentsv1alpha1.DecisionDeny
+	})
+	c := Control{
+		Status:    status(with, without),
+		Statement: "Every tool call is authorized by the router against the policy of the agent's type before it runs; calls no rule allows are denied.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies are enforcing with a default deny", len(with), len(f.policies))},
+		Gaps:      gap(without, "are permissive or allow by default"),
+	}
+	if f.audit != nil {
+		c.Evidence = append(c.Evidence, fmt.Sprintf("%d calls allowed and %d denied in the audit period", f.audit.Allowed, f.audit.Denied))
+	}
+	return c
+}
+
+// assessLeastPrivilege checks that no policy allows a tool wildcard
+// without constraints.
+func assessLeastPrivilege(f *facts) Control {
+	var broad []string
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		ok := ap.Spec.DefaultAction == agentsv1alpha1.DecisionDeny
+		for _, tp := range ap.Spec.ToolPermissions {
+			if tp.Action == agentsv1alpha1.DecisionAllow && strings.Contains(tp.Tool, "*") && tp.Constraints == nil {
+				broad = append(broad, fmt.Sprintf("%s allows %s without constraints", ap.Name, tp.Tool))
+				ok = false
+			}
+		}
+		return ok
+	})
+	constrained, allows := 0, 0
+	for _, ap := range f.policies {
+		for _, tp := range ap.Spec.ToolPermissions {
+			if tp.Action == agentsv1alpha1.DecisionAllow {
+				allows++
+				if tp.Constraints != nil {
+					constrained++
+				}
+			}
+		}
+	}
+	return Control{
+		Status:    status(with, without),
+		Statement: "Agents are allowed only the tools their type needs, by name, with path, domain, and parameter constraints narrowing each allowed call.",
+		Evidence:  []string{fmt.Sprintf("%d of %d allow rules carry constraints", constrained, allows)},
+		Gaps:      append(gap(without, "allow by default or allow unconstrained tool wildcards"), broad...),
+	}
+}
+
+// assessAuditEvents checks that decisions are audited.
+func assessAuditEvents(f *facts) Control {
+	c := Control{
+		Statement: "The router records an audit event for every authorization decision, with the agent's identity, the tool, the decision, and its reason.",
+	}
+	if f.audit == nil {
+		c.Status = NotSatisfied
+		c.Gaps = []string{"no audit log was provided as evidence"}
+		return c
+	}
+	c.Status = Satisfied
+	c.Evidence = []string{
+		fmt.Sprintf("%d audit events from %s to %s", f.audit.Events, f.audit.From.UTC().Format(time.RFC3339), f.audit.To.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("%d agent types and %d tenants recorded", f.audit.AgentTypes, f.audit.Tenants),
+		"tamper evidence can be checked with apctl verify-audit when the router chains its audit log",
+	}
+	return c
+}
+
+// assessAuditContent checks that audit events carry every field of an
+// audit record.
+func assessAuditContent(f *facts) Control {
+	c := Control{
+		Statement: "Audit events record when the call happened, its request ID, the agent type, sandbox, and tenant, the tool, the decision, and the policy that made it.",
+	}
+	if f.audit == nil {
+		c.Status = NotSatisfied
+		c.Gaps = []string{"no audit log was provided as evidence"}
+		return c
+	}
+	c.Evidence = []string{ratio(f.complete, f.audit.Events) + " carry a timestamp, request ID, agent identity, and tool"}
+	c.Status = Satisfied
+	if f.complete < f.audit.Events {
+		c.Status = Partial
+		c.Gaps = []string{fmt.Sprintf("%d audit events lack fields of an audit record", f.audit.Events-f.complete)}
+	}
+	return c
+}
+
+// assessIntegrity checks that policies pin the content of the tools or
+// files agents may use.
+func assessIntegrity(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		for _, tp := range ap.Spec.ToolPermissions {
+			if tp.Constraints != nil && len(tp.Constraints.AllowedContentHashes) > 0 {
+				return true
+			}
+		}
+		return false
+	})
+	return Control{
+		Status:    status(with, without),
+		Statement: "Allow rules can pin the content hashes of the files and artifacts agents act on, so that modified content is refused.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies pin content hashes", len(with), len(f.policies))},
+		Gaps:      gap(without, "pin no content hashes"),
+	}
+}
+
+// assessIsolation checks that policies enforce tenant isolation.
+func assessIsolation(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		ti := ap.Spec.TenantIsolation
+		return ti != nil && ti.MTSLabel != "" && (ti.EnforceMode == "" || ti.EnforceMode == agentsv1alpha1.MTSEnforceModeStrict)
+	})
+	return Control{
+		Status:    status(with, without),
+		Statement: "Multi-tenant security labels confine each agent to its tenant's data: calls crossing tenant labels are denied.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies enforce strict tenant isolation", len(with), len(f.policies))},
+		Gaps:      gap(without, "do not enforce strict tenant isolation"),
+	}
+}
+
+// assessBoundary checks that policies confine the destinations of allowed
+// calls.
+func assessBoundary(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		if ap.Spec.DefaultAction != agentsv1alpha1.DecisionDeny {
+			return false
+		}
+		for _, tp := range ap.Spec.ToolPermissions {
+			c := tp.Constraints
+			if c == nil {
+				continue
+			}
+			if len(c.AllowedDomains) > 0 || len(c.DeniedDomains) > 0 || len(c.AllowedPorts) > 0 ||
+				c.Modbus != nil || c.OPCUA != nil || c.Conditions != nil {
+				return true
+			}
+		}
+		return false
+	})
+	return Control{
+		Status:    status(with, without),
+		Statement: "Calls between zones are denied unless a rule allows them, as a conduit confined to named domains, ports, and industrial protocol operations.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies deny by default and confine destinations", len(with), len(f.policies))},
+		Gaps:      gap(without, "allow by default or confine no destinations"),
+	}
+}
+
+// assessMonitoring checks that agent behavior is monitored.
+func assessMonitoring(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		return ap.Spec.Profile != nil
+	})
+	c := Control{
+		Status:    status(with, without),
+		Statement: "Agent behavior is compared against learned profiles, and denials are audited for review.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies enforce a behavior profile", len(with), len(f.policies))},
+		Gaps:      gap(without, "enforce no behavior profile"),
+	}
+	if f.audit != nil {
+		c.Evidence = append(c.Evidence, fmt.Sprintf("%d denials recorded in the audit period", f.audit.Denied))
+		if c.Status == NotSatisfied {
+			c.Status = Partial
+		}
+	}
+	return c
+}
+
+// assessAvailability checks that policies bound the calls of agents.
+func assessAvailability(f *facts) Control {
+	with, without := f.count(func(ap *agentsv1alpha1.AgentPolicy) bool {
+		return ap.Spec.RateLimit != nil || ap.Spec.Budget != nil
+	})
+	return Control{
+		Status:    status(with, without),
+		Statement: "Rate limits and budgets bound how many calls an agent can make, so that one agent cannot exhaust the tools it shares.",
+		Evidence:  []string{fmt.Sprintf("%d of %d policies set a rate limit or budget", len(with), len(f.policies))},
+		Gaps:      gap(without, "set no rate limit or budget"),
+	}
+}
+
+// control is a control statement of a framework and its assessment.
+type control struct {
+	id, title string
+	assess    func(*facts) Control
+}
+
+// catalog is the controls of a framework the policies are assessed
+// against.
+type catalog struct {
+	title    string
+	controls []control
+}
+
+var catalogs = map[Framework]catalog{
+	IEC62443: {
+		title: "IEC 62443-3-3 System Security Requirements",
+		controls: []control{
+			{"SR 1.1", "Human user identification and authentication", assessIdentification},
+			{"SR 1.2", "Software process and device identification and authentication", assessIdentification},
+			{"SR 2.1", "Authorization enforcement", assessAuthorization},
+			{"SR 2.8", "Auditable events", assessAuditEvents},
+			{"SR 2.11", "Timestamps", assessAuditContent},
+			{"SR 3.4", "Software and information integrity", assessIntegrity},
+			{"SR 4.1", "Information confidentiality", assessIsolation},
+			{"SR 5.1", "Network segmentation", assessBoundary},
+			{"SR 5.2", "Zone boundary protection", assessBoundary},
+			{"SR 6.2", "Continuous monitoring", assessMonitoring},
+			{"SR 7.1", "Denial of service protection", assessAvailability},
+		},
+	},
+	SOC2: {
+		title: "SOC 2 Trust Services Criteria",
+		controls: []control{
+			{"CC6.1", "Logical access security", assessAuthorization},
+			{"CC6.2", "Registration and authorization of users", assessIdentification},
+			{"CC6.3", "Role-based access and least privilege", assessLeastPrivilege},
+			{"CC6.6", "Boundary protection", assessBoundary},
+			{"CC7.2", "Monitoring of system components", assessMonitoring},
+			{"CC7.3", "Evaluation of security events", assessAuditEvents},
+			{"C1.1", "Protection of confidential information", assessIsolation},
+			{"A1.1", "Capacity management", assessAvailability},
+		},
+	},
+	NIST: {
+		title: "NIST SP 800-53 Rev. 5",
+		controls: []control{
+			{"AC-3", "Access enforcement", assessAuthorization},
+			{"AC-4", "Information flow enforcement", assessIsolation},
+			{"AC-6", "Least privilege", assessLeastPrivilege},
+			{"AU-2", "Event logging", assessAuditEvents},
+			{"AU-3", "Content of audit records", assessAuditContent},
+			{"IA-9", "Service identification and authentication", assessIdentification},
+			{"SC-5", "Denial-of-service protection", assessAvailability},
+			{"SC-7", "Boundary protection", assessBoundary},
+			{"SI-4", "System monitoring", assessMonitoring},
+			{"SI-7", "Software, firmware, and information integrity", assessIntegrity},
+		},
+	},
+}
@@ -0,0 +1,185 @@
+package compliance
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+func testPolicies() []*agentsv1alpha1.AgentPolicy {
+	return []*agentsv1alpha1.AgentPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "control-zone", Namespace: "ot"},
+			Spec: agentsv1alpha1.AgentPolicySpec{
+				AgentTypes:    []string{"control-zone-agent"},
+				DefaultAction: agentsv1alpha1.DecisionDeny,
+				Mode:          agentsv1alpha1.EnforcementModeEnforcing,
+				TenantIsolation: &agentsv1alpha1.MTSConfig{
+					MTSLabel:    "s0:c1",
+					EnforceMode: agentsv1alpha1.MTSEnforceModeStrict,
+				},
+				RateLimit: &agentsv1alpha1.RateLimitSpec{RequestsPerSecond: 5},
+				ToolPermissions: []agentsv1alpha1.ToolPermission{{
+					Tool:   "historian.read",
+					Action: agentsv1alpha1.DecisionAllow,
+					Constraints: &agentsv1alpha1.ToolConstraints{
+						AllowedDomains: []string{"historian.operations.local"},
+					},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "enterprise", Namespace: "it"},
+			Spec: agentsv1alpha1.AgentPolicySpec{
+				AgentTypes:    []string{"enterprise-zone-agent"},
+				DefaultAction: agentsv1alpha1.DecisionAllow,
+				Mode:          agentsv1alpha1.EnforcementModePermissive,
+				ToolPermissions: []agentsv1alpha1.ToolPermission{
+					{Tool: "erp.*", Action: agentsv1alpha1.DecisionAllow},
+				},
+			},
+		},
+	}
+}
+
+func findControl(t *testing.T, r *Report, id string) Control {
+	t.Helper()
+	for _, c := range r.Controls {
+		if c.ID == id {
+			return c
+		}
+	}
+	t.Fatalf("no control %s in %s report", id, r.Framework)
+	return Control{}
+}
+
+// TestGenerate tests that controls are assessed from the policies and the
+// audit events.
+func TestGenerate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []policy.AuditEvent{
+		{Timestamp: now.Add(-time.Hour), RequestID: "r1", Decision: policy.Allow, Tool: "historian.read",
+			Agent: policy.AgentContext{AgentType: "control-zone-agent", SandboxID: "sb-1", TenantID: "plant-a"}},
+		{Timestamp: now.Add(-time.Minute), RequestID: "r2", Decision: policy.Deny, Tool: "plc.write",
+			Agent: policy.AgentContext{AgentType: "control-zone-agent", SandboxID: "sb-1", TenantID: "plant-a"}},
+		{Timestamp: now, Decision: policy.Deny, Tool: "erp.write", Agent: policy.AgentContext{AgentType: "enterprise-zone-agent"}},
+	}
+
+	report, err := Generate(IEC62443, testPolicies(), events, now)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for id, want := range map[string]Status{
+		"SR 1.1":  Partial,      // an event lacks a sandbox
+		"SR 2.1":  Partial,      // the enterprise policy is permissive
+		"SR 2.8":  Satisfied,    // audit events were given
+		"SR 3.4":  NotSatisfied, // no content hashes
+		"SR 4.1":  Partial,
+		"SR 5.2":  Partial,
+		"SR 6.2":  Partial, // no profiles, but denials are audited
+		"SR 7.1":  Partial,
+		"SR 2.11": Partial, // an event lacks a request ID
+	} {
+		if c := findControl(t, report, id); c.Status != want {
+			t.Errorf("%s: expected %s, got %s (%v)", id, want, c.Status, c.Gaps)
+		}
+	}
+	if c := findControl(t, report, "SR 2.1"); len(c.Gaps) != 1 || !strings.HasPrefix(c.Gaps[0], "enterprise:") {
+		t.Errorf("expected the gap to name the permissive policy, got %v", c.Gaps)
+	}
+	if report.Audit == nil || report.Audit.Denied != 2 || report.Audit.Tenants != 1 || !report.Audit.From.Equal(now.Add(-time.Hour)) {
+		t.Errorf("unexpected audit summary %+v", report.Audit)
+	}
+	if s := report.Summary; s.Satisfied+s.Partial+s.NotSatisfied != len(report.Controls) {
+		t.Errorf("summary %+v does not count %d controls", s, len(report.Controls))
+	}
+
+	// Without audit logs, audit controls have no evidence
+	report, _ = Generate(NIST, testPolicies(), nil, now)
+	if c := findControl(t, report, "AU-2"); c.Status != NotSatisfied {
+		t.Errorf("AU-2: expected not satisfied without audit logs, got %s", c.Status)
+	}
+	if c := findControl(t, report, "AC-6"); c.Status != Partial || len(c.Gaps) != 2 {
+		t.Errorf("AC-6: expected the unconstrained wildcard to be a gap, got %s %v", c.Status, c.Gaps)
+	}
+
+	if _, err := Generate("pci", testPolicies(), nil, now); err == nil {
+		t.Error("expected an unknown framework to fail")
+	}
+}
+
+// TestRender tests the Markdown, JSON, and PDF renderings.
+func TestRender(t *testing.T) {
+	report, err := Generate(SOC2, testPolicies(), nil, time.Now())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var md bytes.Buffer
+	if err := report.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# SOC 2 Trust Services Criteria: Compliance Report", "| CC6.1 | Logical access security | Partial |", "### CC6.3", "| ot/control-zone |"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("expected the Markdown to contain %q:\n%s", want, md.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.Controls) != len(report.Controls) {
+		t.Errorf("expected the JSON to round-trip, got %v", err)
+	}
+
+	var pdf bytes.Buffer
+	if err := report.WritePDF(&pdf); err != nil {
+		t.Fatal(err)
+	}
+	out := pdf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") || !strings.Contains(out, "(CC6.6 Boundary protection: Partial) Tj") {
+		t.Errorf("unexpected PDF:\n%s", out)
+	}
+}
+
+// TestWritePDFPages tests that long reports are paginated with a valid
+// cross-reference table.
+func TestWritePDFPages(t *testing.T) {
+	lines := make([]string, 2*pdfRows+1)
+	for i := range lines {
+		lines[i] = "line (with parentheses) and \\ backslash"
+	}
+	var buf bytes.Buffer
+	if err := writePDF(&buf, "test", lines); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "/Count 3") {
+		t.Error("expected 3 pages")
+	}
+	if !strings.Contains(out, `(line \(with parentheses\) and \\ backslash) Tj`) {
+		t.Error("expected string literals to be escaped")
+	}
+	// Each xref entry points at its object
+	xref := out[strings.Index(out, "xref\n"):]
+	entries := strings.Split(xref, "\n")[3:]
+	for i, entry := range entries[:9] {
+		offset, err := strconv.Atoi(strings.Fields(entry)[0])
+		if err != nil {
+			t.Fatalf("malformed xref entry %q", entry)
+		}
+		if want := strings.TrimSpace(strings.SplitN(out[offset:], "\n", 2)[0]); want != strconv.Itoa(i+1)+" 0 obj" {
+			t.Errorf("xref entry %d points at %q", i+1, want)
+		}
+	}
+}
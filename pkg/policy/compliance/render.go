@@ -0,0 +1,150 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// statusLabels are the labels of statuses in rendered reports.
+var statusLabels = map[Status]string{
+	Satisfied:    "Satisfied",
+	Partial:      "Partial",
+	NotSatisfied: "Not satisfied",
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report as a Markdown document.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: Compliance Report\n\n", r.Title)
+	fmt.Fprintf(&b, "Generated %s from %d AgentPolicies", r.Generated.Format(time.RFC3339), len(r.Policies))
+	if r.Audit != nil {
+		fmt.Fprintf(&b, " and %d audit events", r.Audit.Events)
+	}
+	b.WriteString(".\n\n")
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Control | Title | Status |\n|---|---|---|\n")
+	for _, c := range r.Controls {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", c.ID, c.Title, statusLabels[c.Status])
+	}
+	fmt.Fprintf(&b, "\n%d satisfied, %d partial, %d not satisfied.\n\n",
+		r.Summary.Satisfied, r.Summary.Partial, r.Summary.NotSatisfied)
+
+	b.WriteString("## Policies\n\n")
+	b.WriteString("| Policy | Agent types | Mode | Default | Rules |\n|---|---|---|---|---|\n")
+	for _, p := range r.Policies {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %d |\n", policyName(p), strings.Join(p.AgentTypes, ", "), p.Mode, p.DefaultAction, p.Rules)
+	}
+	b.WriteString("\n")
+
+	if r.Audit != nil {
+		b.WriteString("## Audit Evidence\n\n")
+		fmt.Fprintf(&b, "- Period: %s to %s\n", r.Audit.From.UTC().Format(time.RFC3339), r.Audit.To.UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "- Decisions: %d (%d allowed, %d denied)\n", r.Audit.Events, r.Audit.Allowed, r.Audit.Denied)
+		fmt.Fprintf(&b, "- Agent types: %d; tenants: %d\n\n", r.Audit.AgentTypes, r.Audit.Tenants)
+	}
+
+	b.WriteString("## Controls\n")
+	for _, c := range r.Controls {
+		fmt.Fprintf(&b, "\n### %s %s: %s\n\n%s\n", c.ID, c.Title, statusLabels[c.Status], c.Statement)
+		if len(c.Evidence) > 0 {
+			b.WriteString("\nEvidence:\n\n")
+			for _, e := range c.Evidence {
+				fmt.Fprintf(&b, "- %s\n", e)
+			}
+		}
+		if len(c.Gaps) > 0 {
+			b.WriteString("\nGaps:\n\n")
+			for _, g := range c.Gaps {
+				fmt.Fprintf(&b, "- %s\n", g)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WritePDF writes the report as a PDF document of plain text.
+func (r *Report) WritePDF(w io.Writer) error {
+	return writePDF(w, r.Title+": Compliance Report", r.textLines())
+}
+
+// textLines renders the report as plain text lines, for the PDF.
+func (r *Report) textLines() []string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, wrap(fmt.Sprintf(format, args...), pdfColumns)...)
+	}
+
+	add("%s: COMPLIANCE REPORT", strings.ToUpper(r.Title))
+	add("Generated %s from %d AgentPolicies", r.Generated.Format(time.RFC3339), len(r.Policies))
+	if r.Audit != nil {
+		add("Audit evidence: %d decisions (%d allowed, %d denied) from %s to %s",
+			r.Audit.Events, r.Audit.Allowed, r.Audit.Denied,
+			r.Audit.From.UTC().Format(time.RFC3339), r.Audit.To.UTC().Format(time.RFC3339))
+	}
+	add("")
+	add("SUMMARY: %d satisfied, %d partial, %d not satisfied",
+		r.Summary.Satisfied, r.Summary.Partial, r.Summary.NotSatisfied)
+	for _, c := range r.Controls {
+		add("  %-8s %-14s %s", c.ID, statusLabels[c.Status], c.Title)
+	}
+	add("")
+	add("POLICIES")
+	for _, p := range r.Policies {
+		add("  %s: %s, default %s, %d rules, agent types %s", policyName(p), p.Mode, p.DefaultAction, p.Rules, strings.Join(p.AgentTypes, ", "))
+	}
+	for _, c := range r.Controls {
+		add("")
+		add("%s %s: %s", c.ID, c.Title, statusLabels[c.Status])
+		add("%s", c.Statement)
+		for _, e := range c.Evidence {
+			add("  + %s", e)
+		}
+		for _, g := range c.Gaps {
+			add("  - GAP: %s", g)
+		}
+	}
+	return lines
+}
+
+// policyName names a policy by namespace and name.
+func policyName(p PolicySummary) string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
+
+// wrap breaks s into lines of at most width characters at spaces,
+// indenting continuation lines like the first.
+func wrap(s string, width int) []string {
+	if len(s) <= width {
+		return []string{s}
+	}
+	indent := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = indent + word
+		case len(line)+1+len(word) > width:
+			lines = append(lines, line)
+			line = indent + "    " + word
+		default:
+			line += " " + word
+		}
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,286 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToolConditions restrict a tool rule to the environment the router runs
+// in and, for network tools, to destinations in given places. Every set
+// field must be satisfied; lists match any of their entries.
+//
+//	// Only allow network.fetch to EU endpoints from EU clusters
+//	&ToolConditions{Zones: []string{"eu-*"}, DestinationContinents: []string{"EU"}}
+type ToolConditions struct {
+	// Clusters are the names of the clusters (glob patterns) the router
+	// must run in
+	Clusters []string
+
+	// Environments are the environments (e.g., "production") the router
+	// must run in
+	Environments []string
+
+	// Zones are the node zones (glob patterns, e.g., "eu-west-*") the
+	// router must run in
+	Zones []string
+
+	// DestinationCountries are the ISO 3166-1 alpha-2 codes (e.g., "DE")
+	// of the countries every address of the destination must be in
+	DestinationCountries []string
+
+	// DestinationContinents are the continent codes (e.g., "EU") every
+	// address of the destination must be in
+	DestinationContinents []string
+
+	// DestinationASNs are the autonomous systems every address of the
+	// destination must be announced by
+	DestinationASNs []uint32
+}
+
+// checksDestination reports whether the conditions look up destinations.
+func (c *ToolConditions) checksDestination() bool {
+	return c != nil && (len(c.DestinationCountries) > 0 || len(c.DestinationContinents) > 0 || len(c.DestinationASNs) > 0)
+}
+
+// Environment is where the router runs, as the environment conditions of
+// tool rules see it (see WithEnvironment).
+type Environment struct {
+	Cluster     string
+	Environment string
+	Zone        string
+}
+
+// Destination is what an EnrichmentProvider knows of a network address.
+type Destination struct {
+	// Country is the ISO 3166-1 alpha-2 code of the address's country
+	Country string
+
+	// Continent is the code of the address's continent (e.g., "EU")
+	Continent string
+
+	// ASN is the autonomous system announcing the address (0 if unknown)
+	ASN uint32
+}
+
+// EnrichmentProvider looks up network addresses for the destination
+// conditions of tool rules, such as in a GeoIP database. It must be safe
+// for concurrent use.
+type EnrichmentProvider interface {
+	// LookupIP returns what is known of ip, or an error if nothing is.
+	LookupIP(ctx context.Context, ip net.IP) (Destination, error)
+}
+
+// WithEnvironment sets the environment the conditions of tool rules are
+// checked against. Without it, rules with environment conditions deny.
+func WithEnvironment(env Environment) Option {
+	return func(e *Engine) {
+		e.conditions.env = env
+	}
+}
+
+// WithEnrichmentProvider sets the provider the destination conditions of
+// tool rules look up addresses with. Domains are resolved with the system
+// resolver, and every address they resolve to must satisfy the conditions.
+// Without a provider, rules with destination conditions deny. Decisions of
+// such rules are never cached, as they depend on the destination.
+func WithEnrichmentProvider(provider EnrichmentProvider) Option {
+	return func(e *Engine) {
+		e.conditions.provider = provider
+	}
+}
+
+// conditionContext is what the conditions of tool rules are checked
+// against.
+type conditionContext struct {
+	env      Environment
+	provider EnrichmentProvider
+
+	// lookupHost resolves domains (default: net.DefaultResolver)
+	lookupHost func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// checkConditions checks the conditions of a tool rule.
+func checkConditions(in *ConstraintInput) error {
+	c := in.Constraints.Conditions
+	if c == nil {
+		return nil
+	}
+	if in.conditions == nil {
+		in.conditions = &conditionContext{}
+	}
+	env := in.conditions.env
+	if len(c.Clusters) > 0 && !globMatches(c.Clusters, env.Cluster) {
+		return fmt.Errorf("cluster %q is not allowed", env.Cluster)
+	}
+	if len(c.Environments) > 0 && !containsString(c.Environments, env.Environment) {
+		return fmt.Errorf("environment %q is not allowed", env.Environment)
+	}
+	if len(c.Zones) > 0 && !globMatches(c.Zones, env.Zone) {
+		return fmt.Errorf("zone %q is not allowed", env.Zone)
+	}
+	if c.checksDestination() {
+		return checkDestination(in, c)
+	}
+	return nil
+}
+
+// checkDestination fails closed: the destination must be named, resolve,
+// and be known to the provider.
+func checkDestination(in *ConstraintInput, c *ToolConditions) error {
+	host := in.typed.network.Domain
+	if !in.typedOK || host == "" {
+		return errors.New("destination is required")
+	}
+	if in.conditions.provider == nil {
+		return errors.New("no enrichment provider to look up destinations")
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		lookup := in.conditions.lookupHost
+		if lookup == nil {
+			lookup = net.DefaultResolver.LookupIPAddr
+		}
+		addrs, err := lookup(in.Context(), host)
+		if err != nil {
+			return fmt.Errorf("failed to resolve destination %q: %w", host, err)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		if len(ips) == 0 {
+			return fmt.Errorf("destination %q has no addresses", host)
+		}
+	}
+
+	for _, ip := range ips {
+		dest, err := in.conditions.provider.LookupIP(in.Context(), ip)
+		if err != nil {
+			return fmt.Errorf("failed to look up destination %s: %w", ip, err)
+		}
+		if len(c.DestinationCountries) > 0 && !containsFold(c.DestinationCountries, dest.Country) {
+			return fmt.Errorf("destination %s is in country %q", ip, dest.Country)
+		}
+		if len(c.DestinationContinents) > 0 && !containsFold(c.DestinationContinents, dest.Continent) {
+			return fmt.Errorf("destination %s is in continent %q", ip, dest.Continent)
+		}
+		if len(c.DestinationASNs) > 0 && !containsASN(c.DestinationASNs, dest.ASN) {
+			return fmt.Errorf("destination %s is in AS%d", ip, dest.ASN)
+		}
+	}
+	return nil
+}
+
+func globMatches(patterns []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsASN(list []uint32, asn uint32) bool {
+	for _, v := range list {
+		if v == asn && asn != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CIDRGeoProvider is an EnrichmentProvider backed by a table of network
+// ranges, as exported from a GeoIP database. Addresses are looked up by
+// their most specific range. Lookups scan the table, which suits tables of
+// the regions and networks policies name; full GeoIP databases should be
+// plugged in as EnrichmentProviders of their own.
+type CIDRGeoProvider struct {
+	// ranges are sorted by decreasing prefix length
+	ranges []geoRange
+}
+
+type geoRange struct {
+	network *net.IPNet
+	ones    int
+	dest    Destination
+}
+
+// LoadCIDRGeoProvider reads a provider's table as CSV lines of
+//
+//	network,country,continent,asn
+//
+// e.g. "185.199.108.0/22,DE,EU,54113". The ASN may be empty. Blank lines
+// and lines starting with "#" are skipped.
+func LoadCIDRGeoProvider(r io.Reader) (*CIDRGeoProvider, error) {
+	p := &CIDRGeoProvider{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected network,country,continent,asn", lineNum)
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		dest := Destination{
+			Country:   strings.ToUpper(strings.TrimSpace(fields[1])),
+			Continent: strings.ToUpper(strings.TrimSpace(fields[2])),
+		}
+		if asn := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(fields[3])), "AS"); asn != "" {
+			n, err := strconv.ParseUint(asn, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid ASN %q", lineNum, fields[3])
+			}
+			dest.ASN = uint32(n)
+		}
+		ones, _ := network.Mask.Size()
+		p.ranges = append(p.ranges, geoRange{network: network, ones: ones, dest: dest})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(p.ranges, func(i, j int) bool { return p.ranges[i].ones > p.ranges[j].ones })
+	return p, nil
+}
+
+// LookupIP implements EnrichmentProvider.
+func (p *CIDRGeoProvider) LookupIP(_ context.Context, ip net.IP) (Destination, error) {
+	for _, r := range p.ranges {
+		if r.network.Contains(ip) {
+			return r.dest, nil
+		}
+	}
+	return Destination{}, fmt.Errorf("address %s is not in the GeoIP table", ip)
+}
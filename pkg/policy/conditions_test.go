@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+const testGeoTable = `
+# network,country,continent,asn
+185.0.0.0/8,NL,EU,
+185.199.108.0/22,DE,EU,AS54113
+203.0.113.0/24,US,NA,64500
+2001:db8::/32,FR,EU,64501
+`
+
+// TestConditionsEnvironment tests the environment conditions of tool rules.
+func TestConditionsEnvironment(t *testing.T) {
+	policy := CompilePolicy(
+		"conditions-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{Tool: "file.read", Action: Allow, Constraints: &ToolConstraints{
+				Conditions: &ToolConditions{
+					Clusters:     []string{"prod-*"},
+					Environments: []string{"production"},
+					Zones:        []string{"eu-*"},
+				},
+			}},
+		},
+		Enforcing,
+		"",
+	)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	tests := []struct {
+		name string
+		env  Environment
+		want Decision
+	}{
+		{"matching", Environment{Cluster: "prod-eu-1", Environment: "production", Zone: "eu-west-1a"}, Allow},
+		{"wrong cluster", Environment{Cluster: "dev-1", Environment: "production", Zone: "eu-west-1a"}, Deny},
+		{"wrong environment", Environment{Cluster: "prod-eu-1", Environment: "staging", Zone: "eu-west-1a"}, Deny},
+		{"wrong zone", Environment{Cluster: "prod-eu-1", Environment: "production", Zone: "us-east-1a"}, Deny},
+		{"unknown environment", Environment{}, Deny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(WithMode(Enforcing), WithEnvironment(tt.env))
+			engine.LoadPolicy("coding-assistant", policy)
+
+			result, err := engine.EvaluateWithResult(context.Background(), agent, "file.read", map[string]interface{}{"path": "/workspace/a"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Decision != tt.want {
+				t.Errorf("expected %v, got %v (%s)", tt.want, result.Decision, result.Reason)
+			}
+		})
+	}
+}
+
+// TestConditionsDestination tests the destination conditions of network
+// tool rules.
+func TestConditionsDestination(t *testing.T) {
+	geo, err := LoadCIDRGeoProvider(strings.NewReader(testGeoTable))
+	if err != nil {
+		t.Fatalf("failed to load GeoIP table: %v", err)
+	}
+
+	policy := CompilePolicy(
+		"conditions-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{Tool: "network.fetch", Action: Allow, Constraints: &ToolConstraints{
+				Conditions: &ToolConditions{DestinationContinents: []string{"EU"}},
+			}},
+		},
+		Enforcing,
+		"",
+	)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	hosts := map[string][]string{
+		"eu.example.com":    {"185.199.108.153", "185.1.2.3"},
+		"mixed.example.com": {"185.199.108.153", "203.0.113.10"},
+		"us.example.com":    {"203.0.113.10"},
+		"other.example.com": {"192.0.2.1"},
+	}
+	lookupHost := func(_ context.Context, host string) ([]net.IPAddr, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		var out []net.IPAddr
+		for _, a := range addrs {
+			out = append(out, net.IPAddr{IP: net.ParseIP(a)})
+		}
+		return out, nil
+	}
+
+	engine := NewEngine(WithMode(Enforcing), WithEnrichmentProvider(geo))
+	engine.conditions.lookupHost = lookupHost
+	engine.LoadPolicy("coding-assistant", policy)
+
+	tests := []struct {
+		domain string
+		want   Decision
+	}{
+		{"eu.example.com", Allow},
+		{"2001:db8::1", Allow},
+		{"mixed.example.com", Deny}, // every address must match
+		{"us.example.com", Deny},
+		{"other.example.com", Deny}, // not in the table
+		{"missing.example.com", Deny},
+		{"", Deny},
+	}
+	// Twice, so that a cached decision would show
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			params := map[string]interface{}{}
+			if tt.domain != "" {
+				params["domain"] = tt.domain
+			}
+			result, err := engine.EvaluateWithResult(context.Background(), agent, "network.fetch", params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Decision != tt.want {
+				t.Errorf("%q: expected %v, got %v (%s)", tt.domain, tt.want, result.Decision, result.Reason)
+			}
+		}
+	}
+
+	// Without a provider, destination conditions deny
+	engine = NewEngine(WithMode(Enforcing))
+	engine.conditions.lookupHost = lookupHost
+	engine.LoadPolicy("coding-assistant", policy)
+	result, err := engine.EvaluateWithResult(context.Background(), agent, "network.fetch", map[string]interface{}{"domain": "eu.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Decision != Deny {
+		t.Errorf("expected a denial without a provider, got %v", result.Decision)
+	}
+}
+
+// TestLoadCIDRGeoProvider tests parsing GeoIP tables and most specific
+// range lookups.
+func TestLoadCIDRGeoProvider(t *testing.T) {
+	geo, err := LoadCIDRGeoProvider(strings.NewReader(testGeoTable))
+	if err != nil {
+		t.Fatalf("failed to load GeoIP table: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want Destination
+	}{
+		{"185.199.108.153", Destination{Country: "DE", Continent: "EU", ASN: 54113}},
+		{"185.1.2.3", Destination{Country: "NL", Continent: "EU"}},
+		{"203.0.113.10", Destination{Country: "US", Continent: "NA", ASN: 64500}},
+		{"2001:db8::1", Destination{Country: "FR", Continent: "EU", ASN: 64501}},
+	}
+	for _, tt := range tests {
+		got, err := geo.LookupIP(context.Background(), net.ParseIP(tt.ip))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ip, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.ip, tt.want, got)
+		}
+	}
+	if _, err := geo.LookupIP(context.Background(), net.ParseIP("192.0.2.1")); err == nil {
+		t.Error("expected an error for an address outside the table")
+	}
+
+	for _, table := range []string{"185.0.0.0/8,NL,EU", "185.0.0.0,NL,EU,", "185.0.0.0/8,NL,EU,ASX"} {
+		if _, err := LoadCIDRGeoProvider(strings.NewReader(table)); err == nil {
+			t.Errorf("%q: expected an error", table)
+		}
+	}
+}
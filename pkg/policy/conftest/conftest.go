@@ -0,0 +1,427 @@
+// Package conftest exports AgentPolicies as a conftest policy pack.
+//
+// CI pipelines can check the plan of an agent task, the tool calls it
+// intends to make, against production policy before the agent ever runs:
+//
+//	agentType: coding-assistant
+//	tenantID: tenant-a
+//	labels:
+//	  team: payments
+//	calls:
+//	  - tool: file.read
+//	    parameters:
+//	      path: /workspace/src/main.go
+//	  - tool: shell.execute
+//
+// The pack holds the Rego each policy compiles to, unchanged but for its
+// package, moved from agentpolicy to agentpolicies["namespace/name"], and
+// a main package that resolves the plan's agent type and labels to a
+// policy as the router does, evaluates the policy's decision for each
+// call, and denies the calls it denies. Calls permissive policies deny
+// are warned about. Plans of agent types no policy governs are denied, as
+// the router denies their calls:
+//
+//	conftest test --policy DIR plan.yaml
+//
+// Only the main package defines conftest rules: do not test with
+// --all-namespaces. Parts of a policy only the router enforces, such as
+// rate limits, budgets, and constraints evaluated outside Rego, are not
+// checked; Export reports what each policy loses.
+//
+// Usage:
+//
+//	pack, findings, err := conftest.Export(policies)
+//	conftest.Write(dir, pack)
+package conftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// MainModule is the file name of the main package of a pack.
+const MainModule = "main.rego"
+
+// mainRego is the main package of a pack; the JSON of the bindings of
+// the exported policies is appended to it.
+const mainRego = `# Generated by apctl conftest from AgentPolicies: export them again
+# rather than editing it.
+package main
+
+import future.keywords.contains
+import future.keywords.every
+import future.keywords.if
+import future.keywords.in
+
+agent_type := object.get(input, "agentType", "")
+
+labels := object.get(input, "labels", {})
+
+calls := object.get(input, "calls", [])
+
+# matches are the indexes of the bindings governing the plan's agent
+matches contains i if {
+	some i, b in bindings
+	type_matches(b)
+	selector_matches(object.get(b, "selector", {}))
+}
+
+type_matches(b) if b.agentType == agent_type
+
+type_matches(b) if {
+	b.pattern
+	glob.match(b.agentType, [], agent_type)
+}
+
+selector_matches(s) if {
+	every k, v in object.get(s, "matchLabels", {}) {
+		labels[k] == v
+	}
+	every e in object.get(s, "matchExpressions", []) {
+		expression_matches(e)
+	}
+}
+
+expression_matches(e) if {
+	e.operator == "In"
+	labels[e.key] in e.values
+}
+
+expression_matches(e) if {
+	e.operator == "NotIn"
+	value := object.get(labels, e.key, null)
+	not value in e.values
+}
+
+expression_matches(e) if {
+	e.operator == "Exists"
+	_ = labels[e.key]
+}
+
+expression_matches(e) if {
+	e.operator == "DoesNotExist"
+	not has_label(e.key)
+}
+
+has_label(key) if _ = labels[key]
+
+# binding governs the plan: bindings are ordered most specific first
+binding := bindings[min(matches)]
+
+call_input(call) := {
+	"tool": call.tool,
+	"request": object.get(call, "parameters", {}),
+	"agent": {
+		"type": agent_type,
+		"sandbox_id": object.get(input, "sandboxID", ""),
+		"tenant_id": object.get(input, "tenantID", ""),
+		"session_id": object.get(input, "sessionID", ""),
+		"mts_label": object.get(input, "mtsLabel", ""),
+		"mts_dominates": false,
+		"labels": labels,
+	},
+	"policy": {"name": binding.name, "mts_label": binding.mtsLabel},
+}
+
+# decision is the policy's decision of a call; the binding is resolved
+# before the input is replaced with the call's
+decision(call) := d if {
+	key := binding.policy
+	call_in := call_input(call)
+	d := data.agentpolicies[key].decision with input as call_in
+}
+
+denied(d) if d.mts == false
+
+denied(d) if d.deny
+
+denied(d) if not d.allow
+
+denials contains msg if {
+	some i, call in calls
+	d := decision(call)
+	denied(d)
+	msg := sprintf("call %d: AgentPolicy %s denies agent type %s the tool %s: %s", [i + 1, binding.policy, agent_type, call.tool, d.reason])
+}
+
+deny contains msg if {
+	binding.enforcing
+	some msg in denials
+}
+
+warn contains msg if {
+	not binding.enforcing
+	some denial in denials
+	msg := sprintf("%s (permissive)", [denial])
+}
+
+deny contains msg if {
+	count(matches) == 0
+	msg := sprintf("no AgentPolicy governs agent type %s: its calls are denied", [agent_type])
+}
+
+deny contains msg if {
+	some i, call in calls
+	not is_string(call.tool)
+	msg := sprintf("call %d: no tool", [i + 1])
+}
+
+# bindings are the agent types of the policies, most specific first, as
+# the router resolves them
+bindings := `
+
+// Pack is a conftest policy pack: Rego modules keyed by slash-separated
+// path relative to the pack directory.
+type Pack map[string]string
+
+// Finding is a part of an AgentPolicy the pack does not check.
+type Finding struct {
+	// Policy is the AgentPolicy, as "namespace/name"
+	Policy string
+
+	// Message describes what the pack does not check
+	Message string
+
+	// Skipped is set if the policy was not exported at all
+	Skipped bool
+}
+
+func (f Finding) String() string {
+	if f.Skipped {
+		return fmt.Sprintf("%s: not exported: %s", f.Policy, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Policy, f.Message)
+}
+
+// binding is an agent type a policy is bound to, as the main package
+// resolves it.
+type binding struct {
+	AgentType string `json:"agentType"`
+	Pattern   bool   `json:"pattern"`
+
+	// Selector is the policy's agentSelector
+	Selector interface{} `json:"selector,omitempty"`
+
+	// Policy is the package key of the policy, and Name, MTSLabel, and
+	// Enforcing those of the compiled policy
+	Policy    string `json:"policy"`
+	Name      string `json:"name"`
+	MTSLabel  string `json:"mtsLabel"`
+	Enforcing bool   `json:"enforcing"`
+
+	// selectorSize ranks bindings of equal type specificity
+	selectorSize int
+}
+
+var (
+	// packageClause matches the package clause of a generated module
+	packageClause = regexp.MustCompile(`(?m)^package agentpolicy\b`)
+
+	// dataRef matches references to the generated packages
+	dataRef = regexp.MustCompile(`\bdata\.agentpolicy\b`)
+)
+
+// Export compiles policies and returns their pack, and what it does not
+// check. Canaries are not exported, since they apply to a share of
+// sandboxes a plan does not name. It fails if a policy does not compile.
+func Export(policies []*agentsv1alpha1.AgentPolicy) (Pack, []Finding, error) {
+	pack := Pack{}
+	var findings []Finding
+	bindings := []binding{}
+	bound := make(map[string]string)
+	exported := make(map[string]bool)
+
+	for _, ap := range policies {
+		ref := ap.Name
+		if ap.Namespace != "" {
+			ref = ap.Namespace + "/" + ap.Name
+		}
+		if ap.Spec.Canary != nil {
+			findings = append(findings, Finding{Policy: ref, Message: "canaries apply to a share of sandboxes, which a plan does not name", Skipped: true})
+			continue
+		}
+		if exported[ref] {
+			return nil, nil, fmt.Errorf("policy %s is listed twice", ref)
+		}
+		exported[ref] = true
+
+		result, err := compile.AgentPolicy(ap, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile policy %s: %w", ref, err)
+		}
+		compiled := result.Policy
+		modules := compiled.RegoModules
+		if len(modules) == 0 {
+			modules = map[string]string{"policy.rego": compiled.RegoModule}
+		}
+		pkg := fmt.Sprintf("agentpolicies[%q]", ref)
+		for name, module := range modules {
+			module = packageClause.ReplaceAllString(module, "package "+pkg)
+			pack[modulePath(ref, name)] = dataRef.ReplaceAllString(module, "data."+pkg)
+		}
+
+		keys := append([]string(nil), ap.Spec.AgentTypes...)
+		if ap.Spec.Fallback {
+			keys = append(keys, policy.FallbackAgentType)
+		}
+		var selector interface{}
+		if ap.Spec.AgentSelector != nil {
+			selector = ap.Spec.AgentSelector
+		}
+		for _, key := range keys {
+			if other, ok := bound[key]; ok {
+				findings = append(findings, Finding{Policy: ref, Message: fmt.Sprintf("agent type %s is bound to %s, which plans are checked against", key, other)})
+				continue
+			}
+			bound[key] = ref
+			bindings = append(bindings, binding{
+				AgentType:    key,
+				Pattern:      policy.IsAgentTypePattern(key),
+				Selector:     selector,
+				Policy:       ref,
+				Name:         compiled.Name,
+				MTSLabel:     compiled.MTSLabel,
+				Enforcing:    compiled.Mode == policy.Enforcing,
+				selectorSize: selectorSize(ap),
+			})
+		}
+		findings = append(findings, unchecked(ref, &ap.Spec)...)
+	}
+
+	sortBindings(bindings)
+	data, err := json.MarshalIndent(bindings, "", "\t")
+	if err != nil {
+		return nil, nil, err
+	}
+	pack[MainModule] = mainRego + string(data) + "\n"
+	return pack, findings, nil
+}
+
+// modulePath returns the path of a module of policy ref in a pack.
+func modulePath(ref, name string) string {
+	return path.Join("agentpolicies", ref, name)
+}
+
+// selectorSize counts the requirements of a policy's agentSelector.
+func selectorSize(ap *agentsv1alpha1.AgentPolicy) int {
+	if s := ap.Spec.AgentSelector; s != nil {
+		return len(s.MatchLabels) + len(s.MatchExpressions)
+	}
+	return 0
+}
+
+// sortBindings orders bindings as the engine's resolver ranks them: exact
+// agent types first, then patterns by their number of literal characters
+// and the size of their selectors.
+func sortBindings(bindings []binding) {
+	literals := func(pattern string) int {
+		n, inClass := 0, false
+		for _, r := range pattern {
+			switch {
+			case r == '[':
+				inClass = true
+			case r == ']':
+				inClass = false
+			case r == '*' || r == '?' || inClass:
+			default:
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(bindings, func(i, j int) bool {
+		a, b := bindings[i], bindings[j]
+		if a.Pattern != b.Pattern {
+			return !a.Pattern
+		}
+		if la, lb := literals(a.AgentType), literals(b.AgentType); la != lb {
+			return la > lb
+		}
+		if a.selectorSize != b.selectorSize {
+			return a.selectorSize > b.selectorSize
+		}
+		return a.AgentType < b.AgentType
+	})
+}
+
+// unchecked returns the findings of what the pack does not check of a
+// policy's spec.
+func unchecked(ref string, spec *agentsv1alpha1.AgentPolicySpec) []Finding {
+	var findings []Finding
+	finding := func(format string, args ...interface{}) {
+		findings = append(findings, Finding{Policy: ref, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, perm := range spec.ToolPermissions {
+		if len(perm.Mutators) > 0 {
+			finding("calls to %s are checked before its mutators rewrite them", perm.Tool)
+		}
+		if perm.Action != agentsv1alpha1.DecisionAllow || perm.Constraints == nil {
+			continue
+		}
+		if parts := routerConstraints(perm.Constraints); len(parts) > 0 {
+			finding("the %s constraints of tool %s are not checked: only the router enforces them", strings.Join(parts, ", "), perm.Tool)
+		}
+	}
+	if spec.TenantIsolation != nil {
+		finding("the plan's mtsLabel must match the policy's: parent tenants do not dominate it")
+	}
+	if spec.Profile != nil {
+		finding("the behavior profile is not checked")
+	}
+	if spec.RateLimit != nil {
+		finding("the rate limit is not checked")
+	}
+	if spec.Budget != nil {
+		finding("the budget is not checked")
+	}
+	return findings
+}
+
+// routerConstraints returns the names of the constraints the generated
+// Rego does not evaluate.
+func routerConstraints(c *agentsv1alpha1.ToolConstraints) []string {
+	var parts []string
+	add := func(set bool, name string) {
+		if set {
+			parts = append(parts, name)
+		}
+	}
+	add(c.ResolveSymlinks, "resolveSymlinks")
+	add(c.Timeout != "", "timeout")
+	add(c.MaxConcurrent != nil, "maxConcurrent")
+	add(c.Conditions != nil, "conditions")
+	add(c.Modbus != nil, "modbus")
+	add(c.OPCUA != nil, "opcua")
+	add(len(c.Custom) > 0, "custom")
+	return parts
+}
+
+// Write writes pack to dir, creating it. Modules already in dir are
+// replaced, and others left alone.
+func Write(dir string, pack Pack) error {
+	names := make([]string, 0, len(pack))
+	for name := range pack {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(pack[name]), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
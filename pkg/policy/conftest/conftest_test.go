@@ -0,0 +1,157 @@
+package conftest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+func testPolicy(name string, spec agentsv1alpha1.AgentPolicySpec) *agentsv1alpha1.AgentPolicy {
+	return &agentsv1alpha1.AgentPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"}, Spec: spec}
+}
+
+// check evaluates the conftest rules of pack for plan, as conftest does.
+func check(t *testing.T, pack Pack, plan string) (deny, warn []string) {
+	t.Helper()
+	var input interface{}
+	if err := yaml.Unmarshal([]byte(plan), &input); err != nil {
+		t.Fatal(err)
+	}
+	opts := []func(*rego.Rego){rego.Query("deny := data.main.deny; warn := data.main.warn"), rego.Input(input)}
+	for name, module := range pack {
+		opts = append(opts, rego.Module(name, module))
+	}
+	rs, err := rego.New(opts...).Eval(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected one result, got %v", rs)
+	}
+	messages := func(v interface{}) []string {
+		var msgs []string
+		for _, m := range v.([]interface{}) {
+			msgs = append(msgs, m.(string))
+		}
+		sort.Strings(msgs)
+		return msgs
+	}
+	return messages(rs[0].Bindings["deny"]), messages(rs[0].Bindings["warn"])
+}
+
+// TestExport tests that the pack denies the calls of plans the exported
+// policies deny, resolving agent types as the router does, and the
+// findings of what it does not check.
+func TestExport(t *testing.T) {
+	pack, findings, err := Export([]*agentsv1alpha1.AgentPolicy{
+		testPolicy("coding", agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Mode:          agentsv1alpha1.EnforcementModeEnforcing,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{
+				{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow, Constraints: &agentsv1alpha1.ToolConstraints{RequiredAgentLabels: map[string]string{"team": "payments"}, Timeout: "5s"}},
+				{Tool: "shell.execute", Action: agentsv1alpha1.DecisionDeny},
+			},
+			RateLimit: &agentsv1alpha1.RateLimitSpec{},
+		}),
+		testPolicy("coding-v2", agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:   []string{"coding-*"},
+			RegoTemplate: agentsv1alpha1.RegoTemplateV2,
+			Mode:         agentsv1alpha1.EnforcementModePermissive,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{
+				{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow},
+			},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+		}),
+		testPolicy("canary", agentsv1alpha1.AgentPolicySpec{
+			Canary: &agentsv1alpha1.CanarySpec{Stable: "coding", Percent: 10},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name := range pack {
+		names = append(names, name)
+	}
+	if _, ok := pack[MainModule]; !ok || !strings.Contains(strings.Join(names, " "), "agentpolicies/team-a/coding-v2/") {
+		t.Fatalf("expected the main module and the modules of both policies, got %v", names)
+	}
+	for name, module := range pack {
+		if name != MainModule && strings.Contains(module, "package agentpolicy\n") {
+			t.Errorf("expected module %s to be moved out of package agentpolicy", name)
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		plan       string
+		deny, warn []string
+	}{{
+		name: "allowed",
+		plan: "agentType: coding-assistant\nlabels: {team: payments}\ncalls:\n- tool: file.read\n  parameters: {path: /workspace/main.go}\n",
+	}, {
+		name: "denied",
+		plan: "agentType: coding-assistant\nlabels: {team: research}\ncalls:\n- tool: file.read\n- tool: shell.execute\n",
+		deny: []string{"call 1: AgentPolicy team-a/coding denies", "call 2: AgentPolicy team-a/coding denies"},
+	}, {
+		name: "permissive pattern",
+		plan: "agentType: coding-reviewer\ncalls:\n- tool: file.read\n- tool: network.fetch\n",
+		warn: []string{"call 2: AgentPolicy team-a/coding-v2 denies"},
+	}, {
+		name: "ungoverned",
+		plan: "agentType: research-assistant\ncalls:\n- tool: file.read\n",
+		deny: []string{"no AgentPolicy governs agent type research-assistant"},
+	}} {
+		deny, warn := check(t, pack, tc.plan)
+		for _, want := range []struct {
+			kind      string
+			got, want []string
+		}{{"deny", deny, tc.deny}, {"warn", warn, tc.warn}} {
+			if len(want.got) != len(want.want) {
+				t.Errorf("%s: expected %s %v, got %v", tc.name, want.kind, want.want, want.got)
+				continue
+			}
+			for i := range want.got {
+				if !strings.HasPrefix(want.got[i], want.want[i]) {
+					t.Errorf("%s: expected %s %q, got %q", tc.name, want.kind, want.want[i], want.got[i])
+				}
+			}
+		}
+	}
+
+	var messages []string
+	for _, f := range findings {
+		messages = append(messages, f.String())
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{
+		"team-a/canary: not exported: canaries",
+		"team-a/coding: the timeout constraints of tool file.read are not checked",
+		"team-a/coding: the rate limit is not checked",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected finding %q, got:\n%s", want, joined)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := Write(dir, pack); err != nil {
+		t.Fatal(err)
+	}
+	for name := range pack {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+}
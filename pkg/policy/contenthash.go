@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentHashParam is the request parameter carrying the SHA-256 digest of
+// the artifact a tool call runs (e.g., the script passed to code.execute).
+const ContentHashParam = "content_sha256"
+
+// ErrContentHashMismatch is returned by VerifyContent when the content does
+// not hash to the digest the request was authorized with.
+var ErrContentHashMismatch = errors.New("content does not match authorized hash")
+
+// ContentHash returns the lowercase hex SHA-256 digest of content.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHashReader returns the lowercase hex SHA-256 digest of everything
+// read from r.
+func ContentHashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("failed to hash content: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NormalizeContentHash converts a SHA-256 digest to canonical form: 64
+// lowercase hex characters without a "sha256:" prefix.
+// Returns false if the value is not a SHA-256 digest.
+func NormalizeContentHash(digest string) (string, bool) {
+	d := strings.ToLower(strings.TrimSpace(digest))
+	d = strings.TrimPrefix(d, "sha256:")
+	if len(d) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(d); err != nil {
+		return "", false
+	}
+	return d, true
+}
+
+// AllowsContentHash reports whether digest is on the AllowedContentHashes
+// allowlist. A missing or malformed digest never matches.
+func (c *ToolConstraints) AllowsContentHash(digest string) bool {
+	d, ok := NormalizeContentHash(digest)
+	if !ok {
+		return false
+	}
+	for _, allowed := range c.AllowedContentHashes {
+		if a, ok := NormalizeContentHash(allowed); ok && a == d {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyContent checks that content hashes to the digest a request was
+// authorized with. Executors must call it on the exact bytes they are about
+// to run, after policy approval: the policy only sees the digest the agent
+// claims, so without this check an agent could pair an allowlisted digest
+// with different content.
+//
+//	digest, _ := params[policy.ContentHashParam].(string)
+//	if err := policy.VerifyContent(script, digest); err != nil {
+//		return nil, err
+//	}
+func VerifyContent(content []byte, digest string) error {
+	d, ok := NormalizeContentHash(digest)
+	if !ok {
+		return fmt.Errorf("%w: missing or malformed digest %q", ErrContentHashMismatch, digest)
+	}
+	if got := ContentHash(content); got != d {
+		return fmt.Errorf("%w: got sha256:%s, authorized sha256:%s", ErrContentHashMismatch, got, d)
+	}
+	return nil
+}
@@ -0,0 +1,870 @@
+// Package diff computes semantic differences between compiled policies.
+//
+// Unlike a textual diff of the CRD YAML, a semantic diff reports what a
+// change means for enforcement: which tool rules were added or removed,
+// which constraints were tightened or loosened, and whether the default
+// action or enforcement mode changed. Reviewers can use the Effect of each
+// change to spot policy updates that widen an agent's access.
+//
+// Usage:
+//
+//	d := diff.Compare(oldPolicy, newPolicy)
+//	if d.Loosens() {
+//	    // require extra review
+//	}
+//	fmt.Print(d)
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// Kind identifies what part of a policy changed.
+type Kind string
+
+const (
+	// KindDefaultAction is a change of the policy's default action
+	KindDefaultAction Kind = "DefaultActionChanged"
+
+	// KindMode is a change of the enforcement mode
+	KindMode Kind = "ModeChanged"
+
+	// KindMTSLabel is a change of the tenant isolation label
+	KindMTSLabel Kind = "MTSLabelChanged"
+
+	// KindProfile is a change of behavior profile enforcement
+	KindProfile Kind = "ProfileEnforcementChanged"
+
+	// KindRateLimit is a change of the per-client rate limit override
+	KindRateLimit Kind = "RateLimitChanged"
+
+	// KindAgentSelector is a change of the agent label selector
+	KindAgentSelector Kind = "AgentSelectorChanged"
+
+	// KindBudget is a change of the session or tenant spend budget
+	KindBudget Kind = "BudgetChanged"
+
+	// KindPriority is a change of the priority among applicable policies
+	KindPriority Kind = "PriorityChanged"
+
+	// KindCacheTTL is a change of the decision cache TTL override
+	KindCacheTTL Kind = "CacheTTLChanged"
+
+	// KindRuleAdded is a tool rule present only in the new policy
+	KindRuleAdded Kind = "RuleAdded"
+
+	// KindRuleRemoved is a tool rule present only in the old policy
+	KindRuleRemoved Kind = "RuleRemoved"
+
+	// KindRuleAction is a tool rule whose action changed
+	KindRuleAction Kind = "RuleActionChanged"
+
+	// KindConstraint is a change to one constraint of a tool rule
+	KindConstraint Kind = "ConstraintChanged"
+)
+
+// Effect describes how a change affects what agents are allowed to do.
+type Effect string
+
+const (
+	// Tightened means the new policy allows less than the old one
+	Tightened Effect = "tightened"
+
+	// Loosened means the new policy allows more than the old one
+	Loosened Effect = "loosened"
+
+	// Modified means the change neither strictly widens nor narrows access
+	// (e.g., one path pattern replaced by another)
+	Modified Effect = "modified"
+)
+
+// Change is a single semantic difference between two policies.
+type Change struct {
+	// Kind identifies what changed
+	Kind Kind
+
+	// Effect is whether access was tightened, loosened, or modified
+	Effect Effect
+
+	// Tool is the affected tool (empty for policy-level changes)
+	Tool string
+
+	// Field is the affected constraint (only for KindConstraint)
+	Field string
+
+	// Old is the previous value, formatted for display
+	Old string
+
+	// New is the new value, formatted for display
+	New string
+}
+
+// String formats the change as a single human-readable line.
+func (c Change) String() string {
+	subject := kindSubject(c.Kind)
+	switch c.Kind {
+	case KindRuleAdded:
+		return fmt.Sprintf("[%s] rule added: %s %s", c.Effect, c.Tool, c.New)
+	case KindRuleRemoved:
+		return fmt.Sprintf("[%s] rule removed: %s %s", c.Effect, c.Tool, c.Old)
+	case KindRuleAction:
+		subject = c.Tool + " action"
+	case KindConstraint:
+		subject = c.Tool + " " + c.Field
+	}
+	return fmt.Sprintf("[%s] %s: %s -> %s", c.Effect, subject, c.Old, c.New)
+}
+
+// Diff is the set of semantic changes between two policies.
+type Diff struct {
+	// Changes are ordered policy-level first, then by tool name
+	Changes []Change
+}
+
+// Empty reports whether the policies are semantically identical.
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Loosens reports whether any change widens agent access.
+func (d *Diff) Loosens() bool {
+	for _, c := range d.Changes {
+		if c.Effect == Loosened {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary returns a one-line summary suitable for a CRD status field,
+// e.g. "2 rules added, 1 constraint tightened, default action DENY -> ALLOW".
+func (d *Diff) Summary() string {
+	if d.Empty() {
+		return "no changes"
+	}
+
+	var added, removed, actions int
+	constraints := make(map[Effect]int)
+	var parts []string
+
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case KindRuleAdded:
+			added++
+		case KindRuleRemoved:
+			removed++
+		case KindRuleAction:
+			actions++
+		case KindConstraint:
+			constraints[c.Effect]++
+		case KindAgentSelector:
+			parts = append(parts, "agent selector changed")
+		default:
+			parts = append(parts, fmt.Sprintf("%s %s -> %s", kindSubject(c.Kind), c.Old, c.New))
+		}
+	}
+
+	if added > 0 {
+		parts = append(parts, plural(added, "rule")+" added")
+	}
+	if removed > 0 {
+		parts = append(parts, plural(removed, "rule")+" removed")
+	}
+	if actions > 0 {
+		parts = append(parts, plural(actions, "rule action")+" changed")
+	}
+	for _, effect := range []Effect{Tightened, Loosened, Modified} {
+		if n := constraints[effect]; n > 0 {
+			parts = append(parts, plural(n, "constraint")+" "+string(effect))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// String formats the diff with one change per line.
+func (d *Diff) String() string {
+	var b strings.Builder
+	for _, c := range d.Changes {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Compare computes the semantic diff from old to new.
+// A nil policy is treated as an empty policy that denies everything.
+func Compare(old, new *policy.CompiledPolicy) *Diff {
+	if old == nil {
+		old = &policy.CompiledPolicy{DefaultAction: policy.Deny, Mode: policy.Enforcing}
+	}
+	if new == nil {
+		new = &policy.CompiledPolicy{DefaultAction: policy.Deny, Mode: policy.Enforcing}
+	}
+
+	d := &Diff{}
+
+	if old.DefaultAction != new.DefaultAction {
+		d.add(Change{
+			Kind:   KindDefaultAction,
+			Effect: actionEffect(old.DefaultAction, new.DefaultAction),
+			Old:    old.DefaultAction.String(),
+			New:    new.DefaultAction.String(),
+		})
+	}
+
+	if old.Mode != new.Mode {
+		effect := Tightened
+		if new.Mode == policy.Permissive {
+			effect = Loosened
+		}
+		d.add(Change{Kind: KindMode, Effect: effect, Old: old.Mode.String(), New: new.Mode.String()})
+	}
+
+	if old.MTSLabel != new.MTSLabel {
+		d.add(Change{Kind: KindMTSLabel, Effect: Modified, Old: displayString(old.MTSLabel), New: displayString(new.MTSLabel)})
+	}
+
+	if c := compareProfile(old, new); c != nil {
+		d.add(*c)
+	}
+
+	if c := compareRateLimit(old.RateLimit, new.RateLimit); c != nil {
+		d.add(*c)
+	}
+
+	if sel := compareSelectors(old.AgentSelector, new.AgentSelector); sel != nil {
+		d.add(*sel)
+	}
+
+	if c := compareBudget(old.Budget, new.Budget); c != nil {
+		d.add(*c)
+	}
+
+	if old.Priority != new.Priority {
+		d.add(Change{Kind: KindPriority, Effect: Modified, Old: fmt.Sprint(old.Priority), New: fmt.Sprint(new.Priority)})
+	}
+
+	if old.CacheTTL != new.CacheTTL {
+		d.add(Change{Kind: KindCacheTTL, Effect: Modified, Old: cacheTTLString(old.CacheTTL), New: cacheTTLString(new.CacheTTL)})
+	}
+
+	for _, tool := range toolNames(old, new) {
+		d.compareRule(tool, old, new)
+	}
+
+	return d
+}
+
+// compareRule diffs a single tool's rule. A tool missing from a policy
+// falls under that policy's default action.
+func (d *Diff) compareRule(tool string, old, new *policy.CompiledPolicy) {
+	oldPerm, inOld := old.ToolTable[tool]
+	newPerm, inNew := new.ToolTable[tool]
+
+	switch {
+	case !inOld:
+		d.add(Change{
+			Kind:   KindRuleAdded,
+			Effect: actionEffect(old.DefaultAction, newPerm.Action),
+			Tool:   tool,
+			New:    newPerm.Action.String(),
+		})
+		return
+	case !inNew:
+		d.add(Change{
+			Kind:   KindRuleRemoved,
+			Effect: actionEffect(oldPerm.Action, new.DefaultAction),
+			Tool:   tool,
+			Old:    oldPerm.Action.String(),
+		})
+		return
+	}
+
+	if oldPerm.Action != newPerm.Action {
+		d.add(Change{
+			Kind:   KindRuleAction,
+			Effect: actionEffect(oldPerm.Action, newPerm.Action),
+			Tool:   tool,
+			Old:    oldPerm.Action.String(),
+			New:    newPerm.Action.String(),
+		})
+		return
+	}
+
+	// Constraints and obligations only matter on allow rules. An added
+	// obligation is a new duty before execution, so it tightens the rule.
+	if newPerm.Action == policy.Allow {
+		for _, c := range compareConstraints(oldPerm.Constraints, newPerm.Constraints) {
+			c.Tool = tool
+			d.add(c)
+		}
+		if c := compareDenyList("obligations", obligationStrings(oldPerm.Obligations), obligationStrings(newPerm.Obligations)); c != nil {
+			c.Tool = tool
+			d.add(*c)
+		}
+		// A costlier call uses up budgets sooner
+		if oldPerm.Cost != newPerm.Cost {
+			effect := Tightened
+			if newPerm.Cost < oldPerm.Cost {
+				effect = Loosened
+			}
+			d.add(Change{Kind: KindConstraint, Effect: effect, Tool: tool, Field: "cost", Old: fmt.Sprintf("%d", oldPerm.Cost), New: fmt.Sprintf("%d", newPerm.Cost)})
+		}
+	}
+
+	// A riskier tool is subject to more risk rules
+	if oldPerm.RiskLevel != newPerm.RiskLevel {
+		effect := Tightened
+		if policy.RiskRank(newPerm.RiskLevel) < policy.RiskRank(oldPerm.RiskLevel) {
+			effect = Loosened
+		}
+		d.add(Change{Kind: KindConstraint, Effect: effect, Tool: tool, Field: "riskLevel", Old: string(oldPerm.RiskLevel), New: string(newPerm.RiskLevel)})
+	}
+}
+
+// obligationStrings formats obligations for set comparison and display.
+func obligationStrings(obligations []policy.Obligation) []string {
+	if len(obligations) == 0 {
+		return nil
+	}
+	out := make([]string, len(obligations))
+	for i, o := range obligations {
+		out[i] = o.String()
+	}
+	return out
+}
+
+func (d *Diff) add(c Change) {
+	d.Changes = append(d.Changes, c)
+}
+
+// compareConstraints diffs the constraints of an allow rule field by field.
+// A nil constraints struct is equivalent to no constraints.
+func compareConstraints(old, new *policy.ToolConstraints) []Change {
+	if old == nil {
+		old = &policy.ToolConstraints{}
+	}
+	if new == nil {
+		new = &policy.ToolConstraints{}
+	}
+
+	var changes []Change
+	appendIf := func(c *Change) {
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+
+	appendIf(compareAllowList("pathPatterns", old.PathPatterns, new.PathPatterns))
+	appendIf(compareSafeguard("resolveSymlinks", old.ResolveSymlinks, new.ResolveSymlinks))
+	appendIf(compareAllowList("allowedDomains", old.AllowedDomains, new.AllowedDomains))
+	appendIf(compareDenyList("deniedDomains", old.DeniedDomains, new.DeniedDomains))
+	appendIf(compareAllowList("allowedPorts", intsToStrings(old.AllowedPorts), intsToStrings(new.AllowedPorts)))
+	appendIf(compareLimit("maxSizeBytes", old.MaxSizeBytes, new.MaxSizeBytes, func(v int64) string { return fmt.Sprintf("%d", v) }))
+	appendIf(compareLimit("timeout", int64(old.Timeout), int64(new.Timeout), func(v int64) string { return time.Duration(v).String() }))
+	appendIf(compareLimit("maxConcurrent", int64(old.MaxConcurrent), int64(new.MaxConcurrent), func(v int64) string { return fmt.Sprintf("%d", v) }))
+	appendIf(compareLimit("retry.maxAttempts", retryAttempts(old.Retry), retryAttempts(new.Retry), func(v int64) string { return fmt.Sprintf("%d", v) }))
+	appendIf(compareRequiredLabels(old.RequiredAgentLabels, new.RequiredAgentLabels))
+	appendIf(compareAllowList("allowedContentHashes", old.AllowedContentHashes, new.AllowedContentHashes))
+	changes = append(changes, compareConditions(old.Conditions, new.Conditions)...)
+	appendIf(compareExtensions(old.Extensions, new.Extensions))
+	changes = append(changes, compareCustom(old.Custom, new.Custom)...)
+
+	return changes
+}
+
+// compareConditions diffs the conditions of a rule. Each condition is an
+// allow list.
+func compareConditions(old, new *policy.ToolConditions) []Change {
+	if old == nil {
+		old = &policy.ToolConditions{}
+	}
+	if new == nil {
+		new = &policy.ToolConditions{}
+	}
+
+	var changes []Change
+	for _, c := range []*Change{
+		compareAllowList("conditions.clusters", old.Clusters, new.Clusters),
+		compareAllowList("conditions.environments", old.Environments, new.Environments),
+		compareAllowList("conditions.zones", old.Zones, new.Zones),
+		compareAllowList("conditions.destinationCountries", old.DestinationCountries, new.DestinationCountries),
+		compareAllowList("conditions.destinationContinents", old.DestinationContinents, new.DestinationContinents),
+		compareAllowList("conditions.destinationASNs", asnsToStrings(old.DestinationASNs), asnsToStrings(new.DestinationASNs)),
+	} {
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	return changes
+}
+
+// compareAllowList diffs a list where an empty list means "unrestricted".
+func compareAllowList(field string, old, new []string) *Change {
+	effect, changed := setEffect(old, new)
+	if !changed {
+		return nil
+	}
+
+	// An empty allow list permits everything, which inverts the set logic
+	switch {
+	case len(old) == 0:
+		effect = Tightened
+	case len(new) == 0:
+		effect = Loosened
+	}
+
+	return &Change{Kind: KindConstraint, Effect: effect, Field: field, Old: displayList(old), New: displayList(new)}
+}
+
+// compareDenyList diffs a list where more entries means less access.
+func compareDenyList(field string, old, new []string) *Change {
+	effect, changed := setEffect(old, new)
+	if !changed {
+		return nil
+	}
+
+	switch effect {
+	case Tightened:
+		effect = Loosened
+	case Loosened:
+		effect = Tightened
+	}
+
+	return &Change{Kind: KindConstraint, Effect: effect, Field: field, Old: displayList(old), New: displayList(new)}
+}
+
+// compareExtensions diffs constraint extensions. Their semantics are opaque
+// here, so any change is reported as modified.
+func compareExtensions(old, new []policy.ConstraintExtension) *Change {
+	oldStr, newStr := displayExtensions(old), displayExtensions(new)
+	if oldStr == newStr {
+		return nil
+	}
+	return &Change{Kind: KindConstraint, Effect: Modified, Field: "extensions", Old: oldStr, New: newStr}
+}
+
+// displayExtensions formats extensions as "name{fields}" entries.
+func displayExtensions(exts []policy.ConstraintExtension) string {
+	parts := make([]string, len(exts))
+	for i, ext := range exts {
+		parts[i] = fmt.Sprintf("%s%+v", ext.Name(), ext)
+	}
+	return displayList(parts)
+}
+
+// compareCustom diffs custom constraint kinds one kind at a time. Adding a
+// kind adds a check and removing one drops it; a changed configuration is
+// opaque, so it is reported as Modified.
+func compareCustom(old, new map[string]json.RawMessage) []Change {
+	kinds := make(map[string]bool, len(old)+len(new))
+	for kind := range old {
+		kinds[kind] = true
+	}
+	for kind := range new {
+		kinds[kind] = true
+	}
+	sorted := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		sorted = append(sorted, kind)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, kind := range sorted {
+		oldCfg, inOld := old[kind]
+		newCfg, inNew := new[kind]
+		field := "custom." + kind
+		switch {
+		case !inOld:
+			changes = append(changes, Change{Kind: KindConstraint, Effect: Tightened, Field: field, Old: displayString(""), New: string(newCfg)})
+		case !inNew:
+			changes = append(changes, Change{Kind: KindConstraint, Effect: Loosened, Field: field, Old: string(oldCfg), New: displayString("")})
+		case !bytes.Equal(oldCfg, newCfg):
+			changes = append(changes, Change{Kind: KindConstraint, Effect: Modified, Field: field, Old: string(oldCfg), New: string(newCfg)})
+		}
+	}
+	return changes
+}
+
+// compareSafeguard diffs a boolean where enabling it restricts access.
+func compareSafeguard(field string, old, new bool) *Change {
+	if old == new {
+		return nil
+	}
+	effect := Loosened
+	if new {
+		effect = Tightened
+	}
+	return &Change{Kind: KindConstraint, Effect: effect, Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)}
+}
+
+// compareLimit diffs a numeric upper bound where 0 means "unlimited".
+func compareLimit(field string, old, new int64, format func(int64) string) *Change {
+	if old == new {
+		return nil
+	}
+
+	effect := Loosened
+	if old == 0 || (new != 0 && new < old) {
+		effect = Tightened
+	}
+
+	show := func(v int64) string {
+		if v == 0 {
+			return "unlimited"
+		}
+		return format(v)
+	}
+	return &Change{Kind: KindConstraint, Effect: effect, Field: field, Old: show(old), New: show(new)}
+}
+
+// retryAttempts returns the executions a retry policy allows a call: one
+// without a policy.
+func retryAttempts(r *policy.RetryPolicy) int64 {
+	if r == nil {
+		return 1
+	}
+	return int64(r.MaxAttempts)
+}
+
+// compareRequiredLabels diffs required agent labels; more requirements
+// means fewer agents can use the tool.
+func compareRequiredLabels(old, new map[string]string) *Change {
+	oldPairs := labelPairs(old)
+	newPairs := labelPairs(new)
+
+	effect, changed := setEffect(oldPairs, newPairs)
+	if !changed {
+		return nil
+	}
+
+	// setEffect treats a shrinking set as tightening; for requirements
+	// a growing set is the tightening direction
+	switch effect {
+	case Tightened:
+		effect = Loosened
+	case Loosened:
+		effect = Tightened
+	}
+
+	return &Change{Kind: KindConstraint, Effect: effect, Field: "requiredAgentLabels", Old: displayList(oldPairs), New: displayList(newPairs)}
+}
+
+// compareProfile diffs behavior profile enforcement. Only the deny action
+// restricts access; a higher minimum sample count delays it.
+func compareProfile(old, new *policy.CompiledPolicy) *Change {
+	if old.ProfileAction == new.ProfileAction && old.ProfileMinSamples == new.ProfileMinSamples {
+		return nil
+	}
+
+	oldDeny, newDeny := old.ProfileAction == policy.ProfileDeny, new.ProfileAction == policy.ProfileDeny
+	effect := Modified
+	switch {
+	case newDeny && !oldDeny:
+		effect = Tightened
+	case oldDeny && !newDeny:
+		effect = Loosened
+	case newDeny && new.ProfileMinSamples < old.ProfileMinSamples:
+		effect = Tightened
+	case newDeny && new.ProfileMinSamples > old.ProfileMinSamples:
+		effect = Loosened
+	}
+	return &Change{Kind: KindProfile, Effect: effect, Old: profileString(old), New: profileString(new)}
+}
+
+func profileString(p *policy.CompiledPolicy) string {
+	if p.ProfileAction == policy.ProfileOff {
+		return p.ProfileAction.String()
+	}
+	return fmt.Sprintf("%s (min %d samples)", p.ProfileAction, p.ProfileMinSamples)
+}
+
+// compareRateLimit diffs rate limit overrides. Without an override the
+// router's default applies, which the policy cannot see, so adding or
+// removing one is only a modification.
+func compareRateLimit(old, new *policy.RateLimit) *Change {
+	if old == nil && new == nil || old != nil && new != nil && *old == *new {
+		return nil
+	}
+
+	effect := Modified
+	if old != nil && new != nil {
+		rate, burst := new.RequestsPerSecond-old.RequestsPerSecond, new.Burst-old.Burst
+		switch {
+		case rate <= 0 && burst <= 0:
+			effect = Tightened
+		case rate >= 0 && burst >= 0:
+			effect = Loosened
+		}
+	}
+	return &Change{Kind: KindRateLimit, Effect: effect, Old: rateLimitString(old), New: rateLimitString(new)}
+}
+
+func rateLimitString(rl *policy.RateLimit) string {
+	if rl == nil {
+		return "(router default)"
+	}
+	return fmt.Sprintf("%g/s (burst %d)", rl.RequestsPerSecond, rl.Burst)
+}
+
+func cacheTTLString(ttl time.Duration) string {
+	if ttl == 0 {
+		return "(router default)"
+	}
+	return ttl.String()
+}
+
+// compareBudget diffs spend budgets. Each threshold is a limit where 0
+// means none, and spend that starts over less often allows less.
+func compareBudget(old, new *policy.Budget) *Change {
+	oldStr, newStr := budgetString(old), budgetString(new)
+	if oldStr == newStr {
+		return nil
+	}
+	// The period of an added or removed budget does not matter
+	ignorePeriod := old == nil || new == nil
+	if old == nil {
+		old = &policy.Budget{}
+	}
+	if new == nil {
+		new = &policy.Budget{}
+	}
+
+	effects := make(map[Effect]bool)
+	limit := func(old, new int64) {
+		if c := compareLimit("", old, new, func(int64) string { return "" }); c != nil {
+			effects[c.Effect] = true
+		}
+	}
+	for _, pair := range [][2]*policy.BudgetLimit{{old.Session, new.Session}, {old.Tenant, new.Tenant}} {
+		o, n := pair[0], pair[1]
+		if o == nil {
+			o = &policy.BudgetLimit{}
+		}
+		if n == nil {
+			n = &policy.BudgetLimit{}
+		}
+		limit(o.Limit, n.Limit)
+		limit(o.ApprovalThreshold, n.ApprovalThreshold)
+	}
+	if old.Period != new.Period && !ignorePeriod {
+		// A period of 0 never starts over, the tightest of all
+		effects[invert(compareLimit("", int64(old.Period), int64(new.Period), func(int64) string { return "" }).Effect)] = true
+	}
+
+	effect := Modified
+	if len(effects) == 1 {
+		for e := range effects {
+			effect = e
+		}
+	}
+	return &Change{Kind: KindBudget, Effect: effect, Old: oldStr, New: newStr}
+}
+
+func invert(e Effect) Effect {
+	switch e {
+	case Tightened:
+		return Loosened
+	case Loosened:
+		return Tightened
+	}
+	return e
+}
+
+func budgetString(b *policy.Budget) string {
+	if b == nil {
+		return "<none>"
+	}
+	var parts []string
+	for _, scope := range []struct {
+		name  string
+		limit *policy.BudgetLimit
+	}{{"session", b.Session}, {"tenant", b.Tenant}} {
+		if l := scope.limit; l != nil {
+			parts = append(parts, fmt.Sprintf("%s limit %d approval %d", scope.name, l.Limit, l.ApprovalThreshold))
+		}
+	}
+	if b.Period > 0 {
+		parts = append(parts, "per "+b.Period.String())
+	}
+	if len(parts) == 0 {
+		return "<none>"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// compareSelectors diffs agent selectors. A nil selector matches all agents,
+// so adding one narrows the set of agents the policy governs.
+func compareSelectors(old, new *policy.LabelSelector) *Change {
+	oldStr := selectorString(old)
+	newStr := selectorString(new)
+	if oldStr == newStr {
+		return nil
+	}
+	return &Change{Kind: KindAgentSelector, Effect: Modified, Old: oldStr, New: newStr}
+}
+
+// setEffect compares two sets of allowed values: a subset is tightened,
+// a superset is loosened, and anything else is modified.
+func setEffect(old, new []string) (Effect, bool) {
+	oldSet := toSet(old)
+	newSet := toSet(new)
+
+	removed := false
+	for v := range oldSet {
+		if !newSet[v] {
+			removed = true
+			break
+		}
+	}
+	added := false
+	for v := range newSet {
+		if !oldSet[v] {
+			added = true
+			break
+		}
+	}
+
+	switch {
+	case !added && !removed:
+		return "", false
+	case removed && !added:
+		return Tightened, true
+	case added && !removed:
+		return Loosened, true
+	default:
+		return Modified, true
+	}
+}
+
+// actionEffect classifies a transition between two actions.
+func actionEffect(from, to policy.Decision) Effect {
+	switch {
+	case from == to:
+		return Modified
+	case to == policy.Allow:
+		return Loosened
+	default:
+		return Tightened
+	}
+}
+
+// toolNames returns the union of tool names in both policies, sorted.
+func toolNames(old, new *policy.CompiledPolicy) []string {
+	seen := make(map[string]bool, len(old.ToolTable)+len(new.ToolTable))
+	for tool := range old.ToolTable {
+		seen[tool] = true
+	}
+	for tool := range new.ToolTable {
+		seen[tool] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for tool := range seen {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// kindSubject names the policy attribute a change kind refers to.
+func kindSubject(k Kind) string {
+	switch k {
+	case KindDefaultAction:
+		return "default action"
+	case KindMode:
+		return "mode"
+	case KindMTSLabel:
+		return "MTS label"
+	case KindProfile:
+		return "profile enforcement"
+	case KindAgentSelector:
+		return "agent selector"
+	case KindBudget:
+		return "budget"
+	default:
+		return string(k)
+	}
+}
+
+func selectorString(s *policy.LabelSelector) string {
+	if s == nil {
+		return "<none>"
+	}
+
+	parts := labelPairs(s.MatchLabels)
+	for _, expr := range s.MatchExpressions {
+		values := append([]string(nil), expr.Values...)
+		sort.Strings(values)
+		parts = append(parts, fmt.Sprintf("%s %s (%s)", expr.Key, expr.Operator, strings.Join(values, ",")))
+	}
+	if len(parts) == 0 {
+		return "<all>"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func labelPairs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func intsToStrings(values []int) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%d", v)
+	}
+	return out
+}
+
+func asnsToStrings(values []uint32) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%d", v)
+	}
+	return out
+}
+
+func displayList(values []string) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, ", ") + "]"
+}
+
+func displayString(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
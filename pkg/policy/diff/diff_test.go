@@ -0,0 +1,357 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+func compile(defaultAction policy.Decision, mode policy.EnforcementMode, perms ...policy.ToolPermission) *policy.CompiledPolicy {
+	return policy.CompilePolicy("test-policy", []string{"test-agent"}, defaultAction, perms, mode, "")
+}
+
+// TestCompareIdentical tests that equal policies produce no changes.
+func TestCompareIdentical(t *testing.T) {
+	perms := []policy.ToolPermission{
+		{Tool: "file.read", Action: policy.Allow, Constraints: &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}}},
+		{Tool: "shell.exec", Action: policy.Deny},
+	}
+
+	d := Compare(compile(policy.Deny, policy.Enforcing, perms...), compile(policy.Deny, policy.Enforcing, perms...))
+	if !d.Empty() {
+		t.Errorf("expected no changes, got:\n%s", d)
+	}
+	if d.Summary() != "no changes" {
+		t.Errorf("unexpected summary: %q", d.Summary())
+	}
+}
+
+// TestCompareRules tests rule additions, removals, and action changes.
+func TestCompareRules(t *testing.T) {
+	old := compile(policy.Deny, policy.Enforcing,
+		policy.ToolPermission{Tool: "file.read", Action: policy.Allow},
+		policy.ToolPermission{Tool: "file.write", Action: policy.Allow},
+		policy.ToolPermission{Tool: "network.fetch", Action: policy.Deny},
+	)
+	new := compile(policy.Deny, policy.Enforcing,
+		policy.ToolPermission{Tool: "file.read", Action: policy.Allow},
+		policy.ToolPermission{Tool: "network.fetch", Action: policy.Allow},
+		policy.ToolPermission{Tool: "code.execute", Action: policy.Allow},
+	)
+
+	d := Compare(old, new)
+
+	expected := []Change{
+		{Kind: KindRuleAdded, Effect: Loosened, Tool: "code.execute", New: "ALLOW"},
+		{Kind: KindRuleRemoved, Effect: Tightened, Tool: "file.write", Old: "ALLOW"},
+		{Kind: KindRuleAction, Effect: Loosened, Tool: "network.fetch", Old: "DENY", New: "ALLOW"},
+	}
+	if len(d.Changes) != len(expected) {
+		t.Fatalf("expected %d changes, got:\n%s", len(expected), d)
+	}
+	for i, want := range expected {
+		if d.Changes[i] != want {
+			t.Errorf("change %d: expected %+v, got %+v", i, want, d.Changes[i])
+		}
+	}
+
+	if !d.Loosens() {
+		t.Error("expected diff to loosen access")
+	}
+	if got := d.Summary(); got != "1 rule added, 1 rule removed, 1 rule action changed" {
+		t.Errorf("unexpected summary: %q", got)
+	}
+}
+
+// TestComparePolicyLevel tests default action and mode changes.
+func TestComparePolicyLevel(t *testing.T) {
+	d := Compare(compile(policy.Allow, policy.Permissive), compile(policy.Deny, policy.Enforcing))
+
+	if len(d.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got:\n%s", d)
+	}
+	if d.Changes[0].Kind != KindDefaultAction || d.Changes[0].Effect != Tightened {
+		t.Errorf("unexpected default action change: %+v", d.Changes[0])
+	}
+	if d.Changes[1].Kind != KindMode || d.Changes[1].Effect != Tightened {
+		t.Errorf("unexpected mode change: %+v", d.Changes[1])
+	}
+	if d.Loosens() {
+		t.Error("expected diff not to loosen access")
+	}
+}
+
+// TestCompareProfile tests behavior profile enforcement changes.
+func TestCompareProfile(t *testing.T) {
+	withProfile := func(action policy.ProfileAction, minSamples int64) *policy.CompiledPolicy {
+		p := compile(policy.Deny, policy.Enforcing)
+		p.ProfileAction, p.ProfileMinSamples = action, minSamples
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		old, new *policy.CompiledPolicy
+		effect   Effect
+	}{
+		{"deny enabled", withProfile(policy.ProfileOff, 0), withProfile(policy.ProfileDeny, 100), Tightened},
+		{"deny relaxed to flag", withProfile(policy.ProfileDeny, 100), withProfile(policy.ProfileFlag, 100), Loosened},
+		{"deny delayed", withProfile(policy.ProfileDeny, 100), withProfile(policy.ProfileDeny, 1000), Loosened},
+		{"flag enabled", withProfile(policy.ProfileOff, 0), withProfile(policy.ProfileFlag, 0), Modified},
+	}
+
+	for _, tt := range tests {
+		d := Compare(tt.old, tt.new)
+		if len(d.Changes) != 1 || d.Changes[0].Kind != KindProfile || d.Changes[0].Effect != tt.effect {
+			t.Errorf("%s: expected one %s profile change, got:\n%s", tt.name, tt.effect, d)
+		}
+	}
+}
+
+// TestCompareRateLimit tests rate limit override changes.
+func TestCompareRateLimit(t *testing.T) {
+	withLimit := func(rl *policy.RateLimit) *policy.CompiledPolicy {
+		p := compile(policy.Deny, policy.Enforcing)
+		p.RateLimit = rl
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		old, new *policy.RateLimit
+		effect   Effect
+	}{
+		{"lowered", &policy.RateLimit{RequestsPerSecond: 10, Burst: 20}, &policy.RateLimit{RequestsPerSecond: 5, Burst: 20}, Tightened},
+		{"raised", &policy.RateLimit{RequestsPerSecond: 10, Burst: 20}, &policy.RateLimit{RequestsPerSecond: 10, Burst: 40}, Loosened},
+		{"reshaped", &policy.RateLimit{RequestsPerSecond: 10, Burst: 20}, &policy.RateLimit{RequestsPerSecond: 20, Burst: 10}, Modified},
+		{"added", nil, &policy.RateLimit{RequestsPerSecond: 10, Burst: 10}, Modified},
+	}
+
+	for _, tt := range tests {
+		d := Compare(withLimit(tt.old), withLimit(tt.new))
+		if len(d.Changes) != 1 || d.Changes[0].Kind != KindRateLimit || d.Changes[0].Effect != tt.effect {
+			t.Errorf("%s: expected one %s rate limit change, got:\n%s", tt.name, tt.effect, d)
+		}
+	}
+	if d := Compare(withLimit(&policy.RateLimit{RequestsPerSecond: 1, Burst: 1}), withLimit(&policy.RateLimit{RequestsPerSecond: 1, Burst: 1})); len(d.Changes) != 0 {
+		t.Errorf("expected no changes, got:\n%s", d)
+	}
+}
+
+// TestCompareBudget tests spend budget changes.
+func TestCompareBudget(t *testing.T) {
+	withBudget := func(b *policy.Budget) *policy.CompiledPolicy {
+		p := compile(policy.Deny, policy.Enforcing)
+		p.Budget = b
+		return p
+	}
+	daily := func(limit int64) *policy.Budget {
+		return &policy.Budget{Tenant: &policy.BudgetLimit{Limit: limit}, Period: 24 * time.Hour}
+	}
+
+	tests := []struct {
+		name     string
+		old, new *policy.Budget
+		effect   Effect
+	}{
+		{"added", nil, daily(100), Tightened},
+		{"removed", daily(100), nil, Loosened},
+		{"lowered", daily(100), daily(50), Tightened},
+		{"raised", daily(100), daily(200), Loosened},
+		{"approval added", daily(100), &policy.Budget{Tenant: &policy.BudgetLimit{Limit: 100, ApprovalThreshold: 80}, Period: 24 * time.Hour}, Tightened},
+		{"hourly", daily(100), &policy.Budget{Tenant: &policy.BudgetLimit{Limit: 100}, Period: time.Hour}, Loosened},
+		{"never resets", daily(100), &policy.Budget{Tenant: &policy.BudgetLimit{Limit: 100}}, Tightened},
+		{"reshaped", daily(100), &policy.Budget{Tenant: &policy.BudgetLimit{Limit: 200}, Period: 7 * 24 * time.Hour}, Modified},
+	}
+
+	for _, tt := range tests {
+		d := Compare(withBudget(tt.old), withBudget(tt.new))
+		if len(d.Changes) != 1 || d.Changes[0].Kind != KindBudget || d.Changes[0].Effect != tt.effect {
+			t.Errorf("%s: expected one %s budget change, got:\n%s", tt.name, tt.effect, d)
+		}
+	}
+	if d := Compare(withBudget(daily(100)), withBudget(daily(100))); len(d.Changes) != 0 {
+		t.Errorf("expected no changes, got:\n%s", d)
+	}
+}
+
+// TestCompareConstraints tests the tightened/loosened classification of constraints.
+func TestCompareConstraints(t *testing.T) {
+	tests := []struct {
+		name   string
+		old    *policy.ToolConstraints
+		new    *policy.ToolConstraints
+		field  string
+		effect Effect
+	}{
+		{
+			name:   "path subset",
+			old:    &policy.ToolConstraints{PathPatterns: []string{"/workspace/**", "/tmp/**"}},
+			new:    &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			field:  "pathPatterns",
+			effect: Tightened,
+		},
+		{
+			name:   "path restriction removed",
+			old:    &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			new:    nil,
+			field:  "pathPatterns",
+			effect: Loosened,
+		},
+		{
+			name:   "path restriction added",
+			old:    nil,
+			new:    &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			field:  "pathPatterns",
+			effect: Tightened,
+		},
+		{
+			name:   "path replaced",
+			old:    &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			new:    &policy.ToolConstraints{PathPatterns: []string{"/data/**"}},
+			field:  "pathPatterns",
+			effect: Modified,
+		},
+		{
+			name:   "domain added",
+			old:    &policy.ToolConstraints{AllowedDomains: []string{"github.com"}},
+			new:    &policy.ToolConstraints{AllowedDomains: []string{"github.com", "pypi.org"}},
+			field:  "allowedDomains",
+			effect: Loosened,
+		},
+		{
+			name:   "denied domain added",
+			old:    &policy.ToolConstraints{DeniedDomains: []string{"evil.com"}},
+			new:    &policy.ToolConstraints{DeniedDomains: []string{"evil.com", "pastebin.com"}},
+			field:  "deniedDomains",
+			effect: Tightened,
+		},
+		{
+			name:   "port removed",
+			old:    &policy.ToolConstraints{AllowedPorts: []int{80, 443}},
+			new:    &policy.ToolConstraints{AllowedPorts: []int{443}},
+			field:  "allowedPorts",
+			effect: Tightened,
+		},
+		{
+			name:   "size raised",
+			old:    &policy.ToolConstraints{MaxSizeBytes: 1024},
+			new:    &policy.ToolConstraints{MaxSizeBytes: 4096},
+			field:  "maxSizeBytes",
+			effect: Loosened,
+		},
+		{
+			name:   "size limit added",
+			old:    &policy.ToolConstraints{},
+			new:    &policy.ToolConstraints{MaxSizeBytes: 4096},
+			field:  "maxSizeBytes",
+			effect: Tightened,
+		},
+		{
+			name:   "symlink resolution disabled",
+			old:    &policy.ToolConstraints{ResolveSymlinks: true},
+			new:    &policy.ToolConstraints{},
+			field:  "resolveSymlinks",
+			effect: Loosened,
+		},
+		{
+			name:   "custom constraint added",
+			old:    &policy.ToolConstraints{},
+			new:    &policy.ToolConstraints{Custom: map[string]json.RawMessage{"git-branch": json.RawMessage(`["main"]`)}},
+			field:  "custom.git-branch",
+			effect: Tightened,
+		},
+		{
+			name:   "custom constraint changed",
+			old:    &policy.ToolConstraints{Custom: map[string]json.RawMessage{"git-branch": json.RawMessage(`["main"]`)}},
+			new:    &policy.ToolConstraints{Custom: map[string]json.RawMessage{"git-branch": json.RawMessage(`["main","dev"]`)}},
+			field:  "custom.git-branch",
+			effect: Modified,
+		},
+		{
+			name:   "timeout lowered",
+			old:    &policy.ToolConstraints{Timeout: time.Minute},
+			new:    &policy.ToolConstraints{Timeout: 30 * time.Second},
+			field:  "timeout",
+			effect: Tightened,
+		},
+		{
+			name:   "content hash added",
+			old:    &policy.ToolConstraints{AllowedContentHashes: []string{"aa"}},
+			new:    &policy.ToolConstraints{AllowedContentHashes: []string{"aa", "bb"}},
+			field:  "allowedContentHashes",
+			effect: Loosened,
+		},
+		{
+			name:   "required label added",
+			old:    &policy.ToolConstraints{RequiredAgentLabels: map[string]string{"team": "infra"}},
+			new:    &policy.ToolConstraints{RequiredAgentLabels: map[string]string{"team": "infra", "env": "prod"}},
+			field:  "requiredAgentLabels",
+			effect: Tightened,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Compare(
+				compile(policy.Deny, policy.Enforcing, policy.ToolPermission{Tool: "tool", Action: policy.Allow, Constraints: tt.old}),
+				compile(policy.Deny, policy.Enforcing, policy.ToolPermission{Tool: "tool", Action: policy.Allow, Constraints: tt.new}),
+			)
+
+			if len(d.Changes) != 1 {
+				t.Fatalf("expected 1 change, got:\n%s", d)
+			}
+			c := d.Changes[0]
+			if c.Kind != KindConstraint || c.Field != tt.field || c.Effect != tt.effect {
+				t.Errorf("expected %s %s, got %s", tt.field, tt.effect, c)
+			}
+		})
+	}
+}
+
+// TestCompareObligations tests that added obligations tighten a rule and
+// removed ones loosen it.
+func TestCompareObligations(t *testing.T) {
+	notify := policy.Obligation{Type: policy.ObligationNotify, Params: map[string]string{"channel": "#sec"}}
+	logPayload := policy.Obligation{Type: policy.ObligationLogPayload}
+	rule := func(obligations ...policy.Obligation) *policy.CompiledPolicy {
+		return compile(policy.Deny, policy.Enforcing, policy.ToolPermission{Tool: "file.write", Action: policy.Allow, Obligations: obligations})
+	}
+
+	tests := []struct {
+		name   string
+		old    *policy.CompiledPolicy
+		new    *policy.CompiledPolicy
+		effect Effect
+	}{
+		{"added", rule(logPayload), rule(logPayload, notify), Tightened},
+		{"removed", rule(logPayload, notify), rule(), Loosened},
+		{"replaced", rule(logPayload), rule(notify), Modified},
+	}
+
+	for _, tt := range tests {
+		d := Compare(tt.old, tt.new)
+		if len(d.Changes) != 1 {
+			t.Fatalf("%s: expected 1 change, got:\n%s", tt.name, d)
+		}
+		c := d.Changes[0]
+		if c.Kind != KindConstraint || c.Field != "obligations" || c.Effect != tt.effect {
+			t.Errorf("%s: expected obligations %s, got %s", tt.name, tt.effect, c)
+		}
+	}
+
+	if d := Compare(rule(notify), rule(notify)); !d.Empty() {
+		t.Errorf("expected no changes for equal obligations, got:\n%s", d)
+	}
+}
+
+// TestCompareNil tests that a nil policy is treated as deny-all.
+func TestCompareNil(t *testing.T) {
+	d := Compare(nil, compile(policy.Deny, policy.Enforcing, policy.ToolPermission{Tool: "file.read", Action: policy.Allow}))
+
+	if len(d.Changes) != 1 || d.Changes[0].Kind != KindRuleAdded || d.Changes[0].Effect != Loosened {
+		t.Errorf("unexpected changes:\n%s", d)
+	}
+}
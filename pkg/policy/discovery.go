@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"sort"
+	"time"
+)
+
+// AllowedTools returns the tools explicitly allowed for an agent by its
+// effective policy, sorted by tool name, along with that policy.
+//
+// Tools whose RequiredAgentLabels are not satisfied by the agent are
+// omitted, since every call to them would be denied, as are tools disabled
+// by a kill switch. Parameter constraints
+// (paths, domains, sizes) are returned as-is for the caller to honor.
+// Tools not listed in the policy fall under its DefaultAction.
+//
+// Returns false if no policy applies to the agent (every call is denied).
+func (e *Engine) AllowedTools(agent AgentContext) (*CompiledPolicy, []ToolPermission, bool) {
+	policy, ok := e.ResolvePolicy(agent)
+	if !ok {
+		return nil, nil, false
+	}
+
+	now := time.Now()
+	tools := make([]ToolPermission, 0, len(policy.ToolTable))
+	for _, perm := range policy.ToolTable {
+		if perm.Action != Allow {
+			continue
+		}
+		if _, killed := e.kills.killed(perm.Tool, now); killed {
+			continue
+		}
+		if perm.Constraints != nil && !hasLabels(agent.Labels, perm.Constraints.RequiredAgentLabels) {
+			continue
+		}
+		tools = append(tools, *perm)
+	}
+
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Tool < tools[j].Tool
+	})
+	return policy, tools, true
+}
+
+// hasLabels reports whether labels contain every required key/value pair.
+func hasLabels(labels, required map[string]string) bool {
+	for k, v := range required {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
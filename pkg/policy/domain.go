@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidDomain is returned by NormalizeDomain for names that cannot be
+// converted to a canonical DNS name.
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// domainProfile maps domains the way resolvers do for lookup (UTS #46:
+// case folding, width and compatibility mapping, punycode validation),
+// without STD3 rules so that names with underscores remain usable.
+// NormalizeDomain rejects the other characters STD3 rules would.
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// NormalizeDomain converts a domain to the canonical form domain
+// constraints compare: lowercase ASCII, internationalized labels in
+// punycode ("xn--"), and no trailing dot.
+//
+// Normalization makes equivalent spellings compare equal, so
+// "API.GitHub.COM.", and fullwidth "ｇｉｔｈｕｂ.com" match "github.com".
+// Homographs stay distinct: a Cyrillic "аpple.com" becomes
+// "xn--pple-43d.com", which an allowlist entry "apple.com" never matches.
+// Names are host names: their labels may only hold letters, digits,
+// hyphens, and underscores, so URLs and "host:port" are rejected.
+func NormalizeDomain(domain string) (string, error) {
+	d := strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if strings.HasSuffix(d, ".") {
+		return "", fmt.Errorf("%w %q: empty label", ErrInvalidDomain, domain)
+	}
+	if isCanonicalDomain(d) {
+		return d, nil
+	}
+
+	ascii, err := domainProfile.ToASCII(d)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidDomain, domain, err)
+	}
+	if ascii == "" || strings.HasPrefix(ascii, ".") || strings.HasSuffix(ascii, ".") || strings.Contains(ascii, "..") {
+		return "", fmt.Errorf("%w %q: empty label", ErrInvalidDomain, domain)
+	}
+	if !isHostNameChars(ascii) {
+		return "", fmt.Errorf("%w %q: not a host name", ErrInvalidDomain, domain)
+	}
+	return ascii, nil
+}
+
+// NormalizeDomainPattern normalizes a domain constraint pattern: "*" is kept,
+// "*.example.com" keeps its wildcard and normalizes the rest, and anything
+// else is normalized as a domain.
+func NormalizeDomainPattern(pattern string) (string, error) {
+	p := strings.TrimSpace(pattern)
+	if p == "*" {
+		return p, nil
+	}
+	if strings.HasPrefix(p, "*.") {
+		d, err := NormalizeDomain(p[2:])
+		if err != nil {
+			return "", err
+		}
+		return "*." + d, nil
+	}
+	return NormalizeDomain(p)
+}
+
+// NormalizeDomainPatterns normalizes a list of domain constraint patterns
+// (see NormalizeDomainPattern). Patterns that cannot be normalized are
+// kept as written, so they only match themselves.
+func NormalizeDomainPatterns(patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(patterns))
+	for i, p := range patterns {
+		if n, err := NormalizeDomainPattern(p); err == nil {
+			normalized[i] = n
+		} else {
+			normalized[i] = p
+		}
+	}
+	return normalized
+}
+
+// isCanonicalDomain reports whether d is already lowercase ASCII with
+// non-empty labels and no punycode, which NormalizeDomain would return
+// unchanged. It keeps IDNA processing off the common path.
+func isCanonicalDomain(d string) bool {
+	if d == "" || d[0] == '.' || strings.Contains(d, "..") || strings.Contains(d, "xn--") {
+		return false
+	}
+	return isHostNameChars(d)
+}
+
+// isHostNameChars reports whether d only holds the characters of
+// lowercase host names: letters, digits, hyphens, underscores, and dots.
+func isHostNameChars(d string) bool {
+	for i := 0; i < len(d); i++ {
+		c := d[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeConstraintDomains returns c with its domain patterns normalized
+// (see NormalizeDomainPatterns), or c itself if they already are, so that
+// calls are matched against patterns normalized once.
+func normalizeConstraintDomains(c *ToolConstraints) *ToolConstraints {
+	if c == nil || (len(c.AllowedDomains) == 0 && len(c.DeniedDomains) == 0) {
+		return c
+	}
+	allowed, denied := NormalizeDomainPatterns(c.AllowedDomains), NormalizeDomainPatterns(c.DeniedDomains)
+	if slices.Equal(allowed, c.AllowedDomains) && slices.Equal(denied, c.DeniedDomains) {
+		return c
+	}
+	normalized := *c
+	normalized.AllowedDomains, normalized.DeniedDomains = allowed, denied
+	return &normalized
+}
+
+// domainMatches reports whether a normalized domain matches any of the
+// normalized patterns.
+func domainMatches(patterns []string, domain string) bool {
+	for _, p := range patterns {
+		if matchDomain(p, domain) {
+			return true
+		}
+	}
+	return false
+}
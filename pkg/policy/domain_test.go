@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestNormalizeDomain verifies case, trailing-dot, and IDNA normalization
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		domain   string
+		expected string
+		invalid  bool
+	}{
+		{domain: "github.com", expected: "github.com"},
+		{domain: "API.GitHub.COM", expected: "api.github.com"},
+		{domain: "github.com.", expected: "github.com"},
+		{domain: " github.com ", expected: "github.com"},
+		{domain: "ｇｉｔｈｕｂ.com", expected: "github.com"},
+		{domain: "github。com", expected: "github.com"},
+		{domain: "bücher.example", expected: "xn--bcher-kva.example"},
+		{domain: "XN--BCHER-KVA.example", expected: "xn--bcher-kva.example"},
+		{domain: "_acme-challenge.example.com", expected: "_acme-challenge.example.com"},
+		{domain: "10.0.0.1", expected: "10.0.0.1"},
+		{domain: "", invalid: true},
+		{domain: ".", invalid: true},
+		{domain: "github..com", invalid: true},
+		{domain: ".github.com", invalid: true},
+		{domain: "xn--zz.com", invalid: true},
+		{domain: "github.com..", invalid: true},
+		{domain: "github.com/evil", invalid: true},
+		{domain: "github.com:443", invalid: true},
+		{domain: "github.com?x=1", invalid: true},
+		{domain: "evil.com#github.com", invalid: true},
+		{domain: "user@github.com", invalid: true},
+		{domain: "git hub.com", invalid: true},
+		{domain: "Bücher.example/x", invalid: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeDomain(tt.domain)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidDomain) {
+				t.Errorf("%q: expected ErrInvalidDomain, got %q, %v", tt.domain, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: expected %q, got %q, %v", tt.domain, tt.expected, got, err)
+		}
+	}
+
+	for pattern, expected := range map[string]string{
+		"*":                "*",
+		"*.GitHub.io.":     "*.github.io",
+		"*.bücher.example": "*.xn--bcher-kva.example",
+	} {
+		if got, err := NormalizeDomainPattern(pattern); err != nil || got != expected {
+			t.Errorf("pattern %q: expected %q, got %q, %v", pattern, expected, got, err)
+		}
+	}
+}
+
+// TestEngineDomainHomographs verifies that equivalent spellings of a domain
+// are matched and lookalike domains are not
+func TestEngineDomainHomographs(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("research-agent", CompilePolicy(
+		"test-policy",
+		[]string{"research-agent"},
+		Deny,
+		[]ToolPermission{
+			{
+				Tool:   "network.fetch",
+				Action: Allow,
+				Constraints: &ToolConstraints{
+					AllowedDomains: []string{"API.GitHub.com", "*.example.org", "apple.com"},
+					DeniedDomains:  []string{"evil.example.org", "bücher.example.org"},
+				},
+			},
+		},
+		Enforcing,
+		"",
+	))
+
+	// Patterns are normalized as the policy is compiled
+	compiled, _ := engine.GetPolicy("research-agent")
+	if got := compiled.ToolTable["network.fetch"].Constraints.AllowedDomains; got[0] != "api.github.com" {
+		t.Errorf("expected compiled patterns to be normalized, got %v", got)
+	}
+
+	agent := AgentContext{AgentType: "research-agent"}
+
+	tests := []struct {
+		name     string
+		domain   string
+		expected Decision
+	}{
+		{"exact", "api.github.com", Allow},
+		{"mixed case", "API.GITHUB.COM", Allow},
+		{"trailing dot", "api.github.com.", Allow},
+		{"fullwidth", "ａｐｉ.github.com", Allow},
+		{"wildcard mixed case", "Docs.Example.ORG", Allow},
+		{"denied mixed case", "EVIL.example.org", Deny},
+		{"denied trailing dot", "evil.example.org.", Deny},
+		{"denied fullwidth", "ｅｖｉｌ.example.org", Deny},
+		{"denied unicode as punycode", "xn--bcher-kva.example.org", Deny},
+		{"denied punycode as unicode", "BÜCHER.example.org", Deny},
+		{"cyrillic a homograph", "аpple.com", Deny},
+		{"cyrillic homograph as punycode", "xn--pple-43d.com", Deny},
+		{"greek omicron homograph", "api.github.cοm", Deny},
+		{"invalid punycode", "xn--zz.github.com", Deny},
+		{"empty label", "api..github.com", Deny},
+		{"url", "api.github.com/evil", Deny},
+		{"port", "api.github.com:8443", Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.cache.InvalidateAll()
+
+			request := &ToolRequest{Network: &NetworkParams{Domain: tt.domain}}
+			decision, _ := engine.Evaluate(context.Background(), agent, "network.fetch", request)
+			if decision != tt.expected {
+				t.Errorf("domain %q: expected %v, got %v", tt.domain, tt.expected, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// Enforcer is the interface of the policy engine for programs that embed
+// it. It is implemented by *Engine; depend on it rather than on the
+// engine's full method set, which grows with the router's needs.
+//
+// Embedding needs only this package and, to load AgentPolicy manifests,
+// package compile; neither depends on client-go or controller-runtime.
+//
+//	var enforcer policy.Enforcer = policy.NewEngine(policy.WithMode(policy.Enforcing))
+//	enforcer.LoadPolicy("coding-assistant", compiled)
+//	decision, err := enforcer.Evaluate(ctx, agent, "file.read", request)
+type Enforcer interface {
+	// Evaluate decides whether agent may call toolName with request.
+	Evaluate(ctx context.Context, agent AgentContext, toolName string, request interface{}) (Decision, error)
+
+	// LoadPolicy binds a policy to an agent type, pattern, or
+	// FallbackAgentType, replacing any policy bound to it.
+	LoadPolicy(agentType string, policy *CompiledPolicy)
+
+	// RemovePolicy unbinds the policy of an agent type.
+	RemovePolicy(agentType string)
+
+	// Explain reports how a call would be decided, without deciding it.
+	Explain(ctx context.Context, agent AgentContext, toolName string, request interface{}) (*Explanation, error)
+}
+
+var _ Enforcer = (*Engine)(nil)
+
+// Explanation describes how the engine decides a call.
+type Explanation struct {
+	// EvaluationResult is the result Evaluate would return. Cached is
+	// always false: Explain evaluates the call afresh.
+	EvaluationResult
+
+	// PolicyDecision is the policy's decision, before the enforcement
+	// mode is applied
+	PolicyDecision Decision
+
+	// Mode is the enforcement mode of the agent's calls (see
+	// Engine.EffectiveMode)
+	Mode EnforcementMode
+
+	// Rule is the policy's rule for the tool, or nil if the policy has
+	// none and its default action applies
+	Rule *ToolPermission
+
+	// OPA is true if the policy was evaluated with its OPA query
+	OPA bool
+}
+
+// Explain evaluates a call like EvaluateWithResult, and reports the
+// policy, rule, and raw decision behind the result. It neither reads nor
+// fills the decision cache, charges no budget, and emits no audit event,
+// so it can be used to answer "why was this denied?" without affecting
+// enforcement.
+func (e *Engine) Explain(ctx context.Context, agent AgentContext, toolName string, request interface{}) (*Explanation, error) {
+	return e.explain(ctx, agent, toolName, request, e.spend, false)
+}
+
+// explain implements Explain, checking budgets against the spend of
+// spend, and charging it if charge is set.
+func (e *Engine) explain(ctx context.Context, agent AgentContext, toolName string, request interface{}, spend SpendTracker, charge bool) (*Explanation, error) {
+	if e.evalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.evalTimeout)
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+
+	policy, others, exists := e.resolveCombined(agent, toolName)
+	if exists {
+		policy, _, _ = e.selectCanary(policy, agent)
+	}
+	if !exists {
+		result := e.result(nil, agent, toolName, request, nil, nil, Deny, "no policy defined for agent type", policyerrors.ErrNoPolicy, false)
+		return &Explanation{EvaluationResult: *result, PolicyDecision: Deny, Mode: e.EffectiveMode(agent)}, nil
+	}
+
+	request, mutations := mutateRequest(policy, toolName, request)
+
+	explanation := &Explanation{Mode: e.EffectiveMode(agent), OPA: e.shouldUseOPA(policy)}
+	if perm, ok := policy.ToolTable[toolName]; ok {
+		rule := *perm
+		explanation.Rule = &rule
+	}
+
+	decision, reason, obligations, denyErr := e.decide(ctx, policy, agent, toolName, request, false)
+	if decision == Allow && len(others) > 0 && ctx.Err() == nil {
+		if denier, overReason, overErr, ok := e.overrideDeny(ctx, others, agent, toolName, request); ok {
+			policy = denier
+			decision, reason, denyErr, obligations = Deny, overReason, overErr, nil
+			explanation.OPA, explanation.Rule = e.shouldUseOPA(denier), nil
+			if perm, ok := denier.ToolTable[toolName]; ok {
+				rule := *perm
+				explanation.Rule = &rule
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+	decision, reason = e.checkProfile(policy, agent, toolName, request, decision, reason)
+	decision, reason, denyErr, obligations = e.checkBudget(spend, policy, agent, toolName, decision, reason, denyErr, obligations, charge)
+
+	explanation.EvaluationResult = *e.result(policy, agent, toolName, request, mutations, obligations, decision, reason, denyErr, false)
+	explanation.PolicyDecision = decision
+	explanation.Trace = traceOf(ctx)
+	return explanation, nil
+}
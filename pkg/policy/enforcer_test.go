@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestEngineExplain verifies Explain reports the rule and raw decision
+// behind a result, without caching or auditing it
+func TestEngineExplain(t *testing.T) {
+	var events []*AuditEvent
+	var enforcer Enforcer = NewEngine(WithMode(Permissive), WithAuditSink(&testAuditSink{events: &events}))
+	enforcer.LoadPolicy("coding-assistant", CompilePolicy("coding-policy", []string{"coding-assistant"}, Allow,
+		[]ToolPermission{{Tool: "shell.exec", Action: Deny}}, Permissive, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	explanation, err := enforcer.Explain(context.Background(), agent, "shell.exec", nil)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explanation.Decision != Allow || explanation.PolicyDecision != Deny || explanation.Mode != Permissive {
+		t.Errorf("expected a denial allowed by permissive mode, got %+v", explanation)
+	}
+	if explanation.Rule == nil || explanation.Rule.Tool != "shell.exec" || explanation.Policy != "coding-policy" {
+		t.Errorf("expected the shell.exec rule of coding-policy, got %+v", explanation)
+	}
+
+	explanation, err = enforcer.Explain(context.Background(), agent, "file.read", nil)
+	if err != nil || explanation.Rule != nil || explanation.Reason != "allowed by default policy" {
+		t.Errorf("expected the default action without a rule, got %+v (%v)", explanation, err)
+	}
+
+	explanation, err = enforcer.Explain(context.Background(), AgentContext{AgentType: "unknown"}, "file.read", nil)
+	if err != nil || !errors.Is(explanation.Err, policyerrors.ErrNoPolicy) {
+		t.Errorf("expected ErrNoPolicy for an agent type without policy, got %+v (%v)", explanation, err)
+	}
+
+	engine := enforcer.(*Engine)
+	if hits, misses, _ := engine.CacheStats(); hits+misses != 0 || len(events) != 0 {
+		t.Errorf("expected Explain to bypass the cache and audit, got %d lookups and %d events", hits+misses, len(events))
+	}
+}
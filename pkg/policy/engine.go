@@ -2,10 +2,16 @@ package policy
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
-	"sync"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+	"github.com/golden-agent/golden-agent/pkg/redact"
 )
 
 // Engine evaluates tool requests against compiled policies.
@@ -22,29 +28,138 @@ import (
 //	engine.LoadPolicy("coding-assistant", compiledPolicy)
 //	decision, err := engine.Evaluate(ctx, agentCtx, "file.read", request)
 type Engine struct {
-	mu       sync.RWMutex
-	policies map[string]*CompiledPolicy // agentType -> policy
+	resolver *PolicyResolver // agentType/pattern -> policy
 	cache    *DecisionCache
 	audit    AuditSink
-	mode     EnforcementMode
+
+	// mode is the EnforcementMode, which SetMode changes while calls are
+	// evaluated
+	mode atomic.Int32
+
+	// tenantModes override mode for the calls of their tenants
+	tenantModes *tenantModeStore
 
 	// OPA integration (Phase 2)
 	useOPA  bool          // Feature flag for OPA evaluation
 	opaEval *OPAEvaluator // OPA evaluator instance (nil if not using OPA)
+
+	// notifier signals subscribers when policies or the mode change, and
+	// hooks are called with the agent type whose decisions changed
+	notifier policyNotifier
+	hooks    changeHooks
+
+	// auditParams attaches request parameters to audit events, redacted
+	// by auditRedactor if set
+	auditParams   bool
+	auditRedactor *redact.Redactor
+
+	// profiles are the learned behavior baselines by agent type
+	profiles *profileStore
+
+	// sandboxes are the authoritative sandbox contexts by sandbox ID
+	sandboxes *sandboxStore
+
+	// tenants resolves the MTS labels of calls by tenant (optional)
+	tenants *TenantRegistry
+
+	// catalog rates the risk of tools (optional), and riskRules act on
+	// calls by risk level
+	catalog   *ToolCatalog
+	riskRules []RiskRule
+
+	// kills are the kill switches disabling tools for every agent
+	kills *killSwitchStore
+
+	// bus broadcasts cache invalidations to other replicas (optional);
+	// origin identifies this engine's own invalidations on it
+	bus    InvalidationBus
+	origin string
+
+	// evalTimeout bounds each evaluation (0 means no engine bound)
+	evalTimeout time.Duration
+
+	// guard degrades evaluation while its latency is past the SLO
+	// (optional)
+	guard *latencyGuard
+
+	// external is consulted after local evaluation (optional)
+	external *externalAuthz
+
+	// conditions is what the conditions of tool rules are checked against
+	conditions conditionContext
+
+	// spend tracks the spend of policy budgets
+	spend SpendTracker
+
+	// canaries are the canary rollouts of new policy versions
+	canaries *canaryStore
+
+	// opaStats aggregates OPA's metrics of queries (nil if not
+	// collected)
+	opaStats *opaStatsStore
+
+	// wouldDeny counts the denials Permissive mode allowed, across tenant
+	// partitions
+	wouldDeny *atomic.Uint64
+
+	// combining is how the policies that apply to an agent are combined
+	combining CombiningAlgorithm
+
+	// tools normalizes the tool names callers evaluate
+	tools *ToolNormalizer
+
+	// log receives decisions and evaluation failures
+	log *slog.Logger
+
+	// opaMemoTTL is how long OPA results of uncacheable calls are
+	// memoized by input (0 means not memoized)
+	opaMemoTTL time.Duration
+
+	// partialEval specializes OPA queries per agent type at load time;
+	// specialized holds the specialized queries
+	partialEval bool
+	specialized specializedQueries
+
+	// faults injects failures for failure-mode tests (nil in production)
+	faults *FaultInjector
 }
 
+// FallbackAgentType is the wildcard key under which the cluster fallback
+// policy is loaded. It applies to any agent type that has no specific policy,
+// giving new agent types a safe baseline instead of blanket denial.
+const FallbackAgentType = "*"
+
 // AuditSink is the interface for audit event consumers
 type AuditSink interface {
 	Log(event *AuditEvent)
 }
 
+// AuditFlusher is implemented by audit sinks that buffer events. Flush
+// blocks until the events logged so far are delivered, e.g. before the
+// router shuts down.
+type AuditFlusher interface {
+	Flush() error
+}
+
+// ErrEvaluationCancelled is returned by Evaluate when its context is done,
+// or its evaluation timeout passes, before a decision is reached. The
+// error also wraps the context's error, so errors.Is(err,
+// context.DeadlineExceeded) tells a timeout from a cancellation. No
+// decision is cached or audited; callers must fail closed.
+var ErrEvaluationCancelled = errors.New("policy evaluation cancelled")
+
+// evaluationCancelled returns the error of an evaluation whose context is done.
+func evaluationCancelled(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrEvaluationCancelled, ctx.Err())
+}
+
 // Option configures the Engine
 type Option func(*Engine)
 
 // WithMode sets the enforcement mode
 func WithMode(mode EnforcementMode) Option {
 	return func(e *Engine) {
-		e.mode = mode
+		e.mode.Store(int32(mode))
 	}
 }
 
@@ -62,6 +177,36 @@ func WithAuditSink(sink AuditSink) Option {
 	}
 }
 
+// WithAuditParameters attaches the request parameters to audit events, so
+// that audit logs can be used to learn behavior profiles (see
+// ProfileLearner). Parameters may contain sensitive data; only enable it
+// for sinks that may store them.
+func WithAuditParameters(enabled bool) Option {
+	return func(e *Engine) {
+		e.auditParams = enabled
+	}
+}
+
+// WithAuditRedactor redacts PII and secrets from the request parameters
+// attached to audit events WithAuditParameters. Profiles learned from the
+// audit log see the redacted values.
+func WithAuditRedactor(r *redact.Redactor) Option {
+	return func(e *Engine) {
+		e.auditRedactor = r
+	}
+}
+
+// WithEvaluationTimeout bounds the time of each evaluation, in addition to
+// any deadline of the caller's context. The bound covers OPA queries and
+// constraint checkers; evaluations that exceed it fail with
+// ErrEvaluationCancelled. Zero (the default) leaves evaluations bounded
+// only by the caller's context.
+func WithEvaluationTimeout(timeout time.Duration) Option {
+	return func(e *Engine) {
+		e.evalTimeout = timeout
+	}
+}
+
 // WithOPA enables OPA-based policy evaluation.
 // When enabled, policies with OPAEnabled=true and a PreparedQuery
 // will be evaluated using OPA instead of the legacy ToolTable engine.
@@ -73,7 +218,7 @@ func WithOPA(enabled bool) Option {
 	return func(e *Engine) {
 		e.useOPA = enabled
 		if enabled {
-			e.opaEval = NewOPAEvaluator(e.cache, e.audit, e.mode)
+			e.opaEval = NewOPAEvaluator(e.cache, e.audit, e.Mode())
 		}
 	}
 }
@@ -82,13 +227,32 @@ func WithOPA(enabled bool) Option {
 // Default: Permissive mode, 60-second cache TTL
 func NewEngine(opts ...Option) *Engine {
 	e := &Engine{
-		policies: make(map[string]*CompiledPolicy),
-		cache:    NewDecisionCache(60 * time.Second),
-		mode:     Permissive, // Safe default - log only
+		resolver:    NewPolicyResolver(),
+		cache:       NewDecisionCache(60 * time.Second),
+		tenantModes: &tenantModeStore{},
+		profiles:    newProfileStore(),
+		sandboxes:   newSandboxStore(),
+		kills:       &killSwitchStore{},
+		spend:       NewMemorySpendTracker(),
+		canaries:    &canaryStore{},
+		wouldDeny:   new(atomic.Uint64),
+		combining:   FirstApplicable,
+		tools:       NewToolNormalizer(ToolNamesConvert),
+		log:         slog.Default(),
 	}
+	e.mode.Store(int32(Permissive)) // Safe default - log only
 	for _, opt := range opts {
 		opt(e)
 	}
+	if e.opaEval != nil {
+		e.opaEval.log = e.log
+		if e.opaMemoTTL > 0 {
+			e.opaEval.memo = newOPAMemo(e.opaMemoTTL)
+		}
+	}
+	if e.bus != nil {
+		e.subscribeInvalidations()
+	}
 	return e
 }
 
@@ -105,249 +269,630 @@ func NewEngine(opts ...Option) *Engine {
 //   - Deny: agent must not call tool (in Enforcing mode)
 //
 // In Permissive mode, Deny decisions are logged but Allow is returned.
+//
+// Evaluation honors ctx: if it is cancelled or its deadline passes before
+// a decision is reached, including while OPA or a constraint checker runs,
+// Evaluate returns Deny and an error wrapping ErrEvaluationCancelled.
 func (e *Engine) Evaluate(ctx context.Context, agent AgentContext, toolName string, request interface{}) (Decision, error) {
-	requestID := generateRequestID()
+	result, err := e.EvaluateWithResult(ctx, agent, toolName, request)
+	if err != nil {
+		return Deny, err
+	}
+	return result.Decision, nil
+}
+
+// EvaluateWithResult evaluates a tool request like Evaluate and also returns
+// the audit reason, the deciding policy, the parameters rewritten by the
+// tool rule's mutators, the obligations the caller must fulfil for allowed
+// requests, and, for denied requests, the user-facing message of the
+// matching tool rule rendered for agent.Locale.
+//
+// Messages and mutations are computed per request and are never cached,
+// since they depend on request parameters.
+//
+// Like Evaluate, it returns an error wrapping ErrEvaluationCancelled if ctx
+// is done before a decision is reached; a decision reached after ctx is
+// done is discarded rather than cached.
+//
+// The decision is audited under the request ID of ctx (see
+// ContextWithRequestID), or a generated one.
+func (e *Engine) EvaluateWithResult(ctx context.Context, agent AgentContext, toolName string, request interface{}) (*EvaluationResult, error) {
+	if e.evalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.evalTimeout)
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+
+	requestID, ok := RequestIDFromContext(ctx)
+	if !ok {
+		requestID = generateRequestID()
+	}
+	start := time.Now()
+	result, err := e.evaluate(ctx, agent, toolName, request, requestID)
+	e.observeLatency(time.Since(start))
+	if err == nil {
+		e.logDecision(ctx, agent, toolName, requestID, result)
+	}
+	return result, err
+}
 
-	// 1. Check cache first (microsecond path)
-	cacheKey := CacheKey(agent.AgentType, toolName)
-	if decision, reason, ok := e.cache.Get(cacheKey); ok {
-		e.emitAudit(agent, toolName, decision, reason, requestID, true)
-		return e.applyMode(decision), nil
+// evaluate reaches the decision of EvaluateWithResult and audits it under
+// requestID.
+func (e *Engine) evaluate(ctx context.Context, agent AgentContext, toolName string, request interface{}, requestID string) (result *EvaluationResult, err error) {
+	// 1. Resolve the deciding policy (by default the most specific: exact,
+	// pattern, then fallback) and, under DenyOverrides, the others that
+	// apply. This precedes the cache so that cached allows are mutated too.
+	policy, others, exists := e.resolveCombined(agent, toolName)
+	risk := e.RiskLevel(policy, toolName)
+
+	// The claim of the agent's sandbox fills in what its metadata omits,
+	// and denies the call if its metadata contradicts the claim
+	agent, identityErr := e.checkSandbox(agent, policy)
+	if identityErr == nil {
+		// The tenant's label, not the claimed one, decides MTS checks
+		agent, identityErr = e.resolveLabel(ctx, agent, toolName, requestID)
+	}
+	if identityErr != nil {
+		reason := identityErr.Error()
+		e.emitAudit(ctx, agent, toolName, request, risk, Deny, reason, requestID, false)
+		return e.result(nil, agent, toolName, request, nil, nil, Deny, reason, identityErr, false), nil
 	}
 
-	// 2. Look up policy for this agent type
-	e.mu.RLock()
-	policy, exists := e.policies[agent.AgentType]
-	e.mu.RUnlock()
+	// A kill switch overrides every policy and the mode, and precedes the
+	// cache so that no decision cached before the switch allows the call
+	if reason, killErr := e.checkKillSwitch(toolName); killErr != nil {
+		e.emitAuditEnforced(ctx, agent, toolName, request, risk, Deny, Deny, reason, requestID, false)
+		return killedResult(policy, reason, killErr), nil
+	}
+
+	// Agents in a canary rollout are on the new version of the policy
+	var rollout *canaryRollout
+	var canary bool
+	if exists {
+		policy, rollout, canary = e.selectCanary(policy, agent)
+		risk = e.RiskLevel(policy, toolName)
+	}
+	if rollout != nil && querySource(ctx) == "" {
+		defer func() {
+			if result != nil {
+				rollout.record(canary, result.Decision)
+			}
+		}()
+	}
+
+	// 2. Rewrite parameters before anything checks them, so constraints
+	// see exactly what the tool will execute
+	var mutations []string
+	if exists {
+		request, mutations = mutateRequest(policy, toolName, request)
+	}
+
+	// 3. Check cache (microsecond path). Rules with parameter constraints,
+	// and behavior profiles, decide on parameters the cache key does not
+	// cover, so they bypass it, as do traced calls, so that their queries
+	// run.
+	cacheable := !exists || (!checksParameters(policy, toolName) && policy.ProfileAction == ProfileOff && cacheableAll(others, toolName))
+	cacheable = cacheable && !tracing(ctx)
+	cacheKey := agentCacheKey(agent, toolName) + contentHashKey(request)
+	if canary {
+		cacheKey += "~canary"
+	}
+	// A failed cache is bypassed: the call is evaluated afresh
+	cacheUp := e.faults.Inject(ctx, FaultCache) == nil
+	if !cacheUp && ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+	if cacheUp {
+		if entry, ok := e.cache.lookup(cacheKey); ok && cacheable {
+			// Obligations depend only on the tool rule, so a cached
+			// decision carries the rule's obligations
+			var obligations []Obligation
+			if exists {
+				obligations = ruleObligations(policy, toolName)
+			}
+			decision, reason, denyErr, consulted := e.authorizeExternal(ctx, policy, agent, toolName, request, requestID, entry.decision, entry.reason, entry.err)
+			if consulted && ctx.Err() != nil {
+				return nil, evaluationCancelled(ctx)
+			}
+			decision, reason, denyErr, obligations = e.checkBudget(e.spend, policy, agent, toolName, decision, reason, denyErr, obligations, charging(ctx))
+			obligations = e.riskObligations(risk, decision, obligations)
+			e.emitAudit(ctx, agent, toolName, request, risk, decision, reason, requestID, !consulted)
+			return e.result(policy, agent, toolName, request, mutations, obligations, decision, reason, denyErr, !consulted), nil
+		}
+	}
 
 	if !exists {
 		// No policy defined for this agent type
 		decision := Deny
 		reason := "no policy defined for agent type"
-		e.cache.Set(cacheKey, decision, reason)
-		e.emitAudit(agent, toolName, decision, reason, requestID, false)
-		return e.applyMode(decision), nil
+		if cacheUp {
+			e.cache.store(cacheKey, decision, reason, policyerrors.ErrNoPolicy)
+		}
+		decision, reason, denyErr, consulted := e.authorizeExternal(ctx, nil, agent, toolName, request, requestID, decision, reason, policyerrors.ErrNoPolicy)
+		if consulted && ctx.Err() != nil {
+			return nil, evaluationCancelled(ctx)
+		}
+		e.emitAudit(ctx, agent, toolName, request, risk, decision, reason, requestID, false)
+		return e.result(nil, agent, toolName, request, nil, nil, decision, reason, denyErr, false), nil
 	}
 
-	// 3. Evaluate using OPA or legacy engine
-	var decision Decision
-	var reason string
+	// While degraded to cached decisions, deny the calls the cache missed
+	if reason, degradedErr := e.checkCachedOnly(); degradedErr != nil {
+		e.emitAudit(ctx, agent, toolName, request, risk, Deny, reason, requestID, false)
+		return e.result(policy, agent, toolName, request, nil, nil, Deny, reason, degradedErr, false), nil
+	}
 
-	if e.shouldUseOPA(policy) {
-		// OPA evaluation path (~100-500μs)
-		decision, reason = e.evaluateOPA(ctx, policy, agent, toolName, request)
-	} else {
-		// Legacy evaluation path (~10-100μs)
-		decision, reason = e.evaluatePolicy(policy, toolName, request)
+	// 4. Evaluate using OPA or legacy engine. Calls the cache cannot hold
+	// may reuse the OPA query result of an identical input.
+	evalCtx, opaMetrics := e.withOPAMetrics(ctx)
+	decision, reason, obligations, denyErr := e.decide(evalCtx, policy, agent, toolName, request, !cacheable)
+
+	// Under DenyOverrides, any other applicable policy may deny the call
+	deciding := policy
+	if decision == Allow && len(others) > 0 && ctx.Err() == nil {
+		if denier, overReason, overErr, ok := e.overrideDeny(evalCtx, others, agent, toolName, request); ok {
+			deciding = denier
+			decision, reason, denyErr, obligations = Deny, overReason, overErr, nil
+		}
+	}
+
+	// A query or checker cut short by ctx fails closed with an error that
+	// would be mistaken for the policy's decision; discard it
+	if ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+
+	// Judge allowed calls against the agent type's learned behavior
+	decision, reason = e.checkProfile(policy, agent, toolName, request, decision, reason)
+
+	// 5. Cache the decision, for the policy's TTL if it sets one
+	if cacheable && cacheUp {
+		e.cache.storeFor(cacheKey, decision, reason, denyErr, policy.CacheTTL)
 	}
 
-	// 4. Cache the decision
-	e.cache.Set(cacheKey, decision, reason)
+	// Combine it with the external authorizer's, which is never cached
+	var consulted bool
+	decision, reason, denyErr, consulted = e.authorizeExternal(ctx, policy, agent, toolName, request, requestID, decision, reason, denyErr)
+	if consulted && ctx.Err() != nil {
+		return nil, evaluationCancelled(ctx)
+	}
+
+	// Charge the call to the policy's budgets, which are never cached,
+	// unless it is only queried
+	decision, reason, denyErr, obligations = e.checkBudget(e.spend, policy, agent, toolName, decision, reason, denyErr, obligations, charging(ctx))
 
-	// 5. Emit audit event
-	e.emitAudit(agent, toolName, decision, reason, requestID, false)
+	// Risk rules apply to every policy's calls, and are never cached
+	obligations = e.riskObligations(risk, decision, obligations)
 
-	// 6. Apply enforcement mode
-	return e.applyMode(decision), nil
+	// 6. Emit audit event
+	e.emitAudit(ctx, agent, toolName, request, risk, decision, reason, requestID, false)
+
+	// 7. Apply enforcement mode
+	result = e.result(deciding, agent, toolName, request, mutations, obligations, decision, reason, denyErr, false)
+	if opaMetrics != nil && opaMetrics.Queries > 0 {
+		result.OPAMetrics = opaMetrics
+	}
+	result.Trace = traceOf(ctx)
+	return result, nil
+}
+
+// result builds the EvaluationResult for a raw policy decision, applying
+// the enforcement mode. If the call is still allowed it carries the mutated
+// parameters and the obligations; if it is denied, the rendered deny message.
+// denyErr is the typed cause of a denial and is dropped for allows.
+func (e *Engine) result(policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}, mutations []string, obligations []Obligation, decision Decision, reason string, denyErr error, cached bool) *EvaluationResult {
+	result := &EvaluationResult{
+		Decision: e.applyMode(agent, decision),
+		Reason:   reason,
+		Cached:   cached,
+	}
+	if decision == Deny {
+		result.Err = denyErr
+	}
+	if policy != nil {
+		result.Policy = policy.Name
+		if result.Decision == Deny {
+			result.Message, result.MessageLocale = denyMessage(policy, agent, toolName, request)
+		} else {
+			if len(mutations) > 0 {
+				result.Parameters, _ = request.(map[string]interface{})
+				result.Mutations = mutations
+			}
+			result.Obligations = obligations
+			if perm, ok := policy.ToolTable[toolName]; ok && perm.Constraints != nil {
+				result.Timeout = perm.Constraints.Timeout
+				result.MaxConcurrent = perm.Constraints.MaxConcurrent
+				result.Retry = perm.Constraints.Retry
+			}
+		}
+	}
+	return result
+}
+
+// decide evaluates a call against one policy, with OPA or the legacy
+// engine. If memoize is set, the OPA query result may be memoized (see
+// WithOPAMemo).
+func (e *Engine) decide(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}, memoize bool) (Decision, string, []Obligation, error) {
+	if e.shouldUseOPA(policy) {
+		// OPA evaluation path (~100-500μs)
+		return e.evaluateOPA(ctx, policy, agent, toolName, request, memoize)
+	}
+	// Legacy evaluation path (~10-100μs)
+	decision, reason, denyErr := e.evaluatePolicy(ctx, policy, agent, toolName, request)
+	return decision, reason, ruleObligations(policy, toolName), denyErr
 }
 
 // shouldUseOPA determines if OPA should be used for this policy.
 func (e *Engine) shouldUseOPA(policy *CompiledPolicy) bool {
-	return e.useOPA && policy.OPAEnabled && policy.PreparedQuery != nil
+	return e.useOPA && policy.OPAEnabled && policy.PreparedQuery != nil && !e.guard.degradedTo(FallbackLegacy)
 }
 
 // evaluateOPA runs the prepared OPA query for policy evaluation.
 // This is the OPA hot path - uses pre-compiled queries for speed.
-func (e *Engine) evaluateOPA(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}) (Decision, string) {
-	// Convert request to map if needed
-	params, ok := request.(map[string]interface{})
-	if !ok {
-		params = make(map[string]interface{})
+// Denials with a typed cause also return it (see policyerrors). If memoize
+// is set, the query result may be memoized (see WithOPAMemo).
+func (e *Engine) evaluateOPA(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}, memoize bool) (Decision, string, []Obligation, error) {
+	// Flatten typed and raw parameters into the OPA request map
+	params := requestParameterMap(request)
+
+	if err := canonicalizeOPAParams(policy, toolName, params); err != nil {
+		return Deny, err.Error(), nil, err
 	}
 
 	// Use the OPA evaluator if available
 	if e.opaEval != nil {
-		decision, reason, err := e.opaEval.Evaluate(ctx, agent, toolName, params)
+		var decision Decision
+		var reason string
+		var obligations []Obligation
+		err := e.faults.Inject(ctx, FaultOPAEval)
+		if err == nil {
+			query := e.preparedQuery(policy, agent.AgentType)
+			queryCtx, measured := withQueryMetrics(ctx)
+			decision, reason, obligations, err = e.opaEval.evaluateCompiled(queryCtx, policy, query, agent, toolName, params, memoize)
+			e.recordOPAMetrics(ctx, policy, toolName, measured)
+		}
 		if err != nil {
 			// OPA error - fail closed
-			return Deny, fmt.Sprintf("OPA evaluation error: %v", err)
+			return Deny, fmt.Sprintf("OPA evaluation error: %v", err), nil, fmt.Errorf("%w: %w", policyerrors.ErrOPAEvaluation, err)
+		}
+		if decision == Deny && strings.HasPrefix(reason, mtsViolationPrefix) {
+			return decision, reason, obligations, fmt.Errorf("%w: %s", policyerrors.ErrMTSViolation, strings.TrimPrefix(reason, mtsViolationPrefix))
+		}
+
+		// Conditions, constraint extensions, and custom kinds are not part
+		// of the generated Rego
+		if perm, ok := policy.ToolTable[toolName]; ok && decision == Allow && perm.Constraints != nil {
+			if err := checkExtensions(perm.Constraints, agent, toolName, params); err != nil {
+				return Deny, fmt.Sprintf("constraint violation: %v", err), obligations, &policyerrors.ErrConstraintViolation{Constraint: "extensions", Err: err}
+			}
+			in := newConstraintInput(ctx, perm.Constraints, agent, toolName, request)
+			in.params = params
+			in.conditions = &e.conditions
+			if err := checkConditions(in); err != nil {
+				return Deny, fmt.Sprintf("constraint violation: conditions: %v", err), obligations, constraintViolation("conditions", in, err)
+			}
+			if err := runCustomCheckers(in); err != nil {
+				return Deny, fmt.Sprintf("constraint violation: %v", err), obligations, err
+			}
 		}
-		return decision, reason
+		return decision, reason, obligations, nil
 	}
 
 	// Fallback: OPA evaluator not initialized
 	// This should not happen in normal operation as the evaluator is created with the engine
-	return Deny, "OPA evaluator not initialized"
+	return Deny, "OPA evaluator not initialized", nil, fmt.Errorf("%w: evaluator not initialized", policyerrors.ErrOPAEvaluation)
 }
 
-// evaluatePolicy checks the policy for a specific tool
-func (e *Engine) evaluatePolicy(policy *CompiledPolicy, toolName string, request interface{}) (Decision, string) {
+// canonicalizeOPAParams prepares request parameters for generated Rego,
+// which has no IDNA support and cannot see the filesystem: the domain is
+// normalized and, if the tool's constraints ask for it, symlinks in the
+// path are resolved. The Rego normalizes the path lexically itself.
+// Returns a *policyerrors.ErrConstraintViolation if a constrained parameter
+// cannot be canonicalized.
+func canonicalizeOPAParams(policy *CompiledPolicy, toolName string, params map[string]interface{}) error {
+	perm, ok := policy.ToolTable[toolName]
+	if !ok || perm.Constraints == nil {
+		return nil
+	}
+	constraints := perm.Constraints
+
+	if domain, ok := params["domain"].(string); ok && domain != "" {
+		normalized, err := NormalizeDomain(domain)
+		if err == nil {
+			params["domain"] = normalized
+		} else if len(constraints.AllowedDomains) > 0 || len(constraints.DeniedDomains) > 0 {
+			return &policyerrors.ErrConstraintViolation{Constraint: "domains", Value: domain, Err: err}
+		}
+	}
+
+	if constraints.ResolveSymlinks {
+		if path, ok := params["path"].(string); ok && path != "" {
+			resolved, err := canonicalPath(constraints, path)
+			if err != nil {
+				return &policyerrors.ErrConstraintViolation{Constraint: "pathPatterns", Value: path, Err: fmt.Errorf("invalid path: %w", err)}
+			}
+			params["path"] = resolved
+		}
+	}
+	return nil
+}
+
+// evaluatePolicy checks the policy for a specific tool. Constraint
+// violations also return the *policyerrors.ErrConstraintViolation.
+func (e *Engine) evaluatePolicy(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}) (Decision, string, error) {
 	// Check explicit tool permission
 	if perm, ok := policy.ToolTable[toolName]; ok {
 		if perm.Action == Deny {
-			return Deny, "tool explicitly denied by policy"
+			return Deny, "tool explicitly denied by policy", nil
 		}
 
 		// Tool allowed - check constraints if any
 		if perm.Constraints != nil {
-			if !e.checkConstraints(perm.Constraints, toolName, request) {
-				return Deny, "constraint violation"
+			if err := e.checkConstraints(ctx, perm.Constraints, agent, toolName, request); err != nil {
+				return Deny, "constraint violation", err
 			}
 		}
-		return Allow, "tool explicitly allowed by policy"
+		return Allow, "tool explicitly allowed by policy", nil
 	}
 
 	// Tool not in policy - use default action
 	if policy.DefaultAction == Allow {
-		return Allow, "allowed by default policy"
+		return Allow, "allowed by default policy", nil
 	}
-	return Deny, "denied by default policy"
+	return Deny, "denied by default policy", nil
 }
 
-// checkConstraints evaluates constraint rules against the request
-func (e *Engine) checkConstraints(constraints *ToolConstraints, toolName string, request interface{}) bool {
-	// Type-assert request to extract parameters
-	// When using gRPC, parameters come from agentpb.ExecuteRequest.GetParametersMap()
-	params, ok := request.(map[string]interface{})
-	if !ok {
-		// Can't check constraints without structured request
-		return true
+// checkConstraints evaluates constraint rules against the request: the
+// built-in constraint kinds, then any custom kinds (see ConstraintChecker).
+func (e *Engine) checkConstraints(ctx context.Context, constraints *ToolConstraints, agent AgentContext, toolName string, request interface{}) error {
+	in := newConstraintInput(ctx, constraints, agent, toolName, request)
+	in.conditions = &e.conditions
+	if err := runBuiltinCheckers(in); err != nil {
+		return err
 	}
+	return runCustomCheckers(in)
+}
 
-	// Check path constraints for file operations
-	if len(constraints.PathPatterns) > 0 {
-		if path, ok := params["path"].(string); ok {
-			matched := false
-			for _, pattern := range constraints.PathPatterns {
-				if match, _ := filepath.Match(pattern, path); match {
-					matched = true
-					break
-				}
-				// Also check if path is under pattern directory
-				if matchPrefix(pattern, path) {
-					matched = true
-					break
-				}
-			}
-			if !matched {
-				return false
-			}
-		}
+// applyMode returns the final decision based on the enforcement mode of
+// the agent's calls (see EffectiveMode)
+func (e *Engine) applyMode(agent AgentContext, decision Decision) Decision {
+	if decision == Deny && e.EffectiveMode(agent) == Permissive {
+		// In permissive mode, log but allow
+		return Allow
 	}
+	return decision
+}
 
-	// Check domain constraints for network operations
-	if len(constraints.AllowedDomains) > 0 {
-		if domain, ok := params["domain"].(string); ok {
-			allowed := false
-			for _, d := range constraints.AllowedDomains {
-				if matchDomain(d, domain) {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				return false
-			}
-		}
-	}
+// emitAudit sends an audit event of a decision the enforcement mode
+// applies to to the sink
+func (e *Engine) emitAudit(ctx context.Context, agent AgentContext, tool string, request interface{}, risk RiskLevel, decision Decision, reason, requestID string, cached bool) {
+	e.emitAuditEnforced(ctx, agent, tool, request, risk, decision, e.applyMode(agent, decision), reason, requestID, cached)
+}
 
-	// Check denied domains
-	if len(constraints.DeniedDomains) > 0 {
-		if domain, ok := params["domain"].(string); ok {
-			for _, d := range constraints.DeniedDomains {
-				if matchDomain(d, domain) {
-					return false
-				}
-			}
-		}
+// emitAuditEnforced sends an audit event of a decision enforced as
+// enforced to the sink, and counts it if it would have been denied and is
+// not a query
+func (e *Engine) emitAuditEnforced(ctx context.Context, agent AgentContext, tool string, request interface{}, risk RiskLevel, decision, enforced Decision, reason, requestID string, cached bool) {
+	source := querySource(ctx)
+	if decision == Deny && enforced == Allow && source == "" {
+		e.wouldDeny.Add(1)
+	}
+	if e.audit == nil {
+		return
+	}
+	if err := e.faults.Inject(context.Background(), FaultAuditSink); err != nil {
+		e.log.Warn("audit event lost", LogKeyTool, tool, LogKeyRequestID, requestID, "error", err)
+		return
 	}
 
-	// Check size constraints
-	if constraints.MaxSizeBytes > 0 {
-		if size, ok := params["size"].(int64); ok {
-			if size > constraints.MaxSizeBytes {
-				return false
+	event := &AuditEvent{
+		Timestamp: time.Now(),
+		Agent:     agent,
+		Tool:      tool,
+		RawTool:   rawToolName(ctx, tool),
+		RiskLevel: risk,
+		Decision:  decision,
+		Mode:      e.EffectiveMode(agent),
+		Reason:    reason,
+		RequestID: requestID,
+		Cached:    cached,
+		Combining: e.combining,
+		Source:    source,
+
+		EnforcedDecision: enforced,
+	}
+	if e.auditParams || e.riskAuditsParameters(risk) {
+		if params := requestParameterMap(request); len(params) > 0 {
+			if e.auditRedactor != nil {
+				params = e.auditRedactor.Value(params).(map[string]interface{})
 			}
+			event.Parameters = params
 		}
 	}
+	e.audit.Log(event)
+}
 
-	return true
+// Audit records a decision made outside the engine, such as a request the
+// router rejected before evaluation, to the engine's audit sink. The
+// decision is recorded as enforced, whatever the mode.
+func (e *Engine) Audit(agent AgentContext, tool string, decision Decision, reason, requestID string) {
+	e.emitAuditEnforced(context.Background(), agent, tool, nil, e.RiskLevel(nil, tool), decision, decision, reason, requestID, false)
 }
 
-// applyMode returns the final decision based on enforcement mode
-func (e *Engine) applyMode(decision Decision) Decision {
-	if e.mode == Permissive && decision == Deny {
-		// In permissive mode, log but allow
-		return Allow
-	}
-	return decision
+// AuditQuery records a decision on a query from source made outside the
+// engine, such as a query the router rejected before evaluation, like
+// Audit (see ContextWithQuery).
+func (e *Engine) AuditQuery(source string, agent AgentContext, tool string, decision Decision, reason, requestID string) {
+	e.emitAuditEnforced(ContextWithQuery(context.Background(), source), agent, tool, nil, e.RiskLevel(nil, tool), decision, decision, reason, requestID, false)
 }
 
-// emitAudit sends an audit event to the sink
-func (e *Engine) emitAudit(agent AgentContext, tool string, decision Decision, reason, requestID string, cached bool) {
+// AuditExecution records the outcome of an allowed call's execution to
+// the engine's audit sink, as an event following the call's decision
+// event under the same request ID.
+func (e *Engine) AuditExecution(agent AgentContext, tool, requestID string, record ExecutionRecord) {
 	if e.audit == nil {
 		return
 	}
-
 	e.audit.Log(&AuditEvent{
 		Timestamp: time.Now(),
 		Agent:     agent,
 		Tool:      tool,
-		Decision:  decision,
-		Reason:    reason,
+		RiskLevel: e.RiskLevel(nil, tool),
+		Decision:  Allow,
+		Mode:      e.EffectiveMode(agent),
+		Reason:    string(record.Status),
 		RequestID: requestID,
-		Cached:    cached,
+		Execution: &record,
+
+		EnforcedDecision: Allow,
 	})
 }
 
+// WouldDenyCount returns the number of calls the policies denied that
+// were allowed because of Permissive mode, since the engine was created,
+// in every tenant partition of the engine.
+func (e *Engine) WouldDenyCount() uint64 {
+	return e.wouldDeny.Load()
+}
+
+// FlushAudit flushes the engine's audit sink, if it buffers events.
+func (e *Engine) FlushAudit() error {
+	if f, ok := e.audit.(AuditFlusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
 // LoadPolicy adds or updates a policy for an agent type.
 // This invalidates cached decisions for that agent type.
+// Loading under FallbackAgentType designates the cluster fallback policy.
 func (e *Engine) LoadPolicy(agentType string, policy *CompiledPolicy) {
-	e.mu.Lock()
-	e.policies[agentType] = policy
-	e.mu.Unlock()
+	e.specialize(agentType, policy)
+	e.resolver.Set(agentType, policy)
+	e.log.Debug("loaded policy", LogKeyAgentType, agentType, "policy", policy.Name, "opa", e.shouldUseOPA(policy))
+	e.logToolNameCollisions(agentType, policy)
 
-	// Invalidate cache entries for this agent type
-	e.cache.InvalidatePrefix(agentType + ":")
+	// Invalidate cache entries for this agent type, here and on other replicas
+	e.invalidateAgentType(agentType)
+	e.publishInvalidation(agentType)
+	e.notifier.notify()
 }
 
 // RemovePolicy removes a policy for an agent type.
 func (e *Engine) RemovePolicy(agentType string) {
-	e.mu.Lock()
-	delete(e.policies, agentType)
-	e.mu.Unlock()
+	e.resolver.Delete(agentType)
+	e.unbound(agentType)
+}
 
-	e.cache.InvalidatePrefix(agentType + ":")
+// unbound clears the state of an agent type whose policy was removed.
+func (e *Engine) unbound(agentType string) {
+	e.specialized.delete(agentType)
+	e.log.Debug("removed policy", LogKeyAgentType, agentType)
+
+	e.invalidateAgentType(agentType)
+	e.publishInvalidation(agentType)
+	e.notifier.notify()
+}
+
+// PolicyBindings returns the agent types and patterns, including
+// FallbackAgentType, bound to the policy compiled from the resource with
+// uid, sorted.
+func (e *Engine) PolicyBindings(uid string) []string {
+	var keys []string
+	if uid == "" {
+		return keys
+	}
+	for _, key := range e.resolver.Keys() {
+		if policy, ok := e.resolver.Get(key); ok && policy.UID == uid {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// GetPolicy returns the policy for an agent type (for inspection).
+// RemovePolicyUID unbinds the policy compiled from the resource with uid
+// from every agent type and pattern it is bound to, except those in keep,
+// and returns the ones it unbound. Removing a policy that is not loaded
+// does nothing, and bindings concurrently replaced by another policy are
+// kept.
+func (e *Engine) RemovePolicyUID(uid string, keep ...string) []string {
+	var removed []string
+	for _, key := range e.PolicyBindings(uid) {
+		if containsString(keep, key) {
+			continue
+		}
+		if e.resolver.DeleteUID(key, uid) {
+			e.unbound(key)
+			removed = append(removed, key)
+		}
+	}
+	return removed
+}
+
+// FallbackPolicy returns the cluster fallback policy, if one is loaded.
+func (e *Engine) FallbackPolicy() (*CompiledPolicy, bool) {
+	return e.GetPolicy(FallbackAgentType)
+}
+
+// invalidateAgentType clears cached decisions for an agent type.
+// Patterns (including the fallback policy) may back decisions for any
+// agent type, so changing them clears the entire cache.
+func (e *Engine) invalidateAgentType(agentType string) {
+	if e.opaEval != nil && e.opaEval.memo != nil {
+		e.opaEval.memo.invalidate(agentType)
+	}
+	if IsAgentTypePattern(agentType) {
+		e.cache.InvalidateAll()
+	} else {
+		e.cache.InvalidatePrefix(agentType + ":")
+	}
+	e.warmCache(agentType)
+	e.hooks.call(agentType)
+}
+
+// GetPolicy returns the policy bound to an agent type or pattern (for inspection).
 func (e *Engine) GetPolicy(agentType string) (*CompiledPolicy, bool) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	policy, ok := e.policies[agentType]
+	return e.resolver.Get(agentType)
+}
+
+// ResolvePolicy returns the policy that applies to an agent, taking
+// patterns, label selectors, the fallback policy, and canary rollouts into
+// account.
+func (e *Engine) ResolvePolicy(agent AgentContext) (*CompiledPolicy, bool) {
+	policy, ok := e.resolver.Resolve(agent)
+	if ok {
+		policy, _, _ = e.selectCanary(policy, agent)
+	}
 	return policy, ok
 }
 
-// ListPolicies returns all loaded agent types.
+// ListPolicies returns all loaded agent types and patterns.
 func (e *Engine) ListPolicies() []string {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	types := make([]string, 0, len(e.policies))
-	for t := range e.policies {
-		types = append(types, t)
-	}
-	return types
+	return e.resolver.Keys()
 }
 
 // Mode returns the current enforcement mode.
 func (e *Engine) Mode() EnforcementMode {
-	return e.mode
+	return EnforcementMode(e.mode.Load())
 }
 
-// SetMode changes the enforcement mode.
+// SetMode changes the enforcement mode. Tenants with a mode of their own
+// (see SetTenantMode) keep it.
 func (e *Engine) SetMode(mode EnforcementMode) {
-	e.mode = mode
+	e.mode.Store(int32(mode))
+	e.modeChanged()
+}
+
+// Subscribe returns a channel that is signaled whenever a policy is loaded
+// or removed, or the enforcement mode changes. Signals are coalesced: the
+// subscriber should re-read the state it cares about (e.g., ResolvePolicy)
+// on each signal. Call the returned function to unsubscribe.
+func (e *Engine) Subscribe() (<-chan struct{}, func()) {
+	return e.notifier.subscribe()
 }
 
 // CacheStats returns cache statistics.
@@ -355,6 +900,15 @@ func (e *Engine) CacheStats() (hits, misses uint64, hitRate float64) {
 	return e.cache.Stats()
 }
 
+// OPAMemoStats returns the hits and misses of the OPA result memo (zero if
+// not memoizing, see WithOPAMemo).
+func (e *Engine) OPAMemoStats() (hits, misses uint64) {
+	if e.opaEval == nil || e.opaEval.memo == nil {
+		return 0, 0
+	}
+	return e.opaEval.memo.stats()
+}
+
 // IsOPAEnabled returns whether OPA evaluation is enabled.
 func (e *Engine) IsOPAEnabled() bool {
 	return e.useOPA
@@ -382,7 +936,8 @@ func matchPrefix(pattern, path string) bool {
 	return false
 }
 
-// matchDomain checks if domain matches pattern (supports wildcards)
+// matchDomain checks if domain matches pattern (supports wildcards).
+// Both must already be normalized (see NormalizeDomain).
 func matchDomain(pattern, domain string) bool {
 	if pattern == "*" {
 		return true
@@ -395,6 +950,64 @@ func matchDomain(pattern, domain string) bool {
 	return pattern == domain
 }
 
+// agentCacheKey builds the decision cache key for an agent and tool.
+// Agent labels can change which policy applies, and the tenant and MTS
+// label (resolved from the TenantRegistry or claimed) decide MTS checks,
+// so agents with any of them get them appended. The agentType prefix is
+// preserved so per-type invalidation still works.
+func agentCacheKey(agent AgentContext, toolName string) string {
+	key := CacheKey(agent.AgentType, toolName)
+	if len(agent.Labels) == 0 && agent.TenantID == "" && agent.MTSLabel == "" {
+		return key
+	}
+
+	var b strings.Builder
+	b.WriteString(key)
+	if agent.TenantID != "" || agent.MTSLabel != "" {
+		// Resolved labels may dominate policy labels, claimed ones not
+		b.WriteString("|tenant=")
+		b.WriteString(agent.TenantID)
+		b.WriteString("|mts=")
+		b.WriteString(agent.MTSLabel)
+		if agent.labelResolved {
+			b.WriteString("|resolved")
+		}
+	}
+	if len(agent.Labels) == 0 {
+		return b.String()
+	}
+
+	keys := make([]string, 0, len(agent.Labels))
+	for k := range agent.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b.WriteByte('#')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(agent.Labels[k])
+	}
+	return b.String()
+}
+
+// contentHashKey returns a cache key suffix for the content hash a request
+// carries, so a decision for one artifact is never reused for another.
+func contentHashKey(request interface{}) string {
+	params, ok := toTypedParams(request)
+	if !ok || params.exec.ContentSHA256 == "" {
+		return ""
+	}
+	if d, ok := NormalizeContentHash(params.exec.ContentSHA256); ok {
+		return "@" + d
+	}
+	return "@invalid"
+}
+
 // generateRequestID creates a unique request identifier
 func generateRequestID() string {
 	return fmt.Sprintf("req_%d", time.Now().UnixNano())
@@ -405,10 +1018,24 @@ func generateRequestID() string {
 // CompilePolicy converts raw policy spec to optimized CompiledPolicy.
 // This creates a legacy-mode policy (OPAEnabled=false).
 // Use CompilePolicyWithOPA for OPA-enabled policies.
+//
+// Each tool has one rule: if permissions list a tool more than once, the
+// first rule wins and the others are ignored. AgentPolicies that do so
+// are rejected by the compiler (see compile.AgentPolicy).
 func CompilePolicy(name string, agentTypes []string, defaultAction Decision, permissions []ToolPermission, mode EnforcementMode, mtsLabel string) *CompiledPolicy {
 	toolTable := make(map[string]*ToolPermission, len(permissions))
 	for i := range permissions {
-		toolTable[permissions[i].Tool] = &permissions[i]
+		if _, ok := toolTable[permissions[i].Tool]; ok {
+			continue
+		}
+		perm := &permissions[i]
+		// Domain patterns are normalized once, not on every call
+		if c := normalizeConstraintDomains(perm.Constraints); c != perm.Constraints {
+			normalized := *perm
+			normalized.Constraints = c
+			perm = &normalized
+		}
+		toolTable[permissions[i].Tool] = perm
 	}
 
 	return &CompiledPolicy{
@@ -446,3 +1073,23 @@ func CompilePolicyWithOPA(name string, agentTypes []string, defaultAction Decisi
 
 	return policy, nil
 }
+
+// CompilePolicyWithOPAModules creates an OPA-enabled CompiledPolicy from a
+// set of Rego modules keyed by file name, such as the per-tool packages of
+// the v2 template. RegoModule is set to the JoinRegoModules bundle so the
+// policy fingerprint covers every module.
+func CompilePolicyWithOPAModules(name string, agentTypes []string, defaultAction Decision, permissions []ToolPermission, mode EnforcementMode, mtsLabel string, modules map[string]string) (*CompiledPolicy, error) {
+	policy := CompilePolicy(name, agentTypes, defaultAction, permissions, mode, mtsLabel)
+
+	policy.RegoModule = JoinRegoModules(modules)
+	policy.RegoModules = modules
+	policy.OPAEnabled = true
+
+	prepared, err := PrepareRegoModules(modules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile Rego modules: %w", err)
+	}
+	policy.PreparedQuery = &prepared
+
+	return policy, nil
+}
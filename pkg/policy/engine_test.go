@@ -2,8 +2,13 @@ package policy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
 )
 
 // TestEngineBasicAllow verifies that allowed tools pass
@@ -79,6 +84,18 @@ func TestEngineBasicDeny(t *testing.T) {
 	}
 }
 
+// TestCompilePolicyFirstRuleWins verifies that the first rule for a tool
+// is its rule
+func TestCompilePolicyFirstRuleWins(t *testing.T) {
+	policy := CompilePolicy("test-policy", []string{"coding-assistant"}, Allow, []ToolPermission{
+		{Tool: "shell.execute", Action: Deny},
+		{Tool: "shell.execute", Action: Allow},
+	}, Enforcing, "")
+	if perm := policy.ToolTable["shell.execute"]; perm == nil || perm.Action != Deny {
+		t.Errorf("expected the first rule to win, got %+v", perm)
+	}
+}
+
 // TestEngineDefaultAllow verifies default-allow policies
 func TestEngineDefaultAllow(t *testing.T) {
 	engine := NewEngine(WithMode(Enforcing))
@@ -229,6 +246,70 @@ func TestEngineCacheInvalidation(t *testing.T) {
 	}
 }
 
+// TestEngineCacheParameterConstraints verifies that a cached allow is
+// never reused for a call whose parameters violate the rule's constraints.
+// The cache is not invalidated between the calls.
+func TestEngineCacheParameterConstraints(t *testing.T) {
+	tests := []struct {
+		name        string
+		constraints *ToolConstraints
+		allowed     map[string]interface{}
+		denied      []map[string]interface{}
+	}{
+		{
+			name:        "path",
+			constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			allowed:     map[string]interface{}{"path": "/workspace/x"},
+			denied: []map[string]interface{}{
+				{"path": "/workspace/../etc/passwd"},
+				{"path": "/etc/shadow"},
+			},
+		},
+		{
+			name:        "domain",
+			constraints: &ToolConstraints{AllowedDomains: []string{"api.github.com"}},
+			allowed:     map[string]interface{}{"domain": "api.github.com"},
+			denied:      []map[string]interface{}{{"domain": "evil.example.com"}},
+		},
+		{
+			name:        "port",
+			constraints: &ToolConstraints{AllowedPorts: []int{443}},
+			allowed:     map[string]interface{}{"port": 443},
+			denied:      []map[string]interface{}{{"port": 22}},
+		},
+		{
+			name:        "size",
+			constraints: &ToolConstraints{MaxSizeBytes: 1024},
+			allowed:     map[string]interface{}{"size": 10},
+			denied:      []map[string]interface{}{{"size": 1 << 20}},
+		},
+		{
+			name:        "timeout",
+			constraints: &ToolConstraints{Timeout: time.Second},
+			allowed:     map[string]interface{}{"timeout_ms": 100},
+			denied:      []map[string]interface{}{{"timeout_ms": 60000}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(WithMode(Enforcing))
+			engine.LoadPolicy("coding-assistant", CompilePolicy("test-policy", []string{"coding-assistant"}, Deny,
+				[]ToolPermission{{Tool: "tool.call", Action: Allow, Constraints: tt.constraints}}, Enforcing, ""))
+			agent := AgentContext{AgentType: "coding-assistant"}
+
+			if decision, _ := engine.Evaluate(context.Background(), agent, "tool.call", tt.allowed); decision != Allow {
+				t.Fatalf("%v: expected Allow, got %v", tt.allowed, decision)
+			}
+			for _, request := range tt.denied {
+				if decision, _ := engine.Evaluate(context.Background(), agent, "tool.call", request); decision != Deny {
+					t.Errorf("%v: expected Deny after a cached allow, got %v", request, decision)
+				}
+			}
+		})
+	}
+}
+
 // TestEnginePathConstraints verifies file path constraints
 func TestEnginePathConstraints(t *testing.T) {
 	engine := NewEngine(WithMode(Enforcing))
@@ -387,6 +468,47 @@ func TestAuditSink(t *testing.T) {
 	}
 }
 
+// TestAuditEnforcedDecision verifies audit events tell the denials
+// Permissive mode allowed from enforced ones, and that they are counted
+func TestAuditEnforcedDecision(t *testing.T) {
+	var events []*AuditEvent
+	sink := &testAuditSink{events: &events}
+
+	engine := NewEngine(WithMode(Permissive), WithAuditSink(sink))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("test-policy", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "file.read", Action: Allow},
+	}, Permissive, ""))
+	engine.KillTool(KillSwitch{Source: "incident", Tool: "network.fetch"})
+
+	agent := AgentContext{AgentType: "coding-assistant"}
+	for _, tool := range []string{"file.read", "file.write", "network.fetch"} {
+		engine.Evaluate(context.Background(), agent, tool, nil)
+	}
+	engine.Audit(agent, "file.write", Deny, "invalid request", "req-1")
+
+	want := []struct{ raw, enforced Decision }{{Allow, Allow}, {Deny, Allow}, {Deny, Deny}, {Deny, Deny}}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d audit events, got %d", len(want), len(events))
+	}
+	for i, w := range want {
+		if events[i].Decision != w.raw || events[i].EnforcedDecision != w.enforced {
+			t.Errorf("event %d: expected %s enforced as %s, got %s enforced as %s", i, w.raw, w.enforced, events[i].Decision, events[i].EnforcedDecision)
+		}
+	}
+	if avc := formatAVC(events[1]); !strings.Contains(avc, " permissive=1") {
+		t.Errorf("expected the would-deny AVC record to be marked permissive, got %s", avc)
+	}
+	if avc := formatAVC(events[2]); strings.Contains(avc, "permissive=1") {
+		t.Errorf("expected the kill switch's AVC record to be enforced, got %s", avc)
+	}
+	if je := newJSONAuditEvent(events[1]); je.Decision != "DENY" || je.RawDecision != "DENY" || je.EnforcedDecision != "ALLOW" {
+		t.Errorf("unexpected JSON decisions %+v", je)
+	}
+	if n := engine.WouldDenyCount(); n != 1 {
+		t.Errorf("expected 1 would-deny, got %d", n)
+	}
+}
+
 // testAuditSink is a simple audit sink for testing
 type testAuditSink struct {
 	events *[]*AuditEvent
@@ -395,3 +517,283 @@ type testAuditSink struct {
 func (s *testAuditSink) Log(event *AuditEvent) {
 	*s.events = append(*s.events, event)
 }
+
+// TestEngineFallbackPolicy verifies the fallback policy covers unknown agent types
+func TestEngineFallbackPolicy(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+
+	specific := CompilePolicy(
+		"coding-policy",
+		[]string{"coding-assistant"},
+		Allow,
+		[]ToolPermission{},
+		Enforcing,
+		"",
+	)
+	engine.LoadPolicy("coding-assistant", specific)
+
+	fallback := CompilePolicy(
+		"baseline-policy",
+		[]string{"baseline-agent"},
+		Deny,
+		[]ToolPermission{
+			{Tool: "file.read", Action: Allow},
+		},
+		Enforcing,
+		"",
+	)
+	engine.LoadPolicy(FallbackAgentType, fallback)
+
+	unknown := AgentContext{AgentType: "new-agent"}
+
+	decision, _ := engine.Evaluate(context.Background(), unknown, "file.read", nil)
+	if decision != Allow {
+		t.Errorf("expected fallback to allow file.read, got %v", decision)
+	}
+
+	decision, _ = engine.Evaluate(context.Background(), unknown, "file.write", nil)
+	if decision != Deny {
+		t.Errorf("expected fallback to deny file.write, got %v", decision)
+	}
+
+	// Specific policy takes precedence over the fallback
+	coding := AgentContext{AgentType: "coding-assistant"}
+	decision, _ = engine.Evaluate(context.Background(), coding, "file.write", nil)
+	if decision != Allow {
+		t.Errorf("expected specific policy to allow file.write, got %v", decision)
+	}
+
+	// Removing the fallback clears cached fallback decisions
+	engine.RemovePolicy(FallbackAgentType)
+	decision, _ = engine.Evaluate(context.Background(), unknown, "file.read", nil)
+	if decision != Deny {
+		t.Errorf("expected Deny after fallback removal, got %v", decision)
+	}
+}
+
+// TestEngineRequiredAgentLabels verifies label-based tool constraints
+func TestEngineRequiredAgentLabels(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+
+	policy := CompilePolicy(
+		"test-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{
+				Tool:   "db.query",
+				Action: Allow,
+				Constraints: &ToolConstraints{
+					RequiredAgentLabels: map[string]string{"environment": "production"},
+				},
+			},
+		},
+		Enforcing,
+		"",
+	)
+	engine.LoadPolicy("coding-assistant", policy)
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected Decision
+	}{
+		{"matching label", map[string]string{"environment": "production", "team": "ml"}, Allow},
+		{"wrong value", map[string]string{"environment": "dev"}, Deny},
+		{"missing label", nil, Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := AgentContext{AgentType: "coding-assistant", Labels: tt.labels}
+			decision, _ := engine.Evaluate(context.Background(), agent, "db.query", nil)
+			if decision != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, decision)
+			}
+		})
+	}
+}
+
+// TestEngineEvaluationCancelled verifies that a done context fails the
+// evaluation with ErrEvaluationCancelled, without caching or auditing a
+// decision
+func TestEngineEvaluationCancelled(t *testing.T) {
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Enforcing), WithAuditSink(sink))
+	engine.LoadPolicy("coding-assistant", CompilePolicy(
+		"test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}},
+		Enforcing, "",
+	))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	decision, err := engine.Evaluate(ctx, agent, "file.read", nil)
+	if !errors.Is(err, ErrEvaluationCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrEvaluationCancelled wrapping context.Canceled, got %v", err)
+	}
+	if decision != Deny {
+		t.Errorf("expected Deny on cancellation, got %v", decision)
+	}
+	if engine.Cache().Size() != 0 {
+		t.Errorf("expected no cached decision, got %d entries", engine.Cache().Size())
+	}
+	select {
+	case event := <-sink.Events():
+		t.Errorf("expected no audit event, got %+v", event)
+	default:
+	}
+
+	// A cached decision is not served to a cancelled caller either
+	if _, err := engine.Evaluate(context.Background(), agent, "file.read", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := engine.Evaluate(ctx, agent, "file.read", nil); !errors.Is(err, ErrEvaluationCancelled) {
+		t.Errorf("expected ErrEvaluationCancelled on cache hit, got %v", err)
+	}
+}
+
+// TestEngineEvaluationTimeout verifies that WithEvaluationTimeout bounds
+// constraint checkers, which see the deadline through their input
+func TestEngineEvaluationTimeout(t *testing.T) {
+	RegisterConstraintChecker("slow-lookup", ConstraintCheckerFunc(func(in *ConstraintInput) error {
+		<-in.Context().Done()
+		return in.Context().Err()
+	}))
+	defer UnregisterConstraintChecker("slow-lookup")
+
+	engine := NewEngine(WithMode(Enforcing), WithEvaluationTimeout(20*time.Millisecond))
+	engine.LoadPolicy("coding-assistant", CompilePolicy(
+		"test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{
+			Tool:        "repo.push",
+			Action:      Allow,
+			Constraints: &ToolConstraints{Custom: map[string]json.RawMessage{"slow-lookup": json.RawMessage(`{}`)}},
+		}},
+		Enforcing, "",
+	))
+
+	start := time.Now()
+	_, err := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "repo.push", nil)
+	if !errors.Is(err, ErrEvaluationCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrEvaluationCancelled wrapping context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("evaluation took %v, expected it to stop at the timeout", elapsed)
+	}
+}
+
+// TestEngineTypedErrors verifies that denials carry their typed cause,
+// also when served from the cache
+func TestEngineTypedErrors(t *testing.T) {
+	RegisterConstraintChecker("jira-project", ConstraintCheckerFunc(func(in *ConstraintInput) error {
+		return &policyerrors.ErrConstraintViolation{Value: "OPS", Err: errors.New("project is not allowed")}
+	}))
+	defer UnregisterConstraintChecker("jira-project")
+
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy(
+		"test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{
+			{Tool: "file.read", Action: Allow, Constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}}},
+			{Tool: "secrets.read", Action: Allow, Constraints: &ToolConstraints{RequiredAgentLabels: map[string]string{"tier": "trusted"}}},
+			{Tool: "jira.create", Action: Allow, Constraints: &ToolConstraints{Custom: map[string]json.RawMessage{"jira-project": json.RawMessage(`{}`)}}},
+		},
+		Enforcing, "",
+	))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	result, err := engine.EvaluateWithResult(context.Background(), AgentContext{AgentType: "unknown"}, "file.read", nil)
+	if err != nil || !errors.Is(result.Err, policyerrors.ErrNoPolicy) {
+		t.Errorf("expected ErrNoPolicy, got %v (%v)", result.Err, err)
+	}
+
+	for _, cached := range []bool{false, true} {
+		result, err = engine.EvaluateWithResult(context.Background(), agent, "secrets.read", nil)
+		var violation *policyerrors.ErrConstraintViolation
+		if err != nil || result.Cached != cached || !errors.As(result.Err, &violation) || violation.Constraint != "requiredAgentLabels" {
+			t.Fatalf("expected requiredAgentLabels violation (cached=%v), got %+v (%v)", cached, result, err)
+		}
+	}
+
+	// Rules checking parameters are evaluated on every call
+	for i := 0; i < 2; i++ {
+		result, err = engine.EvaluateWithResult(context.Background(), agent, "file.read", map[string]interface{}{"path": "/etc/passwd"})
+		var violation *policyerrors.ErrConstraintViolation
+		if err != nil || result.Cached || !errors.As(result.Err, &violation) {
+			t.Fatalf("expected uncached constraint violation, got %+v (%v)", result, err)
+		}
+		if violation.Constraint != "pathPatterns" || violation.Value != "/etc/passwd" {
+			t.Errorf("expected pathPatterns violation of /etc/passwd, got %+v", violation)
+		}
+	}
+
+	result, _ = engine.EvaluateWithResult(context.Background(), agent, "jira.create", nil)
+	var violation *policyerrors.ErrConstraintViolation
+	if !errors.As(result.Err, &violation) || violation.Constraint != "jira-project" || violation.Value != "OPS" {
+		t.Errorf("expected the checker's reported value, got %v", result.Err)
+	}
+
+	engine.cache.InvalidateAll()
+	result, _ = engine.EvaluateWithResult(context.Background(), agent, "file.read", map[string]interface{}{"path": "/workspace/main.go"})
+	if result.Decision != Allow || result.Err != nil {
+		t.Errorf("expected allow without error, got %+v", result)
+	}
+
+	result, _ = engine.EvaluateWithResult(context.Background(), agent, "db.query", nil)
+	if result.Decision != Deny || result.Err != nil {
+		t.Errorf("expected default deny without typed error, got %+v", result)
+	}
+}
+
+// TestEngineRemovePolicyUID verifies policies are removed by the UID of
+// their resource, not by name.
+func TestEngineRemovePolicyUID(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+
+	newPolicy := func(namespace, uid string, agentTypes ...string) *CompiledPolicy {
+		p := CompilePolicy("shared-name", agentTypes, Allow, nil, Enforcing, "")
+		p.Namespace, p.UID = namespace, uid
+		return p
+	}
+	team1 := newPolicy("team-1", "uid-1", "coding-assistant", "coding-*")
+	team2 := newPolicy("team-2", "uid-2", "data-analyst")
+	engine.LoadPolicy("coding-assistant", team1)
+	engine.LoadPolicy("coding-*", team1)
+	engine.LoadPolicy(FallbackAgentType, team1)
+	engine.LoadPolicy("data-analyst", team2)
+
+	if got := engine.PolicyBindings("uid-1"); strings.Join(got, ",") != "*,coding-*,coding-assistant" {
+		t.Errorf("unexpected bindings of uid-1: %v", got)
+	}
+
+	// Bindings in keep survive
+	if removed := engine.RemovePolicyUID("uid-1", "coding-assistant"); strings.Join(removed, ",") != "*,coding-*" {
+		t.Errorf("expected the pattern and fallback bindings to be removed, got %v", removed)
+	}
+	if _, ok := engine.GetPolicy("coding-assistant"); !ok {
+		t.Error("expected the kept binding to survive")
+	}
+
+	// A binding replaced by another policy is not removed
+	engine.LoadPolicy("coding-assistant", newPolicy("team-1", "uid-3", "coding-assistant"))
+	if removed := engine.RemovePolicyUID("uid-1"); len(removed) != 0 {
+		t.Errorf("expected nothing to be removed, got %v", removed)
+	}
+	if removed := engine.RemovePolicyUID("uid-3"); len(removed) != 1 {
+		t.Errorf("expected the recreated policy to be removed, got %v", removed)
+	}
+	if removed := engine.RemovePolicyUID("uid-3"); len(removed) != 0 {
+		t.Errorf("expected removal to be idempotent, got %v", removed)
+	}
+
+	// The same-named policy of the other namespace is untouched
+	if p, ok := engine.GetPolicy("data-analyst"); !ok || p != team2 {
+		t.Error("expected the other namespace's policy to stay loaded")
+	}
+	if removed := engine.RemovePolicyUID(""); len(removed) != 0 {
+		t.Errorf("expected an empty UID to match nothing, got %v", removed)
+	}
+}
@@ -0,0 +1,92 @@
+// Package errors defines the typed errors of policy evaluation.
+//
+// The engine reports why it denied a request in EvaluationResult.Err, next
+// to the decision, so callers can branch on the cause with errors.Is and
+// errors.As instead of parsing audit reasons:
+//
+//	result, err := engine.EvaluateWithResult(ctx, agent, "file.write", request)
+//	var violation *policyerrors.ErrConstraintViolation
+//	switch {
+//	case errors.Is(result.Err, policyerrors.ErrNoPolicy):
+//	    // no policy governs the agent type
+//	case errors.As(result.Err, &violation):
+//	    // violation.Constraint rejected violation.Value
+//	}
+//
+// The package has no dependencies, so agents can match router denials
+// without importing the engine.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+var (
+	// ErrNoPolicy reports a request from an agent type that no policy,
+	// including the fallback policy, applies to.
+	ErrNoPolicy = stderrors.New("no policy defined for agent type")
+
+	// ErrMTSViolation reports a request denied by tenant isolation: the
+	// agent's MTS label does not dominate the policy's.
+	ErrMTSViolation = stderrors.New("MTS violation")
+
+	// ErrSandboxMismatch reports a request whose tenant, MTS label, or
+	// policy contradicts the SandboxClaim of its sandbox.
+	ErrSandboxMismatch = stderrors.New("sandbox context mismatch")
+
+	// ErrOPACompile reports a Rego module that failed to compile.
+	ErrOPACompile = stderrors.New("failed to compile Rego")
+
+	// ErrOPAEvaluation reports a prepared OPA query that failed to
+	// evaluate; the request was denied fail-closed.
+	ErrOPAEvaluation = stderrors.New("OPA evaluation failed")
+
+	// ErrExternalDenied reports a request denied by the external
+	// authorizer consulted after local evaluation.
+	ErrExternalDenied = stderrors.New("denied by external authorizer")
+
+	// ErrExternalAuthorizer reports an external authorizer that failed or
+	// timed out; the request was denied fail-closed.
+	ErrExternalAuthorizer = stderrors.New("external authorizer failed")
+
+	// ErrBudgetExceeded reports a request denied because its cost would
+	// exceed a session or tenant budget of the policy.
+	ErrBudgetExceeded = stderrors.New("budget exceeded")
+
+	// ErrToolDisabled reports a request for a tool disabled by a kill
+	// switch, whatever the policies and the enforcement mode.
+	ErrToolDisabled = stderrors.New("tool disabled by kill switch")
+
+	// ErrLatencyDegraded reports a request denied because its decision was
+	// not cached while the latency guard degraded evaluation to cached
+	// decisions only.
+	ErrLatencyDegraded = stderrors.New("evaluation degraded by latency guard")
+)
+
+// ErrConstraintViolation reports a request denied by a constraint of the
+// matched tool rule.
+type ErrConstraintViolation struct {
+	// Constraint is the violated constraint kind, a built-in kind such as
+	// "pathPatterns" or "domains", or a custom kind
+	Constraint string
+
+	// Value is the request parameter the constraint rejected, if it checks
+	// a single parameter (e.g., the path or the domain)
+	Value interface{}
+
+	// Err describes the violation
+	Err error
+}
+
+func (e *ErrConstraintViolation) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: constraint violated", e.Constraint)
+	}
+	return fmt.Sprintf("%s: %v", e.Constraint, e.Err)
+}
+
+// Unwrap returns the error describing the violation.
+func (e *ErrConstraintViolation) Unwrap() error {
+	return e.Err
+}
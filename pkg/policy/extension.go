@@ -0,0 +1,38 @@
+package policy
+
+import "fmt"
+
+// ConstraintExtension is a constraint implemented in Go for checks the
+// built-in constraint fields cannot express, such as industrial protocol
+// limits (see ModbusConstraint and OPCUAConstraint).
+//
+// Extensions run in both evaluation paths: after the built-in constraints
+// in the legacy engine, and after an OPA allow for OPA-enabled policies,
+// since generated Rego does not know about them. Their decisions are never
+// cached, as they depend on arbitrary parameters. Implementations should be
+// struct values rather than pointers, so that policy fingerprints and diffs,
+// which format constraints with %+v, see their fields.
+type ConstraintExtension interface {
+	// Name identifies the extension in audit reasons (e.g., "modbus")
+	Name() string
+
+	// Check returns an error describing why the request parameters
+	// violate the constraint, or nil if they satisfy it. Missing
+	// parameters the constraint depends on must be violations.
+	Check(agent AgentContext, toolName string, params map[string]interface{}) error
+}
+
+// checkExtensions runs the constraint extensions of a tool rule against the
+// request, returning the first violation.
+func checkExtensions(constraints *ToolConstraints, agent AgentContext, toolName string, request interface{}) error {
+	if constraints == nil || len(constraints.Extensions) == 0 {
+		return nil
+	}
+	params := requestParameterMap(request)
+	for _, ext := range constraints.Extensions {
+		if err := ext.Check(agent, toolName, params); err != nil {
+			return fmt.Errorf("%s: %w", ext.Name(), err)
+		}
+	}
+	return nil
+}
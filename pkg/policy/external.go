@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// ExternalAuthorizer is an authorization service outside the engine, such
+// as a corporate ABAC service, consulted after local evaluation (see
+// WithExternalAuthorizer). The router provides HTTP and gRPC clients.
+type ExternalAuthorizer interface {
+	// Authorize decides a call. Errors, including a done ctx, deny the
+	// call fail-closed.
+	Authorize(ctx context.Context, req *ExternalAuthzRequest) (*ExternalAuthzResponse, error)
+}
+
+// ExternalAuthzRequest is the call an ExternalAuthorizer decides, with the
+// local decision for context.
+type ExternalAuthzRequest struct {
+	RequestID  string                 `json:"request_id"`
+	Agent      OPAAgentInput          `json:"agent"`
+	Tool       string                 `json:"tool"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Policy is the name of the local policy, if any
+	Policy string `json:"policy,omitempty"`
+
+	// LocalDecision and LocalReason are the local decision ("ALLOW" or
+	// "DENY") and its audit reason
+	LocalDecision string `json:"local_decision"`
+	LocalReason   string `json:"local_reason"`
+}
+
+// ExternalAuthzResponse is the decision of an ExternalAuthorizer.
+type ExternalAuthzResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExternalCombination is how the decision of an ExternalAuthorizer is
+// combined with the local one.
+type ExternalCombination string
+
+const (
+	// ExternalDenyOverrides consults the authorizer on calls the local
+	// policy allows; either may deny
+	ExternalDenyOverrides ExternalCombination = "deny-overrides"
+
+	// ExternalLocalFirst lets the local policy decide calls a tool rule
+	// matches, and the authorizer the others, instead of the policy's
+	// default action
+	ExternalLocalFirst ExternalCombination = "local-first"
+
+	// ExternalOnly lets the authorizer decide every call
+	ExternalOnly ExternalCombination = "external-only"
+)
+
+// DefaultExternalTimeout bounds external authorizer calls by default.
+const DefaultExternalTimeout = 500 * time.Millisecond
+
+// ParseExternalCombination parses an ExternalCombination.
+func ParseExternalCombination(s string) (ExternalCombination, error) {
+	switch c := ExternalCombination(s); c {
+	case ExternalDenyOverrides, ExternalLocalFirst, ExternalOnly:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid external authorizer combination %q: must be deny-overrides, local-first, or external-only", s)
+}
+
+// externalAuthz is the external authorizer of an engine.
+type externalAuthz struct {
+	authorizer  ExternalAuthorizer
+	combination ExternalCombination
+	timeout     time.Duration
+}
+
+// WithExternalAuthorizer consults authorizer after local evaluation,
+// combining its decision with the local one as combination says
+// (ExternalDenyOverrides if empty). Each
+// call is bounded by timeout (DefaultExternalTimeout if zero); calls that
+// fail or time out are denied. External decisions are never cached: the
+// decision cache holds the local decision, and the authorizer is consulted
+// on cache hits too. Calls denied before evaluation, such as for a
+// sandbox mismatch, are not sent to it.
+func WithExternalAuthorizer(authorizer ExternalAuthorizer, combination ExternalCombination, timeout time.Duration) Option {
+	return func(e *Engine) {
+		if timeout <= 0 {
+			timeout = DefaultExternalTimeout
+		}
+		if combination == "" {
+			combination = ExternalDenyOverrides
+		}
+		e.external = &externalAuthz{authorizer: authorizer, combination: combination, timeout: timeout}
+	}
+}
+
+// authorizeExternal combines the local decision of a call with the
+// external authorizer's, and reports whether it was consulted. If ctx is
+// done while it is, the caller must discard the decision.
+func (e *Engine) authorizeExternal(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}, requestID string, decision Decision, reason string, denyErr error) (Decision, string, error, bool) {
+	ext := e.external
+	if ext == nil {
+		return decision, reason, denyErr, false
+	}
+	switch ext.combination {
+	case ExternalLocalFirst:
+		if policy != nil {
+			if _, ok := policy.ToolTable[toolName]; ok {
+				return decision, reason, denyErr, false
+			}
+		}
+	case ExternalOnly:
+	default:
+		if decision == Deny {
+			return decision, reason, denyErr, false
+		}
+	}
+
+	req := &ExternalAuthzRequest{
+		RequestID: requestID,
+		Agent: OPAAgentInput{
+			Type:      agent.AgentType,
+			SandboxID: agent.SandboxID,
+			TenantID:  agent.TenantID,
+			SessionID: agent.SessionID,
+			MTSLabel:  agent.MTSLabel,
+			Labels:    agent.Labels,
+
+			ParentSessionID: agent.ParentSessionID,
+			RootSessionID:   agent.RootSessionID(),
+			Lineage:         agent.Lineage,
+		},
+		Tool:          toolName,
+		LocalDecision: decision.String(),
+		LocalReason:   reason,
+	}
+	if params := requestParameterMap(request); len(params) > 0 {
+		req.Parameters = params
+	}
+	if policy != nil {
+		req.Policy = policy.Name
+		req.Agent.MTSDominates = mtsDominates(agent, policy.MTSLabel)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ext.timeout)
+	defer cancel()
+	resp, err := ext.authorizer.Authorize(ctx, req)
+	if err == nil && resp == nil {
+		err = fmt.Errorf("empty response")
+	}
+	if err != nil {
+		e.log.Warn("external authorizer failed", LogKeyTool, toolName, LogKeyRequestID, requestID, "error", err)
+		return Deny, fmt.Sprintf("external authorizer error: %v", err), fmt.Errorf("%w: %w", policyerrors.ErrExternalAuthorizer, err), true
+	}
+	if !resp.Allow {
+		reason := "denied by external authorizer"
+		if resp.Reason != "" {
+			reason += ": " + resp.Reason
+		}
+		return Deny, reason, policyerrors.ErrExternalDenied, true
+	}
+	switch {
+	case ext.combination == ExternalDenyOverrides:
+		// Both allow: the local reason stands
+		return decision, reason, nil, true
+	case resp.Reason != "":
+		return Allow, "allowed by external authorizer: " + resp.Reason, nil, true
+	default:
+		return Allow, "allowed by external authorizer", nil, true
+	}
+}
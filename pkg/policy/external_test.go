@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// fakeAuthorizer allows the tools in allow, and records the requests it
+// decided.
+type fakeAuthorizer struct {
+	allow    map[string]bool
+	delay    time.Duration
+	requests []*ExternalAuthzRequest
+}
+
+func (a *fakeAuthorizer) Authorize(ctx context.Context, req *ExternalAuthzRequest) (*ExternalAuthzResponse, error) {
+	a.requests = append(a.requests, req)
+	if a.delay > 0 {
+		select {
+		case <-time.After(a.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &ExternalAuthzResponse{Allow: a.allow[req.Tool], Reason: "abac"}, nil
+}
+
+// TestExternalAuthorizer tests the combination of local and external
+// decisions.
+func TestExternalAuthorizer(t *testing.T) {
+	policy := CompilePolicy(
+		"external-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{Tool: "file.read", Action: Allow},
+			{Tool: "file.write", Action: Allow},
+			{Tool: "shell.exec", Action: Deny},
+		},
+		Enforcing,
+		"",
+	)
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}
+
+	// The authorizer allows file.read, shell.exec, and network.fetch
+	allow := map[string]bool{"file.read": true, "shell.exec": true, "network.fetch": true}
+
+	tests := []struct {
+		combination ExternalCombination
+		want        map[string]Decision
+	}{
+		{ExternalDenyOverrides, map[string]Decision{"file.read": Allow, "file.write": Deny, "shell.exec": Deny, "network.fetch": Deny}},
+		{ExternalLocalFirst, map[string]Decision{"file.read": Allow, "file.write": Allow, "shell.exec": Deny, "network.fetch": Allow}},
+		{ExternalOnly, map[string]Decision{"file.read": Allow, "file.write": Deny, "shell.exec": Allow, "network.fetch": Allow}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.combination), func(t *testing.T) {
+			authz := &fakeAuthorizer{allow: allow}
+			engine := NewEngine(WithMode(Enforcing), WithExternalAuthorizer(authz, tt.combination, 0))
+			engine.LoadPolicy("coding-assistant", policy)
+
+			// Twice, so that the second call hits the cache
+			for i := 0; i < 2; i++ {
+				for tool, want := range tt.want {
+					result, err := engine.EvaluateWithResult(context.Background(), agent, tool, map[string]interface{}{"path": "/workspace/a"})
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if result.Decision != want {
+						t.Errorf("%s: expected %v, got %v (%s)", tool, want, result.Decision, result.Reason)
+					}
+					// Only the authorizer denies file.write
+					if tool == "file.write" && want == Deny && !errors.Is(result.Err, policyerrors.ErrExternalDenied) {
+						t.Errorf("%s: expected ErrExternalDenied, got %v", tool, result.Err)
+					}
+				}
+			}
+		})
+	}
+
+	// The request carries the identity, parameters, and local decision
+	authz := &fakeAuthorizer{allow: allow}
+	engine := NewEngine(WithMode(Enforcing), WithExternalAuthorizer(authz, ExternalDenyOverrides, 0))
+	engine.LoadPolicy("coding-assistant", policy)
+	engine.Evaluate(ContextWithRequestID(context.Background(), "req-1"), agent, "file.read", map[string]interface{}{"path": "/workspace/a"})
+	if len(authz.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(authz.requests))
+	}
+	req := authz.requests[0]
+	if req.RequestID != "req-1" || req.Agent.TenantID != "team-a" || req.Policy != "external-policy" ||
+		req.LocalDecision != "ALLOW" || req.Parameters["path"] != "/workspace/a" {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+// TestExternalAuthorizerFailClosed tests that authorizers that time out
+// deny calls.
+func TestExternalAuthorizerFailClosed(t *testing.T) {
+	authz := &fakeAuthorizer{allow: map[string]bool{"file.read": true}, delay: time.Second}
+	engine := NewEngine(WithMode(Enforcing), WithExternalAuthorizer(authz, ExternalDenyOverrides, 10*time.Millisecond))
+	engine.LoadPolicy("coding-assistant", CompilePolicy(
+		"external-policy",
+		[]string{"coding-assistant"},
+		Allow,
+		nil,
+		Enforcing,
+		"",
+	))
+
+	result, err := engine.EvaluateWithResult(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Decision != Deny || !errors.Is(result.Err, policyerrors.ErrExternalAuthorizer) || !errors.Is(result.Err, context.DeadlineExceeded) {
+		t.Errorf("expected a fail-closed denial, got %v (%v)", result.Decision, result.Err)
+	}
+
+	if _, err := ParseExternalCombination("first-match"); err == nil {
+		t.Error("expected an invalid combination to be rejected")
+	}
+}
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FaultPoint names a point of the engine or controller where faults can be
+// injected, to test failure modes deterministically.
+type FaultPoint string
+
+const (
+	// FaultCache fails decision cache lookups and stores. A failed cache
+	// is bypassed: calls are evaluated afresh and nothing is cached.
+	FaultCache FaultPoint = "cache"
+
+	// FaultOPAEval delays or fails OPA queries. A failed query denies the
+	// call with ErrOPAEvaluation (fail closed); one delayed past the
+	// evaluation timeout returns ErrEvaluationCancelled.
+	FaultOPAEval FaultPoint = "opa-eval"
+
+	// FaultAuditSink stalls or fails audit emission. Decisions are only
+	// returned once audited, so a stall delays them; a failed emission
+	// loses the event but not the decision.
+	FaultAuditSink FaultPoint = "audit-sink"
+
+	// FaultControllerSync fails AgentPolicy reconciles as if the API
+	// server were unreachable. Loaded policies stay in force and the
+	// reconcile is retried.
+	FaultControllerSync FaultPoint = "controller-sync"
+)
+
+// Fault is the failure injected at a fault point.
+type Fault struct {
+	// Delay stalls the point, or until its context is done
+	Delay time.Duration
+
+	// Err fails the point after the delay
+	Err error
+
+	// Count limits the fault to its next Count hits; zero injects it
+	// until cleared
+	Count int
+}
+
+// FaultInjector holds the faults to inject. It is meant for tests: pass
+// it to the engine WithFaults, and to the router in PolicyConfig.Faults.
+// A nil injector injects nothing.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults map[FaultPoint]Fault
+	hits   map[FaultPoint]int
+}
+
+// NewFaultInjector returns an injector with no faults set.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		faults: make(map[FaultPoint]Fault),
+		hits:   make(map[FaultPoint]int),
+	}
+}
+
+// WithFaults injects the faults set on f into the engine.
+func WithFaults(f *FaultInjector) Option {
+	return func(e *Engine) {
+		e.faults = f
+	}
+}
+
+// Set injects fault at point, replacing any fault set there.
+func (f *FaultInjector) Set(point FaultPoint, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[point] = fault
+}
+
+// Clear removes the fault at point.
+func (f *FaultInjector) Clear(point FaultPoint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.faults, point)
+}
+
+// Hits returns how many times a fault was injected at point.
+func (f *FaultInjector) Hits(point FaultPoint) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[point]
+}
+
+// Inject injects the fault set at point, if any: it waits out the fault's
+// delay, or until ctx is done, and returns its error or ctx's.
+func (f *FaultInjector) Inject(ctx context.Context, point FaultPoint) error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	fault, ok := f.faults[point]
+	if ok {
+		f.hits[point]++
+		if fault.Count > 0 {
+			if fault.Count--; fault.Count == 0 {
+				delete(f.faults, point)
+			} else {
+				f.faults[point] = fault
+			}
+		}
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fault.Err
+}
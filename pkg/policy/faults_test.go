@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestFaultInjector tests fault delays, errors, and counts.
+func TestFaultInjector(t *testing.T) {
+	var nilInjector *FaultInjector
+	if err := nilInjector.Inject(context.Background(), FaultCache); err != nil {
+		t.Fatalf("expected a nil injector to inject nothing, got %v", err)
+	}
+
+	f := NewFaultInjector()
+	boom := errors.New("boom")
+	f.Set(FaultCache, Fault{Err: boom, Count: 2})
+	for i := 0; i < 3; i++ {
+		err := f.Inject(context.Background(), FaultCache)
+		if want := i < 2; (err != nil) != want {
+			t.Errorf("hit %d: expected fault %v, got %v", i, want, err)
+		}
+	}
+	if hits := f.Hits(FaultCache); hits != 2 {
+		t.Errorf("expected 2 hits, got %d", hits)
+	}
+
+	// Delays end with the context
+	f.Set(FaultOPAEval, Fault{Delay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.Inject(ctx, FaultOPAEval); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the delay to end at the deadline, got %v", err)
+	}
+	f.Clear(FaultOPAEval)
+	if err := f.Inject(context.Background(), FaultOPAEval); err != nil {
+		t.Errorf("expected no fault after Clear, got %v", err)
+	}
+}
+
+// TestFaultCache verifies a failed cache is bypassed, not trusted.
+func TestFaultCache(t *testing.T) {
+	faults := NewFaultInjector()
+	engine := NewEngine(WithMode(Enforcing), WithFaults(faults))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("cache-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	faults.Set(FaultCache, Fault{Err: errors.New("cache unavailable")})
+	for i := 0; i < 2; i++ {
+		result, err := engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Decision != Allow || result.Cached {
+			t.Errorf("call %d: expected a fresh allow, got %v (cached %v)", i, result.Decision, result.Cached)
+		}
+	}
+	if hits, misses, _ := engine.CacheStats(); hits != 0 || misses != 0 {
+		t.Errorf("expected the cache to be bypassed, got %d hits and %d misses", hits, misses)
+	}
+
+	faults.Clear(FaultCache)
+	engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+	result, _ := engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+	if !result.Cached {
+		t.Error("expected the cache to be used once it recovers")
+	}
+}
+
+// TestFaultOPAEval verifies failed OPA queries deny, and slow ones are cut
+// short by the evaluation timeout.
+func TestFaultOPAEval(t *testing.T) {
+	faults := NewFaultInjector()
+	engine := NewEngine(WithMode(Enforcing), WithOPA(true), WithFaults(faults),
+		WithEvaluationTimeout(20*time.Millisecond), WithCache(NewDecisionCache(0)))
+
+	policy := CompilePolicy("opa-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, "")
+	policy.OPAEnabled = true
+	policy.PreparedQuery = &rego.PreparedEvalQuery{}
+	engine.LoadPolicy("coding-assistant", policy)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	faults.Set(FaultOPAEval, Fault{Err: errors.New("query failed")})
+	result, err := engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Decision != Deny || !errors.Is(result.Err, policyerrors.ErrOPAEvaluation) {
+		t.Errorf("expected a fail-closed denial, got %v (%v)", result.Decision, result.Err)
+	}
+
+	faults.Set(FaultOPAEval, Fault{Delay: time.Hour})
+	start := time.Now()
+	_, err = engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+	if !errors.Is(err, ErrEvaluationCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the evaluation to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the timeout to cut the query short, took %v", elapsed)
+	}
+}
+
+// TestFaultAuditSink verifies decisions wait for their audit event, and
+// survive its loss.
+func TestFaultAuditSink(t *testing.T) {
+	faults := NewFaultInjector()
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Enforcing), WithAuditSink(sink), WithFaults(faults))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("audit-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	faults.Set(FaultAuditSink, Fault{Delay: 30 * time.Millisecond, Count: 1})
+	start := time.Now()
+	decision, err := engine.Evaluate(context.Background(), agent, "file.read", nil)
+	if err != nil || decision != Allow {
+		t.Fatalf("expected allow, got %v %v", decision, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected the decision to wait for the stalled audit, took %v", elapsed)
+	}
+	select {
+	case <-sink.Events():
+	default:
+		t.Error("expected the decision to be audited before it was returned")
+	}
+
+	faults.Set(FaultAuditSink, Fault{Err: errors.New("sink down"), Count: 1})
+	if decision, err := engine.Evaluate(context.Background(), agent, "file.write", nil); err != nil || decision != Allow {
+		t.Errorf("expected the decision to survive a lost audit event, got %v %v", decision, err)
+	}
+	select {
+	case event := <-sink.Events():
+		t.Errorf("expected the event to be lost, got %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,282 @@
+// Package gatekeeper exports AgentPolicies as Gatekeeper admission
+// constraints.
+//
+// Clusters that already run OPA Gatekeeper can mirror the tool rules of
+// agent policies at admission: Pods labelled with an agent type declare
+// the tools they will call in an annotation,
+//
+//	metadata:
+//	  labels:
+//	    agents.sandbox.io/agent-type: coding-assistant
+//	  annotations:
+//	    agents.sandbox.io/tools: file.read, file.write
+//
+// and a Pod declaring a tool its agent type's policy denies is rejected
+// before it runs. One ConstraintTemplate, AgentToolPolicy, holds the Rego;
+// each AgentPolicy becomes an AgentToolPolicy Constraint whose parameters
+// are its agent types, default action, and allowed and denied tools.
+// Enforcing policies deny admission and permissive ones warn.
+//
+// Only that subset is expressible: an allowed tool is admitted whatever
+// its constraints, which the router still enforces on each call, and
+// agent selectors, tenant isolation, profiles, rate limits, budgets, and
+// canary and fallback designations have no admission equivalent. Export
+// reports what each policy loses.
+//
+// Usage:
+//
+//	objects, findings := gatekeeper.Export(policies)
+//	gatekeeper.Write(os.Stdout, objects)
+package gatekeeper
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+const (
+	// AgentTypeLabel is the Pod label naming the agent type a Pod runs
+	AgentTypeLabel = "agents.sandbox.io/agent-type"
+
+	// ToolsAnnotation is the Pod annotation listing, comma-separated, the
+	// tools a Pod's agent calls
+	ToolsAnnotation = "agents.sandbox.io/tools"
+
+	// TemplateName is the name of the ConstraintTemplate, and TemplateKind
+	// the kind of its Constraints
+	TemplateName = "agenttoolpolicy"
+	TemplateKind = "AgentToolPolicy"
+)
+
+// templateRego is the Rego of the ConstraintTemplate. Tools are matched
+// exactly, as the engine matches them, and agent types as glob patterns.
+const templateRego = `package agenttoolpolicy
+
+agent_type := input.review.object.metadata.labels["` + AgentTypeLabel + `"]
+
+governed {
+  pattern := input.parameters.agentTypes[_]
+  glob.match(pattern, [], agent_type)
+}
+
+declared[tool] {
+  entry := split(input.review.object.metadata.annotations["` + ToolsAnnotation + `"], ",")[_]
+  tool := trim_space(entry)
+  tool != ""
+}
+
+allowed(tool) {
+  input.parameters.allowedTools[_] == tool
+}
+
+denied(tool) {
+  input.parameters.deniedTools[_] == tool
+}
+
+violation[{"msg": msg}] {
+  governed
+  tool := declared[_]
+  denied(tool)
+  msg := sprintf("AgentPolicy %v denies agent type %v the tool %v", [input.parameters.policy, agent_type, tool])
+}
+
+violation[{"msg": msg}] {
+  governed
+  input.parameters.defaultAction == "deny"
+  tool := declared[_]
+  not allowed(tool)
+  not denied(tool)
+  msg := sprintf("AgentPolicy %v does not allow agent type %v the tool %v", [input.parameters.policy, agent_type, tool])
+}
+`
+
+// Object is an exported Kubernetes object.
+type Object map[string]interface{}
+
+// Finding is a part of an AgentPolicy that has no admission equivalent.
+type Finding struct {
+	// Policy is the AgentPolicy, as "namespace/name"
+	Policy string
+
+	// Message describes what the constraint does not enforce
+	Message string
+
+	// Skipped is set if the policy was not exported at all
+	Skipped bool
+}
+
+func (f Finding) String() string {
+	if f.Skipped {
+		return fmt.Sprintf("%s: not exported: %s", f.Policy, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Policy, f.Message)
+}
+
+// ConstraintTemplate returns the AgentToolPolicy ConstraintTemplate.
+func ConstraintTemplate() Object {
+	return Object{
+		"apiVersion": "templates.gatekeeper.sh/v1",
+		"kind":       "ConstraintTemplate",
+		"metadata":   map[string]interface{}{"name": TemplateName},
+		"spec": map[string]interface{}{
+			"crd": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"names": map[string]interface{}{"kind": TemplateKind},
+					"validation": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"policy":        map[string]interface{}{"type": "string"},
+								"agentTypes":    stringArray(),
+								"defaultAction": map[string]interface{}{"type": "string", "enum": []string{"allow", "deny"}},
+								"allowedTools":  stringArray(),
+								"deniedTools":   stringArray(),
+							},
+						},
+					},
+				},
+			},
+			"targets": []interface{}{
+				map[string]interface{}{
+					"target": "admission.k8s.gatekeeper.sh",
+					"rego":   templateRego,
+				},
+			},
+		},
+	}
+}
+
+func stringArray() map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+}
+
+// invalidNameChars matches the characters Constraint names cannot have.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// Constraint returns the AgentToolPolicy Constraint of an AgentPolicy, and
+// what it does not enforce. It returns nil for policies that cannot be
+// exported.
+func Constraint(ap *agentsv1alpha1.AgentPolicy) (Object, []Finding) {
+	ref := ap.Name
+	if ap.Namespace != "" {
+		ref = ap.Namespace + "/" + ap.Name
+	}
+	finding := func(format string, args ...interface{}) Finding {
+		return Finding{Policy: ref, Message: fmt.Sprintf(format, args...)}
+	}
+
+	spec := &ap.Spec
+	switch {
+	case spec.Canary != nil:
+		return nil, []Finding{{Policy: ref, Message: "canaries apply to a share of sandboxes, which admission cannot select", Skipped: true}}
+	case len(spec.AgentTypes) == 0:
+		return nil, []Finding{{Policy: ref, Message: "no agent types", Skipped: true}}
+	}
+
+	var findings []Finding
+	var allowed, denied []string
+	for _, perm := range spec.ToolPermissions {
+		if perm.Action == agentsv1alpha1.DecisionAllow {
+			allowed = append(allowed, perm.Tool)
+			if perm.Constraints != nil {
+				findings = append(findings, finding("tool %s is admitted without its constraints, which only the router enforces", perm.Tool))
+			}
+		} else {
+			denied = append(denied, perm.Tool)
+		}
+	}
+	sort.Strings(allowed)
+	sort.Strings(denied)
+
+	if spec.AgentSelector != nil {
+		findings = append(findings, finding("agentSelector is not enforced: the constraint applies to every Pod of its agent types"))
+	}
+	if spec.Fallback {
+		findings = append(findings, finding("the fallback designation is not exported"))
+	}
+	if spec.TenantIsolation != nil {
+		findings = append(findings, finding("tenant isolation is not enforced"))
+	}
+	if spec.Profile != nil {
+		findings = append(findings, finding("the behavior profile is not enforced"))
+	}
+	if spec.RateLimit != nil {
+		findings = append(findings, finding("the rate limit is not enforced"))
+	}
+	if spec.Budget != nil {
+		findings = append(findings, finding("the budget is not enforced"))
+	}
+
+	action := "deny"
+	if spec.Mode == agentsv1alpha1.EnforcementModePermissive {
+		action = "warn"
+	}
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(strings.ReplaceAll(ref, "/", ".")), "-"), "-.")
+
+	parameters := map[string]interface{}{
+		"policy":        ref,
+		"agentTypes":    spec.AgentTypes,
+		"defaultAction": string(spec.DefaultAction),
+	}
+	if len(allowed) > 0 {
+		parameters["allowedTools"] = allowed
+	}
+	if len(denied) > 0 {
+		parameters["deniedTools"] = denied
+	}
+	return Object{
+		"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+		"kind":       TemplateKind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"enforcementAction": action,
+			"match": map[string]interface{}{
+				"kinds": []interface{}{
+					map[string]interface{}{"apiGroups": []string{""}, "kinds": []string{"Pod"}},
+				},
+			},
+			"parameters": parameters,
+		},
+	}, findings
+}
+
+// Export returns the ConstraintTemplate and the Constraints of policies,
+// and what they do not enforce.
+func Export(policies []*agentsv1alpha1.AgentPolicy) ([]Object, []Finding) {
+	objects := []Object{ConstraintTemplate()}
+	var findings []Finding
+	for _, ap := range policies {
+		constraint, policyFindings := Constraint(ap)
+		findings = append(findings, policyFindings...)
+		if constraint != nil {
+			objects = append(objects, constraint)
+		}
+	}
+	return objects, findings
+}
+
+// Write writes objects as a multi-document YAML stream.
+func Write(w io.Writer, objects []Object) error {
+	for i, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
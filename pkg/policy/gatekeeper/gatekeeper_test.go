@@ -0,0 +1,86 @@
+package gatekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+func testPolicy(name string, spec agentsv1alpha1.AgentPolicySpec) *agentsv1alpha1.AgentPolicy {
+	return &agentsv1alpha1.AgentPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "Team_A"}, Spec: spec}
+}
+
+// TestExport tests the Constraints of policies and the findings of what
+// they do not enforce.
+func TestExport(t *testing.T) {
+	maxSize := int64(1024)
+	objects, findings := Export([]*agentsv1alpha1.AgentPolicy{
+		testPolicy("coding", agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-*"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			Mode:          agentsv1alpha1.EnforcementModePermissive,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{
+				{Tool: "file.write", Action: agentsv1alpha1.DecisionAllow, Constraints: &agentsv1alpha1.ToolConstraints{MaxSizeBytes: &maxSize}},
+				{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow},
+				{Tool: "shell.execute", Action: agentsv1alpha1.DecisionDeny},
+			},
+			Budget: &agentsv1alpha1.BudgetSpec{},
+		}),
+		testPolicy("coding-v2", agentsv1alpha1.AgentPolicySpec{
+			Canary: &agentsv1alpha1.CanarySpec{Stable: "coding", Percent: 10},
+		}),
+	})
+
+	if len(objects) != 2 || objects[0]["kind"] != "ConstraintTemplate" {
+		t.Fatalf("expected the template and one constraint, got %d objects", len(objects))
+	}
+	constraint := objects[1]
+	if name := constraint["metadata"].(map[string]interface{})["name"]; name != "team-a.coding" {
+		t.Errorf("expected a valid constraint name, got %q", name)
+	}
+	spec := constraint["spec"].(map[string]interface{})
+	if spec["enforcementAction"] != "warn" {
+		t.Errorf("expected a permissive policy to warn, got %v", spec["enforcementAction"])
+	}
+	params := spec["parameters"].(map[string]interface{})
+	if got := params["allowedTools"].([]string); strings.Join(got, ",") != "file.read,file.write" {
+		t.Errorf("unexpected allowed tools %v", got)
+	}
+	if got := params["deniedTools"].([]string); strings.Join(got, ",") != "shell.execute" {
+		t.Errorf("unexpected denied tools %v", got)
+	}
+	if params["defaultAction"] != "deny" || params["policy"] != "Team_A/coding" {
+		t.Errorf("unexpected parameters %v", params)
+	}
+
+	var messages []string
+	for _, f := range findings {
+		messages = append(messages, f.String())
+	}
+	want := []string{
+		"Team_A/coding: tool file.write is admitted without its constraints, which only the router enforces",
+		"Team_A/coding: the budget is not enforced",
+		"Team_A/coding-v2: not exported: canaries apply to a share of sandboxes, which admission cannot select",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s", strings.Join(messages, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, objects); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	docs := strings.Split(buf.String(), "---\n")
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 YAML documents, got %d", len(docs))
+	}
+	var template map[string]interface{}
+	if err := yaml.Unmarshal([]byte(docs[0]), &template); err != nil || !strings.Contains(docs[0], ToolsAnnotation) {
+		t.Errorf("expected the template's Rego to read the tools annotation (%v)", err)
+	}
+}
@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Industrial protocol request parameters read by ModbusConstraint and
+// OPCUAConstraint.
+const (
+	// ModbusFunctionCodeParam is the Modbus function code (e.g., 3 = read holding registers)
+	ModbusFunctionCodeParam = "function_code"
+
+	// ModbusAddressParam is the first register or coil address
+	ModbusAddressParam = "address"
+
+	// ModbusQuantityParam is the number of registers or coils (default 1)
+	ModbusQuantityParam = "quantity"
+
+	// OPCUANodeIDParam is a single OPC-UA node ID (e.g., "ns=2;s=Line1/Temp")
+	OPCUANodeIDParam = "node_id"
+
+	// OPCUANodeIDsParam is a list of OPC-UA node IDs
+	OPCUANodeIDsParam = "node_ids"
+
+	// OPCUAOperationParam is the OPC-UA service used (e.g., "read", "write")
+	OPCUAOperationParam = "operation"
+)
+
+// Modbus protocol limits: addresses are 16-bit, written either 0-based or
+// as register numbers up to 465536, and no request covers more than 2000
+// coils.
+const (
+	maxModbusAddress  = 465536
+	maxModbusQuantity = 2000
+)
+
+// modbusReadFunctionCodes are the Modbus function codes that do not change
+// device state: read coils, discrete inputs, holding and input registers.
+var modbusReadFunctionCodes = map[int]bool{1: true, 2: true, 3: true, 4: true}
+
+// opcuaReadOperations are the OPC-UA services that do not change server state.
+var opcuaReadOperations = map[string]bool{"read": true, "browse": true, "subscribe": true, "history_read": true}
+
+// RegisterRange is an inclusive range of Modbus addresses.
+type RegisterRange struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the addresses [address, address+quantity) all
+// fall within the range.
+func (r RegisterRange) Contains(address, quantity int64) bool {
+	return address >= int64(r.Start) && address+quantity-1 <= int64(r.End)
+}
+
+// ModbusConstraint limits Modbus requests by function code and register
+// address. Addresses are compared as sent in the request, so ranges must use
+// the same addressing convention as the tool (e.g., 0-based PDU addresses or
+// 40001-style register numbers).
+type ModbusConstraint struct {
+	// AllowedFunctionCodes limits the function codes (empty allows any)
+	AllowedFunctionCodes []int
+
+	// AllowedRegisters are the address ranges the whole request span must
+	// fall within one of (empty allows any address)
+	AllowedRegisters []RegisterRange
+
+	// ReadOnly limits requests to the read function codes 1-4
+	ReadOnly bool
+}
+
+// Name implements ConstraintExtension.
+func (c ModbusConstraint) Name() string { return "modbus" }
+
+// Check implements ConstraintExtension.
+func (c ModbusConstraint) Check(agent AgentContext, toolName string, params map[string]interface{}) error {
+	if c.ReadOnly || len(c.AllowedFunctionCodes) > 0 {
+		fc, ok := CoerceInt64(params[ModbusFunctionCodeParam])
+		if !ok {
+			return fmt.Errorf("missing or malformed %s", ModbusFunctionCodeParam)
+		}
+		if c.ReadOnly && !modbusReadFunctionCodes[int(fc)] {
+			return fmt.Errorf("function code %d is not a read", fc)
+		}
+		if len(c.AllowedFunctionCodes) > 0 && !containsInt(c.AllowedFunctionCodes, int(fc)) {
+			return fmt.Errorf("function code %d is not allowed", fc)
+		}
+	}
+
+	if len(c.AllowedRegisters) > 0 {
+		address, ok := CoerceInt64(params[ModbusAddressParam])
+		if !ok || address < 0 || address > maxModbusAddress {
+			return fmt.Errorf("missing or malformed %s", ModbusAddressParam)
+		}
+		quantity := int64(1)
+		if v, present := params[ModbusQuantityParam]; present {
+			if quantity, ok = CoerceInt64(v); !ok || quantity < 1 || quantity > maxModbusQuantity {
+				return fmt.Errorf("malformed %s", ModbusQuantityParam)
+			}
+		}
+		allowed := false
+		for _, r := range c.AllowedRegisters {
+			if r.Contains(address, quantity) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("addresses %d-%d are outside the allowed ranges", address, address+quantity-1)
+		}
+	}
+
+	return nil
+}
+
+// OPCUAConstraint limits OPC-UA requests by node ID and operation.
+type OPCUAConstraint struct {
+	// AllowedNodeIDs are the node IDs the request may address. An entry
+	// ending in "*" matches node IDs by prefix (e.g., "ns=2;s=Line1/*").
+	// Namespace 0 may be written without "ns=0;". Empty allows any node.
+	AllowedNodeIDs []string
+
+	// ReadOnly limits requests to read, browse, subscribe, and history_read
+	ReadOnly bool
+}
+
+// Name implements ConstraintExtension.
+func (c OPCUAConstraint) Name() string { return "opcua" }
+
+// Check implements ConstraintExtension.
+func (c OPCUAConstraint) Check(agent AgentContext, toolName string, params map[string]interface{}) error {
+	if c.ReadOnly {
+		op, _ := params[OPCUAOperationParam].(string)
+		if !opcuaReadOperations[strings.ToLower(strings.TrimSpace(op))] {
+			return fmt.Errorf("operation %q is not a read", op)
+		}
+	}
+
+	if len(c.AllowedNodeIDs) > 0 {
+		nodes, ok := opcuaNodeIDs(params)
+		if !ok {
+			return fmt.Errorf("missing or malformed %s", OPCUANodeIDParam)
+		}
+		for _, node := range nodes {
+			if !c.allowsNode(node) {
+				return fmt.Errorf("node %q is not allowed", node)
+			}
+		}
+	}
+
+	return nil
+}
+
+// allowsNode reports whether a node ID matches AllowedNodeIDs.
+func (c OPCUAConstraint) allowsNode(node string) bool {
+	node = NormalizeNodeID(node)
+	for _, allowed := range c.AllowedNodeIDs {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(node, NormalizeNodeID(prefix)) {
+				return true
+			}
+		} else if node == NormalizeNodeID(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeNodeID converts an OPC-UA node ID to the form constraints
+// compare: surrounding whitespace removed and the default namespace
+// written out ("i=85" -> "ns=0;i=85").
+func NormalizeNodeID(node string) string {
+	node = strings.TrimSpace(node)
+	if node == "" || strings.HasPrefix(node, "ns=") || strings.HasPrefix(node, "nsu=") {
+		return node
+	}
+	return "ns=0;" + node
+}
+
+// opcuaNodeIDs collects the node IDs a request addresses from node_id and
+// node_ids. Returns false if there are none or any is not a string.
+func opcuaNodeIDs(params map[string]interface{}) ([]string, bool) {
+	var nodes []string
+	if v, present := params[OPCUANodeIDParam]; present {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		nodes = append(nodes, s)
+	}
+	switch list := params[OPCUANodeIDsParam].(type) {
+	case nil:
+	case []string:
+		nodes = append(nodes, list...)
+	case []interface{}:
+		for _, v := range list {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			nodes = append(nodes, s)
+		}
+	default:
+		return nil, false
+	}
+	for _, n := range nodes {
+		if strings.TrimSpace(n) == "" {
+			return nil, false
+		}
+	}
+	return nodes, len(nodes) > 0
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
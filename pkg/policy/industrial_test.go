@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestModbusConstraint verifies function code and register range checks
+func TestModbusConstraint(t *testing.T) {
+	c := ModbusConstraint{
+		AllowedFunctionCodes: []int{3, 4, 6},
+		AllowedRegisters:     []RegisterRange{{Start: 40001, End: 40050}},
+		ReadOnly:             true,
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		allowed bool
+	}{
+		{"read in range", map[string]interface{}{"function_code": 3, "address": 40001, "quantity": 10}, true},
+		{"float params from JSON", map[string]interface{}{"function_code": 3.0, "address": 40010.0}, true},
+		{"span ends at range end", map[string]interface{}{"function_code": 4, "address": 40041, "quantity": 10}, true},
+		{"span past range end", map[string]interface{}{"function_code": 4, "address": 40045, "quantity": 10}, false},
+		{"below range", map[string]interface{}{"function_code": 3, "address": 40000}, false},
+		{"write single register", map[string]interface{}{"function_code": 6, "address": 40001}, false},
+		{"read coils not allowed", map[string]interface{}{"function_code": 1, "address": 40001}, false},
+		{"missing function code", map[string]interface{}{"address": 40001}, false},
+		{"missing address", map[string]interface{}{"function_code": 3}, false},
+		{"zero quantity", map[string]interface{}{"function_code": 3, "address": 40001, "quantity": 0}, false},
+		{"negative quantity", map[string]interface{}{"function_code": 3, "address": 40050, "quantity": -100}, false},
+		{"quantity overflow", map[string]interface{}{"function_code": 3, "address": 40001, "quantity": int64(1) << 62}, false},
+		{"address out of protocol range", map[string]interface{}{"function_code": 3, "address": int64(-1) << 62}, false},
+	}
+
+	for _, tt := range tests {
+		err := c.Check(AgentContext{}, "modbus.read", tt.params)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+}
+
+// TestOPCUAConstraint verifies node ID allowlists and read-only operations
+func TestOPCUAConstraint(t *testing.T) {
+	c := OPCUAConstraint{
+		AllowedNodeIDs: []string{"ns=2;s=Line1/*", "i=2258"},
+		ReadOnly:       true,
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		allowed bool
+	}{
+		{"prefix match", map[string]interface{}{"operation": "read", "node_id": "ns=2;s=Line1/Temp"}, true},
+		{"default namespace", map[string]interface{}{"operation": "Browse", "node_id": "ns=0;i=2258"}, true},
+		{"node list", map[string]interface{}{"operation": "read", "node_ids": []interface{}{"i=2258", "ns=2;s=Line1/Pressure"}}, true},
+		{"node list with disallowed node", map[string]interface{}{"operation": "read", "node_ids": []interface{}{"i=2258", "ns=2;s=Line2/Valve"}}, false},
+		{"other namespace", map[string]interface{}{"operation": "read", "node_id": "ns=3;s=Line1/Temp"}, false},
+		{"write", map[string]interface{}{"operation": "write", "node_id": "ns=2;s=Line1/Setpoint"}, false},
+		{"call method", map[string]interface{}{"operation": "call", "node_id": "i=2258"}, false},
+		{"missing operation", map[string]interface{}{"node_id": "i=2258"}, false},
+		{"missing node", map[string]interface{}{"operation": "read"}, false},
+		{"non-string node", map[string]interface{}{"operation": "read", "node_id": 2258}, false},
+	}
+
+	for _, tt := range tests {
+		err := c.Check(AgentContext{}, "opcua.read", tt.params)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+
+	if got := NormalizeNodeID(" i=85 "); got != "ns=0;i=85" {
+		t.Errorf("expected ns=0;i=85, got %q", got)
+	}
+}
+
+// TestEngineConstraintExtensions verifies extensions are enforced on every
+// request rather than served from the decision cache
+func TestEngineConstraintExtensions(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("control-agent", CompilePolicy(
+		"test-policy",
+		[]string{"control-agent"},
+		Deny,
+		[]ToolPermission{
+			{
+				Tool:   "modbus.read",
+				Action: Allow,
+				Constraints: &ToolConstraints{
+					Extensions: []ConstraintExtension{ModbusConstraint{
+						AllowedRegisters: []RegisterRange{{Start: 30001, End: 30100}},
+						ReadOnly:         true,
+					}},
+				},
+			},
+		},
+		Enforcing,
+		"",
+	))
+
+	agent := AgentContext{AgentType: "control-agent"}
+
+	read := map[string]interface{}{"function_code": 4, "address": 30001}
+	if decision, reason := engine.Evaluate(context.Background(), agent, "modbus.read", read); decision != Allow {
+		t.Fatalf("expected read to be allowed, got %v: %s", decision, reason)
+	}
+
+	write := map[string]interface{}{"function_code": 16, "address": 30001}
+	if decision, _ := engine.Evaluate(context.Background(), agent, "modbus.read", write); decision != Deny {
+		t.Errorf("expected write after cached read to be denied, got %v", decision)
+	}
+
+	if decision, _ := engine.Evaluate(context.Background(), agent, "modbus.read", "unstructured"); decision != Deny {
+		t.Errorf("expected request without parameters to be denied, got %v", decision)
+	}
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Invalidation announces that the cached decisions for an agent type are
+// stale, because its policy changed on some replica.
+type Invalidation struct {
+	// AgentType is the agent type or pattern whose policy changed. Decisions
+	// cached under the "<agentType>:" prefix are invalidated; patterns
+	// (including FallbackAgentType) invalidate the entire cache.
+	AgentType string
+
+	// Origin identifies the engine that published the invalidation, so that
+	// it can ignore its own. Empty if unknown, in which case every engine
+	// applies it.
+	Origin string
+}
+
+// InvalidationBus broadcasts cache invalidations between router replicas.
+// Each replica caches decisions locally, so a policy update applied on one
+// replica (by its controller, webhook, or admin API) would otherwise leave
+// stale decisions cached on the others until they expire.
+//
+// Delivery is best-effort and at-least-once: an invalidation may be
+// delivered more than once or coalesced with others for the same agent
+// type, which is harmless since invalidation is idempotent.
+type InvalidationBus interface {
+	// Publish broadcasts an invalidation to every subscriber. It must not
+	// block on the network; implementations queue and deliver in the
+	// background, reporting their own delivery errors.
+	Publish(ctx context.Context, inv Invalidation) error
+
+	// Subscribe registers a handler called for each invalidation received.
+	// Call the returned function to unsubscribe.
+	Subscribe(handler func(Invalidation)) (cancel func())
+}
+
+// WithInvalidationBus connects the engine to a cross-replica invalidation
+// bus. Policies loaded or removed on this engine are published to the bus,
+// and invalidations published by other engines clear the matching cached
+// decisions. The engine stays subscribed for its lifetime.
+func WithInvalidationBus(bus InvalidationBus) Option {
+	return func(e *Engine) {
+		e.bus = bus
+	}
+}
+
+// subscribeInvalidations subscribes the engine to its invalidation bus,
+// ignoring the invalidations it published itself.
+func (e *Engine) subscribeInvalidations() {
+	e.origin = newOrigin()
+	e.bus.Subscribe(func(inv Invalidation) {
+		if inv.Origin != "" && inv.Origin == e.origin {
+			return
+		}
+		e.invalidateAgentType(inv.AgentType)
+	})
+}
+
+// publishInvalidation announces a local policy change to other replicas.
+func (e *Engine) publishInvalidation(agentType string) {
+	if e.bus == nil {
+		return
+	}
+	// Publish does not block; delivery errors are the bus's to report
+	if err := e.bus.Publish(context.Background(), Invalidation{AgentType: agentType, Origin: e.origin}); err != nil {
+		e.log.Warn("failed to publish cache invalidation", LogKeyAgentType, agentType, "error", err)
+	}
+}
+
+// newOrigin returns a random identifier for an engine instance.
+func newOrigin() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// LocalInvalidationBus is an in-process InvalidationBus. It connects engines
+// in the same process, e.g. in tests or when several engines share one
+// replica; cross-replica deployments use a bus backed by shared state such
+// as a Kubernetes ConfigMap.
+type LocalInvalidationBus struct {
+	mu       sync.RWMutex
+	handlers map[int]func(Invalidation)
+	next     int
+}
+
+// NewLocalInvalidationBus creates an in-process invalidation bus.
+func NewLocalInvalidationBus() *LocalInvalidationBus {
+	return &LocalInvalidationBus{handlers: make(map[int]func(Invalidation))}
+}
+
+// Publish delivers the invalidation to every subscriber synchronously.
+func (b *LocalInvalidationBus) Publish(ctx context.Context, inv Invalidation) error {
+	b.mu.RLock()
+	handlers := make([]func(Invalidation), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(inv)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for invalidations.
+func (b *LocalInvalidationBus) Subscribe(handler func(Invalidation)) func() {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.handlers, id)
+			b.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestInvalidationBus verifies a policy update on one engine clears the
+// cached decisions of the same agent type on engines sharing the bus
+func TestInvalidationBus(t *testing.T) {
+	bus := NewLocalInvalidationBus()
+	replicaA := NewEngine(WithMode(Enforcing), WithInvalidationBus(bus))
+	replicaB := NewEngine(WithMode(Enforcing), WithInvalidationBus(bus))
+
+	policy := CompilePolicy("test-policy", []string{"coding-assistant", "research-agent"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, "")
+	for _, e := range []*Engine{replicaA, replicaB} {
+		e.LoadPolicy("coding-assistant", policy)
+		e.LoadPolicy("research-agent", policy)
+	}
+	for _, agentType := range []string{"coding-assistant", "research-agent"} {
+		replicaB.Evaluate(context.Background(), AgentContext{AgentType: agentType}, "file.read", nil)
+	}
+	if size := replicaB.Cache().Size(); size != 2 {
+		t.Fatalf("expected 2 cached decisions, got %d", size)
+	}
+
+	replicaA.LoadPolicy("coding-assistant", policy)
+	if size := replicaB.Cache().Size(); size != 1 {
+		t.Errorf("expected only coding-assistant decisions invalidated, got %d entries", size)
+	}
+
+	// Patterns invalidate everything
+	replicaA.LoadPolicy("research-*", policy)
+	if size := replicaB.Cache().Size(); size != 0 {
+		t.Errorf("expected pattern update to clear the cache, got %d entries", size)
+	}
+
+	// An engine ignores its own invalidations
+	replicaB.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", nil)
+	bus.Publish(context.Background(), Invalidation{AgentType: "coding-assistant", Origin: replicaB.origin})
+	if size := replicaB.Cache().Size(); size != 1 {
+		t.Errorf("expected own invalidation to be ignored, got %d entries", size)
+	}
+}
+
+// TestOnPolicyChange verifies that change callbacks see local and remote
+// policy changes, canary and mode changes, and stop once unregistered
+func TestOnPolicyChange(t *testing.T) {
+	bus := NewLocalInvalidationBus()
+	replicaA := NewEngine(WithMode(Enforcing), WithInvalidationBus(bus))
+	replicaB := NewEngine(WithMode(Enforcing), WithInvalidationBus(bus))
+
+	var changed []string
+	cancel := replicaB.OnPolicyChange(func(agentType string) {
+		// The engine's own cache is already invalidated
+		if size := replicaB.Cache().Size(); size != 0 {
+			t.Errorf("%s: expected the cache to be invalidated first, got %d entries", agentType, size)
+		}
+		changed = append(changed, agentType)
+	})
+
+	policy := CompilePolicy("test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, "")
+	replicaB.LoadPolicy("coding-assistant", policy)
+	replicaB.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", nil)
+	replicaA.LoadPolicy("coding-assistant", policy)
+	replicaB.LoadCanary("test-policy", policy, 10)
+	replicaB.SetMode(Permissive)
+	replicaB.RemovePolicy("coding-assistant")
+
+	want := []string{"coding-assistant", "coding-assistant", FallbackAgentType, FallbackAgentType, "coding-assistant"}
+	if len(changed) != len(want) {
+		t.Fatalf("expected changes %v, got %v", want, changed)
+	}
+	for i := range want {
+		if changed[i] != want[i] {
+			t.Errorf("change %d: expected %s, got %s", i, want[i], changed[i])
+		}
+	}
+
+	cancel()
+	replicaB.LoadPolicy("coding-assistant", policy)
+	if len(changed) != len(want) {
+		t.Errorf("expected no calls after cancel, got %v", changed[len(want):])
+	}
+}
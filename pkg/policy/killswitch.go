@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// KillSwitch globally disables a tool: every call to it is denied, under
+// every policy and in every enforcement mode, until the switch is lifted or
+// expires. It is meant for incidents, when editing each policy that allows
+// the tool would be too slow.
+type KillSwitch struct {
+	// Source identifies the switch, such as the name of its ToolKillSwitch
+	Source string
+
+	// Tool is the canonical name of the disabled tool
+	Tool string
+
+	// Reason explains the switch to operators and agents
+	Reason string
+
+	// ExpiresAt lifts the switch once passed (zero means never)
+	ExpiresAt time.Time
+}
+
+// active reports whether the switch is in effect at now.
+func (k KillSwitch) active(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// killSwitchStore holds the kill switches of an engine by source.
+type killSwitchStore struct {
+	mu       sync.RWMutex
+	switches map[string]KillSwitch
+}
+
+// killed returns the active switch disabling tool, the oldest source first
+// if several do.
+func (s *killSwitchStore) killed(tool string, now time.Time) (KillSwitch, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found KillSwitch
+	var ok bool
+	for _, k := range s.switches {
+		if k.Tool == tool && k.active(now) && (!ok || k.Source < found.Source) {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+// KillTool disables a tool for every agent until LiftKill(source) is
+// called, replacing any switch of the same source. Cached decisions are
+// dropped here and on other replicas, and subscribers are notified, so
+// that discovery stops offering the tool at once.
+func (e *Engine) KillTool(k KillSwitch) {
+	e.kills.mu.Lock()
+	if e.kills.switches == nil {
+		e.kills.switches = make(map[string]KillSwitch)
+	}
+	e.kills.switches[k.Source] = k
+	e.kills.mu.Unlock()
+
+	e.log.Warn("tool disabled by kill switch", LogKeyTool, k.Tool, "source", k.Source, "reason", k.Reason)
+	e.invalidateKillSwitches()
+}
+
+// LiftKill lifts the kill switch of source, if any.
+func (e *Engine) LiftKill(source string) {
+	e.kills.mu.Lock()
+	k, ok := e.kills.switches[source]
+	delete(e.kills.switches, source)
+	e.kills.mu.Unlock()
+	if ok {
+		e.log.Info("kill switch lifted", LogKeyTool, k.Tool, "source", source)
+		e.invalidateKillSwitches()
+	}
+}
+
+// ReplaceKillSwitches atomically replaces all kill switches, as a
+// controller reconciling every switch at once does.
+func (e *Engine) ReplaceKillSwitches(switches []KillSwitch) {
+	replaced := make(map[string]KillSwitch, len(switches))
+	for _, k := range switches {
+		replaced[k.Source] = k
+	}
+	e.kills.mu.Lock()
+	e.kills.switches = replaced
+	e.kills.mu.Unlock()
+	e.invalidateKillSwitches()
+}
+
+// KillSwitches returns the kill switches in effect, sorted by source.
+func (e *Engine) KillSwitches() []KillSwitch {
+	now := time.Now()
+	e.kills.mu.RLock()
+	switches := make([]KillSwitch, 0, len(e.kills.switches))
+	for _, k := range e.kills.switches {
+		if k.active(now) {
+			switches = append(switches, k)
+		}
+	}
+	e.kills.mu.RUnlock()
+	sort.Slice(switches, func(i, j int) bool {
+		return switches[i].Source < switches[j].Source
+	})
+	return switches
+}
+
+// checkKillSwitch returns the reason and cause of the denial of a call to
+// a disabled tool, and a nil error for other tools.
+func (e *Engine) checkKillSwitch(toolName string) (string, error) {
+	k, ok := e.kills.killed(toolName, time.Now())
+	if !ok {
+		return "", nil
+	}
+	reason := fmt.Sprintf("tool %s disabled by kill switch %s", toolName, k.Source)
+	if k.Reason != "" {
+		reason += ": " + k.Reason
+	}
+	return reason, fmt.Errorf("%w: %s", policyerrors.ErrToolDisabled, k.Source)
+}
+
+// killedResult is the result of a call denied by a kill switch, which the
+// enforcement mode does not soften.
+func killedResult(policy *CompiledPolicy, reason string, denyErr error) *EvaluationResult {
+	result := &EvaluationResult{Decision: Deny, Reason: reason, Message: reason, Err: denyErr}
+	if policy != nil {
+		result.Policy = policy.Name
+	}
+	return result
+}
+
+// invalidateKillSwitches drops cached decisions after a kill switch
+// changes. Switches are checked before the cache, so this is for the
+// subscribers and replicas that hold on to decisions of their own.
+func (e *Engine) invalidateKillSwitches() {
+	e.invalidateAgentType(FallbackAgentType)
+	e.publishInvalidation(FallbackAgentType)
+	e.notifier.notify()
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestKillSwitch tests that a kill switch denies a tool under every policy
+// and mode, including calls whose allow was cached, until it is lifted or
+// expires.
+func TestKillSwitch(t *testing.T) {
+	engine := NewEngine(WithMode(Permissive))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("team", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "network.fetch", Action: Allow}, {Tool: "file.read", Action: Allow}}, Enforcing, ""))
+	engine.LoadPolicy(FallbackAgentType, CompilePolicy("fallback", []string{FallbackAgentType}, Allow, nil, Enforcing, ""))
+	changed, cancel := engine.Subscribe()
+	defer cancel()
+
+	evaluate := func(agentType, tool string) *EvaluationResult {
+		t.Helper()
+		result, err := engine.EvaluateWithResult(context.Background(), AgentContext{AgentType: agentType}, tool, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// Cache an allow
+	evaluate("coding-assistant", "network.fetch")
+	if result := evaluate("coding-assistant", "network.fetch"); result.Decision != Allow || !result.Cached {
+		t.Fatalf("expected a cached allow, got %+v", result)
+	}
+
+	engine.KillTool(KillSwitch{Source: "incident-42", Tool: "network.fetch", Reason: "exfiltration"})
+	select {
+	case <-changed:
+	default:
+		t.Error("expected subscribers to be notified of the kill switch")
+	}
+	for _, agentType := range []string{"coding-assistant", "data-analyst"} {
+		result := evaluate(agentType, "network.fetch")
+		if result.Decision != Deny || !errors.Is(result.Err, policyerrors.ErrToolDisabled) {
+			t.Errorf("%s: expected network.fetch to be denied by the kill switch, got %+v", agentType, result)
+		}
+	}
+	if result := evaluate("coding-assistant", "file.read"); result.Decision != Allow {
+		t.Errorf("expected other tools to stay allowed, got %+v", result)
+	}
+	if _, tools, _ := engine.AllowedTools(AgentContext{AgentType: "coding-assistant"}); len(tools) != 1 || tools[0].Tool != "file.read" {
+		t.Errorf("expected discovery to omit the disabled tool, got %v", tools)
+	}
+
+	engine.LiftKill("incident-42")
+	if result := evaluate("coding-assistant", "network.fetch"); result.Decision != Allow {
+		t.Errorf("expected network.fetch to be allowed once the switch is lifted, got %+v", result)
+	}
+
+	engine.ReplaceKillSwitches([]KillSwitch{{Source: "expired", Tool: "network.fetch", ExpiresAt: time.Now().Add(-time.Second)}})
+	if result := evaluate("coding-assistant", "network.fetch"); result.Decision != Allow {
+		t.Errorf("expected an expired switch to be lifted, got %+v", result)
+	}
+	if switches := engine.KillSwitches(); len(switches) != 0 {
+		t.Errorf("expected no switches in effect, got %v", switches)
+	}
+}
@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// LabelCapacity is the number of distinct two-category MTS labels.
+const LabelCapacity = (MaxCategory + 1) * MaxCategory / 2
+
+// DefaultLabelWarnThreshold is the share of LabelCapacity allocated beyond
+// which a LabelAllocator warns of exhaustion.
+const DefaultLabelWarnThreshold = 0.8
+
+// LabelAllocator allocates tenants MTS labels of two categories, like
+// GenerateMTSLabel, that no other tenant holds.
+//
+// GenerateMTSLabel hashes tenant IDs into the category pairs, so a large
+// fleet has tenants with the same label, which isolates them from nobody
+// but each other. The allocator gives each tenant its hashed label while it
+// is free, and falls back to the next free label in sequence when another
+// tenant holds it. Allocations are meant to be persisted (see Assignments)
+// and loaded back, so that tenants keep their labels across restarts.
+type LabelAllocator struct {
+	// WarnThreshold is the share of LabelCapacity allocated beyond which
+	// allocations log a warning (default: DefaultLabelWarnThreshold)
+	WarnThreshold float64
+
+	log *slog.Logger
+
+	mu     sync.Mutex
+	labels map[string][2]int
+	owners map[[2]int]string
+	next   [2]int // where the sequential search resumes
+}
+
+// NewLabelAllocator returns an allocator with no labels allocated, which
+// logs collisions and exhaustion warnings to logger (slog.Default if nil).
+func NewLabelAllocator(logger *slog.Logger) *LabelAllocator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LabelAllocator{
+		WarnThreshold: DefaultLabelWarnThreshold,
+		log:           logger,
+		labels:        make(map[string][2]int),
+		owners:        make(map[[2]int]string),
+		next:          [2]int{0, 1},
+	}
+}
+
+// Load loads persisted allocations, as tenant IDs to labels. A label held
+// by several tenants is kept by the first of them in sorted order; the
+// others, and tenants with labels that are not of two categories, are
+// returned, and are allocated new labels on their next Allocate.
+func (a *LabelAllocator) Load(assignments map[string]string) []string {
+	tenants := make([]string, 0, len(assignments))
+	for tenant := range assignments {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rejected []string
+	for _, tenant := range tenants {
+		pair, ok := labelPair(assignments[tenant])
+		if !ok {
+			rejected = append(rejected, tenant)
+			continue
+		}
+		if owner, taken := a.owners[pair]; taken && owner != tenant {
+			a.log.Warn("MTS label collision", "tenant", tenant, "owner", owner, "label", assignments[tenant])
+			rejected = append(rejected, tenant)
+			continue
+		}
+		if previous, ok := a.labels[tenant]; ok {
+			delete(a.owners, previous)
+		}
+		a.labels[tenant] = pair
+		a.owners[pair] = tenant
+	}
+	return rejected
+}
+
+// Allocate returns the label of a tenant, allocating it one if it has none:
+// its hashed label if free, or else the next free label in sequence.
+// Returns ErrCategoriesExhausted if every label is allocated.
+func (a *LabelAllocator) Allocate(tenantID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if pair, ok := a.labels[tenantID]; ok {
+		return pairLabel(pair).String(), nil
+	}
+
+	hashed := GenerateMTSLabel(tenantID)
+	pair := [2]int{hashed.Categories[0], hashed.Categories[1]}
+	if owner, taken := a.owners[pair]; taken {
+		var ok bool
+		if pair, ok = a.nextFree(); !ok {
+			return "", fmt.Errorf("%w: no MTS label left for tenant %q", ErrCategoriesExhausted, tenantID)
+		}
+		a.log.Warn("MTS label hash collision, allocated sequentially",
+			"tenant", tenantID, "owner", owner, "hashed", hashed.String(), "label", pairLabel(pair).String())
+	}
+	a.labels[tenantID] = pair
+	a.owners[pair] = tenantID
+
+	threshold := a.WarnThreshold
+	if threshold <= 0 {
+		threshold = DefaultLabelWarnThreshold
+	}
+	if used := len(a.owners); float64(used) >= threshold*LabelCapacity {
+		a.log.Warn("MTS label space nearing exhaustion", "allocated", used, "capacity", LabelCapacity)
+	}
+	return pairLabel(pair).String(), nil
+}
+
+// nextFree returns the next free category pair in sequence, resuming after
+// the last one it returned.
+func (a *LabelAllocator) nextFree() ([2]int, bool) {
+	pair := a.next
+	for i := 0; i < LabelCapacity; i++ {
+		current := pair
+		if pair[1]++; pair[1] > MaxCategory {
+			pair[0]++
+			pair[1] = pair[0] + 1
+			if pair[0] >= MaxCategory {
+				pair = [2]int{0, 1}
+			}
+		}
+		if _, taken := a.owners[current]; !taken {
+			a.next = pair
+			return current, true
+		}
+	}
+	return [2]int{}, false
+}
+
+// Release frees the label of a tenant.
+func (a *LabelAllocator) Release(tenantID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if pair, ok := a.labels[tenantID]; ok {
+		delete(a.owners, pair)
+		delete(a.labels, tenantID)
+	}
+}
+
+// Assignments returns the allocated labels, as tenant IDs to labels.
+func (a *LabelAllocator) Assignments() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	assignments := make(map[string]string, len(a.labels))
+	for tenant, pair := range a.labels {
+		assignments[tenant] = pairLabel(pair).String()
+	}
+	return assignments
+}
+
+// Usage returns the number of labels allocated and LabelCapacity.
+func (a *LabelAllocator) Usage() (allocated, capacity int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.owners), LabelCapacity
+}
+
+// labelPair returns the categories of a two-category label.
+func labelPair(label string) ([2]int, bool) {
+	l, err := ParseMTSLabel(label)
+	if err != nil || l.Sensitivity != DefaultSensitivity || l.High() != DefaultSensitivity || len(l.Categories) != 2 {
+		return [2]int{}, false
+	}
+	return [2]int{l.Categories[0], l.Categories[1]}, true
+}
+
+// pairLabel returns the label of a category pair.
+func pairLabel(pair [2]int) *MTSLabel {
+	return &MTSLabel{Sensitivity: DefaultSensitivity, Categories: []int{pair[0], pair[1]}}
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestLabelAllocator tests that tenants whose hashed labels collide are
+// allocated distinct labels, and that persisted labels are kept.
+func TestLabelAllocator(t *testing.T) {
+	var logs bytes.Buffer
+	alloc := NewLabelAllocator(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	label, err := alloc.Allocate("team-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := GenerateMTSLabel("team-a").String(); label != want {
+		t.Errorf("expected the hashed label %s, got %s", want, label)
+	}
+
+	// team-b's hashed label is held by a persisted tenant
+	hashed := GenerateMTSLabel("team-b").String()
+	if rejected := alloc.Load(map[string]string{"legacy": hashed}); len(rejected) != 0 {
+		t.Fatalf("expected no rejected tenants, got %v", rejected)
+	}
+	label, err = alloc.Allocate("team-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if label == hashed {
+		t.Errorf("expected a label other than the colliding %s", hashed)
+	}
+	if label != "s0:c0,c1" {
+		t.Errorf("expected the first sequential label, got %s", label)
+	}
+	if !strings.Contains(logs.String(), "MTS label hash collision") {
+		t.Errorf("expected the collision to be logged, got %q", logs.String())
+	}
+	if again, _ := alloc.Allocate("team-b"); again != label {
+		t.Errorf("expected allocations to be stable, got %s then %s", label, again)
+	}
+
+	assignments := alloc.Assignments()
+	if len(assignments) != 3 || assignments["legacy"] != hashed || assignments["team-b"] != label {
+		t.Errorf("unexpected assignments %v", assignments)
+	}
+	if used, capacity := alloc.Usage(); used != 3 || capacity != LabelCapacity {
+		t.Errorf("expected 3 of %d labels, got %d of %d", LabelCapacity, used, capacity)
+	}
+
+	// Persisted collisions are kept by the first tenant in order
+	reloaded := NewLabelAllocator(slog.New(slog.NewTextHandler(&logs, nil)))
+	rejected := reloaded.Load(map[string]string{"a": "s0:c5,c6", "b": "s0:c5,c6", "c": "s0:c7", "d": "s0:c8,c9"})
+	if fmt.Sprint(rejected) != "[b c]" {
+		t.Errorf("expected [b c] rejected, got %v", rejected)
+	}
+	if label, _ := reloaded.Allocate("b"); label == "s0:c5,c6" {
+		t.Error("expected the colliding tenant to be reallocated")
+	}
+	reloaded.Release("d")
+	if label, _ := reloaded.Allocate("d"); label != GenerateMTSLabel("d").String() {
+		t.Errorf("expected a released tenant to get its hashed label back, got %s", label)
+	}
+}
+
+// TestLabelAllocatorExhaustion tests the warning near exhaustion and the
+// error once every label is allocated.
+func TestLabelAllocatorExhaustion(t *testing.T) {
+	var logs bytes.Buffer
+	alloc := NewLabelAllocator(slog.New(slog.NewTextHandler(&logs, nil)))
+	alloc.WarnThreshold = 0.5
+	for a := 0; a < MaxCategory; a++ {
+		for b := a + 1; b <= MaxCategory; b++ {
+			if len(alloc.owners) < LabelCapacity/2 {
+				tenant := fmt.Sprintf("t-%d-%d", a, b)
+				alloc.owners[[2]int{a, b}] = tenant
+				alloc.labels[tenant] = [2]int{a, b}
+			}
+		}
+	}
+	if _, err := alloc.Allocate("team-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "MTS label space nearing exhaustion") {
+		t.Errorf("expected an exhaustion warning, got %q", logs.String())
+	}
+
+	for a := 0; a < MaxCategory; a++ {
+		for b := a + 1; b <= MaxCategory; b++ {
+			if _, taken := alloc.owners[[2]int{a, b}]; !taken {
+				alloc.owners[[2]int{a, b}] = "filler"
+			}
+		}
+	}
+	if _, err := alloc.Allocate("team-b"); !errors.Is(err, ErrCategoriesExhausted) {
+		t.Errorf("expected ErrCategoriesExhausted, got %v", err)
+	}
+}
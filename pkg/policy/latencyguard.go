@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// LatencyFallback is what the latency guard degrades evaluation to (see
+// WithLatencyGuard).
+type LatencyFallback string
+
+const (
+	// FallbackLegacy evaluates every policy with the legacy tool table
+	// instead of OPA. Decisions only Rego can reach, such as those of
+	// custom Rego modules, are not made while degraded.
+	FallbackLegacy LatencyFallback = "legacy"
+
+	// FallbackCachedOnly serves cached decisions and denies the calls the
+	// cache has no decision for, including those that bypass it, with
+	// ErrLatencyDegraded.
+	FallbackCachedOnly LatencyFallback = "cached-only"
+)
+
+// ParseLatencyFallback parses a LatencyFallback.
+func ParseLatencyFallback(s string) (LatencyFallback, error) {
+	switch f := LatencyFallback(s); f {
+	case FallbackLegacy, FallbackCachedOnly:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid latency fallback %q: must be legacy or cached-only", s)
+}
+
+// LatencyGuardConfig configures the latency SLO guard of an engine.
+type LatencyGuardConfig struct {
+	// Threshold is the p99 evaluation latency past which the engine
+	// degrades to Fallback
+	Threshold time.Duration
+
+	// Fallback is the evaluation the engine degrades to
+	Fallback LatencyFallback
+
+	// Window is the number of recent evaluations the p99 is computed over
+	// (default: 1000). The p99 is checked every tenth of a window.
+	Window int
+
+	// Cooldown is how long the engine stays degraded before it evaluates
+	// normally again and measures afresh (default: 1m)
+	Cooldown time.Duration
+
+	// Alert, if set, is called when the engine degrades and when it
+	// recovers, in addition to the error and info logs it writes then
+	Alert func(LatencyAlert)
+}
+
+// LatencyAlert reports a change of the latency guard's state.
+type LatencyAlert struct {
+	// Degraded is true when the engine degraded, false when it recovered
+	Degraded bool
+
+	// P99 is the p99 latency that degraded the engine
+	P99 time.Duration
+
+	// Threshold and Fallback are those of the guard
+	Threshold time.Duration
+	Fallback  LatencyFallback
+}
+
+// latencyGuard measures evaluation latency, and degrades evaluation while
+// its p99 is past the threshold.
+type latencyGuard struct {
+	config LatencyGuardConfig
+
+	mu       sync.Mutex
+	samples  []time.Duration // ring buffer
+	next     int
+	full     bool
+	recorded int
+	until    time.Time // degraded until, zero if not degraded
+	p99      time.Duration
+}
+
+// WithLatencyGuard watches the p99 latency of evaluations and, past
+// config.Threshold, degrades evaluation to config.Fallback for
+// config.Cooldown, to protect the latency of tool calls during OPA
+// pathologies. A zero threshold disables the guard.
+func WithLatencyGuard(config LatencyGuardConfig) Option {
+	return func(e *Engine) {
+		if config.Threshold <= 0 {
+			e.guard = nil
+			return
+		}
+		if config.Window <= 0 {
+			config.Window = 1000
+		}
+		if config.Cooldown <= 0 {
+			config.Cooldown = time.Minute
+		}
+		e.guard = &latencyGuard{config: config, samples: make([]time.Duration, config.Window)}
+	}
+}
+
+// LatencyDegraded reports whether the latency guard has degraded
+// evaluation, and the p99 latency that degraded it.
+func (e *Engine) LatencyDegraded() (bool, time.Duration) {
+	if e.guard == nil {
+		return false, 0
+	}
+	e.guard.mu.Lock()
+	defer e.guard.mu.Unlock()
+	return !e.guard.until.IsZero(), e.guard.p99
+}
+
+// degradedTo reports whether evaluation is degraded to fallback.
+func (g *latencyGuard) degradedTo(fallback LatencyFallback) bool {
+	if g == nil || g.config.Fallback != fallback {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.until.IsZero()
+}
+
+// observeLatency records the latency of an evaluation, and degrades or
+// recovers evaluation. Evaluations while degraded are not measured, since
+// they do not show whether normal evaluation is still slow.
+func (e *Engine) observeLatency(d time.Duration) {
+	g := e.guard
+	if g == nil {
+		return
+	}
+	now := time.Now()
+	g.mu.Lock()
+	if !g.until.IsZero() {
+		if now.Before(g.until) {
+			g.mu.Unlock()
+			return
+		}
+		// Recover, and measure normal evaluation afresh
+		g.until, g.next, g.full, g.recorded = time.Time{}, 0, false, 0
+		alert := LatencyAlert{P99: g.p99, Threshold: g.config.Threshold, Fallback: g.config.Fallback}
+		g.p99 = 0
+		g.mu.Unlock()
+		e.log.Info("latency guard recovered", "fallback", alert.Fallback)
+		e.alertLatency(alert)
+		return
+	}
+
+	g.samples[g.next] = d
+	g.next = (g.next + 1) % len(g.samples)
+	if g.next == 0 {
+		g.full = true
+	}
+	g.recorded++
+	if !g.full || g.recorded < max(len(g.samples)/10, 1) {
+		g.mu.Unlock()
+		return
+	}
+	g.recorded = 0
+	p99 := percentile(g.samples, 0.99)
+	if p99 <= g.config.Threshold {
+		g.mu.Unlock()
+		return
+	}
+	g.until, g.p99 = now.Add(g.config.Cooldown), p99
+	alert := LatencyAlert{Degraded: true, P99: p99, Threshold: g.config.Threshold, Fallback: g.config.Fallback}
+	g.mu.Unlock()
+
+	e.log.Error("evaluation latency past SLO, degrading", "p99", p99, "threshold", alert.Threshold, "fallback", alert.Fallback, "cooldown", g.config.Cooldown)
+	e.alertLatency(alert)
+}
+
+// alertLatency calls the guard's alert hook, if set.
+func (e *Engine) alertLatency(alert LatencyAlert) {
+	if e.guard.config.Alert != nil {
+		e.guard.config.Alert(alert)
+	}
+}
+
+// percentile returns the p-th percentile of samples.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// checkCachedOnly returns the reason and cause of the denial of a call the
+// cache has no decision for while degraded to FallbackCachedOnly, and a
+// nil error otherwise.
+func (e *Engine) checkCachedOnly() (string, error) {
+	if !e.guard.degradedTo(FallbackCachedOnly) {
+		return "", nil
+	}
+	return "decision not cached while evaluation is degraded to cached-only", policyerrors.ErrLatencyDegraded
+}
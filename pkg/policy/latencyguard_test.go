@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestLatencyGuard tests that an engine whose p99 latency passes the
+// threshold degrades to cached decisions only, alerts, and recovers after
+// the cooldown.
+func TestLatencyGuard(t *testing.T) {
+	faults := NewFaultInjector()
+	var alerts []LatencyAlert
+	engine := NewEngine(WithMode(Enforcing), WithFaults(faults), WithAuditSink(NewChannelAuditSink(1)), WithLatencyGuard(LatencyGuardConfig{
+		Threshold: 5 * time.Millisecond,
+		Fallback:  FallbackCachedOnly,
+		Window:    10,
+		Cooldown:  50 * time.Millisecond,
+		Alert:     func(a LatencyAlert) { alerts = append(alerts, a) },
+	}))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("team", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}, {Tool: "file.write", Action: Allow}}, Enforcing, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+	evaluate := func(tool string) *EvaluationResult {
+		t.Helper()
+		result, err := engine.EvaluateWithResult(context.Background(), agent, tool, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	evaluate("file.read")
+	// Slow audit emission stands in for a slow evaluation
+	faults.Set(FaultAuditSink, Fault{Delay: 10 * time.Millisecond, Count: 10})
+	for i := 0; i < 10; i++ {
+		evaluate("file.read")
+	}
+	if degraded, p99 := engine.LatencyDegraded(); !degraded || p99 < 5*time.Millisecond {
+		t.Fatalf("expected the engine to degrade, got %v with p99 %s", degraded, p99)
+	}
+	if len(alerts) != 1 || !alerts[0].Degraded {
+		t.Errorf("expected a degradation alert, got %+v", alerts)
+	}
+
+	if result := evaluate("file.read"); result.Decision != Allow || !result.Cached {
+		t.Errorf("expected cached decisions to be served, got %+v", result)
+	}
+	if result := evaluate("file.write"); result.Decision != Deny || !errors.Is(result.Err, policyerrors.ErrLatencyDegraded) {
+		t.Errorf("expected a cache miss to be denied, got %+v", result)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	evaluate("file.read")
+	if degraded, _ := engine.LatencyDegraded(); degraded {
+		t.Error("expected the engine to recover after the cooldown")
+	}
+	if len(alerts) != 2 || alerts[1].Degraded {
+		t.Errorf("expected a recovery alert, got %+v", alerts)
+	}
+	if result := evaluate("file.write"); result.Decision != Allow {
+		t.Errorf("expected normal evaluation after recovery, got %+v", result)
+	}
+}
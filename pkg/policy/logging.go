@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Keys of the fields attached to log records about a request, shared by
+// the engine and the router so that a request's records can be joined
+// with each other and with its audit event.
+const (
+	LogKeyRequestID = "request_id"
+	LogKeyAgentType = "agent_type"
+	LogKeyTool      = "tool"
+	LogKeyDecision  = "decision"
+)
+
+// WithLogger sets the logger of the engine and its OPA evaluator.
+// Decisions are logged at debug level, evaluation failures at error
+// level. Default: slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Engine) {
+		if logger != nil {
+			e.log = logger
+		}
+	}
+}
+
+// requestIDKey is the context key of a caller-assigned request ID.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the caller's ID for a
+// request. The engine uses it as the request ID of the audit event and log
+// records of the evaluation, instead of generating one, so that they
+// correlate with the caller's own records.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID set by ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// logDecision logs a decision at debug level.
+func (e *Engine) logDecision(ctx context.Context, agent AgentContext, tool, requestID string, result *EvaluationResult) {
+	if !e.log.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	e.log.LogAttrs(ctx, slog.LevelDebug, "policy decision",
+		slog.String(LogKeyRequestID, requestID),
+		slog.String(LogKeyAgentType, agent.AgentType),
+		slog.String(LogKeyTool, tool),
+		slog.String(LogKeyDecision, result.Decision.String()),
+		slog.String("reason", result.Reason),
+		slog.String("policy", result.Policy),
+		slog.Bool("cached", result.Cached),
+	)
+}
@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// TestEngineLogger verifies decisions are logged at debug level with the
+// request fields, under the caller's request ID
+func TestEngineLogger(t *testing.T) {
+	var buf bytes.Buffer
+	var events []*AuditEvent
+	engine := NewEngine(
+		WithMode(Enforcing),
+		WithAuditSink(&testAuditSink{events: &events}),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	engine.LoadPolicy("coding-assistant", CompilePolicy("coding-policy", []string{"coding-assistant"}, Allow,
+		[]ToolPermission{{Tool: "shell.exec", Action: Deny}}, Enforcing, ""))
+	buf.Reset()
+
+	ctx := ContextWithRequestID(context.Background(), "req-42")
+	if _, err := engine.Evaluate(ctx, AgentContext{AgentType: "coding-assistant"}, "shell.exec", nil); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON log record, got %q: %v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		"level":         "DEBUG",
+		"msg":           "policy decision",
+		LogKeyRequestID: "req-42",
+		LogKeyAgentType: "coding-assistant",
+		LogKeyTool:      "shell.exec",
+		LogKeyDecision:  "DENY",
+		"policy":        "coding-policy",
+		"cached":        false,
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, record[k])
+		}
+	}
+
+	if len(events) != 1 || events[0].RequestID != "req-42" {
+		t.Errorf("expected the decision audited under the caller's request ID, got %+v", events)
+	}
+}
+
+// TestEngineLoggerLevel verifies decisions are not logged above debug level
+func TestEngineLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	engine := NewEngine(WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	if _, err := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", nil); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no records at info level, got %q", buf.String())
+	}
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxOPAMemoEntries bounds the memoized OPA results kept before expired
+// ones are swept.
+const maxOPAMemoEntries = 10000
+
+// WithOPAMemo memoizes OPA query results by a hash of the exact OPAInput
+// for ttl. The decision cache is keyed by agent, tool, and content hash
+// only, so calls whose decision depends on parameters it cannot see (tool
+// rules with parameter constraints, see checksParameters, and behavior
+// profiles) bypass it and run the query every time; with a memo, such
+// calls that repeat exactly skip the query. The input holds every
+// parameter and the agent's resolved label and tenant, so a result is only
+// reused for an identical call. The checks outside the generated Rego (custom
+// kinds, extensions, profiles) still run on every call.
+//
+// Results are invalidated with the decision cache when policies change;
+// ttl bounds how long a query whose Rego is not a pure function of its
+// input (e.g., one that reads the time) is reused, and should be short.
+// Zero (the default) disables the memo. Requires WithOPA.
+func WithOPAMemo(ttl time.Duration) Option {
+	return func(e *Engine) {
+		e.opaMemoTTL = ttl
+	}
+}
+
+// opaMemo holds OPA query results by input hash.
+type opaMemo struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]opaMemoEntry
+	hits    uint64
+	misses  uint64
+}
+
+// opaMemoEntry is a memoized query result and when it expires.
+type opaMemoEntry struct {
+	decision    Decision
+	reason      string
+	obligations []Obligation
+	expiresAt   time.Time
+}
+
+func newOPAMemo(ttl time.Duration) *opaMemo {
+	return &opaMemo{ttl: ttl, entries: make(map[string]opaMemoEntry)}
+}
+
+// opaMemoKey returns the memo key of a query input: the agent type and
+// tool, so that entries are invalidated with the decision cache's, and the
+// SHA-256 digest of the input's JSON encoding, which sorts map keys and so
+// is canonical. Inputs that cannot be encoded are not memoized.
+func opaMemoKey(input *OPAInput) (string, bool) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return CacheKey(input.Agent.Type, input.Tool) + "#" + hex.EncodeToString(sum[:]), true
+}
+
+// get returns the unexpired result memoized under key.
+func (m *opaMemo) get(key string) (opaMemoEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if ok && time.Now().Before(entry.expiresAt) {
+		m.hits++
+		return entry, true
+	}
+	if ok {
+		delete(m.entries, key)
+	}
+	m.misses++
+	return opaMemoEntry{}, false
+}
+
+// put memoizes a query result under key.
+func (m *opaMemo) put(key string, decision Decision, reason string, obligations []Obligation) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.entries) >= maxOPAMemoEntries {
+		for k, v := range m.entries {
+			if !now.Before(v.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+		if len(m.entries) >= maxOPAMemoEntries {
+			return
+		}
+	}
+	m.entries[key] = opaMemoEntry{decision: decision, reason: reason, obligations: obligations, expiresAt: now.Add(m.ttl)}
+}
+
+// invalidate removes the results of an agent type, or all results for a
+// pattern or the fallback policy, like the decision cache.
+func (m *opaMemo) invalidate(agentType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if agentType == FallbackAgentType || IsAgentTypePattern(agentType) {
+		m.entries = make(map[string]opaMemoEntry)
+		return
+	}
+	prefix := agentType + ":"
+	for k := range m.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.entries, k)
+		}
+	}
+}
+
+// stats returns the memo's hits and misses.
+func (m *opaMemo) stats() (hits, misses uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits, m.misses
+}
@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOPAMemoKey verifies equal inputs share a key regardless of map
+// order, and inputs differing in any parameter do not
+func TestOPAMemoKey(t *testing.T) {
+	input := func(path string) *OPAInput {
+		return &OPAInput{
+			Tool:    "file.write",
+			Request: map[string]interface{}{"path": path, "mode": "0644", "size": 10},
+			Agent:   OPAAgentInput{Type: "coding-assistant", Labels: map[string]string{"env": "prod", "team": "a"}},
+			Policy:  OPAPolicyInput{Name: "coding-policy"},
+		}
+	}
+
+	a, ok := opaMemoKey(input("/workspace/a.go"))
+	if !ok {
+		t.Fatal("expected input to be memoizable")
+	}
+	b, _ := opaMemoKey(input("/workspace/a.go"))
+	if a != b {
+		t.Errorf("expected equal inputs to share a key, got %q and %q", a, b)
+	}
+	if c, _ := opaMemoKey(input("/etc/passwd")); c == a {
+		t.Error("expected inputs with different parameters to have different keys")
+	}
+	if prefix := CacheKey("coding-assistant", "file.write") + "#"; a[:len(prefix)] != prefix {
+		t.Errorf("expected key prefixed with %q for invalidation, got %q", prefix, a)
+	}
+
+	unencodable := input("/workspace/a.go")
+	unencodable.Request["callback"] = func() {}
+	if _, ok := opaMemoKey(unencodable); ok {
+		t.Error("expected unencodable input not to be memoized")
+	}
+}
+
+// TestOPAMemo verifies results expire and are invalidated by agent type
+func TestOPAMemo(t *testing.T) {
+	memo := newOPAMemo(time.Minute)
+	memo.put("coding-assistant:file.read#1", Allow, "allowed", []Obligation{{Type: "log"}})
+	memo.put("data-analyst:file.read#1", Deny, "denied", nil)
+
+	entry, ok := memo.get("coding-assistant:file.read#1")
+	if !ok || entry.decision != Allow || len(entry.obligations) != 1 {
+		t.Fatalf("expected memoized allow with its obligation, got %+v, %v", entry, ok)
+	}
+
+	memo.invalidate("coding-assistant")
+	if _, ok := memo.get("coding-assistant:file.read#1"); ok {
+		t.Error("expected invalidated result to be gone")
+	}
+	if _, ok := memo.get("data-analyst:file.read#1"); !ok {
+		t.Error("expected other agent type's result to be kept")
+	}
+
+	memo.invalidate(FallbackAgentType)
+	if _, ok := memo.get("data-analyst:file.read#1"); ok {
+		t.Error("expected fallback policy change to clear all results")
+	}
+
+	expired := newOPAMemo(-time.Second)
+	expired.put("coding-assistant:file.read#1", Allow, "allowed", nil)
+	if _, ok := expired.get("coding-assistant:file.read#1"); ok {
+		t.Error("expected expired result to miss")
+	}
+
+	if hits, misses := memo.stats(); hits != 2 || misses != 2 {
+		t.Errorf("expected 2 hits and 2 misses, got %d and %d", hits, misses)
+	}
+}
+
+// TestEngineOPAMemoParameters verifies that calls to a rule with path
+// constraints bypass the decision cache and reuse OPA results of identical
+// calls only
+func TestEngineOPAMemoParameters(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing), WithOPA(true), WithOPAMemo(time.Minute))
+	policy, err := CompilePolicyWithOPA("coding-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow, Constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}}}},
+		Enforcing, "", `package agentpolicy
+
+import rego.v1
+
+default decision := {"allow": false, "deny": true, "mts": true, "reason": "path not allowed"}
+
+decision := {"allow": true, "deny": false, "mts": true, "reason": "allowed"} if {
+	startswith(input.request.path, "/workspace/")
+}
+`)
+	if err != nil {
+		t.Fatalf("CompilePolicyWithOPA failed: %v", err)
+	}
+	engine.LoadPolicy("coding-assistant", policy)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	for _, tt := range []struct {
+		path     string
+		expected Decision
+	}{
+		{"/workspace/x", Allow},
+		{"/etc/shadow", Deny},
+		{"/workspace/x", Allow},
+	} {
+		result, err := engine.EvaluateWithResult(context.Background(), agent, "file.read", map[string]interface{}{"path": tt.path})
+		if err != nil || result.Decision != tt.expected || result.Cached {
+			t.Errorf("%s: expected uncached %v, got %+v (%v)", tt.path, tt.expected, result, err)
+		}
+	}
+	if hits, misses := engine.OPAMemoStats(); hits != 1 || misses != 2 {
+		t.Errorf("expected the repeated call only to reuse a result, got %d hits and %d misses", hits, misses)
+	}
+}
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EvaluationResult is the outcome of a policy evaluation with the details
+// callers need to report it.
+type EvaluationResult struct {
+	// Decision is the effective decision, after the enforcement mode is applied
+	Decision Decision
+
+	// Reason is the audit reason for the policy decision. It describes policy
+	// internals and is meant for operators, not end users.
+	Reason string
+
+	// Policy is the name of the policy that made the decision. It is empty
+	// if no policy applies.
+	Policy string
+
+	// Message is the rendered user-facing denial message of the matching
+	// tool rule. It is empty unless the request is denied and the rule
+	// defines a message.
+	Message string
+
+	// MessageLocale is the language of Message ("" for the default message)
+	MessageLocale string
+
+	// Parameters are the request parameters rewritten by the tool rule's
+	// mutators. Callers must execute these instead of the original
+	// parameters. Nil unless the request is allowed and a mutator changed it.
+	Parameters map[string]interface{}
+
+	// Mutations describe each change made to Parameters, for auditing
+	Mutations []string
+
+	// Obligations must all be fulfilled by the caller before it executes
+	// the call; if any cannot be, the call must not execute. Nil unless the
+	// request is allowed and the tool rule or Rego decision carries them.
+	Obligations []Obligation
+
+	// Timeout bounds the execution of the call: the timeout constraint of
+	// the tool rule. Zero unless the request is allowed and the rule sets
+	// one.
+	Timeout time.Duration
+
+	// MaxConcurrent bounds the executions of the tool in flight at once
+	// per sandbox: the maxConcurrent constraint of the tool rule. Zero
+	// unless the request is allowed and the rule sets one.
+	MaxConcurrent int
+
+	// Retry bounds the retries of the call's transiently failed
+	// executions: the retry constraint of the tool rule. Nil unless the
+	// request is allowed and the rule sets one.
+	Retry *RetryPolicy
+
+	// Cached is true if the decision came from the decision cache
+	Cached bool
+
+	// OPAMetrics are OPA's metrics of the queries that reached the
+	// decision. Nil unless the engine collects them (see WithOPAMetrics)
+	// and a query ran
+	OPAMetrics *OPAEvalMetrics
+
+	// Trace is OPA's trace of the queries that reached the decision, if
+	// the evaluation was traced (see ContextWithTrace). Empty for
+	// decisions reached without OPA.
+	Trace string
+
+	// Err is the typed cause of a policy denial (see package
+	// policy/errors): ErrNoPolicy, an *ErrConstraintViolation,
+	// ErrMTSViolation, or ErrOPAEvaluation. It is nil for allows and for
+	// denials by tool rule or default action, and is also set for denials
+	// that permissive mode allowed.
+	Err error
+}
+
+// placeholderRe matches message template placeholders such as {path}.
+var placeholderRe = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// maxPlaceholderValue caps interpolated values so a request parameter
+// cannot flood the message shown to users.
+const maxPlaceholderValue = 256
+
+// SelectDenyMessage picks the message template for a locale: an exact
+// LocalizedDenyMessages match (case-insensitive), then the base language
+// ("pt-BR" -> "pt"), then DenyMessage. Returns the template and the locale
+// it was selected for ("" for DenyMessage).
+func (p *ToolPermission) SelectDenyMessage(locale string) (string, string) {
+	if locale != "" && len(p.LocalizedDenyMessages) > 0 {
+		for _, candidate := range []string{locale, baseLanguage(locale)} {
+			for tag, tmpl := range p.LocalizedDenyMessages {
+				if strings.EqualFold(tag, candidate) && tmpl != "" {
+					return tmpl, tag
+				}
+			}
+		}
+	}
+	return p.DenyMessage, ""
+}
+
+// baseLanguage returns the language subtag of a BCP 47 tag ("pt-BR" -> "pt").
+func baseLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// RenderDenyMessage interpolates a deny message template.
+//
+// Placeholders are written {name}. The names tool, agent_type, and tenant
+// refer to the call itself; any other name is looked up in the request
+// parameters (e.g., {path}, {domain}, {port}). Placeholders with no value
+// are left as written so template mistakes stay visible. Values are
+// inserted verbatim, never re-expanded, and truncated to a bounded length.
+func RenderDenyMessage(tmpl string, agent AgentContext, toolName string, request interface{}) string {
+	if !strings.Contains(tmpl, "{") {
+		return tmpl
+	}
+
+	params := requestParameterMap(request)
+	return placeholderRe.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+
+		var value string
+		switch name {
+		case "tool":
+			value = toolName
+		case "agent_type":
+			value = agent.AgentType
+		case "tenant":
+			value = agent.TenantID
+		default:
+			v, ok := params[name]
+			if !ok || v == nil {
+				return placeholder
+			}
+			value = fmt.Sprint(v)
+		}
+
+		value = strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return ' '
+			}
+			return r
+		}, value)
+		if runes := []rune(value); len(runes) > maxPlaceholderValue {
+			value = string(runes[:maxPlaceholderValue]) + "..."
+		}
+		return value
+	})
+}
+
+// denyMessage renders the message of the rule that governs toolName in p
+// for a denied request.
+func denyMessage(p *CompiledPolicy, agent AgentContext, toolName string, request interface{}) (string, string) {
+	perm, ok := p.ToolTable[toolName]
+	if !ok {
+		return "", ""
+	}
+	tmpl, locale := perm.SelectDenyMessage(agent.Locale)
+	if tmpl == "" {
+		return "", ""
+	}
+	return RenderDenyMessage(tmpl, agent, toolName, request), locale
+}
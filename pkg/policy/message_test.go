@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestRenderDenyMessage verifies placeholder interpolation
+func TestRenderDenyMessage(t *testing.T) {
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "acme"}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		request  interface{}
+		expected string
+	}{
+		{"no placeholders", "Writes are disabled", nil, "Writes are disabled"},
+		{"call fields", "{agent_type} may not use {tool} for {tenant}", nil, "coding-assistant may not use file.write for acme"},
+		{"raw parameter", "You can only edit files under /workspace, not {path}", map[string]interface{}{"path": "/etc/passwd"}, "You can only edit files under /workspace, not /etc/passwd"},
+		{"typed parameter", "{path} is outside the workspace", &ToolRequest{File: &FileParams{Path: "/etc/hosts"}}, "/etc/hosts is outside the workspace"},
+		{"numeric parameter", "Writes of {size} bytes are too large", map[string]interface{}{"size": float64(4096)}, "Writes of 4096 bytes are too large"},
+		{"missing parameter", "Cannot fetch {domain}", nil, "Cannot fetch {domain}"},
+		{"control characters", "Denied: {path}", map[string]interface{}{"path": "a\nb"}, "Denied: a b"},
+		{"no re-expansion", "Denied: {path}", map[string]interface{}{"path": "{tool}"}, "Denied: {tool}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderDenyMessage(tt.tmpl, agent, "file.write", tt.request); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	long := RenderDenyMessage("{path}", agent, "file.write", map[string]interface{}{"path": strings.Repeat("x", 1000)})
+	if len(long) != maxPlaceholderValue+len("...") {
+		t.Errorf("expected long values to be truncated, got %d bytes", len(long))
+	}
+}
+
+// TestSelectDenyMessage verifies locale fallback
+func TestSelectDenyMessage(t *testing.T) {
+	perm := ToolPermission{
+		DenyMessage:           "Not allowed",
+		LocalizedDenyMessages: map[string]string{"de": "Nicht erlaubt", "pt-BR": "Não permitido"},
+	}
+
+	tests := []struct {
+		locale     string
+		expected   string
+		wantLocale string
+	}{
+		{"", "Not allowed", ""},
+		{"de", "Nicht erlaubt", "de"},
+		{"de-AT", "Nicht erlaubt", "de"},
+		{"pt-br", "Não permitido", "pt-BR"},
+		{"fr", "Not allowed", ""},
+	}
+
+	for _, tt := range tests {
+		msg, locale := perm.SelectDenyMessage(tt.locale)
+		if msg != tt.expected || locale != tt.wantLocale {
+			t.Errorf("locale %q: expected (%q, %q), got (%q, %q)", tt.locale, tt.expected, tt.wantLocale, msg, locale)
+		}
+	}
+}
+
+// TestEvaluateWithResultMessage verifies deny messages are rendered per
+// request, and only for effective denials
+func TestEvaluateWithResultMessage(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("coding-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{
+			{
+				Tool:        "file.write",
+				Action:      Allow,
+				Constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+				DenyMessage: "You can only edit files under /workspace, not {path}",
+				LocalizedDenyMessages: map[string]string{
+					"de": "Sie dürfen nur Dateien unter /workspace bearbeiten, nicht {path}",
+				},
+			},
+			{Tool: "shell.exec", Action: Deny},
+		}, Enforcing, ""))
+
+	ctx := context.Background()
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	result, err := engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "/etc/passwd"})
+	if err != nil {
+		t.Fatalf("EvaluateWithResult failed: %v", err)
+	}
+	if result.Decision != Deny || result.Policy != "coding-policy" || result.Reason != "constraint violation" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Message != "You can only edit files under /workspace, not /etc/passwd" {
+		t.Errorf("unexpected message: %q", result.Message)
+	}
+
+	// Rendered with this request's parameters and locale
+	agent.Locale = "de-DE"
+	result, _ = engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "/etc/shadow"})
+	if result.Decision != Deny || result.MessageLocale != "de" || !strings.HasSuffix(result.Message, "nicht /etc/shadow") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Rules without a message and default denials carry no message
+	for _, tool := range []string{"shell.exec", "network.fetch"} {
+		result, _ = engine.EvaluateWithResult(ctx, agent, tool, nil)
+		if result.Decision != Deny || result.Message != "" || result.Policy != "coding-policy" {
+			t.Errorf("%s: unexpected result: %+v", tool, result)
+		}
+	}
+
+	// Permissive mode allows the request, so there is nothing to explain
+	engine.SetMode(Permissive)
+	result, _ = engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "/etc/passwd"})
+	if result.Decision != Allow || result.Message != "" {
+		t.Errorf("unexpected permissive result: %+v", result)
+	}
+}
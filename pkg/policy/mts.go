@@ -14,18 +14,30 @@ import (
 )
 
 // MTSLabel represents a Multi-Tenant Sandboxing label following SELinux MCS format.
-// Format: sensitivity:category1,category2 (e.g., "s0:c42,c108")
+// Format: sensitivity:category1,category2 (e.g., "s0:c42,c108"), or, for a
+// range, low-high:categories (e.g., "s0-s2:c10.c20,c42")
 //
-// The sensitivity level (s0, s1, etc.) represents classification.
-// Categories (c0-c1023) represent compartments for tenant isolation.
+// The sensitivity level (s0, s1, etc.) represents classification; a range
+// spans a band of levels, from Sensitivity up to Clearance.
+// Categories (c0-c1023) represent compartments for tenant isolation; a
+// category range such as c10.c20 stands for every category in it.
 //
 // Access rules:
 //   - Subject can access object if subject categories dominate object categories
 //   - Dominance means subject has all categories that object has (superset or equal)
+//
+// Ranges make tenancy hierarchical: an organization labeled
+// "s0-s1:c0.c99" dominates each of its teams ("s0:c10,c11", "s1:c12"),
+// while the teams are isolated from each other and from the organization.
 type MTSLabel struct {
-	// Sensitivity level (typically s0 for tenant isolation)
+	// Sensitivity level (typically s0 for tenant isolation), the low end
+	// of a range
 	Sensitivity int
 
+	// Clearance is the high end of a range; labels of a single level leave
+	// it zero or equal to Sensitivity
+	Clearance int
+
 	// Categories are the compartment labels (e.g., [42, 108])
 	Categories []int
 }
@@ -44,10 +56,11 @@ var ErrCategoryOutOfRange = errors.New("category out of range (0-1023)")
 
 // ParseMTSLabel parses an SELinux MCS-style label string.
 // Valid formats:
-//   - "s0:c42,c108" - sensitivity 0 with categories 42 and 108
-//   - "s0:c42"      - sensitivity 0 with single category
-//   - "s0"          - sensitivity 0 with no categories (empty compartment)
-//   - ""            - empty label (no restrictions)
+//   - "s0:c42,c108"  - sensitivity 0 with categories 42 and 108
+//   - "s0:c42"       - sensitivity 0 with single category
+//   - "s0"           - sensitivity 0 with no categories (empty compartment)
+//   - "s0-s2:c10.c20" - sensitivities 0 through 2 with categories 10 through 20
+//   - ""             - empty label (no restrictions)
 func ParseMTSLabel(s string) (*MTSLabel, error) {
 	if s == "" {
 		return &MTSLabel{Sensitivity: DefaultSensitivity, Categories: nil}, nil
@@ -57,19 +70,26 @@ func ParseMTSLabel(s string) (*MTSLabel, error) {
 
 	// Split sensitivity from categories
 	parts := strings.SplitN(s, ":", 2)
-	if len(parts) == 0 || !strings.HasPrefix(parts[0], "s") {
-		return nil, ErrInvalidMTSLabel
-	}
 
-	// Parse sensitivity level
-	sensStr := parts[0][1:] // Remove 's' prefix
-	sensitivity, err := strconv.Atoi(sensStr)
-	if err != nil || sensitivity < 0 {
-		return nil, ErrInvalidMTSLabel
+	// Parse the sensitivity level or range
+	low, high, isRange := strings.Cut(parts[0], "-")
+	sensitivity, err := parseSensitivity(low)
+	if err != nil {
+		return nil, err
+	}
+	clearance := sensitivity
+	if isRange {
+		if clearance, err = parseSensitivity(high); err != nil {
+			return nil, err
+		}
+		if clearance < sensitivity {
+			return nil, ErrInvalidMTSLabel
+		}
 	}
 
 	label := &MTSLabel{
 		Sensitivity: sensitivity,
+		Clearance:   clearance,
 		Categories:  make([]int, 0),
 	}
 
@@ -77,20 +97,24 @@ func ParseMTSLabel(s string) (*MTSLabel, error) {
 	if len(parts) == 2 && parts[1] != "" {
 		catStrs := strings.Split(parts[1], ",")
 		for _, catStr := range catStrs {
-			catStr = strings.TrimSpace(catStr)
-			if !strings.HasPrefix(catStr, "c") {
-				return nil, ErrInvalidMTSLabel
-			}
-
-			catNum, err := strconv.Atoi(catStr[1:])
+			first, last, isRange := strings.Cut(strings.TrimSpace(catStr), ".")
+			from, err := parseCategory(first)
 			if err != nil {
-				return nil, ErrInvalidMTSLabel
+				return nil, err
 			}
-			if catNum < 0 || catNum > MaxCategory {
-				return nil, ErrCategoryOutOfRange
+			to := from
+			if isRange {
+				if to, err = parseCategory(last); err != nil {
+					return nil, err
+				}
+				if to < from {
+					return nil, ErrInvalidMTSLabel
+				}
 			}
 
-			label.Categories = append(label.Categories, catNum)
+			for c := from; c <= to; c++ {
+				label.Categories = append(label.Categories, c)
+			}
 		}
 
 		// Sort and deduplicate categories for canonical form
@@ -100,21 +124,72 @@ func ParseMTSLabel(s string) (*MTSLabel, error) {
 	return label, nil
 }
 
-// String returns the canonical SELinux MCS format string.
+// parseSensitivity parses a sensitivity level such as "s0".
+func parseSensitivity(s string) (int, error) {
+	if !strings.HasPrefix(s, "s") {
+		return 0, ErrInvalidMTSLabel
+	}
+	sensitivity, err := strconv.Atoi(s[1:])
+	if err != nil || sensitivity < 0 {
+		return 0, ErrInvalidMTSLabel
+	}
+	return sensitivity, nil
+}
+
+// parseCategory parses a category such as "c42".
+func parseCategory(s string) (int, error) {
+	if !strings.HasPrefix(s, "c") {
+		return 0, ErrInvalidMTSLabel
+	}
+	catNum, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return 0, ErrInvalidMTSLabel
+	}
+	if catNum < 0 || catNum > MaxCategory {
+		return 0, ErrCategoryOutOfRange
+	}
+	return catNum, nil
+}
+
+// High returns the highest sensitivity of the label: its Clearance, for a
+// range, or else its Sensitivity.
+func (l *MTSLabel) High() int {
+	if l.Clearance > l.Sensitivity {
+		return l.Clearance
+	}
+	return l.Sensitivity
+}
+
+// String returns the canonical SELinux MCS format string. Runs of three or
+// more consecutive categories are written as ranges (e.g., "c10.c20").
 func (l *MTSLabel) String() string {
 	if l == nil {
 		return ""
 	}
 
+	level := fmt.Sprintf("s%d", l.Sensitivity)
+	if high := l.High(); high > l.Sensitivity {
+		level += fmt.Sprintf("-s%d", high)
+	}
 	if len(l.Categories) == 0 {
-		return fmt.Sprintf("s%d", l.Sensitivity)
+		return level
 	}
 
-	catStrs := make([]string, len(l.Categories))
-	for i, c := range l.Categories {
-		catStrs[i] = fmt.Sprintf("c%d", c)
+	catStrs := make([]string, 0, len(l.Categories))
+	for i := 0; i < len(l.Categories); {
+		j := i
+		for j+1 < len(l.Categories) && l.Categories[j+1] == l.Categories[j]+1 {
+			j++
+		}
+		if j-i >= 2 {
+			catStrs = append(catStrs, fmt.Sprintf("c%d.c%d", l.Categories[i], l.Categories[j]))
+			i = j + 1
+			continue
+		}
+		catStrs = append(catStrs, fmt.Sprintf("c%d", l.Categories[i]))
+		i++
 	}
-	return fmt.Sprintf("s%d:%s", l.Sensitivity, strings.Join(catStrs, ","))
+	return fmt.Sprintf("%s:%s", level, strings.Join(catStrs, ","))
 }
 
 // GenerateMTSLabel creates a deterministic MTS label from a tenant ID.
@@ -155,7 +230,7 @@ func hashToCategory(tenantID string, seed int) int {
 
 // CanAccess checks if a subject with this label can access an object with the given label.
 // Implements SELinux MCS dominance rules:
-//   - Subject sensitivity must be >= object sensitivity
+//   - Subject's highest sensitivity must be >= object's highest sensitivity
 //   - Subject categories must be a superset of (or equal to) object categories
 //   - Empty subject categories can only access empty object categories
 //
@@ -167,7 +242,7 @@ func (l *MTSLabel) CanAccess(object *MTSLabel) bool {
 	}
 
 	// Check sensitivity dominance
-	if l.Sensitivity < object.Sensitivity {
+	if l.High() < object.High() {
 		return false
 	}
 
@@ -185,6 +260,24 @@ func (l *MTSLabel) CanAccess(object *MTSLabel) bool {
 	return containsAll(l.Categories, object.Categories)
 }
 
+// Contains checks if this label's range and categories enclose the other
+// label's, as an organization's label encloses the labels of its teams:
+// the other label's sensitivities must lie within this label's range, and
+// its categories must be a subset of this label's. A nil label contains
+// every label.
+func (l *MTSLabel) Contains(other *MTSLabel) bool {
+	if l == nil {
+		return true
+	}
+	if other == nil {
+		return false
+	}
+	if other.Sensitivity < l.Sensitivity || other.High() > l.High() {
+		return false
+	}
+	return containsAll(l.Categories, other.Categories)
+}
+
 // Equals checks if two MTS labels are identical.
 func (l *MTSLabel) Equals(other *MTSLabel) bool {
 	if l == nil && other == nil {
@@ -193,7 +286,7 @@ func (l *MTSLabel) Equals(other *MTSLabel) bool {
 	if l == nil || other == nil {
 		return false
 	}
-	if l.Sensitivity != other.Sensitivity {
+	if l.Sensitivity != other.Sensitivity || l.High() != other.High() {
 		return false
 	}
 	if len(l.Categories) != len(other.Categories) {
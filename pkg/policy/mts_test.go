@@ -387,3 +387,84 @@ func TestMTSRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+// TestMTSLabelRanges verifies parsing and formatting of sensitivity and
+// category ranges
+func TestMTSLabelRanges(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantSens  int
+		wantHigh  int
+		wantCats  int
+		canonical string
+		wantErr   bool
+	}{
+		{input: "s0-s2:c10.c20", wantSens: 0, wantHigh: 2, wantCats: 11, canonical: "s0-s2:c10.c20"},
+		{input: "s1-s1:c3", wantSens: 1, wantHigh: 1, wantCats: 1, canonical: "s1:c3"},
+		{input: "s0:c1,c2,c3,c7", wantSens: 0, wantHigh: 0, wantCats: 4, canonical: "s0:c1.c3,c7"},
+		{input: "s0:c5.c7,c6,c1,c2", wantSens: 0, wantHigh: 0, wantCats: 5, canonical: "s0:c1,c2,c5.c7"},
+		{input: "s0-s3", wantSens: 0, wantHigh: 3, wantCats: 0, canonical: "s0-s3"},
+		{input: "s2-s1:c1", wantErr: true},
+		{input: "s0-:c1", wantErr: true},
+		{input: "s0:c20.c10", wantErr: true},
+		{input: "s0:c10.c2000", wantErr: true},
+		{input: "s0:c10.20", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			label, err := ParseMTSLabel(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", label)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if label.Sensitivity != tt.wantSens || label.High() != tt.wantHigh || len(label.Categories) != tt.wantCats {
+				t.Errorf("got s%d-s%d with %d categories, want s%d-s%d with %d", label.Sensitivity, label.High(), len(label.Categories), tt.wantSens, tt.wantHigh, tt.wantCats)
+			}
+			if got := label.String(); got != tt.canonical {
+				t.Errorf("String() = %q, want %q", got, tt.canonical)
+			}
+		})
+	}
+}
+
+// TestMTSLabelHierarchy verifies dominance and containment of ranged
+// labels: an organization's label encloses its teams'
+func TestMTSLabelHierarchy(t *testing.T) {
+	org, _ := ParseMTSLabel("s0-s1:c0.c99")
+	teamA, _ := ParseMTSLabel("s0:c10,c11")
+	teamB, _ := ParseMTSLabel("s1:c12")
+	outside, _ := ParseMTSLabel("s0:c100")
+	secret, _ := ParseMTSLabel("s2:c10")
+
+	if !org.CanAccess(teamA) || !org.CanAccess(teamB) {
+		t.Error("expected the organization to access its teams")
+	}
+	if teamA.CanAccess(teamB) || teamA.CanAccess(org) {
+		t.Error("expected a team not to access its sibling or its organization")
+	}
+	if org.CanAccess(outside) || org.CanAccess(secret) {
+		t.Error("expected the organization not to access labels outside its range")
+	}
+
+	if !org.Contains(teamA) || !org.Contains(teamB) {
+		t.Error("expected the organization to contain its teams")
+	}
+	if org.Contains(secret) || org.Contains(outside) || teamA.Contains(org) {
+		t.Error("expected containment to respect ranges and categories")
+	}
+	high, _ := ParseMTSLabel("s1:c10")
+	if (&MTSLabel{Sensitivity: 2, Clearance: 3, Categories: []int{10}}).Contains(high) {
+		t.Error("expected a label below the range not to be contained")
+	}
+
+	same, _ := ParseMTSLabel("s0-s1:c0.c99")
+	if !org.Equals(same) || org.Equals(teamA) {
+		t.Error("expected ranged labels to compare by range")
+	}
+}
@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MutatorType identifies a parameter rewrite applied to allowed tool calls.
+type MutatorType string
+
+const (
+	// MutateSet assigns a fixed value (e.g., force read_only=true)
+	MutateSet MutatorType = "set"
+
+	// MutateClamp caps a numeric parameter at Max (e.g., size)
+	MutateClamp MutatorType = "clamp"
+
+	// MutatePrefixRelativePath joins a relative path onto Root, so the
+	// tool resolves it inside the sandbox (e.g., "src/a.go" -> "/workspace/src/a.go")
+	MutatePrefixRelativePath MutatorType = "prefixRelativePath"
+
+	// MutateRemoveKey deletes Key from a map parameter, matching keys
+	// case-insensitively (e.g., the Authorization entry of headers), or
+	// removes the parameter itself when Key is empty
+	MutateRemoveKey MutatorType = "removeKey"
+)
+
+// ParameterMutator rewrites one request parameter of a tool rule.
+//
+// Mutators run before the rule's constraints are checked, so constraints
+// see the parameters that will actually be executed, and the rewritten
+// parameters are returned to the caller only when the call is allowed.
+type ParameterMutator struct {
+	// Type is the kind of rewrite
+	Type MutatorType
+
+	// Param is the request parameter to rewrite (e.g., "path", "size")
+	Param string
+
+	// Value is assigned by MutateSet
+	Value interface{}
+
+	// Max is the upper bound for MutateClamp
+	Max int64
+
+	// Root is the directory MutatePrefixRelativePath joins paths onto
+	Root string
+
+	// Key is the map entry MutateRemoveKey deletes
+	Key string
+}
+
+// String describes the mutator, e.g. "clamp size to 1024".
+func (m ParameterMutator) String() string {
+	switch m.Type {
+	case MutateSet:
+		return fmt.Sprintf("set %s to %v", m.Param, m.Value)
+	case MutateClamp:
+		return fmt.Sprintf("clamp %s to %d", m.Param, m.Max)
+	case MutatePrefixRelativePath:
+		return fmt.Sprintf("prefix relative %s with %s", m.Param, m.Root)
+	case MutateRemoveKey:
+		if m.Key == "" {
+			return fmt.Sprintf("remove %s", m.Param)
+		}
+		return fmt.Sprintf("remove %s from %s", m.Key, m.Param)
+	default:
+		return fmt.Sprintf("%s %s", m.Type, m.Param)
+	}
+}
+
+// Validate checks that the mutator has the fields its type requires.
+func (m ParameterMutator) Validate() error {
+	if m.Param == "" {
+		return fmt.Errorf("%s mutator: param is required", m.Type)
+	}
+	switch m.Type {
+	case MutateSet, MutateRemoveKey:
+	case MutateClamp:
+		if m.Max < 0 {
+			return fmt.Errorf("clamp mutator for %s: max must not be negative", m.Param)
+		}
+	case MutatePrefixRelativePath:
+		if !filepath.IsAbs(m.Root) {
+			return fmt.Errorf("prefixRelativePath mutator for %s: root %q must be absolute", m.Param, m.Root)
+		}
+	default:
+		return fmt.Errorf("unknown mutator type %q", m.Type)
+	}
+	return nil
+}
+
+// ApplyMutators applies mutators in order to a copy of params and returns
+// the copy with a description of each change made. Mutators whose
+// parameter is absent or of the wrong type change nothing; in particular a
+// non-numeric value is never clamped, so a size constraint still fails
+// closed on it. If nothing changed, the returned changes are empty.
+func ApplyMutators(mutators []ParameterMutator, params map[string]interface{}) (map[string]interface{}, []string) {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	var changes []string
+	for _, m := range mutators {
+		if change, ok := m.apply(out); ok {
+			changes = append(changes, change)
+		}
+	}
+	return out, changes
+}
+
+// apply rewrites params in place, reporting the change if one was made.
+func (m ParameterMutator) apply(params map[string]interface{}) (string, bool) {
+	current, present := params[m.Param]
+
+	switch m.Type {
+	case MutateSet:
+		if present && fmt.Sprint(current) == fmt.Sprint(m.Value) {
+			return "", false
+		}
+		params[m.Param] = m.Value
+		return fmt.Sprintf("set %s to %v", m.Param, m.Value), true
+
+	case MutateClamp:
+		n, ok := CoerceInt64(current)
+		if !present || !ok || n <= m.Max {
+			return "", false
+		}
+		params[m.Param] = m.Max
+		return fmt.Sprintf("clamped %s from %d to %d", m.Param, n, m.Max), true
+
+	case MutatePrefixRelativePath:
+		p, ok := current.(string)
+		if !ok || p == "" || filepath.IsAbs(p) {
+			return "", false
+		}
+		joined := filepath.Join(m.Root, p)
+		params[m.Param] = joined
+		return fmt.Sprintf("rewrote %s %q to %q", m.Param, p, joined), true
+
+	case MutateRemoveKey:
+		if !present {
+			return "", false
+		}
+		if m.Key == "" {
+			delete(params, m.Param)
+			return fmt.Sprintf("removed %s", m.Param), true
+		}
+		return removeMapKey(params, m.Param, m.Key)
+	}
+
+	return "", false
+}
+
+// mutateRequest applies the mutators of the allow rule for toolName in p.
+// If any parameter changed, it returns the rewritten parameter map in place
+// of request, along with the changes; otherwise request is returned as is.
+func mutateRequest(p *CompiledPolicy, toolName string, request interface{}) (interface{}, []string) {
+	perm, ok := p.ToolTable[toolName]
+	if !ok || perm.Action != Allow || len(perm.Mutators) == 0 {
+		return request, nil
+	}
+	params, changes := ApplyMutators(perm.Mutators, requestParameterMap(request))
+	if len(changes) == 0 {
+		return request, nil
+	}
+	return params, changes
+}
+
+// removeMapKey deletes key (case-insensitively) from the map parameter
+// param, copying the map so the caller's request is left untouched.
+func removeMapKey(params map[string]interface{}, param, key string) (string, bool) {
+	var removed []string
+	var copied interface{}
+
+	switch m := params[param].(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			if strings.EqualFold(k, key) {
+				removed = append(removed, k)
+				continue
+			}
+			c[k] = v
+		}
+		copied = c
+	case map[string]string:
+		c := make(map[string]string, len(m))
+		for k, v := range m {
+			if strings.EqualFold(k, key) {
+				removed = append(removed, k)
+				continue
+			}
+			c[k] = v
+		}
+		copied = c
+	default:
+		return "", false
+	}
+
+	if len(removed) == 0 {
+		return "", false
+	}
+	params[param] = copied
+	return fmt.Sprintf("removed %s from %s", strings.Join(removed, ", "), param), true
+}
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestApplyMutators verifies each mutator type and that the input is not modified
+func TestApplyMutators(t *testing.T) {
+	mutators := []ParameterMutator{
+		{Type: MutateSet, Param: "read_only", Value: true},
+		{Type: MutateClamp, Param: "size", Max: 1024},
+		{Type: MutatePrefixRelativePath, Param: "path", Root: "/workspace"},
+		{Type: MutateRemoveKey, Param: "headers", Key: "Authorization"},
+	}
+
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		expected map[string]interface{}
+		changes  int
+	}{
+		{
+			name:     "all rewritten",
+			params:   map[string]interface{}{"read_only": false, "size": float64(4096), "path": "src/main.go", "headers": map[string]interface{}{"authorization": "Bearer x", "Accept": "*/*"}},
+			expected: map[string]interface{}{"read_only": true, "size": int64(1024), "path": "/workspace/src/main.go", "headers": map[string]interface{}{"Accept": "*/*"}},
+			changes:  4,
+		},
+		{
+			name:     "already compliant",
+			params:   map[string]interface{}{"read_only": true, "size": int64(10), "path": "/workspace/a", "headers": map[string]string{"Accept": "*/*"}},
+			expected: map[string]interface{}{"read_only": true, "size": int64(10), "path": "/workspace/a", "headers": map[string]string{"Accept": "*/*"}},
+		},
+		{
+			name:     "absent parameters",
+			params:   map[string]interface{}{},
+			expected: map[string]interface{}{"read_only": true},
+			changes:  1,
+		},
+		{
+			name:     "relative path is cleaned",
+			params:   map[string]interface{}{"read_only": true, "path": "../etc/passwd"},
+			expected: map[string]interface{}{"read_only": true, "path": "/etc/passwd"},
+			changes:  1,
+		},
+		{
+			name:     "malformed size left for constraints",
+			params:   map[string]interface{}{"read_only": true, "size": "lots"},
+			expected: map[string]interface{}{"read_only": true, "size": "lots"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(tt.params)
+			got, changes := ApplyMutators(mutators, tt.params)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if len(changes) != tt.changes {
+				t.Errorf("expected %d changes, got %v", tt.changes, changes)
+			}
+			if len(tt.params) != before {
+				t.Errorf("input parameters were modified: %v", tt.params)
+			}
+		})
+	}
+
+	headers := map[string]interface{}{"Authorization": "Bearer x"}
+	ApplyMutators(mutators, map[string]interface{}{"headers": headers})
+	if _, ok := headers["Authorization"]; !ok {
+		t.Error("removeKey modified the caller's map")
+	}
+}
+
+// TestParameterMutatorValidate verifies required fields per mutator type
+func TestParameterMutatorValidate(t *testing.T) {
+	valid := []ParameterMutator{
+		{Type: MutateSet, Param: "read_only", Value: true},
+		{Type: MutateClamp, Param: "size", Max: 0},
+		{Type: MutatePrefixRelativePath, Param: "path", Root: "/workspace"},
+		{Type: MutateRemoveKey, Param: "headers", Key: "Authorization"},
+	}
+	for _, m := range valid {
+		if err := m.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", m, err)
+		}
+	}
+
+	invalid := []ParameterMutator{
+		{Type: MutateSet},
+		{Type: MutateClamp, Param: "size", Max: -1},
+		{Type: MutatePrefixRelativePath, Param: "path", Root: "workspace"},
+		{Type: "rename", Param: "path"},
+	}
+	for _, m := range invalid {
+		if err := m.Validate(); err == nil {
+			t.Errorf("%s: expected error", m)
+		}
+	}
+}
+
+// TestEvaluateWithResultMutators verifies mutated parameters are checked
+// against constraints and returned for allowed requests
+func TestEvaluateWithResultMutators(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("coding-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{
+			{
+				Tool:        "file.write",
+				Action:      Allow,
+				Constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}, MaxSizeBytes: 1024},
+				Mutators: []ParameterMutator{
+					{Type: MutatePrefixRelativePath, Param: "path", Root: "/workspace"},
+					{Type: MutateClamp, Param: "size", Max: 1024},
+				},
+			},
+		}, Enforcing, ""))
+
+	ctx := context.Background()
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	// The relative path and oversized write only pass after mutation
+	result, err := engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "src/main.go", "size": 4096})
+	if err != nil {
+		t.Fatalf("EvaluateWithResult failed: %v", err)
+	}
+	if result.Decision != Allow || len(result.Mutations) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Parameters["path"] != "/workspace/src/main.go" || result.Parameters["size"] != int64(1024) {
+		t.Errorf("unexpected parameters: %v", result.Parameters)
+	}
+
+	// Each allow is mutated with its request's parameters
+	result, _ = engine.EvaluateWithResult(ctx, agent, "file.write", &ToolRequest{File: &FileParams{Path: "docs/a.md"}})
+	if result.Decision != Allow || result.Parameters["path"] != "/workspace/docs/a.md" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Requests that need no rewrite carry no parameters
+	result, _ = engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "/workspace/a"})
+	if result.Decision != Allow || result.Parameters != nil || result.Mutations != nil {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Mutation cannot launder a path out of the sandbox
+	engine.Cache().InvalidateAll()
+	result, _ = engine.EvaluateWithResult(ctx, agent, "file.write", map[string]interface{}{"path": "../etc/passwd"})
+	if result.Decision != Deny || result.Parameters != nil {
+		t.Errorf("expected traversal to be denied, got %+v", result)
+	}
+}
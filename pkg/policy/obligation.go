@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Well-known obligation types. Callers may define others; an obligation
+// whose type the caller does not understand must fail the call.
+const (
+	// ObligationLogPayload requires the full request payload to be logged
+	// before the tool executes
+	ObligationLogPayload = "logPayload"
+
+	// ObligationNotify requires a notification to Params["channel"]
+	// (e.g., "#security-alerts") before the tool executes
+	ObligationNotify = "notify"
+
+	// ObligationReadOnlySandbox requires the tool to execute in a read-only
+	// sandbox
+	ObligationReadOnlySandbox = "readOnlySandbox"
+
+	// ObligationApproval requires a human to approve the call before the
+	// tool executes. The engine attaches it to calls that take a Budget
+	// past its approval threshold, with Params "budget" (session or
+	// tenant), "spent", and "threshold", and to calls a RiskRule requires
+	// approval of, with Params "risk" and "rule".
+	ObligationApproval = "approval"
+
+	// ObligationInjectCredential requires a short-lived credential to be
+	// issued for Params["path"] (e.g., "database/creds/orders-ro") and
+	// injected into the parameter Params["param"] (default "credential")
+	// before the tool executes, so that agents never hold it. "{domain}" in
+	// the path is replaced by the domain the call's parameters name, which
+	// the rule's domain constraints bound.
+	ObligationInjectCredential = "injectCredential"
+)
+
+// Obligation is a duty attached to an allow decision. Unlike a mutator,
+// which the engine applies itself, an obligation is carried out by the
+// caller: the call may only proceed once every obligation of the decision
+// has been fulfilled, and must fail if any cannot be (fail closed), as
+// with XACML obligations.
+type Obligation struct {
+	// Type identifies the duty (e.g., ObligationNotify)
+	Type string
+
+	// Params configure the duty (e.g., {"channel": "#security-alerts"})
+	Params map[string]string
+}
+
+// String describes the obligation, e.g. "notify channel=#security-alerts".
+func (o Obligation) String() string {
+	if len(o.Params) == 0 {
+		return o.Type
+	}
+	keys := make([]string, 0, len(o.Params))
+	for k := range o.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{o.Type}
+	for _, k := range keys {
+		parts = append(parts, k+"="+o.Params[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// Validate checks that the obligation has a type and the parameters its
+// type requires.
+func (o Obligation) Validate() error {
+	if o.Type == "" {
+		return fmt.Errorf("obligation type is required")
+	}
+	if o.Type == ObligationNotify && o.Params["channel"] == "" {
+		return fmt.Errorf("notify obligation: channel is required")
+	}
+	if o.Type == ObligationInjectCredential && o.Params["path"] == "" {
+		return fmt.Errorf("injectCredential obligation: path is required")
+	}
+	return nil
+}
+
+// ruleObligations returns the obligations of the allow rule for a tool,
+// or nil if the tool has no allow rule.
+func ruleObligations(policy *CompiledPolicy, toolName string) []Obligation {
+	perm, ok := policy.ToolTable[toolName]
+	if !ok || perm.Action != Allow {
+		return nil
+	}
+	return perm.Obligations
+}
+
+// obligationsFromOPA parses the "obligations" field of a Rego decision
+// object: a list of {"type": string, "params": {string: any}} objects.
+// Entries without a type are rejected, since an obligation that cannot be
+// identified cannot be fulfilled.
+func obligationsFromOPA(value interface{}) ([]Obligation, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("obligations must be a list, got %T", value)
+	}
+
+	obligations := make([]Obligation, 0, len(list))
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("obligation must be an object, got %T", item)
+		}
+		o := Obligation{}
+		o.Type, _ = obj["type"].(string)
+		if params, ok := obj["params"].(map[string]interface{}); ok && len(params) > 0 {
+			o.Params = make(map[string]string, len(params))
+			for k, v := range params {
+				o.Params[k] = fmt.Sprint(v)
+			}
+		}
+		if err := o.Validate(); err != nil {
+			return nil, err
+		}
+		obligations = append(obligations, o)
+	}
+	return obligations, nil
+}
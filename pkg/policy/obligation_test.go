@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// TestEngineObligations verifies allowed calls carry the tool rule's
+// obligations, including cached decisions, and denied calls carry none
+func TestEngineObligations(t *testing.T) {
+	notify := Obligation{Type: ObligationNotify, Params: map[string]string{"channel": "#security-alerts"}}
+	compiled := CompilePolicy("test-policy", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "file.write", Action: Allow, Obligations: []Obligation{{Type: ObligationLogPayload}, notify}},
+		{Tool: "file.read", Action: Allow},
+	}, Enforcing, "")
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", compiled)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	for _, cached := range []bool{false, true} {
+		result, err := engine.EvaluateWithResult(context.Background(), agent, "file.write", map[string]interface{}{"path": "/workspace/a"})
+		if err != nil {
+			t.Fatalf("EvaluateWithResult failed: %v", err)
+		}
+		if result.Cached != cached || len(result.Obligations) != 2 || result.Obligations[1].String() != "notify channel=#security-alerts" {
+			t.Errorf("expected obligations (cached %v), got %v (cached %v)", cached, result.Obligations, result.Cached)
+		}
+	}
+
+	if result, _ := engine.EvaluateWithResult(context.Background(), agent, "file.read", nil); result.Obligations != nil {
+		t.Errorf("expected no obligations for rule without any, got %v", result.Obligations)
+	}
+	if result, _ := engine.EvaluateWithResult(context.Background(), agent, "code.execute", nil); result.Decision != Deny || result.Obligations != nil {
+		t.Errorf("expected denial without obligations, got %v %v", result.Decision, result.Obligations)
+	}
+}
+
+// TestOPAObligations verifies obligations are read from the Rego decision
+// object, and that malformed obligations deny the call
+func TestOPAObligations(t *testing.T) {
+	evaluator := NewOPAEvaluator(nil, nil, Enforcing)
+	result := func(obligations interface{}) rego.Result {
+		return rego.Result{Expressions: []*rego.ExpressionValue{{Value: map[string]interface{}{
+			"allow": true, "deny": false, "mts": true, "reason": "tool explicitly allowed",
+			"obligations": obligations,
+		}}}}
+	}
+
+	decision, _, obligations, _ := evaluator.extractDecision(result([]interface{}{
+		map[string]interface{}{"type": "notify", "params": map[string]interface{}{"channel": "#security-alerts"}},
+	}))
+	want := []Obligation{{Type: ObligationNotify, Params: map[string]string{"channel": "#security-alerts"}}}
+	if decision != Allow || !reflect.DeepEqual(obligations, want) {
+		t.Errorf("expected allow with %v, got %v with %v", want, decision, obligations)
+	}
+
+	decision, reason, _, _ := evaluator.extractDecision(result([]interface{}{map[string]interface{}{"params": map[string]interface{}{}}}))
+	if decision != Deny || !strings.HasPrefix(reason, "invalid obligations") {
+		t.Errorf("expected deny for malformed obligations, got %v: %s", decision, reason)
+	}
+}
+
+// TestObligationsFromOPA verifies malformed Rego obligations are rejected
+func TestObligationsFromOPA(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr bool
+	}{
+		{"absent", nil, false},
+		{"valid", []interface{}{map[string]interface{}{"type": "notify", "params": map[string]interface{}{"channel": "#sec"}}}, false},
+		{"not a list", "notify", true},
+		{"missing type", []interface{}{map[string]interface{}{"params": map[string]interface{}{}}}, true},
+		{"notify without channel", []interface{}{map[string]interface{}{"type": "notify"}}, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := obligationsFromOPA(tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
@@ -14,12 +14,22 @@ package policy
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/open-policy-agent/opa/rego"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
 )
 
+// mtsViolationPrefix prefixes the reason of decisions denied by the mts
+// field of the decision object; the engine reports them as
+// policyerrors.ErrMTSViolation.
+const mtsViolationPrefix = "MTS violation: "
+
 // OPAPolicy represents a compiled OPA policy ready for high-speed evaluation.
 // The PreparedQuery is compiled once when the policy is loaded and reused
 // for every evaluation, avoiding the ~50ms compilation cost on each request.
@@ -63,6 +73,12 @@ type OPAEvaluator struct {
 
 	// mode is the global enforcement mode
 	mode EnforcementMode
+
+	// log receives evaluation failures (the engine's logger)
+	log *slog.Logger
+
+	// memo holds query results by input hash (nil if not memoizing)
+	memo *opaMemo
 }
 
 // OPAInput is the structured input passed to OPA for policy evaluation.
@@ -82,12 +98,24 @@ type OPAInput struct {
 }
 
 // OPAAgentInput represents the agent identity in OPA input.
+//
+// MTSDominates is set when the agent's MTS label, resolved from the
+// TenantRegistry, dominates the policy's: a parent tenant's agents dominate
+// the policies of the child tenants that delegate to it.
 type OPAAgentInput struct {
-	Type      string `json:"type"`
-	SandboxID string `json:"sandbox_id"`
-	TenantID  string `json:"tenant_id"`
-	SessionID string `json:"session_id"`
-	MTSLabel  string `json:"mts_label"`
+	Type         string            `json:"type"`
+	SandboxID    string            `json:"sandbox_id"`
+	TenantID     string            `json:"tenant_id"`
+	SessionID    string            `json:"session_id"`
+	MTSLabel     string            `json:"mts_label"`
+	MTSDominates bool              `json:"mts_dominates"`
+	Labels       map[string]string `json:"labels"`
+
+	// ParentSessionID, RootSessionID, and Lineage are the agent's
+	// delegation lineage (see AgentContext)
+	ParentSessionID string   `json:"parent_session_id"`
+	RootSessionID   string   `json:"root_session_id"`
+	Lineage         []string `json:"lineage"`
 }
 
 // OPAPolicyInput represents policy metadata in OPA input.
@@ -98,11 +126,21 @@ type OPAPolicyInput struct {
 
 // OPAOutput is the expected output structure from OPA evaluation.
 // The Rego policy must return a decision object matching this structure.
+//
+// Obligations is optional: duties the caller must fulfil before an allowed
+// call executes, each an object {"type": string, "params": {...}}.
 type OPAOutput struct {
-	Allow  bool   `json:"allow"`
-	Deny   bool   `json:"deny"`
-	MTS    bool   `json:"mts"`
-	Reason string `json:"reason"`
+	Allow       bool            `json:"allow"`
+	Deny        bool            `json:"deny"`
+	MTS         bool            `json:"mts"`
+	Reason      string          `json:"reason"`
+	Obligations []OPAObligation `json:"obligations,omitempty"`
+}
+
+// OPAObligation is one entry of the decision object's obligations list.
+type OPAObligation struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params,omitempty"`
 }
 
 // NewOPAEvaluator creates a new OPA evaluator with the given options.
@@ -112,6 +150,7 @@ func NewOPAEvaluator(cache *DecisionCache, audit AuditSink, mode EnforcementMode
 		cache:    cache,
 		audit:    audit,
 		mode:     mode,
+		log:      slog.Default(),
 	}
 }
 
@@ -127,51 +166,127 @@ func NewOPAEvaluator(cache *DecisionCache, audit AuditSink, mode EnforcementMode
 //   - (Deny, nil): Agent must not call tool
 //   - (_, error): Evaluation error (fail closed)
 func (e *OPAEvaluator) Evaluate(ctx context.Context, agent AgentContext, toolName string, request map[string]interface{}) (Decision, string, error) {
-	// Look up policy for agent type
+	// Look up policy for agent type, then the fallback policy
 	e.mu.RLock()
 	policy, exists := e.policies[agent.AgentType]
+	if !exists {
+		policy, exists = e.policies[FallbackAgentType]
+	}
 	e.mu.RUnlock()
 
 	if !exists {
 		return Deny, "no OPA policy defined for agent type", nil
 	}
 
+	decision, reason, _, err := e.evaluateQuery(ctx, policy.PreparedQuery, policy.Name, policy.MTSLabel, agent, toolName, request, false)
+	return decision, reason, err
+}
+
+// EvaluateCompiled evaluates a CompiledPolicy that was already resolved by
+// the Engine. This lets the engine's resolver (patterns, selectors,
+// fallback) decide which prepared query runs. It also returns the
+// obligations of the decision object.
+func (e *OPAEvaluator) EvaluateCompiled(ctx context.Context, policy *CompiledPolicy, agent AgentContext, toolName string, request map[string]interface{}) (Decision, string, []Obligation, error) {
+	if policy == nil {
+		return Deny, "no OPA policy defined for agent type", nil, nil
+	}
+	return e.evaluateCompiled(ctx, policy, policy.PreparedQuery, agent, toolName, request, false)
+}
+
+// evaluateCompiled is EvaluateCompiled with the policy's query, or one
+// specialized from it, reusing memoized results of the same input if
+// memoize is set and the evaluator memoizes (see WithOPAMemo).
+func (e *OPAEvaluator) evaluateCompiled(ctx context.Context, policy *CompiledPolicy, query *rego.PreparedEvalQuery, agent AgentContext, toolName string, request map[string]interface{}, memoize bool) (Decision, string, []Obligation, error) {
+	if policy == nil || query == nil {
+		return Deny, "no OPA policy defined for agent type", nil, nil
+	}
+
+	return e.evaluateQuery(ctx, *query, policy.Name, policy.MTSLabel, agent, toolName, request, memoize)
+}
+
+// evaluateQuery builds the OPA input and runs a prepared query. If memoize
+// is set, results are memoized by input; errors never are.
+func (e *OPAEvaluator) evaluateQuery(ctx context.Context, query rego.PreparedEvalQuery, policyName, policyMTSLabel string, agent AgentContext, toolName string, request map[string]interface{}, memoize bool) (Decision, string, []Obligation, error) {
 	// Build OPA input
 	input := OPAInput{
 		Tool:    toolName,
 		Request: request,
 		Agent: OPAAgentInput{
-			Type:      agent.AgentType,
-			SandboxID: agent.SandboxID,
-			TenantID:  agent.TenantID,
-			SessionID: agent.SessionID,
-			MTSLabel:  agent.MTSLabel,
+			Type:         agent.AgentType,
+			SandboxID:    agent.SandboxID,
+			TenantID:     agent.TenantID,
+			SessionID:    agent.SessionID,
+			MTSLabel:     agent.MTSLabel,
+			MTSDominates: mtsDominates(agent, policyMTSLabel),
+			Labels:       agent.Labels,
+
+			ParentSessionID: agent.ParentSessionID,
+			RootSessionID:   agent.RootSessionID(),
+			Lineage:         agent.Lineage,
 		},
 		Policy: OPAPolicyInput{
-			Name:     policy.Name,
-			MTSLabel: policy.MTSLabel,
+			Name:     policyName,
+			MTSLabel: policyMTSLabel,
 		},
 	}
 
+	var memoKey string
+	if memoize && e.memo != nil && !tracing(ctx) {
+		if key, ok := opaMemoKey(&input); ok {
+			if entry, ok := e.memo.get(key); ok {
+				return entry.decision, entry.reason, entry.obligations, nil
+			}
+			memoKey = key
+		}
+	}
+
 	// Evaluate using prepared query (fast path: ~100-500μs)
-	results, err := policy.PreparedQuery.Eval(ctx, rego.EvalInput(input))
+	opts, recordMetrics := opaMetricsOptions(ctx)
+	traceOpts, recordTrace := traceOptions(ctx, policyName, toolName)
+	opts = append(append([]rego.EvalOption{rego.EvalInput(input)}, opts...), traceOpts...)
+	results, err := query.Eval(ctx, opts...)
+	recordMetrics()
+	recordTrace()
 	if err != nil {
-		return Deny, fmt.Sprintf("OPA evaluation error: %v", err), err
+		// Queries cut short by ctx are the caller's to report
+		if ctx.Err() == nil {
+			e.log.LogAttrs(ctx, slog.LevelError, "OPA evaluation failed",
+				slog.String(LogKeyAgentType, agent.AgentType),
+				slog.String(LogKeyTool, toolName),
+				slog.String("policy", policyName),
+				slog.Any("error", err),
+			)
+		}
+		return Deny, fmt.Sprintf("OPA evaluation error: %v", err), nil, err
 	}
 
 	if len(results) == 0 {
-		return Deny, "OPA returned no results", nil
+		e.log.LogAttrs(ctx, slog.LevelWarn, "OPA query returned no results",
+			slog.String(LogKeyAgentType, agent.AgentType),
+			slog.String(LogKeyTool, toolName),
+			slog.String("policy", policyName),
+		)
+		if memoKey != "" {
+			e.memo.put(memoKey, Deny, "OPA returned no results", nil)
+		}
+		return Deny, "OPA returned no results", nil, nil
 	}
 
 	// Extract decision from OPA result
-	return e.extractDecision(results[0])
+	decision, reason, obligations, err := e.extractDecision(results[0])
+	if err == nil && memoKey != "" && ctx.Err() == nil {
+		e.memo.put(memoKey, decision, reason, obligations)
+	}
+	return decision, reason, obligations, err
 }
 
-// extractDecision parses the OPA evaluation result into a Decision.
-func (e *OPAEvaluator) extractDecision(result rego.Result) (Decision, string, error) {
+// extractDecision parses the OPA evaluation result into a Decision and the
+// obligations of the decision object. An allow with malformed obligations
+// is denied, since its duties cannot be carried out.
+func (e *OPAEvaluator) extractDecision(result rego.Result) (Decision, string, []Obligation, error) {
 	// OPA returns results as []rego.Result where each Result has Expressions
 	if len(result.Expressions) == 0 {
-		return Deny, "no expressions in OPA result", nil
+		return Deny, "no expressions in OPA result", nil, nil
 	}
 
 	// The first expression should be our decision object
@@ -183,11 +298,11 @@ func (e *OPAEvaluator) extractDecision(result rego.Result) (Decision, string, er
 		// If it's a simple boolean (from data.policy.allow query)
 		if allowed, ok := value.(bool); ok {
 			if allowed {
-				return Allow, "allowed by OPA policy", nil
+				return Allow, "allowed by OPA policy", nil, nil
 			}
-			return Deny, "denied by OPA policy", nil
+			return Deny, "denied by OPA policy", nil, nil
 		}
-		return Deny, "unexpected OPA result type", nil
+		return Deny, "unexpected OPA result type", nil, nil
 	}
 
 	// Extract fields from decision object
@@ -198,21 +313,27 @@ func (e *OPAEvaluator) extractDecision(result rego.Result) (Decision, string, er
 
 	// Check MTS first (tenant isolation takes precedence)
 	if mts, ok := decision["mts"].(bool); ok && !mts {
-		return Deny, "MTS violation: " + reason, nil
+		return Deny, mtsViolationPrefix + reason, nil, nil
 	}
 
 	// Check explicit deny
 	if denied, ok := decision["deny"].(bool); ok && denied {
-		return Deny, reason, nil
+		return Deny, reason, nil, nil
+	}
+
+	obligations, err := obligationsFromOPA(decision["obligations"])
+	if err != nil {
+		return Deny, fmt.Sprintf("invalid obligations: %v", err), nil, nil
 	}
 
 	// Check allow
 	if allowed, ok := decision["allow"].(bool); ok && allowed {
-		return Allow, reason, nil
+		return Allow, reason, obligations, nil
 	}
 
-	// Default deny (fail closed)
-	return Deny, "denied by default: " + reason, nil
+	// Default deny (fail closed). The obligations are kept for permissive
+	// mode, where the call still executes.
+	return Deny, "denied by default: " + reason, obligations, nil
 }
 
 // LoadPolicy compiles a Rego module and stores it for the given agent types.
@@ -243,12 +364,11 @@ func (e *OPAEvaluator) LoadPolicy(name string, agentTypes []string, regoModule s
 		e.policies[agentType] = policy
 	}
 	e.mu.Unlock()
+	e.log.Debug("loaded OPA policy", "policy", name, "agentTypes", agentTypes)
 
 	// Invalidate cache for affected agent types
-	if e.cache != nil {
-		for _, agentType := range agentTypes {
-			e.cache.InvalidatePrefix(agentType + ":")
-		}
+	for _, agentType := range agentTypes {
+		e.invalidateAgentType(agentType)
 	}
 
 	return nil
@@ -261,9 +381,23 @@ func (e *OPAEvaluator) RemovePolicy(agentType string) {
 	delete(e.policies, agentType)
 	e.mu.Unlock()
 
-	if e.cache != nil {
-		e.cache.InvalidatePrefix(agentType + ":")
+	e.invalidateAgentType(agentType)
+}
+
+// invalidateAgentType clears cached decisions for an agent type.
+// Changing the fallback policy clears the entire cache.
+func (e *OPAEvaluator) invalidateAgentType(agentType string) {
+	if e.memo != nil {
+		e.memo.invalidate(agentType)
 	}
+	if e.cache == nil {
+		return
+	}
+	if agentType == FallbackAgentType {
+		e.cache.InvalidateAll()
+		return
+	}
+	e.cache.InvalidatePrefix(agentType + ":")
 }
 
 // GetPolicy returns the policy for an agent type (for inspection/debugging).
@@ -293,25 +427,60 @@ func (e *OPAEvaluator) ListPolicies() []string {
 //
 //	package agentpolicy
 //	decision := {"allow": bool, "deny": bool, "mts": bool, "reason": string}
+//
+// and optionally "obligations": [{"type": string, "params": {...}}].
 func PrepareRegoQuery(regoModule string) (rego.PreparedEvalQuery, error) {
-	// Create Rego instance with the module
-	r := rego.New(
-		rego.Query("data.agentpolicy.decision"),
-		rego.Module("policy.rego", regoModule),
-	)
+	return PrepareRegoModules(map[string]string{"policy.rego": regoModule})
+}
+
+// PrepareRegoModules compiles a set of Rego modules, keyed by file name,
+// into a single PreparedEvalQuery for "data.agentpolicy.decision". The
+// modules may span several packages (e.g., agentpolicy and agentpolicy.tools.*).
+// Compile errors wrap policyerrors.ErrOPACompile.
+func PrepareRegoModules(modules map[string]string) (rego.PreparedEvalQuery, error) {
+	// Create Rego instance with the modules (sorted for deterministic errors)
+	opts := []func(*rego.Rego){rego.Query("data.agentpolicy.decision")}
+	for _, name := range sortedModuleNames(modules) {
+		opts = append(opts, rego.Module(name, modules[name]))
+	}
+	r := rego.New(opts...)
 
 	// Prepare for evaluation (compile to bytecode)
 	ctx := context.Background()
 	prepared, err := r.PrepareForEval(ctx)
 	if err != nil {
-		return rego.PreparedEvalQuery{}, fmt.Errorf("failed to prepare Rego query: %w", err)
+		return rego.PreparedEvalQuery{}, fmt.Errorf("%w: %w", policyerrors.ErrOPACompile, err)
 	}
 
 	return prepared, nil
 }
 
+// JoinRegoModules concatenates a module set into one document for display,
+// hashing, and audit, each module preceded by a "# file: <name>" line.
+// The result is not itself a valid Rego module.
+func JoinRegoModules(modules map[string]string) string {
+	var b strings.Builder
+	for _, name := range sortedModuleNames(modules) {
+		fmt.Fprintf(&b, "# file: %s\n%s", name, modules[name])
+		if !strings.HasSuffix(modules[name], "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func sortedModuleNames(modules map[string]string) []string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ValidateRegoModule checks if a Rego module is syntactically valid.
-// This is useful for validating policies before loading them.
+// This is useful for validating policies before loading them. Errors wrap
+// policyerrors.ErrOPACompile.
 func ValidateRegoModule(regoModule string) error {
 	r := rego.New(
 		rego.Query("data.agentpolicy.decision"),
@@ -319,6 +488,8 @@ func ValidateRegoModule(regoModule string) error {
 	)
 
 	ctx := context.Background()
-	_, err := r.PrepareForEval(ctx)
-	return err
+	if _, err := r.PrepareForEval(ctx); err != nil {
+		return fmt.Errorf("%w: %w", policyerrors.ErrOPACompile, err)
+	}
+	return nil
 }
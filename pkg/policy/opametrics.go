@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/metrics"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// OPAEvalBuckets are the upper bounds of the evaluation time buckets of
+// OPAEvalStats.
+var OPAEvalBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// OPAEvalMetrics are OPA's metrics of the queries of an evaluation.
+type OPAEvalMetrics struct {
+	// Queries is the number of OPA queries run; results reused from the
+	// OPA memo (see WithOPAMemo) are not
+	Queries int
+
+	// EvalTime is the time OPA spent evaluating the queries
+	EvalTime time.Duration
+
+	// Operations is the number of evaluation operations OPA performed,
+	// such as rule index lookups, plugs, resolves, and built-in calls: a
+	// measure of the work of the queries independent of the load of the
+	// router
+	Operations int64
+}
+
+// OPAEvalStats aggregates the metrics of the OPA queries of a policy rule.
+type OPAEvalStats struct {
+	// Policy is the policy, as "namespace/name" (or the name, for
+	// policies without a namespace)
+	Policy string
+
+	// Rule is the tool of the rule that decided the calls, or "" for
+	// calls to tools the policy has no rule for
+	Rule string
+
+	// Count is the number of queries, and EvalTime and Operations their
+	// sums
+	Count      uint64
+	EvalTime   time.Duration
+	Operations int64
+
+	// Buckets are the cumulative counts of queries by evaluation time:
+	// Buckets[i] counts those that took at most OPAEvalBuckets[i]
+	Buckets []uint64
+}
+
+// WithOPAMetrics collects OPA's metrics of every query: they are
+// aggregated by policy and rule (see Engine.OPAEvalStats), and returned
+// with the result of each evaluation (see EvaluationResult.OPAMetrics), to
+// tell which policy rules are slow. Instrumenting queries slows them
+// down; leave it disabled (the default) unless investigating latency.
+// Requires WithOPA.
+func WithOPAMetrics(enabled bool) Option {
+	return func(e *Engine) {
+		e.opaStats = nil
+		if enabled {
+			e.opaStats = &opaStatsStore{stats: make(map[opaStatsKey]*OPAEvalStats)}
+		}
+	}
+}
+
+// OPAEvalStats returns the metrics of the OPA queries run since the
+// engine was created, by policy and rule, sorted. Nil unless the engine
+// collects them (see WithOPAMetrics).
+func (e *Engine) OPAEvalStats() []OPAEvalStats {
+	if e.opaStats == nil {
+		return nil
+	}
+	e.opaStats.mu.Lock()
+	defer e.opaStats.mu.Unlock()
+	stats := make([]OPAEvalStats, 0, len(e.opaStats.stats))
+	for _, s := range e.opaStats.stats {
+		copied := *s
+		copied.Buckets = append([]uint64(nil), s.Buckets...)
+		stats = append(stats, copied)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Policy != stats[j].Policy {
+			return stats[i].Policy < stats[j].Policy
+		}
+		return stats[i].Rule < stats[j].Rule
+	})
+	return stats
+}
+
+// opaStatsKey identifies the rule of a policy stats are aggregated for.
+type opaStatsKey struct {
+	policy, rule string
+}
+
+// opaStatsStore aggregates the metrics of OPA queries by policy and rule.
+type opaStatsStore struct {
+	mu    sync.Mutex
+	stats map[opaStatsKey]*OPAEvalStats
+}
+
+// observe adds the metrics of a query of policy deciding a call to
+// toolName. Calls to tools the policy has no rule for share a rule, so
+// that callers cannot add rules by the tool names they call.
+func (s *opaStatsStore) observe(policy *CompiledPolicy, toolName string, m OPAEvalMetrics) {
+	key := opaStatsKey{policy: policyRef(policy)}
+	if _, ok := policy.ToolTable[toolName]; ok {
+		key.rule = toolName
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = &OPAEvalStats{Policy: key.policy, Rule: key.rule, Buckets: make([]uint64, len(OPAEvalBuckets))}
+		s.stats[key] = stats
+	}
+	stats.Count++
+	stats.EvalTime += m.EvalTime
+	stats.Operations += m.Operations
+	for i, bound := range OPAEvalBuckets {
+		if m.EvalTime <= bound {
+			stats.Buckets[i]++
+		}
+	}
+}
+
+// Context keys of the OPAEvalMetrics the queries of an evaluation add
+// theirs to, and one query records its own in
+type (
+	opaMetricsKey      struct{}
+	opaQueryMetricsKey struct{}
+)
+
+// withOPAMetrics returns a context whose OPA queries add their metrics to
+// the returned OPAEvalMetrics, if the engine collects them.
+func (e *Engine) withOPAMetrics(ctx context.Context) (context.Context, *OPAEvalMetrics) {
+	if e.opaStats == nil || e.opaEval == nil {
+		return ctx, nil
+	}
+	m := &OPAEvalMetrics{}
+	return context.WithValue(ctx, opaMetricsKey{}, m), m
+}
+
+// withQueryMetrics returns a context for one OPA query that
+// records its metrics in the returned OPAEvalMetrics, if the evaluation
+// run with ctx collects them.
+func withQueryMetrics(ctx context.Context) (context.Context, *OPAEvalMetrics) {
+	if _, ok := ctx.Value(opaMetricsKey{}).(*OPAEvalMetrics); !ok {
+		return ctx, nil
+	}
+	m := &OPAEvalMetrics{}
+	return context.WithValue(ctx, opaQueryMetricsKey{}, m), m
+}
+
+// opaMetricsOptions returns the evaluation options that collect the
+// metrics of a query run with ctx, if it records them, and a function
+// that records them once the query has run.
+func opaMetricsOptions(ctx context.Context) ([]rego.EvalOption, func()) {
+	recorded, ok := ctx.Value(opaQueryMetricsKey{}).(*OPAEvalMetrics)
+	if !ok {
+		return nil, func() {}
+	}
+	m := metrics.New()
+	return []rego.EvalOption{rego.EvalMetrics(m), rego.EvalInstrument(true)}, func() {
+		*recorded = queryMetrics(m)
+	}
+}
+
+// queryMetrics returns OPA's metrics of one query.
+func queryMetrics(m metrics.Metrics) OPAEvalMetrics {
+	result := OPAEvalMetrics{Queries: 1, EvalTime: time.Duration(m.Timer(metrics.RegoQueryEval).Int64())}
+	for name, value := range m.All() {
+		switch {
+		case strings.HasPrefix(name, "counter_eval_op_"):
+			if n, ok := value.(uint64); ok {
+				result.Operations += int64(n)
+			}
+		case strings.HasPrefix(name, "histogram_eval_op_"):
+			if h, ok := value.(map[string]interface{}); ok {
+				if n, ok := h["count"].(int64); ok {
+					result.Operations += n
+				}
+			}
+		}
+	}
+	return result
+}
+
+// recordOPAMetrics adds the metrics of a query of policy deciding a call
+// to toolName to those of the evaluation run with ctx, and to the
+// engine's stats. Queries that were not run, such as memoized ones, have
+// none.
+func (e *Engine) recordOPAMetrics(ctx context.Context, policy *CompiledPolicy, toolName string, m *OPAEvalMetrics) {
+	evaluation, ok := ctx.Value(opaMetricsKey{}).(*OPAEvalMetrics)
+	if !ok || m == nil || m.Queries == 0 {
+		return
+	}
+	evaluation.Queries += m.Queries
+	evaluation.EvalTime += m.EvalTime
+	evaluation.Operations += m.Operations
+	e.opaStats.observe(policy, toolName, *m)
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOPAEvalStats tests that query metrics are aggregated by policy and
+// rule into cumulative evaluation time buckets, and that calls to tools a
+// policy has no rule for share a rule.
+func TestOPAEvalStats(t *testing.T) {
+	engine := NewEngine(WithOPAMetrics(true))
+	p := CompilePolicy("team", []string{"coding-assistant"}, Deny, []ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, "")
+	p.Namespace = "agents"
+
+	engine.opaStats.observe(p, "file.read", OPAEvalMetrics{Queries: 1, EvalTime: 80 * time.Microsecond, Operations: 12})
+	engine.opaStats.observe(p, "file.read", OPAEvalMetrics{Queries: 1, EvalTime: 3 * time.Millisecond, Operations: 30})
+	engine.opaStats.observe(p, "shell.exec", OPAEvalMetrics{Queries: 1, EvalTime: time.Second, Operations: 5})
+	engine.opaStats.observe(p, "made.up", OPAEvalMetrics{Queries: 1, EvalTime: time.Second, Operations: 5})
+
+	stats := engine.OPAEvalStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 rules, got %+v", stats)
+	}
+	unknown, read := stats[0], stats[1]
+	if unknown.Policy != "agents/team" || unknown.Rule != "" || unknown.Count != 2 || unknown.Operations != 10 {
+		t.Errorf("expected calls without a rule to share one, got %+v", unknown)
+	}
+	if unknown.Buckets[len(OPAEvalBuckets)-1] != 0 {
+		t.Errorf("expected queries slower than every bucket in none, got %v", unknown.Buckets)
+	}
+	if read.Rule != "file.read" || read.Count != 2 || read.EvalTime != 3080*time.Microsecond || read.Operations != 42 {
+		t.Errorf("unexpected file.read stats %+v", read)
+	}
+	for i, bound := range OPAEvalBuckets {
+		want := uint64(0)
+		if bound >= 80*time.Microsecond {
+			want++
+		}
+		if bound >= 3*time.Millisecond {
+			want++
+		}
+		if read.Buckets[i] != want {
+			t.Errorf("bucket %s: expected %d, got %d", bound, want, read.Buckets[i])
+		}
+	}
+
+	// Evaluations without OPA have no metrics
+	engine.LoadPolicy("coding-assistant", p)
+	result, err := engine.EvaluateWithResult(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OPAMetrics != nil {
+		t.Errorf("expected no OPA metrics without OPA, got %+v", result.OPAMetrics)
+	}
+	if stats := NewEngine().OPAEvalStats(); stats != nil {
+		t.Errorf("expected no stats unless collected, got %+v", stats)
+	}
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// MaxTraceSize bounds the trace of an evaluation (see ContextWithTrace);
+// longer traces are truncated.
+const MaxTraceSize = 256 << 10
+
+// traceKey is the context key of the trace of an evaluation.
+type traceKey struct{}
+
+// opaTrace collects the traces of the OPA queries of an evaluation.
+type opaTrace struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// ContextWithTrace returns a context whose evaluation traces its OPA
+// queries: EvaluationResult.Trace (and Explanation.Trace) holds the
+// trace, with the location of each step in the policy's Rego, to explain
+// a decision without reproducing it offline. A traced evaluation bypasses
+// the decision cache and the OPA memo, so that its queries run, and does
+// not cache its decision. Tracing is slow; trace single calls only.
+func ContextWithTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, traceKey{}, &opaTrace{})
+}
+
+// tracing reports whether the evaluation run with ctx is traced.
+func tracing(ctx context.Context) bool {
+	_, ok := ctx.Value(traceKey{}).(*opaTrace)
+	return ok
+}
+
+// traceOf returns the trace of the evaluation run with ctx, or "" if it
+// is not traced or ran no OPA query.
+func traceOf(ctx context.Context) string {
+	trace, ok := ctx.Value(traceKey{}).(*opaTrace)
+	if !ok {
+		return ""
+	}
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	return trace.buf.String()
+}
+
+// traceOptions returns the evaluation options that trace a query of
+// policyName run with ctx, if its evaluation is traced, and a function
+// that adds the trace to the evaluation's once the query has run.
+func traceOptions(ctx context.Context, policyName, toolName string) ([]rego.EvalOption, func()) {
+	trace, ok := ctx.Value(traceKey{}).(*opaTrace)
+	if !ok {
+		return nil, func() {}
+	}
+	tracer := topdown.NewBufferTracer()
+	return []rego.EvalOption{rego.EvalQueryTracer(tracer)}, func() {
+		var query bytes.Buffer
+		fmt.Fprintf(&query, "# policy %s, tool %s\n", policyName, toolName)
+		topdown.PrettyTraceWithLocation(&query, *tracer)
+
+		trace.mu.Lock()
+		defer trace.mu.Unlock()
+		if trace.buf.Len()+query.Len() > MaxTraceSize {
+			query.Truncate(max(MaxTraceSize-trace.buf.Len(), 0))
+			query.WriteString("\n# trace truncated\n")
+		}
+		trace.buf.Write(query.Bytes())
+	}
+}
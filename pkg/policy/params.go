@@ -0,0 +1,320 @@
+package policy
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numericParams are the parameter names that constraints compare numerically.
+// Their values are coerced to int64 for both legacy checks and OPA input.
+var numericParams = []string{"size", "port", "timeout_ms"}
+
+// FileParams are the typed parameters for file operations (file.read, file.write).
+type FileParams struct {
+	// Path is the target file path
+	Path string
+
+	// Size is the number of bytes to write (0 if not applicable)
+	Size int64
+}
+
+// NetworkParams are the typed parameters for network operations (network.fetch).
+type NetworkParams struct {
+	// Domain is the target host name
+	Domain string
+
+	// Port is the target port (0 if not specified)
+	Port int
+
+	// URL is the full request URL (optional)
+	URL string
+
+	// Method is the request method (optional)
+	Method string
+}
+
+// ExecParams are the typed parameters for execution operations (code.execute).
+type ExecParams struct {
+	// Command is the program or script to run
+	Command string
+
+	// Args are the command arguments
+	Args []string
+
+	// WorkingDir is the directory to run the command in
+	WorkingDir string
+
+	// Timeout is the requested execution timeout (0 if not specified)
+	Timeout time.Duration
+
+	// ContentSHA256 is the SHA-256 digest of the script or artifact the
+	// command runs (optional; required by AllowedContentHashes constraints)
+	ContentSHA256 string
+}
+
+// ToolRequest carries tool parameters in both raw and typed form.
+// The router builds it from the typed oneof in agentpb.ExecuteRequest;
+// constraint checking reads the typed forms and falls back to the raw map.
+type ToolRequest struct {
+	// Parameters are the raw JSON-decoded parameters
+	Parameters map[string]interface{}
+
+	// File is set for file operations
+	File *FileParams
+
+	// Network is set for network operations
+	Network *NetworkParams
+
+	// Exec is set for execution operations
+	Exec *ExecParams
+}
+
+// ParameterMap returns the raw parameters merged with the typed fields,
+// so Rego policies and executors see a single flat parameter map.
+// Typed fields take precedence over raw values of the same name.
+func (r *ToolRequest) ParameterMap() map[string]interface{} {
+	params := make(map[string]interface{}, len(r.Parameters)+4)
+	for k, v := range r.Parameters {
+		params[k] = v
+	}
+
+	if f := r.File; f != nil {
+		if f.Path != "" {
+			params["path"] = f.Path
+		}
+		if f.Size > 0 {
+			params["size"] = f.Size
+		}
+	}
+
+	if n := r.Network; n != nil {
+		if n.Domain != "" {
+			params["domain"] = n.Domain
+		}
+		if n.Port > 0 {
+			params["port"] = n.Port
+		}
+		if n.URL != "" {
+			params["url"] = n.URL
+		}
+		if n.Method != "" {
+			params["method"] = n.Method
+		}
+	}
+
+	if x := r.Exec; x != nil {
+		if x.Command != "" {
+			params["command"] = x.Command
+		}
+		if len(x.Args) > 0 {
+			params["args"] = x.Args
+		}
+		if x.WorkingDir != "" {
+			params["working_dir"] = x.WorkingDir
+		}
+		if x.Timeout > 0 {
+			params["timeout_ms"] = x.Timeout.Milliseconds()
+		}
+		if x.ContentSHA256 != "" {
+			params[ContentHashParam] = x.ContentSHA256
+		}
+	}
+
+	return params
+}
+
+// typedParams is the typed view of a request used by constraint checking.
+type typedParams struct {
+	file    FileParams
+	network NetworkParams
+	exec    ExecParams
+
+	// malformed records numeric parameters that were present but could
+	// not be coerced; constraints on them fail closed
+	malformed map[string]bool
+}
+
+// toTypedParams builds the typed view of a request.
+// Returns false if the request carries no structured parameters.
+func toTypedParams(request interface{}) (typedParams, bool) {
+	var tp typedParams
+
+	switch r := request.(type) {
+	case *ToolRequest:
+		if r == nil {
+			return tp, false
+		}
+		tp = typedFromMap(r.Parameters)
+		if r.File != nil {
+			tp.file = *r.File
+		}
+		if r.Network != nil {
+			tp.network = *r.Network
+		}
+		if r.Exec != nil {
+			tp.exec = *r.Exec
+		}
+		return tp, true
+	case map[string]interface{}:
+		return typedFromMap(r), true
+	default:
+		return tp, false
+	}
+}
+
+// typedFromMap derives typed parameters from a raw parameter map.
+func typedFromMap(params map[string]interface{}) typedParams {
+	var tp typedParams
+
+	for _, key := range numericParams {
+		if v, ok := params[key]; ok {
+			if _, ok := CoerceInt64(v); !ok {
+				if tp.malformed == nil {
+					tp.malformed = make(map[string]bool)
+				}
+				tp.malformed[key] = true
+			}
+		}
+	}
+
+	if path, ok := params["path"].(string); ok {
+		tp.file.Path = path
+	}
+	if size, ok := CoerceInt64(params["size"]); ok {
+		tp.file.Size = size
+	}
+
+	if domain, ok := params["domain"].(string); ok {
+		tp.network.Domain = domain
+	}
+	if port, ok := CoerceInt64(params["port"]); ok {
+		tp.network.Port = int(port)
+	}
+
+	if command, ok := params["command"].(string); ok {
+		tp.exec.Command = command
+	}
+	if timeoutMs, ok := CoerceInt64(params["timeout_ms"]); ok {
+		tp.exec.Timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	if digest, ok := params[ContentHashParam].(string); ok {
+		tp.exec.ContentSHA256 = digest
+	}
+
+	return tp
+}
+
+// requestParameterMap converts an Evaluate request into a flat parameter map
+// with numeric parameters normalized, for use as OPA input.
+func requestParameterMap(request interface{}) map[string]interface{} {
+	switch r := request.(type) {
+	case *ToolRequest:
+		if r != nil {
+			return normalizeNumericParams(r.ParameterMap())
+		}
+	case map[string]interface{}:
+		return normalizeNumericParams(r)
+	}
+	return make(map[string]interface{})
+}
+
+// normalizeNumericParams returns a copy of params with numeric parameters
+// coerced to int64 and the content hash in canonical form. Values that
+// cannot be normalized are left unchanged so that constraints comparing
+// them fail closed.
+func normalizeNumericParams(params map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		normalized[k] = v
+	}
+	for _, key := range numericParams {
+		if v, ok := params[key]; ok {
+			if n, ok := CoerceInt64(v); ok {
+				normalized[key] = n
+			}
+		}
+	}
+	if digest, ok := params[ContentHashParam].(string); ok {
+		if d, ok := NormalizeContentHash(digest); ok {
+			normalized[ContentHashParam] = d
+		}
+	}
+	return normalized
+}
+
+// CoerceInt64 converts a decoded parameter value to int64.
+//
+// JSON decoding produces float64 for every number, so a plain int64 type
+// assertion never matches gRPC payloads. Accepted forms:
+//   - Go integer types (int, int32, int64, ...)
+//   - float64/float32 with no fractional part
+//   - json.Number
+//   - decimal strings ("1024")
+//
+// Returns false for fractional, out-of-range, or non-numeric values.
+func CoerceInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return uintToInt64(uint64(n))
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return uintToInt64(n)
+	case float32:
+		return floatToInt64(float64(n))
+	case float64:
+		return floatToInt64(n)
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := n.Float64(); err == nil {
+			return floatToInt64(f)
+		}
+		return 0, false
+	case string:
+		s := strings.TrimSpace(n)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+}
+
+// floatToInt64 converts integral floats within int64 range.
+func floatToInt64(f float64) (int64, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+		return 0, false
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// uintToInt64 converts unsigned values that fit in int64.
+func uintToInt64(u uint64) (int64, bool) {
+	if u > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(u), true
+}
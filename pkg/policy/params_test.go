@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// TestCoerceInt64 verifies numeric coercion of decoded parameter values
+func TestCoerceInt64(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  int64
+		ok    bool
+	}{
+		{"int", 42, 42, true},
+		{"int32", int32(42), 42, true},
+		{"int64", int64(42), 42, true},
+		{"float64 integral", float64(1024), 1024, true},
+		{"float64 fractional", 10.5, 0, false},
+		{"json.Number", json.Number("2048"), 2048, true},
+		{"string", "4096", 4096, true},
+		{"string with spaces", " 80 ", 80, true},
+		{"non-numeric string", "large", 0, false},
+		{"nil", nil, 0, false},
+		{"bool", true, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CoerceInt64(tt.input)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("CoerceInt64(%v) = (%d, %v), want (%d, %v)", tt.input, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// TestEngineNumericConstraintsFromJSON verifies size and port constraints
+// against parameters decoded from JSON, as they arrive over gRPC
+func TestEngineNumericConstraintsFromJSON(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+
+	policy := CompilePolicy(
+		"test-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{
+				Tool:        "file.write",
+				Action:      Allow,
+				Constraints: &ToolConstraints{MaxSizeBytes: 1024},
+			},
+			{
+				Tool:        "network.fetch",
+				Action:      Allow,
+				Constraints: &ToolConstraints{AllowedPorts: []int{443}},
+			},
+		},
+		Enforcing,
+		"",
+	)
+	engine.LoadPolicy("coding-assistant", policy)
+
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	tests := []struct {
+		tool     string
+		payload  string
+		expected Decision
+	}{
+		{"file.write", `{"size": 512}`, Allow},
+		{"file.write", `{"size": 4096}`, Deny},
+		{"file.write", `{"size": "4096"}`, Deny},
+		{"file.write", `{"size": "large"}`, Deny},
+		{"file.write", `{"size": 10.5}`, Deny},
+		{"network.fetch", `{"domain": "example.com", "port": 443}`, Allow},
+		{"network.fetch", `{"domain": "example.com", "port": 8080}`, Deny},
+		{"network.fetch", `{"domain": "example.com", "port": "8080"}`, Deny},
+	}
+
+	for _, tt := range tests {
+		engine.cache.InvalidateAll()
+
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.payload), &params); err != nil {
+			t.Fatalf("invalid payload %s: %v", tt.payload, err)
+		}
+
+		decision, _ := engine.Evaluate(context.Background(), agent, tt.tool, params)
+		if decision != tt.expected {
+			t.Errorf("%s %s: expected %v, got %v", tt.tool, tt.payload, tt.expected, decision)
+		}
+	}
+}
+
+// TestNormalizeNumericParams verifies OPA input receives int64 numerics
+func TestNormalizeNumericParams(t *testing.T) {
+	params := map[string]interface{}{
+		"path": "/workspace/a.txt",
+		"size": float64(2048),
+		"port": "443",
+	}
+
+	normalized := requestParameterMap(params)
+
+	if normalized["size"] != int64(2048) {
+		t.Errorf("expected size int64(2048), got %#v", normalized["size"])
+	}
+	if normalized["port"] != int64(443) {
+		t.Errorf("expected port int64(443), got %#v", normalized["port"])
+	}
+	if normalized["path"] != "/workspace/a.txt" {
+		t.Errorf("expected path unchanged, got %#v", normalized["path"])
+	}
+	if _, ok := params["size"].(float64); !ok {
+		t.Error("expected original params to be left unmodified")
+	}
+}
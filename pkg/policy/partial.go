@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// partialKnown are the input fields fixed when a policy is loaded for an
+// agent type, which partial evaluation folds into the specialized query.
+var partialKnown = []string{
+	"input.agent.type",
+	"input.policy.name",
+	"input.policy.mts_label",
+}
+
+// partialUnknowns are the input fields that vary per call. Every OPAInput
+// field must be in partialKnown or here: a field in neither would be
+// undefined in specialized queries.
+var partialUnknowns = []string{
+	"input.tool",
+	"input.request",
+	"input.agent.sandbox_id",
+	"input.agent.tenant_id",
+	"input.agent.session_id",
+	"input.agent.mts_label",
+	"input.agent.mts_dominates",
+	"input.agent.labels",
+	"input.agent.parent_session_id",
+	"input.agent.root_session_id",
+	"input.agent.lineage",
+}
+
+// WithPartialEval specializes the prepared query of OPA policies for each
+// agent type they are loaded under, when they are loaded: OPA partial
+// evaluation binds the agent type and the policy's name and MTS label, so
+// the rules that only depend on them are evaluated once rather than on
+// every call. Policies loaded under a pattern or as the fallback, and
+// policies whose Rego partial evaluation does not support, are evaluated
+// with their standard prepared query. Requires WithOPA.
+func WithPartialEval(enabled bool) Option {
+	return func(e *Engine) {
+		e.partialEval = enabled
+	}
+}
+
+// specializedQueries holds the specialized prepared queries by agent type.
+type specializedQueries struct {
+	mu      sync.RWMutex
+	queries map[string]specializedQuery
+}
+
+// specializedQuery is a policy's query specialized for an agent type.
+type specializedQuery struct {
+	policy *CompiledPolicy
+	query  rego.PreparedEvalQuery
+}
+
+// get returns the query of policy specialized for agentType.
+func (s *specializedQueries) get(agentType string, policy *CompiledPolicy) (*rego.PreparedEvalQuery, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, ok := s.queries[agentType]
+	if !ok || q.policy != policy {
+		return nil, false
+	}
+	return &q.query, true
+}
+
+// set stores the query of policy specialized for agentType.
+func (s *specializedQueries) set(agentType string, policy *CompiledPolicy, query rego.PreparedEvalQuery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queries == nil {
+		s.queries = make(map[string]specializedQuery)
+	}
+	s.queries[agentType] = specializedQuery{policy: policy, query: query}
+}
+
+// delete removes the specialized query of agentType.
+func (s *specializedQueries) delete(agentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queries, agentType)
+}
+
+// specialize stores the query of an OPA policy specialized for the agent
+// type it is loaded under, or removes a stale one if the policy cannot be
+// specialized.
+func (e *Engine) specialize(agentType string, policy *CompiledPolicy) {
+	if !e.partialEval || !e.shouldUseOPA(policy) || agentType == FallbackAgentType || IsAgentTypePattern(agentType) {
+		e.specialized.delete(agentType)
+		return
+	}
+	query, err := SpecializeRegoQuery(policy, agentType)
+	if err != nil {
+		e.specialized.delete(agentType)
+		e.log.Debug("using the standard OPA query", LogKeyAgentType, agentType, "policy", policy.Name, "error", err)
+		return
+	}
+	e.specialized.set(agentType, policy, query)
+}
+
+// preparedQuery returns the query to evaluate policy with for agentType:
+// its specialized query, if any, or else its standard one.
+func (e *Engine) preparedQuery(policy *CompiledPolicy, agentType string) *rego.PreparedEvalQuery {
+	if query, ok := e.specialized.get(agentType, policy); ok {
+		return query
+	}
+	return policy.PreparedQuery
+}
+
+// SpecializeRegoQuery partially evaluates the Rego of an OPA policy for an
+// agent type and prepares the residual query, which answers every input
+// of that agent type like the policy's PreparedQuery. Returns an error if
+// the Rego uses constructs partial evaluation does not support.
+func SpecializeRegoQuery(policy *CompiledPolicy, agentType string) (rego.PreparedEvalQuery, error) {
+	modules := policy.RegoModules
+	if modules == nil {
+		modules = map[string]string{"policy.rego": policy.RegoModule}
+	}
+
+	opts := []func(*rego.Rego){
+		rego.Query("data.agentpolicy.decision"),
+		rego.Input(map[string]interface{}{
+			"agent":  map[string]interface{}{"type": agentType},
+			"policy": map[string]interface{}{"name": policy.Name, "mts_label": policy.MTSLabel},
+		}),
+		rego.Unknowns(partialUnknowns),
+	}
+	for _, name := range sortedModuleNames(modules) {
+		opts = append(opts, rego.Module(name, modules[name]))
+	}
+
+	ctx := context.Background()
+	partial, err := rego.New(opts...).PartialResult(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("partial evaluation failed: %w", err)
+	}
+	prepared, err := partial.Rego().PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("failed to prepare specialized query: %w", err)
+	}
+	return prepared, nil
+}
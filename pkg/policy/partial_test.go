@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// TestPartialInputFields verifies every OPAInput field is either bound or
+// left unknown by partial evaluation
+func TestPartialInputFields(t *testing.T) {
+	classified := make(map[string]int)
+	for _, ref := range append(append([]string(nil), partialKnown...), partialUnknowns...) {
+		classified[ref]++
+	}
+
+	var walk func(prefix string, typ reflect.Type)
+	walk = func(prefix string, typ reflect.Type) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			ref := prefix + "." + name
+			if f.Type.Kind() == reflect.Struct {
+				walk(ref, f.Type)
+				continue
+			}
+			if n := classified[ref]; n != 1 {
+				t.Errorf("expected %s to be known or unknown exactly once, got %d", ref, n)
+			}
+			delete(classified, ref)
+		}
+	}
+	walk("input", reflect.TypeOf(OPAInput{}))
+
+	for ref := range classified {
+		t.Errorf("%s is not an OPAInput field", ref)
+	}
+}
+
+// TestEnginePartialEvalFallback verifies policies that cannot be
+// specialized, or are loaded under a pattern, use their standard query
+func TestEnginePartialEvalFallback(t *testing.T) {
+	engine := NewEngine(WithOPA(true), WithPartialEval(true))
+
+	policy := CompilePolicy("broken-policy", []string{"coding-assistant"}, Deny, nil, Enforcing, "")
+	policy.OPAEnabled = true
+	policy.RegoModule = "package"
+	policy.PreparedQuery = &rego.PreparedEvalQuery{}
+	engine.LoadPolicy("coding-assistant", policy)
+	engine.LoadPolicy("coding-*", policy)
+
+	for _, agentType := range []string{"coding-assistant", "coding-*"} {
+		if got := engine.preparedQuery(policy, agentType); got != policy.PreparedQuery {
+			t.Errorf("%s: expected the standard query", agentType)
+		}
+	}
+
+	// A query specialized from a replaced policy is not used
+	engine.specialized.set("data-analyst", policy, rego.PreparedEvalQuery{})
+	replacement := CompilePolicy("data-policy", []string{"data-analyst"}, Deny, nil, Enforcing, "")
+	replacement.PreparedQuery = &rego.PreparedEvalQuery{}
+	if got := engine.preparedQuery(replacement, "data-analyst"); got != replacement.PreparedQuery {
+		t.Error("expected the replacement's standard query")
+	}
+	if got := engine.preparedQuery(policy, "data-analyst"); got == policy.PreparedQuery {
+		t.Error("expected the specialized query of the policy it was specialized from")
+	}
+}
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathTraversal is returned by NormalizePath for paths that could escape
+// the directory a path pattern confines them to.
+var ErrPathTraversal = errors.New("path traversal rejected")
+
+// NormalizePath returns the canonical form of a request path for matching
+// against path patterns.
+//
+// Paths containing a ".." segment are rejected rather than resolved: a
+// pattern like /workspace/** would otherwise match /workspace/../etc/passwd
+// by prefix, and resolving ".." lexically is wrong when an earlier segment
+// is a symlink. NUL bytes are rejected because the OS truncates the path at
+// them. Everything else is cleaned with filepath.Clean, which collapses
+// repeated separators and "." segments and drops a trailing separator.
+func NormalizePath(path string) (string, error) {
+	if strings.ContainsRune(path, 0) {
+		return "", fmt.Errorf("%w: NUL byte in %q", ErrPathTraversal, path)
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w: %q contains \"..\"", ErrPathTraversal, path)
+		}
+	}
+	return filepath.Clean(path), nil
+}
+
+// ResolvePathSymlinks resolves symlinks in an absolute, normalized path
+// against the local filesystem. Only the longest existing prefix is
+// resolved, so paths of files that do not exist yet (e.g., for file.write)
+// resolve through their parent directory.
+//
+// This is only meaningful when the engine sees the same filesystem as the
+// tool that will open the path, such as a router sharing the sandbox's
+// workspace volume. The check is inherently racy against a tool that can
+// create symlinks between policy evaluation and execution.
+func ResolvePathSymlinks(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("cannot resolve symlinks in relative path %q", path)
+	}
+
+	existing, rest := path, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to resolve %q: %w", path, err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+	return filepath.Join(resolved, rest), nil
+}
+
+// canonicalPath normalizes a request path for constraint checking and, if
+// the constraints ask for it, resolves symlinks.
+func canonicalPath(constraints *ToolConstraints, path string) (string, error) {
+	normalized, err := NormalizePath(path)
+	if err != nil {
+		return "", err
+	}
+	if constraints.ResolveSymlinks {
+		return ResolvePathSymlinks(normalized)
+	}
+	return normalized, nil
+}
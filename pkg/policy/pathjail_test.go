@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNormalizePath verifies canonicalization and traversal rejection
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		reject   bool
+	}{
+		{path: "/workspace/src/main.go", expected: "/workspace/src/main.go"},
+		{path: "/workspace//src/./main.go", expected: "/workspace/src/main.go"},
+		{path: "/workspace/src/", expected: "/workspace/src"},
+		{path: "/workspace/./.", expected: "/workspace"},
+		{path: "/workspace/..hidden", expected: "/workspace/..hidden"},
+		{path: "/workspace/a..b/c", expected: "/workspace/a..b/c"},
+		{path: "/workspace/../etc/passwd", reject: true},
+		{path: "/workspace/src/../../etc/passwd", reject: true},
+		{path: "/workspace/..", reject: true},
+		{path: "/workspace/.//../etc", reject: true},
+		{path: "../etc/passwd", reject: true},
+		{path: "..", reject: true},
+		{path: "/workspace/a\x00/../../etc/passwd", reject: true},
+		{path: "/workspace/ok.txt\x00.png", reject: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePath(tt.path)
+		if tt.reject {
+			if !errors.Is(err, ErrPathTraversal) {
+				t.Errorf("%q: expected ErrPathTraversal, got %q, %v", tt.path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: expected %q, got %q, %v", tt.path, tt.expected, got, err)
+		}
+	}
+}
+
+// TestEnginePathTraversal verifies adversarial paths cannot satisfy a
+// path pattern by prefix
+func TestEnginePathTraversal(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy(
+		"test-policy",
+		[]string{"coding-assistant"},
+		Deny,
+		[]ToolPermission{
+			{
+				Tool:        "file.read",
+				Action:      Allow,
+				Constraints: &ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			},
+		},
+		Enforcing,
+		"",
+	))
+
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	tests := []struct {
+		path     string
+		expected Decision
+	}{
+		{"/workspace/src/main.go", Allow},
+		{"/workspace//src/./main.go", Allow},
+		{"/workspace/../etc/passwd", Deny},
+		{"/workspace/src/../../etc/shadow", Deny},
+		{"/workspace/..", Deny},
+		{"/workspace/./../root/.ssh/id_rsa", Deny},
+		{"/workspace/a\x00/../../etc/passwd", Deny},
+		{"/workspace-evil/secrets", Deny},
+		{"//etc/passwd", Deny},
+	}
+
+	// The cache is not invalidated between calls: the allow of the first
+	// must not be reused for the others
+	for _, tt := range tests {
+		decision, _ := engine.Evaluate(context.Background(), agent, "file.read", &ToolRequest{File: &FileParams{Path: tt.path}})
+		if decision != tt.expected {
+			t.Errorf("path %q: expected %v, got %v", tt.path, tt.expected, decision)
+		}
+	}
+}
+
+// TestResolvePathSymlinks verifies symlinks out of the workspace are
+// followed when ResolveSymlinks is set
+func TestResolvePathSymlinks(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "workspace")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{workspace, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	// Resolve the temp dir itself, which may sit behind a symlink
+	realWorkspace, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		t.Fatalf("failed to resolve workspace: %v", err)
+	}
+
+	resolved, err := ResolvePathSymlinks(filepath.Join(workspace, "escape", "new", "file.txt"))
+	if err != nil {
+		t.Fatalf("ResolvePathSymlinks failed: %v", err)
+	}
+	if realOutside, _ := filepath.EvalSymlinks(outside); resolved != filepath.Join(realOutside, "new", "file.txt") {
+		t.Errorf("expected path through the symlink target, got %q", resolved)
+	}
+
+	if _, err := ResolvePathSymlinks("relative/path"); err == nil {
+		t.Error("expected error for relative path")
+	}
+
+	constraints := &ToolConstraints{PathPatterns: []string{realWorkspace + "/**"}}
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.write", Action: Allow, Constraints: constraints}}, Enforcing, ""))
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	tests := []struct {
+		name     string
+		resolve  bool
+		path     string
+		expected Decision
+	}{
+		{"lexical match", false, filepath.Join(realWorkspace, "escape", "file.txt"), Allow},
+		{"symlink escape", true, filepath.Join(realWorkspace, "escape", "file.txt"), Deny},
+		{"regular file", true, filepath.Join(realWorkspace, "file.txt"), Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraints.ResolveSymlinks = tt.resolve
+			engine.cache.InvalidateAll()
+
+			decision, _ := engine.Evaluate(context.Background(), agent, "file.write", map[string]interface{}{"path": tt.path})
+			if decision != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// PlanCall is a call of an agent's plan: a tool and the request it will
+// be called with, as Evaluate takes them.
+type PlanCall struct {
+	ToolName string
+	Request  interface{}
+}
+
+// PlanStep is the evaluation of a call of a plan.
+type PlanStep struct {
+	*Explanation
+
+	// Preauthorized is set if the call may execute on the plan's
+	// authorization without being evaluated again: the policy allows it
+	// without obligations or parameter rewrites, which are only applied
+	// to calls as they are evaluated
+	Preauthorized bool
+}
+
+// PlanEvaluation is the evaluation of an agent's plan (see EvaluatePlan).
+type PlanEvaluation struct {
+	// Steps are the evaluations of the plan's calls, in plan order
+	Steps []PlanStep
+
+	// Allowed is set if the policy allows every call of the plan, whatever
+	// the enforcement mode
+	Allowed bool
+
+	// Fingerprint is the DecisionFingerprint of the agent the plan was
+	// evaluated against
+	Fingerprint string
+}
+
+// EvaluatePlan evaluates the calls of an agent's plan in order, as Explain
+// does, with the cost of each allowed call counted against the budgets of
+// the calls after it, so that a plan that would run out of budget midway
+// is denied before it starts. Calls to tools disabled by a kill switch are
+// denied. No audit event is emitted.
+//
+// If the plan is allowed, the cost of its preauthorized calls is charged
+// to its budgets at once, since they are not evaluated as they execute;
+// the plan is denied with ErrBudgetExceeded if concurrent calls spent the
+// budgets in the meantime. Unexecuted calls are not refunded.
+func (e *Engine) EvaluatePlan(ctx context.Context, agent AgentContext, calls []PlanCall) (*PlanEvaluation, error) {
+	plan := &PlanEvaluation{Allowed: true, Fingerprint: e.DecisionFingerprint(agent)}
+	spend := &planSpend{base: e.spend}
+	for i, call := range calls {
+		spend.step = i
+		var explanation *Explanation
+		if reason, killErr := e.checkKillSwitch(call.ToolName); killErr != nil {
+			policy, _ := e.ResolvePolicy(agent)
+			explanation = &Explanation{EvaluationResult: *killedResult(policy, reason, killErr), PolicyDecision: Deny, Mode: e.EffectiveMode(agent)}
+		} else {
+			var err error
+			if explanation, err = e.explain(ctx, agent, call.ToolName, call.Request, spend, true); err != nil {
+				return nil, err
+			}
+		}
+		allowed := explanation.PolicyDecision == Allow
+		plan.Allowed = plan.Allowed && allowed
+		plan.Steps = append(plan.Steps, PlanStep{
+			Explanation:   explanation,
+			Preauthorized: allowed && len(explanation.Obligations) == 0 && len(explanation.Mutations) == 0,
+		})
+	}
+	if !plan.Allowed {
+		return plan, nil
+	}
+	if err := spend.commit(plan.Steps); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// DecisionFingerprint returns a hash of what decides the calls of an agent:
+// the policies that apply to it, after canary rollouts are applied, and
+// the kill switches in effect. It changes whenever they do, so that a
+// decision taken earlier, such as that of a plan, can be reused while it
+// stays the same.
+func (e *Engine) DecisionFingerprint(agent AgentContext) string {
+	h := sha256.New()
+	for i, policy := range e.resolver.Matching(agent) {
+		if i == 0 {
+			policy, _, _ = e.selectCanary(policy, agent)
+		}
+		io.WriteString(h, policy.Fingerprint()+"\n")
+	}
+	for _, k := range e.KillSwitches() {
+		fmt.Fprintf(h, "kill=%s/%s\n", k.Source, k.Tool)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// planCharge is the charge of the cost of a call of a plan to a budget.
+type planCharge struct {
+	step    int
+	key     string
+	cost    int64
+	limit   int64
+	expires time.Time
+}
+
+// planSpend tracks the spend of the calls of a plan on top of the spend
+// tracked by base, without charging base until the plan is committed.
+type planSpend struct {
+	base    SpendTracker
+	step    int
+	charges []planCharge
+}
+
+// Charge records the charge of the current step, unless the spend of
+// base and the plan's earlier steps would exceed limit.
+func (s *planSpend) Charge(key string, cost, limit int64, expires time.Time) (int64, bool) {
+	spent := s.Spent(key)
+	if cost > 0 && limit > 0 && spent+cost > limit {
+		return spent, false
+	}
+	s.charges = append(s.charges, planCharge{step: s.step, key: key, cost: cost, limit: limit, expires: expires})
+	return spent + cost, true
+}
+
+// Spent returns the spend of key tracked by base and the plan's charges.
+func (s *planSpend) Spent(key string) int64 {
+	spent := s.base.Spent(key)
+	for _, c := range s.charges {
+		if c.key == key {
+			spent += c.cost
+		}
+	}
+	return spent
+}
+
+// commit charges base with the cost of the preauthorized steps, all or
+// nothing.
+func (s *planSpend) commit(steps []PlanStep) error {
+	var keys []string
+	totals := make(map[string]planCharge)
+	for _, c := range s.charges {
+		if !steps[c.step].Preauthorized {
+			continue
+		}
+		total, ok := totals[c.key]
+		if !ok {
+			keys = append(keys, c.key)
+			total = c
+		} else {
+			total.cost += c.cost
+		}
+		totals[c.key] = total
+	}
+
+	for i, key := range keys {
+		total := totals[key]
+		if total.cost == 0 {
+			continue
+		}
+		if spent, ok := s.base.Charge(key, total.cost, total.limit, total.expires); !ok {
+			for _, charged := range keys[:i] {
+				c := totals[charged]
+				s.base.Charge(charged, -c.cost, 0, c.expires)
+			}
+			return fmt.Errorf("%w: plan costs %d, but %d of %d is spent", policyerrors.ErrBudgetExceeded, total.cost, spent, total.limit)
+		}
+	}
+	return nil
+}
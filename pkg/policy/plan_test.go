@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestEvaluatePlan tests that the calls of a plan are charged against the
+// budgets of the calls after them, and its preauthorized calls to the
+// budgets once the plan is allowed.
+func TestEvaluatePlan(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", budgetPolicy(&Budget{
+		Tenant: &BudgetLimit{Limit: 100},
+	}))
+	ctx := context.Background()
+	teamA := AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}
+	teamB := AgentContext{AgentType: "coding-assistant", TenantID: "team-b"}
+
+	plan, err := engine.EvaluatePlan(ctx, teamA, []PlanCall{{ToolName: "gpu.run"}, {ToolName: "file.read"}, {ToolName: "gpu.run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Allowed || len(plan.Steps) != 3 || plan.Fingerprint != engine.DecisionFingerprint(teamA) {
+		t.Fatalf("expected the plan to be allowed, got %+v", plan)
+	}
+	for i, step := range plan.Steps {
+		if !step.Preauthorized {
+			t.Errorf("step %d: expected the call to be preauthorized", i)
+		}
+	}
+	// The plan spent 80 of team-a's 100
+	if decision, _ := engine.Evaluate(ctx, teamA, "gpu.run", nil); decision != Deny {
+		t.Errorf("expected the plan's calls to be charged, got %v", decision)
+	}
+
+	// A plan that runs out of budget midway is denied, and charges nothing
+	plan, err = engine.EvaluatePlan(ctx, teamB, []PlanCall{{ToolName: "gpu.run"}, {ToolName: "gpu.run"}, {ToolName: "gpu.run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Allowed || plan.Steps[1].PolicyDecision != Allow || plan.Steps[2].PolicyDecision != Deny {
+		t.Fatalf("expected the third call to be denied, got %+v", plan)
+	}
+	for i := 0; i < 2; i++ {
+		if decision, _ := engine.Evaluate(ctx, teamB, "gpu.run", nil); decision != Allow {
+			t.Errorf("call %d: expected the denied plan not to be charged, got %v", i, decision)
+		}
+	}
+
+	// Kill switches deny calls and change the fingerprint
+	fingerprint := engine.DecisionFingerprint(teamB)
+	engine.KillTool(KillSwitch{Source: "incident-7", Tool: "file.read"})
+	plan, err = engine.EvaluatePlan(ctx, teamB, []PlanCall{{ToolName: "file.read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Allowed || plan.Steps[0].Preauthorized {
+		t.Errorf("expected the killed tool to be denied, got %+v", plan.Steps[0])
+	}
+	if plan.Fingerprint == fingerprint {
+		t.Error("expected the kill switch to change the fingerprint")
+	}
+}
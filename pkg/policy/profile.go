@@ -0,0 +1,339 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+)
+
+// ProfileAction is how the engine treats allowed calls that fall outside
+// the learned behavior profile of their agent type.
+type ProfileAction int
+
+const (
+	// ProfileOff ignores behavior profiles
+	ProfileOff ProfileAction = iota
+	// ProfileFlag allows the call and records the deviation in the audit reason
+	ProfileFlag
+	// ProfileDeny denies the call
+	ProfileDeny
+)
+
+func (a ProfileAction) String() string {
+	switch a {
+	case ProfileOff:
+		return "off"
+	case ProfileFlag:
+		return "flag"
+	case ProfileDeny:
+		return "deny"
+	default:
+		return "unknown"
+	}
+}
+
+// Profile learning limits: parameters with more distinct values than
+// maxProfileValues are treated as unbounded, and string values longer than
+// maxProfileValueLen (file contents, queries) are counted but not recorded.
+const (
+	maxProfileValues   = 64
+	maxProfileValueLen = 256
+)
+
+// BehaviorProfile is the learned baseline of an agent type: the tools it
+// calls and the parameter values it uses. It is built by a ProfileLearner
+// from audit traffic (typically recorded in permissive mode), stored as an
+// AgentProfile resource, and enforced by policies with a ProfileAction.
+// This is SELinux's permissive-learning workflow applied to agents.
+type BehaviorProfile struct {
+	// AgentType is the agent type the profile describes
+	AgentType string
+
+	// Samples is the number of calls the profile was learned from
+	Samples int64
+
+	// Tools are the baselines of the tools observed, by tool name
+	Tools map[string]*ToolBaseline
+}
+
+// ToolBaseline is the learned usage of one tool.
+type ToolBaseline struct {
+	// Count is the number of calls observed
+	Count int64
+
+	// Parameters are the baselines of the parameters observed, by name
+	Parameters map[string]*ParameterBaseline
+}
+
+// ParameterBaseline is the learned distribution of one tool parameter.
+// String and boolean values are recorded by value ("path" by directory,
+// "domain" in canonical form), as are ports; other integers by range.
+type ParameterBaseline struct {
+	// Count is the number of calls that carried the parameter
+	Count int64
+
+	// Values counts the calls per observed value
+	Values map[string]int64
+
+	// Unbounded is set once more than maxProfileValues distinct values
+	// were observed; any value is then within the baseline
+	Unbounded bool
+
+	// Numeric is set if integer values were observed, bounded by Min and Max
+	Numeric bool
+	Min     int64
+	Max     int64
+}
+
+// profileValue classifies a parameter value for profiling: a string key for
+// values recorded by value, or an integer. Returns neither for values that
+// are not profiled (lists, objects, long strings).
+func profileValue(name string, v interface{}) (key string, isKey bool, n int64, isNum bool) {
+	switch val := v.(type) {
+	case string:
+		if len(val) > maxProfileValueLen {
+			return "", false, 0, false
+		}
+		switch name {
+		case "path":
+			if clean, err := NormalizePath(val); err == nil {
+				val = path.Dir(clean)
+			}
+		case "domain":
+			if d, err := NormalizeDomain(val); err == nil {
+				val = d
+			}
+		}
+		return val, true, 0, false
+	case bool:
+		return fmt.Sprint(val), true, 0, false
+	case nil, []interface{}, map[string]interface{}:
+		return "", false, 0, false
+	}
+	if n, ok := CoerceInt64(v); ok {
+		if name == "port" {
+			return fmt.Sprint(n), true, 0, false
+		}
+		return "", false, n, true
+	}
+	return "", false, 0, false
+}
+
+// observe adds a call to the profile.
+func (p *BehaviorProfile) observe(toolName string, params map[string]interface{}) {
+	if p.Tools == nil {
+		p.Tools = make(map[string]*ToolBaseline)
+	}
+	tool, ok := p.Tools[toolName]
+	if !ok {
+		tool = &ToolBaseline{Parameters: make(map[string]*ParameterBaseline)}
+		p.Tools[toolName] = tool
+	}
+	p.Samples++
+	tool.Count++
+
+	for name, v := range params {
+		param, ok := tool.Parameters[name]
+		if !ok {
+			param = &ParameterBaseline{}
+			tool.Parameters[name] = param
+		}
+		param.Count++
+
+		key, isKey, n, isNum := profileValue(name, v)
+		switch {
+		case isKey && !param.Unbounded:
+			if param.Values == nil {
+				param.Values = make(map[string]int64)
+			}
+			param.Values[key]++
+			if len(param.Values) > maxProfileValues {
+				param.Values = nil
+				param.Unbounded = true
+			}
+		case isNum:
+			if !param.Numeric || n < param.Min {
+				param.Min = n
+			}
+			if !param.Numeric || n > param.Max {
+				param.Max = n
+			}
+			param.Numeric = true
+		}
+	}
+}
+
+// Deviation describes how a call differs from the profile: a tool, a
+// parameter, or a parameter value never observed, or an integer outside the
+// observed range. Returns "" if the call is within the baseline.
+func (p *BehaviorProfile) Deviation(toolName string, params map[string]interface{}) string {
+	tool, ok := p.Tools[toolName]
+	if !ok {
+		return fmt.Sprintf("tool %q never observed", toolName)
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		param, ok := tool.Parameters[name]
+		if !ok {
+			return fmt.Sprintf("parameter %q never observed", name)
+		}
+
+		key, isKey, n, isNum := profileValue(name, params[name])
+		switch {
+		case isKey && !param.Unbounded && param.Values[key] == 0:
+			return fmt.Sprintf("%s=%q never observed", name, key)
+		case isNum && (!param.Numeric || n < param.Min || n > param.Max):
+			if !param.Numeric {
+				return fmt.Sprintf("%s=%d never observed", name, n)
+			}
+			return fmt.Sprintf("%s=%d outside observed range [%d, %d]", name, n, param.Min, param.Max)
+		}
+	}
+	return ""
+}
+
+// ProfileLearner builds behavior profiles from audit events. It is an
+// AuditSink, so it can learn live from an engine built WithAuditParameters,
+// or offline from events read back from a JSON audit log.
+type ProfileLearner struct {
+	mu       sync.Mutex
+	profiles map[string]*BehaviorProfile
+
+	// IncludeDenials learns from denied calls too. Set it when learning
+	// from permissive-mode traffic, where denied calls still executed.
+	IncludeDenials bool
+}
+
+// NewProfileLearner creates an empty learner.
+func NewProfileLearner() *ProfileLearner {
+	return &ProfileLearner{profiles: make(map[string]*BehaviorProfile)}
+}
+
+// Log implements AuditSink by learning from the event. Queries are not
+// calls, so their events are skipped.
+func (l *ProfileLearner) Log(event *AuditEvent) {
+	if event.Execution != nil || event.Source != "" || (event.Decision != Allow && !l.IncludeDenials) {
+		return
+	}
+	l.Observe(event.Agent.AgentType, event.Tool, event.Parameters)
+}
+
+// Observe adds a call to the profile of an agent type.
+func (l *ProfileLearner) Observe(agentType, toolName string, params map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.profiles[agentType]
+	if !ok {
+		p = &BehaviorProfile{AgentType: agentType}
+		l.profiles[agentType] = p
+	}
+	p.observe(toolName, params)
+}
+
+// AgentTypes returns the agent types with a profile, sorted.
+func (l *ProfileLearner) AgentTypes() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	types := make([]string, 0, len(l.profiles))
+	for t := range l.profiles {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Profile returns a copy of the profile learned for an agent type.
+func (l *ProfileLearner) Profile(agentType string) (*BehaviorProfile, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.profiles[agentType]
+	if !ok {
+		return nil, false
+	}
+	return p.clone(), true
+}
+
+// clone returns a deep copy of the profile.
+func (p *BehaviorProfile) clone() *BehaviorProfile {
+	c := &BehaviorProfile{AgentType: p.AgentType, Samples: p.Samples, Tools: make(map[string]*ToolBaseline, len(p.Tools))}
+	for name, tool := range p.Tools {
+		t := &ToolBaseline{Count: tool.Count, Parameters: make(map[string]*ParameterBaseline, len(tool.Parameters))}
+		for pname, param := range tool.Parameters {
+			cp := *param
+			if param.Values != nil {
+				cp.Values = make(map[string]int64, len(param.Values))
+				for k, v := range param.Values {
+					cp.Values[k] = v
+				}
+			}
+			t.Parameters[pname] = &cp
+		}
+		c.Tools[name] = t
+	}
+	return c
+}
+
+// profileStore holds the behavior profiles loaded into an engine.
+type profileStore struct {
+	mu       sync.RWMutex
+	profiles map[string]*BehaviorProfile
+}
+
+func newProfileStore() *profileStore {
+	return &profileStore{profiles: make(map[string]*BehaviorProfile)}
+}
+
+// LoadProfile adds or replaces the behavior profile of an agent type.
+// Profiles are only enforced by policies with a ProfileAction.
+func (e *Engine) LoadProfile(profile *BehaviorProfile) {
+	e.profiles.mu.Lock()
+	e.profiles.profiles[profile.AgentType] = profile
+	e.profiles.mu.Unlock()
+}
+
+// RemoveProfile removes the behavior profile of an agent type.
+func (e *Engine) RemoveProfile(agentType string) {
+	e.profiles.mu.Lock()
+	delete(e.profiles.profiles, agentType)
+	e.profiles.mu.Unlock()
+}
+
+// GetProfile returns the behavior profile loaded for an agent type.
+func (e *Engine) GetProfile(agentType string) (*BehaviorProfile, bool) {
+	e.profiles.mu.RLock()
+	defer e.profiles.mu.RUnlock()
+	p, ok := e.profiles.profiles[agentType]
+	return p, ok
+}
+
+// checkProfile applies the policy's ProfileAction to an allowed call.
+// Calls are only judged against profiles learned from at least
+// ProfileMinSamples calls; without one they pass unchanged.
+func (e *Engine) checkProfile(policy *CompiledPolicy, agent AgentContext, toolName string, request interface{}, decision Decision, reason string) (Decision, string) {
+	if decision != Allow || policy.ProfileAction == ProfileOff {
+		return decision, reason
+	}
+	profile, ok := e.GetProfile(agent.AgentType)
+	if !ok || profile.Samples < policy.ProfileMinSamples {
+		return decision, reason
+	}
+
+	deviation := profile.Deviation(toolName, requestParameterMap(request))
+	if deviation == "" {
+		return decision, reason
+	}
+	if policy.ProfileAction == ProfileDeny {
+		return Deny, "outside learned profile: " + deviation
+	}
+	return decision, reason + " (flagged: outside learned profile: " + deviation + ")"
+}
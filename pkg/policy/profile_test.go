@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestProfileLearner verifies tools and parameter distributions are learned
+// from audit events
+func TestProfileLearner(t *testing.T) {
+	learner := NewProfileLearner()
+	log := func(decision Decision, tool string, params map[string]interface{}) {
+		learner.Log(&AuditEvent{Agent: AgentContext{AgentType: "coding-assistant"}, Tool: tool, Decision: decision, Parameters: params})
+	}
+
+	log(Allow, "file.read", map[string]interface{}{"path": "/workspace/src/main.go"})
+	log(Allow, "file.read", map[string]interface{}{"path": "/workspace/src/util.go"})
+	log(Allow, "file.write", map[string]interface{}{"path": "/workspace/out.txt", "size": float64(100)})
+	log(Allow, "file.write", map[string]interface{}{"path": "/workspace/out.txt", "size": int64(4096)})
+	log(Allow, "network.fetch", map[string]interface{}{"domain": "API.GitHub.com."})
+	log(Deny, "code.execute", map[string]interface{}{"command": "rm -rf /"})
+
+	p, ok := learner.Profile("coding-assistant")
+	if !ok {
+		t.Fatal("expected a profile")
+	}
+	if p.Samples != 5 {
+		t.Errorf("expected 5 samples (denials excluded), got %d", p.Samples)
+	}
+	if _, ok := p.Tools["code.execute"]; ok {
+		t.Error("denied calls must not be learned by default")
+	}
+	if got := p.Tools["file.read"].Parameters["path"].Values; len(got) != 1 || got["/workspace/src"] != 2 {
+		t.Errorf("expected paths recorded by directory, got %v", got)
+	}
+	size := p.Tools["file.write"].Parameters["size"]
+	if !size.Numeric || size.Min != 100 || size.Max != 4096 {
+		t.Errorf("expected size range [100, 4096], got %+v", size)
+	}
+	if got := p.Tools["network.fetch"].Parameters["domain"].Values; got["api.github.com"] != 1 {
+		t.Errorf("expected normalized domain, got %v", got)
+	}
+
+	learner.IncludeDenials = true
+	log(Deny, "code.execute", map[string]interface{}{"command": "ls"})
+	if p, _ := learner.Profile("coding-assistant"); p.Tools["code.execute"] == nil {
+		t.Error("expected denied call to be learned with IncludeDenials")
+	}
+	learner.Log(&AuditEvent{Agent: AgentContext{AgentType: "coding-assistant"}, Tool: "shell.run", Decision: Allow, Source: "dataapi"})
+	if p, _ := learner.Profile("coding-assistant"); p.Tools["shell.run"] != nil {
+		t.Error("expected queries not to be learned")
+	}
+
+	for i := 0; i <= maxProfileValues; i++ {
+		learner.Observe("search-agent", "web.search", map[string]interface{}{"query": strings.Repeat("q", i+1)})
+	}
+	if p, _ := learner.Profile("search-agent"); !p.Tools["web.search"].Parameters["query"].Unbounded {
+		t.Error("expected high-cardinality parameter to be unbounded")
+	}
+}
+
+// TestProfileDeviation verifies calls outside the baseline are described
+func TestProfileDeviation(t *testing.T) {
+	learner := NewProfileLearner()
+	learner.Observe("coding-assistant", "file.write", map[string]interface{}{"path": "/workspace/a.txt", "size": 10, "append": false})
+	learner.Observe("coding-assistant", "file.write", map[string]interface{}{"path": "/workspace/b.txt", "size": 500, "append": false})
+	learner.Observe("coding-assistant", "network.fetch", map[string]interface{}{"port": 443})
+	p, _ := learner.Profile("coding-assistant")
+
+	tests := []struct {
+		name      string
+		tool      string
+		params    map[string]interface{}
+		deviation string
+	}{
+		{"within baseline", "file.write", map[string]interface{}{"path": "/workspace/c.txt", "size": 200}, ""},
+		{"JSON number", "file.write", map[string]interface{}{"size": float64(500)}, ""},
+		{"unknown tool", "file.delete", nil, `tool "file.delete" never observed`},
+		{"unknown parameter", "file.write", map[string]interface{}{"mode": "0777"}, `parameter "mode" never observed`},
+		{"new directory", "file.write", map[string]interface{}{"path": "/etc/passwd"}, `path="/etc" never observed`},
+		{"above range", "file.write", map[string]interface{}{"size": 10000}, "size=10000 outside observed range [10, 500]"},
+		{"new boolean", "file.write", map[string]interface{}{"append": true}, `append="true" never observed`},
+		{"known port", "network.fetch", map[string]interface{}{"port": float64(443)}, ""},
+		{"port within range is still new", "network.fetch", map[string]interface{}{"port": 80}, `port="80" never observed`},
+	}
+
+	for _, tt := range tests {
+		if got := p.Deviation(tt.tool, tt.params); got != tt.deviation {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.deviation, got)
+		}
+	}
+}
+
+// TestEngineProfileEnforcement verifies the flag and deny profile actions
+func TestEngineProfileEnforcement(t *testing.T) {
+	learner := NewProfileLearner()
+	for i := 0; i < 3; i++ {
+		learner.Observe("coding-assistant", "file.read", map[string]interface{}{"path": "/workspace/src/main.go"})
+	}
+	profile, _ := learner.Profile("coding-assistant")
+
+	compiled := CompilePolicy("test-policy", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}, {Tool: "file.write", Action: Allow}}, Enforcing, "")
+	engine := NewEngine(WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", compiled)
+	engine.LoadProfile(profile)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	evaluate := func(tool, path string) *EvaluationResult {
+		t.Helper()
+		result, err := engine.EvaluateWithResult(context.Background(), agent, tool, map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatalf("EvaluateWithResult failed: %v", err)
+		}
+		return result
+	}
+
+	if r := evaluate("file.write", "/workspace/src/x"); r.Decision != Allow || strings.Contains(r.Reason, "flagged") {
+		t.Errorf("expected unflagged allow without profile enforcement, got %v: %s", r.Decision, r.Reason)
+	}
+
+	compiled.ProfileAction = ProfileFlag
+	if r := evaluate("file.write", "/workspace/src/x"); r.Decision != Allow || !strings.Contains(r.Reason, `flagged: outside learned profile: tool "file.write" never observed`) {
+		t.Errorf("expected flagged allow, got %v: %s", r.Decision, r.Reason)
+	}
+
+	compiled.ProfileAction = ProfileDeny
+	if r := evaluate("file.read", "/workspace/src/other.go"); r.Decision != Allow {
+		t.Errorf("expected call within profile to be allowed, got %v: %s", r.Decision, r.Reason)
+	}
+	if r := evaluate("file.read", "/home/user/.ssh/id_rsa"); r.Decision != Deny || r.Cached {
+		t.Errorf("expected uncached deny outside profile, got %v (cached %v): %s", r.Decision, r.Cached, r.Reason)
+	}
+
+	compiled.ProfileMinSamples = 10
+	if r := evaluate("file.read", "/home/user/.ssh/id_rsa"); r.Decision != Allow {
+		t.Errorf("expected profile below MinSamples to be ignored, got %v: %s", r.Decision, r.Reason)
+	}
+}
+
+// TestAuditParameters verifies parameters reach audit events only when
+// enabled, and round-trip through the JSON sink
+func TestAuditParameters(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		sink := NewChannelAuditSink(1)
+		engine := NewEngine(WithMode(Enforcing), WithAuditSink(sink), WithAuditParameters(enabled))
+		engine.LoadPolicy("coding-assistant", CompilePolicy("test-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, ""))
+
+		engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant"}, "file.read", map[string]interface{}{"path": "/workspace/a", "size": "10"})
+		event := <-sink.Events()
+		if !enabled {
+			if event.Parameters != nil {
+				t.Errorf("expected no parameters by default, got %v", event.Parameters)
+			}
+			continue
+		}
+		if event.Parameters["path"] != "/workspace/a" || event.Parameters["size"] != int64(10) {
+			t.Errorf("expected normalized parameters, got %v", event.Parameters)
+		}
+
+		var buf bytes.Buffer
+		NewJSONAuditSink(&buf, false).Log(event)
+		var decoded JSONAuditEvent
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("failed to decode JSON audit event: %v", err)
+		}
+		if decoded.Parameters["path"] != "/workspace/a" {
+			t.Errorf("expected parameters in JSON audit event, got %v", decoded.Parameters)
+		}
+	}
+}
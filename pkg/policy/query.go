@@ -0,0 +1,25 @@
+package policy
+
+import "context"
+
+// queryKey is the context key of the source of a query (see
+// ContextWithQuery).
+type queryKey struct{}
+
+// ContextWithQuery returns a context whose evaluation is a query from
+// source, e.g. "dataapi": it asks how a call would be decided without
+// making it. A query checks the budgets of the call's policy without
+// charging them, so an allowed call that would exceed a budget is still
+// denied. It is not counted as a call Permissive mode allowed nor as a
+// decision of a canary rollout, and its audit event carries source as
+// AuditEvent.Source, for the tools learning from audit logs to skip it.
+func ContextWithQuery(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, queryKey{}, source)
+}
+
+// querySource returns the source of the query evaluated with ctx, or ""
+// if the evaluation decides a call.
+func querySource(ctx context.Context) string {
+	source, _ := ctx.Value(queryKey{}).(string)
+	return source
+}
@@ -0,0 +1,377 @@
+package rego
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/format"
+)
+
+// Severity classifies a lint finding.
+type Severity string
+
+const (
+	// SeverityError marks a module that OPA will reject or that cannot
+	// behave as intended; compilation should fail
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks a suspicious construct that still compiles
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single lint result for a Rego module.
+type Finding struct {
+	// Module is the file name of the module, when linting a module set
+	Module string
+
+	// Rule is the lint rule that produced the finding (e.g., "empty-body")
+	Rule string
+
+	// Severity is error or warning
+	Severity Severity
+
+	// Line is the 1-based line of the rule head the finding refers to
+	Line int
+
+	// Message describes the problem
+	Message string
+}
+
+// String formats the finding as "line N: [rule] message", prefixed with
+// the module file name when set.
+func (f Finding) String() string {
+	if f.Module != "" {
+		return fmt.Sprintf("%s:%d: [%s] %s", f.Module, f.Line, f.Rule, f.Message)
+	}
+	return fmt.Sprintf("line %d: [%s] %s", f.Line, f.Rule, f.Message)
+}
+
+// HasErrors reports whether any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Format formats a Rego module the way `opa fmt` does.
+// It fails if the module does not parse.
+func Format(module string) (string, error) {
+	formatted, err := format.Source("policy.rego", []byte(module))
+	if err != nil {
+		return "", fmt.Errorf("failed to format Rego: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// Vet lints a generated module and then formats it.
+// Lint errors are returned as an error listing every error finding; warnings
+// are returned alongside the formatted module for the caller to surface.
+func Vet(module string) (string, []Finding, error) {
+	findings := Lint(module)
+
+	var warnings []Finding
+	var errs []string
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f.String())
+		} else {
+			warnings = append(warnings, f)
+		}
+	}
+	if len(errs) > 0 {
+		return "", warnings, fmt.Errorf("generated Rego failed lint: %s", strings.Join(errs, "; "))
+	}
+
+	formatted, err := Format(module)
+	if err != nil {
+		return "", warnings, err
+	}
+	return formatted, warnings, nil
+}
+
+// VetModules vets every module of a module set (see CompileToModules) the
+// way Vet does, returning the formatted modules. Findings carry the module
+// file name; lint errors from all modules are reported together.
+func VetModules(modules map[string]string) (map[string]string, []Finding, error) {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []Finding
+	var errs []string
+	for _, name := range names {
+		for _, f := range Lint(modules[name]) {
+			f.Module = name
+			if f.Severity == SeverityError {
+				errs = append(errs, f.String())
+			} else {
+				warnings = append(warnings, f)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, warnings, fmt.Errorf("generated Rego failed lint: %s", strings.Join(errs, "; "))
+	}
+
+	formatted := make(map[string]string, len(modules))
+	for _, name := range names {
+		out, err := Format(modules[name])
+		if err != nil {
+			return nil, warnings, fmt.Errorf("%s: %w", name, err)
+		}
+		formatted[name] = out
+	}
+	return formatted, warnings, nil
+}
+
+// Lint checks a Rego module for constructs the template generator is known
+// to get wrong. It is a line-oriented checker for generated modules, not a
+// full Rego parser. Rules:
+//
+//   - empty-body: a rule body with no expressions (rejected by OPA)
+//   - always-true: a rule or helper whose body is just `true`
+//   - always-false: a body containing a bare `false`, which can never succeed
+//   - conjunctive-alternatives: one body matching the same variable against
+//     several literals (e.g., domain == "a" and domain == "b"); alternatives
+//     must be separate bodies, otherwise the rule can never succeed
+//   - undefined-function: a call to a function the module does not define
+//     and that is not a known built-in
+func Lint(module string) []Finding {
+	rules := parseRules(module)
+
+	defined := make(map[string]bool)
+	for _, r := range rules {
+		if r.function {
+			defined[r.name] = true
+		}
+	}
+
+	var findings []Finding
+	for _, r := range rules {
+		findings = append(findings, lintRule(r, defined)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// lintRule applies the lint rules to a single rule body.
+func lintRule(r parsedRule, defined map[string]bool) []Finding {
+	var findings []Finding
+	add := func(rule string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Rule:     rule,
+			Severity: severity,
+			Line:     r.line,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(r.body) == 0 {
+		add("empty-body", SeverityError, "%s has an empty body", r.name)
+		return findings
+	}
+
+	if len(r.body) == 1 && r.body[0] == "true" {
+		add("always-true", SeverityWarning, "%s always succeeds; its body is just true", r.name)
+	}
+
+	for _, expr := range r.body {
+		if expr == "false" {
+			add("always-false", SeverityWarning, "%s body contains false and can never succeed", r.name)
+			break
+		}
+	}
+
+	// Literal matches per variable, in order of appearance
+	matches := make(map[string][]string)
+	var vars []string
+	for _, expr := range r.body {
+		if v, lit, ok := literalMatch(expr); ok {
+			if _, seen := matches[v]; !seen {
+				vars = append(vars, v)
+			}
+			matches[v] = append(matches[v], lit)
+		}
+	}
+	for _, v := range vars {
+		if lits := matches[v]; len(lits) > 1 {
+			add("conjunctive-alternatives", SeverityWarning,
+				"%s requires %s to match all of %s at once; alternatives must be separate rule bodies",
+				r.name, v, strings.Join(lits, ", "))
+		}
+	}
+
+	for _, expr := range r.body {
+		for _, call := range functionCalls(expr) {
+			if !defined[call] && !regoBuiltins[call] {
+				add("undefined-function", SeverityError, "%s calls undefined function %s", r.name, call)
+			}
+		}
+	}
+
+	return findings
+}
+
+// parsedRule is a rule head with the expressions of its body.
+type parsedRule struct {
+	name     string
+	function bool
+	line     int
+	body     []string
+}
+
+var (
+	// ruleHeadRe matches rule heads that open a body on the same line:
+	// "allow if {", "path_allowed_x(path) if {", `reason := "..." if {`
+	ruleHeadRe = regexp.MustCompile(`^([a-z_][a-zA-Z0-9_]*)(\([^)]*\))?\s*(?:(?::=|=)\s*.*?\s+)?(?:if\s*)?\{$`)
+
+	// callRe matches function calls; dotted built-ins (glob.match) are skipped
+	callRe = regexp.MustCompile(`(^|[^a-zA-Z0-9_.])([a-z_][a-zA-Z0-9_]*)\(`)
+
+	eqLiteralRe    = regexp.MustCompile(`^([a-z_][a-zA-Z0-9_.]*)\s*==\s*("[^"]*")$`)
+	suffixRe       = regexp.MustCompile(`^endswith\(([a-z_][a-zA-Z0-9_.]*),\s*("[^"]*")\)$`)
+	globLiteralRe  = regexp.MustCompile(`^glob\.match\(("[^"]*"),\s*\[[^\]]*\],\s*([a-z_][a-zA-Z0-9_.]*)\)$`)
+	objectAssignRe = regexp.MustCompile(`:=\s*\{$`)
+)
+
+// regoBuiltins are the non-dotted built-in functions the checker knows.
+var regoBuiltins = map[string]bool{
+	"abs": true, "all": true, "any": true, "array": true, "concat": true,
+	"contains": true, "count": true, "endswith": true, "format_int": true,
+	"indexof": true, "is_array": true, "is_boolean": true, "is_number": true,
+	"is_object": true, "is_set": true, "is_string": true, "lower": true,
+	"max": true, "min": true, "object": true, "product": true, "replace": true,
+	"round": true, "set": true, "sort": true, "split": true, "sprintf": true,
+	"startswith": true, "substring": true, "sum": true, "to_number": true,
+	"trim": true, "trim_left": true, "trim_prefix": true, "trim_right": true,
+	"trim_space": true, "trim_suffix": true, "union": true, "upper": true,
+	"walk": true,
+}
+
+// parseRules extracts rule bodies from a module. Bodies are the lines between
+// a rule head ending in "{" and the matching "}", with comments and blank
+// lines removed.
+func parseRules(module string) []parsedRule {
+	var rules []parsedRule
+	var current *parsedRule
+	depth := 0
+
+	for i, raw := range strings.Split(module, "\n") {
+		line := strings.TrimSpace(stripComment(raw))
+
+		if current == nil {
+			if line == "" || objectAssignRe.MatchString(line) {
+				continue
+			}
+			m := ruleHeadRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			current = &parsedRule{name: m[1], function: m[2] != "", line: i + 1}
+			depth = 1
+			continue
+		}
+
+		depth += braceDelta(line)
+		if depth <= 0 {
+			rules = append(rules, *current)
+			current = nil
+			continue
+		}
+		if line != "" {
+			current.body = append(current.body, line)
+		}
+	}
+
+	return rules
+}
+
+// literalMatch recognizes expressions that match a variable against a literal:
+// x == "lit", endswith(x, "lit"), glob.match("lit", [], x).
+func literalMatch(expr string) (string, string, bool) {
+	if m := eqLiteralRe.FindStringSubmatch(expr); m != nil {
+		return m[1], m[2], true
+	}
+	if m := suffixRe.FindStringSubmatch(expr); m != nil {
+		return m[1], m[2], true
+	}
+	if m := globLiteralRe.FindStringSubmatch(expr); m != nil {
+		return m[2], m[1], true
+	}
+	return "", "", false
+}
+
+// functionCalls returns the non-dotted function names called in an expression.
+func functionCalls(expr string) []string {
+	var calls []string
+	for _, m := range callRe.FindAllStringSubmatch(stripStrings(expr), -1) {
+		calls = append(calls, m[2])
+	}
+	return calls
+}
+
+// stripComment removes a trailing "#" comment outside string literals.
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// stripStrings replaces string literals with empty strings.
+func stripStrings(expr string) string {
+	var b strings.Builder
+	inString := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if c == '\\' && inString {
+			i++
+			continue
+		}
+		if c == '"' {
+			inString = !inString
+			b.WriteByte('"')
+			continue
+		}
+		if !inString {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// braceDelta counts opening minus closing braces outside string literals.
+func braceDelta(line string) int {
+	delta := 0
+	for _, c := range stripStrings(line) {
+		switch c {
+		case '{':
+			delta++
+		case '}':
+			delta--
+		}
+	}
+	return delta
+}
@@ -0,0 +1,164 @@
+package rego
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLintGenerated tests lint findings on generated modules.
+func TestLintGenerated(t *testing.T) {
+	basic, err := CompileToRego(goldenSpecs["basic"])
+	if err != nil {
+		t.Fatalf("CompileToRego failed: %v", err)
+	}
+	if findings := Lint(basic); len(findings) != 0 {
+		t.Errorf("expected no findings for basic policy, got %v", findings)
+	}
+
+	constrained, err := CompileToRego(goldenSpecs["constraints"])
+	if err != nil {
+		t.Fatalf("CompileToRego failed: %v", err)
+	}
+
+	// The current helper templates end every helper with a "false" body
+	// and put all denied domains in one conjunctive body
+	rules := make(map[string]int)
+	for _, f := range Lint(constrained) {
+		rules[f.Rule]++
+	}
+	if rules["always-false"] == 0 {
+		t.Error("expected always-false findings for helper fallbacks")
+	}
+	if rules["conjunctive-alternatives"] != 1 {
+		t.Errorf("expected 1 conjunctive-alternatives finding for denied domains, got %d", rules["conjunctive-alternatives"])
+	}
+}
+
+// TestLintRules tests each lint rule on hand-written modules.
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		module   string
+		rule     string
+		severity Severity
+	}{
+		{
+			name:     "empty body",
+			module:   "package p\n\nhelper(x) if {\n}\n",
+			rule:     "empty-body",
+			severity: SeverityError,
+		},
+		{
+			name:     "empty body with comment",
+			module:   "package p\n\nhelper(x) if {\n    # nothing here\n}\n",
+			rule:     "empty-body",
+			severity: SeverityError,
+		},
+		{
+			name:     "always true helper",
+			module:   "package p\n\nhelper(x) if {\n    true\n}\n",
+			rule:     "always-true",
+			severity: SeverityWarning,
+		},
+		{
+			name:     "always false",
+			module:   "package p\n\nallow if {\n    input.tool == \"a\"\n    false  # fallback\n}\n",
+			rule:     "always-false",
+			severity: SeverityWarning,
+		},
+		{
+			name:     "conjunctive alternatives",
+			module:   "package p\n\nbad(d) if {\n    d == \"a.com\"\n    endswith(d, \".b.com\")\n}\n",
+			rule:     "conjunctive-alternatives",
+			severity: SeverityWarning,
+		},
+		{
+			name:     "undefined function",
+			module:   "package p\n\nallow if {\n    not missing_helper(input.request.path)\n}\n",
+			rule:     "undefined-function",
+			severity: SeverityError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Lint(tt.module)
+			if len(findings) != 1 {
+				t.Fatalf("expected 1 finding, got %v", findings)
+			}
+			if findings[0].Rule != tt.rule || findings[0].Severity != tt.severity {
+				t.Errorf("expected %s/%s, got %s", tt.rule, tt.severity, findings[0])
+			}
+			if findings[0].Line != 3 {
+				t.Errorf("expected finding on line 3, got %d", findings[0].Line)
+			}
+		})
+	}
+}
+
+// TestLintClean tests that well-formed constructs produce no findings.
+func TestLintClean(t *testing.T) {
+	module := `package p
+
+import future.keywords.if
+
+decision := {
+    "allow": allow,
+    "reason": reason
+}
+
+allowed_path(p) if {
+    glob.match("/workspace/**", [], p)
+}
+
+allowed_path(p) if {
+    startswith(p, "/tmp/")
+}
+
+allow if {
+    input.tool == "file.read"
+    allowed_path(input.request.path)
+    input.request.port in {80, 443}
+}
+
+reason := "tool allowed" if {
+    allow
+}
+`
+	if findings := Lint(module); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
+
+// TestVet tests that lint errors fail vetting and warnings are returned.
+func TestVet(t *testing.T) {
+	spec := &PolicySpec{
+		Name:          "allowed-domains-only",
+		DefaultAction: "deny",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "network.fetch", Action: "allow", Constraints: &ConstraintSpec{
+				AllowedDomains: []string{"github.com"},
+			}},
+		},
+	}
+	generated, err := CompileToRego(spec)
+	if err != nil {
+		t.Fatalf("CompileToRego failed: %v", err)
+	}
+
+	// Allowed domains without denied domains generate an empty
+	// domain_denied helper body, which OPA rejects
+	_, _, err = Vet(generated)
+	if err == nil || !strings.Contains(err.Error(), "empty-body") {
+		t.Errorf("expected empty-body lint error, got %v", err)
+	}
+
+	basic, err := CompileToRego(goldenSpecs["basic"])
+	if err != nil {
+		t.Fatalf("CompileToRego failed: %v", err)
+	}
+	formatted, warnings, err := Vet(basic)
+	if err != nil || len(warnings) != 0 || formatted == "" {
+		t.Errorf("expected clean vet, got warnings=%v err=%v", warnings, err)
+	}
+}
@@ -10,11 +10,24 @@
 //	deny { explicit denials }
 //	mts_allow { tenant isolation check }
 //	decision := {allow, deny, mts, reason}
+//
+// Policies whose rules carry obligations also define obligations, the
+// requested tool's list, and add it to the decision object.
+//
+// Template v2 (the default, see CompileToModules) splits the policy into an
+// entrypoint with the same decision object and one package per tool:
+//
+//	package agentpolicy              # dispatch, default action, MTS, decision
+//	package agentpolicy.tools.<key>  # listed, allow/deny, private helpers
+//
+// The single-module v1 template above is kept as a compatibility mode.
 package rego
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 )
@@ -43,6 +56,11 @@ type PolicySpec struct {
 
 	// MTSEnforceMode is "strict", "permissive", or "disabled"
 	MTSEnforceMode string
+
+	// TemplateVersion selects the generator used by CompileToModules:
+	// "v1" (single module, compatibility) or "v2" (per-tool packages).
+	// Empty means DefaultTemplateVersion.
+	TemplateVersion string
 }
 
 // ToolPermissionSpec represents a single tool permission rule.
@@ -55,6 +73,16 @@ type ToolPermissionSpec struct {
 
 	// Constraints are optional conditions for allow rules
 	Constraints *ConstraintSpec
+
+	// Obligations are returned in the decision object for allow rules
+	Obligations []ObligationSpec
+}
+
+// ObligationSpec is a duty the caller must fulfil before executing an
+// allowed call, emitted into the decision object's obligations list.
+type ObligationSpec struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params,omitempty"`
 }
 
 // ConstraintSpec represents constraint conditions for a tool permission.
@@ -65,6 +93,13 @@ type ConstraintSpec struct {
 	AllowedPorts   []int32
 	MaxSizeBytes   int64
 	Timeout        string
+
+	// RequiredAgentLabels must match input.agent.labels
+	RequiredAgentLabels map[string]string
+
+	// AllowedContentHashes are canonical SHA-256 digests that
+	// input.request.content_sha256 must match
+	AllowedContentHashes []string
 }
 
 // regoTemplate is the base template for generating Rego policies.
@@ -103,6 +138,18 @@ deny if {
     input.tool == "{{.Tool}}"
 }
 {{end}}
+{{- if .ObligationRules}}
+
+# ============================================================================
+# Obligations of allowed calls
+# ============================================================================
+default obligations := []
+{{range .ObligationRules}}
+obligations := {{.Obligations}} if {
+    input.tool == "{{.Tool}}"
+}
+{{end}}
+{{- end}}
 
 # ============================================================================
 # Multi-Tenant Sandboxing (MTS) enforcement
@@ -120,6 +167,11 @@ mts_allow if {
     # Empty policy MTS label means no restriction
     "{{.MTSLabel}}" == ""
 }
+
+mts_allow if {
+    # Parent tenants dominate the labels of delegating child tenants
+    input.agent.mts_dominates
+}
 {{else if eq .MTSEnforceMode "permissive"}}
 # Permissive mode: log but allow (MTS check always passes)
 mts_allow := true
@@ -135,13 +187,16 @@ mts_allow := true
 # ============================================================================
 # Path constraint helpers
 # ============================================================================
-{{range .PathHelpers}}
-path_allowed_{{.SafeName}}(path) if {
+{{- if .PathHelpers}}
+{{pathNormalization}}
+{{- end}}
+{{range .PathHelpers}}{{$name := .SafeName}}
+path_allowed_{{$name}}(path) if {
 {{- range .Patterns}}
     glob.match("{{.}}", [], path)
 }
 
-path_allowed_{{.SafeName}}(path) if {
+path_allowed_{{$name}}(path) if {
 {{- end}}
     false  # fallback
 }
@@ -150,8 +205,8 @@ path_allowed_{{.SafeName}}(path) if {
 # ============================================================================
 # Domain constraint helpers
 # ============================================================================
-{{range .DomainHelpers}}
-domain_allowed_{{.SafeName}}(domain) if {
+{{range .DomainHelpers}}{{$name := .SafeName}}
+domain_allowed_{{$name}}(domain) if {
 {{- range .AllowedDomains}}
     {{if hasPrefix . "*."}}
     # Wildcard: {{.}}
@@ -161,7 +216,7 @@ domain_allowed_{{.SafeName}}(domain) if {
 {{- end}}
 }
 
-domain_allowed_{{.SafeName}}(domain) if {
+domain_allowed_{{$name}}(domain) if {
 {{- end}}
     false  # fallback
 }
@@ -173,6 +228,7 @@ domain_denied_{{.SafeName}}(domain) if {
 {{- else}}
     domain == "{{.}}"
 {{- end}}
+{{- end}}
 }
 {{end}}
 
@@ -183,7 +239,8 @@ decision := {
     "allow": final_allow,
     "deny": deny,
     "mts": mts_allow,
-    "reason": reason
+    "reason": reason{{if .ObligationRules}},
+    "obligations": obligations{{end}}
 }
 
 # Final allow considers MTS
@@ -227,6 +284,9 @@ type templateData struct {
 	MTSEnabled     bool
 	MTSLabel       string
 	MTSEnforceMode string
+
+	// ObligationRules are the obligations of each allowed tool that has any
+	ObligationRules []obligationRuleData
 }
 
 type ruleData struct {
@@ -235,6 +295,12 @@ type ruleData struct {
 	ConstraintRego string
 }
 
+// obligationRuleData holds a tool's obligations as a Rego (JSON) array.
+type obligationRuleData struct {
+	Tool        string
+	Obligations string
+}
+
 type pathHelperData struct {
 	SafeName string
 	Patterns []string
@@ -246,8 +312,8 @@ type domainHelperData struct {
 	DeniedDomains  []string
 }
 
-// CompileToRego converts a PolicySpec to a complete Rego module.
-// This is the main entry point for policy generation.
+// CompileToRego converts a PolicySpec to a complete Rego module using the
+// v1 template, regardless of spec.TemplateVersion.
 func CompileToRego(spec *PolicySpec) (string, error) {
 	// Process the spec into template data
 	data := processSpec(spec)
@@ -256,6 +322,9 @@ func CompileToRego(spec *PolicySpec) (string, error) {
 	funcMap := template.FuncMap{
 		"hasPrefix":  strings.HasPrefix,
 		"trimPrefix": strings.TrimPrefix,
+		"pathNormalization": func() string {
+			return strings.ReplaceAll(pathNormalizationRego, "\t", "    ")
+		},
 	}
 
 	tmpl, err := template.New("rego").Funcs(funcMap).Parse(regoTemplate)
@@ -326,16 +395,44 @@ func processSpec(spec *PolicySpec) templateData {
 		}
 	}
 
+	data.ObligationRules = obligationRules(spec)
 	return data
 }
 
+// obligationRules collects the obligations of each tool's allow rules, in
+// order of first appearance, rendered as Rego arrays. A tool listed by
+// several allow rules gets the obligations of all of them.
+func obligationRules(spec *PolicySpec) []obligationRuleData {
+	var tools []string
+	byTool := make(map[string][]ObligationSpec)
+	for _, tp := range spec.ToolPermissions {
+		if tp.Action != "allow" || len(tp.Obligations) == 0 {
+			continue
+		}
+		if _, ok := byTool[tp.Tool]; !ok {
+			tools = append(tools, tp.Tool)
+		}
+		byTool[tp.Tool] = append(byTool[tp.Tool], tp.Obligations...)
+	}
+
+	rules := make([]obligationRuleData, 0, len(tools))
+	for _, tool := range tools {
+		// JSON is valid Rego; map keys are sorted, so output is stable
+		encoded, _ := json.Marshal(byTool[tool])
+		rules = append(rules, obligationRuleData{Tool: tool, Obligations: string(encoded)})
+	}
+	return rules
+}
+
 // hasAnyConstraint checks if a ConstraintSpec has any constraints defined.
 func hasAnyConstraint(c *ConstraintSpec) bool {
 	return len(c.PathPatterns) > 0 ||
 		len(c.AllowedDomains) > 0 ||
 		len(c.DeniedDomains) > 0 ||
 		len(c.AllowedPorts) > 0 ||
-		c.MaxSizeBytes > 0
+		c.MaxSizeBytes > 0 ||
+		len(c.RequiredAgentLabels) > 0 ||
+		len(c.AllowedContentHashes) > 0
 }
 
 // generateConstraintRego generates inline Rego for constraint checking.
@@ -344,7 +441,9 @@ func generateConstraintRego(tool string, c *ConstraintSpec, safeName string) str
 
 	// Path constraints
 	if len(c.PathPatterns) > 0 {
-		lines = append(lines, fmt.Sprintf("    path_allowed_%s(input.request.path)", safeName))
+		for _, cond := range pathConditions("path_allowed_" + safeName) {
+			lines = append(lines, "    "+cond)
+		}
 	}
 
 	// Domain constraints (allowed)
@@ -371,9 +470,76 @@ func generateConstraintRego(tool string, c *ConstraintSpec, safeName string) str
 		lines = append(lines, fmt.Sprintf("    input.request.size <= %d", c.MaxSizeBytes))
 	}
 
+	// Content hash allowlist
+	if len(c.AllowedContentHashes) > 0 {
+		lines = append(lines, "    "+contentHashRego(c.AllowedContentHashes))
+	}
+
+	// Required agent labels (sorted for deterministic output)
+	if len(c.RequiredAgentLabels) > 0 {
+		keys := make([]string, 0, len(c.RequiredAgentLabels))
+		for k := range c.RequiredAgentLabels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("    input.agent.labels[%q] == %q", k, c.RequiredAgentLabels[k]))
+		}
+	}
+
 	return strings.Join(lines, "\n")
 }
 
+// pathNormalizationRego defines the path helpers behind pathConditions, the
+// Rego equivalent of policy.NormalizePath: paths with a ".." segment or a NUL
+// byte are rejected, and repeated separators and "." segments are dropped
+// before a path is matched against patterns.
+const pathNormalizationRego = `
+# Paths that could escape a pattern's directory never match
+path_traversal(path) if {
+	some segment in split(path, "/")
+	segment == ".."
+}
+
+path_traversal(path) if {
+	regex.match("\\x00", path)
+}
+
+# clean_path("/workspace//a/./b/") == "/workspace/a/b"
+clean_path(path) := cleaned if {
+	kept := [segment | some i, segment in split(path, "/"); keep_segment(i, segment)]
+	cleaned := concat("/", kept)
+}
+
+# The leading empty segment keeps an absolute path absolute
+keep_segment(i, segment) if {
+	i == 0
+	segment == ""
+}
+
+keep_segment(_, segment) if {
+	segment != ""
+	segment != "."
+}`
+
+// pathConditions generates the conditions checking input.request.path
+// against the path pattern helper named helper.
+func pathConditions(helper string) []string {
+	return []string{
+		"not path_traversal(input.request.path)",
+		fmt.Sprintf("%s(clean_path(input.request.path))", helper),
+	}
+}
+
+// contentHashRego generates the set membership check for a content hash allowlist.
+func contentHashRego(hashes []string) string {
+	quoted := make([]string, len(hashes))
+	for i, h := range hashes {
+		quoted[i] = fmt.Sprintf("%q", h)
+	}
+	return fmt.Sprintf("input.request.content_sha256 in {%s}", strings.Join(quoted, ", "))
+}
+
 // makeSafeName converts a tool name to a safe Rego identifier.
 // "file.read" -> "file_read"
 func makeSafeName(tool string) string {
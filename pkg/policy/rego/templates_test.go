@@ -0,0 +1,86 @@
+package rego
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenSpecs are the template inputs covered by golden files.
+var goldenSpecs = map[string]*PolicySpec{
+	"basic": {
+		Name:          "basic-policy",
+		AgentTypes:    []string{"coding-assistant"},
+		DefaultAction: "deny",
+		Mode:          "enforcing",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "file.read", Action: "allow"},
+			{Tool: "shell.execute", Action: "deny"},
+		},
+	},
+	"constraints": {
+		Name:          "constraint-policy",
+		AgentTypes:    []string{"research-agent"},
+		DefaultAction: "deny",
+		Mode:          "enforcing",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "file.write", Action: "allow", Constraints: &ConstraintSpec{
+				PathPatterns: []string{"/workspace/**", "/tmp/**"},
+				MaxSizeBytes: 1048576,
+			}},
+			{Tool: "network.fetch", Action: "allow", Constraints: &ConstraintSpec{
+				AllowedDomains: []string{"github.com", "*.pypi.org"},
+				DeniedDomains:  []string{"evil.com", "*.pastebin.com"},
+				AllowedPorts:   []int32{443},
+			}},
+			{Tool: "deploy.run", Action: "allow", Constraints: &ConstraintSpec{
+				RequiredAgentLabels: map[string]string{"team": "infra", "env": "prod"},
+			}},
+			{Tool: "code.execute", Action: "allow", Constraints: &ConstraintSpec{
+				AllowedContentHashes: []string{"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+			}},
+		},
+	},
+	"mts": {
+		Name:           "tenant-policy",
+		AgentTypes:     []string{"tenant-agent"},
+		DefaultAction:  "allow",
+		Mode:           "permissive",
+		MTSLabel:       "s0:c100,c200",
+		MTSEnforceMode: "strict",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "db.admin", Action: "deny"},
+		},
+	},
+}
+
+// TestCompileToRegoGolden compares generated Rego against testdata/*.rego.
+// Run with -update to regenerate the golden files after template changes.
+func TestCompileToRegoGolden(t *testing.T) {
+	for name, spec := range goldenSpecs {
+		t.Run(name, func(t *testing.T) {
+			got, err := CompileToRego(spec)
+			if err != nil {
+				t.Fatalf("CompileToRego failed: %v", err)
+			}
+
+			path := filepath.Join("testdata", name+".rego")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("generated Rego does not match %s (run with -update to accept):\n%s", path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,466 @@
+package rego
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Template versions selectable through PolicySpec.TemplateVersion.
+const (
+	// TemplateV1 is the original single-module template, kept as a
+	// compatibility mode for policies that depend on its output
+	TemplateV1 = "v1"
+
+	// TemplateV2 generates one package per tool behind an entrypoint
+	TemplateV2 = "v2"
+
+	// DefaultTemplateVersion is used when PolicySpec.TemplateVersion is empty
+	DefaultTemplateVersion = TemplateV2
+)
+
+// Module file names used by the generated module sets.
+const (
+	// LegacyModule is the file name of the single v1 module
+	LegacyModule = "policy.rego"
+
+	// EntrypointModule is the file name of the v2 entrypoint (package agentpolicy)
+	EntrypointModule = "agentpolicy.rego"
+)
+
+// ToolModule returns the v2 module file name for a tool package key.
+func ToolModule(key string) string {
+	return "tools/" + key + ".rego"
+}
+
+// CompileToModules generates the Rego modules for a PolicySpec, keyed by
+// file name, using the template version the spec selects. Version v1 yields
+// a single module; v2 yields an entrypoint plus one module per tool.
+// Every version defines data.agentpolicy.decision.
+func CompileToModules(spec *PolicySpec) (map[string]string, error) {
+	switch spec.TemplateVersion {
+	case "", TemplateV2:
+		return CompileToRegoV2(spec)
+	case TemplateV1:
+		module, err := CompileToRego(spec)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{LegacyModule: module}, nil
+	default:
+		return nil, fmt.Errorf("unknown Rego template version %q", spec.TemplateVersion)
+	}
+}
+
+// entrypointTemplate is the v2 package agentpolicy module. It dispatches the
+// request to the package of the requested tool and combines its verdict with
+// the default action and tenant isolation.
+const entrypointTemplate = `# Auto-generated from AgentPolicy CRD: {{.Name}} (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool rules live in data.agentpolicy.tools.<key>, where the key is the tool
+# name with non-identifier characters replaced ("file.read" -> "file_read").
+{{- range .Tools}}
+#   {{.Key}}: {{.Tool}}
+{{- end}}
+tool_key := regex.replace(input.tool, "[^a-zA-Z0-9_]", "_")
+
+default listed := false
+default allow := false
+default deny := false
+
+listed if {
+	data.agentpolicy.tools[tool_key].listed
+}
+
+# Listed tools are decided by their own package
+allow if {
+	listed
+	data.agentpolicy.tools[tool_key].allow
+}
+
+deny if {
+	listed
+	data.agentpolicy.tools[tool_key].deny
+}
+{{- if .HasObligations}}
+
+# Obligations of allowed calls are defined by the tool's package
+default obligations := []
+
+obligations := data.agentpolicy.tools[tool_key].obligations if {
+	listed
+}
+{{- end}}
+{{if eq .DefaultAction "allow"}}
+# Default action: allow unlisted tools
+allow if {
+	not listed
+}
+{{else}}
+# Default action: deny unlisted tools
+{{end}}
+# ============================================================================
+# Multi-Tenant Sandboxing (MTS) enforcement
+# ============================================================================
+{{if and .MTSEnabled (eq .MTSEnforceMode "strict")}}
+# MTS Label: {{.MTSLabel}} (strict: require exact label match)
+default mts_allow := false
+
+mts_allow if {
+	input.agent.mts_label == {{printf "%q" .MTSLabel}}
+}
+
+# Parent tenants dominate the labels of delegating child tenants
+mts_allow if {
+	input.agent.mts_dominates
+}
+{{else if .MTSEnabled}}
+# MTS Label: {{.MTSLabel}} ({{.MTSEnforceMode}}: no tenant check)
+mts_allow := true
+{{else}}
+# MTS not configured - allow all
+mts_allow := true
+{{end}}
+# ============================================================================
+# Final decision object
+# ============================================================================
+decision := {
+	"allow": final_allow,
+	"deny": deny,
+	"mts": mts_allow,
+	"reason": reason,
+{{- if .HasObligations}}
+	"obligations": obligations,
+{{- end}}
+}
+
+default final_allow := false
+
+final_allow if {
+	allow
+	not deny
+	mts_allow
+}
+
+reason := "tool explicitly denied" if {
+	deny
+}
+
+reason := "MTS violation: tenant isolation" if {
+	allow
+	not deny
+	not mts_allow
+}
+
+reason := "tool explicitly allowed" if {
+	allow
+	not deny
+	mts_allow
+	listed
+}
+
+reason := "allowed by default policy" if {
+	allow
+	not deny
+	mts_allow
+	not listed
+}
+
+reason := "constraints not satisfied" if {
+	not allow
+	not deny
+	listed
+}
+
+reason := "denied by default policy" if {
+	not allow
+	not deny
+	not listed
+}
+`
+
+// toolTemplate is a v2 per-tool package. Each allow rule becomes its own
+// allow body with private helpers; every pattern or domain is a separate
+// helper body, so helpers succeed when any alternative matches.
+const toolTemplate = `# Auto-generated from AgentPolicy CRD: {{.Policy}} (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.{{.Key}}
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: {{.Tool}}
+listed := true
+{{if .Denied}}
+# Explicitly denied
+deny := true
+{{- else}}
+default allow := false
+{{- if .Obligations}}
+
+# Obligations the caller must fulfil before executing an allowed call
+obligations := {{.Obligations}}
+{{- end}}
+{{- range .Rules}}
+{{if .Conditions}}
+allow if {
+{{- range .Conditions}}
+	{{.}}
+{{- end}}
+}
+{{- else}}
+allow := true
+{{- end}}
+{{- $suffix := .Suffix}}
+{{- range .PathPatterns}}
+
+path_allowed{{$suffix}}(path) if {
+	glob.match({{printf "%q" .}}, [], path)
+}
+{{- end}}
+{{- range .AllowedDomains}}
+
+domain_allowed{{$suffix}}(domain) if {
+	{{domainMatch .}}
+}
+{{- end}}
+{{- range .DeniedDomains}}
+
+domain_denied{{$suffix}}(domain) if {
+	{{domainMatch .}}
+}
+{{- end}}
+{{- end}}
+{{- if .HasPaths}}
+{{pathNormalization}}
+{{- end}}
+{{- end}}
+`
+
+// toolData is the template input for one v2 tool package.
+type toolData struct {
+	Policy string
+	Tool   string
+	Key    string
+	Denied bool
+	Rules  []toolRuleData
+
+	// HasPaths is set if any rule has path patterns, to emit the shared
+	// path normalization helpers once per package
+	HasPaths bool
+
+	// Obligations is the Rego array of the allow rules' obligations, if any
+	Obligations string
+}
+
+// toolRuleData is one allow rule within a tool package. Suffix keeps the
+// helpers of a tool's second and later rules apart ("path_allowed_2").
+type toolRuleData struct {
+	Suffix         string
+	Conditions     []string
+	PathPatterns   []string
+	AllowedDomains []string
+	DeniedDomains  []string
+}
+
+// entrypointData is the template input for the v2 entrypoint.
+type entrypointData struct {
+	Name           string
+	DefaultAction  string
+	Tools          []toolData
+	MTSEnabled     bool
+	MTSLabel       string
+	MTSEnforceMode string
+	HasObligations bool
+}
+
+var (
+	nonIdentRe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	identRe    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// regoKeywords cannot be used as package path segments.
+var regoKeywords = map[string]bool{
+	"as": true, "contains": true, "default": true, "else": true, "every": true,
+	"false": true, "if": true, "import": true, "in": true, "not": true,
+	"null": true, "package": true, "some": true, "true": true, "with": true,
+}
+
+// ToolKey returns the v2 package key for a tool name. The entrypoint
+// computes the same key from input.tool at evaluation time.
+// "file.read" -> "file_read", "db-admin" -> "db_admin"
+func ToolKey(tool string) string {
+	return nonIdentRe.ReplaceAllString(tool, "_")
+}
+
+// CompileToRegoV2 converts a PolicySpec to a v2 module set: an entrypoint
+// (package agentpolicy) and one package per listed tool
+// (package agentpolicy.tools.<key>), keyed by file name.
+//
+// Tool packages decide listed tools on their own: a listed tool whose allow
+// rules all fail is denied even when the default action is allow, matching
+// the legacy engine. A deny rule for a tool overrides its allow rules.
+// Tools whose names map to the same key, or to a key that is not a valid
+// Rego identifier, are rejected.
+func CompileToRegoV2(spec *PolicySpec) (map[string]string, error) {
+	tools, err := processToolsV2(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	funcMap := template.FuncMap{
+		"domainMatch":       domainMatch,
+		"pathNormalization": func() string { return pathNormalizationRego },
+	}
+	entryTmpl, err := template.New("entrypoint").Parse(entrypointTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Rego entrypoint template: %w", err)
+	}
+	toolTmpl, err := template.New("tool").Funcs(funcMap).Parse(toolTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Rego tool template: %w", err)
+	}
+
+	mtsMode := spec.MTSEnforceMode
+	if mtsMode == "" {
+		mtsMode = "strict" // default
+	}
+
+	modules := make(map[string]string, len(tools)+1)
+
+	hasObligations := false
+	for _, tool := range tools {
+		hasObligations = hasObligations || tool.Obligations != ""
+	}
+
+	var buf bytes.Buffer
+	if err := entryTmpl.Execute(&buf, entrypointData{
+		Name:           spec.Name,
+		DefaultAction:  spec.DefaultAction,
+		Tools:          tools,
+		MTSEnabled:     spec.MTSLabel != "",
+		MTSLabel:       spec.MTSLabel,
+		MTSEnforceMode: mtsMode,
+		HasObligations: hasObligations,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to execute Rego entrypoint template: %w", err)
+	}
+	modules[EntrypointModule] = buf.String()
+
+	for _, tool := range tools {
+		buf.Reset()
+		if err := toolTmpl.Execute(&buf, tool); err != nil {
+			return nil, fmt.Errorf("failed to execute Rego template for tool %s: %w", tool.Tool, err)
+		}
+		modules[ToolModule(tool.Key)] = buf.String()
+	}
+
+	return modules, nil
+}
+
+// processToolsV2 groups tool permissions into per-tool package data, in
+// order of first appearance.
+func processToolsV2(spec *PolicySpec) ([]toolData, error) {
+	var tools []toolData
+	index := make(map[string]int)
+
+	for _, tp := range spec.ToolPermissions {
+		key := ToolKey(tp.Tool)
+		if !identRe.MatchString(key) || regoKeywords[key] {
+			return nil, fmt.Errorf("tool %q cannot be mapped to a Rego package name", tp.Tool)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(tools)
+			index[key] = i
+			tools = append(tools, toolData{Policy: spec.Name, Tool: tp.Tool, Key: key})
+		} else if tools[i].Tool != tp.Tool {
+			return nil, fmt.Errorf("tools %q and %q map to the same Rego package %s", tools[i].Tool, tp.Tool, key)
+		}
+
+		if tp.Action != "allow" {
+			tools[i].Denied = true
+			continue
+		}
+
+		suffix := ""
+		if n := len(tools[i].Rules); n > 0 {
+			suffix = fmt.Sprintf("_%d", n+1)
+		}
+		tools[i].Rules = append(tools[i].Rules, ruleV2(tp.Constraints, suffix))
+		if tp.Constraints != nil && len(tp.Constraints.PathPatterns) > 0 {
+			tools[i].HasPaths = true
+		}
+	}
+
+	for _, rule := range obligationRules(spec) {
+		tools[index[ToolKey(rule.Tool)]].Obligations = rule.Obligations
+	}
+
+	return tools, nil
+}
+
+// ruleV2 builds the allow body and helper inputs for one allow rule.
+func ruleV2(c *ConstraintSpec, suffix string) toolRuleData {
+	rule := toolRuleData{Suffix: suffix}
+	if c == nil {
+		return rule
+	}
+
+	rule.PathPatterns = c.PathPatterns
+	rule.AllowedDomains = c.AllowedDomains
+	rule.DeniedDomains = c.DeniedDomains
+
+	if len(c.PathPatterns) > 0 {
+		rule.Conditions = append(rule.Conditions, pathConditions("path_allowed"+suffix)...)
+	}
+	if len(c.AllowedDomains) > 0 {
+		rule.Conditions = append(rule.Conditions, fmt.Sprintf("domain_allowed%s(input.request.domain)", suffix))
+	}
+	if len(c.DeniedDomains) > 0 {
+		rule.Conditions = append(rule.Conditions, fmt.Sprintf("not domain_denied%s(input.request.domain)", suffix))
+	}
+	if len(c.AllowedPorts) > 0 {
+		ports := make([]string, len(c.AllowedPorts))
+		for i, p := range c.AllowedPorts {
+			ports[i] = fmt.Sprintf("%d", p)
+		}
+		rule.Conditions = append(rule.Conditions, fmt.Sprintf("input.request.port in {%s}", strings.Join(ports, ", ")))
+	}
+	if c.MaxSizeBytes > 0 {
+		rule.Conditions = append(rule.Conditions, fmt.Sprintf("input.request.size <= %d", c.MaxSizeBytes))
+	}
+	if len(c.AllowedContentHashes) > 0 {
+		rule.Conditions = append(rule.Conditions, contentHashRego(c.AllowedContentHashes))
+	}
+
+	// Required agent labels (sorted for deterministic output)
+	keys := make([]string, 0, len(c.RequiredAgentLabels))
+	for k := range c.RequiredAgentLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rule.Conditions = append(rule.Conditions, fmt.Sprintf("input.agent.labels[%q] == %q", k, c.RequiredAgentLabels[k]))
+	}
+
+	return rule
+}
+
+// domainMatch renders the match expression for one domain pattern.
+// "*.example.com" matches subdomains by suffix; anything else is exact.
+func domainMatch(pattern string) string {
+	if strings.HasPrefix(pattern, "*.") {
+		return fmt.Sprintf("endswith(domain, %q)", strings.TrimPrefix(pattern, "*"))
+	}
+	return fmt.Sprintf("domain == %q", pattern)
+}
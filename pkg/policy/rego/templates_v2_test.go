@@ -0,0 +1,304 @@
+package rego
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestCompileToRegoV2Golden compares generated v2 module sets against
+// testdata/v2/<name>/. Run with -update to regenerate the golden files.
+func TestCompileToRegoV2Golden(t *testing.T) {
+	for name, spec := range goldenSpecs {
+		t.Run(name, func(t *testing.T) {
+			modules, err := CompileToRegoV2(spec)
+			if err != nil {
+				t.Fatalf("CompileToRegoV2 failed: %v", err)
+			}
+
+			dir := filepath.Join("testdata", "v2", name)
+			if *update {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("failed to clear golden dir: %v", err)
+				}
+				for file, src := range modules {
+					path := filepath.Join(dir, file)
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatalf("failed to create golden dir: %v", err)
+					}
+					if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+						t.Fatalf("failed to update golden file: %v", err)
+					}
+				}
+			}
+
+			var files []string
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(dir, path)
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("failed to read golden dir (run with -update to create): %v", err)
+			}
+			if got := sortedKeys(modules); strings.Join(got, ",") != strings.Join(files, ",") {
+				t.Fatalf("generated modules %v do not match golden files %v", got, files)
+			}
+
+			for _, file := range files {
+				want, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil {
+					t.Fatalf("failed to read golden file: %v", err)
+				}
+				if modules[file] != string(want) {
+					t.Errorf("generated %s does not match golden file (run with -update to accept):\n%s", file, modules[file])
+				}
+			}
+		})
+	}
+}
+
+// TestMigrationV1ToV2 tests that v2 covers the same tools as v1 with one
+// package each and fixes the helper defects the linter finds in v1 output.
+func TestMigrationV1ToV2(t *testing.T) {
+	for name, spec := range goldenSpecs {
+		t.Run(name, func(t *testing.T) {
+			v1, err := CompileToRego(spec)
+			if err != nil {
+				t.Fatalf("CompileToRego failed: %v", err)
+			}
+			v2, err := CompileToRegoV2(spec)
+			if err != nil {
+				t.Fatalf("CompileToRegoV2 failed: %v", err)
+			}
+
+			for file, src := range v2 {
+				if findings := Lint(src); len(findings) != 0 {
+					t.Errorf("%s: expected no lint findings, got %v", file, findings)
+				}
+			}
+
+			// The entrypoint keeps the query path and decision shape of v1
+			entry := v2[EntrypointModule]
+			for _, want := range []string{"package agentpolicy\n", "decision := {", `"allow": final_allow`, `"reason": reason`} {
+				if !strings.Contains(v1, want) || !strings.Contains(entry, want) {
+					t.Errorf("expected both templates to contain %q", want)
+				}
+			}
+
+			// Every v1 rule has a tool package in v2
+			for _, tp := range spec.ToolPermissions {
+				if !strings.Contains(v1, `input.tool == "`+tp.Tool+`"`) {
+					t.Errorf("v1 has no rule for %s", tp.Tool)
+				}
+				src, ok := v2[ToolModule(ToolKey(tp.Tool))]
+				if !ok {
+					t.Errorf("v2 has no module for %s", tp.Tool)
+					continue
+				}
+				if !strings.Contains(src, "package agentpolicy.tools."+ToolKey(tp.Tool)+"\n") {
+					t.Errorf("module for %s has the wrong package:\n%s", tp.Tool, src)
+				}
+			}
+			if len(v2) != len(spec.ToolPermissions)+1 {
+				t.Errorf("expected %d modules, got %v", len(spec.ToolPermissions)+1, sortedKeys(v2))
+			}
+		})
+	}
+}
+
+// TestCompileToRegoV2Rules tests multi-rule tools, deny precedence, and
+// one helper body per alternative.
+func TestCompileToRegoV2Rules(t *testing.T) {
+	spec := &PolicySpec{
+		Name:          "multi-policy",
+		DefaultAction: "allow",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "network.fetch", Action: "allow", Constraints: &ConstraintSpec{
+				AllowedDomains: []string{"github.com", "*.github.io"},
+			}},
+			{Tool: "network.fetch", Action: "allow", Constraints: &ConstraintSpec{
+				AllowedDomains: []string{"pypi.org"},
+				AllowedPorts:   []int32{443},
+			}},
+			{Tool: "shell.exec", Action: "allow"},
+			{Tool: "shell.exec", Action: "deny"},
+		},
+	}
+
+	modules, err := CompileToRegoV2(spec)
+	if err != nil {
+		t.Fatalf("CompileToRegoV2 failed: %v", err)
+	}
+
+	fetch := modules[ToolModule("network_fetch")]
+	if n := strings.Count(fetch, "allow if {"); n != 2 {
+		t.Errorf("expected 2 allow bodies, got %d:\n%s", n, fetch)
+	}
+	if n := strings.Count(fetch, "domain_allowed(domain) if {"); n != 2 {
+		t.Errorf("expected one domain_allowed body per domain, got %d:\n%s", n, fetch)
+	}
+	if !strings.Contains(fetch, "domain_allowed_2(input.request.domain)") ||
+		!strings.Contains(fetch, "domain_allowed_2(domain) if {\n\tdomain == \"pypi.org\"") {
+		t.Errorf("expected second rule to use its own helper:\n%s", fetch)
+	}
+
+	shell := modules[ToolModule("shell_exec")]
+	if !strings.Contains(shell, "deny := true") || strings.Contains(shell, "allow") {
+		t.Errorf("expected deny to override allow:\n%s", shell)
+	}
+
+	if !strings.Contains(modules[EntrypointModule], "allow if {\n\tnot listed\n}") {
+		t.Errorf("expected default allow for unlisted tools only:\n%s", modules[EntrypointModule])
+	}
+
+	for file, src := range modules {
+		if findings := Lint(src); len(findings) != 0 {
+			t.Errorf("%s: expected no lint findings, got %v", file, findings)
+		}
+	}
+}
+
+// TestCompileObligations tests that both template versions return the
+// obligations of the requested tool in the decision object, and leave the
+// decision object unchanged for policies without obligations.
+func TestCompileObligations(t *testing.T) {
+	spec := &PolicySpec{
+		Name:          "obligation-policy",
+		DefaultAction: "deny",
+		ToolPermissions: []ToolPermissionSpec{
+			{Tool: "file.write", Action: "allow", Obligations: []ObligationSpec{
+				{Type: "notify", Params: map[string]string{"channel": "#security-alerts"}},
+			}},
+			{Tool: "file.write", Action: "allow", Obligations: []ObligationSpec{{Type: "logPayload"}}},
+			{Tool: "file.read", Action: "allow"},
+		},
+	}
+	const want = `[{"type":"notify","params":{"channel":"#security-alerts"}},{"type":"logPayload"}]`
+
+	v1, err := CompileToRego(spec)
+	if err != nil {
+		t.Fatalf("CompileToRego failed: %v", err)
+	}
+	if !strings.Contains(v1, "obligations := "+want+" if {\n    input.tool == \"file.write\"") ||
+		!strings.Contains(v1, `"obligations": obligations`) {
+		t.Errorf("expected v1 obligations for file.write:\n%s", v1)
+	}
+
+	v2, err := CompileToRegoV2(spec)
+	if err != nil {
+		t.Fatalf("CompileToRegoV2 failed: %v", err)
+	}
+	if !strings.Contains(v2[ToolModule("file_write")], "obligations := "+want) ||
+		strings.Contains(v2[ToolModule("file_read")], "obligations") {
+		t.Errorf("expected obligations in the file.write package only:\n%s", v2[ToolModule("file_write")])
+	}
+	if !strings.Contains(v2[EntrypointModule], "obligations := data.agentpolicy.tools[tool_key].obligations if {") ||
+		!strings.Contains(v2[EntrypointModule], `"obligations": obligations,`) {
+		t.Errorf("expected entrypoint to return the tool's obligations:\n%s", v2[EntrypointModule])
+	}
+	for file, src := range v2 {
+		if findings := Lint(src); len(findings) != 0 {
+			t.Errorf("%s: expected no lint findings, got %v", file, findings)
+		}
+	}
+
+	spec.ToolPermissions = spec.ToolPermissions[2:]
+	v2, _ = CompileToRegoV2(spec)
+	if strings.Contains(v2[EntrypointModule], "obligations") {
+		t.Errorf("expected no obligations without obligation rules:\n%s", v2[EntrypointModule])
+	}
+}
+
+// TestCompileToRegoV2Keys tests rejection of tool names without a usable package key.
+func TestCompileToRegoV2Keys(t *testing.T) {
+	tests := []struct {
+		name  string
+		tools []string
+	}{
+		{name: "collision", tools: []string{"file.read", "file_read"}},
+		{name: "leading digit", tools: []string{"3d.render"}},
+		{name: "keyword", tools: []string{"import"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &PolicySpec{Name: "p", DefaultAction: "deny"}
+			for _, tool := range tt.tools {
+				spec.ToolPermissions = append(spec.ToolPermissions, ToolPermissionSpec{Tool: tool, Action: "allow"})
+			}
+			if _, err := CompileToRegoV2(spec); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if key := ToolKey("db-admin.v2"); key != "db_admin_v2" {
+		t.Errorf("unexpected key %q", key)
+	}
+}
+
+// TestCompileToModules tests template version selection.
+func TestCompileToModules(t *testing.T) {
+	spec := *goldenSpecs["basic"]
+
+	modules, err := CompileToModules(&spec)
+	if err != nil {
+		t.Fatalf("CompileToModules failed: %v", err)
+	}
+	if _, ok := modules[EntrypointModule]; !ok {
+		t.Errorf("expected v2 by default, got %v", sortedKeys(modules))
+	}
+
+	spec.TemplateVersion = TemplateV1
+	modules, err = CompileToModules(&spec)
+	if err != nil {
+		t.Fatalf("CompileToModules failed: %v", err)
+	}
+	v1, _ := CompileToRego(&spec)
+	if len(modules) != 1 || modules[LegacyModule] != v1 {
+		t.Errorf("expected compatibility mode to produce the v1 module, got %v", sortedKeys(modules))
+	}
+
+	spec.TemplateVersion = "v3"
+	if _, err := CompileToModules(&spec); err == nil {
+		t.Error("expected error for unknown template version")
+	}
+}
+
+// TestVetModules tests that findings carry their module name.
+func TestVetModules(t *testing.T) {
+	modules := map[string]string{
+		"a.rego": "package a\n\nimport rego.v1\n\nhelper(x) if {\n    true\n}\n",
+		"b.rego": "package b\n\nimport rego.v1\n\nallow if {\n    input.tool == \"x\"\n}\n",
+	}
+
+	formatted, warnings, err := VetModules(modules)
+	if err != nil {
+		t.Fatalf("VetModules failed: %v", err)
+	}
+	if len(formatted) != 2 {
+		t.Errorf("expected 2 formatted modules, got %d", len(formatted))
+	}
+	if len(warnings) != 1 || warnings[0].Module != "a.rego" || !strings.HasPrefix(warnings[0].String(), "a.rego:5:") {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	modules["c.rego"] = "package c\n\nimport rego.v1\n\nallow if {\n    missing(input.tool)\n}\n"
+	if _, _, err := VetModules(modules); err == nil || !strings.Contains(err.Error(), "c.rego:5:") {
+		t.Errorf("expected lint error naming c.rego, got %v", err)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+# Auto-generated from AgentPolicy CRD: basic-policy
+# Do not edit directly - changes will be overwritten
+package agentpolicy
+
+import future.keywords.if
+import future.keywords.in
+
+# Default action: deny
+default allow := false
+default deny := false
+default mts_allow := true
+
+# ============================================================================
+# Tool-specific allow rules
+# ============================================================================
+
+# Rule: file.read - allowed
+allow if {
+    input.tool == "file.read"
+}
+
+
+# ============================================================================
+# Tool-specific deny rules
+# ============================================================================
+
+# Rule: shell.execute - denied
+deny if {
+    input.tool == "shell.execute"
+}
+
+
+# ============================================================================
+# Multi-Tenant Sandboxing (MTS) enforcement
+# ============================================================================
+
+# MTS not configured - allow all
+mts_allow := true
+
+
+# ============================================================================
+# Path constraint helpers
+# ============================================================================
+
+
+# ============================================================================
+# Domain constraint helpers
+# ============================================================================
+
+
+# ============================================================================
+# Final decision object
+# ============================================================================
+decision := {
+    "allow": final_allow,
+    "deny": deny,
+    "mts": mts_allow,
+    "reason": reason
+}
+
+# Final allow considers MTS
+final_allow if {
+    allow
+    not deny
+    mts_allow
+}
+
+# Reason determination
+reason := "tool explicitly allowed" if {
+    allow
+    not deny
+    mts_allow
+}
+
+reason := "tool explicitly denied" if {
+    deny
+}
+
+reason := "MTS violation: tenant isolation" if {
+    allow
+    not deny
+    not mts_allow
+}
+
+reason := "denied by default policy" if {
+    not allow
+    not deny
+}
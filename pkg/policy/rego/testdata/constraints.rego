@@ -0,0 +1,168 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy
+# Do not edit directly - changes will be overwritten
+package agentpolicy
+
+import future.keywords.if
+import future.keywords.in
+
+# Default action: deny
+default allow := false
+default deny := false
+default mts_allow := true
+
+# ============================================================================
+# Tool-specific allow rules
+# ============================================================================
+
+# Rule: file.write - allowed
+allow if {
+    input.tool == "file.write"
+        not path_traversal(input.request.path)
+    path_allowed_file_write(clean_path(input.request.path))
+    input.request.size <= 1048576
+}
+
+# Rule: network.fetch - allowed
+allow if {
+    input.tool == "network.fetch"
+        domain_allowed_network_fetch(input.request.domain)
+    not domain_denied_network_fetch(input.request.domain)
+    input.request.port in {443}
+}
+
+# Rule: deploy.run - allowed
+allow if {
+    input.tool == "deploy.run"
+        input.agent.labels["env"] == "prod"
+    input.agent.labels["team"] == "infra"
+}
+
+# Rule: code.execute - allowed
+allow if {
+    input.tool == "code.execute"
+        input.request.content_sha256 in {"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+}
+
+
+# ============================================================================
+# Tool-specific deny rules
+# ============================================================================
+
+
+# ============================================================================
+# Multi-Tenant Sandboxing (MTS) enforcement
+# ============================================================================
+
+# MTS not configured - allow all
+mts_allow := true
+
+
+# ============================================================================
+# Path constraint helpers
+# ============================================================================
+
+# Paths that could escape a pattern's directory never match
+path_traversal(path) if {
+    some segment in split(path, "/")
+    segment == ".."
+}
+
+path_traversal(path) if {
+    regex.match("\\x00", path)
+}
+
+# clean_path("/workspace//a/./b/") == "/workspace/a/b"
+clean_path(path) := cleaned if {
+    kept := [segment | some i, segment in split(path, "/"); keep_segment(i, segment)]
+    cleaned := concat("/", kept)
+}
+
+# The leading empty segment keeps an absolute path absolute
+keep_segment(i, segment) if {
+    i == 0
+    segment == ""
+}
+
+keep_segment(_, segment) if {
+    segment != ""
+    segment != "."
+}
+
+path_allowed_file_write(path) if {
+    glob.match("/workspace/**", [], path)
+}
+
+path_allowed_file_write(path) if {
+    glob.match("/tmp/**", [], path)
+}
+
+path_allowed_file_write(path) if {
+    false  # fallback
+}
+
+
+# ============================================================================
+# Domain constraint helpers
+# ============================================================================
+
+domain_allowed_network_fetch(domain) if {
+    
+    domain == "github.com"
+}
+
+domain_allowed_network_fetch(domain) if {
+    
+    # Wildcard: *.pypi.org
+    endswith(domain, ".pypi.org")
+}
+
+domain_allowed_network_fetch(domain) if {
+    false  # fallback
+}
+
+domain_denied_network_fetch(domain) if {
+    
+    domain == "evil.com"
+    
+    endswith(domain, ".pastebin.com")
+}
+
+
+# ============================================================================
+# Final decision object
+# ============================================================================
+decision := {
+    "allow": final_allow,
+    "deny": deny,
+    "mts": mts_allow,
+    "reason": reason
+}
+
+# Final allow considers MTS
+final_allow if {
+    allow
+    not deny
+    mts_allow
+}
+
+# Reason determination
+reason := "tool explicitly allowed" if {
+    allow
+    not deny
+    mts_allow
+}
+
+reason := "tool explicitly denied" if {
+    deny
+}
+
+reason := "MTS violation: tenant isolation" if {
+    allow
+    not deny
+    not mts_allow
+}
+
+reason := "denied by default policy" if {
+    not allow
+    not deny
+}
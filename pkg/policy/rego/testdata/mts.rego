@@ -0,0 +1,99 @@
+# Auto-generated from AgentPolicy CRD: tenant-policy
+# Do not edit directly - changes will be overwritten
+package agentpolicy
+
+import future.keywords.if
+import future.keywords.in
+
+# Default action: allow
+default allow := true
+default deny := false
+default mts_allow := true
+
+# ============================================================================
+# Tool-specific allow rules
+# ============================================================================
+
+
+# ============================================================================
+# Tool-specific deny rules
+# ============================================================================
+
+# Rule: db.admin - denied
+deny if {
+    input.tool == "db.admin"
+}
+
+
+# ============================================================================
+# Multi-Tenant Sandboxing (MTS) enforcement
+# ============================================================================
+
+# MTS Label: s0:c100,c200
+# Enforce Mode: strict
+
+# Strict mode: require exact label match
+mts_allow if {
+    input.agent.mts_label == "s0:c100,c200"
+}
+
+mts_allow if {
+    # Empty policy MTS label means no restriction
+    "s0:c100,c200" == ""
+}
+
+mts_allow if {
+    # Parent tenants dominate the labels of delegating child tenants
+    input.agent.mts_dominates
+}
+
+
+
+# ============================================================================
+# Path constraint helpers
+# ============================================================================
+
+
+# ============================================================================
+# Domain constraint helpers
+# ============================================================================
+
+
+# ============================================================================
+# Final decision object
+# ============================================================================
+decision := {
+    "allow": final_allow,
+    "deny": deny,
+    "mts": mts_allow,
+    "reason": reason
+}
+
+# Final allow considers MTS
+final_allow if {
+    allow
+    not deny
+    mts_allow
+}
+
+# Reason determination
+reason := "tool explicitly allowed" if {
+    allow
+    not deny
+    mts_allow
+}
+
+reason := "tool explicitly denied" if {
+    deny
+}
+
+reason := "MTS violation: tenant isolation" if {
+    allow
+    not deny
+    not mts_allow
+}
+
+reason := "denied by default policy" if {
+    not allow
+    not deny
+}
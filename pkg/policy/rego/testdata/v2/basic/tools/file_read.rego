@@ -0,0 +1,13 @@
+# Auto-generated from AgentPolicy CRD: basic-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.file_read
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: file.read
+listed := true
+
+default allow := false
+
+allow := true
@@ -0,0 +1,12 @@
+# Auto-generated from AgentPolicy CRD: basic-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.shell_execute
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: shell.execute
+listed := true
+
+# Explicitly denied
+deny := true
@@ -0,0 +1,96 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool rules live in data.agentpolicy.tools.<key>, where the key is the tool
+# name with non-identifier characters replaced ("file.read" -> "file_read").
+#   file_write: file.write
+#   network_fetch: network.fetch
+#   deploy_run: deploy.run
+#   code_execute: code.execute
+tool_key := regex.replace(input.tool, "[^a-zA-Z0-9_]", "_")
+
+default listed := false
+default allow := false
+default deny := false
+
+listed if {
+	data.agentpolicy.tools[tool_key].listed
+}
+
+# Listed tools are decided by their own package
+allow if {
+	listed
+	data.agentpolicy.tools[tool_key].allow
+}
+
+deny if {
+	listed
+	data.agentpolicy.tools[tool_key].deny
+}
+
+# Default action: deny unlisted tools
+
+# ============================================================================
+# Multi-Tenant Sandboxing (MTS) enforcement
+# ============================================================================
+
+# MTS not configured - allow all
+mts_allow := true
+
+# ============================================================================
+# Final decision object
+# ============================================================================
+decision := {
+	"allow": final_allow,
+	"deny": deny,
+	"mts": mts_allow,
+	"reason": reason,
+}
+
+default final_allow := false
+
+final_allow if {
+	allow
+	not deny
+	mts_allow
+}
+
+reason := "tool explicitly denied" if {
+	deny
+}
+
+reason := "MTS violation: tenant isolation" if {
+	allow
+	not deny
+	not mts_allow
+}
+
+reason := "tool explicitly allowed" if {
+	allow
+	not deny
+	mts_allow
+	listed
+}
+
+reason := "allowed by default policy" if {
+	allow
+	not deny
+	mts_allow
+	not listed
+}
+
+reason := "constraints not satisfied" if {
+	not allow
+	not deny
+	listed
+}
+
+reason := "denied by default policy" if {
+	not allow
+	not deny
+	not listed
+}
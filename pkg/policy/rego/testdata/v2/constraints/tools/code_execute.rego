@@ -0,0 +1,15 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.code_execute
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: code.execute
+listed := true
+
+default allow := false
+
+allow if {
+	input.request.content_sha256 in {"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+}
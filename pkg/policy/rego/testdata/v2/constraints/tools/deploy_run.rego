@@ -0,0 +1,16 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.deploy_run
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: deploy.run
+listed := true
+
+default allow := false
+
+allow if {
+	input.agent.labels["env"] == "prod"
+	input.agent.labels["team"] == "infra"
+}
@@ -0,0 +1,52 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.file_write
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: file.write
+listed := true
+
+default allow := false
+
+allow if {
+	not path_traversal(input.request.path)
+	path_allowed(clean_path(input.request.path))
+	input.request.size <= 1048576
+}
+
+path_allowed(path) if {
+	glob.match("/workspace/**", [], path)
+}
+
+path_allowed(path) if {
+	glob.match("/tmp/**", [], path)
+}
+
+# Paths that could escape a pattern's directory never match
+path_traversal(path) if {
+	some segment in split(path, "/")
+	segment == ".."
+}
+
+path_traversal(path) if {
+	regex.match("\\x00", path)
+}
+
+# clean_path("/workspace//a/./b/") == "/workspace/a/b"
+clean_path(path) := cleaned if {
+	kept := [segment | some i, segment in split(path, "/"); keep_segment(i, segment)]
+	cleaned := concat("/", kept)
+}
+
+# The leading empty segment keeps an absolute path absolute
+keep_segment(i, segment) if {
+	i == 0
+	segment == ""
+}
+
+keep_segment(_, segment) if {
+	segment != ""
+	segment != "."
+}
@@ -0,0 +1,33 @@
+# Auto-generated from AgentPolicy CRD: constraint-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.network_fetch
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: network.fetch
+listed := true
+
+default allow := false
+
+allow if {
+	domain_allowed(input.request.domain)
+	not domain_denied(input.request.domain)
+	input.request.port in {443}
+}
+
+domain_allowed(domain) if {
+	domain == "github.com"
+}
+
+domain_allowed(domain) if {
+	endswith(domain, ".pypi.org")
+}
+
+domain_denied(domain) if {
+	domain == "evil.com"
+}
+
+domain_denied(domain) if {
+	endswith(domain, ".pastebin.com")
+}
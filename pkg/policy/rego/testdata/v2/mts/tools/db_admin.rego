@@ -0,0 +1,12 @@
+# Auto-generated from AgentPolicy CRD: tenant-policy (template v2)
+# Do not edit directly - changes will be overwritten
+package agentpolicy.tools.db_admin
+
+import future.keywords.if
+import future.keywords.in
+
+# Tool: db.admin
+listed := true
+
+# Explicitly denied
+deny := true
@@ -0,0 +1,253 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// maxDivergentPaths caps the paths listed per tool in a comparison.
+const maxDivergentPaths = 20
+
+// Comparison aggregates the calls on which two versions of a policy
+// decide differently, for review before the candidate is promoted. It
+// marshals to JSON as the report artifact.
+type Comparison struct {
+	// Baseline and Candidate are the names of the compared versions
+	Baseline  string `json:"baseline"`
+	Candidate string `json:"candidate"`
+
+	// Since and Until are the timestamps of the first and last event compared
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+
+	// Total is the number of events read
+	Total int `json:"total"`
+
+	// Evaluated is the number of events governed by the baseline
+	Evaluated int `json:"evaluated"`
+
+	// Diverged is the number of evaluated events the versions decide
+	// differently: NewlyDenied are allowed by the baseline only, and
+	// NewlyAllowed by the candidate only
+	Diverged     int `json:"diverged"`
+	NewlyDenied  int `json:"newlyDenied"`
+	NewlyAllowed int `json:"newlyAllowed"`
+
+	// Tools aggregates divergences per agent type and tool, sorted by count
+	Tools []ToolDivergence `json:"tools,omitempty"`
+
+	// Tenants aggregates divergences per tenant, sorted by count
+	Tenants []TenantDivergence `json:"tenants,omitempty"`
+
+	// Timeline counts calls and divergences per interval, in time order
+	Timeline []Interval `json:"timeline,omitempty"`
+}
+
+// ToolDivergence aggregates the calls to one tool the versions decide
+// differently.
+type ToolDivergence struct {
+	AgentType    string `json:"agentType"`
+	Tool         string `json:"tool"`
+	Calls        int    `json:"calls"`
+	NewlyDenied  int    `json:"newlyDenied"`
+	NewlyAllowed int    `json:"newlyAllowed"`
+
+	// Paths are the recorded path parameters of divergent calls, sorted
+	Paths []string `json:"paths,omitempty"`
+
+	// FirstSeen and LastSeen bound the divergent calls
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// TenantDivergence counts a tenant's calls the versions decide differently.
+type TenantDivergence struct {
+	TenantID string `json:"tenantID"`
+	Calls    int    `json:"calls"`
+	Diverged int    `json:"diverged"`
+}
+
+// Interval counts the calls of one interval of a comparison's timeline.
+type Interval struct {
+	Start    time.Time `json:"start"`
+	Calls    int       `json:"calls"`
+	Diverged int       `json:"diverged"`
+}
+
+// Summary returns a one-line description of the divergence.
+func (c *Comparison) Summary() string {
+	return fmt.Sprintf("%d of %d calls diverge: %d newly denied, %d newly allowed by %s",
+		c.Diverged, c.Evaluated, c.NewlyDenied, c.NewlyAllowed, c.Candidate)
+}
+
+// String formats the comparison with a breakdown by tool and tenant.
+func (c *Comparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s vs %s: %s\n", c.Baseline, c.Candidate, c.Summary())
+	for _, t := range c.Tools {
+		fmt.Fprintf(&b, "  %s %s: %d calls, %d newly denied, %d newly allowed\n",
+			t.AgentType, t.Tool, t.Calls, t.NewlyDenied, t.NewlyAllowed)
+		if len(t.Paths) > 0 {
+			fmt.Fprintf(&b, "    paths: %s\n", strings.Join(t.Paths, ", "))
+		}
+	}
+	for _, t := range c.Tenants {
+		if t.Diverged > 0 {
+			fmt.Fprintf(&b, "  tenant %s: %d of %d calls diverge\n", t.TenantID, t.Diverged, t.Calls)
+		}
+	}
+	return b.String()
+}
+
+// Compare evaluates events against two versions of a policy and
+// aggregates the calls they decide differently, by tool, path, tenant,
+// and interval of the given length (0 omits the timeline).
+//
+// Both versions are loaded into isolated enforcing engines under the
+// baseline's agent types, so a canary without agent types of its own is
+// compared on the traffic of the policy it replaces. Events for agents the
+// baseline does not govern are not evaluated. As with Run, parameters are
+// not re-checked; recorded paths are only reported.
+func Compare(ctx context.Context, events []policy.AuditEvent, baseline, candidate *policy.CompiledPolicy, interval time.Duration) (*Comparison, error) {
+	if baseline == nil || candidate == nil {
+		return nil, fmt.Errorf("comparison requires two policies")
+	}
+
+	newEngine := func(p *policy.CompiledPolicy) *policy.Engine {
+		engine := policy.NewEngine(policy.WithMode(policy.Enforcing), policy.WithOPA(p.OPAEnabled))
+		for _, agentType := range baseline.AgentTypes {
+			engine.LoadPolicy(agentType, p)
+		}
+		return engine
+	}
+	baseEngine, candEngine := newEngine(baseline), newEngine(candidate)
+
+	c := &Comparison{Baseline: baseline.Name, Candidate: candidate.Name}
+	tools := make(map[string]*ToolDivergence)
+	paths := make(map[string]map[string]bool)
+	tenants := make(map[string]*TenantDivergence)
+	timeline := make(map[time.Time]*Interval)
+
+	for _, event := range events {
+		c.Total++
+		if resolved, ok := baseEngine.ResolvePolicy(event.Agent); !ok || resolved != baseline {
+			continue
+		}
+		c.Evaluated++
+		if c.Since.IsZero() || event.Timestamp.Before(c.Since) {
+			c.Since = event.Timestamp
+		}
+		if event.Timestamp.After(c.Until) {
+			c.Until = event.Timestamp
+		}
+
+		before, err := baseEngine.Evaluate(ctx, event.Agent, event.Tool, nil)
+		if err != nil {
+			return nil, fmt.Errorf("evaluating %s %s: %w", event.Agent.AgentType, event.Tool, err)
+		}
+		after, err := candEngine.Evaluate(ctx, event.Agent, event.Tool, nil)
+		if err != nil {
+			return nil, fmt.Errorf("evaluating %s %s: %w", event.Agent.AgentType, event.Tool, err)
+		}
+		diverged := before != after
+
+		var slot *Interval
+		if interval > 0 {
+			start := event.Timestamp.Truncate(interval)
+			if slot = timeline[start]; slot == nil {
+				slot = &Interval{Start: start}
+				timeline[start] = slot
+			}
+			slot.Calls++
+		}
+		tenant := tenants[event.Agent.TenantID]
+		if tenant == nil {
+			tenant = &TenantDivergence{TenantID: event.Agent.TenantID}
+			tenants[event.Agent.TenantID] = tenant
+		}
+		tenant.Calls++
+
+		key := event.Agent.AgentType + "\x00" + event.Tool
+		tool := tools[key]
+		if tool == nil {
+			tool = &ToolDivergence{AgentType: event.Agent.AgentType, Tool: event.Tool}
+			tools[key] = tool
+		}
+		tool.Calls++
+
+		if !diverged {
+			continue
+		}
+		c.Diverged++
+		tenant.Diverged++
+		if slot != nil {
+			slot.Diverged++
+		}
+		if after == policy.Deny {
+			c.NewlyDenied++
+			tool.NewlyDenied++
+		} else {
+			c.NewlyAllowed++
+			tool.NewlyAllowed++
+		}
+		if tool.FirstSeen.IsZero() || event.Timestamp.Before(tool.FirstSeen) {
+			tool.FirstSeen = event.Timestamp
+		}
+		if event.Timestamp.After(tool.LastSeen) {
+			tool.LastSeen = event.Timestamp
+		}
+		if p, ok := event.Parameters["path"].(string); ok && p != "" {
+			if paths[key] == nil {
+				paths[key] = make(map[string]bool)
+			}
+			paths[key][p] = true
+		}
+	}
+
+	for key, tool := range tools {
+		if tool.NewlyDenied+tool.NewlyAllowed == 0 {
+			continue
+		}
+		for p := range paths[key] {
+			tool.Paths = append(tool.Paths, p)
+		}
+		sort.Strings(tool.Paths)
+		if len(tool.Paths) > maxDivergentPaths {
+			tool.Paths = tool.Paths[:maxDivergentPaths]
+		}
+		c.Tools = append(c.Tools, *tool)
+	}
+	sort.Slice(c.Tools, func(i, j int) bool {
+		a, b := c.Tools[i], c.Tools[j]
+		if ca, cb := a.NewlyDenied+a.NewlyAllowed, b.NewlyDenied+b.NewlyAllowed; ca != cb {
+			return ca > cb
+		}
+		if a.AgentType != b.AgentType {
+			return a.AgentType < b.AgentType
+		}
+		return a.Tool < b.Tool
+	})
+
+	for _, tenant := range tenants {
+		c.Tenants = append(c.Tenants, *tenant)
+	}
+	sort.Slice(c.Tenants, func(i, j int) bool {
+		a, b := c.Tenants[i], c.Tenants[j]
+		if a.Diverged != b.Diverged {
+			return a.Diverged > b.Diverged
+		}
+		return a.TenantID < b.TenantID
+	})
+
+	for _, slot := range timeline {
+		c.Timeline = append(c.Timeline, *slot)
+	}
+	sort.Slice(c.Timeline, func(i, j int) bool { return c.Timeline[i].Start.Before(c.Timeline[j].Start) })
+
+	return c, nil
+}
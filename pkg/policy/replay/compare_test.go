@@ -0,0 +1,78 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestCompare tests that divergences are aggregated by tool, path, tenant,
+// and interval.
+func TestCompare(t *testing.T) {
+	baseline := policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.read", Action: policy.Allow},
+			{Tool: "file.write", Action: policy.Allow},
+		}, policy.Enforcing, "")
+	// A canary has no agent types of its own
+	candidate := policy.CompilePolicy("coding-policy-v2", nil, policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.read", Action: policy.Allow},
+			{Tool: "network.fetch", Action: policy.Allow},
+		}, policy.Enforcing, "")
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	event := func(minutes int, tenant, tool, path string) policy.AuditEvent {
+		e := policy.AuditEvent{
+			Timestamp: start.Add(time.Duration(minutes) * time.Minute),
+			Agent:     policy.AgentContext{AgentType: "coding-assistant", TenantID: tenant},
+			Tool:      tool,
+		}
+		if path != "" {
+			e.Parameters = map[string]interface{}{"path": path}
+		}
+		return e
+	}
+	events := []policy.AuditEvent{
+		event(0, "team-a", "file.read", "/src/a.go"),
+		event(10, "team-a", "file.write", "/src/a.go"),
+		event(70, "team-b", "file.write", "/src/b.go"),
+		event(80, "team-b", "network.fetch", ""),
+		{Timestamp: start, Agent: policy.AgentContext{AgentType: "research-agent"}, Tool: "file.write"},
+	}
+
+	c, err := Compare(context.Background(), events, baseline, candidate, time.Hour)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if c.Total != 5 || c.Evaluated != 4 || c.Diverged != 3 || c.NewlyDenied != 2 || c.NewlyAllowed != 1 {
+		t.Errorf("unexpected counts: %+v", c)
+	}
+	if len(c.Tools) != 2 || c.Tools[0].Tool != "file.write" || c.Tools[0].NewlyDenied != 2 {
+		t.Fatalf("unexpected tools: %+v", c.Tools)
+	}
+	if got := c.Tools[0].Paths; len(got) != 2 || got[0] != "/src/a.go" || got[1] != "/src/b.go" {
+		t.Errorf("unexpected paths: %v", got)
+	}
+	if !c.Tools[0].FirstSeen.Equal(start.Add(10*time.Minute)) || !c.Tools[0].LastSeen.Equal(start.Add(70*time.Minute)) {
+		t.Errorf("unexpected first and last seen: %v %v", c.Tools[0].FirstSeen, c.Tools[0].LastSeen)
+	}
+	if len(c.Tenants) != 2 || c.Tenants[0].TenantID != "team-b" || c.Tenants[0].Diverged != 2 || c.Tenants[1].Diverged != 1 {
+		t.Errorf("unexpected tenants: %+v", c.Tenants)
+	}
+	if len(c.Timeline) != 2 || c.Timeline[0].Calls != 2 || c.Timeline[0].Diverged != 1 || c.Timeline[1].Diverged != 2 {
+		t.Errorf("unexpected timeline: %+v", c.Timeline)
+	}
+
+	var decoded Comparison
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Diverged != 3 {
+		t.Errorf("expected the report to round-trip through JSON, got %+v (%v)", decoded, err)
+	}
+}
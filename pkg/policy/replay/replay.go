@@ -0,0 +1,376 @@
+// Package replay evaluates recorded audit traffic against a proposed policy.
+//
+// Before a policy change is activated, operators want to know its impact on
+// real traffic: which calls that were allowed would now be denied, and
+// which denied calls would now succeed. The replay subsystem reads JSON audit
+// logs (as written by JSONAuditSink or FileAuditSink in "json" format),
+// re-evaluates every call against the proposed policy in an isolated engine,
+// and reports the decisions that would flip.
+//
+// Audit events record the agent and tool but not the tool parameters, so the
+// replay is at tool granularity: parameter constraints (paths, domains,
+// sizes) are not re-checked, while RequiredAgentLabels and agent selectors
+// are, since agent labels are part of the event.
+//
+// Usage:
+//
+//	events, stats, err := replay.ReadEvents(f, replay.Window{Since: time.Now().Add(-24 * time.Hour)})
+//	report, err := replay.Run(ctx, events, proposed)
+//	fmt.Print(report)
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// Window restricts replay to events recorded in [Since, Until).
+// A zero bound is open.
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Contains reports whether t falls within the window.
+func (w Window) Contains(t time.Time) bool {
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	if !w.Until.IsZero() && !t.Before(w.Until) {
+		return false
+	}
+	return true
+}
+
+// ReadStats counts the lines seen while reading an audit log.
+type ReadStats struct {
+	// Lines is the number of non-empty lines read
+	Lines int
+
+	// Malformed is the number of lines that were not valid JSON audit events
+	Malformed int
+
+	// OutsideWindow is the number of events outside the requested window
+	OutsideWindow int
+
+	// Executions is the number of execution events, which record the
+	// outcome of decided calls and are not replayed
+	Executions int
+}
+
+// ReadEvents parses JSON audit lines from r, keeping events inside the window.
+// Lines may be JSONAuditEvents or CloudEvents of policy decisions.
+// Lines that are not JSON audit events (e.g., AVC-format lines mixed into the
+// same file) are counted as malformed and skipped. Execution events are
+// counted and skipped, and the restart markers of chained logs skipped.
+func ReadEvents(r io.Reader, window Window) ([]policy.AuditEvent, ReadStats, error) {
+	var events []policy.AuditEvent
+	var stats ReadStats
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		stats.Lines++
+
+		if isExecutionEvent([]byte(line)) {
+			stats.Executions++
+			continue
+		}
+		if policy.IsAuditRestartMarker([]byte(line)) {
+			continue
+		}
+		event, ok := parseEvent([]byte(line))
+		if !ok {
+			stats.Malformed++
+			continue
+		}
+		if !window.Contains(event.Timestamp) {
+			stats.OutsideWindow++
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return events, stats, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, stats, nil
+}
+
+// isExecutionEvent reports whether a JSON audit line, or a CloudEvents
+// line, is an execution event rather than a policy decision.
+func isExecutionEvent(line []byte) bool {
+	var event struct {
+		SpecVersion string `json:"specversion"`
+		Type        string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return false
+	}
+	if event.SpecVersion != "" {
+		return event.Type == policy.CloudEventExecutionType
+	}
+	return event.Type == "EXECUTION"
+}
+
+// parseEvent converts a JSON audit line, or a CloudEvents line of a
+// policy decision, back into an AuditEvent.
+func parseEvent(line []byte) (policy.AuditEvent, bool) {
+	var ce struct {
+		SpecVersion string          `json:"specversion"`
+		Type        string          `json:"type"`
+		Data        json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(line, &ce); err != nil {
+		return policy.AuditEvent{}, false
+	}
+	if ce.SpecVersion != "" {
+		if ce.Type != policy.CloudEventType {
+			return policy.AuditEvent{}, false
+		}
+		line = ce.Data
+	}
+
+	var je policy.JSONAuditEvent
+	if err := json.Unmarshal(line, &je); err != nil {
+		return policy.AuditEvent{}, false
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, je.Timestamp)
+	if err != nil || je.Tool == "" || je.Agent.Type == "" {
+		return policy.AuditEvent{}, false
+	}
+
+	var decision policy.Decision
+	switch je.Decision {
+	case policy.Allow.String():
+		decision = policy.Allow
+	case policy.Deny.String():
+		decision = policy.Deny
+	default:
+		return policy.AuditEvent{}, false
+	}
+
+	// Events recorded before the mode was audited read as permissive
+	mode := policy.Permissive
+	if je.Mode == policy.Enforcing.String() {
+		mode = policy.Enforcing
+	}
+
+	// and those recorded before the enforced decision was, as their mode
+	// enforced them
+	enforced := decision
+	switch je.EnforcedDecision {
+	case policy.Allow.String():
+		enforced = policy.Allow
+	case policy.Deny.String():
+		enforced = policy.Deny
+	default:
+		if mode == policy.Permissive {
+			enforced = policy.Allow
+		}
+	}
+
+	return policy.AuditEvent{
+		Timestamp: ts,
+		Agent: policy.AgentContext{
+			AgentType: je.Agent.Type,
+			SandboxID: je.Agent.SandboxID,
+			TenantID:  je.Agent.TenantID,
+			SessionID: je.Agent.SessionID,
+			MTSLabel:  je.Agent.MTSLabel,
+			PolicyRef: je.Agent.PolicyRef,
+			Labels:    je.Agent.Labels,
+		},
+		Tool:       je.Tool,
+		RawTool:    je.RawTool,
+		RiskLevel:  policy.RiskLevel(je.RiskLevel),
+		Decision:   decision,
+		Mode:       mode,
+		Reason:     je.Reason,
+		RequestID:  je.RequestID,
+		Cached:     je.Cached,
+		Combining:  policy.CombiningAlgorithm(je.Combining),
+		Parameters: je.Parameters,
+		Repeated:   je.Repeated,
+		Source:     je.Source,
+
+		EnforcedDecision: enforced,
+	}, true
+}
+
+// Flip is a recorded call whose decision changes under the proposed policy.
+type Flip struct {
+	// Event is the recorded audit event
+	Event policy.AuditEvent
+
+	// Decision is the decision under the proposed policy
+	Decision policy.Decision
+
+	// Reason explains the new decision
+	Reason string
+}
+
+// ToolImpact aggregates replay results for one agent type and tool.
+type ToolImpact struct {
+	AgentType    string
+	Tool         string
+	Calls        int
+	NewlyDenied  int
+	NewlyAllowed int
+}
+
+// Report summarizes the impact of a proposed policy on recorded traffic.
+type Report struct {
+	// Policy is the name of the proposed policy
+	Policy string
+
+	// Total is the number of events replayed
+	Total int
+
+	// Evaluated is the number of events governed by the proposed policy
+	Evaluated int
+
+	// Skipped is the number of events for agents the policy does not govern
+	Skipped int
+
+	// Unchanged is the number of evaluated events with the same decision
+	Unchanged int
+
+	// NewlyDenied counts previously allowed calls the policy would deny
+	NewlyDenied int
+
+	// NewlyAllowed counts previously denied calls the policy would allow
+	NewlyAllowed int
+
+	// Flips lists every changed decision in log order
+	Flips []Flip
+
+	// Tools aggregates results per agent type and tool, sorted by impact
+	Tools []ToolImpact
+}
+
+// Summary returns a one-line description of the impact.
+func (r *Report) Summary() string {
+	return fmt.Sprintf("%d of %d replayed calls would change: %d newly denied, %d newly allowed",
+		r.NewlyDenied+r.NewlyAllowed, r.Evaluated, r.NewlyDenied, r.NewlyAllowed)
+}
+
+// String formats the report with a per-tool breakdown of changed decisions.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "policy %s: %s\n", r.Policy, r.Summary())
+	if r.Skipped > 0 {
+		fmt.Fprintf(&b, "(%d calls skipped: agent not governed by this policy)\n", r.Skipped)
+	}
+
+	for _, t := range r.Tools {
+		if t.NewlyDenied == 0 && t.NewlyAllowed == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s %s: %d calls, %d newly denied, %d newly allowed\n",
+			t.AgentType, t.Tool, t.Calls, t.NewlyDenied, t.NewlyAllowed)
+	}
+	return b.String()
+}
+
+// Run replays events against the proposed policy.
+//
+// The policy is loaded into an isolated enforcing engine under each of its
+// agent types, so only events for agents the policy would govern (including
+// its AgentSelector) are evaluated. Recorded decisions are raw policy
+// decisions, before permissive mode is applied, and are compared as such.
+func Run(ctx context.Context, events []policy.AuditEvent, proposed *policy.CompiledPolicy) (*Report, error) {
+	if proposed == nil {
+		return nil, fmt.Errorf("no proposed policy")
+	}
+
+	sink := &lastEventSink{}
+	engine := policy.NewEngine(
+		policy.WithMode(policy.Enforcing),
+		policy.WithAuditSink(sink),
+		policy.WithOPA(proposed.OPAEnabled),
+	)
+	for _, agentType := range proposed.AgentTypes {
+		engine.LoadPolicy(agentType, proposed)
+	}
+
+	report := &Report{Policy: proposed.Name}
+	impacts := make(map[string]*ToolImpact)
+
+	for _, event := range events {
+		report.Total++
+
+		if resolved, ok := engine.ResolvePolicy(event.Agent); !ok || resolved != proposed {
+			report.Skipped++
+			continue
+		}
+		report.Evaluated++
+
+		decision, err := engine.Evaluate(ctx, event.Agent, event.Tool, nil)
+		if err != nil {
+			return nil, fmt.Errorf("replaying %s %s: %w", event.Agent.AgentType, event.Tool, err)
+		}
+
+		key := event.Agent.AgentType + "\x00" + event.Tool
+		impact, ok := impacts[key]
+		if !ok {
+			impact = &ToolImpact{AgentType: event.Agent.AgentType, Tool: event.Tool}
+			impacts[key] = impact
+		}
+		impact.Calls++
+
+		if decision == event.Decision {
+			report.Unchanged++
+			continue
+		}
+
+		if decision == policy.Deny {
+			report.NewlyDenied++
+			impact.NewlyDenied++
+		} else {
+			report.NewlyAllowed++
+			impact.NewlyAllowed++
+		}
+		report.Flips = append(report.Flips, Flip{Event: event, Decision: decision, Reason: sink.reason})
+	}
+
+	for _, impact := range impacts {
+		report.Tools = append(report.Tools, *impact)
+	}
+	sort.Slice(report.Tools, func(i, j int) bool {
+		a, b := report.Tools[i], report.Tools[j]
+		if ca, cb := a.NewlyDenied+a.NewlyAllowed, b.NewlyDenied+b.NewlyAllowed; ca != cb {
+			return ca > cb
+		}
+		if a.AgentType != b.AgentType {
+			return a.AgentType < b.AgentType
+		}
+		return a.Tool < b.Tool
+	})
+
+	return report, nil
+}
+
+// lastEventSink remembers the reason of the most recent decision so replay
+// can report why a decision flipped. Replay evaluates sequentially.
+type lastEventSink struct {
+	reason string
+}
+
+func (s *lastEventSink) Log(event *policy.AuditEvent) {
+	s.reason = event.Reason
+}
@@ -0,0 +1,173 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// recordTraffic evaluates calls against a policy with a JSON audit sink
+// and returns the resulting audit log.
+func recordTraffic(t *testing.T, current *policy.CompiledPolicy, calls []policy.AgentContext, tools []string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	engine := policy.NewEngine(
+		policy.WithMode(policy.Enforcing),
+		policy.WithAuditSink(policy.NewJSONAuditSink(&buf, false)),
+	)
+	for _, agentType := range current.AgentTypes {
+		engine.LoadPolicy(agentType, current)
+	}
+
+	for _, agent := range calls {
+		for _, tool := range tools {
+			if _, err := engine.Evaluate(context.Background(), agent, tool, nil); err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// TestReplayImpact tests that flipped decisions are counted per direction.
+func TestReplayImpact(t *testing.T) {
+	current := policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.read", Action: policy.Allow},
+			{Tool: "file.write", Action: policy.Allow},
+		}, policy.Enforcing, "")
+
+	agents := []policy.AgentContext{
+		{AgentType: "coding-assistant", SessionID: "s1"},
+		{AgentType: "research-agent", SessionID: "s2"},
+	}
+	log := recordTraffic(t, current, agents, []string{"file.read", "file.write", "network.fetch"})
+
+	events, stats, err := ReadEvents(strings.NewReader(log), Window{})
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if len(events) != 6 || stats.Malformed != 0 {
+		t.Fatalf("expected 6 events, got %d (stats %+v)", len(events), stats)
+	}
+
+	// Proposed: stop allowing writes, start allowing fetches
+	proposed := policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.read", Action: policy.Allow},
+			{Tool: "network.fetch", Action: policy.Allow},
+		}, policy.Enforcing, "")
+
+	report, err := Run(context.Background(), events, proposed)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if report.Total != 6 || report.Evaluated != 3 || report.Skipped != 3 {
+		t.Errorf("unexpected counts: total=%d evaluated=%d skipped=%d", report.Total, report.Evaluated, report.Skipped)
+	}
+	if report.NewlyDenied != 1 || report.NewlyAllowed != 1 || report.Unchanged != 1 {
+		t.Errorf("unexpected impact: %s", report.Summary())
+	}
+	if len(report.Flips) != 2 || report.Flips[0].Event.Tool != "file.write" || report.Flips[0].Decision != policy.Deny {
+		t.Errorf("unexpected flips: %+v", report.Flips)
+	}
+}
+
+// TestReplayLabels tests that agent labels from the log are replayed.
+func TestReplayLabels(t *testing.T) {
+	current := policy.CompilePolicy("deploy-policy", []string{"deployer"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "deploy.run", Action: policy.Allow}}, policy.Enforcing, "")
+
+	agents := []policy.AgentContext{
+		{AgentType: "deployer", Labels: map[string]string{"env": "prod"}},
+		{AgentType: "deployer", Labels: map[string]string{"env": "dev"}},
+	}
+	log := recordTraffic(t, current, agents, []string{"deploy.run"})
+
+	events, _, err := ReadEvents(strings.NewReader(log), Window{})
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+
+	proposed := policy.CompilePolicy("deploy-policy", []string{"deployer"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "deploy.run", Action: policy.Allow, Constraints: &policy.ToolConstraints{
+			RequiredAgentLabels: map[string]string{"env": "prod"},
+		}}}, policy.Enforcing, "")
+
+	report, err := Run(context.Background(), events, proposed)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.NewlyDenied != 1 || report.Flips[0].Event.Agent.Labels["env"] != "dev" {
+		t.Errorf("expected only the dev agent to be newly denied: %s", report.Summary())
+	}
+}
+
+// TestReadEventsWindow tests time filtering and malformed line handling.
+func TestReadEventsWindow(t *testing.T) {
+	log := strings.Join([]string{
+		`{"type":"AVC","timestamp":"2024-01-01T10:00:00Z","decision":"ALLOW","tool":"file.read","agent":{"type":"a"}}`,
+		`{"type":"AVC","timestamp":"2024-01-02T10:00:00Z","decision":"DENY","tool":"file.write","agent":{"type":"a"}}`,
+		`type=AVC msg=audit(1704103200.000:1): avc: denied { file.write } for agent_type=a`,
+		``,
+		`{"type":"AVC","timestamp":"2024-01-03T10:00:00Z","decision":"ALLOW","tool":"file.read","agent":{"type":"a"}}`,
+	}, "\n")
+
+	window := Window{
+		Since: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	events, stats, err := ReadEvents(strings.NewReader(log), window)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Tool != "file.write" || events[0].Decision != policy.Deny {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if stats.Lines != 4 || stats.Malformed != 1 || stats.OutsideWindow != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+// TestReadEventsCloudEvents tests that CloudEvents audit lines are read
+// like JSON audit lines, and execution and other CloudEvents are skipped.
+func TestReadEventsCloudEvents(t *testing.T) {
+	var buf bytes.Buffer
+	sink := policy.NewJSONAuditSink(&buf, false)
+	sink.Format = policy.AuditFormatCloudEvents
+	sink.Log(&policy.AuditEvent{
+		Timestamp: time.Now(),
+		Agent:     policy.AgentContext{AgentType: "coding-assistant"},
+		Tool:      "file.write",
+		Decision:  policy.Deny,
+		RequestID: "req-1",
+	})
+	sink.Log(&policy.AuditEvent{
+		Timestamp: time.Now(),
+		Agent:     policy.AgentContext{AgentType: "coding-assistant"},
+		Tool:      "file.read",
+		Decision:  policy.Allow,
+		RequestID: "req-2",
+		Execution: &policy.ExecutionRecord{Status: policy.ExecutionSucceeded, Attempts: 1},
+	})
+	buf.WriteString(`{"specversion":"1.0","type":"com.example.other","data":{"timestamp":"2024-01-01T10:00:00Z","tool":"x","agent":{"type":"a"}}}` + "\n")
+	buf.WriteString(`{"type":"AUDIT_CHAIN_RESTART","timestamp":"2024-01-01T10:00:00Z","after":"e3b0c4"}` + "\n")
+
+	events, stats, err := ReadEvents(&buf, Window{})
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Tool != "file.write" || events[0].Decision != policy.Deny || events[0].RequestID != "req-1" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if stats.Malformed != 1 || stats.Executions != 1 {
+		t.Errorf("expected the execution, foreign CloudEvents, and restart marker to be skipped, got %+v", stats)
+	}
+}
@@ -0,0 +1,300 @@
+package policy
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LabelSelector restricts a policy to agents carrying matching labels.
+// It mirrors the Kubernetes metav1.LabelSelector semantics without
+// depending on the Kubernetes API packages.
+type LabelSelector struct {
+	// MatchLabels requires each key to be present with the given value
+	MatchLabels map[string]string
+
+	// MatchExpressions are set-based requirements (In, NotIn, Exists, DoesNotExist)
+	MatchExpressions []LabelSelectorRequirement
+}
+
+// LabelSelectorRequirement is a set-based label requirement.
+type LabelSelectorRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// Label selector operators
+const (
+	SelectorOpIn           = "In"
+	SelectorOpNotIn        = "NotIn"
+	SelectorOpExists       = "Exists"
+	SelectorOpDoesNotExist = "DoesNotExist"
+)
+
+// Matches reports whether the labels satisfy the selector.
+// A nil selector matches all labels; an unknown operator never matches.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+
+	for k, v := range s.MatchLabels {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+
+	for _, req := range s.MatchExpressions {
+		value, exists := labels[req.Key]
+		switch req.Operator {
+		case SelectorOpIn:
+			if !exists || !containsString(req.Values, value) {
+				return false
+			}
+		case SelectorOpNotIn:
+			if exists && containsString(req.Values, value) {
+				return false
+			}
+		case SelectorOpExists:
+			if !exists {
+				return false
+			}
+		case SelectorOpDoesNotExist:
+			if exists {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// size returns the number of requirements in the selector (for specificity).
+func (s *LabelSelector) size() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.MatchLabels) + len(s.MatchExpressions)
+}
+
+// PolicyResolver selects the policy that applies to an agent.
+//
+// Policies are bound to keys, which are either exact agent types
+// ("coding-assistant"), glob patterns ("coding-*"), or the fallback key
+// ("*"). A policy with an AgentSelector only applies to agents whose labels
+// match the selector.
+//
+// When several bindings match, the most specific one wins:
+//  1. Exact agent type over any pattern
+//  2. Patterns with more literal characters over broader patterns
+//  3. Policies with more selector requirements over fewer
+//  4. Lexicographic order of the binding key (deterministic tie-break)
+//
+// The fallback key "*" has no literal characters, so it is always the
+// last candidate.
+type PolicyResolver struct {
+	mu       sync.RWMutex
+	policies map[string]*CompiledPolicy // binding key -> policy
+}
+
+// NewPolicyResolver creates an empty resolver.
+func NewPolicyResolver() *PolicyResolver {
+	return &PolicyResolver{
+		policies: make(map[string]*CompiledPolicy),
+	}
+}
+
+// Set binds a policy to a key, replacing any existing binding.
+func (r *PolicyResolver) Set(key string, policy *CompiledPolicy) {
+	r.mu.Lock()
+	r.policies[key] = policy
+	r.mu.Unlock()
+}
+
+// Delete removes the binding for a key.
+func (r *PolicyResolver) Delete(key string) {
+	r.mu.Lock()
+	delete(r.policies, key)
+	r.mu.Unlock()
+}
+
+// DeleteUID removes the binding for a key if it is to the policy compiled
+// from the resource with uid, and reports whether it did.
+func (r *PolicyResolver) DeleteUID(key, uid string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if policy, ok := r.policies[key]; !ok || uid == "" || policy.UID != uid {
+		return false
+	}
+	delete(r.policies, key)
+	return true
+}
+
+// Get returns the policy bound to exactly this key.
+func (r *PolicyResolver) Get(key string) (*CompiledPolicy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	policy, ok := r.policies[key]
+	return policy, ok
+}
+
+// Keys returns all binding keys.
+func (r *PolicyResolver) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]string, 0, len(r.policies))
+	for k := range r.policies {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Resolve returns the most specific policy matching the agent's type and labels.
+func (r *PolicyResolver) Resolve(agent AgentContext) (*CompiledPolicy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Fast path: exact binding with no selector or a matching selector
+	if policy, ok := r.policies[agent.AgentType]; ok && policy.AgentSelector.Matches(agent.Labels) {
+		return policy, true
+	}
+
+	var candidates []resolverCandidate
+	for key, policy := range r.policies {
+		if !IsAgentTypePattern(key) {
+			continue
+		}
+		if match, _ := path.Match(key, agent.AgentType); !match {
+			continue
+		}
+		if !policy.AgentSelector.Matches(agent.Labels) {
+			continue
+		}
+		candidates = append(candidates, resolverCandidate{key: key, policy: policy})
+	}
+
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].moreSpecific(candidates[j])
+	})
+	return candidates[0].policy, true
+}
+
+// Matching returns the policies of every binding matching the agent's
+// type and labels, most specific first, as Resolve ranks them; a policy
+// bound under several matching keys is returned once. The fallback policy
+// is only returned if no other binding matches.
+func (r *PolicyResolver) Matching(agent AgentContext) []*CompiledPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var candidates []resolverCandidate
+	for key, policy := range r.policies {
+		if key == agent.AgentType {
+			if policy.AgentSelector.Matches(agent.Labels) {
+				candidates = append(candidates, resolverCandidate{key: key, policy: policy, exact: true})
+			}
+			continue
+		}
+		if !IsAgentTypePattern(key) {
+			continue
+		}
+		if match, _ := path.Match(key, agent.AgentType); !match {
+			continue
+		}
+		if !policy.AgentSelector.Matches(agent.Labels) {
+			continue
+		}
+		candidates = append(candidates, resolverCandidate{key: key, policy: policy})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].moreSpecific(candidates[j])
+	})
+
+	policies := make([]*CompiledPolicy, 0, len(candidates))
+	for _, c := range candidates {
+		if c.key == FallbackAgentType && len(policies) > 0 {
+			continue
+		}
+		if !containsPolicy(policies, c.policy) {
+			policies = append(policies, c.policy)
+		}
+	}
+	return policies
+}
+
+// containsPolicy reports whether policies contains policy.
+func containsPolicy(policies []*CompiledPolicy, policy *CompiledPolicy) bool {
+	for _, p := range policies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// resolverCandidate is a binding that matched an agent.
+type resolverCandidate struct {
+	key    string
+	policy *CompiledPolicy
+
+	// exact is set for the binding of the agent's exact type (Matching)
+	exact bool
+}
+
+// moreSpecific orders candidates by the resolver's specificity rules.
+func (c resolverCandidate) moreSpecific(other resolverCandidate) bool {
+	if c.exact != other.exact {
+		return c.exact
+	}
+	if a, b := literalLength(c.key), literalLength(other.key); a != b {
+		return a > b
+	}
+	if a, b := c.policy.AgentSelector.size(), other.policy.AgentSelector.size(); a != b {
+		return a > b
+	}
+	return c.key < other.key
+}
+
+// IsAgentTypePattern reports whether an agent type key contains glob
+// metacharacters (including the fallback key "*").
+func IsAgentTypePattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// literalLength counts the non-wildcard characters in a pattern.
+func literalLength(pattern string) int {
+	n := 0
+	inClass := false
+	for _, r := range pattern {
+		switch {
+		case r == '[':
+			inClass = true
+		case r == ']':
+			inClass = false
+		case r == '*' || r == '?' || inClass:
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// containsString checks if a string slice contains a value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestResolverSpecificity verifies exact > pattern > fallback ordering
+func TestResolverSpecificity(t *testing.T) {
+	resolver := NewPolicyResolver()
+
+	exact := &CompiledPolicy{Name: "exact"}
+	narrow := &CompiledPolicy{Name: "narrow"}
+	broad := &CompiledPolicy{Name: "broad"}
+	fallback := &CompiledPolicy{Name: "fallback"}
+
+	resolver.Set("coding-assistant", exact)
+	resolver.Set("coding-review-*", narrow)
+	resolver.Set("coding-*", broad)
+	resolver.Set(FallbackAgentType, fallback)
+
+	tests := []struct {
+		agentType string
+		want      string
+	}{
+		{"coding-assistant", "exact"},
+		{"coding-review-bot", "narrow"},
+		{"coding-helper", "broad"},
+		{"research-agent", "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agentType, func(t *testing.T) {
+			policy, ok := resolver.Resolve(AgentContext{AgentType: tt.agentType})
+			if !ok {
+				t.Fatalf("expected a policy for %q", tt.agentType)
+			}
+			if policy.Name != tt.want {
+				t.Errorf("expected policy %q, got %q", tt.want, policy.Name)
+			}
+		})
+	}
+}
+
+// TestResolverLabelSelector verifies selector-based matching
+func TestResolverLabelSelector(t *testing.T) {
+	resolver := NewPolicyResolver()
+
+	prod := &CompiledPolicy{
+		Name: "prod",
+		AgentSelector: &LabelSelector{
+			MatchLabels: map[string]string{"environment": "production"},
+		},
+	}
+	team := &CompiledPolicy{
+		Name: "ml-team",
+		AgentSelector: &LabelSelector{
+			MatchExpressions: []LabelSelectorRequirement{
+				{Key: "team", Operator: SelectorOpIn, Values: []string{"ml", "research"}},
+			},
+		},
+	}
+
+	resolver.Set("coding-*", prod)
+	resolver.Set("*-assistant", team)
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+		found  bool
+	}{
+		{"production agent", map[string]string{"environment": "production"}, "prod", true},
+		{"ml team agent", map[string]string{"team": "ml"}, "ml-team", true},
+		{"both match uses deterministic order", map[string]string{"environment": "production", "team": "ml"}, "ml-team", true},
+		{"no labels", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := AgentContext{AgentType: "coding-assistant", Labels: tt.labels}
+			policy, ok := resolver.Resolve(agent)
+			if ok != tt.found {
+				t.Fatalf("expected found=%v, got %v", tt.found, ok)
+			}
+			if ok && policy.Name != tt.want {
+				t.Errorf("expected policy %q, got %q", tt.want, policy.Name)
+			}
+		})
+	}
+}
+
+// TestEngineLabelCacheIsolation verifies labeled agents don't share cached decisions
+func TestEngineLabelCacheIsolation(t *testing.T) {
+	engine := NewEngine(WithMode(Enforcing))
+
+	prod := CompilePolicy("prod", []string{"coding-*"}, Allow, nil, Enforcing, "")
+	prod.AgentSelector = &LabelSelector{MatchLabels: map[string]string{"environment": "production"}}
+	engine.LoadPolicy("coding-*", prod)
+
+	prodAgent := AgentContext{AgentType: "coding-assistant", Labels: map[string]string{"environment": "production"}}
+	devAgent := AgentContext{AgentType: "coding-assistant", Labels: map[string]string{"environment": "dev"}}
+
+	decision, _ := engine.Evaluate(context.Background(), prodAgent, "file.read", nil)
+	if decision != Allow {
+		t.Errorf("expected Allow for production agent, got %v", decision)
+	}
+
+	decision, _ = engine.Evaluate(context.Background(), devAgent, "file.read", nil)
+	if decision != Deny {
+		t.Errorf("expected Deny for dev agent, got %v", decision)
+	}
+}
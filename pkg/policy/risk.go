@@ -0,0 +1,201 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// riskRanks orders the risk levels; unrated tools rank 0.
+var riskRanks = map[RiskLevel]int{RiskLow: 1, RiskMedium: 2, RiskHigh: 3, RiskCritical: 4}
+
+// RiskRank orders risk levels from 1 (low) to 4 (critical), and is 0 for
+// unrated tools.
+func RiskRank(level RiskLevel) int {
+	return riskRanks[level]
+}
+
+// ParseRiskLevel parses a RiskLevel.
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	if _, ok := riskRanks[RiskLevel(s)]; !ok {
+		return "", fmt.Errorf("invalid risk level %q: must be low, medium, high, or critical", s)
+	}
+	return RiskLevel(s), nil
+}
+
+// RiskAction is what a RiskRule does to the calls it applies to.
+type RiskAction string
+
+const (
+	// RiskRequireApproval attaches an ObligationApproval to allowed calls,
+	// with Params "risk" (the tool's risk level) and "rule"
+	RiskRequireApproval RiskAction = "approval"
+
+	// RiskAuditParameters records the parameters of calls in their audit
+	// events, as WithAuditParameters does for every call
+	RiskAuditParameters RiskAction = "audit-parameters"
+)
+
+// RiskRule applies actions to the calls of every policy whose tool's risk
+// level compares to Level by Op, such as "tier>=high:approval". Rules are
+// set for the whole engine, so they control tools across policies without
+// editing each one. Unrated tools match no rule.
+type RiskRule struct {
+	// Op compares the tool's level to Level: >=, >, ==, <=, or <
+	Op string
+
+	// Level is the level compared to
+	Level RiskLevel
+
+	// Actions are applied to matching calls
+	Actions []RiskAction
+}
+
+// riskOps are the comparison operators of RiskRules, longest first so
+// that parsing finds ">=" before ">".
+var riskOps = []string{">=", "<=", "==", ">", "<"}
+
+// ParseRiskRule parses a rule of the form "tier<op><level>:<action>[+...]",
+// e.g. "tier>=high:approval" or "tier==critical:approval+audit-parameters".
+func ParseRiskRule(s string) (RiskRule, error) {
+	cond, actions, ok := strings.Cut(s, ":")
+	if !ok || actions == "" {
+		return RiskRule{}, fmt.Errorf("invalid risk rule %q: expected tier<op><level>:<action>", s)
+	}
+	cond, ok = strings.CutPrefix(strings.TrimSpace(cond), "tier")
+	if !ok {
+		return RiskRule{}, fmt.Errorf("invalid risk rule %q: condition must compare the tier", s)
+	}
+
+	var rule RiskRule
+	for _, op := range riskOps {
+		if level, ok := strings.CutPrefix(strings.TrimSpace(cond), op); ok {
+			rule.Op = op
+			var err error
+			if rule.Level, err = ParseRiskLevel(strings.TrimSpace(level)); err != nil {
+				return RiskRule{}, fmt.Errorf("invalid risk rule %q: %w", s, err)
+			}
+			break
+		}
+	}
+	if rule.Op == "" {
+		return RiskRule{}, fmt.Errorf("invalid risk rule %q: operator must be >=, >, ==, <=, or <", s)
+	}
+
+	for _, a := range strings.Split(actions, "+") {
+		switch action := RiskAction(strings.TrimSpace(a)); action {
+		case RiskRequireApproval, RiskAuditParameters:
+			rule.Actions = append(rule.Actions, action)
+		default:
+			return RiskRule{}, fmt.Errorf("invalid risk rule %q: action %q must be approval or audit-parameters", s, a)
+		}
+	}
+	return rule, nil
+}
+
+// String formats the rule as ParseRiskRule parses it.
+func (r RiskRule) String() string {
+	actions := make([]string, len(r.Actions))
+	for i, a := range r.Actions {
+		actions[i] = string(a)
+	}
+	return "tier" + r.Op + string(r.Level) + ":" + strings.Join(actions, "+")
+}
+
+// Matches reports whether the rule applies to tools of level.
+func (r RiskRule) Matches(level RiskLevel) bool {
+	rank, ok := riskRanks[level]
+	if !ok {
+		return false
+	}
+	than := riskRanks[r.Level]
+	switch r.Op {
+	case ">=":
+		return rank >= than
+	case ">":
+		return rank > than
+	case "==":
+		return rank == than
+	case "<=":
+		return rank <= than
+	case "<":
+		return rank < than
+	}
+	return false
+}
+
+// has reports whether the rule applies action.
+func (r RiskRule) has(action RiskAction) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// WithToolCatalog sets the catalog the risk levels of tools are taken
+// from, for risk rules.
+func WithToolCatalog(c *ToolCatalog) Option {
+	return func(e *Engine) {
+		e.catalog = c
+	}
+}
+
+// WithRiskRules sets the risk rules applied to the calls of every policy
+// (see RiskRule).
+func WithRiskRules(rules ...RiskRule) Option {
+	return func(e *Engine) {
+		e.riskRules = rules
+	}
+}
+
+// RiskLevel returns the risk level of calls to a tool under a policy (nil
+// for none): the higher of the level its rule in the policy sets and the
+// level of the engine's tool catalog, so that a policy may raise a tool's
+// level but not lower it below the catalog's. It is "" for unrated tools.
+func (e *Engine) RiskLevel(policy *CompiledPolicy, toolName string) RiskLevel {
+	var level RiskLevel
+	if e.catalog != nil {
+		if tool, ok := e.catalog.Lookup(toolName); ok {
+			level = tool.RiskLevel
+		}
+	}
+	if policy != nil {
+		if perm, ok := policy.ToolTable[toolName]; ok && riskRanks[perm.RiskLevel] > riskRanks[level] {
+			level = perm.RiskLevel
+		}
+	}
+	return level
+}
+
+// riskObligations attaches an ObligationApproval to an allowed call for
+// each risk rule requiring approval that matches its level.
+func (e *Engine) riskObligations(level RiskLevel, decision Decision, obligations []Obligation) []Obligation {
+	if decision != Allow || level == "" {
+		return obligations
+	}
+	var approvals []Obligation
+	for _, rule := range e.riskRules {
+		if rule.has(RiskRequireApproval) && rule.Matches(level) {
+			approvals = append(approvals, Obligation{
+				Type:   ObligationApproval,
+				Params: map[string]string{"risk": string(level), "rule": rule.String()},
+			})
+		}
+	}
+	if len(approvals) == 0 {
+		return obligations
+	}
+	return append(append([]Obligation(nil), obligations...), approvals...)
+}
+
+// riskAuditsParameters reports whether a risk rule requires the
+// parameters of calls of level to be audited.
+func (e *Engine) riskAuditsParameters(level RiskLevel) bool {
+	for _, rule := range e.riskRules {
+		if rule.has(RiskAuditParameters) && rule.Matches(level) {
+			return true
+		}
+	}
+	return false
+}
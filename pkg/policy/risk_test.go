@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestRiskRules tests that risk rules act on the calls of every policy by
+// the risk level of the catalog or the policy's rule.
+func TestRiskRules(t *testing.T) {
+	catalog := NewToolCatalog()
+	catalog.Replace([]ToolCatalogSet{{Source: "platform", Tools: []CatalogTool{
+		{Name: "file.read", RiskLevel: RiskLow},
+		{Name: "shell.exec", RiskLevel: RiskHigh},
+		{Name: "db.drop", RiskLevel: RiskCritical},
+	}}})
+	var rules []RiskRule
+	for _, s := range []string{"tier>=high:approval", "tier==critical:audit-parameters"} {
+		rule, err := ParseRiskRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule.String() != s {
+			t.Errorf("expected %q to format as itself, got %q", s, rule.String())
+		}
+		rules = append(rules, rule)
+	}
+
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Enforcing), WithToolCatalog(catalog), WithRiskRules(rules...), WithAuditSink(sink))
+	p := CompilePolicy("team", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "file.read", Action: Allow},
+		{Tool: "shell.exec", Action: Allow, RiskLevel: RiskLow},
+		{Tool: "db.drop", Action: Allow},
+		{Tool: "net.fetch", Action: Allow, RiskLevel: RiskHigh},
+	}, Enforcing, "")
+	engine.LoadPolicy("coding-assistant", p)
+	agent := AgentContext{AgentType: "coding-assistant"}
+
+	for _, tc := range []struct {
+		tool     string
+		risk     RiskLevel
+		approval bool
+		params   bool
+	}{
+		{"file.read", RiskLow, false, false},
+		// The policy cannot lower the catalog's level
+		{"shell.exec", RiskHigh, true, false},
+		{"db.drop", RiskCritical, true, true},
+		// Tools the catalog does not rate are rated by the policy
+		{"net.fetch", RiskHigh, true, false},
+	} {
+		// Twice, so that cached decisions are checked too
+		for i := 0; i < 2; i++ {
+			result, err := engine.EvaluateWithResult(context.Background(), agent, tc.tool, map[string]interface{}{"arg": "x"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			approval := len(result.Obligations) == 1 && result.Obligations[0].Type == ObligationApproval &&
+				result.Obligations[0].Params["risk"] == string(tc.risk)
+			if result.Decision != Allow || approval != tc.approval {
+				t.Errorf("%s: expected approval %v, got %s with %v", tc.tool, tc.approval, result.Decision, result.Obligations)
+			}
+			event := <-sink.Events()
+			if event.RiskLevel != tc.risk || (event.Parameters != nil) != tc.params {
+				t.Errorf("%s: expected risk %s and parameters %v audited, got %s and %v", tc.tool, tc.risk, tc.params, event.RiskLevel, event.Parameters)
+			}
+		}
+	}
+
+	for _, s := range []string{"tier>=severe:approval", "tier~high:approval", "tier>=high", "risk>=high:approval", "tier>=high:deny"} {
+		if _, err := ParseRiskRule(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
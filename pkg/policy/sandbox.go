@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// SandboxContext is the authoritative context of a sandbox, taken from the
+// SandboxClaim that admitted it rather than from the sandbox's own request
+// metadata. Calls from a sandbox with a context are cross-checked against
+// it: metadata it leaves out is filled in, and metadata that contradicts
+// it denies the call with ErrSandboxMismatch.
+type SandboxContext struct {
+	// SandboxID identifies the sandbox, as AgentContext.SandboxID
+	SandboxID string
+
+	// TenantID is the tenant the sandbox was claimed for
+	TenantID string
+
+	// MTSLabel is the MTS label of the claimed policy, if it has one, or
+	// the label allocated to the tenant
+	MTSLabel string
+
+	// PolicyRef is the policy the claim references, as "namespace/name"
+	PolicyRef string
+}
+
+// sandboxStore holds the sandbox contexts loaded into an engine.
+type sandboxStore struct {
+	mu        sync.RWMutex
+	sandboxes map[string]SandboxContext
+}
+
+func newSandboxStore() *sandboxStore {
+	return &sandboxStore{sandboxes: make(map[string]SandboxContext)}
+}
+
+// SetSandboxContext adds or replaces the context of a sandbox.
+func (e *Engine) SetSandboxContext(sc SandboxContext) {
+	e.sandboxes.mu.Lock()
+	e.sandboxes.sandboxes[sc.SandboxID] = sc
+	e.sandboxes.mu.Unlock()
+}
+
+// RemoveSandboxContext removes the context of a sandbox; its calls are
+// then evaluated on their metadata alone.
+func (e *Engine) RemoveSandboxContext(sandboxID string) {
+	e.sandboxes.mu.Lock()
+	delete(e.sandboxes.sandboxes, sandboxID)
+	e.sandboxes.mu.Unlock()
+}
+
+// GetSandboxContext returns the context loaded for a sandbox.
+func (e *Engine) GetSandboxContext(sandboxID string) (SandboxContext, bool) {
+	e.sandboxes.mu.RLock()
+	defer e.sandboxes.mu.RUnlock()
+	sc, ok := e.sandboxes.sandboxes[sandboxID]
+	return sc, ok
+}
+
+// ListSandboxContexts returns the IDs of the sandboxes with a context,
+// sorted.
+func (e *Engine) ListSandboxContexts() []string {
+	e.sandboxes.mu.RLock()
+	defer e.sandboxes.mu.RUnlock()
+	ids := make([]string, 0, len(e.sandboxes.sandboxes))
+	for id := range e.sandboxes.sandboxes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// checkSandbox cross-checks an agent's metadata against the context of its
+// sandbox, if one is loaded, and returns the agent with the metadata it
+// left out filled in. The policy resolved for the agent must be the one
+// the claim references, unless it was not loaded from a resource.
+func (e *Engine) checkSandbox(agent AgentContext, policy *CompiledPolicy) (AgentContext, error) {
+	if agent.SandboxID == "" {
+		return agent, nil
+	}
+	sc, ok := e.GetSandboxContext(agent.SandboxID)
+	if !ok {
+		return agent, nil
+	}
+
+	mismatch := func(field, claimed, actual string) error {
+		return fmt.Errorf("%w: sandbox %q is claimed with %s %q, not %q",
+			policyerrors.ErrSandboxMismatch, agent.SandboxID, field, claimed, actual)
+	}
+	if sc.TenantID != "" {
+		if agent.TenantID != "" && agent.TenantID != sc.TenantID {
+			return agent, mismatch("tenant", sc.TenantID, agent.TenantID)
+		}
+		agent.TenantID = sc.TenantID
+	}
+	if sc.MTSLabel != "" {
+		if agent.MTSLabel != "" && agent.MTSLabel != sc.MTSLabel {
+			return agent, mismatch("MTS label", sc.MTSLabel, agent.MTSLabel)
+		}
+		agent.MTSLabel = sc.MTSLabel
+	}
+	if sc.PolicyRef != "" {
+		if agent.PolicyRef != "" && !matchesPolicyRef(sc.PolicyRef, agent.PolicyRef) {
+			return agent, mismatch("policy", sc.PolicyRef, agent.PolicyRef)
+		}
+		agent.PolicyRef = sc.PolicyRef
+		if policy != nil && policy.Namespace != "" && policy.Namespace+"/"+policy.Name != sc.PolicyRef {
+			return agent, mismatch("policy", sc.PolicyRef, policy.Namespace+"/"+policy.Name)
+		}
+	}
+	return agent, nil
+}
+
+// matchesPolicyRef reports whether a policy reference from request
+// metadata, "namespace/name" or just "name", names the policy ref.
+func matchesPolicyRef(ref, claimed string) bool {
+	if strings.Contains(claimed, "/") {
+		return claimed == ref
+	}
+	return claimed == ref[strings.LastIndex(ref, "/")+1:]
+}
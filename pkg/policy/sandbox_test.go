@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestSandboxContext tests the cross-check of request metadata against
+// the claim of the sandbox.
+func TestSandboxContext(t *testing.T) {
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Enforcing), WithAuditSink(sink))
+	compiled := CompilePolicy("coding-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, "")
+	compiled.Namespace = "team-a"
+	engine.LoadPolicy("coding-assistant", compiled)
+
+	engine.SetSandboxContext(SandboxContext{
+		SandboxID: "sandbox-1",
+		TenantID:  "team-a",
+		MTSLabel:  "s0:c1,c2",
+		PolicyRef: "team-a/coding-policy",
+	})
+
+	tests := []struct {
+		name  string
+		agent AgentContext
+		want  Decision
+	}{
+		{"matching metadata", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1", TenantID: "team-a", MTSLabel: "s0:c1,c2", PolicyRef: "coding-policy"}, Allow},
+		{"omitted metadata", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1"}, Allow},
+		{"other tenant", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1", TenantID: "team-b"}, Deny},
+		{"other MTS label", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1", MTSLabel: "s0:c3"}, Deny},
+		{"other policy", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1", PolicyRef: "team-b/coding-policy"}, Deny},
+		{"unclaimed sandbox", AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-2", TenantID: "team-b"}, Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.EvaluateWithResult(context.Background(), tt.agent, "file.read", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Decision != tt.want {
+				t.Fatalf("expected %v, got %v (%s)", tt.want, result.Decision, result.Reason)
+			}
+			if mismatch := errors.Is(result.Err, policyerrors.ErrSandboxMismatch); mismatch != (tt.want == Deny) {
+				t.Errorf("expected ErrSandboxMismatch %v, got %v", tt.want == Deny, result.Err)
+			}
+		})
+	}
+
+	// The claim fills in the audited context
+	for len(sink.Events()) > 0 {
+		<-sink.Events()
+	}
+	engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1"}, "file.write", nil)
+	event := <-sink.Events()
+	if event.Agent.TenantID != "team-a" || event.Agent.MTSLabel != "s0:c1,c2" {
+		t.Errorf("expected the claimed tenant and label to be audited, got %q %q", event.Agent.TenantID, event.Agent.MTSLabel)
+	}
+
+	// A claim for another policy denies the sandbox's calls
+	engine.SetSandboxContext(SandboxContext{SandboxID: "sandbox-1", PolicyRef: "team-a/review-policy"})
+	if decision, _ := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1"}, "file.list", nil); decision != Deny {
+		t.Errorf("expected a policy other than the claimed one to deny, got %v", decision)
+	}
+
+	engine.RemoveSandboxContext("sandbox-1")
+	if ids := engine.ListSandboxContexts(); len(ids) != 0 {
+		t.Errorf("expected no sandbox contexts, got %v", ids)
+	}
+	if decision, _ := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", SandboxID: "sandbox-1", TenantID: "team-b"}, "file.list", nil); decision != Allow {
+		t.Errorf("expected the metadata to be trusted once the claim is removed, got %v", decision)
+	}
+}
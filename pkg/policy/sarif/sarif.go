@@ -0,0 +1,309 @@
+// Package sarif exports policy violations as SARIF 2.1.0 logs.
+//
+// CI pipelines that run agents against a router, or replay recorded agent
+// runs against a proposed policy, can upload the log to a code review
+// system (e.g., GitHub code scanning) so that violations show up as
+// annotations: calls with a file parameter inside the source tree are
+// reported on that file, and everything else on the policy manifest.
+//
+// Identical violations (same rule, agent type, tool, location, and reason)
+// are reported once, with the number of occurrences in the "count"
+// property.
+//
+// Usage:
+//
+//	log := sarif.FromDenials(events, sarif.Options{PolicyURI: "policies/coding.yaml", SourceRoot: "/workspace"})
+//	log.Write(os.Stdout)
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+const (
+	// Version is the SARIF version of the exported logs
+	Version = "2.1.0"
+
+	// Schema is the JSON schema of SARIF 2.1.0 logs
+	Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Rule IDs of the exported results
+const (
+	// RuleDenied is a call denied by the policy in force
+	RuleDenied = "policy-denied"
+
+	// RuleNewlyDenied is a recorded call a proposed policy would deny
+	RuleNewlyDenied = "newly-denied"
+
+	// RuleNewlyAllowed is a recorded denied call a proposed policy would allow
+	RuleNewlyAllowed = "newly-allowed"
+)
+
+// rules describe the exported rules, by ID.
+var rules = map[string]Rule{
+	RuleDenied: {
+		ID:               RuleDenied,
+		Name:             "PolicyDenied",
+		ShortDescription: Message{Text: "Tool call denied by AgentPolicy"},
+		DefaultConfig:    &RuleConfig{Level: "error"},
+	},
+	RuleNewlyDenied: {
+		ID:               RuleNewlyDenied,
+		Name:             "NewlyDenied",
+		ShortDescription: Message{Text: "Recorded tool call the proposed AgentPolicy would deny"},
+		DefaultConfig:    &RuleConfig{Level: "warning"},
+	},
+	RuleNewlyAllowed: {
+		ID:               RuleNewlyAllowed,
+		Name:             "NewlyAllowed",
+		ShortDescription: Message{Text: "Recorded denied tool call the proposed AgentPolicy would allow"},
+		DefaultConfig:    &RuleConfig{Level: "note"},
+	},
+}
+
+// Options configure the exported log.
+type Options struct {
+	// ToolName is the name of the analysis tool (default: "golden-agent")
+	ToolName string
+
+	// ToolVersion is its version, if known
+	ToolVersion string
+
+	// PolicyURI is the path of the policy manifest, relative to the
+	// repository root. Results without a file location are reported on it.
+	PolicyURI string
+
+	// SourceRoot is the directory the agent's source tree was mounted at
+	// (e.g., "/workspace"). Path parameters under it are reported relative
+	// to the repository root; other paths are not file locations.
+	SourceRoot string
+}
+
+// Log is a SARIF log.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one analysis tool run.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the analysis tool.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the analysis tool and the rules it reports.
+type Driver struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Rule describes a kind of result.
+type Rule struct {
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	ShortDescription Message     `json:"shortDescription"`
+	DefaultConfig    *RuleConfig `json:"defaultConfiguration,omitempty"`
+}
+
+// RuleConfig is the default configuration of a rule.
+type RuleConfig struct {
+	Level string `json:"level"`
+}
+
+// Message is a SARIF message.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is one reported violation.
+type Result struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Location is where a result is reported.
+type Location struct {
+	PhysicalLocation *PhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// PhysicalLocation is a file location.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+// ArtifactLocation is a file URI, relative to the repository root.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// LogicalLocation names the agent type and tool of a result.
+type LogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// FromDenials reports the denied calls among audit events. Decisions are
+// the raw policy decisions the events record, so denials allowed by
+// permissive mode are reported too.
+func FromDenials(events []policy.AuditEvent, opts Options) *Log {
+	b := newBuilder(opts)
+	for i := range events {
+		event := &events[i]
+		if event.Decision != policy.Deny {
+			continue
+		}
+		b.add(RuleDenied, event, fmt.Sprintf("%s call to %s denied by policy: %s",
+			event.Agent.AgentType, event.Tool, event.Reason))
+	}
+	return b.log()
+}
+
+// FromReport reports the decisions a proposed policy would change in a
+// replay of recorded traffic.
+func FromReport(report *replay.Report, opts Options) *Log {
+	b := newBuilder(opts)
+	for i := range report.Flips {
+		flip := &report.Flips[i]
+		rule := RuleNewlyAllowed
+		if flip.Decision == policy.Deny {
+			rule = RuleNewlyDenied
+		}
+		b.add(rule, &flip.Event, fmt.Sprintf("%s call to %s would change from %s to %s under policy %s: %s",
+			flip.Event.Agent.AgentType, flip.Event.Tool, flip.Event.Decision, flip.Decision, report.Policy, flip.Reason))
+	}
+	return b.log()
+}
+
+// Write writes the log as indented JSON.
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// builder accumulates deduplicated results.
+type builder struct {
+	opts    Options
+	rules   []Rule
+	index   map[string]int // rule ID -> index in rules
+	results []Result
+	seen    map[string]int // fingerprint -> index in results
+}
+
+func newBuilder(opts Options) *builder {
+	if opts.ToolName == "" {
+		opts.ToolName = "golden-agent"
+	}
+	return &builder{
+		opts:  opts,
+		index: make(map[string]int),
+		seen:  make(map[string]int),
+	}
+}
+
+// add reports a result of rule for an event, or counts it if an identical
+// one was reported.
+func (b *builder) add(ruleID string, event *policy.AuditEvent, text string) {
+	location := b.location(event)
+	uri := ""
+	if location.PhysicalLocation != nil {
+		uri = location.PhysicalLocation.ArtifactLocation.URI
+	}
+	fingerprint := strings.Join([]string{ruleID, event.Agent.AgentType, event.Tool, uri, event.Reason}, "\x00")
+	if i, ok := b.seen[fingerprint]; ok {
+		b.results[i].Properties["count"] = b.results[i].Properties["count"].(int) + 1
+		return
+	}
+
+	ruleIndex, ok := b.index[ruleID]
+	if !ok {
+		ruleIndex = len(b.rules)
+		b.index[ruleID] = ruleIndex
+		b.rules = append(b.rules, rules[ruleID])
+	}
+
+	b.seen[fingerprint] = len(b.results)
+	b.results = append(b.results, Result{
+		RuleID:    ruleID,
+		RuleIndex: ruleIndex,
+		Level:     rules[ruleID].DefaultConfig.Level,
+		Message:   Message{Text: text},
+		Locations: []Location{location},
+		PartialFingerprints: map[string]string{
+			"agentTool/v1": event.Agent.AgentType + "/" + event.Tool,
+		},
+		Properties: map[string]interface{}{
+			"agentType": event.Agent.AgentType,
+			"tool":      event.Tool,
+			"count":     1,
+		},
+	})
+}
+
+// location reports an event on the file of its path parameter, if it is
+// inside the source root, or else on the policy manifest.
+func (b *builder) location(event *policy.AuditEvent) Location {
+	location := Location{LogicalLocations: []LogicalLocation{{
+		Name:               event.Tool,
+		FullyQualifiedName: event.Agent.AgentType + "/" + event.Tool,
+		Kind:               "function",
+	}}}
+
+	if p, ok := event.Parameters["path"].(string); ok && b.opts.SourceRoot != "" {
+		root := path.Clean(b.opts.SourceRoot)
+		if p = path.Clean(p); strings.HasPrefix(p, root+"/") {
+			location.PhysicalLocation = &PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: strings.TrimPrefix(p, root+"/")}}
+			return location
+		}
+	}
+	if b.opts.PolicyURI != "" {
+		location.PhysicalLocation = &PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: b.opts.PolicyURI}}
+	}
+	return location
+}
+
+// log returns the SARIF log of the accumulated results.
+func (b *builder) log() *Log {
+	rules := b.rules
+	if rules == nil {
+		rules = []Rule{}
+	}
+	results := b.results
+	if results == nil {
+		results = []Result{}
+	}
+	return &Log{
+		Version: Version,
+		Schema:  Schema,
+		Runs: []Run{{
+			Tool: Tool{Driver: Driver{
+				Name:    b.opts.ToolName,
+				Version: b.opts.ToolVersion,
+				Rules:   rules,
+			}},
+			Results: results,
+		}},
+	}
+}
@@ -0,0 +1,93 @@
+package sarif
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/replay"
+)
+
+func denial(tool, path, reason string) policy.AuditEvent {
+	event := policy.AuditEvent{
+		Timestamp: time.Now(),
+		Agent:     policy.AgentContext{AgentType: "coding-assistant"},
+		Tool:      tool,
+		Decision:  policy.Deny,
+		Reason:    reason,
+	}
+	if path != "" {
+		event.Parameters = map[string]interface{}{"path": path}
+	}
+	return event
+}
+
+// TestFromDenials verifies denials are located on source files or the
+// policy manifest, and identical denials are counted once
+func TestFromDenials(t *testing.T) {
+	events := []policy.AuditEvent{
+		denial("file.write", "/workspace/src/main.go", "constraint violation"),
+		denial("file.write", "/workspace/src/main.go", "constraint violation"),
+		denial("file.write", "/etc/passwd", "constraint violation"),
+		denial("db.query", "", "denied by default policy"),
+		{Agent: policy.AgentContext{AgentType: "coding-assistant"}, Tool: "file.read", Decision: policy.Allow},
+	}
+
+	log := FromDenials(events, Options{PolicyURI: "policies/coding.yaml", SourceRoot: "/workspace/"})
+	results := log.Runs[0].Results
+	if len(results) != 3 || len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Fatalf("expected 3 results of 1 rule, got %+v", log.Runs[0])
+	}
+
+	wantURIs := []string{"src/main.go", "policies/coding.yaml", "policies/coding.yaml"}
+	for i, r := range results {
+		if r.RuleID != RuleDenied || r.Level != "error" {
+			t.Errorf("result %d: unexpected rule %s (%s)", i, r.RuleID, r.Level)
+		}
+		if uri := r.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != wantURIs[i] {
+			t.Errorf("result %d: expected location %s, got %s", i, wantURIs[i], uri)
+		}
+	}
+	if results[0].Properties["count"] != 2 {
+		t.Errorf("expected repeated denial to be counted, got %v", results[0].Properties)
+	}
+
+	var buf bytes.Buffer
+	if err := log.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded["version"] != "2.1.0" {
+		t.Errorf("expected a SARIF 2.1.0 log, got %s (%v)", buf.String(), err)
+	}
+}
+
+// TestFromReport verifies replay flips are reported by direction
+func TestFromReport(t *testing.T) {
+	allowed := denial("file.write", "", "")
+	allowed.Decision = policy.Allow
+	events := []policy.AuditEvent{allowed, denial("network.fetch", "", "denied by default policy")}
+
+	proposed := policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "network.fetch", Action: policy.Allow}}, policy.Enforcing, "")
+	report, err := replay.Run(context.Background(), events, proposed)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	log := FromReport(report, Options{})
+	results := log.Runs[0].Results
+	if len(results) != 2 || results[0].RuleID != RuleNewlyDenied || results[1].RuleID != RuleNewlyAllowed {
+		t.Fatalf("expected newly-denied and newly-allowed results, got %+v", results)
+	}
+	if results[1].Level != "note" || results[1].RuleIndex != 1 || results[0].Locations[0].PhysicalLocation != nil {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if empty := FromReport(&replay.Report{}, Options{}); empty.Runs[0].Results == nil {
+		t.Error("expected an empty results array")
+	}
+}
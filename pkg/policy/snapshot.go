@@ -0,0 +1,252 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot format; RestoreSnapshot
+// rejects snapshots of other versions.
+const SnapshotVersion = 1
+
+// Snapshot is the state of an engine, as JSON: its policies and their
+// bindings, canary rollouts, kill switches, tenant modes, and optionally
+// its cached decisions. A router restores the snapshot of a running
+// replica before it starts serving, so that a new replica of a blue/green
+// deploy does not serve with an empty policy set until its controller or
+// policy source has synced.
+//
+// Policies are carried as the manifests they were compiled from, and
+// recompiled on restore, since prepared OPA queries cannot be serialized.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Policies     []SnapshotPolicy        `json:"policies"`
+	KillSwitches []SnapshotKillSwitch    `json:"kill_switches,omitempty"`
+	TenantModes  map[string]string       `json:"tenant_modes,omitempty"`
+	Cache        []SnapshotCacheDecision `json:"cache,omitempty"`
+}
+
+// SnapshotPolicy is a policy of a snapshot, and where it is loaded.
+type SnapshotPolicy struct {
+	// Source is the manifest the policy was compiled from
+	// (CompiledPolicy.Source)
+	Source json.RawMessage `json:"source"`
+
+	// Bindings are the keys the policy is loaded under
+	Bindings []string `json:"bindings,omitempty"`
+
+	// CanaryOf and CanaryPercent are the stable policy the policy is
+	// rolled out as the new version of, and to what share (see
+	// Engine.LoadCanary)
+	CanaryOf      string `json:"canary_of,omitempty"`
+	CanaryPercent int    `json:"canary_percent,omitempty"`
+
+	// Unresolved is set for policies that read constraint values from
+	// Secrets, whose Source leaves them unresolved; they are not restored
+	Unresolved bool `json:"unresolved,omitempty"`
+
+	// Fingerprint is the policy's CompiledPolicy.Fingerprint, which
+	// recompiling Source elsewhere must reproduce for the policy to be
+	// enforced the same. Unresolved policies have none, as it would hash
+	// their Secret values
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// SnapshotKillSwitch is a kill switch of a snapshot (see KillSwitch).
+type SnapshotKillSwitch struct {
+	Source    string    `json:"source"`
+	Tool      string    `json:"tool"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// SnapshotCacheDecision is a cached decision of a snapshot.
+type SnapshotCacheDecision struct {
+	Key       string    `json:"key"`
+	Allow     bool      `json:"allow"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Snapshot returns the state of the engine, with its cached decisions if
+// cache is set. Cached denials with a typed error are left out, since the
+// error does not survive serialization; the restored engine evaluates
+// those calls again. Policies not compiled from a manifest, such as those
+// built with CompilePolicy, cannot be snapshotted and fail it. Policies
+// that read values from Secrets are carried without them (see
+// CompiledPolicy.SecretValues).
+func (e *Engine) Snapshot(cache bool) (*Snapshot, error) {
+	s := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC()}
+
+	index := make(map[*CompiledPolicy]int)
+	add := func(p *CompiledPolicy) (*SnapshotPolicy, error) {
+		if i, ok := index[p]; ok {
+			return &s.Policies[i], nil
+		}
+		if len(p.Source) == 0 {
+			return nil, fmt.Errorf("policy %s was not compiled from a manifest and cannot be snapshotted", p.Name)
+		}
+		index[p] = len(s.Policies)
+		entry := SnapshotPolicy{Source: json.RawMessage(p.Source), Unresolved: len(p.SecretValues) > 0}
+		if !entry.Unresolved {
+			entry.Fingerprint = p.Fingerprint()
+		}
+		s.Policies = append(s.Policies, entry)
+		return &s.Policies[len(s.Policies)-1], nil
+	}
+
+	keys := e.resolver.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		p, ok := e.resolver.Get(key)
+		if !ok {
+			continue
+		}
+		entry, err := add(p)
+		if err != nil {
+			return nil, err
+		}
+		entry.Bindings = append(entry.Bindings, key)
+	}
+
+	e.canaries.mu.RLock()
+	stables := make([]string, 0, len(e.canaries.rollouts))
+	for stable := range e.canaries.rollouts {
+		stables = append(stables, stable)
+	}
+	sort.Strings(stables)
+	for _, stable := range stables {
+		rollout := e.canaries.rollouts[stable]
+		entry, err := add(rollout.policy)
+		if err != nil {
+			e.canaries.mu.RUnlock()
+			return nil, err
+		}
+		entry.CanaryOf, entry.CanaryPercent = stable, rollout.percent
+	}
+	e.canaries.mu.RUnlock()
+
+	for _, k := range e.KillSwitches() {
+		s.KillSwitches = append(s.KillSwitches, SnapshotKillSwitch(k))
+	}
+	for tenantID, mode := range e.TenantModes() {
+		if s.TenantModes == nil {
+			s.TenantModes = make(map[string]string)
+		}
+		s.TenantModes[tenantID] = mode.String()
+	}
+
+	if cache {
+		s.Cache = e.cache.snapshot()
+	}
+	return s, nil
+}
+
+// RestoreSnapshot loads the state of a snapshot into the engine,
+// recompiling its policies with compile, which is given the manifest of
+// each (see compile.Manifest). Nothing is loaded unless every policy
+// compiles. The restored state is replaced as policies are loaded
+// afterwards, such as by the controller once it has synced; policies
+// deleted since the snapshot was taken stay loaded until then, so take
+// the snapshot just before it is restored.
+//
+// Unresolved policies, which read values from Secrets the router cannot
+// read, are left out until then, and so are the cached decisions, which
+// could have been made by them.
+func (e *Engine) RestoreSnapshot(s *Snapshot, compile func(source []byte) (*CompiledPolicy, error)) error {
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d (want %d)", s.Version, SnapshotVersion)
+	}
+	tenantModes := make(map[string]EnforcementMode, len(s.TenantModes))
+	for tenantID, mode := range s.TenantModes {
+		switch strings.ToLower(mode) {
+		case "enforcing":
+			tenantModes[tenantID] = Enforcing
+		case "permissive":
+			tenantModes[tenantID] = Permissive
+		default:
+			return fmt.Errorf("invalid mode %q of tenant %s in snapshot", mode, tenantID)
+		}
+	}
+	compiled := make([]*CompiledPolicy, len(s.Policies))
+	unresolved := 0
+	for i, p := range s.Policies {
+		if p.Unresolved {
+			unresolved++
+			continue
+		}
+		policy, err := compile(p.Source)
+		if err != nil {
+			return fmt.Errorf("failed to compile snapshot policy %d: %w", i, err)
+		}
+		compiled[i] = policy
+	}
+
+	for i, p := range s.Policies {
+		if p.Unresolved {
+			continue
+		}
+		for _, key := range p.Bindings {
+			e.LoadPolicy(key, compiled[i])
+		}
+		if p.CanaryOf != "" {
+			e.LoadCanary(p.CanaryOf, compiled[i], p.CanaryPercent)
+		}
+	}
+	switches := make([]KillSwitch, 0, len(s.KillSwitches))
+	for _, k := range s.KillSwitches {
+		switches = append(switches, KillSwitch(k))
+	}
+	e.ReplaceKillSwitches(switches)
+	e.ReplaceTenantModes(tenantModes)
+
+	// Cached decisions last, since loading invalidates the cache
+	cached := s.Cache
+	if unresolved > 0 {
+		cached = nil
+	}
+	e.cache.restore(cached)
+	e.log.Info("restored engine snapshot", "created", s.CreatedAt, "policies", len(s.Policies)-unresolved, "unresolved", unresolved, "cached", len(cached))
+	return nil
+}
+
+// snapshot returns the unexpired decisions of the cache, sorted by key,
+// without those of denials with a typed error.
+func (c *DecisionCache) snapshot() []SnapshotCacheDecision {
+	now := time.Now()
+	var decisions []SnapshotCacheDecision
+	c.entries.Range(func(key, val interface{}) bool {
+		entry := val.(cacheEntry)
+		if entry.err == nil && now.Before(entry.expiresAt) {
+			decisions = append(decisions, SnapshotCacheDecision{
+				Key:       key.(string),
+				Allow:     entry.decision == Allow,
+				Reason:    entry.reason,
+				ExpiresAt: entry.expiresAt.UTC(),
+			})
+		}
+		return true
+	})
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Key < decisions[j].Key })
+	return decisions
+}
+
+// restore stores the unexpired decisions of a snapshot, until they expire.
+func (c *DecisionCache) restore(decisions []SnapshotCacheDecision) {
+	now := time.Now()
+	for _, d := range decisions {
+		if !now.Before(d.ExpiresAt) {
+			continue
+		}
+		decision := Deny
+		if d.Allow {
+			decision = Allow
+		}
+		c.entries.Store(d.Key, cacheEntry{decision: decision, reason: d.Reason, expiresAt: d.ExpiresAt})
+	}
+}
@@ -0,0 +1,393 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxTenants is the default cap on the number of tenant partitions.
+const DefaultMaxTenants = 1000
+
+// TenantEngineConfig configures a TenantEngine.
+type TenantEngineConfig struct {
+	// CacheTTL is the decision cache TTL of each tenant (default: 60s)
+	CacheTTL time.Duration
+
+	// MaxTenants caps the number of tenant partitions (default:
+	// DefaultMaxTenants; negative means no limit). Requests from tenants
+	// beyond the cap are evaluated by the default partition, and loading
+	// their tenant policies fails.
+	MaxTenants int
+}
+
+// TenantStats are the statistics of one tenant partition.
+type TenantStats struct {
+	CacheHits    uint64
+	CacheMisses  uint64
+	HitRate      float64
+	CacheEntries int
+	Policies     int
+}
+
+// TenantEngine partitions policy evaluation by tenant for noisy-neighbor
+// isolation. Each tenant gets its own Engine, with its own decision cache
+// and policy namespace, selected by AgentContext.TenantID: a policy update
+// for one tenant invalidates only that tenant's cache, and one tenant's
+// traffic cannot evict another's cached decisions.
+//
+// The partitions are forked from a base engine, which is the default
+// partition for requests without a TenantID. Policies bound in the base
+// engine, with LoadPolicy or directly, are shared: they apply to every
+// tenant that has not loaded its own policy for the agent type with
+// LoadTenantPolicy. Everything else is engine-wide and shared with the
+// base engine: its mode, kill switches, canary rollouts, tenant modes,
+// sandbox claims, tenant registry, budgets, tool catalog, and audit sink.
+//
+// Usage:
+//
+//	engine := NewTenantEngine(TenantEngineConfig{MaxTenants: 1000}, WithMode(Enforcing))
+//	engine.LoadPolicy("coding-assistant", sharedPolicy)
+//	engine.LoadTenantPolicy("tenant-a", "coding-assistant", tenantAPolicy)
+//	decision, err := engine.Evaluate(ctx, agentCtx, "file.read", request)
+type TenantEngine struct {
+	mu     sync.RWMutex
+	config TenantEngineConfig
+
+	// base is the default partition, and holds the shared policies and
+	// the engine-wide state
+	base *Engine
+
+	// overrides are the tenant-specific policies, by tenant and agent type
+	overrides map[string]map[string]*CompiledPolicy
+
+	// partitions are the tenant engines forked from base
+	partitions map[string]*Engine
+
+	// stop unregisters the mirroring of base's changes
+	stop func()
+}
+
+// NewTenantEngine creates a tenant-partitioned engine whose base engine is
+// created with opts (see NewTenantEngineFrom).
+func NewTenantEngine(config TenantEngineConfig, opts ...Option) *TenantEngine {
+	return NewTenantEngineFrom(NewEngine(opts...), config)
+}
+
+// NewTenantEngineFrom partitions the evaluation of base by tenant. Each
+// partition gets its own decision cache and OPA memo, and shares the rest
+// of base's configuration; only base publishes to and subscribes to its
+// InvalidationBus, and its invalidations reach the partitions. Call Close
+// to stop mirroring base's changes.
+func NewTenantEngineFrom(base *Engine, config TenantEngineConfig) *TenantEngine {
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = 60 * time.Second
+	}
+	if config.MaxTenants == 0 {
+		config.MaxTenants = DefaultMaxTenants
+	}
+	t := &TenantEngine{
+		config:     config,
+		base:       base,
+		overrides:  make(map[string]map[string]*CompiledPolicy),
+		partitions: make(map[string]*Engine),
+	}
+	t.stop = base.OnPolicyChange(t.baseChanged)
+	return t
+}
+
+// Close stops mirroring the changes of the base engine into the partitions.
+func (t *TenantEngine) Close() {
+	t.stop()
+}
+
+// Base returns the base engine, the default partition.
+func (t *TenantEngine) Base() *Engine {
+	return t.base
+}
+
+// fork creates a tenant partition of e: an engine with its own policy
+// bindings, decision cache, and OPA memo that shares the engine-wide state
+// of e. It has no InvalidationBus; e's invalidations are mirrored into it.
+func (e *Engine) fork(cache *DecisionCache) *Engine {
+	p := &Engine{
+		resolver:      NewPolicyResolver(),
+		cache:         cache,
+		audit:         e.audit,
+		tenantModes:   e.tenantModes,
+		useOPA:        e.useOPA,
+		auditParams:   e.auditParams,
+		auditRedactor: e.auditRedactor,
+		profiles:      e.profiles,
+		sandboxes:     e.sandboxes,
+		tenants:       e.tenants,
+		catalog:       e.catalog,
+		riskRules:     e.riskRules,
+		kills:         e.kills,
+		evalTimeout:   e.evalTimeout,
+		guard:         e.guard,
+		external:      e.external,
+		conditions:    e.conditions,
+		spend:         e.spend,
+		canaries:      e.canaries,
+		wouldDeny:     e.wouldDeny,
+		opaStats:      e.opaStats,
+		combining:     e.combining,
+		tools:         e.tools,
+		log:           e.log,
+		opaMemoTTL:    e.opaMemoTTL,
+		partialEval:   e.partialEval,
+		faults:        e.faults,
+	}
+	p.mode.Store(e.mode.Load())
+	if e.opaEval != nil {
+		p.opaEval = NewOPAEvaluator(cache, e.audit, e.Mode())
+		p.opaEval.log = e.log
+		if e.opaMemoTTL > 0 {
+			p.opaEval.memo = newOPAMemo(e.opaMemoTTL)
+		}
+	}
+	return p
+}
+
+// newPartition forks the engine of a tenant with the shared policies and
+// the tenant's own. Callers must hold t.mu for writing.
+func (t *TenantEngine) newPartition(tenantID string) *Engine {
+	e := t.base.fork(NewDecisionCache(t.config.CacheTTL))
+	for _, agentType := range t.base.ListPolicies() {
+		if _, overridden := t.overrides[tenantID][agentType]; overridden {
+			continue
+		}
+		if p, ok := t.base.GetPolicy(agentType); ok {
+			e.LoadPolicy(agentType, p)
+		}
+	}
+	for agentType, p := range t.overrides[tenantID] {
+		e.LoadPolicy(agentType, p)
+	}
+	return e
+}
+
+// baseChanged mirrors a change of the base engine's binding of agentType
+// into the partitions that do not override it, along with its mode. Kill
+// switches, canaries, and mode changes are reported as FallbackAgentType,
+// whose mirroring drops every cached decision of the partitions.
+func (t *TenantEngine) baseChanged(agentType string) {
+	policy, bound := t.base.GetPolicy(agentType)
+	mode := t.base.Mode()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for tenantID, e := range t.partitions {
+		if e.Mode() != mode {
+			e.SetMode(mode)
+		}
+		if _, overridden := t.overrides[tenantID][agentType]; overridden {
+			if IsAgentTypePattern(agentType) {
+				e.invalidateAgentType(agentType)
+			}
+			continue
+		}
+		if bound {
+			e.LoadPolicy(agentType, policy)
+		} else {
+			e.RemovePolicy(agentType)
+		}
+	}
+}
+
+// partition returns the engine that evaluates requests of a tenant,
+// creating it on first use. Requests without a tenant, and tenants beyond
+// MaxTenants, use the base engine.
+func (t *TenantEngine) partition(tenantID string) *Engine {
+	if tenantID == "" {
+		return t.base
+	}
+	t.mu.RLock()
+	e, ok := t.partitions[tenantID]
+	t.mu.RUnlock()
+	if ok {
+		return e
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.partitions[tenantID]; ok {
+		return e
+	}
+	if t.full() {
+		return t.base
+	}
+	e = t.newPartition(tenantID)
+	t.partitions[tenantID] = e
+	return e
+}
+
+// full reports whether the partition cap is reached. Callers must hold t.mu.
+func (t *TenantEngine) full() bool {
+	return t.config.MaxTenants > 0 && len(t.partitions) >= t.config.MaxTenants
+}
+
+// Partition returns the engine that evaluates the requests of a tenant,
+// creating its partition on first use: the base engine for requests
+// without a tenant and for tenants beyond MaxTenants.
+func (t *TenantEngine) Partition(tenantID string) *Engine {
+	return t.partition(tenantID)
+}
+
+// Evaluate evaluates a tool request in the partition of agent.TenantID.
+func (t *TenantEngine) Evaluate(ctx context.Context, agent AgentContext, toolName string, request interface{}) (Decision, error) {
+	return t.partition(agent.TenantID).Evaluate(ctx, agent, toolName, request)
+}
+
+// EvaluateWithResult evaluates a tool request in the partition of
+// agent.TenantID and returns the full result (see Engine.EvaluateWithResult).
+func (t *TenantEngine) EvaluateWithResult(ctx context.Context, agent AgentContext, toolName string, request interface{}) (*EvaluationResult, error) {
+	return t.partition(agent.TenantID).EvaluateWithResult(ctx, agent, toolName, request)
+}
+
+// LoadPolicy adds or updates a shared policy for an agent type in the base
+// engine, and so in every partition where the tenant has not overridden it.
+func (t *TenantEngine) LoadPolicy(agentType string, policy *CompiledPolicy) {
+	t.base.LoadPolicy(agentType, policy)
+}
+
+// RemovePolicy removes a shared policy from the base engine, and so from
+// every partition where the tenant has not overridden it.
+func (t *TenantEngine) RemovePolicy(agentType string) {
+	t.base.RemovePolicy(agentType)
+}
+
+// LoadTenantPolicy adds or updates the policy of an agent type for one
+// tenant, overriding the shared policy. Only the tenant's cache is
+// invalidated. Returns an error if the tenant would exceed MaxTenants.
+func (t *TenantEngine) LoadTenantPolicy(tenantID, agentType string, policy *CompiledPolicy) error {
+	if tenantID == "" {
+		return fmt.Errorf("tenant ID is required")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.partitions[tenantID]
+	if !ok && t.full() {
+		return fmt.Errorf("tenant %q: partition limit of %d tenants reached", tenantID, t.config.MaxTenants)
+	}
+
+	if t.overrides[tenantID] == nil {
+		t.overrides[tenantID] = make(map[string]*CompiledPolicy)
+	}
+	t.overrides[tenantID][agentType] = policy
+
+	if !ok {
+		t.partitions[tenantID] = t.newPartition(tenantID)
+		return nil
+	}
+	e.LoadPolicy(agentType, policy)
+	return nil
+}
+
+// RemoveTenantPolicy removes a tenant's policy for an agent type, restoring
+// the shared policy if there is one.
+func (t *TenantEngine) RemoveTenantPolicy(tenantID, agentType string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.overrides[tenantID][agentType]; !ok {
+		return
+	}
+	delete(t.overrides[tenantID], agentType)
+	if len(t.overrides[tenantID]) == 0 {
+		delete(t.overrides, tenantID)
+	}
+
+	e, ok := t.partitions[tenantID]
+	if !ok {
+		return
+	}
+	if shared, ok := t.base.GetPolicy(agentType); ok {
+		e.LoadPolicy(agentType, shared)
+	} else {
+		e.RemovePolicy(agentType)
+	}
+}
+
+// RemoveTenant drops a tenant's partition and policies, releasing its
+// cache. Later requests from the tenant start a fresh partition.
+func (t *TenantEngine) RemoveTenant(tenantID string) {
+	if tenantID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.partitions, tenantID)
+	delete(t.overrides, tenantID)
+}
+
+// Tenant returns the engine of a tenant's partition, if it exists, and
+// the base engine for "".
+func (t *TenantEngine) Tenant(tenantID string) (*Engine, bool) {
+	if tenantID == "" {
+		return t.base, true
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	e, ok := t.partitions[tenantID]
+	return e, ok
+}
+
+// Tenants returns the IDs of the tenant partitions, sorted. The default
+// partition is not included.
+func (t *TenantEngine) Tenants() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	ids := make([]string, 0, len(t.partitions))
+	for id := range t.partitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetMode changes the enforcement mode of the base engine, and so of
+// every partition.
+func (t *TenantEngine) SetMode(mode EnforcementMode) {
+	t.base.SetMode(mode)
+}
+
+// TenantStats returns the statistics of a tenant's partition ("" for the
+// default partition).
+func (t *TenantEngine) TenantStats(tenantID string) (TenantStats, bool) {
+	e, ok := t.Tenant(tenantID)
+	if !ok {
+		return TenantStats{}, false
+	}
+	return partitionStats(e), true
+}
+
+// Stats returns the statistics of every partition by tenant ID, including
+// the default partition under "".
+func (t *TenantEngine) Stats() map[string]TenantStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	stats := make(map[string]TenantStats, len(t.partitions)+1)
+	stats[""] = partitionStats(t.base)
+	for id, e := range t.partitions {
+		stats[id] = partitionStats(e)
+	}
+	return stats
+}
+
+func partitionStats(e *Engine) TenantStats {
+	s := TenantStats{
+		CacheEntries: e.Cache().Size(),
+		Policies:     len(e.ListPolicies()),
+	}
+	s.CacheHits, s.CacheMisses, s.HitRate = e.CacheStats()
+	return s
+}
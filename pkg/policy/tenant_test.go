@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestTenantEngineIsolation verifies tenants have separate policy
+// namespaces and caches, falling back to shared policies
+func TestTenantEngineIsolation(t *testing.T) {
+	engine := NewTenantEngine(TenantEngineConfig{}, WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("shared", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.read", Action: Allow}}, Enforcing, ""))
+	if err := engine.LoadTenantPolicy("tenant-a", "coding-assistant", CompilePolicy("tenant-a", []string{"coding-assistant"}, Deny,
+		[]ToolPermission{{Tool: "file.write", Action: Allow}}, Enforcing, "")); err != nil {
+		t.Fatalf("LoadTenantPolicy failed: %v", err)
+	}
+
+	evaluate := func(tenant, tool string) Decision {
+		t.Helper()
+		decision, err := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", TenantID: tenant}, tool, nil)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		return decision
+	}
+
+	if evaluate("tenant-a", "file.write") != Allow || evaluate("tenant-a", "file.read") != Deny {
+		t.Error("expected tenant-a to use its own policy")
+	}
+	if evaluate("tenant-b", "file.read") != Allow || evaluate("tenant-b", "file.write") != Deny {
+		t.Error("expected tenant-b to use the shared policy")
+	}
+	if evaluate("", "file.read") != Allow {
+		t.Error("expected requests without a tenant to use the shared policy")
+	}
+
+	// Updating tenant-a's policy must leave tenant-b's cache intact
+	evaluate("tenant-b", "file.read")
+	engine.LoadTenantPolicy("tenant-a", "coding-assistant", CompilePolicy("tenant-a-v2", []string{"coding-assistant"}, Deny, nil, Enforcing, ""))
+	if stats, _ := engine.TenantStats("tenant-b"); stats.CacheEntries != 2 {
+		t.Errorf("expected tenant-b cache to be untouched, got %+v", stats)
+	}
+	if stats, _ := engine.TenantStats("tenant-a"); stats.CacheEntries != 0 || stats.Policies != 1 {
+		t.Errorf("expected tenant-a cache to be invalidated, got %+v", stats)
+	}
+	if evaluate("tenant-a", "file.write") != Deny {
+		t.Error("expected tenant-a to use its updated policy")
+	}
+
+	engine.RemoveTenantPolicy("tenant-a", "coding-assistant")
+	if evaluate("tenant-a", "file.read") != Allow {
+		t.Error("expected tenant-a to fall back to the shared policy")
+	}
+
+	if got := engine.Tenants(); len(got) != 2 || got[0] != "tenant-a" || got[1] != "tenant-b" {
+		t.Errorf("expected tenants [tenant-a tenant-b], got %v", got)
+	}
+	stats := engine.Stats()
+	if stats["tenant-b"].CacheHits != 1 || stats["tenant-b"].CacheMisses != 2 {
+		t.Errorf("expected per-tenant cache stats, got %+v", stats["tenant-b"])
+	}
+
+	engine.RemoveTenant("tenant-b")
+	if _, ok := engine.TenantStats("tenant-b"); ok {
+		t.Error("expected tenant-b partition to be removed")
+	}
+}
+
+// TestTenantEngineMaxTenants verifies tenants beyond the cap share the
+// default partition
+func TestTenantEngineMaxTenants(t *testing.T) {
+	engine := NewTenantEngine(TenantEngineConfig{MaxTenants: 1}, WithMode(Enforcing))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("shared", []string{"coding-assistant"}, Allow, nil, Enforcing, ""))
+	policy := CompilePolicy("tenant", []string{"coding-assistant"}, Deny, nil, Enforcing, "")
+
+	if err := engine.LoadTenantPolicy("tenant-a", "coding-assistant", policy); err != nil {
+		t.Fatalf("LoadTenantPolicy failed: %v", err)
+	}
+	if err := engine.LoadTenantPolicy("tenant-b", "coding-assistant", policy); err == nil {
+		t.Error("expected an error beyond MaxTenants")
+	}
+
+	decision, _ := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", TenantID: "tenant-c"}, "file.read", nil)
+	if decision != Allow {
+		t.Errorf("expected tenant beyond the cap to use the default partition, got %v", decision)
+	}
+	if got := engine.Tenants(); len(got) != 1 {
+		t.Errorf("expected one tenant partition, got %v", got)
+	}
+	if stats, _ := engine.TenantStats(""); stats.CacheMisses != 1 {
+		t.Errorf("expected default partition to serve tenant-c, got %+v", stats)
+	}
+}
+
+// TestTenantEngineSharedState verifies that policies, kill switches,
+// canaries, and the mode set on the base engine after a tenant's partition
+// exists reach it, without invalidating its cache by hand
+func TestTenantEngineSharedState(t *testing.T) {
+	base := NewEngine(WithMode(Enforcing))
+	engine := NewTenantEngineFrom(base, TenantEngineConfig{})
+	defer engine.Close()
+	if engine.config.MaxTenants != DefaultMaxTenants {
+		t.Errorf("expected MaxTenants to default to %d, got %d", DefaultMaxTenants, engine.config.MaxTenants)
+	}
+
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "tenant-a", SandboxID: "sandbox-1"}
+	evaluate := func(tool string) Decision {
+		t.Helper()
+		decision, err := engine.Evaluate(context.Background(), agent, tool, nil)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		return decision
+	}
+
+	// The partition is created before the policy is bound in the base
+	if evaluate("file.write") != Deny {
+		t.Fatal("expected a deny without policies")
+	}
+	stable, canary := canaryPolicies()
+	base.LoadPolicy("coding-assistant", stable)
+	if evaluate("file.write") != Allow || evaluate("file.write") != Allow {
+		t.Fatal("expected the base engine's policy to reach the partition")
+	}
+
+	base.LoadCanary("default/tools", canary, 100)
+	if evaluate("file.write") != Deny {
+		t.Error("expected the base engine's canary to reach the partition")
+	}
+	base.RemoveCanary("default/tools")
+	if evaluate("file.read") != Allow {
+		t.Fatal("expected the stable policy after the canary is removed")
+	}
+
+	base.KillTool(KillSwitch{Source: "incident", Tool: "file.read"})
+	if evaluate("file.read") != Deny {
+		t.Error("expected the base engine's kill switch to reach the partition")
+	}
+	base.LiftKill("incident")
+
+	base.SetMode(Permissive)
+	if evaluate("shell.exec") != Allow {
+		t.Error("expected the base engine's mode to reach the partition")
+	}
+
+	base.RemovePolicy("coding-assistant")
+	base.SetMode(Enforcing)
+	if evaluate("file.read") != Deny {
+		t.Error("expected the policy removed from the base engine to be removed from the partition")
+	}
+	if got := engine.Tenants(); len(got) != 1 || got[0] != "tenant-a" {
+		t.Errorf("expected tenant-a's partition, got %v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCategoriesExhausted is returned when every MTS category is allocated.
+var ErrCategoriesExhausted = errors.New("MTS categories exhausted")
+
+// ErrCategoryAllocated is returned when reserving a category another tenant
+// holds.
+var ErrCategoryAllocated = errors.New("MTS category allocated to another tenant")
+
+// CategoryAllocator allocates each tenant an MTS category of its own.
+//
+// GenerateMTSLabel hashes tenant IDs into the category space, so two
+// tenants may share categories, and with them access to each other's
+// objects. The allocator starts from the same hash, so that allocations are
+// stable, but probes past categories other tenants hold, so that no two
+// tenants share one.
+//
+// A CategoryAllocator is not safe for concurrent use.
+type CategoryAllocator struct {
+	owners     map[int]string
+	categories map[string]int
+}
+
+// NewCategoryAllocator returns an allocator with no categories allocated.
+func NewCategoryAllocator() *CategoryAllocator {
+	return &CategoryAllocator{
+		owners:     make(map[int]string),
+		categories: make(map[string]int),
+	}
+}
+
+// Reserve allocates category to a tenant, such as one it was allocated
+// before, releasing any other category the tenant holds. The category must
+// be free or the tenant's already.
+func (a *CategoryAllocator) Reserve(tenantID string, category int) error {
+	if category < 0 || category > MaxCategory {
+		return fmt.Errorf("%w: c%d", ErrCategoryOutOfRange, category)
+	}
+	if owner, ok := a.owners[category]; ok && owner != tenantID {
+		return fmt.Errorf("%w: c%d is allocated to tenant %q", ErrCategoryAllocated, category, owner)
+	}
+	a.Release(tenantID)
+	a.owners[category] = tenantID
+	a.categories[tenantID] = category
+	return nil
+}
+
+// Allocate returns the category of a tenant, allocating it the first free
+// category from its hash if it has none.
+func (a *CategoryAllocator) Allocate(tenantID string) (int, error) {
+	if category, ok := a.categories[tenantID]; ok {
+		return category, nil
+	}
+	start := hashToCategory(tenantID, 0)
+	for i := 0; i <= MaxCategory; i++ {
+		category := (start + i) % (MaxCategory + 1)
+		if _, taken := a.owners[category]; !taken {
+			a.owners[category] = tenantID
+			a.categories[tenantID] = category
+			return category, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: tenant %q", ErrCategoriesExhausted, tenantID)
+}
+
+// Release frees the category of a tenant.
+func (a *CategoryAllocator) Release(tenantID string) {
+	if category, ok := a.categories[tenantID]; ok {
+		delete(a.owners, category)
+		delete(a.categories, tenantID)
+	}
+}
+
+// Category returns the category allocated to a tenant.
+func (a *CategoryAllocator) Category(tenantID string) (int, bool) {
+	category, ok := a.categories[tenantID]
+	return category, ok
+}
+
+// TenantNode is a tenant of a tenant hierarchy.
+type TenantNode struct {
+	// ID identifies the tenant, as AgentContext.TenantID
+	ID string
+
+	// Parent is the ID of the parent tenant, empty for a root tenant
+	Parent string
+
+	// DelegateToParent grants the parent tenant's agents access to the
+	// tenant's objects
+	DelegateToParent bool
+}
+
+// TenantLabels returns the MTS labels of a tenant hierarchy, for the
+// tenants allocated a category in alloc.
+//
+// The object label of a tenant, which its policies carry, holds its own
+// category. The subject label returned here, which its agents are
+// evaluated with, also holds the categories of the subject labels of the
+// children that delegate to it, so that a parent's agents dominate the
+// objects of its delegating descendants, while siblings, children, and
+// non-delegating descendants stay isolated from each other and from the
+// parent. Cycles in the hierarchy are broken; the tenants on them get only
+// the categories reachable without repeating one.
+func TenantLabels(tenants []TenantNode, alloc *CategoryAllocator) map[string]*MTSLabel {
+	children := make(map[string][]string)
+	for _, t := range tenants {
+		if t.Parent != "" && t.Parent != t.ID && t.DelegateToParent {
+			children[t.Parent] = append(children[t.Parent], t.ID)
+		}
+	}
+
+	var collect func(id string, visiting map[string]bool) []int
+	collect = func(id string, visiting map[string]bool) []int {
+		if visiting[id] {
+			return nil
+		}
+		visiting[id] = true
+		defer delete(visiting, id)
+
+		var cats []int
+		if category, ok := alloc.Category(id); ok {
+			cats = append(cats, category)
+		}
+		for _, child := range children[id] {
+			cats = append(cats, collect(child, visiting)...)
+		}
+		return cats
+	}
+
+	labels := make(map[string]*MTSLabel, len(tenants))
+	for _, t := range tenants {
+		if _, ok := alloc.Category(t.ID); !ok {
+			continue
+		}
+		labels[t.ID] = &MTSLabel{
+			Sensitivity: DefaultSensitivity,
+			Categories:  uniqueSorted(collect(t.ID, map[string]bool{})),
+		}
+	}
+	return labels
+}
+
+// TenantObjectLabel returns the object label of a tenant allocated
+// category: the MTS label its policies carry.
+func TenantObjectLabel(category int) *MTSLabel {
+	return &MTSLabel{Sensitivity: DefaultSensitivity, Categories: []int{category}}
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// collidingTenants returns two tenant IDs that hash to the same category.
+func collidingTenants(t *testing.T) (string, string) {
+	t.Helper()
+	seen := make(map[int]string)
+	for i := 0; i < 10*MaxCategory; i++ {
+		id := fmt.Sprintf("tenant-%d", i)
+		category := hashToCategory(id, 0)
+		if other, ok := seen[category]; ok {
+			return other, id
+		}
+		seen[category] = id
+	}
+	t.Fatal("no colliding tenant IDs found")
+	return "", ""
+}
+
+// TestCategoryAllocator tests that tenants whose IDs hash to the same
+// category are allocated distinct ones.
+func TestCategoryAllocator(t *testing.T) {
+	a, b := collidingTenants(t)
+	if hashToCategory(a, 0) != hashToCategory(b, 0) {
+		t.Fatalf("expected %q and %q to collide", a, b)
+	}
+
+	alloc := NewCategoryAllocator()
+	ca, err := alloc.Allocate(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cb, err := alloc.Allocate(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ca == cb {
+		t.Errorf("expected distinct categories, got c%d for both", ca)
+	}
+	if ca != hashToCategory(a, 0) {
+		t.Errorf("expected the first tenant to get its hashed category c%d, got c%d", hashToCategory(a, 0), ca)
+	}
+	if again, _ := alloc.Allocate(a); again != ca {
+		t.Errorf("expected allocations to be stable, got c%d then c%d", ca, again)
+	}
+
+	// A reserved category is kept by its tenant
+	if err := alloc.Reserve(b, ca); !errors.Is(err, ErrCategoryAllocated) {
+		t.Errorf("expected ErrCategoryAllocated, got %v", err)
+	}
+	if err := alloc.Reserve(b, MaxCategory+1); !errors.Is(err, ErrCategoryOutOfRange) {
+		t.Errorf("expected ErrCategoryOutOfRange, got %v", err)
+	}
+	alloc.Release(a)
+	if err := alloc.Reserve(b, ca); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if category, _ := alloc.Category(b); category != ca {
+		t.Errorf("expected c%d, got c%d", ca, category)
+	}
+	if category, _ := alloc.Allocate(a); category == ca {
+		t.Errorf("expected the released tenant to move off c%d", ca)
+	}
+
+	// Every category is allocated once
+	full := NewCategoryAllocator()
+	for i := 0; i <= MaxCategory; i++ {
+		if _, err := full.Allocate(fmt.Sprintf("tenant-%d", i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := full.Allocate("one-too-many"); !errors.Is(err, ErrCategoriesExhausted) {
+		t.Errorf("expected ErrCategoriesExhausted, got %v", err)
+	}
+}
+
+// TestTenantLabels tests that parents dominate the objects of delegating
+// descendants only.
+func TestTenantLabels(t *testing.T) {
+	tenants := []TenantNode{
+		{ID: "org"},
+		{ID: "team-a", Parent: "org", DelegateToParent: true},
+		{ID: "team-a-ci", Parent: "team-a", DelegateToParent: true},
+		{ID: "team-b", Parent: "org"},
+		{ID: "loop-1", Parent: "loop-2", DelegateToParent: true},
+		{ID: "loop-2", Parent: "loop-1", DelegateToParent: true},
+	}
+	alloc := NewCategoryAllocator()
+	for i, tenant := range tenants {
+		if err := alloc.Reserve(tenant.ID, i); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	labels := TenantLabels(tenants, alloc)
+
+	want := map[string]string{
+		"org":       "s0:c0.c2",
+		"team-a":    "s0:c1,c2",
+		"team-a-ci": "s0:c2",
+		"team-b":    "s0:c3",
+		"loop-1":    "s0:c4,c5",
+		"loop-2":    "s0:c4,c5",
+	}
+	for id, label := range want {
+		if got := labels[id].String(); got != label {
+			t.Errorf("%s: expected %s, got %s", id, label, got)
+		}
+	}
+
+	tests := []struct {
+		subject, object string
+		want            bool
+	}{
+		{"org", "team-a", true},
+		{"org", "team-a-ci", true},
+		{"team-a", "team-a-ci", true},
+		{"org", "team-b", false},
+		{"team-a", "org", false},
+		{"team-a-ci", "team-a", false},
+		{"team-a", "team-b", false},
+	}
+	for _, tt := range tests {
+		category, _ := alloc.Category(tt.object)
+		if got := labels[tt.subject].CanAccess(TenantObjectLabel(category)); got != tt.want {
+			t.Errorf("%s accessing %s: expected %v, got %v", tt.subject, tt.object, tt.want, got)
+		}
+	}
+}
+
+// TestMTSDominates tests that only labels resolved from the registry
+// dominate the labels of other policies.
+func TestMTSDominates(t *testing.T) {
+	reg := NewTenantRegistry(LabelMismatchDeny)
+	reg.SetLabel("org", "s0:c0,c1")
+	engine := NewEngine(WithMode(Enforcing), WithTenantRegistry(reg))
+
+	// A claimed child label within the parent's is consistent
+	agent, err := engine.resolveLabel(context.Background(), AgentContext{TenantID: "org", MTSLabel: "s0:c1"}, "file.read", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agent.MTSLabel != "s0:c0,c1" {
+		t.Errorf("expected the registry label, got %q", agent.MTSLabel)
+	}
+	if !mtsDominates(agent, "s0:c1") {
+		t.Error("expected the parent to dominate the child's policy")
+	}
+	if mtsDominates(agent, "s0:c2") {
+		t.Error("expected other tenants' policies not to be dominated")
+	}
+
+	claimed := AgentContext{TenantID: "other", MTSLabel: "s0:c0.c1023"}
+	if mtsDominates(claimed, "s0:c1") {
+		t.Error("expected a claimed label not to dominate")
+	}
+}
@@ -0,0 +1,80 @@
+package policy
+
+import "sync"
+
+// tenantModeStore holds the enforcement modes of tenants that override the
+// engine's mode.
+type tenantModeStore struct {
+	mu    sync.RWMutex
+	modes map[string]EnforcementMode
+}
+
+// SetTenantMode overrides the enforcement mode for the calls of a tenant,
+// such as to keep a tenant that is still onboarding permissive while the
+// others are enforced.
+func (e *Engine) SetTenantMode(tenantID string, mode EnforcementMode) {
+	e.tenantModes.mu.Lock()
+	if e.tenantModes.modes == nil {
+		e.tenantModes.modes = make(map[string]EnforcementMode)
+	}
+	e.tenantModes.modes[tenantID] = mode
+	e.tenantModes.mu.Unlock()
+	e.modeChanged()
+}
+
+// RemoveTenantMode removes the mode override of a tenant, whose calls then
+// follow the engine's mode.
+func (e *Engine) RemoveTenantMode(tenantID string) {
+	e.tenantModes.mu.Lock()
+	_, ok := e.tenantModes.modes[tenantID]
+	delete(e.tenantModes.modes, tenantID)
+	e.tenantModes.mu.Unlock()
+	if ok {
+		e.modeChanged()
+	}
+}
+
+// ReplaceTenantModes atomically replaces the mode overrides of all
+// tenants, by tenant ID.
+func (e *Engine) ReplaceTenantModes(modes map[string]EnforcementMode) {
+	replaced := make(map[string]EnforcementMode, len(modes))
+	for tenantID, mode := range modes {
+		replaced[tenantID] = mode
+	}
+	e.tenantModes.mu.Lock()
+	e.tenantModes.modes = replaced
+	e.tenantModes.mu.Unlock()
+	e.modeChanged()
+}
+
+// TenantModes returns the mode overrides of tenants, by tenant ID.
+func (e *Engine) TenantModes() map[string]EnforcementMode {
+	e.tenantModes.mu.RLock()
+	defer e.tenantModes.mu.RUnlock()
+	modes := make(map[string]EnforcementMode, len(e.tenantModes.modes))
+	for tenantID, mode := range e.tenantModes.modes {
+		modes[tenantID] = mode
+	}
+	return modes
+}
+
+// EffectiveMode returns the enforcement mode of an agent's calls: the
+// override of its tenant, if any, else the engine's mode.
+func (e *Engine) EffectiveMode(agent AgentContext) EnforcementMode {
+	if agent.TenantID != "" {
+		e.tenantModes.mu.RLock()
+		mode, ok := e.tenantModes.modes[agent.TenantID]
+		e.tenantModes.mu.RUnlock()
+		if ok {
+			return mode
+		}
+	}
+	return e.Mode()
+}
+
+// modeChanged notifies subscribers and hooks of a change of mode. The
+// mode is applied after the cache, so cached decisions stay valid.
+func (e *Engine) modeChanged() {
+	e.notifier.notify()
+	e.hooks.call(FallbackAgentType)
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestTenantMode tests that tenants with a mode of their own are enforced
+// in it, that the others follow the engine's mode, and that audit events
+// record the mode each decision was applied in.
+func TestTenantMode(t *testing.T) {
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Permissive), WithAuditSink(sink))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("team", []string{"coding-assistant"}, Deny, nil, Enforcing, ""))
+	engine.SetTenantMode("tenant-a", Enforcing)
+
+	for _, tc := range []struct {
+		tenant   string
+		decision Decision
+		mode     EnforcementMode
+	}{
+		{"tenant-a", Deny, Enforcing},
+		{"tenant-b", Allow, Permissive},
+		{"", Allow, Permissive},
+	} {
+		agent := AgentContext{AgentType: "coding-assistant", TenantID: tc.tenant}
+		decision, err := engine.Evaluate(context.Background(), agent, "shell.exec", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision != tc.decision {
+			t.Errorf("tenant %q: expected %s, got %s", tc.tenant, tc.decision, decision)
+		}
+		event := <-sink.Events()
+		if event.Decision != Deny || event.Mode != tc.mode {
+			t.Errorf("tenant %q: expected a denial audited in %s mode, got %s in %s mode", tc.tenant, tc.mode, event.Decision, event.Mode)
+		}
+		if !strings.Contains(formatAVC(event), " mode="+tc.mode.String()) {
+			t.Errorf("tenant %q: expected the AVC record to carry the mode, got %s", tc.tenant, formatAVC(event))
+		}
+		if got := newJSONAuditEvent(event).Mode; got != tc.mode.String() {
+			t.Errorf("tenant %q: expected JSON mode %s, got %q", tc.tenant, tc.mode, got)
+		}
+	}
+
+	// Tenants keep their mode when the engine's changes, until it is removed
+	engine.SetMode(Enforcing)
+	engine.ReplaceTenantModes(map[string]EnforcementMode{"tenant-b": Permissive})
+	agent := AgentContext{AgentType: "coding-assistant", TenantID: "tenant-b"}
+	if mode := engine.EffectiveMode(agent); mode != Permissive {
+		t.Errorf("expected tenant-b to stay permissive, got %s", mode)
+	}
+	engine.RemoveTenantMode("tenant-b")
+	if mode := engine.EffectiveMode(agent); mode != Enforcing {
+		t.Errorf("expected tenant-b to follow the engine once its mode is removed, got %s", mode)
+	}
+	if modes := engine.TenantModes(); len(modes) != 0 {
+		t.Errorf("expected no tenant modes, got %v", modes)
+	}
+}
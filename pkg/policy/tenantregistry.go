@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// LabelMismatchAction is how the engine treats calls whose claimed MTS
+// label differs from the label the TenantRegistry holds for their tenant.
+type LabelMismatchAction int
+
+const (
+	// LabelMismatchFlag evaluates the call with the registry label and
+	// logs the mismatch as a warning
+	LabelMismatchFlag LabelMismatchAction = iota
+	// LabelMismatchDeny denies the call with ErrMTSViolation
+	LabelMismatchDeny
+)
+
+func (a LabelMismatchAction) String() string {
+	switch a {
+	case LabelMismatchFlag:
+		return "flag"
+	case LabelMismatchDeny:
+		return "deny"
+	default:
+		return "unknown"
+	}
+}
+
+// TenantRegistry is the server-side authority for MTS labels: it maps
+// tenant IDs to their labels, so that the engine evaluates calls with the
+// label of their tenant rather than the one they claim. It is filled by
+// the SandboxClaim controller, or directly with SetLabel.
+//
+// Calls from tenants the registry has no label for keep their claimed
+// label.
+type TenantRegistry struct {
+	mu     sync.RWMutex
+	labels map[string]string
+	action LabelMismatchAction
+}
+
+// NewTenantRegistry returns an empty registry that treats label mismatches
+// with action.
+func NewTenantRegistry(action LabelMismatchAction) *TenantRegistry {
+	return &TenantRegistry{labels: make(map[string]string), action: action}
+}
+
+// WithTenantRegistry resolves the MTS labels of calls with reg.
+func WithTenantRegistry(reg *TenantRegistry) Option {
+	return func(e *Engine) {
+		e.tenants = reg
+	}
+}
+
+// SetLabel sets the MTS label of a tenant. The label must be valid (see
+// ParseMTSLabel).
+func (r *TenantRegistry) SetLabel(tenantID, label string) error {
+	if _, err := ParseMTSLabel(label); err != nil {
+		return fmt.Errorf("tenant %q: %w: %q", tenantID, err, label)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.labels[tenantID] = label
+	return nil
+}
+
+// RemoveLabel removes the MTS label of a tenant.
+func (r *TenantRegistry) RemoveLabel(tenantID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.labels, tenantID)
+}
+
+// Label returns the MTS label of a tenant.
+func (r *TenantRegistry) Label(tenantID string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	label, ok := r.labels[tenantID]
+	return label, ok
+}
+
+// Tenants returns the tenants with a label, sorted.
+func (r *TenantRegistry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenants := make([]string, 0, len(r.labels))
+	for tenant := range r.labels {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// resolveLabel replaces the claimed MTS label of an agent with the label
+// the registry holds for its tenant. A claimed label the tenant's does not
+// contain is flagged, or denied with ErrMTSViolation.
+func (e *Engine) resolveLabel(ctx context.Context, agent AgentContext, toolName, requestID string) (AgentContext, error) {
+	if e.tenants == nil || agent.TenantID == "" {
+		return agent, nil
+	}
+	label, ok := e.tenants.Label(agent.TenantID)
+	if !ok {
+		return agent, nil
+	}
+
+	if agent.MTSLabel != "" && agent.MTSLabel != label && !labelContains(label, agent.MTSLabel) {
+		if e.tenants.action == LabelMismatchDeny {
+			return agent, fmt.Errorf("%w: claimed label %q is not the label %q of tenant %q",
+				policyerrors.ErrMTSViolation, agent.MTSLabel, label, agent.TenantID)
+		}
+		e.log.LogAttrs(ctx, slog.LevelWarn, "claimed MTS label overridden",
+			slog.String(LogKeyRequestID, requestID),
+			slog.String(LogKeyAgentType, agent.AgentType),
+			slog.String(LogKeyTool, toolName),
+			slog.String("tenant", agent.TenantID),
+			slog.String("claimed", agent.MTSLabel),
+			slog.String("label", label),
+		)
+	}
+	agent.MTSLabel = label
+	agent.labelResolved = true
+	return agent, nil
+}
+
+// labelContains reports whether the MTS label outer contains inner, such as
+// a tenant's label the object label of one of its policies.
+func labelContains(outer, inner string) bool {
+	o, err := ParseMTSLabel(outer)
+	if err != nil {
+		return false
+	}
+	i, err := ParseMTSLabel(inner)
+	if err != nil {
+		return false
+	}
+	return o.Contains(i)
+}
+
+// mtsDominates reports whether the MTS label of an agent dominates a
+// policy's label (see MTSLabel.CanAccess). Only labels resolved from the
+// TenantRegistry may dominate: a claimed label could claim any categories.
+func mtsDominates(agent AgentContext, policyLabel string) bool {
+	if !agent.labelResolved || policyLabel == "" {
+		return false
+	}
+	subject, err := ParseMTSLabel(agent.MTSLabel)
+	if err != nil {
+		return false
+	}
+	object, err := ParseMTSLabel(policyLabel)
+	if err != nil {
+		return false
+	}
+	return subject.CanAccess(object)
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// TestTenantRegistry tests that calls are evaluated with the label of
+// their tenant, and that differing claimed labels are flagged or denied.
+func TestTenantRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  LabelMismatchAction
+		claimed string
+		want    Decision
+	}{
+		{"registry label", LabelMismatchDeny, "s0:c1,c2", Allow},
+		{"omitted label", LabelMismatchDeny, "", Allow},
+		{"flagged mismatch", LabelMismatchFlag, "s0:c1,c2,c3", Allow},
+		{"denied mismatch", LabelMismatchDeny, "s0:c1,c2,c3", Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTenantRegistry(tt.action)
+			if err := reg.SetLabel("team-a", "s0:c1,c2"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sink := NewChannelAuditSink(10)
+			engine := NewEngine(WithMode(Enforcing), WithAuditSink(sink), WithTenantRegistry(reg))
+			engine.LoadPolicy("coding-assistant", CompilePolicy("mts-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, ""))
+
+			agent := AgentContext{AgentType: "coding-assistant", TenantID: "team-a", MTSLabel: tt.claimed}
+			result, err := engine.EvaluateWithResult(context.Background(), agent, "file.read", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Decision != tt.want {
+				t.Fatalf("expected %v, got %v (%s)", tt.want, result.Decision, result.Reason)
+			}
+			if tt.want == Deny {
+				if !errors.Is(result.Err, policyerrors.ErrMTSViolation) {
+					t.Errorf("expected ErrMTSViolation, got %v", result.Err)
+				}
+				return
+			}
+			if event := <-sink.Events(); event.Agent.MTSLabel != "s0:c1,c2" {
+				t.Errorf("expected the registry label to be audited, got %q", event.Agent.MTSLabel)
+			}
+		})
+	}
+
+	reg := NewTenantRegistry(LabelMismatchDeny)
+	if err := reg.SetLabel("team-a", "c1"); !errors.Is(err, ErrInvalidMTSLabel) {
+		t.Errorf("expected ErrInvalidMTSLabel, got %v", err)
+	}
+	reg.SetLabel("team-b", "s0:c3")
+	reg.SetLabel("team-a", "s0:c1")
+	reg.RemoveLabel("team-b")
+	if tenants := reg.Tenants(); len(tenants) != 1 || tenants[0] != "team-a" {
+		t.Errorf("expected [team-a], got %v", tenants)
+	}
+
+	// Tenants without a label keep their claimed one
+	engine := NewEngine(WithMode(Enforcing), WithTenantRegistry(reg))
+	engine.LoadPolicy("coding-assistant", CompilePolicy("mts-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, ""))
+	if decision, _ := engine.Evaluate(context.Background(), AgentContext{AgentType: "coding-assistant", TenantID: "team-c", MTSLabel: "s0:c9"}, "file.read", nil); decision != Allow {
+		t.Errorf("expected a tenant without a label to be allowed, got %v", decision)
+	}
+}
+
+// TestTenantRegistryCache tests that a cached decision of an agent is not
+// reused for an agent of the same type with another tenant or label.
+func TestTenantRegistryCache(t *testing.T) {
+	reg := NewTenantRegistry(LabelMismatchDeny)
+	reg.SetLabel("team-a", "s0:c1,c2")
+	reg.SetLabel("team-b", "s0:c3,c4")
+	engine := NewEngine(WithMode(Enforcing), WithOPA(true), WithTenantRegistry(reg))
+	policy, err := CompilePolicyWithOPA("mts-policy", []string{"coding-assistant"}, Allow, nil, Enforcing, "s0:c1,c2", `package agentpolicy
+
+import rego.v1
+
+default decision := {"allow": false, "deny": false, "mts": false, "reason": "MTS label mismatch"}
+
+decision := {"allow": true, "deny": false, "mts": true, "reason": "allowed"} if {
+	input.agent.mts_label == input.policy.mts_label
+}
+`)
+	if err != nil {
+		t.Fatalf("CompilePolicyWithOPA failed: %v", err)
+	}
+	engine.LoadPolicy("coding-assistant", policy)
+
+	// The cache is not invalidated between calls
+	tests := []struct {
+		name  string
+		agent AgentContext
+		want  Decision
+	}{
+		{"matching tenant", AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}, Allow},
+		{"other tenant", AgentContext{AgentType: "coding-assistant", TenantID: "team-b"}, Deny},
+		{"other claimed label", AgentContext{AgentType: "coding-assistant", MTSLabel: "s0:c3,c4"}, Deny},
+		{"matching claimed label", AgentContext{AgentType: "coding-assistant", MTSLabel: "s0:c1,c2"}, Allow},
+		{"no label", AgentContext{AgentType: "coding-assistant"}, Deny},
+		{"matching tenant again", AgentContext{AgentType: "coding-assistant", TenantID: "team-a"}, Allow},
+	}
+	for _, tt := range tests {
+		if decision, _ := engine.Evaluate(context.Background(), tt.agent, "file.read", nil); decision != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, decision)
+		}
+	}
+	if hits, _, _ := engine.CacheStats(); hits != 1 {
+		t.Errorf("expected only the repeated call to hit the cache, got %d hits", hits)
+	}
+}
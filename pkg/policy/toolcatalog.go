@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RiskLevel rates how much harm calls to a tool can do.
+type RiskLevel string
+
+const (
+	RiskLow      RiskLevel = "low"
+	RiskMedium   RiskLevel = "medium"
+	RiskHigh     RiskLevel = "high"
+	RiskCritical RiskLevel = "critical"
+)
+
+// CatalogTool is what the tool catalog knows of a tool.
+type CatalogTool struct {
+	// Name is the canonical tool name
+	Name string
+
+	// Description says what the tool does
+	Description string
+
+	// Category groups related tools (e.g., "filesystem")
+	Category string
+
+	// RiskLevel rates the tool ("" if unrated)
+	RiskLevel RiskLevel
+
+	// Parameters is the JSON Schema of the tool's parameters (nil if not
+	// declared)
+	Parameters map[string]interface{}
+}
+
+// ToolCatalogSet is the tools declared by one source, such as a
+// ToolCatalog resource.
+type ToolCatalogSet struct {
+	// Source identifies who declared the tools
+	Source string
+
+	// Tools are the declared tools
+	Tools []CatalogTool
+}
+
+// ToolCatalog is the set of tools known to the cluster, as platform teams
+// declare them. It is safe for concurrent use.
+type ToolCatalog struct {
+	mu    sync.RWMutex
+	tools map[string]CatalogTool
+}
+
+// NewToolCatalog returns an empty catalog.
+func NewToolCatalog() *ToolCatalog {
+	return &ToolCatalog{tools: make(map[string]CatalogTool)}
+}
+
+// Replace replaces all tools of the catalog with those of sets, at once.
+// A tool may be declared by one source only: sets are applied in order,
+// and the tools a set redeclares are left out of it. It returns, by index,
+// an error naming the tools left out of each set, and nil for sets applied
+// whole.
+func (c *ToolCatalog) Replace(sets []ToolCatalogSet) []error {
+	tools := make(map[string]CatalogTool)
+	sources := make(map[string]string)
+	errs := make([]error, len(sets))
+	for i, set := range sets {
+		var duplicates []string
+		for _, tool := range set.Tools {
+			if source, ok := sources[tool.Name]; ok && source != set.Source {
+				duplicates = append(duplicates, fmt.Sprintf("%s (declared by %s)", tool.Name, source))
+				continue
+			}
+			tools[tool.Name] = tool
+			sources[tool.Name] = set.Source
+		}
+		if len(duplicates) > 0 {
+			errs[i] = fmt.Errorf("tools already declared: %s", strings.Join(duplicates, ", "))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = tools
+	return errs
+}
+
+// Lookup returns what the catalog knows of a tool.
+func (c *ToolCatalog) Lookup(name string) (CatalogTool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tool, ok := c.tools[name]
+	return tool, ok
+}
+
+// Len returns the number of tools in the catalog.
+func (c *ToolCatalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.tools)
+}
+
+// Unknown returns the tools of names the catalog does not declare, sorted
+// and once each. An empty catalog knows every tool, so that clusters that
+// declare none are not warned of any.
+func (c *ToolCatalog) Unknown(names []string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.tools) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, name := range names {
+		if _, ok := c.tools[name]; !ok && !seen[name] {
+			seen[name] = true
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestToolCatalog tests that sources keep the tools they declare first,
+// and the detection of unknown tools.
+func TestToolCatalog(t *testing.T) {
+	catalog := NewToolCatalog()
+	if unknown := catalog.Unknown([]string{"file.read"}); unknown != nil {
+		t.Errorf("expected an empty catalog to know every tool, got %v", unknown)
+	}
+
+	errs := catalog.Replace([]ToolCatalogSet{
+		{Source: "platform", Tools: []CatalogTool{{Name: "file.read", Category: "filesystem", RiskLevel: RiskLow}}},
+		{Source: "network", Tools: []CatalogTool{
+			{Name: "network.fetch", RiskLevel: RiskHigh},
+			{Name: "file.read", Category: "network"},
+		}},
+	})
+	if errs[0] != nil || errs[1] == nil || !strings.Contains(errs[1].Error(), "file.read (declared by platform)") {
+		t.Errorf("expected the second set to redeclare file.read, got %v", errs)
+	}
+	if tool, ok := catalog.Lookup("file.read"); !ok || tool.Category != "filesystem" {
+		t.Errorf("expected the first declaration to be kept, got %+v", tool)
+	}
+	if tool, ok := catalog.Lookup("network.fetch"); !ok || tool.RiskLevel != RiskHigh {
+		t.Errorf("expected the rest of the second set to be applied, got %+v", tool)
+	}
+	if catalog.Len() != 2 {
+		t.Errorf("expected 2 tools, got %d", catalog.Len())
+	}
+
+	unknown := catalog.Unknown([]string{"shell.exec", "file.read", "file.raed", "shell.exec"})
+	if strings.Join(unknown, ",") != "file.raed,shell.exec" {
+		t.Errorf("expected file.raed,shell.exec to be unknown, got %v", unknown)
+	}
+
+	catalog.Replace(nil)
+	if _, ok := catalog.Lookup("file.read"); ok {
+		t.Error("expected the replaced catalog to be empty")
+	}
+}
@@ -0,0 +1,298 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ToolNameRule is how tool names called by agents are rewritten to the
+// canonical names policies are written in, when no alias applies.
+type ToolNameRule string
+
+const (
+	// ToolNamesConvert lowercases dotted names and converts CamelCase and
+	// snake_case names to dotted ones ("FileRead" and "file_read" become
+	// "file.read"). It is the default. Distinct names may collide.
+	ToolNamesConvert ToolNameRule = "convert"
+
+	// ToolNamesLowercase only lowercases names.
+	ToolNamesLowercase ToolNameRule = "lowercase"
+
+	// ToolNamesExact keeps names as they are called.
+	ToolNamesExact ToolNameRule = "exact"
+)
+
+// ParseToolNameRule parses a ToolNameRule.
+func ParseToolNameRule(s string) (ToolNameRule, error) {
+	switch r := ToolNameRule(s); r {
+	case ToolNamesConvert, ToolNamesLowercase, ToolNamesExact:
+		return r, nil
+	}
+	return "", fmt.Errorf("invalid tool name rule %q: must be convert, lowercase, or exact", s)
+}
+
+// ToolNormalizer maps the raw tool names agents call to canonical tool
+// names: by alias first, such as those of ToolAlias resources, and
+// otherwise by its ToolNameRule. It is safe for concurrent use.
+type ToolNormalizer struct {
+	rule ToolNameRule
+
+	mu sync.RWMutex
+	// aliases maps raw names to canonical ones, and sources raw names to
+	// the source (e.g., the ToolAlias) that set them
+	aliases map[string]string
+	sources map[string]string
+}
+
+// NewToolNormalizer returns a normalizer without aliases that applies
+// rule (ToolNamesConvert if empty).
+func NewToolNormalizer(rule ToolNameRule) *ToolNormalizer {
+	if rule == "" {
+		rule = ToolNamesConvert
+	}
+	return &ToolNormalizer{rule: rule, aliases: make(map[string]string), sources: make(map[string]string)}
+}
+
+// Rule returns the normalizer's rule.
+func (n *ToolNormalizer) Rule() ToolNameRule {
+	return n.rule
+}
+
+// Normalize returns the canonical name of a raw tool name: the tool it is
+// an alias of, or else the name rewritten by the rule, unless that is an
+// alias. Empty names stay empty.
+func (n *ToolNormalizer) Normalize(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if tool, ok := n.aliases[raw]; ok {
+		return tool
+	}
+	name := n.applyRule(raw)
+	if tool, ok := n.aliases[name]; ok {
+		return tool
+	}
+	return name
+}
+
+// applyRule rewrites a raw name by the normalizer's rule.
+func (n *ToolNormalizer) applyRule(raw string) string {
+	switch n.rule {
+	case ToolNamesExact:
+		return raw
+	case ToolNamesLowercase:
+		return strings.ToLower(raw)
+	}
+
+	// Already in correct format
+	if strings.Contains(raw, ".") {
+		return strings.ToLower(raw)
+	}
+
+	// Convert CamelCase to dot notation
+	// FileRead -> file.read
+	var result strings.Builder
+	for i, r := range raw {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			result.WriteRune('.')
+		}
+		result.WriteRune(r)
+	}
+
+	// Convert snake_case to dot notation
+	normalized := strings.ToLower(result.String())
+	return strings.ReplaceAll(normalized, "_", ".")
+}
+
+// SetAliases replaces the aliases set by source with aliases of tool. A
+// raw name may be the alias of one tool only: if another source already
+// maps one of aliases to a different tool, nothing changes and an error
+// names the conflict. tool must be canonical, i.e. normalize to itself
+// without aliases.
+func (n *ToolNormalizer) SetAliases(source, tool string, aliases []string) error {
+	if err := n.checkCanonical(tool); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return setAliases(n.aliases, n.sources, source, tool, aliases)
+}
+
+// ToolAliasSet is the aliases of a tool set by one source.
+type ToolAliasSet struct {
+	// Source identifies who set the aliases (e.g., the ToolAlias name)
+	Source string
+
+	// Tool is the canonical tool name
+	Tool string
+
+	// Aliases are the raw names of Tool
+	Aliases []string
+}
+
+// ReplaceAliases replaces all aliases of the normalizer with sets, at
+// once. Sets are applied in order, so earlier sets keep the aliases later
+// ones conflict with; it returns the error of each set that was not
+// applied, by index, and nil for those that were.
+func (n *ToolNormalizer) ReplaceAliases(sets []ToolAliasSet) []error {
+	aliases, sources := make(map[string]string), make(map[string]string)
+	errs := make([]error, len(sets))
+	for i, set := range sets {
+		if errs[i] = n.checkCanonical(set.Tool); errs[i] != nil {
+			continue
+		}
+		errs[i] = setAliases(aliases, sources, set.Source, set.Tool, set.Aliases)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.aliases, n.sources = aliases, sources
+	return errs
+}
+
+// checkCanonical checks that tool normalizes to itself by the rule.
+func (n *ToolNormalizer) checkCanonical(tool string) error {
+	if tool == "" || n.applyRule(tool) != tool {
+		return fmt.Errorf("tool %q is not a canonical tool name: calls to it are normalized to %q", tool, n.applyRule(tool))
+	}
+	return nil
+}
+
+// setAliases replaces the aliases source set in the aliases and sources
+// maps of a normalizer with aliases of tool, unless they conflict with
+// those of other sources.
+func setAliases(aliases, sources map[string]string, source, tool string, raws []string) error {
+	// Aliases are not chained: a tool may not be an alias, nor an alias a
+	// tool, of another source
+	if other, ok := sources[tool]; ok && other != source {
+		return fmt.Errorf("tool %q is itself an alias of %s (set by %s)", tool, aliases[tool], other)
+	}
+	for _, raw := range raws {
+		if raw == tool {
+			return fmt.Errorf("alias %q is the tool itself", raw)
+		}
+		if other, ok := sources[raw]; ok && other != source && aliases[raw] != tool {
+			return fmt.Errorf("alias %q is already an alias of %s (set by %s)", raw, aliases[raw], other)
+		}
+		for r, target := range aliases {
+			if target == raw && sources[r] != source {
+				return fmt.Errorf("alias %q is itself a tool with aliases (set by %s)", raw, sources[r])
+			}
+		}
+	}
+	removeAliases(aliases, sources, source)
+	for _, raw := range raws {
+		aliases[raw] = tool
+		sources[raw] = source
+	}
+	return nil
+}
+
+// RemoveAliases removes the aliases set by source.
+func (n *ToolNormalizer) RemoveAliases(source string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	removeAliases(n.aliases, n.sources, source)
+}
+
+func removeAliases(aliases, sources map[string]string, source string) {
+	for raw, s := range sources {
+		if s == source {
+			delete(sources, raw)
+			delete(aliases, raw)
+		}
+	}
+}
+
+// ToolNameCollision is a tool rule of a policy that no call can match,
+// because calls to its tool are normalized to another name.
+type ToolNameCollision struct {
+	// Tool is the tool of the rule
+	Tool string
+
+	// Normalized is the name calls to Tool are evaluated as
+	Normalized string
+
+	// Shadowed is set if the policy also has a rule for Normalized, which
+	// decides calls to Tool
+	Shadowed bool
+}
+
+// String describes the collision.
+func (c ToolNameCollision) String() string {
+	if c.Shadowed {
+		return fmt.Sprintf("tool %s is normalized to %s, whose rule decides its calls", c.Tool, c.Normalized)
+	}
+	return fmt.Sprintf("tool %s is normalized to %s, so its rule never matches", c.Tool, c.Normalized)
+}
+
+// Collisions returns the tool rules of a policy that no call can match,
+// sorted by tool.
+func (n *ToolNormalizer) Collisions(p *CompiledPolicy) []ToolNameCollision {
+	var collisions []ToolNameCollision
+	for tool := range p.ToolTable {
+		if normalized := n.Normalize(tool); normalized != tool {
+			_, shadowed := p.ToolTable[normalized]
+			collisions = append(collisions, ToolNameCollision{Tool: tool, Normalized: normalized, Shadowed: shadowed})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Tool < collisions[j].Tool
+	})
+	return collisions
+}
+
+// WithToolNormalizer sets the normalizer of the tool names the engine's
+// callers evaluate, whose collisions with the tool rules of policies are
+// logged when they are loaded (default: NewToolNormalizer(ToolNamesConvert)).
+// The engine evaluates tool names as they are passed.
+func WithToolNormalizer(n *ToolNormalizer) Option {
+	return func(e *Engine) {
+		e.tools = n
+	}
+}
+
+// ToolNormalizer returns the engine's tool name normalizer.
+func (e *Engine) ToolNormalizer() *ToolNormalizer {
+	return e.tools
+}
+
+// ToolNameCollisions returns the tool rules of a policy that calls cannot
+// match under the engine's tool name normalizer (see
+// ToolNormalizer.Collisions).
+func (e *Engine) ToolNameCollisions(p *CompiledPolicy) []ToolNameCollision {
+	return e.tools.Collisions(p)
+}
+
+// logToolNameCollisions warns of the tool rules of a loaded policy that
+// calls cannot match.
+func (e *Engine) logToolNameCollisions(agentType string, p *CompiledPolicy) {
+	for _, c := range e.tools.Collisions(p) {
+		e.log.Warn("tool rule collides with tool name normalization", LogKeyAgentType, agentType, "policy", p.Name,
+			LogKeyTool, c.Tool, "normalized", c.Normalized, "shadowed", c.Shadowed)
+	}
+}
+
+type rawToolNameKey struct{}
+
+// ContextWithRawToolName returns a context carrying the tool name a call
+// was made with, before normalization. The engine records it in the audit
+// event of the call if it differs from the evaluated name.
+func ContextWithRawToolName(ctx context.Context, raw string) context.Context {
+	return context.WithValue(ctx, rawToolNameKey{}, raw)
+}
+
+// rawToolName returns the raw tool name set by ContextWithRawToolName, if
+// it differs from tool.
+func rawToolName(ctx context.Context, tool string) string {
+	if raw, ok := ctx.Value(rawToolNameKey{}).(string); ok && raw != tool {
+		return raw
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+// TestToolNormalizer tests the normalization rules and aliases.
+func TestToolNormalizer(t *testing.T) {
+	for rule, want := range map[ToolNameRule]map[string]string{
+		ToolNamesConvert:   {"file.read": "file.read", "File.Read": "file.read", "FileRead": "file.read", "file_read": "file.read", "": ""},
+		ToolNamesLowercase: {"File.Read": "file.read", "FileRead": "fileread", "file_read": "file_read"},
+		ToolNamesExact:     {"File.Read": "File.Read", "FileRead": "FileRead"},
+	} {
+		n := NewToolNormalizer(rule)
+		for raw, normalized := range want {
+			if got := n.Normalize(raw); got != normalized {
+				t.Errorf("%s: expected %q to normalize to %q, got %q", rule, raw, normalized, got)
+			}
+		}
+	}
+	if _, err := ParseToolNameRule("snake"); err == nil {
+		t.Error("expected an unknown rule to be rejected")
+	}
+
+	n := NewToolNormalizer("")
+	if err := n.SetAliases("file-read", "file.read", []string{"readFile", "fs.read"}); err != nil {
+		t.Fatal(err)
+	}
+	// Aliases match raw names, and names as the rule rewrites them
+	for raw, want := range map[string]string{"readFile": "file.read", "fs.read": "file.read", "FS.Read": "file.read", "read.file": "read.file"} {
+		if got := n.Normalize(raw); got != want {
+			t.Errorf("expected %q to normalize to %q, got %q", raw, want, got)
+		}
+	}
+
+	if err := n.SetAliases("other", "file.open", []string{"readFile"}); err == nil {
+		t.Error("expected an alias of another tool to conflict")
+	}
+	if err := n.SetAliases("chained", "fs.read", []string{"fsRead"}); err == nil {
+		t.Error("expected an alias to be rejected as a tool")
+	}
+	if err := n.SetAliases("camel", "FileOpen", []string{"open"}); err == nil {
+		t.Error("expected a tool that is not canonical to be rejected")
+	}
+	if got := n.Normalize("readFile"); got != "file.read" {
+		t.Errorf("expected rejected aliases to leave the others, got %q", got)
+	}
+
+	// Sources replace their own aliases
+	if err := n.SetAliases("file-read", "file.read", []string{"fs.read"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Normalize("readFile"); got != "read.file" {
+		t.Errorf("expected the replaced alias to be removed, got %q", got)
+	}
+	n.RemoveAliases("file-read")
+	if got := n.Normalize("fs.read"); got != "fs.read" {
+		t.Errorf("expected the removed alias to be removed, got %q", got)
+	}
+
+	// Earlier sets win conflicts
+	errs := n.ReplaceAliases([]ToolAliasSet{
+		{Source: "older", Tool: "file.read", Aliases: []string{"readFile"}},
+		{Source: "newer", Tool: "file.open", Aliases: []string{"readFile", "openFile"}},
+	})
+	if errs[0] != nil || errs[1] == nil {
+		t.Errorf("expected only the newer set to conflict, got %v", errs)
+	}
+	if n.Normalize("readFile") != "file.read" || n.Normalize("openFile") != "open.file" {
+		t.Errorf("expected only the older set to be applied")
+	}
+}
+
+// TestToolNameCollisions tests that tool rules calls cannot match are
+// detected, and that audit events record raw tool names.
+func TestToolNameCollisions(t *testing.T) {
+	normalizer := NewToolNormalizer(ToolNamesConvert)
+	if err := normalizer.SetAliases("shell", "shell.exec", []string{"bash.run"}); err != nil {
+		t.Fatal(err)
+	}
+	sink := NewChannelAuditSink(10)
+	engine := NewEngine(WithMode(Enforcing), WithToolNormalizer(normalizer), WithAuditSink(sink))
+
+	p := CompilePolicy("team", []string{"coding-assistant"}, Deny, []ToolPermission{
+		{Tool: "file.read", Action: Allow},
+		{Tool: "File.Read", Action: Deny},
+		{Tool: "file_write", Action: Allow},
+		{Tool: "bash.run", Action: Allow},
+	}, Enforcing, "")
+	collisions := engine.ToolNameCollisions(p)
+	if len(collisions) != 3 {
+		t.Fatalf("expected 3 collisions, got %v", collisions)
+	}
+	for i, want := range []ToolNameCollision{
+		{Tool: "File.Read", Normalized: "file.read", Shadowed: true},
+		{Tool: "bash.run", Normalized: "shell.exec"},
+		{Tool: "file_write", Normalized: "file.write"},
+	} {
+		if collisions[i] != want {
+			t.Errorf("expected %v, got %v", want, collisions[i])
+		}
+	}
+
+	engine.LoadPolicy("coding-assistant", p)
+	agent := AgentContext{AgentType: "coding-assistant"}
+	ctx := ContextWithRawToolName(context.Background(), "FileRead")
+	if _, err := engine.EvaluateWithResult(ctx, agent, "file.read", nil); err != nil {
+		t.Fatal(err)
+	}
+	if event := <-sink.Events(); event.Tool != "file.read" || event.RawTool != "FileRead" {
+		t.Errorf("expected the raw tool name to be audited, got %q as %q", event.RawTool, event.Tool)
+	}
+	ctx = ContextWithRawToolName(context.Background(), "file.read")
+	engine.EvaluateWithResult(ctx, agent, "file.read", nil)
+	if event := <-sink.Events(); event.RawTool != "" {
+		t.Errorf("expected no raw tool name when it is the tool, got %q", event.RawTool)
+	}
+}
@@ -3,6 +3,7 @@
 package policy
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/open-policy-agent/opa/rego"
@@ -60,14 +61,45 @@ type ToolPermission struct {
 
 	// Constraints are optional conditions for the permission
 	Constraints *ToolConstraints
+
+	// DenyMessage is a user-facing message template returned when this rule
+	// denies a request, separate from the audit reason. Placeholders such as
+	// {path} are filled from the request (see RenderDenyMessage).
+	DenyMessage string
+
+	// LocalizedDenyMessages are DenyMessage translations keyed by BCP 47
+	// language tag (e.g., "de", "pt-BR"), selected by AgentContext.Locale
+	LocalizedDenyMessages map[string]string
+
+	// Mutators rewrite the request parameters of allowed calls, in order,
+	// before constraints are checked (see ParameterMutator)
+	Mutators []ParameterMutator
+
+	// Obligations are duties the caller must fulfil before executing an
+	// allowed call (see Obligation)
+	Obligations []Obligation
+
+	// Cost is what each allowed call to the tool is charged against the
+	// policy's Budget (e.g., 100 for gpu.run, 1 for file.read)
+	Cost int64
+
+	// RiskLevel rates the tool in this policy, for risk rules. It may
+	// raise the level of the tool catalog, but not lower it.
+	RiskLevel RiskLevel
 }
 
 // ToolConstraints define conditional access rules
 type ToolConstraints struct {
-	// PathPatterns for file operations (glob patterns)
+	// PathPatterns for file operations (glob patterns). Request paths are
+	// normalized first and paths containing ".." never match (see NormalizePath).
 	PathPatterns []string
 
-	// AllowedDomains for network operations
+	// ResolveSymlinks resolves symlinks in request paths against the local
+	// filesystem before matching PathPatterns (see ResolvePathSymlinks)
+	ResolveSymlinks bool
+
+	// AllowedDomains for network operations. Domains and patterns are
+	// compared in canonical form (see NormalizeDomain).
 	AllowedDomains []string
 
 	// DeniedDomains explicitly blocked domains
@@ -79,8 +111,51 @@ type ToolConstraints struct {
 	// MaxSizeBytes for write operations
 	MaxSizeBytes int64
 
-	// Timeout for execution operations
+	// Timeout bounds the tool's execution: the router cancels the
+	// executor's context at the deadline and fails the call
 	Timeout time.Duration
+
+	// MaxConcurrent bounds the executions of the tool in flight at once
+	// for each sandbox (or, for calls without one, each session): the
+	// router rejects calls past it with RESOURCE_EXHAUSTED (0 means no
+	// bound)
+	MaxConcurrent int
+
+	// Retry re-executes calls whose execution fails transiently (nil:
+	// executed once)
+	Retry *RetryPolicy
+
+	// RequiredAgentLabels must all be present on the requesting agent
+	RequiredAgentLabels map[string]string
+
+	// AllowedContentHashes are the SHA-256 digests of artifacts the tool may
+	// run (allow-by-artifact). When set, requests must carry a matching
+	// content_sha256 parameter; executors confirm it with VerifyContent.
+	AllowedContentHashes []string
+
+	// Conditions restrict the rule to the environment the router runs in
+	// and, for network tools, to destinations in given places
+	Conditions *ToolConditions
+
+	// Extensions are additional constraints implemented in Go, such as
+	// industrial protocol limits. All must be satisfied.
+	Extensions []ConstraintExtension
+
+	// Custom configures constraint kinds enforced by registered
+	// ConstraintCheckers, keyed by kind. Each value is the kind's JSON
+	// configuration. Kinds without a registered checker deny.
+	Custom map[string]json.RawMessage
+}
+
+// RetryPolicy bounds the retries of a tool's transiently failed executions.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a call is executed at most, the first
+	// attempt included
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, doubled before each
+	// further one
+	Backoff time.Duration
 }
 
 // CompiledPolicy is a pre-processed policy for fast evaluation.
@@ -89,9 +164,19 @@ type CompiledPolicy struct {
 	// Name of the policy (from CRD metadata)
 	Name string
 
-	// AgentTypes this policy applies to
+	// Namespace and UID identify the AgentPolicy the policy was compiled
+	// from. Names are only unique within a namespace and are reused when a
+	// policy is recreated, so the controller tracks its policies by UID.
+	// Both are empty for policies not compiled from a resource.
+	Namespace string
+	UID       string
+
+	// AgentTypes this policy applies to (exact names or glob patterns)
 	AgentTypes []string
 
+	// AgentSelector optionally restricts the policy to agents with matching labels
+	AgentSelector *LabelSelector
+
 	// DefaultAction for tools not explicitly listed
 	DefaultAction Decision
 
@@ -107,13 +192,57 @@ type CompiledPolicy struct {
 	// CompiledAt is when this policy was compiled
 	CompiledAt time.Time
 
+	// ProfileAction is applied to allowed calls outside the learned
+	// behavior profile of the agent type (see BehaviorProfile)
+	ProfileAction ProfileAction
+
+	// ProfileMinSamples is the number of calls a profile must have been
+	// learned from before it is enforced
+	ProfileMinSamples int64
+
+	// RateLimit caps the request rate of each client the policy applies
+	// to, overriding the router's default (nil means the default applies)
+	RateLimit *RateLimit
+
+	// Budget caps the cost of the calls the policy allows per session and
+	// tenant (nil means no budget)
+	Budget *Budget
+
+	// Priority ranks the policy among the policies that apply to an agent
+	// under the PriorityOrdered combining algorithm; higher wins
+	Priority int
+
+	// CacheTTL is how long the policy's decisions are cached, overriding
+	// the TTL of the engine's decision cache (0 means the cache's TTL)
+	CacheTTL time.Duration
+
+	// CacheWarmup lists calls whose decisions are computed and cached
+	// when the policy is loaded (see CacheWarmupCall)
+	CacheWarmup []CacheWarmupCall
+
+	// Source is the JSON manifest of the AgentPolicy the policy was
+	// compiled from, which engine snapshots carry to recompile it (see
+	// Engine.Snapshot). Nil for policies not compiled from a manifest
+	Source []byte
+
+	// SecretValues are the constraint values the policy read from Secrets
+	// (see compile.WithholdSecrets). Its Source is then the manifest with
+	// its valuesFrom references unresolved, which snapshots carry but
+	// cannot recompile, and the inspection UI redacts them
+	SecretValues []string
+
 	// ============================================================
 	// OPA Integration Fields (Phase 2)
 	// ============================================================
 
-	// RegoModule is the generated Rego source code (for debugging/audit)
+	// RegoModule is the generated Rego source code (for debugging/audit).
+	// For multi-module policies it is the JoinRegoModules bundle.
 	RegoModule string
 
+	// RegoModules holds the generated modules keyed by file name when the
+	// policy was compiled from more than one module (nil otherwise)
+	RegoModules map[string]string
+
 	// PreparedQuery is the pre-compiled OPA query for fast evaluation.
 	// This is nil when using the legacy engine.
 	PreparedQuery *rego.PreparedEvalQuery
@@ -124,6 +253,16 @@ type CompiledPolicy struct {
 	OPAEnabled bool
 }
 
+// RateLimit is a token bucket limit on the requests of one client. The
+// engine does not enforce it; the router applies it before evaluation.
+type RateLimit struct {
+	// RequestsPerSecond is the sustained request rate
+	RequestsPerSecond float64
+
+	// Burst is the number of requests allowed at once
+	Burst int
+}
+
 // AgentContext represents the identity of an agent making a request
 type AgentContext struct {
 	// AgentType is the type/class of agent (e.g., "coding-assistant")
@@ -138,11 +277,43 @@ type AgentContext struct {
 	// SessionID is the session identifier
 	SessionID string
 
+	// ParentSessionID is the session of the agent that delegated this
+	// agent's task, for sub-agents
+	ParentSessionID string
+
+	// Lineage lists the sessions of the agents the task was delegated
+	// through: the root task's first, and the parent's last
+	Lineage []string
+
 	// MTSLabel is the Multi-Tenant Sandboxing label
 	MTSLabel string
 
 	// PolicyRef is the name of the policy being applied
 	PolicyRef string
+
+	// Labels are additional agent attributes (e.g., environment, team)
+	Labels map[string]string
+
+	// Locale is the preferred BCP 47 language for user-facing messages
+	Locale string
+
+	// labelResolved is set when MTSLabel was resolved from the
+	// TenantRegistry rather than claimed, so that it may dominate policy
+	// labels other than its own
+	labelResolved bool
+}
+
+// RootSessionID returns the session of the root task of the agent's
+// delegation lineage: the first of its Lineage, or its own session if it
+// has no lineage.
+func (a AgentContext) RootSessionID() string {
+	if len(a.Lineage) > 0 {
+		return a.Lineage[0]
+	}
+	if a.ParentSessionID != "" {
+		return a.ParentSessionID
+	}
+	return a.SessionID
 }
 
 // AuditEvent records a policy decision for compliance
@@ -156,9 +327,27 @@ type AuditEvent struct {
 	// Tool being called
 	Tool string
 
-	// Decision made (Allow or Deny)
+	// RawTool is the tool name the call was made with, if normalization
+	// changed it (see ContextWithRawToolName)
+	RawTool string
+
+	// RiskLevel is the risk level of the tool, if rated (see
+	// Engine.RiskLevel)
+	RiskLevel RiskLevel
+
+	// Decision made (Allow or Deny) by the policies: the raw decision,
+	// before the enforcement mode is applied
 	Decision Decision
 
+	// EnforcedDecision is the decision after the enforcement mode is
+	// applied, which the call was served with. It differs from Decision
+	// for the calls Permissive mode would have denied
+	EnforcedDecision Decision
+
+	// Mode is the enforcement mode the decision was applied in: the
+	// engine's, or its tenant's (see Engine.EffectiveMode)
+	Mode EnforcementMode
+
 	// Reason for the decision
 	Reason string
 
@@ -167,4 +356,56 @@ type AuditEvent struct {
 
 	// Cached indicates if this was a cache hit
 	Cached bool
+
+	// Combining is the engine's algorithm for combining the policies
+	// that apply to the agent (see CombiningAlgorithm)
+	Combining CombiningAlgorithm
+
+	// Parameters are the request parameters, set only when the engine is
+	// built WithAuditParameters or a risk rule audits them
+	Parameters map[string]interface{}
+
+	// Repeated counts the identical denials a sink coalesced into this
+	// one, the last of them (see StdoutAuditSink.CoalesceDenials); 0 for
+	// events written as they occurred
+	Repeated int
+
+	// Execution is the outcome of an allowed call's execution, set on the
+	// execution events that follow decision events under the same
+	// RequestID (see Engine.AuditExecution); nil on decision events
+	Execution *ExecutionRecord
+
+	// Source is the source of a query, which asked how a call would be
+	// decided without making it (see ContextWithQuery); empty for calls
+	Source string
+}
+
+// ExecutionStatus is the outcome of a tool execution.
+type ExecutionStatus string
+
+const (
+	// ExecutionSucceeded is the status of executions that returned a result
+	ExecutionSucceeded ExecutionStatus = "success"
+
+	// ExecutionFailed is the status of executions that failed
+	ExecutionFailed ExecutionStatus = "error"
+)
+
+// ExecutionRecord is the outcome of the execution of an allowed call, as
+// audited after the tool returns.
+type ExecutionRecord struct {
+	// Status is whether the execution succeeded
+	Status ExecutionStatus
+
+	// Duration is how long the tool ran, over all attempts
+	Duration time.Duration
+
+	// ResultBytes is the size of the JSON-encoded result returned
+	ResultBytes int
+
+	// Attempts is how many times the tool ran (see RetryPolicy)
+	Attempts int
+
+	// Error is the error of a failed execution
+	Error string
 }
@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// policyNotifier fans out "policy changed" signals to subscribers.
+//
+// Signals are coalescing: each subscriber has a one-slot channel, so a slow
+// subscriber sees at most one pending signal no matter how many changes
+// happened. Subscribers re-read the current policy state on each signal,
+// which means no change is ever lost, only merged.
+type policyNotifier struct {
+	mu   sync.Mutex
+	subs map[int]chan struct{}
+	next int
+}
+
+// subscribe registers a subscriber and returns its signal channel and a
+// cancel function that unregisters it.
+func (n *policyNotifier) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	if n.subs == nil {
+		n.subs = make(map[int]chan struct{})
+	}
+	id := n.next
+	n.next++
+	n.subs[id] = ch
+	n.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(n.subs, id)
+			n.mu.Unlock()
+		})
+	}
+	return ch, cancel
+}
+
+// notify signals all subscribers without blocking.
+func (n *policyNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, ch := range n.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// Signal already pending
+		}
+	}
+}
+
+// changeHooks calls the callbacks registered with OnPolicyChange.
+type changeHooks struct {
+	mu    sync.RWMutex
+	hooks map[int]func(agentType string)
+	next  int
+}
+
+// add registers a callback and returns a function that unregisters it.
+func (h *changeHooks) add(fn func(agentType string)) func() {
+	h.mu.Lock()
+	if h.hooks == nil {
+		h.hooks = make(map[int]func(string))
+	}
+	id := h.next
+	h.next++
+	h.hooks[id] = fn
+	h.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.hooks, id)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// call calls every callback with agentType, outside the lock, so that
+// callbacks may register and unregister callbacks.
+func (h *changeHooks) call(agentType string) {
+	h.mu.RLock()
+	hooks := make([]func(string), 0, len(h.hooks))
+	for _, fn := range h.hooks {
+		hooks = append(hooks, fn)
+	}
+	h.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(agentType)
+	}
+}
+
+// OnPolicyChange registers fn to be called whenever decisions for an
+// agent type may have changed, so that components outside the engine
+// that cache allowances, such as executor-side caches, SDK clients, and
+// mesh filters, can drop them. Call the returned function to unregister.
+//
+// fn receives the agent type or pattern whose policy was loaded or
+// removed, here or, with an InvalidationBus, on another replica. A
+// pattern, including FallbackAgentType, may affect any agent type it
+// matches; FallbackAgentType is also passed when a canary rollout or the
+// enforcement mode changes, which may affect every agent type.
+//
+// Unlike Subscribe, every change is reported: fn is called synchronously,
+// after the engine's own cached decisions are dropped, by the goroutine
+// that made the change, so it must not block.
+func (e *Engine) OnPolicyChange(fn func(agentType string)) (cancel func()) {
+	return e.hooks.add(fn)
+}
+
+// Fingerprint returns a stable hash of the policy's effective content:
+// name, default action, mode, tool rules, constraints, deny messages,
+// mutators, obligations, profile enforcement, and Rego module. Two policies with the same fingerprint make the same decisions.
+func (p *CompiledPolicy) Fingerprint() string {
+	if p == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "name=%s\ndefault=%s\nmode=%s\nmts=%s\n", p.Name, p.DefaultAction, p.Mode, p.MTSLabel)
+
+	tools := make([]string, 0, len(p.ToolTable))
+	for tool := range p.ToolTable {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	for _, tool := range tools {
+		perm := p.ToolTable[tool]
+		fmt.Fprintf(h, "tool=%s action=%s", tool, perm.Action)
+		if perm.Constraints != nil {
+			fmt.Fprintf(h, " constraints=%+v", *perm.Constraints)
+		}
+		if perm.DenyMessage != "" || len(perm.LocalizedDenyMessages) > 0 {
+			fmt.Fprintf(h, " message=%q localized=%v", perm.DenyMessage, perm.LocalizedDenyMessages)
+		}
+		for _, m := range perm.Mutators {
+			fmt.Fprintf(h, " mutator=%+v", m)
+		}
+		for _, o := range perm.Obligations {
+			fmt.Fprintf(h, " obligation=%s", o)
+		}
+		if perm.RiskLevel != "" {
+			fmt.Fprintf(h, " risk=%s", perm.RiskLevel)
+		}
+		fmt.Fprintln(h)
+	}
+
+	if p.ProfileAction != ProfileOff {
+		fmt.Fprintf(h, "profile=%s min=%d\n", p.ProfileAction, p.ProfileMinSamples)
+	}
+	if p.RateLimit != nil {
+		fmt.Fprintf(h, "ratelimit=%g burst=%d\n", p.RateLimit.RequestsPerSecond, p.RateLimit.Burst)
+	}
+
+	if p.AgentSelector != nil {
+		fmt.Fprintf(h, "selector=%+v\n", *p.AgentSelector)
+	}
+	fmt.Fprintf(h, "rego=%s\n", p.RegoModule)
+
+	sum := h.Sum(nil)
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,450 @@
+// Package redact removes PII and secrets from the parameters and results of
+// tool calls before they leave the router: in audit events, recordings,
+// and responses.
+//
+// A Redactor replaces
+//
+//   - the values of sensitive keys (e.g., "password", "access_token"),
+//   - the matches of PII and credential patterns in strings (e.g., email
+//     addresses, AWS access keys, private key headers), and
+//   - high-entropy tokens, such as generated API keys, that no pattern
+//     knows,
+//
+// with Placeholder, except for values an allowlist pattern matches whole
+// (e.g., commit hashes, which look like secrets but are not):
+//
+//	r := redact.Default()
+//	clean := r.Value(params) // map[string]interface{}{"api_key": "[REDACTED]", ...}
+//
+// Redactors run on every audited call, so they avoid work on clean data;
+// see the package benchmarks.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Placeholder replaces redacted values and substrings.
+const Placeholder = "[REDACTED]"
+
+// DefaultKeys are the keys whose values are redacted by default. Keys match
+// case-insensitively and by substring, so "token" also redacts
+// "access_token".
+var DefaultKeys = []string{
+	"password", "passwd", "secret", "token", "api_key", "apikey",
+	"authorization", "cookie", "credential", "private_key",
+}
+
+// DefaultPatterns match PII and well-known credential formats.
+var DefaultPatterns = []string{
+	// Email addresses
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	// US social security numbers
+	`\b\d{3}-\d{2}-\d{4}\b`,
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// GitHub tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// Slack tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`,
+	// JSON Web Tokens
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	// PEM private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`,
+	// Bearer credentials
+	`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`,
+}
+
+// DefaultAllow match values that look like secrets but are not.
+var DefaultAllow = []string{
+	// Git commit hashes and SHA-256 digests
+	`^[0-9a-f]{40}$`,
+	`^(?:sha256:)?[0-9a-f]{64}$`,
+	// UUIDs
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Keys are the keys whose values are redacted, matched
+	// case-insensitively and by substring
+	Keys []string
+
+	// Patterns are regular expressions whose matches in strings are
+	// redacted
+	Patterns []string
+
+	// MinEntropy is the Shannon entropy, in bits per character, from which
+	// tokens of letters and digits are redacted as secrets. Zero disables
+	// entropy detection.
+	MinEntropy float64
+
+	// MinEntropyLength is the length from which tokens are checked for
+	// entropy (default: 20)
+	MinEntropyLength int
+
+	// Allow are regular expressions of strings and tokens that are never
+	// redacted by pattern or entropy, matched against them whole
+	Allow []string
+}
+
+// DefaultConfig returns the configuration of Default.
+func DefaultConfig() Config {
+	return Config{
+		Keys:             DefaultKeys,
+		Patterns:         DefaultPatterns,
+		MinEntropy:       3.5,
+		MinEntropyLength: 20,
+		Allow:            DefaultAllow,
+	}
+}
+
+// Redactor redacts sensitive data. It is safe for concurrent use.
+type Redactor struct {
+	keys             []string
+	patterns         []pattern
+	allow            *regexp.Regexp
+	minEntropy       float64
+	minEntropyLength int
+}
+
+// New compiles a Redactor.
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{
+		minEntropy:       cfg.MinEntropy,
+		minEntropyLength: cfg.MinEntropyLength,
+	}
+	if r.minEntropyLength <= 0 {
+		r.minEntropyLength = 20
+	}
+	for _, k := range cfg.Keys {
+		if k != "" {
+			r.keys = append(r.keys, strings.ToLower(k))
+		}
+	}
+
+	for _, expr := range cfg.Patterns {
+		p, err := compilePattern(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, p)
+	}
+
+	if len(cfg.Allow) > 0 {
+		parts := make([]string, len(cfg.Allow))
+		for i, expr := range cfg.Allow {
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("invalid allow pattern %q: %w", expr, err)
+			}
+			parts[i] = "(?:" + expr + ")"
+		}
+		allow, err := regexp.Compile("^(?:" + strings.Join(parts, "|") + ")$")
+		if err != nil {
+			return nil, err
+		}
+		r.allow = allow
+	}
+	return r, nil
+}
+
+// pattern is a compiled redaction pattern. Most strings match no pattern,
+// so patterns are only run on strings that contain their hint, a literal
+// every match contains.
+type pattern struct {
+	re   *regexp.Regexp
+	hint string
+	fold bool
+}
+
+func compilePattern(expr string) (pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pattern{}, err
+	}
+	p := pattern{re: re}
+	if parsed, err := syntax.Parse(expr, syntax.Perl); err == nil {
+		p.hint, p.fold = requiredLiteral(parsed.Simplify())
+		if p.fold {
+			p.hint = strings.ToLower(p.hint)
+		}
+	}
+	return p, nil
+}
+
+// requiredLiteral returns the longest literal of a regular expression's
+// top-level concatenation, which every match contains, and whether it
+// matches case-insensitively.
+func requiredLiteral(re *syntax.Regexp) (string, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune), re.Flags&syntax.FoldCase != 0
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpConcat:
+		var hint string
+		var fold bool
+		for _, sub := range re.Sub {
+			if h, f := requiredLiteral(sub); len(h) > len(hint) {
+				hint, fold = h, f
+			}
+		}
+		return hint, fold
+	}
+	return "", false
+}
+
+// candidate reports whether s, or lower, its lowercase form, may match p.
+func (p pattern) candidate(s string, lower func() string) bool {
+	switch {
+	case p.hint == "":
+		return true
+	case p.fold:
+		return strings.Contains(lower(), p.hint)
+	default:
+		return strings.Contains(s, p.hint)
+	}
+}
+
+var defaultRedactor *Redactor
+
+func init() {
+	r, err := New(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	defaultRedactor = r
+}
+
+// Default returns the Redactor of DefaultConfig.
+func Default() *Redactor {
+	return defaultRedactor
+}
+
+// SensitiveKey reports whether the value of key is redacted whole.
+func (r *Redactor) SensitiveKey(key string) bool {
+	if len(r.keys) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns v, a decoded JSON value or string slice, with sensitive
+// data redacted. Maps and slices are copied only where they change; v is
+// never modified.
+func (r *Redactor) Value(v interface{}) interface{} {
+	redacted, _ := r.value(v)
+	return redacted
+}
+
+// value returns v redacted, and whether it changed.
+func (r *Redactor) value(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		s := r.String(v)
+		return s, s != v
+	case map[string]interface{}:
+		var out map[string]interface{}
+		for k, child := range v {
+			var redacted interface{}
+			var changed bool
+			if r.SensitiveKey(k) {
+				redacted, changed = Placeholder, true
+			} else {
+				redacted, changed = r.value(child)
+			}
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(v))
+				for k2, c2 := range v {
+					out[k2] = c2
+				}
+			}
+			out[k] = redacted
+		}
+		if out == nil {
+			return v, false
+		}
+		return out, true
+	case []interface{}:
+		var out []interface{}
+		for i, child := range v {
+			redacted, changed := r.value(child)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}(nil), v...)
+			}
+			out[i] = redacted
+		}
+		if out == nil {
+			return v, false
+		}
+		return out, true
+	case []string:
+		var out []string
+		for i, child := range v {
+			redacted := r.String(child)
+			if redacted == child {
+				continue
+			}
+			if out == nil {
+				out = append([]string(nil), v...)
+			}
+			out[i] = redacted
+		}
+		if out == nil {
+			return v, false
+		}
+		return out, true
+	default:
+		return v, false
+	}
+}
+
+// String returns s with the matches of the redaction patterns and its
+// high-entropy tokens redacted, unless the allowlist matches it whole.
+func (r *Redactor) String(s string) string {
+	if s == "" || r.allowed(s) {
+		return s
+	}
+	var lowered string
+	lower := func() string {
+		if lowered == "" {
+			lowered = strings.ToLower(s)
+		}
+		return lowered
+	}
+	for _, p := range r.patterns {
+		if p.candidate(s, lower) {
+			if redacted := p.re.ReplaceAllString(s, Placeholder); redacted != s {
+				s, lowered = redacted, ""
+			}
+		}
+	}
+	if r.minEntropy > 0 && len(s) >= r.minEntropyLength {
+		s = r.redactTokens(s)
+	}
+	return s
+}
+
+// JSON returns a JSON document with sensitive data redacted. Data that is
+// not JSON is redacted as a string. Numbers are kept as written.
+func (r *Redactor) JSON(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return []byte(r.String(string(data)))
+	}
+	redacted, changed := r.value(v)
+	if !changed {
+		return data
+	}
+	out, err := json.Marshal(redacted)
+	if err != nil {
+		return []byte(`"` + Placeholder + `"`)
+	}
+	return out
+}
+
+func (r *Redactor) allowed(s string) bool {
+	return r.allow != nil && r.allow.MatchString(s)
+}
+
+// redactTokens redacts the tokens of s, runs of characters that occur in
+// generated secrets, that have letters and digits and at least the
+// minimum entropy.
+func (r *Redactor) redactTokens(s string) string {
+	var b *strings.Builder
+	last := 0
+	for start := 0; start < len(s); {
+		if !tokenChar(s[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(s) && tokenChar(s[end]) {
+			end++
+		}
+		if token := s[start:end]; len(token) >= r.minEntropyLength && r.secret(token) {
+			if b == nil {
+				b = &strings.Builder{}
+				b.Grow(len(s))
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(Placeholder)
+			last = end
+		}
+		start = end
+	}
+	if b == nil {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// tokenChar reports whether c occurs in generated secrets: letters, digits,
+// and the symbols of base64 and URL-safe encodings. Padding is left out, so
+// that assignments such as KEY=value split.
+func tokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '-' || c == '_'
+}
+
+// secret reports whether a token looks like a generated secret.
+func (r *Redactor) secret(token string) bool {
+	if r.allowed(token) || strings.Contains(token, Placeholder) {
+		return false
+	}
+	var letters, digits bool
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits = true
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			letters = true
+		}
+	}
+	return letters && digits && Entropy(token) >= r.minEntropy
+}
+
+// Entropy returns the Shannon entropy of s in bits per byte.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// DefaultExternalAuthorizerMethod is the gRPC method GRPCAuthorizer calls
+// by default.
+const DefaultExternalAuthorizerMethod = "/agents.sandbox.v1alpha1.ExternalAuthorizer/Authorize"
+
+// maxAuthorizerResponse bounds the responses of external authorizers.
+const maxAuthorizerResponse = 64 * 1024
+
+// HTTPAuthorizer is a policy.ExternalAuthorizer that POSTs each
+// policy.ExternalAuthzRequest as JSON to a URL, which must answer 200 OK
+// with a policy.ExternalAuthzResponse:
+//
+//	{"allow": false, "reason": "contractor accounts may not write"}
+type HTTPAuthorizer struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAuthorizer returns an authorizer calling url with client
+// (http.DefaultClient if nil). Calls are bounded by the engine's external
+// authorizer timeout.
+func NewHTTPAuthorizer(url string, client *http.Client) *HTTPAuthorizer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPAuthorizer{url: url, client: client}
+}
+
+// Authorize implements policy.ExternalAuthorizer.
+func (a *HTTPAuthorizer) Authorize(ctx context.Context, req *policy.ExternalAuthzRequest) (*policy.ExternalAuthzResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var decision policy.ExternalAuthzResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAuthorizerResponse)).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &decision, nil
+}
+
+// GRPCAuthorizer is a policy.ExternalAuthorizer that calls a unary gRPC
+// method taking and returning a google.protobuf.Struct: the request is the
+// JSON form of policy.ExternalAuthzRequest, and the response must hold a
+// bool "allow" and optionally a string "reason". Authorizers need no code
+// generated from this repository's protos.
+type GRPCAuthorizer struct {
+	conn   grpc.ClientConnInterface
+	method string
+}
+
+// NewGRPCAuthorizer returns an authorizer calling method (a full method
+// name, DefaultExternalAuthorizerMethod if empty) on conn.
+func NewGRPCAuthorizer(conn grpc.ClientConnInterface, method string) *GRPCAuthorizer {
+	if method == "" {
+		method = DefaultExternalAuthorizerMethod
+	}
+	return &GRPCAuthorizer{conn: conn, method: method}
+}
+
+// Authorize implements policy.ExternalAuthorizer.
+func (a *GRPCAuthorizer) Authorize(ctx context.Context, req *policy.ExternalAuthzRequest) (*policy.ExternalAuthzResponse, error) {
+	// The JSON round trip leaves only types structpb can hold
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	in, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &structpb.Struct{}
+	if err := a.conn.Invoke(ctx, a.method, in, out); err != nil {
+		return nil, err
+	}
+	allow, ok := out.GetFields()["allow"].GetKind().(*structpb.Value_BoolValue)
+	if !ok {
+		return nil, fmt.Errorf("invalid response: missing bool \"allow\"")
+	}
+	return &policy.ExternalAuthzResponse{
+		Allow:  allow.BoolValue,
+		Reason: out.GetFields()["reason"].GetStringValue(),
+	}, nil
+}
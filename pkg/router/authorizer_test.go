@@ -0,0 +1,87 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestHTTPAuthorizer tests the JSON exchange with HTTP authorizers.
+func TestHTTPAuthorizer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req policy.ExternalAuthzRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Agent.TenantID == "broken" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(policy.ExternalAuthzResponse{
+			Allow:  req.Tool == "file.read",
+			Reason: "tool " + req.Tool,
+		})
+	}))
+	defer srv.Close()
+
+	authz := NewHTTPAuthorizer(srv.URL, nil)
+	resp, err := authz.Authorize(context.Background(), &policy.ExternalAuthzRequest{Tool: "file.read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Allow || resp.Reason != "tool file.read" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	req := &policy.ExternalAuthzRequest{Tool: "file.read", Agent: policy.OPAAgentInput{TenantID: "broken"}}
+	if _, err := authz.Authorize(context.Background(), req); err == nil {
+		t.Error("expected an error for a failed authorizer")
+	}
+}
+
+// structConn is a gRPC connection whose method denies the tools in deny.
+type structConn struct {
+	grpc.ClientConnInterface
+	method string
+	deny   map[string]bool
+}
+
+func (c *structConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	c.method = method
+	tool := args.(*structpb.Struct).GetFields()["tool"].GetStringValue()
+	out, err := structpb.NewStruct(map[string]interface{}{"allow": !c.deny[tool], "reason": "checked " + tool})
+	if err != nil {
+		return err
+	}
+	proto.Merge(reply.(*structpb.Struct), out)
+	return nil
+}
+
+// TestGRPCAuthorizer tests the Struct exchange with gRPC authorizers.
+func TestGRPCAuthorizer(t *testing.T) {
+	conn := &structConn{deny: map[string]bool{"shell.exec": true}}
+	authz := NewGRPCAuthorizer(conn, "")
+
+	resp, err := authz.Authorize(context.Background(), &policy.ExternalAuthzRequest{
+		Tool:       "shell.exec",
+		Parameters: map[string]interface{}{"command": "ls", "timeout_ms": int64(100)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Allow || resp.Reason != "checked shell.exec" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if conn.method != DefaultExternalAuthorizerMethod {
+		t.Errorf("expected the default method, got %s", conn.method)
+	}
+}
@@ -0,0 +1,277 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+)
+
+// ErrToolCircuitOpen is returned for calls to a tool whose circuit breaker
+// is open, without executing them.
+var ErrToolCircuitOpen = errors.New("tool circuit open")
+
+// CircuitReason is the ErrorInfo reason of calls rejected by an open
+// circuit.
+const CircuitReason = "TOOL_CIRCUIT_OPEN"
+
+// BreakerState is the state of a tool's circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets calls through and measures their outcomes
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen rejects calls with ErrToolCircuitOpen
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen lets a single probe call through, whose outcome
+	// closes or reopens the circuit
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreakerConfig configures the circuit breakers the server keeps per
+// tool around its executions.
+type CircuitBreakerConfig struct {
+	// FailureRate is the share of failed executions among the last Window
+	// of a tool from which its circuit opens (e.g., 0.5). Zero disables
+	// circuit breaking.
+	FailureRate float64
+
+	// SlowCall is the execution time past which an execution counts as
+	// failed (0: only errors count)
+	SlowCall time.Duration
+
+	// Window is the number of recent executions of a tool its failure rate
+	// is computed over (default: 20). No circuit opens before a window of
+	// executions is recorded.
+	Window int
+
+	// Cooldown is how long an open circuit rejects calls before it lets a
+	// probe call through (default: 30s)
+	Cooldown time.Duration
+}
+
+// BreakerStatus is the state of a tool's circuit breaker, as the
+// diagnostics server reports it.
+type BreakerStatus struct {
+	Tool  string       `json:"tool"`
+	State BreakerState `json:"state"`
+
+	// FailureRate is the share of failed executions in the current window
+	FailureRate float64 `json:"failureRate"`
+
+	// OpenUntil is when an open circuit lets a probe call through
+	OpenUntil *time.Time `json:"openUntil,omitempty"`
+
+	// Rejected counts the calls open circuits rejected
+	Rejected uint64 `json:"rejected"`
+}
+
+// circuitBreakers keeps the circuit breakers of the tools executed.
+type circuitBreakers struct {
+	config CircuitBreakerConfig
+	log    *slog.Logger
+
+	mu    sync.Mutex
+	tools map[string]*toolBreaker
+}
+
+// toolBreaker is the circuit breaker of one tool.
+type toolBreaker struct {
+	failed    []bool // ring buffer of outcomes
+	next      int
+	full      bool
+	openUntil time.Time // zero while closed
+	probing   bool
+	rejected  uint64
+}
+
+// newCircuitBreakers returns the breakers of config, or nil if it disables
+// circuit breaking.
+func newCircuitBreakers(config CircuitBreakerConfig, log *slog.Logger) *circuitBreakers {
+	if config.FailureRate <= 0 {
+		return nil
+	}
+	if config.Window <= 0 {
+		config.Window = 20
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	return &circuitBreakers{config: config, log: log, tools: make(map[string]*toolBreaker)}
+}
+
+// breakerTicket is a call let through a circuit breaker. Its outcome is
+// recorded with record; release returns the probe of a half-open circuit
+// whose call was not executed.
+type breakerTicket struct {
+	b        *circuitBreakers
+	tool     string
+	probe    bool
+	recorded bool
+}
+
+// allow lets a call to tool through its circuit, or returns the time until
+// the open circuit lets a probe call through.
+func (b *circuitBreakers) allow(tool string) (*breakerTicket, time.Duration, bool) {
+	if b == nil {
+		return nil, 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.tools[tool]
+	if t == nil {
+		t = &toolBreaker{failed: make([]bool, b.config.Window)}
+		b.tools[tool] = t
+	}
+	if t.openUntil.IsZero() {
+		return &breakerTicket{b: b, tool: tool}, 0, true
+	}
+	if wait := time.Until(t.openUntil); wait > 0 || t.probing {
+		t.rejected++
+		return nil, max(wait, 0), false
+	}
+	t.probing = true
+	return &breakerTicket{b: b, tool: tool, probe: true}, 0, true
+}
+
+// record records the outcome of the call's execution: a failure if err is
+// set, or if it ran longer than the slow call threshold.
+func (k *breakerTicket) record(err error, elapsed time.Duration) {
+	if k == nil || k.recorded {
+		return
+	}
+	k.recorded = true
+	b := k.b
+	failed := err != nil || (b.config.SlowCall > 0 && elapsed > b.config.SlowCall)
+
+	b.mu.Lock()
+	t := b.tools[k.tool]
+	if k.probe {
+		t.probing = false
+		if failed {
+			t.openUntil = time.Now().Add(b.config.Cooldown)
+			b.mu.Unlock()
+			return
+		}
+		t.openUntil, t.next, t.full = time.Time{}, 0, false
+		b.mu.Unlock()
+		b.log.Info("tool circuit closed", "tool", k.tool)
+		return
+	}
+	if !t.openUntil.IsZero() {
+		// Calls let through before the circuit opened
+		b.mu.Unlock()
+		return
+	}
+
+	t.failed[t.next] = failed
+	t.next = (t.next + 1) % len(t.failed)
+	if t.next == 0 {
+		t.full = true
+	}
+	rate := t.failureRate()
+	if !t.full || rate < b.config.FailureRate {
+		b.mu.Unlock()
+		return
+	}
+	t.openUntil = time.Now().Add(b.config.Cooldown)
+	b.mu.Unlock()
+	b.log.Error("tool failing, opening its circuit", "tool", k.tool, "failureRate", rate, "cooldown", b.config.Cooldown)
+}
+
+// release returns the probe of a call that was not executed, so that the
+// next call probes the circuit instead.
+func (k *breakerTicket) release() {
+	if k == nil || k.recorded || !k.probe {
+		return
+	}
+	k.b.mu.Lock()
+	k.b.tools[k.tool].probing = false
+	k.b.mu.Unlock()
+}
+
+// failureRate returns the share of failed outcomes recorded.
+func (t *toolBreaker) failureRate() float64 {
+	n := t.next
+	if t.full {
+		n = len(t.failed)
+	}
+	if n == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range t.failed[:n] {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(n)
+}
+
+// statuses returns the state of every tool's breaker, by tool.
+func (b *circuitBreakers) statuses() []BreakerStatus {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	out := make([]BreakerStatus, 0, len(b.tools))
+	for tool, t := range b.tools {
+		s := BreakerStatus{Tool: tool, State: BreakerClosed, FailureRate: t.failureRate(), Rejected: t.rejected}
+		if !t.openUntil.IsZero() {
+			s.State = BreakerOpen
+			if t.probing || !now.Before(t.openUntil) {
+				s.State = BreakerHalfOpen
+			}
+			openUntil := t.openUntil
+			s.OpenUntil = &openUntil
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tool < out[j].Tool })
+	return out
+}
+
+// CircuitBreakers returns the state of the circuit breakers of the tools
+// executed so far, or nil if circuit breaking is disabled.
+func (s *Server) CircuitBreakers() []BreakerStatus {
+	return s.breakers.statuses()
+}
+
+// circuitOpenError is the status of calls rejected by the open circuit of
+// their tool: UNAVAILABLE, with the time until the circuit lets a probe
+// call through as RetryInfo.
+func circuitOpenError(tool string, retry time.Duration) error {
+	st := status.Newf(codes.Unavailable, "%v: %q", ErrToolCircuitOpen, tool)
+	withDetails, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   CircuitReason,
+			Domain:   agentsv1alpha1.GroupVersion.Group,
+			Metadata: map[string]string{"tool": tool},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retry)},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// String describes the breaker status, e.g. "db.query: open (80% failed)".
+func (s BreakerStatus) String() string {
+	return fmt.Sprintf("%s: %s (%.0f%% failed)", s.Tool, s.State, s.FailureRate*100)
+}
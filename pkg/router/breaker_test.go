@@ -0,0 +1,114 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestCircuitBreaker tests that a tool's circuit opens once its executions
+// keep failing or run slow, rejects calls until its cooldown elapses, and
+// closes after a successful probe, while other tools stay available.
+func TestCircuitBreaker(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.CircuitBreaker = CircuitBreakerConfig{FailureRate: 0.5, SlowCall: 50 * time.Millisecond, Window: 4, Cooldown: 100 * time.Millisecond}
+	server := NewServer(config)
+	executor := &mockToolExecutor{result: "ok"}
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "db.query", Action: policy.Allow}, {Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	execute := func(tool string) (*agentpb.ExecuteResponse, error) {
+		return server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName: tool,
+			Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+		})
+	}
+
+	// Two of the first four executions fail
+	for i, fail := range []bool{true, false, true, false} {
+		executor.err = nil
+		if fail {
+			executor.err = errors.New("connection refused")
+		}
+		if _, err := execute("db.query"); err != nil {
+			t.Fatalf("call %d: expected the call to execute, got %v", i, err)
+		}
+	}
+
+	executor.err = nil
+	executor.params = map[string]interface{}{}
+	_, err := execute("db.query")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the open circuit to reject the call, got %v", err)
+	}
+	var info *errdetails.ErrorInfo
+	var retry *errdetails.RetryInfo
+	for _, d := range status.Convert(err).Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.RetryInfo:
+			retry = d
+		}
+	}
+	if info.GetReason() != CircuitReason || info.GetMetadata()["tool"] != "db.query" || retry.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("expected circuit details, got %v %v", info, retry)
+	}
+	if executor.params == nil {
+		t.Error("expected the rejected call not to execute")
+	}
+	if resp, err := execute("file.read"); err != nil || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Errorf("expected other tools to stay available, got %v (%v)", resp, err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.InspectHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/breakers", nil))
+	var breakers []BreakerStatus
+	if err := json.NewDecoder(rec.Body).Decode(&breakers); err != nil || len(breakers) != 2 {
+		t.Fatalf("expected the breakers of both tools, got %s (%v)", rec.Body, err)
+	}
+	if breakers[0].Tool != "db.query" || breakers[0].State != BreakerOpen || breakers[0].Rejected != 1 || breakers[1].State != BreakerClosed {
+		t.Errorf("unexpected breakers %v", breakers)
+	}
+
+	// A failed probe reopens the circuit, a successful one closes it
+	time.Sleep(100 * time.Millisecond)
+	executor.err = errors.New("connection refused")
+	if _, err := execute("db.query"); err != nil {
+		t.Fatalf("expected the probe to execute, got %v", err)
+	}
+	if _, err := execute("db.query"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the failed probe to reopen the circuit, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	executor.err = nil
+	for i := 0; i < 2; i++ {
+		if _, err := execute("db.query"); err != nil {
+			t.Fatalf("call %d: expected the circuit to close, got %v", i, err)
+		}
+	}
+	if s := server.CircuitBreakers()[0]; s.State != BreakerClosed {
+		t.Errorf("expected the circuit to be closed, got %v", s)
+	}
+
+	// Slow executions count as failures
+	server.SetToolExecutor(&slowExecutor{delay: 60 * time.Millisecond})
+	for i := 0; i < 4; i++ {
+		execute("db.query")
+	}
+	if s := server.CircuitBreakers()[0]; s.State != BreakerOpen {
+		t.Errorf("expected slow executions to open the circuit, got %v", s)
+	}
+}
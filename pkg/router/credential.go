@@ -0,0 +1,298 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// DefaultCredentialParam is the parameter credentials are injected into
+// when their obligation names none.
+const DefaultCredentialParam = "credential"
+
+// maxCredentialResponse bounds the responses of credential brokers.
+const maxCredentialResponse = 64 * 1024
+
+// Credential is a credential a CredentialBroker issued for one call.
+type Credential struct {
+	// Data holds the credential's fields (e.g., username and password)
+	Data map[string]interface{}
+
+	// LeaseID identifies the credential's lease, if the broker leases it
+	LeaseID string
+
+	// ExpiresAt is when the credential expires; zero if unknown
+	ExpiresAt time.Time
+}
+
+// CredentialBroker issues credentials for the paths of
+// policy.ObligationInjectCredential obligations.
+type CredentialBroker interface {
+	Credential(ctx context.Context, path string) (*Credential, error)
+}
+
+// NewCredentialHandler returns a handler for
+// policy.ObligationInjectCredential that injects the credential broker
+// issues for the obligation's path into the call's parameters. The
+// parameters are copied, so that the credential never reaches the decision
+// cache, and the server fulfils these obligations after all others, so
+// that no other handler sees it.
+func NewCredentialHandler(broker CredentialBroker) ObligationHandler {
+	return ObligationHandlerFunc(func(ctx context.Context, call *ToolCall, obligation policy.Obligation) error {
+		path, err := credentialPath(obligation.Params["path"], call.Parameters)
+		if err != nil {
+			return err
+		}
+		credential, err := broker.Credential(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to issue credential for %s: %w", path, err)
+		}
+
+		param := obligation.Params["param"]
+		if param == "" {
+			param = DefaultCredentialParam
+		}
+		params := make(map[string]interface{}, len(call.Parameters)+1)
+		for k, v := range call.Parameters {
+			params[k] = v
+		}
+		params[param] = credential.Data
+		call.Parameters = params
+		return nil
+	})
+}
+
+// credentialPath replaces "{domain}" in path with the normalized domain
+// parameter of the call, which scopes the credential to the destination
+// the rule's constraints allowed. A domain with any character but
+// [a-z0-9._-] is rejected, so that it cannot reach another path, a query,
+// or a fragment of the secret store.
+func credentialPath(path string, params map[string]interface{}) (string, error) {
+	if !strings.Contains(path, "{domain}") {
+		return path, nil
+	}
+	raw, _ := params["domain"].(string)
+	domain, err := policy.NormalizeDomain(raw)
+	if err != nil || domain == "" {
+		return "", fmt.Errorf("credential path %s needs the call's domain parameter", path)
+	}
+	if !credentialDomainPattern.MatchString(domain) || strings.Contains(domain, "..") {
+		return "", fmt.Errorf("credential path %s: domain %q is not a host name", path, domain)
+	}
+	return strings.ReplaceAll(path, "{domain}", domain), nil
+}
+
+// credentialDomainPattern matches the domains credential paths take.
+var credentialDomainPattern = regexp.MustCompile(`^[a-z0-9_-][a-z0-9._-]*$`)
+
+// VaultConfig configures a VaultBroker.
+type VaultConfig struct {
+	// Address is the Vault server's URL (e.g., https://vault.vault:8200)
+	Address string
+
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace string
+
+	// TokenFile holds the Vault token, and is read again for each
+	// credential, so that an agent sidecar can renew it. Without it, the
+	// broker logs in with Kubernetes auth.
+	TokenFile string
+
+	// AuthMount is the mount of Vault's Kubernetes auth method
+	// (default "kubernetes")
+	AuthMount string
+
+	// AuthRole is the Vault role the router logs in as with Kubernetes auth
+	AuthRole string
+
+	// JWTFile holds the ServiceAccount token the router logs in with
+	// (default: the token mounted into the pod)
+	JWTFile string
+}
+
+// defaultJWTFile is where Kubernetes mounts the pod's ServiceAccount token.
+const defaultJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultBroker is a CredentialBroker that reads credentials from Vault's
+// secrets engines, such as the dynamic credentials of
+// database/creds/ROLE, whose leases Vault revokes when they expire.
+type VaultBroker struct {
+	config VaultConfig
+	client *http.Client
+
+	mu           sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+// NewVaultBroker returns a broker reading from the Vault of config with
+// client (http.DefaultClient if nil).
+func NewVaultBroker(config VaultConfig, client *http.Client) *VaultBroker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = "kubernetes"
+	}
+	if config.JWTFile == "" {
+		config.JWTFile = defaultJWTFile
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &VaultBroker{config: config, client: client}
+}
+
+// vaultResponse is the envelope of Vault's API responses.
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// Credential implements CredentialBroker.
+func (b *VaultBroker) Credential(ctx context.Context, path string) (*Credential, error) {
+	token, err := b.vaultToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(ctx, http.MethodGet, path, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("vault returned no data for %s", path)
+	}
+
+	credential := &Credential{Data: resp.Data, LeaseID: resp.LeaseID}
+	if resp.LeaseDuration > 0 {
+		credential.ExpiresAt = time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+	}
+	return credential, nil
+}
+
+// vaultToken returns the token of TokenFile, or else of a Kubernetes auth
+// login, which is reused until 90% of its lease has passed.
+func (b *VaultBroker) vaultToken(ctx context.Context) (string, error) {
+	if b.config.TokenFile != "" {
+		token, err := os.ReadFile(b.config.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vault token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token != "" && time.Now().Before(b.tokenExpires) {
+		return b.token, nil
+	}
+
+	jwt, err := os.ReadFile(b.config.JWTFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ServiceAccount token: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{"role": b.config.AuthRole, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return "", err
+	}
+	resp, err := b.do(ctx, http.MethodPost, "auth/"+b.config.AuthMount+"/login", "", body)
+	if err != nil {
+		return "", fmt.Errorf("vault login failed: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault login returned no token")
+	}
+	b.token = resp.Auth.ClientToken
+	b.tokenExpires = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second * 9 / 10)
+	return b.token, nil
+}
+
+// do calls Vault's API at path and decodes its response.
+func (b *VaultBroker) do(ctx context.Context, method, path, token string, body []byte) (*vaultResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.config.Address+"/v1/"+strings.TrimPrefix(path, "/"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if b.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var decoded vaultResponse
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxCredentialResponse)).Decode(&decoded)
+	if resp.StatusCode != http.StatusOK {
+		if len(decoded.Errors) > 0 {
+			return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.Join(decoded.Errors, "; "))
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("invalid response: %w", decodeErr)
+	}
+	return &decoded, nil
+}
+
+// FileBroker is a CredentialBroker that reads credentials from Secrets
+// mounted under a directory, such as those the External Secrets Operator
+// syncs from a secrets manager. The credential of a path is the directory
+// of that path under the root, with a field for each of its files.
+type FileBroker struct {
+	dir string
+}
+
+// NewFileBroker returns a broker reading credentials from under dir.
+func NewFileBroker(dir string) *FileBroker {
+	return &FileBroker{dir: dir}
+}
+
+// Credential implements CredentialBroker.
+func (b *FileBroker) Credential(ctx context.Context, path string) (*Credential, error) {
+	dir := filepath.Join(b.dir, filepath.Clean("/"+path))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	for _, entry := range entries {
+		// Mounted Secrets keep their files under hidden ..data directories
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		value, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = strings.TrimRight(string(value), "\n")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no credential files in %s", dir)
+	}
+	return &Credential{Data: data}, nil
+}
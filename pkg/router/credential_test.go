@@ -0,0 +1,161 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestVaultBroker tests Kubernetes auth logins and credential reads
+// against a fake Vault.
+func TestVaultBroker(t *testing.T) {
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role"] != "router" || login["jwt"] != "sa-token" {
+				http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+			logins++
+			w.Write([]byte(`{"auth":{"client_token":"s.router","lease_duration":3600}}`))
+		case "/v1/database/creds/orders-ro":
+			if r.Header.Get("X-Vault-Token") != "s.router" {
+				http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"lease_id":"database/creds/orders-ro/abc","lease_duration":300,"data":{"username":"v-orders","password":"p"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(jwt, []byte("sa-token\n"), 0o600)
+	broker := NewVaultBroker(VaultConfig{Address: srv.URL + "/", AuthRole: "router", JWTFile: jwt}, nil)
+
+	for i := 0; i < 2; i++ {
+		credential, err := broker.Credential(context.Background(), "database/creds/orders-ro")
+		if err != nil {
+			t.Fatalf("Credential failed: %v", err)
+		}
+		if credential.Data["username"] != "v-orders" || credential.LeaseID != "database/creds/orders-ro/abc" || credential.ExpiresAt.IsZero() {
+			t.Errorf("unexpected credential %+v", credential)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected the login to be reused, got %d logins", logins)
+	}
+	if _, err := broker.Credential(context.Background(), "database/creds/billing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected an unknown path to fail, got %v", err)
+	}
+
+	os.WriteFile(jwt, []byte("other"), 0o600)
+	stale := NewVaultBroker(VaultConfig{Address: srv.URL, AuthRole: "router", JWTFile: jwt}, nil)
+	if _, err := stale.Credential(context.Background(), "database/creds/orders-ro"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected a failed login to fail, got %v", err)
+	}
+}
+
+// TestServerCredentials tests that credentials are injected into the
+// parameters of allowed calls, after the other obligations, and that calls
+// fail closed without them.
+func TestServerCredentials(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+	executor := &mockToolExecutor{result: "ok"}
+	server.SetToolExecutor(executor)
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "db", "orders.corp.example", "..data"), 0o700)
+	os.WriteFile(filepath.Join(dir, "db", "orders.corp.example", "username"), []byte("agent\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "db", "orders.corp.example", "password"), []byte("secret\n"), 0o600)
+	server.SetObligationHandler(policy.ObligationInjectCredential, NewCredentialHandler(NewFileBroker(dir)))
+	var payloads strings.Builder
+	server.SetObligationHandler(policy.ObligationLogPayload, NewPayloadLogHandler(&payloads))
+
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("credential-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "db.query", Action: policy.Allow, Obligations: []policy.Obligation{
+			{Type: policy.ObligationInjectCredential, Params: map[string]string{"path": "db/{domain}", "param": "auth"}},
+			{Type: policy.ObligationLogPayload},
+		}}}, policy.Enforcing, ""))
+
+	execute := func(params string) *agentpb.ExecuteResponse {
+		t.Helper()
+		resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   "db.query",
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+			Parameters: []byte(params),
+		})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := execute(`{"domain":"Orders.corp.example","sql":"select 1"}`)
+		if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+			t.Fatalf("expected success, got %v: %s", resp.GetStatus(), resp.GetError())
+		}
+		auth, _ := executor.params["auth"].(map[string]interface{})
+		if auth["username"] != "agent" || auth["password"] != "secret" || executor.params["sql"] != "select 1" {
+			t.Errorf("expected the credential to be injected, got %v", executor.params)
+		}
+	}
+	if strings.Contains(payloads.String(), "secret") {
+		t.Errorf("expected the payload to be logged before the credential was injected, got %q", payloads.String())
+	}
+
+	executor.params = nil
+	resp := execute(`{"domain":"billing.corp.example"}`)
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR || executor.params != nil {
+		t.Errorf("expected a call without a credential to fail, got %v: %s", resp.GetStatus(), resp.GetError())
+	}
+	resp = execute(`{}`)
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR || !strings.Contains(resp.GetError(), "domain parameter") {
+		t.Errorf("expected a call without a domain to fail, got %v: %s", resp.GetStatus(), resp.GetError())
+	}
+}
+
+// TestCredentialPath verifies the domain of a call is substituted into
+// credential paths only when it is a host name
+func TestCredentialPath(t *testing.T) {
+	tests := []struct {
+		domain  interface{}
+		want    string
+		wantErr bool
+	}{
+		{"API.Example.com.", "secret/data/api.example.com", false},
+		{"münchen.example.com", "secret/data/xn--mnchen-3ya.example.com", false},
+		{"_acme.example.com", "secret/data/_acme.example.com", false},
+		{"anything?#.example.com", "", true},
+		{"a/b.example.com", "", true},
+		{"x#y.example.com", "", true},
+		{"a..example.com", "", true},
+		{"", "", true},
+		{nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := credentialPath("secret/data/{domain}", map[string]interface{}{"domain": tt.domain})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%v: expected %q (error %v), got %q %v", tt.domain, tt.want, tt.wantErr, got, err)
+		}
+	}
+	if got, err := credentialPath("secret/data/static", nil); err != nil || got != "secret/data/static" {
+		t.Errorf("expected a path without {domain} unchanged, got %q %v", got, err)
+	}
+}
@@ -0,0 +1,183 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// dataAPIPrefix is the path prefix of OPA's REST data API.
+const dataAPIPrefix = "/v1/data"
+
+// maxDataAPIBytes bounds the request bodies of the data API.
+const maxDataAPIBytes = 1 << 20
+
+// dataAPISource is the AuditEvent.Source of data API queries.
+const dataAPISource = "dataapi"
+
+// dataAPIRequest is the body of an OPA data API query.
+type dataAPIRequest struct {
+	Input *dataAPIInput `json:"input"`
+}
+
+// dataAPIInput is the input of a query. Its request is kept raw, to be
+// bounded by the server's RequestLimits before it is decoded.
+type dataAPIInput struct {
+	policy.OPAInput
+	Request json.RawMessage `json:"request"`
+}
+
+// dataAPIResponse is the body of an OPA data API answer. Result is omitted
+// for undefined documents, as OPA does.
+type dataAPIResponse struct {
+	DecisionID string      `json:"decision_id,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// dataAPIError is the body of an OPA data API error.
+type dataAPIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// DataAPIHandler serves the engine through OPA's REST data API, so OPA
+// clients, conftest-style integrations, and curl can query it:
+//
+//	POST /v1/data/agentpolicy/decision
+//	{"input": {"tool": "file.read", "request": {...}, "agent": {"type": "coding-assistant", ...}}}
+//
+// answers {"result": {"allow": true, "deny": false, "mts": true, "reason": ...}}
+// with the decision object of the generated Rego (see policy.OPAOutput),
+// whether or not the engine evaluates with OPA. Paths into the document,
+// such as /v1/data/agentpolicy/decision/allow, answer that field. The
+// decision is the engine's effective one, after the enforcement mode, and
+// is audited; an X-Request-ID header is its request ID and decision ID.
+//
+// Queries are held to the rate limits and RequestLimits of Execute calls,
+// and rejected with 429 Too Many Requests and 400 Bad Request past them.
+// A query does not make the call, so it checks the budgets of its policy
+// without charging them, is not counted in the would-deny and canary
+// stats, and is audited with source "dataapi", which apctl profile and
+// apctl generate skip (see policy.ContextWithQuery).
+//
+// The agent identity in the input is trusted as given, so the handler
+// must only be served to trusted enforcement points.
+func (s *Server) DataAPIHandler() http.Handler {
+	return http.HandlerFunc(s.serveDataAPI)
+}
+
+func (s *Server) serveDataAPI(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, dataAPIPrefix)
+	if !ok || (path != "" && !strings.HasPrefix(path, "/")) {
+		writeDataAPIError(w, http.StatusNotFound, "resource_not_found", "unknown path")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeDataAPIError(w, http.StatusMethodNotAllowed, "invalid_parameter", "the data API takes POST requests with an input")
+		return
+	}
+
+	var body dataAPIRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDataAPIBytes))
+	if err := dec.Decode(&body); err != nil {
+		writeDataAPIError(w, http.StatusBadRequest, "invalid_parameter", "malformed request body: "+err.Error())
+		return
+	}
+	if body.Input == nil {
+		writeDataAPIError(w, http.StatusBadRequest, "invalid_parameter", "missing input")
+		return
+	}
+
+	requestID := r.Header.Get("X-Request-ID")
+	input := body.Input
+	metadata := RequestMetadata{
+		AgentType: input.Agent.Type,
+		SandboxID: input.Agent.SandboxID,
+		TenantID:  input.Agent.TenantID,
+		SessionID: input.Agent.SessionID,
+		MTSLabel:  input.Agent.MTSLabel,
+		Labels:    input.Agent.Labels,
+
+		ParentSessionID: input.Agent.ParentSessionID,
+		Lineage:         input.Agent.Lineage,
+	}
+
+	// Rate limit the client before spending any work on its query
+	if key, delay, ok := s.rateLimited(metadata); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		writeDataAPIError(w, http.StatusTooManyRequests, "too_many_requests", "rate limit exceeded for "+key)
+		return
+	}
+
+	// Bound the request before decoding it
+	var request interface{}
+	if len(input.Request) > 0 && string(input.Request) != "null" {
+		if err := s.limits.check(input.Request); err != nil {
+			s.policy.Engine().AuditQuery(dataAPISource, extractAgentIdentity(metadata), input.Tool, policy.Deny, "invalid request: "+err.Error(), requestID)
+			writeDataAPIError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+			return
+		}
+		var params map[string]interface{}
+		if err := json.Unmarshal(input.Request, &params); err != nil {
+			writeDataAPIError(w, http.StatusBadRequest, "invalid_parameter", "malformed input request: "+err.Error())
+			return
+		}
+		request = params
+	}
+
+	ctx := policy.ContextWithQuery(r.Context(), dataAPISource)
+	if requestID != "" {
+		ctx = policy.ContextWithRequestID(ctx, requestID)
+	}
+	result, err := s.policy.EvaluateWithResult(ctx, metadata, input.Tool, request)
+	if err != nil {
+		writeDataAPIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+
+	output := map[string]interface{}{
+		"allow":  result.Decision == policy.Allow,
+		"deny":   result.Decision != policy.Allow,
+		"mts":    !errors.Is(result.Err, policyerrors.ErrMTSViolation),
+		"reason": result.Reason,
+	}
+	if len(result.Obligations) > 0 {
+		obligations := make([]interface{}, len(result.Obligations))
+		for i, o := range result.Obligations {
+			obligations[i] = policy.OPAObligation{Type: o.Type, Params: o.Params}
+		}
+		output["obligations"] = obligations
+	}
+
+	// Walk the path into the data document, which only holds the decision
+	var document interface{} = map[string]interface{}{
+		"agentpolicy": map[string]interface{}{"decision": output},
+	}
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			document = nil
+			break
+		}
+		document = object[segment]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataAPIResponse{DecisionID: requestID, Result: document})
+}
+
+// writeDataAPIError answers an OPA data API request with an error.
+func writeDataAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dataAPIError{Code: code, Message: message})
+}
@@ -0,0 +1,154 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestDataAPI verifies the engine answers OPA data API queries
+func TestDataAPI(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny, []policy.ToolPermission{
+		{Tool: "file.read", Action: policy.Allow},
+	}, policy.Enforcing, ""))
+	handler := server.DataAPIHandler()
+
+	query := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var out map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s %s: malformed response %q", method, path, rec.Body.String())
+		}
+		return rec.Code, out
+	}
+	input := func(tool string) string {
+		return `{"input": {"tool": "` + tool + `", "agent": {"type": "coding-assistant"}}}`
+	}
+
+	code, out := query(http.MethodPost, "/v1/data/agentpolicy/decision", input("file.read"))
+	if code != http.StatusOK || out["decision_id"] != "req-1" {
+		t.Fatalf("unexpected response %d %v", code, out)
+	}
+	decision, ok := out["result"].(map[string]interface{})
+	if !ok || decision["allow"] != true || decision["deny"] != false || decision["mts"] != true {
+		t.Errorf("expected an allow decision, got %v", out["result"])
+	}
+
+	for _, tt := range []struct {
+		path string
+		tool string
+		want interface{}
+	}{
+		{"/v1/data/agentpolicy/decision/allow", "file.write", false},
+		{"/v1/data/agentpolicy/decision/deny", "file.write", true},
+		{"/v1/data/agentpolicy/decision/unknown", "file.read", nil},
+		{"/v1/data/other", "file.read", nil},
+	} {
+		code, out := query(http.MethodPost, tt.path, input(tt.tool))
+		if code != http.StatusOK || out["result"] != tt.want {
+			t.Errorf("%s: expected result %v, got %d %v", tt.path, tt.want, code, out)
+		}
+	}
+	if code, out := query(http.MethodPost, "/v1/data", input("file.read")); code != http.StatusOK || out["result"] == nil {
+		t.Errorf("expected the whole document, got %d %v", code, out)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/v1/data/agentpolicy/decision", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/data/agentpolicy/decision", "{", http.StatusBadRequest},
+		{http.MethodPost, "/v1/data/agentpolicy/decision", "{}", http.StatusBadRequest},
+		{http.MethodPost, "/v1/policies", input("file.read"), http.StatusNotFound},
+	} {
+		if code, out := query(tt.method, tt.path, tt.body); code != tt.want || out["code"] == nil {
+			t.Errorf("%s %s %q: expected error %d, got %d %v", tt.method, tt.path, tt.body, tt.want, code, out)
+		}
+	}
+}
+
+// TestDataAPILimits verifies data API queries are held to the limits of
+// Execute calls, and check budgets without charging them
+func TestDataAPILimits(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.RateLimit = RateLimitConfig{RequestsPerSecond: 0.001, Burst: 3}
+	config.RequestLimits = RequestLimits{MaxDepth: 2}
+	server := NewServer(config)
+	compiled := policy.CompilePolicy("budget-policy", []string{"coding-assistant"}, policy.Deny, []policy.ToolPermission{
+		{Tool: "gpu.run", Action: policy.Allow, Cost: 40},
+	}, policy.Enforcing, "")
+	compiled.Budget = &policy.Budget{Tenant: &policy.BudgetLimit{Limit: 50}}
+	server.LoadPolicy("coding-assistant", compiled)
+	handler := server.DataAPIHandler()
+
+	query := func(sandbox, request string) *httptest.ResponseRecorder {
+		body := `{"input": {"tool": "gpu.run", "request": ` + request + `, "agent": {"type": "coding-assistant", "sandbox_id": "` + sandbox + `", "tenant_id": "team-a"}}}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/data/agentpolicy/decision/allow", strings.NewReader(body)))
+		return rec
+	}
+
+	// A cost of 40 fits the budget of 50 once, but queries charge nothing
+	for i := 0; i < 2; i++ {
+		rec := query("sandbox-1", `{}`)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"result":true`) {
+			t.Fatalf("%d: expected an allow, got %d %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Requests past RequestLimits are rejected before evaluation
+	if rec := query("sandbox-1", `{"a": {"b": {"c": 1}}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a request past the depth limit to be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The sandbox has used its burst of 3
+	rec := query("sandbox-1", `{}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the query to be rate limited, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := query("sandbox-2", `null`); rec.Code != http.StatusOK {
+		t.Errorf("expected another sandbox to have a bucket of its own, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestDataAPIQueries verifies data API queries are audited as queries,
+// and not counted as calls
+func TestDataAPIQueries(t *testing.T) {
+	sink := policy.NewChannelAuditSink(10)
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Permissive
+	config.PolicyConfig.AuditSink = sink
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny, nil, policy.Permissive, ""))
+
+	body := `{"input": {"tool": "file.write", "agent": {"type": "coding-assistant"}}}`
+	rec := httptest.NewRecorder()
+	server.DataAPIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/data/agentpolicy/decision/allow", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"result":true`) {
+		t.Fatalf("expected permissive mode to allow the query, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case event := <-sink.Events():
+		if event.Source != "dataapi" {
+			t.Errorf("expected the query audited with source dataapi, got %q", event.Source)
+		}
+	default:
+		t.Fatal("expected the query to be audited")
+	}
+	if n := server.policy.Engine().WouldDenyCount(); n != 0 {
+		t.Errorf("expected the query not to be counted as a would-deny call, got %d", n)
+	}
+}
@@ -0,0 +1,164 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// drainRetryDelay is the retry hint given to callers rejected while
+// draining. Clients retry on another replica; the delay only matters to
+// clients that can reach no other.
+const drainRetryDelay = time.Second
+
+// drainState tracks in-flight Execute calls so that a draining server
+// can wait for them.
+type drainState struct {
+	draining bool
+	inflight int
+
+	// done is closed when draining starts; idle when the last in-flight
+	// call of a draining server ends
+	done chan struct{}
+	idle chan struct{}
+}
+
+// beginCall admits an Execute call, unless the server is draining.
+func (s *Server) beginCall() bool {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.drain.draining {
+		return false
+	}
+	s.drain.inflight++
+	return true
+}
+
+// endCall ends an admitted Execute call.
+func (s *Server) endCall() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	s.drain.inflight--
+	if s.drain.draining && s.drain.inflight == 0 {
+		close(s.drain.idle)
+	}
+}
+
+// retainCall keeps an admitted call in flight past its RPC, for calls
+// that execute in the background; endCall ends it.
+func (s *Server) retainCall() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	s.drain.inflight++
+}
+
+// Draining reports whether Drain has been called.
+func (s *Server) Draining() bool {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	return s.drain.draining
+}
+
+// InFlight returns the number of Execute calls being evaluated or
+// executed.
+func (s *Server) InFlight() int {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	return s.drain.inflight
+}
+
+// Ready returns an error while the server is draining, so that readiness
+// probes take the replica out of its Service before it stops.
+func (s *Server) Ready() error {
+	if s.Draining() {
+		return errors.New("server is draining")
+	}
+	return s.policy.HealthCheck()
+}
+
+// Drain shuts the server down without failing admitted calls:
+//  1. New Execute calls are rejected with UNAVAILABLE and a retry hint, so
+//     clients fail over to another replica, and WatchPolicy streams end
+//     with UNAVAILABLE so agents resubscribe elsewhere
+//  2. In-flight Execute calls are awaited until ctx is done
+//  3. The audit sink is flushed
+//  4. The gRPC server is stopped, forcibly if ctx is done by then
+//
+// Drain returns ctx's error if in-flight calls were abandoned, or the
+// audit flush error. Calling it again has no effect.
+func (s *Server) Drain(ctx context.Context) error {
+	s.drainMu.Lock()
+	if s.drain.draining {
+		s.drainMu.Unlock()
+		return nil
+	}
+	s.drain.draining = true
+	close(s.drain.done)
+	if s.drain.inflight == 0 {
+		close(s.drain.idle)
+	}
+	s.drainMu.Unlock()
+
+	var err error
+	select {
+	case <-s.drain.idle:
+	case <-ctx.Done():
+		err = fmt.Errorf("abandoned %d in-flight calls: %w", s.InFlight(), ctx.Err())
+	}
+
+	if flushErr := s.policy.Engine().FlushAudit(); flushErr != nil && err == nil {
+		err = fmt.Errorf("failed to flush audit events: %w", flushErr)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+	return err
+}
+
+// DrainOnSignal blocks until the process receives SIGTERM or SIGINT, then
+// drains the server with the given timeout. Kubernetes sends SIGTERM on pod
+// deletion, so the timeout should be below the pod's
+// terminationGracePeriodSeconds. It returns nil without draining if ctx is
+// done first.
+func (s *Server) DrainOnSignal(ctx context.Context, timeout time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-signals:
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Drain(drainCtx)
+}
+
+// drainingError is the status of calls rejected while draining.
+func drainingError() error {
+	st := status.New(codes.Unavailable, "server is draining")
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(drainRetryDelay)})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
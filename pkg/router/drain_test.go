@@ -0,0 +1,103 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// blockingExecutor blocks each call until released.
+type blockingExecutor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingExecutor) Execute(ctx context.Context, toolName string, params map[string]interface{}) (interface{}, error) {
+	e.started <- struct{}{}
+	<-e.release
+	return "ok", nil
+}
+
+// flushSink counts flushes.
+type flushSink struct {
+	policy.NullAuditSink
+	flushes int
+}
+
+func (s *flushSink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+// TestServerDrain verifies draining rejects new calls, waits for in-flight
+// ones, and flushes the audit sink
+func TestServerDrain(t *testing.T) {
+	sink := &flushSink{}
+	config := DefaultServerConfig()
+	config.PolicyConfig.AuditSink = sink
+	server := NewServer(config)
+	executor := &blockingExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Allow, nil, policy.Enforcing, ""))
+
+	req := &agentpb.ExecuteRequest{ToolName: "file.read", Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"}}
+	inflight := make(chan error, 1)
+	go func() {
+		_, err := server.Execute(context.Background(), req)
+		inflight <- err
+	}()
+	<-executor.started
+
+	drained := make(chan error, 1)
+	go func() { drained <- server.Drain(context.Background()) }()
+	for !server.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := server.Execute(context.Background(), req)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected UNAVAILABLE while draining, got %v", err)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("expected Drain to wait for the in-flight call, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(executor.release)
+	if err := <-inflight; err != nil {
+		t.Errorf("expected in-flight call to complete, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("expected clean drain, got %v", err)
+	}
+	if sink.flushes != 1 || server.InFlight() != 0 {
+		t.Errorf("expected one audit flush and no in-flight calls, got %d, %d", sink.flushes, server.InFlight())
+	}
+}
+
+// TestServerDrainDeadline verifies Drain gives up on calls that outlast
+// its deadline
+func TestServerDrainDeadline(t *testing.T) {
+	server := NewServer(DefaultServerConfig())
+	executor := &blockingExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(executor.release)
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Allow, nil, policy.Enforcing, ""))
+
+	go server.Execute(context.Background(), &agentpb.ExecuteRequest{ToolName: "file.read", Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"}})
+	<-executor.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error with a call in flight, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// Labels set by the built-in context enrichers.
+const (
+	// SPIFFEIDLabel is the SPIFFE ID of the caller's client certificate
+	SPIFFEIDLabel = "agents.sandbox.io/spiffe-id"
+
+	// TrustDomainLabel is the trust domain of the caller's SPIFFE ID
+	TrustDomainLabel = "agents.sandbox.io/trust-domain"
+
+	// CountryLabel, ContinentLabel, and ASNLabel are what is known of the
+	// caller's network address
+	CountryLabel   = "agents.sandbox.io/country"
+	ContinentLabel = "agents.sandbox.io/continent"
+	ASNLabel       = "agents.sandbox.io/asn"
+)
+
+// ContextEnricher adds to the AgentContext of a call what a source other
+// than its request metadata knows of the caller, such as the labels of the
+// router's pod or the SPIFFE ID of the caller's certificate. Enrichers run
+// in the order of PolicyConfig.ContextEnrichers, after the caller is
+// identified and before every evaluation, each seeing the context the
+// ones before it left; the policy is resolved on the result, so enriched
+// labels can match agent selectors.
+//
+// Enrichers are trusted: what they set replaces the caller's claims. The
+// context's Labels are the enrichment's own copy and may be written to.
+// An enricher that knows nothing of the caller should leave the context
+// as it is; an error fails the call closed. Enrichers must be safe for
+// concurrent use.
+type ContextEnricher interface {
+	// Name identifies the enricher in errors
+	Name() string
+
+	// Enrich adds what the enricher knows of the caller to agent
+	Enrich(ctx context.Context, agent *policy.AgentContext) error
+}
+
+// EnricherFunc adapts a function, such as a lookup of the caller's claims
+// in an external store, to a ContextEnricher.
+type EnricherFunc struct {
+	// EnricherName is returned by Name
+	EnricherName string
+
+	// Func enriches the context
+	Func func(ctx context.Context, agent *policy.AgentContext) error
+}
+
+// Name returns f.EnricherName.
+func (f EnricherFunc) Name() string {
+	return f.EnricherName
+}
+
+// Enrich calls f.Func.
+func (f EnricherFunc) Enrich(ctx context.Context, agent *policy.AgentContext) error {
+	return f.Func(ctx, agent)
+}
+
+// agentContext builds the AgentContext of a call from its request metadata
+// and runs the context enrichers on it.
+func (r *RouterPolicyIntegration) agentContext(ctx context.Context, metadata RequestMetadata) (policy.AgentContext, error) {
+	agent := extractAgentIdentity(metadata)
+	if len(r.config.ContextEnrichers) == 0 {
+		return agent, nil
+	}
+
+	labels := make(map[string]string, len(agent.Labels))
+	for k, v := range agent.Labels {
+		labels[k] = v
+	}
+	agent.Labels = labels
+	for _, enricher := range r.config.ContextEnrichers {
+		if err := enricher.Enrich(ctx, &agent); err != nil {
+			return policy.AgentContext{}, fmt.Errorf("context enrichment %s: %w", enricher.Name(), err)
+		}
+	}
+	return agent, nil
+}
+
+// podLabelsRefresh is how long the pod labels read from the downward API
+// are used before the file is read again.
+const podLabelsRefresh = 10 * time.Second
+
+// PodLabelsEnricher sets the labels of the router's pod, as a downward
+// API volume projects them, on every call. It suits routers that run in
+// the pod of the one sandbox they serve, such as sidecars, whose pod
+// labels are then the sandbox's, attested by Kubernetes.
+type PodLabelsEnricher struct {
+	path string
+
+	mu     sync.Mutex
+	labels map[string]string
+	readAt time.Time
+}
+
+// NewPodLabelsEnricher returns an enricher of the labels in the downward
+// API file at path (e.g., /etc/podinfo/labels), reading it once to check
+// that it can.
+func NewPodLabelsEnricher(path string) (*PodLabelsEnricher, error) {
+	e := &PodLabelsEnricher{path: path}
+	if _, err := e.podLabels(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Name returns "pod-labels".
+func (e *PodLabelsEnricher) Name() string {
+	return "pod-labels"
+}
+
+// Enrich sets the pod's labels.
+func (e *PodLabelsEnricher) Enrich(_ context.Context, agent *policy.AgentContext) error {
+	labels, err := e.podLabels()
+	if err != nil {
+		return err
+	}
+	for k, v := range labels {
+		agent.Labels[k] = v
+	}
+	return nil
+}
+
+// podLabels returns the pod's labels, reading them again once they are
+// older than podLabelsRefresh. Kubernetes updates the file when the labels
+// change.
+func (e *PodLabelsEnricher) podLabels() (map[string]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.labels != nil && time.Since(e.readAt) < podLabelsRefresh {
+		return e.labels, nil
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod labels: %w", err)
+	}
+	labels, err := parseDownwardAPILabels(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod labels file %s: %w", e.path, err)
+	}
+	e.labels, e.readAt = labels, time.Now()
+	return labels, nil
+}
+
+// parseDownwardAPILabels parses the key="value" lines of a downward API
+// labels file, whose values are quoted as Go strings.
+func parseDownwardAPILabels(data []byte) (map[string]string, error) {
+	labels := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, quoted, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key=\"value\"", n)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %s", n, quoted)
+		}
+		labels[key] = value
+	}
+	return labels, scanner.Err()
+}
+
+// SPIFFEEnricher sets the SPIFFE ID of the client certificate of mTLS
+// callers (SPIFFEIDLabel) and its trust domain (TrustDomainLabel).
+// Callers without one are left as they are.
+type SPIFFEEnricher struct{}
+
+// Name returns "spiffe".
+func (SPIFFEEnricher) Name() string {
+	return "spiffe"
+}
+
+// Enrich sets the caller's SPIFFE ID.
+func (SPIFFEEnricher) Enrich(ctx context.Context, agent *policy.AgentContext) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+	// A SPIFFE X509-SVID has exactly one URI SAN
+	for _, uri := range info.State.PeerCertificates[0].URIs {
+		if uri.Scheme == "spiffe" && uri.Host != "" {
+			agent.Labels[SPIFFEIDLabel] = uri.String()
+			agent.Labels[TrustDomainLabel] = uri.Host
+			return nil
+		}
+	}
+	return nil
+}
+
+// GeoIPEnricher sets what its provider knows of the caller's network
+// address: its country (CountryLabel), continent (ContinentLabel), and
+// autonomous system (ASNLabel). Callers whose address is unknown, or not
+// an IP address, are left as they are.
+type GeoIPEnricher struct {
+	Provider policy.EnrichmentProvider
+}
+
+// Name returns "geoip".
+func (GeoIPEnricher) Name() string {
+	return "geoip"
+}
+
+// Enrich sets what is known of the caller's address.
+func (e GeoIPEnricher) Enrich(ctx context.Context, agent *policy.AgentContext) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	dest, err := e.Provider.LookupIP(ctx, ip)
+	if err != nil {
+		return nil
+	}
+	if dest.Country != "" {
+		agent.Labels[CountryLabel] = dest.Country
+	}
+	if dest.Continent != "" {
+		agent.Labels[ContinentLabel] = dest.Continent
+	}
+	if dest.ASN != 0 {
+		agent.Labels[ASNLabel] = strconv.FormatUint(uint64(dest.ASN), 10)
+	}
+	return nil
+}
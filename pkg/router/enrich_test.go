@@ -0,0 +1,121 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestContextEnrichers tests that the enrichers run in order before
+// evaluation, that their labels select policies, and that their errors
+// fail the call.
+func TestContextEnrichers(t *testing.T) {
+	dir := t.TempDir()
+	labelsFile := filepath.Join(dir, "labels")
+	if err := os.WriteFile(labelsFile, []byte("app=\"sandbox\"\nenvironment=\"production\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	podLabels, err := NewPodLabelsEnricher(labelsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	geo, err := policy.LoadCIDRGeoProvider(strings.NewReader("10.0.0.0/8,DE,EU,64512\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffeID, _ := url.Parse("spiffe://example.org/agents/coding")
+
+	config := DefaultPolicyConfig()
+	config.Mode = policy.Enforcing
+	config.ContextEnrichers = []ContextEnricher{
+		podLabels,
+		SPIFFEEnricher{},
+		GeoIPEnricher{Provider: geo},
+		// Later enrichers see what earlier ones set
+		EnricherFunc{EnricherName: "claims", Func: func(_ context.Context, agent *policy.AgentContext) error {
+			if agent.Labels[TrustDomainLabel] == "example.org" {
+				agent.Labels["team"] = "platform"
+			}
+			return nil
+		}},
+	}
+	integration := NewRouterPolicyIntegration(config)
+	selected := policy.CompilePolicy("production", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, "")
+	selected.AgentSelector = &policy.LabelSelector{MatchLabels: map[string]string{
+		"environment": "production", CountryLabel: "DE", "team": "platform",
+	}}
+	integration.LoadPolicy("coding-assistant", selected)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 4000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{spiffeID}}},
+		}},
+	})
+	claimed := map[string]string{"environment": "staging"}
+	metadata := RequestMetadata{AgentType: "coding-assistant", SandboxID: "sb-1", Labels: claimed}
+
+	agent, err := integration.agentContext(ctx, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"app": "sandbox", "environment": "production", SPIFFEIDLabel: "spiffe://example.org/agents/coding",
+		TrustDomainLabel: "example.org", CountryLabel: "DE", ContinentLabel: "EU", ASNLabel: "64512", "team": "platform",
+	} {
+		if agent.Labels[key] != want {
+			t.Errorf("label %s: expected %q, got %q", key, want, agent.Labels[key])
+		}
+	}
+	if claimed["environment"] != "staging" || len(claimed) != 1 {
+		t.Errorf("expected the request's labels not to be modified, got %v", claimed)
+	}
+
+	result, err := integration.EvaluateWithResult(ctx, metadata, "file.read", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Decision != policy.Allow || result.Policy != "production" {
+		t.Errorf("expected the enriched labels to select the policy, got %s by %q", result.Decision, result.Policy)
+	}
+
+	// Without a peer, the SPIFFE and GeoIP enrichers add nothing
+	if result, _ := integration.EvaluateWithResult(context.Background(), metadata, "file.read", nil); result.Decision != policy.Deny {
+		t.Errorf("expected no policy to apply without the peer's labels, got %s", result.Decision)
+	}
+
+	failing := NewRouterPolicyIntegration(DefaultPolicyConfig())
+	failing.config.ContextEnrichers = []ContextEnricher{EnricherFunc{EnricherName: "claims", Func: func(context.Context, *policy.AgentContext) error {
+		return errors.New("claim store unavailable")
+	}}}
+	if _, err := failing.EvaluateWithResult(ctx, metadata, "file.read", nil); err == nil || !strings.Contains(err.Error(), "context enrichment claims: claim store unavailable") {
+		t.Errorf("expected the enrichment error, got %v", err)
+	}
+}
+
+// TestParseDownwardAPILabels tests parsing of downward API labels files.
+func TestParseDownwardAPILabels(t *testing.T) {
+	labels, err := parseDownwardAPILabels([]byte("app=\"web\"\nnote=\"a \\\"quoted\\\" value\"\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels["app"] != "web" || labels["note"] != `a "quoted" value` {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if _, err := parseDownwardAPILabels([]byte("app=web\n")); err == nil {
+		t.Error("expected an unquoted value to be rejected")
+	}
+}
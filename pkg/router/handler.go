@@ -28,8 +28,6 @@ import (
 	"context"
 
 	"github.com/golden-agent/golden-agent/pkg/policy"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // ExecuteRequest represents a tool execution request (internal format).
@@ -43,6 +41,10 @@ type ExecuteRequest struct {
 
 	// Metadata contains agent identity and context
 	Metadata RequestMetadata
+
+	// Obligations are set by the router from the policy decision before
+	// routing; the sandbox must fulfil them or fail the call
+	Obligations []policy.Obligation
 }
 
 // ExecuteResponse represents the result of a tool execution (internal format).
@@ -91,7 +93,7 @@ func (r *ToolRouter) Execute(ctx context.Context, req *ExecuteRequest) (*Execute
 	// ============================================================
 
 	// Evaluate the request against loaded policies
-	decision, err := r.policy.Evaluate(
+	result, err := r.policy.EvaluateWithResult(
 		ctx,
 		req.Metadata,
 		req.ToolName,
@@ -99,17 +101,14 @@ func (r *ToolRouter) Execute(ctx context.Context, req *ExecuteRequest) (*Execute
 	)
 	if err != nil {
 		// Policy evaluation error - fail closed (deny)
-		return nil, status.Errorf(codes.Internal,
-			"policy evaluation failed: %v", err)
+		return nil, evaluationError(err)
 	}
 
 	// Check the policy decision
-	if decision == policy.Deny {
+	if result.Decision == policy.Deny {
 		// Policy denied the request - return PermissionDenied
 		// The audit event has already been logged by the policy engine
-		return nil, status.Errorf(codes.PermissionDenied,
-			"tool %q denied by policy for agent type %q",
-			req.ToolName, req.Metadata.AgentType)
+		return nil, denyError(req.ToolName, req.Metadata.AgentType, result)
 	}
 
 	// ============================================================
@@ -125,7 +124,16 @@ func (r *ToolRouter) Execute(ctx context.Context, req *ExecuteRequest) (*Execute
 		}, nil
 	}
 
-	// Route the request to the sandbox
+	// Route the request to the sandbox, with the parameters rewritten by
+	// the tool rule's mutators if any, and the decision's obligations
+	if result.Parameters != nil || len(result.Obligations) > 0 {
+		routed := *req
+		if result.Parameters != nil {
+			routed.Parameters = result.Parameters
+		}
+		routed.Obligations = result.Obligations
+		req = &routed
+	}
 	return r.routeToSandbox(ctx, req)
 }
 
@@ -0,0 +1,208 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// ErrTransient marks execution errors worth retrying under the retry
+// constraint of tool rules. Executors wrap failures such as dropped
+// connections with it, e.g. fmt.Errorf("%w: %v", router.ErrTransient, err).
+var ErrTransient = errors.New("transient failure")
+
+// IsTransient reports whether an execution error is transient: it wraps
+// ErrTransient, or reports itself temporary as some net errors do.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrTransient) {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// executionOutcome is the outcome of the executions of an allowed call.
+type executionOutcome struct {
+	result   interface{}
+	err      error
+	elapsed  time.Duration
+	attempts int
+}
+
+// executeWithRetry runs an allowed call, and runs it again while it fails
+// with a transient error and the tool rule's retry policy allows another
+// attempt, waiting its backoff, doubled after each retry. Every attempt
+// carries the call's request ID (see policy.RequestIDFromContext), with
+// which executors avoid applying a write twice.
+func (s *Server) executeWithRetry(ctx context.Context, call *ToolCall, delegated []policy.Obligation, timeout time.Duration, retry *policy.RetryPolicy) executionOutcome {
+	var out executionOutcome
+	var backoff time.Duration
+	if retry != nil {
+		backoff = retry.Backoff
+	}
+	for {
+		result, elapsed, err := s.executeTool(ctx, call, delegated, timeout)
+		out.result, out.err = result, err
+		out.elapsed += elapsed
+		out.attempts++
+		if err == nil || retry == nil || out.attempts >= retry.MaxAttempts || !IsTransient(err) {
+			return out
+		}
+
+		s.policy.log.LogAttrs(ctx, slog.LevelInfo, "retrying tool execution",
+			slog.String(policy.LogKeyRequestID, call.RequestID),
+			slog.String(policy.LogKeyTool, call.ToolName),
+			slog.Int("attempt", out.attempts),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err),
+		)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return out
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// idempotencyCache remembers the outcomes of executed calls by request ID
+// for a window, so that a call retried with the request ID of one already
+// executed gets its outcome instead of executing again. A retry arriving
+// while the call executes waits for it.
+type idempotencyCache struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	calls     map[string]*idempotentCall
+	nextSweep time.Time
+}
+
+// idempotentCall is a call executing, or executed, under a request ID.
+type idempotentCall struct {
+	cache      *idempotencyCache
+	key        string
+	paramsHash string
+
+	done     chan struct{}
+	finished bool
+	executed bool
+	outcome  executionOutcome
+	expires  time.Time
+}
+
+// newIdempotencyCache returns a cache remembering outcomes for window, or
+// nil if window is not positive.
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	if window <= 0 {
+		return nil
+	}
+	return &idempotencyCache{window: window, calls: make(map[string]*idempotentCall)}
+}
+
+// idempotencyKey scopes a request ID to its caller and tool, so that
+// callers cannot replay each other's calls.
+func idempotencyKey(md RequestMetadata, req *agentpb.ExecuteRequest) string {
+	return identityHash(md) + "/" + req.GetToolName() + "/" + req.GetRequestId()
+}
+
+// begin starts the call of key, which the caller then completes or
+// abandons, or returns the outcome of the call already executed under it.
+// Reusing a request ID for other parameters fails with
+// FAILED_PRECONDITION. Calls without a request ID are not remembered.
+func (c *idempotencyCache) begin(ctx context.Context, md RequestMetadata, req *agentpb.ExecuteRequest, toolReq *policy.ToolRequest) (*idempotentCall, *executionOutcome, error) {
+	if c == nil || req.GetRequestId() == "" {
+		return nil, nil, nil
+	}
+	hash, err := paramsHash(toolReq)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid parameters: %v", err)
+	}
+	key := idempotencyKey(md, req)
+
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		c.sweep(now)
+		call, ok := c.calls[key]
+		if !ok {
+			call = &idempotentCall{cache: c, key: key, paramsHash: hash, done: make(chan struct{})}
+			c.calls[key] = call
+			c.mu.Unlock()
+			return call, nil, nil
+		}
+		c.mu.Unlock()
+
+		if call.paramsHash != hash {
+			return nil, nil, status.Errorf(codes.FailedPrecondition, "request ID %q was used for a call with other parameters", req.GetRequestId())
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, status.FromContextError(ctx.Err()).Err()
+		case <-call.done:
+		}
+		if call.executed {
+			outcome := call.outcome
+			return nil, &outcome, nil
+		}
+		// The call was abandoned before it executed; run this one instead
+	}
+}
+
+// sweep forgets the outcomes past the window, at most every tenth of it.
+func (c *idempotencyCache) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	c.nextSweep = now.Add(c.window / 10)
+	for key, call := range c.calls {
+		if call.finished && now.After(call.expires) {
+			delete(c.calls, key)
+		}
+	}
+}
+
+// complete records the outcome of the executed call for the window.
+func (k *idempotentCall) complete(outcome executionOutcome) {
+	if k == nil {
+		return
+	}
+	c := k.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k.finished {
+		return
+	}
+	k.finished, k.executed, k.outcome = true, true, outcome
+	k.expires = time.Now().Add(c.window)
+	close(k.done)
+}
+
+// abandon forgets a call that was not executed, such as one whose
+// obligations were not fulfilled, so that a retry is evaluated and
+// executed afresh.
+func (k *idempotentCall) abandon() {
+	if k == nil {
+		return
+	}
+	c := k.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k.finished {
+		return
+	}
+	k.finished = true
+	if c.calls[k.key] == k {
+		delete(c.calls, k.key)
+	}
+	close(k.done)
+}
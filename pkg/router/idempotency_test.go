@@ -0,0 +1,148 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// flakyExecutor fails its first calls with err, and counts its calls and
+// the request IDs they carried.
+type flakyExecutor struct {
+	mu         sync.Mutex
+	failures   int
+	err        error
+	delay      time.Duration
+	calls      int
+	requestIDs []string
+}
+
+func (e *flakyExecutor) Execute(ctx context.Context, toolName string, params map[string]interface{}) (interface{}, error) {
+	time.Sleep(e.delay)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	requestID, _ := policy.RequestIDFromContext(ctx)
+	e.requestIDs = append(e.requestIDs, requestID)
+	if e.calls <= e.failures {
+		return nil, e.err
+	}
+	return map[string]interface{}{"written": params["path"]}, nil
+}
+
+func newRetryServer(t *testing.T, window time.Duration, executor ToolExecutor) *Server {
+	t.Helper()
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.IdempotencyWindow = window
+	server := NewServer(config)
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("retry-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.write", Action: policy.Allow, Constraints: &policy.ToolConstraints{
+			Retry: &policy.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+		}}}, policy.Enforcing, ""))
+	return server
+}
+
+func writeRequest(requestID, path string) *agentpb.ExecuteRequest {
+	return &agentpb.ExecuteRequest{
+		ToolName:   "file.write",
+		RequestId:  requestID,
+		Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-1"},
+		Parameters: []byte(fmt.Sprintf(`{"path":%q}`, path)),
+	}
+}
+
+// TestExecuteRetry tests that transient failures are retried up to the
+// tool rule's attempts, with the call's request ID, and others are not.
+func TestExecuteRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		status   agentpb.ExecutionStatus
+		attempts int32
+	}{
+		{"transient", 2, fmt.Errorf("%w: connection reset", ErrTransient), agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS, 3},
+		{"transient past attempts", 3, fmt.Errorf("%w: connection reset", ErrTransient), agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR, 3},
+		{"permanent", 2, errors.New("permission denied"), agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &flakyExecutor{failures: tt.failures, err: tt.err}
+			server := newRetryServer(t, 0, executor)
+
+			resp, err := server.Execute(context.Background(), writeRequest("req-1", "/workspace/a"))
+			if err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if resp.GetStatus() != tt.status || resp.GetAttempts() != tt.attempts || executor.calls != int(tt.attempts) {
+				t.Errorf("expected %v after %d attempts, got %v after %d (%d calls): %s", tt.status, tt.attempts, resp.GetStatus(), resp.GetAttempts(), executor.calls, resp.GetError())
+			}
+			for _, id := range executor.requestIDs {
+				if id != "req-1" {
+					t.Errorf("expected every attempt to carry the request ID, got %q", id)
+				}
+			}
+		})
+	}
+}
+
+// TestExecuteIdempotency tests that calls resent with the request ID of an
+// executed call get its outcome without executing again, including while
+// it is still running, and that the request ID cannot be reused for other
+// parameters.
+func TestExecuteIdempotency(t *testing.T) {
+	executor := &flakyExecutor{delay: 20 * time.Millisecond}
+	server := newRetryServer(t, time.Minute, executor)
+
+	var wg sync.WaitGroup
+	responses := make([]*agentpb.ExecuteResponse, 3)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := server.Execute(context.Background(), writeRequest("req-1", "/workspace/a"))
+			if err != nil {
+				t.Errorf("Execute failed: %v", err)
+			}
+			responses[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	if executor.calls != 1 {
+		t.Fatalf("expected a single execution, got %d", executor.calls)
+	}
+	replayed := 0
+	for _, resp := range responses {
+		if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS || string(resp.GetResult()) != `{"written":"/workspace/a"}` {
+			t.Errorf("expected the outcome of the execution, got %v: %s", resp.GetStatus(), resp.GetResult())
+		}
+		if resp.GetReplayed() {
+			replayed++
+		}
+	}
+	if replayed != 2 {
+		t.Errorf("expected 2 replayed responses, got %d", replayed)
+	}
+
+	_, err := server.Execute(context.Background(), writeRequest("req-1", "/workspace/b"))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected a reused request ID to fail, got %v", err)
+	}
+	if resp, err := server.Execute(context.Background(), writeRequest("req-2", "/workspace/a")); err != nil || resp.GetReplayed() || executor.calls != 2 {
+		t.Errorf("expected another request ID to execute, got %v (%v)", resp, err)
+	}
+	if resp, err := server.Execute(context.Background(), writeRequest("", "/workspace/a")); err != nil || resp.GetReplayed() || executor.calls != 3 {
+		t.Errorf("expected a call without a request ID to execute, got %v (%v)", resp, err)
+	}
+}
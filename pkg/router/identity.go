@@ -0,0 +1,99 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/golden-agent/golden-agent/pkg/controller"
+)
+
+// identify returns the identity of a caller from its request metadata:
+// peer attestation for Unix socket callers, then the IdentityAuthenticator,
+// if any.
+func (s *Server) identify(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+	md, err := s.attestPeer(ctx, md)
+	if err != nil || s.identity == nil {
+		return md, err
+	}
+	authenticated, err := s.identity.Authenticate(ctx, md)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return RequestMetadata{}, err
+		}
+		return RequestMetadata{}, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+	}
+	return authenticated, nil
+}
+
+// tokenReviewIdentity authenticates callers by the ServiceAccount token in
+// their "authorization: Bearer" gRPC metadata.
+type tokenReviewIdentity struct {
+	auth *controller.TokenReviewAuthenticator
+}
+
+// Authenticate replaces the claimed identity with the one the token's
+// AgentIdentityBinding maps to.
+func (t tokenReviewIdentity) Authenticate(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return RequestMetadata{}, status.Error(codes.Unauthenticated, "service account token required (authorization: Bearer metadata)")
+	}
+
+	identity, err := t.auth.Authenticate(ctx, token)
+	switch {
+	case errors.Is(err, controller.ErrTokenInvalid):
+		return RequestMetadata{}, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, controller.ErrIdentityNotBound):
+		return RequestMetadata{}, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return RequestMetadata{}, status.Errorf(codes.Unavailable, "failed to authenticate token: %v", err)
+	}
+	return bindIdentity(md, identity)
+}
+
+// bindIdentity applies an attested identity to request metadata. Claimed
+// identity fields must match it, as they must match a session's; the
+// claimed labels are replaced, and the sandbox ID, if not claimed or
+// attested, is the pod the token is bound to.
+func bindIdentity(md RequestMetadata, identity *controller.AgentIdentity) (RequestMetadata, error) {
+	for _, f := range []struct{ name, claimed, bound string }{
+		{"agent_type", md.AgentType, identity.AgentType},
+		{"tenant_id", md.TenantID, identity.TenantID},
+		{"mts_label", md.MTSLabel, identity.MTSLabel},
+	} {
+		if f.claimed != "" && f.claimed != f.bound {
+			return RequestMetadata{}, status.Errorf(codes.PermissionDenied,
+				"metadata.%s does not match service account %s", f.name, identity.ServiceAccount)
+		}
+	}
+
+	md.AgentType = identity.AgentType
+	md.TenantID = identity.TenantID
+	md.MTSLabel = identity.MTSLabel
+	md.Labels = identity.Labels
+	if md.SandboxID == "" {
+		md.SandboxID = identity.PodName
+	}
+	return md, nil
+}
+
+// bearerToken returns the bearer token of the call's "authorization"
+// metadata.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, v := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(v, " ")
+		if ok && strings.EqualFold(scheme, "bearer") && strings.TrimSpace(token) != "" {
+			return strings.TrimSpace(token), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/controller"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestServerIdentityAuthenticator verifies calls are evaluated as the
+// authenticated identity, and refused when authentication fails
+func TestServerIdentityAuthenticator(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.IdentityAuthenticator = SessionAuthenticatorFunc(func(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+		token, ok := bearerToken(ctx)
+		if !ok || token != "coding-token" {
+			return md, errors.New("unknown token")
+		}
+		md.AgentType = "coding-assistant"
+		return md, nil
+	})
+	server := NewServer(config)
+	server.SetToolExecutor(&sessionExecutor{})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	authenticated := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer coding-token"))
+	req := &agentpb.ExecuteRequest{ToolName: "file.read", Metadata: &agentpb.RequestMetadata{AgentType: "claimed-agent"}}
+	resp, err := server.Execute(authenticated, req)
+	if err != nil || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("expected call evaluated as the authenticated agent type to succeed, got %v (%v)", resp, err)
+	}
+
+	if _, err := server.Execute(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected UNAUTHENTICATED without a token, got %v", err)
+	}
+	if _, err := server.ListAllowedTools(context.Background(), &agentpb.ListAllowedToolsRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+	}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected ListAllowedTools to be authenticated, got %v", err)
+	}
+}
+
+// TestBindIdentity verifies the bound identity replaces the claimed one,
+// and conflicting claims are refused
+func TestBindIdentity(t *testing.T) {
+	identity := &controller.AgentIdentity{
+		ServiceAccount: client.ObjectKey{Namespace: "team-a", Name: "coding-agent"},
+		PodName:        "coding-agent-7d9f8",
+		AgentType:      "coding-assistant",
+		TenantID:       "team-a",
+		Labels:         map[string]string{"env": "prod"},
+	}
+
+	md, err := bindIdentity(RequestMetadata{AgentType: "coding-assistant", Labels: map[string]string{"env": "spoofed"}, Locale: "fr"}, identity)
+	if err != nil {
+		t.Fatalf("bindIdentity failed: %v", err)
+	}
+	if md.TenantID != "team-a" || md.Labels["env"] != "prod" || md.SandboxID != "coding-agent-7d9f8" || md.Locale != "fr" {
+		t.Errorf("expected bound identity with claimed locale, got %+v", md)
+	}
+
+	if md, _ := bindIdentity(RequestMetadata{SandboxID: "sandbox-1"}, identity); md.SandboxID != "sandbox-1" {
+		t.Errorf("expected attested sandbox ID to be kept, got %q", md.SandboxID)
+	}
+
+	for _, claimed := range []RequestMetadata{{AgentType: "admin-agent"}, {TenantID: "team-b"}, {MTSLabel: "s0:c1"}} {
+		if _, err := bindIdentity(claimed, identity); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("expected PERMISSION_DENIED for %+v, got %v", claimed, err)
+		}
+	}
+}
+
+// TestBearerToken verifies the token is read from authorization metadata
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer  abc ", "abc", true},
+		{"Basic abc", "", false},
+		{"Bearer ", "", false},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", tt.header))
+		if token, ok := bearerToken(ctx); token != tt.token || ok != tt.ok {
+			t.Errorf("%q: expected (%q, %v), got (%q, %v)", tt.header, tt.token, tt.ok, token, ok)
+		}
+	}
+	if _, ok := bearerToken(context.Background()); ok {
+		t.Error("expected no token without metadata")
+	}
+}
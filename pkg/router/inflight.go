@@ -0,0 +1,83 @@
+package router
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inflightTracker counts the executions in flight per client and tool, for
+// the maxConcurrent constraint of tool rules.
+type inflightTracker struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{counts: make(map[string]int)}
+}
+
+// acquire takes one of the limit execution slots of key, or returns false
+// if all are taken.
+func (t *inflightTracker) acquire(key string, limit int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts[key] >= limit {
+		return false
+	}
+	t.counts[key]++
+	return true
+}
+
+// release returns a slot taken by acquire.
+func (t *inflightTracker) release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts[key] <= 1 {
+		delete(t.counts, key)
+		return
+	}
+	t.counts[key]--
+}
+
+// inflightKey returns the key of a client's executions of a tool: its
+// sandbox, or its session if it has no sandbox ID, or else its agent type.
+func inflightKey(md RequestMetadata, toolName string) string {
+	switch {
+	case md.SandboxID != "":
+		return "sandbox/" + md.SandboxID + "/" + toolName
+	case md.SessionID != "":
+		return "session/" + md.SessionID + "/" + toolName
+	default:
+		return "agentType/" + md.AgentType + "/" + toolName
+	}
+}
+
+// acquireExecution takes an execution slot of a call whose tool rule
+// allows at most limit executions in flight (0 means no bound), and
+// returns the function releasing it. A call past the limit is rejected
+// with a RESOURCE_EXHAUSTED status carrying QuotaFailure details.
+func (s *Server) acquireExecution(md RequestMetadata, toolName string, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	key := inflightKey(md, toolName)
+	if s.inflight.acquire(key, limit) {
+		return func() { s.inflight.release(key) }, nil
+	}
+
+	st := status.Newf(codes.ResourceExhausted, "too many concurrent executions of %s", key)
+	withDetails, err := st.WithDetails(&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+		Subject:     key,
+		Description: fmt.Sprintf("at most %d concurrent executions allowed", limit),
+	}}})
+	if err != nil {
+		return nil, st.Err()
+	}
+	return nil, withDetails.Err()
+}
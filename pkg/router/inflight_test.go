@@ -0,0 +1,74 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestServerMaxConcurrent verifies calls past a tool's concurrency bound
+// get RESOURCE_EXHAUSTED while other sandboxes and tools are unaffected
+func TestServerMaxConcurrent(t *testing.T) {
+	server := NewServer(DefaultServerConfig())
+	executor := &blockingExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Allow, []policy.ToolPermission{
+		{Tool: "code.execute", Action: policy.Allow, Constraints: &policy.ToolConstraints{MaxConcurrent: 1}},
+	}, policy.Enforcing, ""))
+
+	execute := func(sandbox, tool string) error {
+		_, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{ToolName: tool,
+			Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: sandbox}})
+		return err
+	}
+
+	inflight := make(chan error, 1)
+	go func() { inflight <- execute("sandbox-a", "code.execute") }()
+	<-executor.started
+
+	err := execute("sandbox-a", "code.execute")
+	st, _ := status.FromError(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED past the bound, got %v", err)
+	}
+	var quota *errdetails.QuotaFailure
+	for _, d := range st.Details() {
+		if q, ok := d.(*errdetails.QuotaFailure); ok {
+			quota = q
+		}
+	}
+	if quota == nil || quota.GetViolations()[0].GetSubject() != "sandbox/sandbox-a/code.execute" {
+		t.Errorf("expected QuotaFailure for the sandbox's executions, got %v", st.Details())
+	}
+
+	// Other sandboxes and unbounded tools run alongside
+	alongside := make(chan error, 2)
+	go func() { alongside <- execute("sandbox-b", "code.execute") }()
+	go func() { alongside <- execute("sandbox-a", "file.read") }()
+	<-executor.started
+	<-executor.started
+
+	for i := 0; i < 3; i++ {
+		executor.release <- struct{}{}
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-alongside; err != nil {
+			t.Errorf("unexpected error alongside: %v", err)
+		}
+	}
+	if err := <-inflight; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go func() { inflight <- execute("sandbox-a", "code.execute") }()
+	<-executor.started
+	executor.release <- struct{}{}
+	if err := <-inflight; err != nil {
+		t.Errorf("expected the slot to be released, got %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+package router
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// denialLog is an audit sink that keeps the most recent denials, for the
+// inspection UI.
+type denialLog struct {
+	mu     sync.Mutex
+	events []policy.AuditEvent // ring buffer
+	next   int
+	full   bool
+}
+
+func newDenialLog(size int) *denialLog {
+	return &denialLog{events: make([]policy.AuditEvent, size)}
+}
+
+// Log implements policy.AuditSink.
+func (l *denialLog) Log(event *policy.AuditEvent) {
+	if event.Decision == policy.Allow {
+		return
+	}
+	l.mu.Lock()
+	l.events[l.next] = *event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.mu.Unlock()
+}
+
+// recent returns the kept denials, newest first.
+func (l *denialLog) recent() []policy.AuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.events)
+	}
+	out := make([]policy.AuditEvent, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.events[(l.next-i+len(l.events))%len(l.events)])
+	}
+	return out
+}
+
+// inspectPolicy is a loaded policy as the inspection UI lists it.
+type inspectPolicy struct {
+	AgentType string
+	Policy    *policy.CompiledPolicy
+	Rules     []*policy.ToolPermission
+	Canary    string
+}
+
+// constraints renders the constraints of a rule as JSON, without the
+// values the policy read from Secrets.
+func (p inspectPolicy) Constraints(rule *policy.ToolPermission) string {
+	if rule.Constraints == nil {
+		return ""
+	}
+	data, err := json.Marshal(rule.Constraints)
+	if err != nil {
+		return err.Error()
+	}
+	return redactSecretValues(string(data), p.Policy)
+}
+
+// redactSecretValues replaces the values p read from Secrets in s.
+func redactSecretValues(s string, p *policy.CompiledPolicy) string {
+	for _, v := range p.SecretValues {
+		s = strings.ReplaceAll(s, v, "<redacted>")
+	}
+	return s
+}
+
+// simulation is the form and result of a simulated call.
+type simulation struct {
+	AgentType, SandboxID, TenantID, SessionID, MTSLabel, Tool, Params string
+
+	// Trace asks for the OPA trace of the simulated call
+	Trace bool
+
+	Explanation *policy.Explanation
+	Error       string
+}
+
+// InspectHandler serves a read-only web UI for operators debugging a live
+// router: the loaded policies and their generated Rego, cache statistics,
+// the recent denials (if PolicyConfig.RecentDenials is set), and a form
+// that simulates a call with Explain, which neither caches, charges, nor
+// audits it. /snapshot serves the engine snapshot a new replica restores
+// (see PolicyConfig.SnapshotFrom), with its cached decisions for
+// ?cache=true, and /breakers the state of the tools' circuit breakers as
+// JSON. Everything is served with GET; nothing changes the router.
+//
+// The UI shows policies and denied calls, so it must only be served on a
+// diagnostics address reachable by operators.
+func (s *Server) InspectHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveInspectIndex)
+	mux.HandleFunc("/policy", s.serveInspectPolicy)
+	mux.HandleFunc("/simulate", s.serveInspectSimulate)
+	mux.HandleFunc("/snapshot", s.serveInspectSnapshot)
+	mux.HandleFunc("/breakers", s.serveInspectBreakers)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "the inspection UI is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) serveInspectIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	engine := s.policy.Engine()
+	hits, misses, hitRate := engine.CacheStats()
+	memoHits, memoMisses := engine.OPAMemoStats()
+
+	var policies []inspectPolicy
+	agentTypes := engine.ListPolicies()
+	sort.Strings(agentTypes)
+	for _, agentType := range agentTypes {
+		p, ok := engine.GetPolicy(agentType)
+		if !ok {
+			continue
+		}
+		entry := inspectPolicy{AgentType: agentType, Policy: p}
+		if canary, percent, ok := engine.Canary(policyRefOf(p)); ok {
+			entry.Canary = canary.Name + " at " + strconv.Itoa(percent) + "%"
+		}
+		policies = append(policies, entry)
+	}
+
+	var denials []policy.AuditEvent
+	if s.policy.denials != nil {
+		denials = s.policy.denials.recent()
+	}
+	ready := "ready"
+	if err := s.Ready(); err != nil {
+		ready = err.Error()
+	}
+	s.renderInspect(w, "index", map[string]interface{}{
+		"Mode":          engine.Mode(),
+		"OPA":           engine.IsOPAEnabled(),
+		"Ready":         ready,
+		"CacheHits":     hits,
+		"CacheMisses":   misses,
+		"CacheHitRate":  hitRate * 100,
+		"MemoHits":      memoHits,
+		"MemoMisses":    memoMisses,
+		"Policies":      policies,
+		"Denials":       denials,
+		"DenialsKept":   s.policy.denials != nil,
+		"Simulation":    simulation{},
+		"GeneratedTime": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func (s *Server) serveInspectPolicy(w http.ResponseWriter, r *http.Request) {
+	agentType := r.URL.Query().Get("agentType")
+	p, ok := s.policy.Engine().GetPolicy(agentType)
+	if !ok {
+		http.Error(w, "no policy is loaded for agent type "+agentType, http.StatusNotFound)
+		return
+	}
+	entry := inspectPolicy{AgentType: agentType, Policy: p}
+	tools := make([]string, 0, len(p.ToolTable))
+	for tool := range p.ToolTable {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		entry.Rules = append(entry.Rules, p.ToolTable[tool])
+	}
+	s.renderInspect(w, "policy", entry)
+}
+
+func (s *Server) serveInspectSimulate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sim := simulation{
+		AgentType: q.Get("agentType"),
+		SandboxID: q.Get("sandboxId"),
+		TenantID:  q.Get("tenantId"),
+		SessionID: q.Get("sessionId"),
+		MTSLabel:  q.Get("mtsLabel"),
+		Tool:      q.Get("tool"),
+		Params:    q.Get("params"),
+		Trace:     q.Get("trace") != "",
+	}
+
+	var params map[string]interface{}
+	if strings.TrimSpace(sim.Params) != "" {
+		if err := json.Unmarshal([]byte(sim.Params), &params); err != nil {
+			sim.Error = "parameters must be a JSON object: " + err.Error()
+		}
+	}
+	if sim.Error == "" && (sim.AgentType == "" || sim.Tool == "") {
+		sim.Error = "an agent type and a tool are required"
+	}
+	if sim.Error == "" {
+		agent := policy.AgentContext{
+			AgentType: sim.AgentType,
+			SandboxID: sim.SandboxID,
+			TenantID:  sim.TenantID,
+			SessionID: sim.SessionID,
+			MTSLabel:  sim.MTSLabel,
+		}
+		ctx := r.Context()
+		if sim.Trace {
+			ctx = policy.ContextWithTrace(ctx)
+		}
+		engine := s.policy.engineFor(agent.TenantID)
+		explanation, err := engine.Explain(ctx, agent, sim.Tool, params)
+		if err != nil {
+			sim.Error = err.Error()
+		}
+		if p, ok := engine.ResolvePolicy(agent); ok && explanation != nil {
+			// The trace holds the Rego, and so the values read from Secrets
+			explanation.Trace = redactSecretValues(explanation.Trace, p)
+		}
+		sim.Explanation = explanation
+	}
+	s.renderInspect(w, "simulate", sim)
+}
+
+func (s *Server) serveInspectBreakers(w http.ResponseWriter, r *http.Request) {
+	breakers := s.CircuitBreakers()
+	if breakers == nil {
+		breakers = []BreakerStatus{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(breakers); err != nil {
+		s.policy.log.Error("failed to write circuit breakers", "error", err)
+	}
+}
+
+// renderInspect renders a page of the inspection UI.
+func (s *Server) renderInspect(w http.ResponseWriter, page string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := inspectTemplates.ExecuteTemplate(w, page, data); err != nil {
+		s.policy.log.Error("failed to render inspection page", "page", page, "error", err)
+	}
+}
+
+// policyRefOf names a compiled policy as canaries name their stable
+// policy.
+func policyRefOf(p *policy.CompiledPolicy) string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
+
+var inspectTemplates = template.Must(template.New("inspect").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>golden-agent router</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.deny { color: #b00020; font-weight: bold; }
+.allow { color: #1b5e20; font-weight: bold; }
+form label { display: inline-block; width: 9em; }
+</style></head><body>
+<p><a href="/">Overview</a></p>
+{{end}}
+
+{{define "form"}}
+<form action="/simulate" method="get">
+<p><label>Agent type</label> <input name="agentType" value="{{.AgentType}}" required></p>
+<p><label>Tool</label> <input name="tool" value="{{.Tool}}" required></p>
+<p><label>Sandbox ID</label> <input name="sandboxId" value="{{.SandboxID}}"></p>
+<p><label>Tenant ID</label> <input name="tenantId" value="{{.TenantID}}"></p>
+<p><label>Session ID</label> <input name="sessionId" value="{{.SessionID}}"></p>
+<p><label>MTS label</label> <input name="mtsLabel" value="{{.MTSLabel}}"></p>
+<p><label>Parameters</label> <textarea name="params" rows="3" cols="60" placeholder='{"path": "/workspace/main.go"}'>{{.Params}}</textarea></p>
+<p><label>OPA trace</label> <input type="checkbox" name="trace" value="on"{{if .Trace}} checked{{end}}></p>
+<p><button type="submit">Simulate</button> (evaluated with Explain: not cached, charged, or audited)</p>
+</form>
+{{end}}
+
+{{define "index"}}{{template "head"}}
+<h1>Router inspection</h1>
+<p>Mode <b>{{.Mode}}</b>, OPA {{if .OPA}}enabled{{else}}disabled{{end}}, {{.Ready}}. Generated {{.GeneratedTime}}.</p>
+
+<h2>Cache</h2>
+<table>
+<tr><th></th><th>Hits</th><th>Misses</th></tr>
+<tr><td>Decision cache</td><td>{{.CacheHits}}</td><td>{{.CacheMisses}} ({{printf "%.1f" .CacheHitRate}}% hit rate)</td></tr>
+{{if .OPA}}<tr><td>OPA memo</td><td>{{.MemoHits}}</td><td>{{.MemoMisses}}</td></tr>{{end}}
+</table>
+
+<h2>Policies</h2>
+{{if .Policies}}<table>
+<tr><th>Agent type</th><th>Policy</th><th>Mode</th><th>Default</th><th>Rules</th><th>Compiled</th><th>Canary</th></tr>
+{{range .Policies}}<tr>
+<td><a href="/policy?agentType={{.AgentType}}">{{.AgentType}}</a></td>
+<td>{{if .Policy.Namespace}}{{.Policy.Namespace}}/{{end}}{{.Policy.Name}}</td>
+<td>{{.Policy.Mode}}</td><td>{{.Policy.DefaultAction}}</td><td>{{len .Policy.ToolTable}}</td>
+<td>{{.Policy.CompiledAt.UTC.Format "2006-01-02T15:04:05Z"}}</td><td>{{.Canary}}</td>
+</tr>{{end}}
+</table>{{else}}<p>No policies are loaded.</p>{{end}}
+
+<h2>Recent denials</h2>
+{{if not .DenialsKept}}<p>Recent denials are not kept (set PolicyConfig.RecentDenials).</p>
+{{else if .Denials}}<table>
+<tr><th>Time</th><th>Agent type</th><th>Sandbox</th><th>Tenant</th><th>Tool</th><th>Reason</th><th>Request</th></tr>
+{{range .Denials}}<tr>
+<td>{{.Timestamp.UTC.Format "2006-01-02T15:04:05Z"}}</td><td>{{.Agent.AgentType}}</td><td>{{.Agent.SandboxID}}</td>
+<td>{{.Agent.TenantID}}</td><td>{{.Tool}}</td><td>{{.Reason}}</td><td>{{.RequestID}}</td>
+</tr>{{end}}
+</table>{{else}}<p>No denials yet.</p>{{end}}
+
+<h2>Simulate a call</h2>
+{{template "form" .Simulation}}
+</body></html>
+{{end}}
+
+{{define "policy"}}{{template "head"}}
+<h1>{{if .Policy.Namespace}}{{.Policy.Namespace}}/{{end}}{{.Policy.Name}}</h1>
+<p>Bound to agent type <b>{{.AgentType}}</b>. Mode {{.Policy.Mode}}, default {{.Policy.DefaultAction}}{{if .Policy.MTSLabel}}, MTS label {{.Policy.MTSLabel}}{{end}}, compiled {{.Policy.CompiledAt.UTC.Format "2006-01-02T15:04:05Z"}}.</p>
+
+<h2>Tool rules</h2>
+<table>
+<tr><th>Tool</th><th>Action</th><th>Constraints</th><th>Deny message</th></tr>
+{{range .Rules}}<tr>
+<td>{{.Tool}}</td><td class="{{if eq .Action.String "ALLOW"}}allow{{else}}deny{{end}}">{{.Action}}</td>
+<td><code>{{$.Constraints .}}</code></td><td>{{.DenyMessage}}</td>
+</tr>{{end}}
+</table>
+
+<h2>Generated Rego</h2>
+{{if .Policy.SecretValues}}<p>The generated Rego holds constraint values read from Secrets and is not shown.</p>
+{{else if .Policy.RegoModule}}<pre>{{.Policy.RegoModule}}</pre>
+{{else}}<p>The policy was not compiled to Rego; run the router with OPA enabled to generate it.</p>{{end}}
+</body></html>
+{{end}}
+
+{{define "simulate"}}{{template "head"}}
+<h1>Simulated call</h1>
+{{if .Error}}<p class="deny">{{.Error}}</p>{{end}}
+{{with .Explanation}}<table>
+<tr><th>Decision</th><td class="{{if eq .Decision.String "ALLOW"}}allow{{else}}deny{{end}}">{{.Decision}}</td></tr>
+<tr><th>Policy decision</th><td>{{.PolicyDecision}} (mode {{.Mode}})</td></tr>
+<tr><th>Policy</th><td>{{.Policy}}</td></tr>
+<tr><th>Rule</th><td>{{with .Rule}}{{.Tool}}: {{.Action}}{{else}}none; the default action applies{{end}}</td></tr>
+<tr><th>Reason</th><td>{{.Reason}}</td></tr>
+{{if .Message}}<tr><th>Message</th><td>{{.Message}}</td></tr>{{end}}
+{{if .Mutations}}<tr><th>Mutations</th><td>{{range .Mutations}}{{.}}<br>{{end}}</td></tr>{{end}}
+{{if .Obligations}}<tr><th>Obligations</th><td>{{range .Obligations}}{{.}}<br>{{end}}</td></tr>{{end}}
+<tr><th>Evaluated with</th><td>{{if .OPA}}OPA{{else}}the native engine{{end}}</td></tr>
+</table>
+{{if .Trace}}<h2>OPA trace</h2>
+<pre>{{.Trace}}</pre>{{end}}{{end}}
+<h2>Simulate another call</h2>
+{{template "form" .}}
+</body></html>
+{{end}}
+`))
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestInspectHandler tests the pages of the inspection UI.
+func TestInspectHandler(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.RecentDenials = 2
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny, []policy.ToolPermission{
+		{Tool: "file.read", Action: policy.Allow, Constraints: &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}}},
+	}, policy.Enforcing, ""))
+	handler := server.InspectHandler()
+
+	get := func(method, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	agent := policy.AgentContext{AgentType: "coding-assistant", SandboxID: "sb-1"}
+	for _, tool := range []string{"file.read", "shell.exec", "net.fetch", "file.write"} {
+		server.policy.Engine().Evaluate(context.Background(), agent, tool, map[string]interface{}{"path": "/workspace/a"})
+	}
+
+	code, body := get(http.MethodGet, "/")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", code, body)
+	}
+	for _, want := range []string{"Mode <b>enforcing</b>", `<a href="/policy?agentType=coding-assistant">`, "coding-policy", "file.write", "net.fetch"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the overview to contain %q:\n%s", want, body)
+		}
+	}
+	// Only the two most recent denials are kept, and allows never are
+	if strings.Contains(body, "<td>shell.exec</td>") || strings.Contains(body, "<td>file.read</td>") {
+		t.Errorf("expected only the two most recent denials:\n%s", body)
+	}
+	if strings.Index(body, "<td>file.write</td>") > strings.Index(body, "<td>net.fetch</td>") {
+		t.Error("expected the newest denial first")
+	}
+
+	code, body = get(http.MethodGet, "/policy?agentType=coding-assistant")
+	if code != http.StatusOK || !strings.Contains(body, "<td>file.read</td>") || !strings.Contains(body, "/workspace/") {
+		t.Errorf("unexpected policy page %d:\n%s", code, body)
+	}
+	if code, _ := get(http.MethodGet, "/policy?agentType=unknown"); code != http.StatusNotFound {
+		t.Errorf("expected an unknown agent type to be not found, got %d", code)
+	}
+
+	q := url.Values{"agentType": {"coding-assistant"}, "tool": {"file.read"}, "params": {`{"path": "/etc/passwd"}`}}
+	code, body = get(http.MethodGet, "/simulate?"+q.Encode())
+	if code != http.StatusOK || !strings.Contains(body, `class="deny">DENY`) {
+		t.Errorf("expected the simulated call to be denied, got %d:\n%s", code, body)
+	}
+	q.Set("params", "not json")
+	if _, body = get(http.MethodGet, "/simulate?"+q.Encode()); !strings.Contains(body, "parameters must be a JSON object") {
+		t.Errorf("expected malformed parameters to be reported:\n%s", body)
+	}
+	// Simulations are not audited
+	if denials := server.policy.denials.recent(); len(denials) != 2 || denials[0].Tool != "file.write" {
+		t.Errorf("expected the simulation not to be kept as a denial, got %+v", denials)
+	}
+
+	if code, _ := get(http.MethodPost, "/simulate"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// RequestLimits bound the parameters of Execute calls. They are enforced
+// on the raw JSON before it is decoded or evaluated, so adversarial
+// payloads cannot exhaust the engine or OPA. Zero disables a limit.
+type RequestLimits struct {
+	// MaxParameterBytes is the maximum size of the parameters JSON
+	MaxParameterBytes int
+
+	// MaxDepth is the maximum nesting depth of objects and arrays; a flat
+	// parameters object has depth 1
+	MaxDepth int
+
+	// MaxKeys is the maximum number of object keys, counted across every
+	// nested object
+	MaxKeys int
+}
+
+// DefaultRequestLimits returns limits that admit any reasonable tool call.
+func DefaultRequestLimits() RequestLimits {
+	return RequestLimits{
+		MaxParameterBytes: 1024 * 1024, // 1MB
+		MaxDepth:          32,
+		MaxKeys:           10000,
+	}
+}
+
+// check returns an error describing the first limit the parameters JSON
+// exceeds. It only scans the bytes; malformed JSON is left to the decoder.
+func (l RequestLimits) check(params []byte) error {
+	if l.MaxParameterBytes > 0 && len(params) > l.MaxParameterBytes {
+		return fmt.Errorf("parameters are %d bytes, exceeding the limit of %d", len(params), l.MaxParameterBytes)
+	}
+	if l.MaxDepth <= 0 && l.MaxKeys <= 0 {
+		return nil
+	}
+
+	depth, keys := 0, 0
+	inString, escaped := false, false
+	for _, c := range params {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return fmt.Errorf("parameters exceed the nesting depth limit of %d", l.MaxDepth)
+			}
+		case '}', ']':
+			depth--
+		case ':':
+			// Every object member has exactly one colon outside strings
+			keys++
+			if l.MaxKeys > 0 && keys > l.MaxKeys {
+				return fmt.Errorf("parameters exceed the limit of %d keys", l.MaxKeys)
+			}
+		}
+	}
+	return nil
+}
+
+// auditInvalid records a request rejected as invalid before evaluation.
+func (s *Server) auditInvalid(metadata RequestMetadata, toolName, reason, requestID string) {
+	s.policy.Engine().Audit(extractAgentIdentity(metadata), toolName, policy.Deny, "invalid request: "+reason, requestID)
+}
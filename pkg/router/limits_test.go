@@ -0,0 +1,70 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestRequestLimits verifies the size, depth, and key count limits
+func TestRequestLimits(t *testing.T) {
+	limits := RequestLimits{MaxParameterBytes: 64, MaxDepth: 3, MaxKeys: 3}
+
+	tests := []struct {
+		name   string
+		params string
+		ok     bool
+	}{
+		{"empty", ``, true},
+		{"flat", `{"path":"/tmp/a","mode":"r"}`, true},
+		{"at depth limit", `{"a":{"b":[1]}}`, true},
+		{"too deep", `{"a":{"b":[[1]]}}`, false},
+		{"too many keys", `{"a":1,"b":2,"c":{"d":4}}`, false},
+		{"brackets in strings", `{"a":"{{{{[[[[:::::"}`, true},
+		{"escaped quote", `{"a":"\"{{{{:::"}`, true},
+		{"too large", `{"a":"` + strings.Repeat("x", 64) + `"}`, false},
+	}
+	for _, tt := range tests {
+		if err := limits.check([]byte(tt.params)); (err == nil) != tt.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tt.name, tt.ok, err)
+		}
+	}
+
+	if err := (RequestLimits{}).check([]byte(strings.Repeat("[", 1000))); err != nil {
+		t.Errorf("expected zero limits to be disabled, got %v", err)
+	}
+}
+
+// TestServerRequestLimits verifies oversized parameters are rejected as
+// INVALID before evaluation, with an audit event
+func TestServerRequestLimits(t *testing.T) {
+	sink := policy.NewChannelAuditSink(10)
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.AuditSink = sink
+	config.RequestLimits.MaxDepth = 4
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Allow, nil, policy.Enforcing, ""))
+
+	resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+		ToolName:   "file.read",
+		Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-a"},
+		Parameters: []byte(`{"path":` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `}`),
+		RequestId:  "req-1",
+	})
+	if err != nil || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID {
+		t.Fatalf("expected INVALID, got %v (%v)", resp, err)
+	}
+
+	select {
+	case event := <-sink.Events():
+		if event.Decision != policy.Deny || event.RequestID != "req-1" || !strings.Contains(event.Reason, "depth") {
+			t.Errorf("expected invalid request audit event, got %+v", event)
+		}
+	default:
+		t.Error("expected an audit event for the rejected request")
+	}
+}
@@ -0,0 +1,102 @@
+package router
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// registerMetrics registers the router's metrics with registry, which the
+// controller's manager serves on PolicyConfig.MetricsAddr:
+//
+//   - agentpolicy_would_deny_total counts the calls the policies denied
+//     that were allowed because of Permissive mode (see
+//     policy.Engine.WouldDenyCount), to tell when a policy is ready to be
+//     enforced
+//   - agentpolicy_opa_eval_seconds and
+//     agentpolicy_opa_eval_operations_total are OPA's evaluation time and
+//     operations by policy and rule, if PolicyConfig.OPAMetrics is set
+//     (see policy.Engine.OPAEvalStats)
+//   - agentpolicy_tool_circuit_state and
+//     agentpolicy_tool_circuit_rejected_total are the state of each tool's
+//     circuit breaker and the calls it rejected, if the server breaks
+//     circuits (see ServerConfig.CircuitBreaker)
+func (r *RouterPolicyIntegration) registerMetrics(registry prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "agentpolicy_would_deny_total",
+			Help: "Calls the policies denied that were allowed because of permissive mode.",
+		}, func() float64 { return float64(r.engine.WouldDenyCount()) }),
+	}
+	if r.config.OPAMetrics {
+		collectors = append(collectors, &opaCollector{engine: r.engine})
+	}
+	collectors = append(collectors, r.collectors...)
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			// Only the first router of a process is exported
+			var registered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &registered) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	opaEvalSecondsDesc = prometheus.NewDesc("agentpolicy_opa_eval_seconds",
+		"Time OPA spent evaluating the queries of a policy rule.", []string{"policy", "rule"}, nil)
+	opaEvalOperationsDesc = prometheus.NewDesc("agentpolicy_opa_eval_operations_total",
+		"Evaluation operations OPA performed for the queries of a policy rule.", []string{"policy", "rule"}, nil)
+)
+
+// opaCollector exports the engine's OPA evaluation stats.
+type opaCollector struct {
+	engine *policy.Engine
+}
+
+func (c *opaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- opaEvalSecondsDesc
+	ch <- opaEvalOperationsDesc
+}
+
+func (c *opaCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.engine.OPAEvalStats() {
+		buckets := make(map[float64]uint64, len(s.Buckets))
+		for i, n := range s.Buckets {
+			buckets[policy.OPAEvalBuckets[i].Seconds()] = n
+		}
+		ch <- prometheus.MustNewConstHistogram(opaEvalSecondsDesc, s.Count, s.EvalTime.Seconds(), buckets, s.Policy, s.Rule)
+		ch <- prometheus.MustNewConstMetric(opaEvalOperationsDesc, prometheus.CounterValue, float64(s.Operations), s.Policy, s.Rule)
+	}
+}
+
+var (
+	circuitStateDesc = prometheus.NewDesc("agentpolicy_tool_circuit_state",
+		"State of a tool's circuit breaker: 0 closed, 1 open, 2 half-open.", []string{"tool"}, nil)
+	circuitRejectedDesc = prometheus.NewDesc("agentpolicy_tool_circuit_rejected_total",
+		"Calls to a tool its open circuit breaker rejected.", []string{"tool"}, nil)
+)
+
+// breakerStateValues are the values of agentpolicy_tool_circuit_state.
+var breakerStateValues = map[BreakerState]float64{BreakerClosed: 0, BreakerOpen: 1, BreakerHalfOpen: 2}
+
+// breakerCollector exports the state of the server's circuit breakers.
+type breakerCollector struct {
+	breakers *circuitBreakers
+}
+
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- circuitStateDesc
+	ch <- circuitRejectedDesc
+}
+
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.breakers.statuses() {
+		ch <- prometheus.MustNewConstMetric(circuitStateDesc, prometheus.GaugeValue, breakerStateValues[s.State], s.Tool)
+		ch <- prometheus.MustNewConstMetric(circuitRejectedDesc, prometheus.CounterValue, float64(s.Rejected), s.Tool)
+	}
+}
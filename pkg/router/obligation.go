@@ -0,0 +1,152 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// ToolCall describes an allowed tool call, to the handlers of its
+// obligations and of its tool.
+type ToolCall struct {
+	// ToolName is the tool being called
+	ToolName string
+
+	// Parameters are the parameters the tool will execute with, after the
+	// policy's mutators
+	Parameters map[string]interface{}
+
+	// Metadata is the caller's identity and context
+	Metadata RequestMetadata
+
+	// RequestID is the client-provided request ID
+	RequestID string
+}
+
+// ObligationHandler fulfils one type of decision obligation before the
+// tool executes, such as logging the payload or sending a notification.
+// Returning an error fails the call without executing it.
+type ObligationHandler interface {
+	Fulfill(ctx context.Context, call *ToolCall, obligation policy.Obligation) error
+}
+
+// ObligationHandlerFunc adapts a function to the ObligationHandler interface.
+type ObligationHandlerFunc func(ctx context.Context, call *ToolCall, obligation policy.Obligation) error
+
+// Fulfill calls f.
+func (f ObligationHandlerFunc) Fulfill(ctx context.Context, call *ToolCall, obligation policy.Obligation) error {
+	return f(ctx, call, obligation)
+}
+
+// ObligationExecutor is a ToolExecutor that carries out obligations as part
+// of execution, such as running the tool in a read-only sandbox. The server
+// delegates to it every obligation no handler is registered for.
+type ObligationExecutor interface {
+	ToolExecutor
+
+	// SupportsObligation reports whether the executor can fulfil
+	// obligations of the given type.
+	SupportsObligation(obligationType string) bool
+
+	// ExecuteWithObligations runs a tool while fulfilling the obligations.
+	// It must fail rather than execute if any cannot be fulfilled.
+	ExecuteWithObligations(ctx context.Context, toolName string, parameters map[string]interface{}, obligations []policy.Obligation) (interface{}, error)
+}
+
+// SetObligationHandler registers the handler for an obligation type,
+// replacing any previous one. A nil handler unregisters the type.
+func (s *Server) SetObligationHandler(obligationType string, handler ObligationHandler) {
+	s.obligationsMu.Lock()
+	defer s.obligationsMu.Unlock()
+
+	if handler == nil {
+		delete(s.obligationHandlers, obligationType)
+		return
+	}
+	s.obligationHandlers[obligationType] = handler
+}
+
+// fulfillObligations runs the registered handler of each obligation, in
+// order, and returns the obligations left for the tool executor. It fails
+// closed: an obligation neither a handler nor the executor supports, or a
+// handler error, is an error and the call must not execute. Obligations of
+// registered tools are never delegated, as the executor does not run them.
+// Credentials are injected last, so that no other handler sees them.
+func (s *Server) fulfillObligations(ctx context.Context, call *ToolCall, obligations []policy.Obligation) ([]policy.Obligation, error) {
+	_, registered := s.tools.lookup(call.ToolName)
+	ordered := make([]policy.Obligation, 0, len(obligations))
+	var credentials []policy.Obligation
+	for _, o := range obligations {
+		if o.Type == policy.ObligationInjectCredential {
+			credentials = append(credentials, o)
+			continue
+		}
+		ordered = append(ordered, o)
+	}
+
+	var delegated []policy.Obligation
+	for _, o := range append(ordered, credentials...) {
+		s.obligationsMu.RLock()
+		handler, ok := s.obligationHandlers[o.Type]
+		s.obligationsMu.RUnlock()
+
+		if ok {
+			if err := handler.Fulfill(ctx, call, o); err != nil {
+				return nil, fmt.Errorf("obligation %q failed: %w", o, err)
+			}
+			continue
+		}
+
+		if executor, ok := s.toolExecutor.(ObligationExecutor); ok && !registered && executor.SupportsObligation(o.Type) {
+			delegated = append(delegated, o)
+			continue
+		}
+		return nil, fmt.Errorf("obligation %q cannot be fulfilled", o)
+	}
+	return delegated, nil
+}
+
+// payloadRecord is the JSON line written by a payload log handler.
+type payloadRecord struct {
+	Timestamp  time.Time              `json:"timestamp"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Tool       string                 `json:"tool"`
+	AgentType  string                 `json:"agent_type"`
+	SandboxID  string                 `json:"sandbox_id,omitempty"`
+	TenantID   string                 `json:"tenant_id,omitempty"`
+	SessionID  string                 `json:"session_id,omitempty"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// NewPayloadLogHandler returns a handler for policy.ObligationLogPayload
+// that writes the full parameters of each call to w as a JSON line.
+// Payloads may contain sensitive data; w should be access controlled like
+// the audit log.
+func NewPayloadLogHandler(w io.Writer) ObligationHandler {
+	var mu sync.Mutex
+	return ObligationHandlerFunc(func(ctx context.Context, call *ToolCall, _ policy.Obligation) error {
+		line, err := json.Marshal(payloadRecord{
+			Timestamp:  time.Now(),
+			RequestID:  call.RequestID,
+			Tool:       call.ToolName,
+			AgentType:  call.Metadata.AgentType,
+			SandboxID:  call.Metadata.SandboxID,
+			TenantID:   call.Metadata.TenantID,
+			SessionID:  call.Metadata.SessionID,
+			Parameters: call.Parameters,
+		})
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = w.Write(append(line, '\n'))
+		return err
+	})
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/gitops"
+	"github.com/golden-agent/golden-agent/pkg/offline"
+)
+
+// OfflineConfig configures offline operation, for air-gapped sites such as
+// OT networks: policies are loaded from a signed bundle on local storage,
+// and audit events are spooled to local storage and forwarded when
+// connectivity returns. The router makes no Kubernetes API calls, so the
+// controller, and everything that requires it, is unavailable.
+type OfflineConfig struct {
+	// Bundle is the path of the policy bundle, a YAML file of AgentPolicy
+	// manifests signed by the file at Bundle + offline.SignatureSuffix.
+	// Required
+	Bundle string
+
+	// PublicKey verifies the signature of the bundle. Required
+	PublicKey ed25519.PublicKey
+
+	// Namespace is the namespace of the bundle's policies without one.
+	// Default: "default"
+	Namespace string
+
+	// ReloadInterval is how often the bundle is checked for changes; a
+	// changed bundle is loaded once its signature verifies. Default: 0
+	// (only load it at startup)
+	ReloadInterval time.Duration
+
+	// SpoolDir is the directory audit events are spooled to, in addition
+	// to the AuditSink. Default: "" (no spool)
+	SpoolDir string
+
+	// MaxSpoolBytes bounds the spool; events past it are dropped and
+	// counted. Default: offline.DefaultMaxSpoolBytes
+	MaxSpoolBytes int64
+
+	// ForwardURL is the URL spooled events are POSTed to as
+	// newline-delimited JSON. Requires SpoolDir. Default: "" (events stay
+	// spooled, e.g., for collection from the disk)
+	ForwardURL string
+
+	// ForwardInterval is how often spooled events are forwarded.
+	// Default: 30s
+	ForwardInterval time.Duration
+}
+
+// errOffline is returned by StartController in offline mode.
+var errOffline = errors.New("the controller requires the Kubernetes API, which offline mode does not use")
+
+// startOffline loads the policy bundle, which must verify and compile for
+// the router to start, then reloads it and forwards the audit spool in
+// background goroutines until ctx is done.
+func (r *RouterPolicyIntegration) startOffline(ctx context.Context) error {
+	config := r.config.Offline
+	if r.spool != nil {
+		if err := r.spool.Open(); err != nil {
+			return err
+		}
+	}
+
+	source := offline.NewBundleSource(config.Bundle, config.PublicKey, config.Namespace,
+		gitops.NewEngineTarget(r.engine, r.config.UseOPA, r.log), r.log)
+	if err := source.Load(ctx); err != nil {
+		return fmt.Errorf("failed to load policy bundle: %w", err)
+	}
+	if config.ReloadInterval > 0 {
+		go source.Start(ctx, config.ReloadInterval)
+	}
+
+	if r.spool != nil && config.ForwardURL != "" {
+		interval := config.ForwardInterval
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		go r.spool.Run(ctx, offline.NewHTTPForwarder(config.ForwardURL, nil), interval)
+	}
+	return nil
+}
+
+// SpoolStats returns the state of the offline audit spool, if any.
+func (r *RouterPolicyIntegration) SpoolStats() (offline.SpoolStats, bool) {
+	if r.spool == nil {
+		return offline.SpoolStats{}, false
+	}
+	return r.spool.Stats(), true
+}
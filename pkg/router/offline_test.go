@@ -0,0 +1,68 @@
+package router
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golden-agent/golden-agent/pkg/offline"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestOffline verifies offline mode loads the signed bundle, spools audit
+// events, and refuses to start the controller
+func TestOffline(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.yaml")
+	data := []byte(`apiVersion: agents.sandbox.io/v1alpha1
+kind: AgentPolicy
+metadata:
+  name: plc-policy
+spec:
+  agentTypes: [plc-agent]
+  defaultAction: deny
+  mode: enforcing
+  toolPermissions:
+    - tool: plc.read
+      action: allow
+`)
+	os.WriteFile(bundle, data, 0o644)
+	os.WriteFile(bundle+offline.SignatureSuffix, offline.Sign(data, private), 0o644)
+
+	config := DefaultPolicyConfig()
+	config.Mode = policy.Enforcing
+	config.Offline = &OfflineConfig{Bundle: bundle, PublicKey: public, SpoolDir: filepath.Join(dir, "spool")}
+	integration := NewRouterPolicyIntegration(config)
+	if err := integration.StartPolicySource(context.Background()); err != nil {
+		t.Fatalf("StartPolicySource failed: %v", err)
+	}
+
+	metadata := RequestMetadata{AgentType: "plc-agent", SandboxID: "sb-1"}
+	if decision, _ := integration.Evaluate(context.Background(), metadata, "plc.read", nil); decision != policy.Allow {
+		t.Error("expected the bundle's policy to allow plc.read")
+	}
+	if decision, _ := integration.Evaluate(context.Background(), metadata, "plc.write", nil); decision != policy.Deny {
+		t.Error("expected the bundle's policy to deny plc.write")
+	}
+	if stats, ok := integration.SpoolStats(); !ok || stats.PendingBytes == 0 {
+		t.Errorf("expected the decisions to be spooled, got %+v", stats)
+	}
+
+	config.EnableController = true
+	if err := NewRouterPolicyIntegration(config).StartController(context.Background()); err == nil {
+		t.Error("expected offline mode to refuse the controller")
+	}
+
+	// A bundle that does not verify fails the start
+	config.Offline = &OfflineConfig{Bundle: bundle, PublicKey: make(ed25519.PublicKey, ed25519.PublicKeySize)}
+	if err := NewRouterPolicyIntegration(config).StartPolicySource(context.Background()); err == nil {
+		t.Error("expected a bundle that does not verify to fail the start")
+	}
+}
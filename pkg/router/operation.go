@@ -0,0 +1,292 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+)
+
+// DefaultOperationTTL is how long operations are kept once done, unless
+// ServerConfig.OperationTTL says otherwise.
+const DefaultOperationTTL = time.Hour
+
+// callbackTimeout bounds the POSTs of operations to their callback URLs.
+const callbackTimeout = 10 * time.Second
+
+// operation is a call submitted with ExecuteAsync.
+type operation struct {
+	id        string
+	owner     string // identityHash of the submitting agent
+	toolName  string
+	requestID string
+	decision  *agentpb.PolicyDecision
+	callback  string
+	submitted time.Time
+
+	done chan struct{}
+
+	// Set once done is closed
+	response *agentpb.ExecuteResponse
+	doneAt   time.Time
+}
+
+// proto returns the operation's current state.
+func (op *operation) proto() *agentpb.Operation {
+	out := &agentpb.Operation{
+		OperationId:       op.id,
+		PolicyDecision:    op.decision,
+		RequestId:         op.requestID,
+		ToolName:          op.toolName,
+		SubmittedUnixNano: op.submitted.UnixNano(),
+	}
+	select {
+	case <-op.done:
+		out.Done = true
+		out.Response = op.response
+		out.DoneUnixNano = op.doneAt.UnixNano()
+	default:
+	}
+	return out
+}
+
+// operationStore keeps the operations of the server, and forgets those
+// done for longer than their TTL.
+type operationStore struct {
+	ttl time.Duration
+
+	mu  sync.Mutex
+	ops map[string]*operation
+}
+
+func newOperationStore(ttl time.Duration) *operationStore {
+	if ttl <= 0 {
+		ttl = DefaultOperationTTL
+	}
+	return &operationStore{ttl: ttl, ops: make(map[string]*operation)}
+}
+
+// add stores op, forgetting the operations past their TTL.
+func (s *operationStore) add(op *operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, old := range s.ops {
+		select {
+		case <-old.done:
+			if now.Sub(old.doneAt) > s.ttl {
+				delete(s.ops, id)
+			}
+		default:
+		}
+	}
+	s.ops[op.id] = op
+}
+
+// get returns the operation of id, or nil.
+func (s *operationStore) get(id string) *operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ops[id]
+}
+
+// asyncSubmission carries an ExecuteAsync call through execute, which
+// starts its operation once the call is admitted.
+type asyncSubmission struct {
+	callback string
+	op       *operation
+}
+
+// submissionContextKey is the context key of a call's asyncSubmission.
+type submissionContextKey struct{}
+
+// submissionFromContext returns the asyncSubmission of an ExecuteAsync
+// call, or nil for Execute calls.
+func submissionFromContext(ctx context.Context) *asyncSubmission {
+	submission, _ := ctx.Value(submissionContextKey{}).(*asyncSubmission)
+	return submission
+}
+
+// ExecuteAsync implements the AgentService.ExecuteAsync RPC. The call is
+// evaluated and admitted as Execute would, and fails as Execute would if
+// it is denied or rejected; once admitted, it executes in the background,
+// unbound by the RPC's deadline, and its operation is returned. Calls
+// answered at submission, such as invalid or replayed calls, return a done
+// operation without an ID.
+func (s *Server) ExecuteAsync(ctx context.Context, req *agentpb.ExecuteAsyncRequest) (*agentpb.Operation, error) {
+	if req.GetRequest() == nil {
+		return nil, status.Error(codes.InvalidArgument, "request is required")
+	}
+	if callback := req.GetCallbackUrl(); callback != "" {
+		if err := s.checkCallback(callback); err != nil {
+			return nil, err
+		}
+	}
+
+	submission := &asyncSubmission{callback: req.GetCallbackUrl()}
+	resp, err := s.execute(context.WithValue(ctx, submissionContextKey{}, submission), req.GetRequest())
+	if err != nil {
+		return nil, err
+	}
+	if submission.op != nil {
+		return submission.op.proto(), nil
+	}
+
+	now := time.Now().UnixNano()
+	return &agentpb.Operation{
+		Done:              true,
+		Response:          resp,
+		PolicyDecision:    resp.GetPolicyDecision(),
+		RequestId:         req.GetRequest().GetRequestId(),
+		ToolName:          req.GetRequest().GetToolName(),
+		SubmittedUnixNano: now,
+		DoneUnixNano:      now,
+	}, nil
+}
+
+// startOperation starts the operation of an admitted ExecuteAsync call,
+// which executes in the background and keeps the server's drain waiting
+// until it is done.
+func (s *Server) startOperation(ctx context.Context, submission *asyncSubmission, admitted *admittedCall) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	op := &operation{
+		id:        hex.EncodeToString(id),
+		owner:     identityHash(admitted.metadata),
+		toolName:  admitted.req.GetToolName(),
+		requestID: admitted.req.GetRequestId(),
+		decision:  admitted.decision,
+		callback:  submission.callback,
+		submitted: time.Now(),
+		done:      make(chan struct{}),
+	}
+	s.operations.add(op)
+	submission.op = op
+
+	// The execution keeps the call's identity, request ID, and session,
+	// but not its deadline
+	ctx = context.WithoutCancel(ctx)
+	s.retainCall()
+	go func() {
+		defer s.endCall()
+		func() {
+			defer admitted.close()
+			op.response = s.executeAdmitted(ctx, admitted)
+		}()
+		op.doneAt = time.Now()
+		close(op.done)
+
+		if op.callback != "" {
+			if err := s.postCallback(ctx, op); err != nil {
+				s.policy.log.Warn("operation callback failed", "operation", op.id, "tool", op.toolName, "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// GetOperation implements the AgentService.GetOperation RPC.
+func (s *Server) GetOperation(ctx context.Context, req *agentpb.GetOperationRequest) (*agentpb.Operation, error) {
+	op, err := s.callerOperation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return op.proto(), nil
+}
+
+// WatchOperation implements the AgentService.WatchOperation RPC: it sends
+// the operation's current state, then its final state once done, unless it
+// was done already.
+func (s *Server) WatchOperation(req *agentpb.GetOperationRequest, stream agentpb.AgentService_WatchOperationServer) error {
+	op, err := s.callerOperation(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	current := op.proto()
+	if err := stream.Send(current); err != nil || current.GetDone() {
+		return err
+	}
+
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case <-s.drain.done:
+		return drainingError()
+	case <-op.done:
+	}
+	return stream.Send(op.proto())
+}
+
+// callerOperation returns the operation a GetOperationRequest names, if
+// the caller submitted it. The operations of other agents are not found.
+func (s *Server) callerOperation(ctx context.Context, req *agentpb.GetOperationRequest) (*operation, error) {
+	if req.GetOperationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "operation_id is required")
+	}
+	if req.GetMetadata() == nil && req.GetSessionToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metadata or session_token is required")
+	}
+	metadata, _, err := s.requestIdentity(ctx, &agentpb.ExecuteRequest{Metadata: req.GetMetadata(), SessionToken: req.GetSessionToken()})
+	if err != nil {
+		return nil, err
+	}
+
+	op := s.operations.get(req.GetOperationId())
+	if op == nil || op.owner != identityHash(metadata) {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", req.GetOperationId())
+	}
+	return op, nil
+}
+
+// checkCallback checks that an operation's callback URL is an HTTP(S) URL
+// on a host the server allows callbacks to, so that agents cannot make the
+// router call arbitrary endpoints.
+func (s *Server) checkCallback(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return status.Errorf(codes.InvalidArgument, "invalid callback_url %q", raw)
+	}
+	if !s.callbackHosts[strings.ToLower(u.Hostname())] {
+		return status.Errorf(codes.PermissionDenied, "callbacks to %q are not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// postCallback POSTs the done operation to its callback URL as JSON.
+func (s *Server) postCallback(ctx context.Context, op *operation) error {
+	body, err := json.Marshal(op.proto())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, op.callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// fakeOperationStream implements agentpb.AgentService_WatchOperationServer
+// for testing.
+type fakeOperationStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	ops chan *agentpb.Operation
+}
+
+func (f *fakeOperationStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeOperationStream) Send(op *agentpb.Operation) error {
+	f.ops <- op
+	return nil
+}
+
+// TestExecuteAsync tests that ExecuteAsync evaluates calls at submission,
+// executes allowed calls past the submitting RPC, and reports their
+// operations to the agent that submitted them only, by polling, watching,
+// and callback.
+func TestExecuteAsync(t *testing.T) {
+	callbacks := make(chan *agentpb.Operation, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op agentpb.Operation
+		json.NewDecoder(r.Body).Decode(&op)
+		callbacks <- &op
+	}))
+	defer hook.Close()
+
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.CallbackHosts = []string{"127.0.0.1"}
+	server := NewServer(config)
+	server.SetToolExecutor(&slowExecutor{delay: 100 * time.Millisecond})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("async-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "code.execute", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	metadata := &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-1"}
+	submit := func(tool, callback string) (*agentpb.Operation, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return server.ExecuteAsync(ctx, &agentpb.ExecuteAsyncRequest{
+			Request:     &agentpb.ExecuteRequest{ToolName: tool, RequestId: "req-1", Metadata: metadata},
+			CallbackUrl: callback,
+		})
+	}
+
+	if _, err := submit("network.fetch", ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected the denied call to fail at submission, got %v", err)
+	}
+	if _, err := submit("code.execute", "https://attacker.example/hook"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected a callback to another host to be rejected, got %v", err)
+	}
+
+	op, err := submit("code.execute", hook.URL+"/done")
+	if err != nil {
+		t.Fatalf("ExecuteAsync failed: %v", err)
+	}
+	if op.GetOperationId() == "" || op.GetDone() || op.GetPolicyDecision().GetDecision() != policy.Allow.String() || op.GetRequestId() != "req-1" {
+		t.Fatalf("expected a running operation with its decision, got %v", op)
+	}
+
+	get := &agentpb.GetOperationRequest{OperationId: op.GetOperationId(), Metadata: metadata}
+	stream := &fakeOperationStream{ctx: context.Background(), ops: make(chan *agentpb.Operation, 2)}
+	if err := server.WatchOperation(get, stream); err != nil {
+		t.Fatalf("WatchOperation failed: %v", err)
+	}
+	if first := <-stream.ops; first.GetDone() {
+		t.Errorf("expected the watch to start with the running operation, got %v", first)
+	}
+	done := <-stream.ops
+	if !done.GetDone() || done.GetResponse().GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS || string(done.GetResponse().GetResult()) != `"done"` {
+		t.Errorf("expected the call to execute past its submission's deadline, got %v", done)
+	}
+
+	polled, err := server.GetOperation(context.Background(), get)
+	if err != nil || !polled.GetDone() || polled.GetDoneUnixNano() < polled.GetSubmittedUnixNano() {
+		t.Errorf("expected the done operation, got %v (%v)", polled, err)
+	}
+	other := &agentpb.GetOperationRequest{OperationId: op.GetOperationId(), Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-2"}}
+	if _, err := server.GetOperation(context.Background(), other); status.Code(err) != codes.NotFound {
+		t.Errorf("expected other agents not to find the operation, got %v", err)
+	}
+
+	select {
+	case posted := <-callbacks:
+		if posted.GetOperationId() != op.GetOperationId() || !posted.GetDone() {
+			t.Errorf("expected the done operation to be posted, got %v", posted)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timed out waiting for the callback")
+	}
+}
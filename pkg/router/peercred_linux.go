@@ -0,0 +1,63 @@
+//go:build linux
+
+package router
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// peerCredentials reads the SO_PEERCRED credentials of a Unix socket
+// connection, and the peer's cgroup from /proc.
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCredentials{}, err
+	}
+	if credErr != nil {
+		return PeerCredentials{}, credErr
+	}
+
+	return PeerCredentials{
+		PID:    ucred.Pid,
+		UID:    ucred.Uid,
+		GID:    ucred.Gid,
+		Cgroup: readCgroup(ucred.Pid),
+	}, nil
+}
+
+// readCgroup returns a process's cgroup v2 path, or its first cgroup v1
+// hierarchy's path. Returns "" if /proc/<pid>/cgroup cannot be read.
+func readCgroup(pid int32) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+
+	var first string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// Lines are "hierarchy-ID:controllers:path"
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if first == "" {
+			first = parts[2]
+		}
+	}
+	return first
+}
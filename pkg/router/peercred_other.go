@@ -0,0 +1,13 @@
+//go:build !linux
+
+package router
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials is only supported on Linux.
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	return PeerCredentials{}, errors.New("unix socket peer credentials are not supported on this platform")
+}
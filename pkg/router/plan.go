@@ -0,0 +1,348 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+)
+
+// planClaims are the identity, decisions, and expiry signed into a plan
+// token. Plan tokens are signed like session tokens, but the progress of
+// a plan is kept by the replica that issued it: other replicas, even those
+// holding the session key, evaluate the plan's calls as usual.
+type planClaims struct {
+	ID string `json:"pid"`
+
+	// Replica identifies the planManager that issued the plan
+	Replica string `json:"rep"`
+
+	// Identity is the hash of the identity the plan was evaluated for
+	Identity string `json:"idh"`
+
+	// Fingerprint is the engine's DecisionFingerprint of the agent when
+	// the plan was evaluated
+	Fingerprint string `json:"fpr"`
+
+	ExpiresAt int64         `json:"exp"`
+	Steps     []plannedStep `json:"stp"`
+}
+
+// plannedStep is a call of a plan, as signed into its token.
+type plannedStep struct {
+	// Tool is the normalized tool name, and Params the hash of the call's
+	// parameters (see paramsHash)
+	Tool   string `json:"t"`
+	Params string `json:"p"`
+
+	// Policy is the policy that allowed the call
+	Policy string `json:"pol,omitempty"`
+
+	// Evaluate is set if the call is evaluated as it executes, as calls
+	// with obligations or parameter rewrites are
+	Evaluate bool `json:"e,omitempty"`
+}
+
+// planManager issues and verifies plan tokens, and keeps the replica-local
+// progress of live plans.
+type planManager struct {
+	sign   func(payload string) []byte
+	maxTTL time.Duration
+
+	// replica is the random ID of this replica signed into its plans
+	replica string
+
+	mu    sync.Mutex
+	plans map[string]*planProgress
+}
+
+// planProgress is the progress of a plan on this replica: the steps before
+// next have executed or were skipped.
+type planProgress struct {
+	next      int
+	expiresAt time.Time
+}
+
+func newPlanManager(sign func(string) []byte, maxTTL time.Duration) *planManager {
+	if maxTTL <= 0 {
+		maxTTL = 5 * time.Minute
+	}
+	replica := make([]byte, 8)
+	if _, err := rand.Read(replica); err != nil {
+		panic(fmt.Sprintf("failed to generate plan replica ID: %v", err))
+	}
+	return &planManager{sign: sign, maxTTL: maxTTL, replica: hex.EncodeToString(replica), plans: make(map[string]*planProgress)}
+}
+
+// issue signs claims into a token expiring after ttl, capped by the
+// manager's maximum, and returns it with its expiry.
+func (m *planManager) issue(claims planClaims, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 || ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl)
+	claims.ID = hex.EncodeToString(id)
+	claims.Replica = m.replica
+	claims.ExpiresAt = expiresAt.UnixNano()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := "p1." + base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(m.sign(encoded)), expiresAt, nil
+}
+
+var (
+	// errPlanExpired is returned by verify for tokens past their expiry.
+	errPlanExpired = errors.New("plan expired")
+
+	// errPlanOtherReplica is returned by verify for tokens issued by
+	// another replica, which holds the plan's progress.
+	errPlanOtherReplica = errors.New("plan issued by another replica")
+)
+
+// verify checks a token's signature, expiry, and issuer and returns its
+// claims.
+// The prefix is signed with the payload, so that session tokens, signed
+// with the same key, are not accepted as plan tokens.
+func (m *planManager) verify(token string) (*planClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != "p1" {
+		return nil, errors.New("malformed plan token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, m.sign(parts[0]+"."+parts[1])) {
+		return nil, errors.New("invalid plan token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed plan token")
+	}
+	var claims planClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed plan token")
+	}
+	if !time.Now().Before(time.Unix(0, claims.ExpiresAt)) {
+		return nil, errPlanExpired
+	}
+	if claims.Replica != m.replica {
+		return nil, errPlanOtherReplica
+	}
+	return &claims, nil
+}
+
+// advance records that step of plan executes. Steps execute in plan order,
+// each once: a step at or before one already executed is refused, and the
+// steps it skips can no longer execute.
+func (m *planManager) advance(claims *planClaims, step int) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, p := range m.plans {
+		if !now.Before(p.expiresAt) {
+			delete(m.plans, id)
+		}
+	}
+	progress, ok := m.plans[claims.ID]
+	if !ok {
+		progress = &planProgress{expiresAt: time.Unix(0, claims.ExpiresAt)}
+		m.plans[claims.ID] = progress
+	}
+	if step < progress.next {
+		return fmt.Errorf("step %d of plan %s has already executed or was skipped", step, claims.ID)
+	}
+	progress.next = step + 1
+	return nil
+}
+
+// identityHash returns the hash of the identity fields of md a plan is
+// bound to; the locale and trace flag may vary per call.
+func identityHash(md RequestMetadata) string {
+	md.Locale, md.Trace = "", false
+	encoded, _ := json.Marshal(md)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// paramsHash returns the hash of the parameters of a call, typed
+// parameters included, as the tool would receive them.
+func paramsHash(toolReq *policy.ToolRequest) (string, error) {
+	encoded, err := json.Marshal(toolReq.ParameterMap())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// PreAuthorize implements the AgentService.PreAuthorize RPC. It evaluates
+// an agent's plan, its tool calls in order, as Execute would evaluate them
+// one by one (see policy.Engine.EvaluatePlan), and, if policy allows every
+// call, issues a token that authorizes them. Execute calls carrying the
+// token and the index of their step skip policy evaluation, as long as
+// they match the step's tool and parameters and the policies and kill
+// switches deciding the agent's calls are unchanged; otherwise they are
+// evaluated as usual.
+func (s *Server) PreAuthorize(ctx context.Context, req *agentpb.PreAuthorizeRequest) (*agentpb.PreAuthorizeResponse, error) {
+	if req.GetMetadata().GetAgentType() == "" && req.GetSessionToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metadata.agent_type or session_token is required")
+	}
+	if len(req.GetCalls()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "calls are required")
+	}
+
+	metadata, _, err := s.requestIdentity(ctx, &agentpb.ExecuteRequest{Metadata: req.GetMetadata(), SessionToken: req.GetSessionToken()})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRateLimit(metadata); err != nil {
+		return nil, err
+	}
+
+	normalizer := s.policy.Engine().ToolNormalizer()
+	calls := make([]policy.PlanCall, len(req.GetCalls()))
+	steps := make([]plannedStep, len(req.GetCalls()))
+	for i, c := range req.GetCalls() {
+		if c.GetToolName() == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "call %d: tool_name is required", i)
+		}
+		if err := s.limits.check(c.GetParameters()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "call %d: %v", i, err)
+		}
+		call := &agentpb.ExecuteRequest{ToolName: c.GetToolName(), Parameters: c.GetParameters()}
+		params, err := call.GetParametersMap()
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "call %d: invalid parameters JSON: %v", i, err)
+		}
+		if !s.servesTool(c.GetToolName()) {
+			return nil, status.Errorf(codes.InvalidArgument, "call %d: %v: %q", i, ErrUnknownTool, c.GetToolName())
+		}
+		toolReq := toToolRequest(call, params)
+		hash, err := paramsHash(toolReq)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "call %d: %v", i, err)
+		}
+		calls[i] = policy.PlanCall{ToolName: normalizer.Normalize(c.GetToolName()), Request: toolReq}
+		steps[i] = plannedStep{Tool: calls[i].ToolName, Params: hash}
+	}
+
+	plan, err := s.policy.EvaluatePlan(ctx, metadata, calls)
+	if errors.Is(err, policyerrors.ErrBudgetExceeded) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		return nil, evaluationError(err)
+	}
+
+	resp := &agentpb.PreAuthorizeResponse{Authorized: plan.Allowed}
+	for i, step := range plan.Steps {
+		resp.Steps = append(resp.Steps, &agentpb.PlanStep{
+			Decision: &agentpb.PolicyDecision{
+				Decision:      step.Decision.String(),
+				PolicyName:    step.Policy,
+				Message:       step.Message,
+				MessageLocale: step.MessageLocale,
+				Mutations:     step.Mutations,
+				Obligations:   obligationsToProto(step.Obligations),
+			},
+			Preauthorized: plan.Allowed && step.Preauthorized,
+		})
+		steps[i].Policy = step.Policy
+		steps[i].Evaluate = !step.Preauthorized
+	}
+	if !plan.Allowed {
+		return resp, nil
+	}
+
+	token, expiresAt, err := s.plans.issue(planClaims{
+		Identity:    identityHash(metadata),
+		Fingerprint: plan.Fingerprint,
+		Steps:       steps,
+	}, time.Duration(req.GetTtlSeconds())*time.Second)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to issue plan token: %v", err)
+	}
+	resp.PlanToken = token
+	resp.ExpiresUnixNano = expiresAt.UnixNano()
+	return resp, nil
+}
+
+// preauthorized returns the result of an Execute call carrying a plan
+// token, if the plan authorizes it without evaluation, and audits it. It
+// returns nil if the call must be evaluated as usual: its token expired or
+// was issued by another replica, its step is not preauthorized, or what
+// decides the agent's calls changed since the plan was evaluated. Calls that do not match their step fail
+// with PERMISSION_DENIED.
+func (s *Server) preauthorized(ctx context.Context, md RequestMetadata, req *agentpb.ExecuteRequest, toolReq *policy.ToolRequest) (*policy.EvaluationResult, error) {
+	if req.GetPlanToken() == "" {
+		return nil, nil
+	}
+	claims, err := s.plans.verify(req.GetPlanToken())
+	if errors.Is(err, errPlanExpired) || errors.Is(err, errPlanOtherReplica) {
+		return nil, nil
+	} else if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if claims.Identity != identityHash(md) {
+		return nil, status.Error(codes.PermissionDenied, "plan belongs to another agent")
+	}
+
+	index := int(req.GetPlanStep())
+	tool := s.policy.Engine().ToolNormalizer().Normalize(req.GetToolName())
+	hash, err := paramsHash(toolReq)
+	if index < 0 || index >= len(claims.Steps) || err != nil ||
+		claims.Steps[index].Tool != tool || claims.Steps[index].Params != hash {
+		return nil, status.Errorf(codes.PermissionDenied, "call does not match step %d of the plan", index)
+	}
+	if err := s.plans.advance(claims, index); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	step := claims.Steps[index]
+	if step.Evaluate {
+		return nil, nil
+	}
+
+	agent, err := s.policy.agentContext(ctx, md)
+	if err != nil {
+		return nil, nil
+	}
+	// The plan was evaluated, and its fingerprint taken, by the engine of
+	// the agent's tenant
+	engine := s.policy.engineFor(agent.TenantID)
+	if engine.DecisionFingerprint(agent) != claims.Fingerprint {
+		return nil, nil
+	}
+
+	result := &policy.EvaluationResult{
+		Decision: policy.Allow,
+		Policy:   step.Policy,
+		Reason:   fmt.Sprintf("preauthorized by plan %s, step %d", claims.ID, index),
+	}
+	if compiled, ok := engine.ResolvePolicy(agent); ok {
+		if perm, ok := compiled.ToolTable[tool]; ok && perm.Constraints != nil {
+			result.Timeout = perm.Constraints.Timeout
+			result.MaxConcurrent = perm.Constraints.MaxConcurrent
+		}
+	}
+	engine.Audit(agent, req.GetToolName(), policy.Allow, result.Reason, req.GetRequestId())
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestPreAuthorize verifies plan tokens preauthorize the plan's calls in
+// order, once each, and only while the policy is unchanged
+func TestPreAuthorize(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+	server.SetToolExecutor(&sessionExecutor{})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}, {Tool: "file.write", Action: policy.Allow}}, policy.Enforcing, ""))
+	ctx := context.Background()
+	md := &agentpb.RequestMetadata{AgentType: "coding-assistant", TenantId: "tenant-a"}
+
+	denied, err := server.PreAuthorize(ctx, &agentpb.PreAuthorizeRequest{Metadata: md, Calls: []*agentpb.PlannedCall{
+		{ToolName: "file.read"}, {ToolName: "shell.execute"},
+	}})
+	if err != nil {
+		t.Fatalf("PreAuthorize failed: %v", err)
+	}
+	if denied.GetAuthorized() || denied.GetPlanToken() != "" || denied.GetSteps()[1].GetDecision().GetDecision() != policy.Deny.String() {
+		t.Errorf("expected the plan to be denied without a token, got %v", denied)
+	}
+
+	plan, err := server.PreAuthorize(ctx, &agentpb.PreAuthorizeRequest{Metadata: md, TtlSeconds: 3600, Calls: []*agentpb.PlannedCall{
+		{ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/a"}`)},
+		{ToolName: "file.write", Parameters: []byte(`{"path":"/workspace/b"}`)},
+		{ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/c"}`)},
+	}})
+	if err != nil {
+		t.Fatalf("PreAuthorize failed: %v", err)
+	}
+	if !plan.GetAuthorized() || plan.GetPlanToken() == "" || !plan.GetSteps()[0].GetPreauthorized() {
+		t.Fatalf("expected the plan to be authorized, got %v", plan)
+	}
+	if time.Until(time.Unix(0, plan.GetExpiresUnixNano())) > 5*time.Minute {
+		t.Errorf("expected the token lifetime capped at 5m, got %v", time.Until(time.Unix(0, plan.GetExpiresUnixNano())))
+	}
+
+	step := func(i int32, tool, params string, metadata *agentpb.RequestMetadata) (*agentpb.ExecuteResponse, error) {
+		return server.Execute(ctx, &agentpb.ExecuteRequest{
+			ToolName: tool, Parameters: []byte(params), Metadata: metadata,
+			PlanToken: plan.GetPlanToken(), PlanStep: i,
+		})
+	}
+
+	resp, err := step(0, "file.read", `{"path":"/workspace/a"}`, md)
+	if err != nil || !resp.GetPolicyDecision().GetPreauthorized() || resp.GetPolicyDecision().GetPolicyName() != "coding-policy" {
+		t.Fatalf("expected step 0 to be preauthorized, got %v (%v)", resp, err)
+	}
+
+	tests := []struct {
+		name     string
+		step     int32
+		tool     string
+		params   string
+		metadata *agentpb.RequestMetadata
+	}{
+		{"replayed step", 0, "file.read", `{"path":"/workspace/a"}`, md},
+		{"other parameters", 1, "file.write", `{"path":"/etc/passwd"}`, md},
+		{"other tool", 1, "file.read", `{"path":"/workspace/b"}`, md},
+		{"out of range", 3, "file.read", `{"path":"/workspace/c"}`, md},
+		{"other agent", 1, "file.write", `{"path":"/workspace/b"}`, &agentpb.RequestMetadata{AgentType: "coding-assistant", TenantId: "tenant-b"}},
+	}
+	for _, tt := range tests {
+		if _, err := step(tt.step, tt.tool, tt.params, tt.metadata); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%s: expected PermissionDenied, got %v", tt.name, err)
+		}
+	}
+
+	// Once the policy changes, the plan's calls are evaluated again
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+	resp, err = step(1, "file.write", `{"path":"/workspace/b"}`, md)
+	if status.Code(err) != codes.PermissionDenied || resp.GetPolicyDecision().GetPreauthorized() {
+		t.Errorf("expected step 1 to be evaluated and denied, got %v (%v)", resp, err)
+	}
+	resp, err = step(2, "file.read", `{"path":"/workspace/c"}`, md)
+	if err != nil || resp.GetPolicyDecision().GetPreauthorized() || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Errorf("expected step 2 to be evaluated and allowed, got %v (%v)", resp, err)
+	}
+
+	if _, err := server.Execute(ctx, &agentpb.ExecuteRequest{ToolName: "file.read", Metadata: md, PlanToken: plan.GetPlanToken() + "x"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected a forged token to be rejected, got %v", err)
+	}
+}
+
+// TestPreAuthorizeTenantPartition verifies plan tokens preauthorize the
+// calls of tenants evaluated by a partition with a policy of its own
+func TestPreAuthorizeTenantPartition(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.TenantPartitions = true
+	server := NewServer(config)
+	server.SetToolExecutor(&sessionExecutor{})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny, nil, policy.Enforcing, ""))
+	if err := server.policy.TenantEngine().LoadTenantPolicy("tenant-a", "coding-assistant", policy.CompilePolicy("tenant-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, "")); err != nil {
+		t.Fatalf("LoadTenantPolicy failed: %v", err)
+	}
+	ctx := context.Background()
+	md := &agentpb.RequestMetadata{AgentType: "coding-assistant", TenantId: "tenant-a"}
+
+	plan, err := server.PreAuthorize(ctx, &agentpb.PreAuthorizeRequest{Metadata: md, Calls: []*agentpb.PlannedCall{
+		{ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/a"}`)},
+	}})
+	if err != nil || !plan.GetAuthorized() {
+		t.Fatalf("expected the plan to be authorized by the tenant's policy, got %v (%v)", plan, err)
+	}
+	resp, err := server.Execute(ctx, &agentpb.ExecuteRequest{
+		ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/a"}`), Metadata: md,
+		PlanToken: plan.GetPlanToken(),
+	})
+	if err != nil || !resp.GetPolicyDecision().GetPreauthorized() || resp.GetPolicyDecision().GetPolicyName() != "tenant-policy" {
+		t.Errorf("expected step 0 to be preauthorized, got %v (%v)", resp, err)
+	}
+}
+
+// TestPreAuthorizeOtherReplica verifies replicas sharing the session key
+// evaluate the calls of plans another replica issued
+func TestPreAuthorizeOtherReplica(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.SessionKey = []byte("shared-session-key")
+	compiled := policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, "")
+	issuer, other := NewServer(config), NewServer(config)
+	for _, server := range []*Server{issuer, other} {
+		server.SetToolExecutor(&sessionExecutor{})
+		server.LoadPolicy("coding-assistant", compiled)
+	}
+	ctx := context.Background()
+	md := &agentpb.RequestMetadata{AgentType: "coding-assistant"}
+
+	plan, err := issuer.PreAuthorize(ctx, &agentpb.PreAuthorizeRequest{Metadata: md, Calls: []*agentpb.PlannedCall{
+		{ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/a"}`)},
+	}})
+	if err != nil || !plan.GetAuthorized() {
+		t.Fatalf("expected the plan to be authorized, got %v (%v)", plan, err)
+	}
+	execute := func(server *Server) (*agentpb.ExecuteResponse, error) {
+		return server.Execute(ctx, &agentpb.ExecuteRequest{
+			ToolName: "file.read", Parameters: []byte(`{"path":"/workspace/a"}`), Metadata: md,
+			PlanToken: plan.GetPlanToken(),
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := execute(other)
+		if err != nil || resp.GetPolicyDecision().GetPreauthorized() || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+			t.Errorf("expected the other replica to evaluate the call, got %v (%v)", resp, err)
+		}
+	}
+	if resp, err := execute(issuer); err != nil || !resp.GetPolicyDecision().GetPreauthorized() {
+		t.Errorf("expected the issuing replica to preauthorize the call, got %v (%v)", resp, err)
+	}
+}
@@ -22,19 +22,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/gitops"
 	"github.com/golden-agent/golden-agent/pkg/controller"
+	"github.com/golden-agent/golden-agent/pkg/offline"
 	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/redact"
 )
 
 var (
@@ -54,6 +65,22 @@ type PolicyConfig struct {
 	// CacheTTL is the duration to cache policy decisions
 	CacheTTL time.Duration
 
+	// EvaluationTimeout bounds each policy evaluation; calls whose
+	// evaluation exceeds it fail with DEADLINE_EXCEEDED. Default: 0 (bounded
+	// only by the caller's deadline)
+	EvaluationTimeout time.Duration
+
+	// LatencyGuard degrades evaluation, to the legacy tool table or to
+	// cached decisions only, while its p99 latency is past
+	// LatencyGuard.Threshold (see policy.WithLatencyGuard). Default: zero
+	// (no guard)
+	LatencyGuard policy.LatencyGuardConfig
+
+	// CombiningAlgorithm is how the policies that apply to an agent, such
+	// as its agent type's and an agent type pattern's, are combined.
+	// Default: "" (policy.FirstApplicable, the most specific decides)
+	CombiningAlgorithm policy.CombiningAlgorithm
+
 	// PolicyPath is the path to watch for AgentPolicy CRDs (Kubernetes mode)
 	PolicyPath string
 
@@ -63,6 +90,36 @@ type PolicyConfig struct {
 	// AuditSink is the destination for audit events (optional)
 	AuditSink policy.AuditSink
 
+	// AuditParameters records request parameters in audit events, so
+	// that behavior profiles can be learned from the audit log
+	AuditParameters bool
+
+	// AuditRedactor, when set, redacts PII and secrets from the request
+	// parameters recorded in audit events
+	AuditRedactor *redact.Redactor
+
+	// ExternalAuthorizer, when set, is consulted after local evaluation
+	// (see HTTPAuthorizer and GRPCAuthorizer); its decision is combined
+	// with the local one as ExternalCombination says (default:
+	// deny-overrides), and calls that take longer than ExternalTimeout
+	// (default: policy.DefaultExternalTimeout) are denied
+	ExternalAuthorizer  policy.ExternalAuthorizer
+	ExternalCombination policy.ExternalCombination
+	ExternalTimeout     time.Duration
+
+	// Environment is where the router runs, as the environment conditions
+	// of tool rules see it
+	Environment policy.Environment
+
+	// EnrichmentProvider, when set, looks up destinations for the
+	// destination conditions of tool rules (see policy.CIDRGeoProvider)
+	EnrichmentProvider policy.EnrichmentProvider
+
+	// ContextEnrichers add to the AgentContext of every call, in order,
+	// what sources other than its request metadata know of the caller
+	// (see ContextEnricher)
+	ContextEnrichers []ContextEnricher
+
 	// ============================================================
 	// OPA Integration Settings
 	// ============================================================
@@ -72,6 +129,30 @@ type PolicyConfig struct {
 	// When false, policies use the legacy ToolTable evaluation.
 	UseOPA bool
 
+	// OPAMemoTTL memoizes OPA results of calls the decision cache cannot
+	// hold (tool rules with custom constraints, behavior profiles) by
+	// their exact input for this long. Default: 0 (disabled)
+	OPAMemoTTL time.Duration
+
+	// PartialEval specializes the OPA query of each policy for each agent
+	// type it applies to when it is loaded, with OPA partial evaluation.
+	// Default: false
+	PartialEval bool
+
+	// TraceAgentTypes are the agent types (or path.Match patterns of
+	// them) whose calls may ask for OPA's trace of their evaluation
+	// (RequestMetadata.Trace); the calls of others that ask are rejected
+	// with ErrTraceNotAllowed. Agent types are claimed by callers unless
+	// identities are authenticated (TokenReview or
+	// ServerConfig.IdentityAuthenticator), so outside of development only
+	// allow tracing with authentication. Default: none
+	TraceAgentTypes []string
+
+	// OPAMetrics collects OPA's evaluation metrics of every query, exports
+	// them per policy rule, and returns those of each decision in its
+	// PolicyDecision. Default: false
+	OPAMetrics bool
+
 	// EnableController enables the Kubernetes controller for CRD watching.
 	// When true, the router will watch for AgentPolicy CRDs and sync them.
 	EnableController bool
@@ -83,6 +164,160 @@ type PolicyConfig struct {
 	// HealthProbeAddr is the address for the controller health probes.
 	// Default: ":8081"
 	HealthProbeAddr string
+
+	// ImpactCheck enables the controller's pre-activation replay of
+	// recorded audit traffic against changed policies (optional).
+	ImpactCheck *controller.ImpactCheckConfig
+
+	// ReconcileOptions tune the concurrency, backoff, and rate of
+	// AgentPolicy reconciles. Default: controller-runtime's
+	ReconcileOptions controller.ReconcileOptions
+
+	// RenderRego writes the Rego generated for each AgentPolicy to a
+	// ConfigMap next to it, referenced by its status.renderedRegoRef, for
+	// inspection. Requires UseOPA. Default: false
+	RenderRego bool
+
+	// ValuesFrom reads the constraint values AgentPolicies take from the
+	// ConfigMaps and Secrets of their namespace, watching their metadata
+	// and reading them uncached. Requires EnableController. Default: false
+	// (policies reading values fail)
+	ValuesFrom bool
+
+	// InvalidationConfigMap, as "namespace/name", broadcasts decision cache
+	// invalidations between router replicas through a ConfigMap, so that a
+	// policy update on one replica clears stale decisions on the others.
+	// Requires EnableController. Default: "" (disabled)
+	InvalidationConfigMap string
+
+	// Heartbeat, as "namespace/name", reports the policies each replica
+	// loaded through per-replica Leases in the namespace, labeled with the
+	// name, and records the replicas that loaded each policy in its status.
+	// Requires EnableController. Default: "" (disabled)
+	Heartbeat string
+
+	// ReplicaIdentity names this replica's heartbeat Lease and must be
+	// unique among the replicas. Default: the hostname (the pod name)
+	ReplicaIdentity string
+
+	// TokenReview, when set, authenticates agents by the ServiceAccount
+	// token in their "authorization" gRPC metadata, and takes their agent
+	// type and tenant from the AgentIdentityBinding of the ServiceAccount
+	// instead of their request metadata. Requires EnableController.
+	// Default: nil (request metadata is trusted)
+	TokenReview *controller.TokenReviewConfig
+
+	// SandboxClaims watches SandboxClaims and cross-checks the tenant, MTS
+	// label, and policy of each call from a claimed sandbox against its
+	// claim. Requires EnableController and the SandboxClaim CRD.
+	// Default: false
+	SandboxClaims bool
+
+	// TenantRegistry, when set, is the server-side authority for the MTS
+	// labels of tenants: calls are evaluated with their tenant's label,
+	// and claimed labels that differ are flagged or denied. With
+	// SandboxClaims, it is filled from the claims. Default: nil (claimed
+	// labels are trusted)
+	TenantRegistry *policy.TenantRegistry
+
+	// MTSAllocations, as "namespace/name", allocates the tenants of
+	// SandboxClaims whose policy sets no MTS label a label no other tenant
+	// holds, persisted in the ConfigMap. Requires SandboxClaims. Default: ""
+	// (such tenants have no label)
+	MTSAllocations string
+
+	// NodeName runs the router node-local, for one router per node: only
+	// the SandboxClaims of sandboxes scheduled on the node are loaded, and
+	// of the AgentPolicies only the fallback, the policies those claims
+	// reference, and their canaries. Requires SandboxClaims. Default: ""
+	// (every claim and policy is loaded)
+	NodeName string
+
+	// Tenants watches Tenants, allocates each an MTS category of its own,
+	// and fills TenantRegistry with the labels of the tenant hierarchy
+	// instead of SandboxClaims. Requires EnableController, TenantRegistry,
+	// and the Tenant CRD. Default: false
+	Tenants bool
+
+	// TenantConfigs watches TenantConfigs and evaluates the calls of their
+	// tenants in their enforcement mode instead of Mode. Requires
+	// EnableController and the TenantConfig CRD. Default: false
+	TenantConfigs bool
+
+	// TenantPartitions evaluates the calls of each tenant in a partition
+	// of the engine with a decision cache of its own (see
+	// policy.TenantEngine), so that one tenant's traffic cannot evict
+	// another's cached decisions. Policies, kill switches, canaries, modes,
+	// and restored snapshots apply to every partition. Default: false
+	TenantPartitions bool
+
+	// MaxTenants caps the number of tenant partitions; the calls of
+	// tenants beyond it are evaluated in the default partition. Default: 0
+	// (policy.DefaultMaxTenants)
+	MaxTenants int
+
+	// ToolNameRule is how tool names called by agents are normalized to
+	// the names policies are written in. Default: "" (ToolNamesConvert)
+	ToolNameRule policy.ToolNameRule
+
+	// ToolAliases watches ToolAliases and normalizes their aliases to
+	// their tools before ToolNameRule applies. Requires EnableController
+	// and the ToolAlias CRD. Default: false
+	ToolAliases bool
+
+	// ToolCatalog, when set, is filled with the tools of ToolCatalogs,
+	// describes the tools ListAllowedTools reports, and rates the risk of
+	// tools for RiskRules. Requires EnableController and the ToolCatalog
+	// CRD. Default: nil
+	ToolCatalog *policy.ToolCatalog
+
+	// KillSwitches watches ToolKillSwitches and denies every call to the
+	// tools they disable. Requires EnableController and the ToolKillSwitch
+	// CRD. Default: false
+	KillSwitches bool
+
+	// RiskRules act on the calls of every policy by the risk level of
+	// their tool, such as requiring approval of high-risk tools (see
+	// policy.RiskRule). Default: nil
+	RiskRules []policy.RiskRule
+
+	// WebhookPort, when set, serves the AgentPolicy validating webhook,
+	// which warns of tools ToolCatalog does not declare, on this port with
+	// the tls.crt and tls.key of WebhookCertDir. Requires ToolCatalog.
+	// Default: 0 (no webhook)
+	WebhookPort    int
+	WebhookCertDir string
+
+	// GitOps, when set, syncs AgentPolicy manifests from a Git repository:
+	// into the engine directly, or, with GitOpsApply, to the cluster as
+	// AgentPolicy resources for the controller to load. GitOpsApply
+	// requires EnableController. Default: nil (no Git source)
+	GitOps      *gitops.Config
+	GitOpsApply bool
+
+	// Offline, when set, loads policies from a signed local bundle and
+	// spools audit events to local storage, without the Kubernetes API.
+	// Incompatible with EnableController. Default: nil (online)
+	Offline *OfflineConfig
+
+	// SnapshotFrom is where Server.RestoreSnapshot reads the engine
+	// snapshot a new replica starts from: the URL of the /snapshot page of
+	// a running router's inspection UI, or the path of a snapshot file.
+	// Default: "" (start with no policies)
+	SnapshotFrom string
+
+	// RecentDenials is the number of recent denials kept for the
+	// inspection UI (see Server.InspectHandler). Default: 0 (none kept)
+	RecentDenials int
+
+	// Logger receives the log records of the engine, the controller, and
+	// the server, with request fields keyed as policy.LogKeyRequestID and
+	// its siblings. Default: slog.Default()
+	Logger *slog.Logger
+
+	// Faults injects failures into the engine and the controller, for
+	// failure-mode tests. Default: nil (none)
+	Faults *policy.FaultInjector
 }
 
 // DefaultPolicyConfig returns sensible defaults for policy integration.
@@ -116,6 +351,9 @@ type RouterPolicyIntegration struct {
 	engine *policy.Engine
 	config PolicyConfig
 
+	// Tenant partitions of engine (nil if not partitioned)
+	tenants *policy.TenantEngine
+
 	// mu protects watcher state
 	mu       sync.RWMutex
 	watching bool
@@ -123,35 +361,167 @@ type RouterPolicyIntegration struct {
 
 	// Controller-runtime manager (nil if controller not enabled)
 	mgr ctrl.Manager
+
+	// Cross-replica cache invalidation bus (nil if not configured)
+	bus *controller.ConfigMapInvalidationBus
+
+	// Replica heartbeat reporting loaded policies (nil if not configured)
+	heartbeat *controller.ReplicaHeartbeat
+
+	// ServiceAccount token authenticator (nil if not configured)
+	tokenReview *controller.TokenReviewAuthenticator
+
+	// Offline audit spool (nil if not configured)
+	spool *offline.Spool
+
+	// Recent denials, for the inspection UI (nil if not configured)
+	denials *denialLog
+
+	// Readiness check of the embedding server, served on the manager's
+	// readiness probe (nil if none)
+	readyz healthz.Checker
+
+	// Metrics of the embedding server, registered with the router's when
+	// the controller starts
+	collectors []prometheus.Collector
+
+	// log is config.Logger, or slog.Default()
+	log *slog.Logger
 }
 
 // NewRouterPolicyIntegration creates a new policy integration layer.
 func NewRouterPolicyIntegration(config PolicyConfig) *RouterPolicyIntegration {
-	return &RouterPolicyIntegration{
-		engine: initPolicyEngine(config),
-		config: config,
+	r := &RouterPolicyIntegration{config: config, log: config.Logger}
+	if r.log == nil {
+		r.log = slog.Default()
+	}
+
+	var opts []policy.Option
+	if config.InvalidationConfigMap != "" {
+		namespace, name, ok := strings.Cut(config.InvalidationConfigMap, "/")
+		if !ok {
+			namespace, name = "default", config.InvalidationConfigMap
+		}
+		r.bus = controller.NewConfigMapInvalidationBus(namespace, name)
+		opts = append(opts, policy.WithInvalidationBus(r.bus))
+	}
+
+	if config.Heartbeat != "" {
+		namespace, name, ok := strings.Cut(config.Heartbeat, "/")
+		if !ok {
+			namespace, name = "default", config.Heartbeat
+		}
+		identity := config.ReplicaIdentity
+		if identity == "" {
+			identity, _ = os.Hostname()
+		}
+		r.heartbeat = controller.NewReplicaHeartbeat(namespace, name, identity)
+	}
+
+	if config.TokenReview != nil {
+		r.tokenReview = controller.NewTokenReviewAuthenticator(*config.TokenReview)
+	}
+
+	if config.Offline != nil && config.Offline.SpoolDir != "" {
+		r.spool = offline.NewSpool(config.Offline.SpoolDir, config.Offline.MaxSpoolBytes, r.log)
+		config.AuditSink = addAuditSink(config.AuditSink, r.spool)
+	}
+
+	if config.RecentDenials > 0 {
+		r.denials = newDenialLog(config.RecentDenials)
+		config.AuditSink = addAuditSink(config.AuditSink, r.denials)
+	}
+
+	r.engine = initPolicyEngine(config, opts...)
+	if config.TenantPartitions {
+		r.tenants = policy.NewTenantEngineFrom(r.engine, policy.TenantEngineConfig{
+			CacheTTL:   config.CacheTTL,
+			MaxTenants: config.MaxTenants,
+		})
 	}
+	return r
 }
 
-// initPolicyEngine creates and configures the policy engine.
-func initPolicyEngine(config PolicyConfig) *policy.Engine {
+// addAuditSink adds sink to the audit sink of the engine, if any.
+func addAuditSink(existing, sink policy.AuditSink) policy.AuditSink {
+	if existing == nil {
+		return sink
+	}
+	return policy.NewAuditEmitter(existing, sink)
+}
+
+// initPolicyEngine creates and configures the policy engine, with any
+// extra options applied last.
+func initPolicyEngine(config PolicyConfig, extra ...policy.Option) *policy.Engine {
 	opts := []policy.Option{
 		policy.WithMode(config.Mode),
+		policy.WithLogger(config.Logger),
+		policy.WithFaults(config.Faults),
+		policy.WithToolNormalizer(policy.NewToolNormalizer(config.ToolNameRule)),
+	}
+
+	if config.TenantRegistry != nil {
+		opts = append(opts, policy.WithTenantRegistry(config.TenantRegistry))
+	}
+
+	if config.ToolCatalog != nil {
+		opts = append(opts, policy.WithToolCatalog(config.ToolCatalog))
+	}
+
+	if len(config.RiskRules) > 0 {
+		opts = append(opts, policy.WithRiskRules(config.RiskRules...))
 	}
 
 	if config.CacheTTL > 0 {
 		opts = append(opts, policy.WithCache(policy.NewDecisionCache(config.CacheTTL)))
 	}
 
+	if config.EvaluationTimeout > 0 {
+		opts = append(opts, policy.WithEvaluationTimeout(config.EvaluationTimeout))
+	}
+
+	if config.LatencyGuard.Threshold > 0 {
+		opts = append(opts, policy.WithLatencyGuard(config.LatencyGuard))
+	}
+
+	opts = append(opts, policy.WithCombiningAlgorithm(config.CombiningAlgorithm))
+
 	if config.AuditSink != nil {
 		opts = append(opts, policy.WithAuditSink(config.AuditSink))
 	}
 
+	if config.ExternalAuthorizer != nil {
+		opts = append(opts, policy.WithExternalAuthorizer(config.ExternalAuthorizer, config.ExternalCombination, config.ExternalTimeout))
+	}
+
+	opts = append(opts, policy.WithEnvironment(config.Environment))
+	if config.EnrichmentProvider != nil {
+		opts = append(opts, policy.WithEnrichmentProvider(config.EnrichmentProvider))
+	}
+
+	if config.AuditParameters {
+		opts = append(opts, policy.WithAuditParameters(true))
+		if config.AuditRedactor != nil {
+			opts = append(opts, policy.WithAuditRedactor(config.AuditRedactor))
+		}
+	}
+
 	// Enable OPA if configured
 	if config.UseOPA {
 		opts = append(opts, policy.WithOPA(true))
+		if config.OPAMemoTTL > 0 {
+			opts = append(opts, policy.WithOPAMemo(config.OPAMemoTTL))
+		}
+		if config.PartialEval {
+			opts = append(opts, policy.WithPartialEval(true))
+		}
+		if config.OPAMetrics {
+			opts = append(opts, policy.WithOPAMetrics(true))
+		}
 	}
 
+	opts = append(opts, extra...)
+
 	return policy.NewEngine(opts...)
 }
 
@@ -170,15 +540,33 @@ type RequestMetadata struct {
 	// SessionID is the current session identifier
 	SessionID string
 
+	// ParentSessionID is the session of the agent that delegated the
+	// task, and Lineage the sessions it was delegated through, root first
+	// and parent last (see policy.AgentContext)
+	ParentSessionID string
+	Lineage         []string
+
 	// MTSLabel is the Multi-Tenant Sandboxing label
 	MTSLabel string
 
 	// PolicyRef is the name of the policy to apply (optional override)
 	PolicyRef string
+
+	// Labels are additional agent attributes (e.g., environment, team)
+	// used for selector-based policy matching.
+	Labels map[string]string
+
+	// Locale is the preferred language for user-facing denial messages
+	Locale string
+
+	// Trace asks for OPA's trace of the call's evaluation (see
+	// PolicyConfig.TraceAgentTypes)
+	Trace bool
 }
 
 // extractAgentIdentity builds an AgentContext from request metadata.
-// This is called for every tool request to establish the caller's identity.
+// This is called for every tool request to establish the caller's identity,
+// before any context enrichers run.
 func extractAgentIdentity(metadata RequestMetadata) policy.AgentContext {
 	return policy.AgentContext{
 		AgentType: metadata.AgentType,
@@ -187,39 +575,12 @@ func extractAgentIdentity(metadata RequestMetadata) policy.AgentContext {
 		SessionID: metadata.SessionID,
 		MTSLabel:  metadata.MTSLabel,
 		PolicyRef: metadata.PolicyRef,
-	}
-}
+		Labels:    metadata.Labels,
+		Locale:    metadata.Locale,
 
-// extractToolName parses the tool name from a request.
-// Tool names follow the pattern: "category.action" (e.g., "file.read", "code.exec").
-//
-// The function normalizes various input formats:
-//   - "file.read" -> "file.read"
-//   - "FileRead" -> "file.read"
-//   - "file_read" -> "file.read"
-func extractToolName(rawName string) string {
-	if rawName == "" {
-		return ""
-	}
-
-	// Already in correct format
-	if strings.Contains(rawName, ".") {
-		return strings.ToLower(rawName)
-	}
-
-	// Convert CamelCase to dot notation
-	// FileRead -> file.read
-	var result strings.Builder
-	for i, r := range rawName {
-		if i > 0 && r >= 'A' && r <= 'Z' {
-			result.WriteRune('.')
-		}
-		result.WriteRune(r)
+		ParentSessionID: metadata.ParentSessionID,
+		Lineage:         metadata.Lineage,
 	}
-
-	// Convert snake_case to dot notation
-	normalized := strings.ToLower(result.String())
-	return strings.ReplaceAll(normalized, "_", ".")
 }
 
 // Evaluate checks if a tool request is permitted.
@@ -235,17 +596,64 @@ func (r *RouterPolicyIntegration) Evaluate(
 	toolName string,
 	request interface{},
 ) (policy.Decision, error) {
-	// Extract identity from metadata
-	agentCtx := extractAgentIdentity(metadata)
+	result, err := r.EvaluateWithResult(ctx, metadata, toolName, request)
+	if err != nil {
+		return policy.Deny, err
+	}
+	return result.Decision, nil
+}
+
+// EvaluateWithResult checks a tool request like Evaluate and also returns
+// the evaluation details, including the user-facing denial message.
+func (r *RouterPolicyIntegration) EvaluateWithResult(
+	ctx context.Context,
+	metadata RequestMetadata,
+	toolName string,
+	request interface{},
+) (*policy.EvaluationResult, error) {
+	// Extract identity from metadata, and enrich it
+	agentCtx, err := r.agentContext(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
 
-	// Normalize tool name
-	normalizedTool := extractToolName(toolName)
+	// Normalize the tool name, by alias or the normalizer's rule (e.g.,
+	// "FileRead" and "file_read" to "file.read"); audit events keep the
+	// raw name
+	normalizedTool := r.engine.ToolNormalizer().Normalize(toolName)
 	if normalizedTool == "" {
-		return policy.Deny, errors.New("empty tool name")
+		return nil, errors.New("empty tool name")
+	}
+	ctx = policy.ContextWithRawToolName(ctx, toolName)
+
+	if metadata.Trace {
+		if !r.traceAllowed(agentCtx.AgentType) {
+			return nil, fmt.Errorf("%w: agent type %q", ErrTraceNotAllowed, agentCtx.AgentType)
+		}
+		ctx = policy.ContextWithTrace(ctx)
 	}
 
 	// Delegate to policy engine
-	return r.engine.Evaluate(ctx, agentCtx, normalizedTool, request)
+	return r.engineFor(agentCtx.TenantID).EvaluateWithResult(ctx, agentCtx, normalizedTool, request)
+}
+
+// EvaluatePlan evaluates the calls of an agent's plan, whose tool names
+// are normalized (see policy.Engine.EvaluatePlan).
+func (r *RouterPolicyIntegration) EvaluatePlan(ctx context.Context, metadata RequestMetadata, calls []policy.PlanCall) (*policy.PlanEvaluation, error) {
+	agentCtx, err := r.agentContext(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return r.engineFor(agentCtx.TenantID).EvaluatePlan(ctx, agentCtx, calls)
+}
+
+// engineFor returns the engine that evaluates the calls of a tenant: its
+// partition with TenantPartitions, and the engine otherwise.
+func (r *RouterPolicyIntegration) engineFor(tenantID string) *policy.Engine {
+	if r.tenants == nil {
+		return r.engine
+	}
+	return r.tenants.Partition(tenantID)
 }
 
 // LoadPolicy adds or updates a policy for an agent type.
@@ -261,7 +669,8 @@ func (r *RouterPolicyIntegration) RemovePolicy(agentType string) {
 }
 
 // StartController starts the Kubernetes controller for watching AgentPolicy CRDs.
-// This creates a controller-runtime manager and registers the AgentPolicyReconciler.
+// This creates a controller-runtime manager and registers the AgentPolicyReconciler
+// and AgentProfileReconciler.
 //
 // The controller runs in a background goroutine and syncs policies from
 // Kubernetes to the embedded policy engine.
@@ -271,6 +680,9 @@ func (r *RouterPolicyIntegration) StartController(ctx context.Context) error {
 	if !r.config.EnableController {
 		return errors.New("controller not enabled in config")
 	}
+	if r.config.Offline != nil {
+		return errOffline
+	}
 
 	r.mu.Lock()
 	if r.watching {
@@ -281,14 +693,20 @@ func (r *RouterPolicyIntegration) StartController(ctx context.Context) error {
 	r.stopCh = make(chan struct{})
 	r.mu.Unlock()
 
-	// Setup logging
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	// Controllers log through the router's logger
+	ctrl.SetLogger(logr.FromSlogHandler(r.log.Handler()))
 
 	// Create controller-runtime manager
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:         scheme,
-		LeaderElection: false, // Embedded controller, no leader election
-	})
+	options := ctrl.Options{
+		Scheme:                 scheme,
+		LeaderElection:         false, // Embedded controller, no leader election
+		Metrics:                metricsserver.Options{BindAddress: r.config.MetricsAddr},
+		HealthProbeBindAddress: r.config.HealthProbeAddr,
+	}
+	if r.config.WebhookPort > 0 {
+		options.WebhookServer = webhook.NewServer(webhook.Options{Port: r.config.WebhookPort, CertDir: r.config.WebhookCertDir})
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		r.mu.Lock()
 		r.watching = false
@@ -298,12 +716,47 @@ func (r *RouterPolicyIntegration) StartController(ctx context.Context) error {
 
 	r.mgr = mgr
 
+	// Register health probes
+	if err := r.addHealthChecks(mgr); err != nil {
+		r.mu.Lock()
+		r.watching = false
+		r.mu.Unlock()
+		return fmt.Errorf("failed to setup health checks: %w", err)
+	}
+
+	// Register metrics
+	if err := r.registerMetrics(metrics.Registry); err != nil {
+		r.mu.Lock()
+		r.watching = false
+		r.mu.Unlock()
+		return fmt.Errorf("failed to register metrics: %w", err)
+	}
+
+	// Index SandboxClaims by node, for node-local routers to list the
+	// claims of their node
+	if r.config.NodeName != "" {
+		if err := controller.IndexSandboxClaimNodes(ctx, mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to index sandbox claims by node: %w", err)
+		}
+	}
+
 	// Register AgentPolicy controller
 	reconciler := &controller.AgentPolicyReconciler{
 		Client:       mgr.GetClient(),
 		Scheme:       mgr.GetScheme(),
 		PolicyEngine: r.engine,
 		UseOPA:       r.config.UseOPA,
+		ImpactCheck:  r.config.ImpactCheck,
+		Heartbeat:    r.heartbeat,
+		Faults:       r.config.Faults,
+		Options:      r.config.ReconcileOptions,
+		RenderRego:   r.config.RenderRego,
+		ValuesFrom:   r.config.ValuesFrom,
+		APIReader:    mgr.GetAPIReader(),
+		NodeName:     r.config.NodeName,
 	}
 
 	if err := reconciler.SetupWithManager(mgr); err != nil {
@@ -313,12 +766,189 @@ func (r *RouterPolicyIntegration) StartController(ctx context.Context) error {
 		return fmt.Errorf("failed to setup controller: %w", err)
 	}
 
+	// Register AgentProfile controller (behavior baselines)
+	profileReconciler := &controller.AgentProfileReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		PolicyEngine: r.engine,
+	}
+
+	if err := profileReconciler.SetupWithManager(mgr); err != nil {
+		r.mu.Lock()
+		r.watching = false
+		r.mu.Unlock()
+		return fmt.Errorf("failed to setup profile controller: %w", err)
+	}
+
+	// Register SandboxClaim controller (authoritative sandbox contexts)
+	if r.config.SandboxClaims {
+		claimReconciler := &controller.SandboxClaimReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			PolicyEngine: r.engine,
+			NodeName:     r.config.NodeName,
+		}
+		if r.config.MTSAllocations != "" {
+			namespace, name, ok := strings.Cut(r.config.MTSAllocations, "/")
+			if !ok {
+				namespace, name = "default", r.config.MTSAllocations
+			}
+			claimReconciler.LabelAllocator = controller.NewConfigMapLabelAllocator(namespace, name, r.config.Logger)
+		}
+		// Tenants, when watched, are the authority for tenant labels
+		if !r.config.Tenants {
+			claimReconciler.TenantRegistry = r.config.TenantRegistry
+		}
+
+		if err := claimReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup sandbox claim controller: %w", err)
+		}
+	}
+
+	// Register Tenant controller (tenant hierarchy and MTS categories)
+	if r.config.Tenants && r.config.TenantRegistry != nil {
+		tenantReconciler := &controller.TenantReconciler{
+			Client:         mgr.GetClient(),
+			Scheme:         mgr.GetScheme(),
+			TenantRegistry: r.config.TenantRegistry,
+		}
+
+		if err := tenantReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup tenant controller: %w", err)
+		}
+	}
+
+	// Register TenantConfig controller (per-tenant enforcement modes)
+	if r.config.TenantConfigs {
+		tenantConfigReconciler := &controller.TenantConfigReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+			Engine: r.engine,
+		}
+
+		if err := tenantConfigReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup tenant config controller: %w", err)
+		}
+	}
+
+	// Register ToolAlias controller (raw tool names)
+	if r.config.ToolAliases {
+		aliasReconciler := &controller.ToolAliasReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Normalizer: r.engine.ToolNormalizer(),
+		}
+
+		if err := aliasReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup tool alias controller: %w", err)
+		}
+	}
+
+	// Register ToolCatalog controller (known tools) and the AgentPolicy
+	// webhook that warns of unknown ones
+	if r.config.ToolCatalog != nil {
+		catalogReconciler := &controller.ToolCatalogReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Catalog: r.config.ToolCatalog,
+		}
+
+		if err := catalogReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup tool catalog controller: %w", err)
+		}
+
+		if r.config.WebhookPort > 0 {
+			validator := &controller.AgentPolicyValidator{Catalog: r.config.ToolCatalog}
+			if err := validator.SetupWebhookWithManager(mgr); err != nil {
+				r.mu.Lock()
+				r.watching = false
+				r.mu.Unlock()
+				return fmt.Errorf("failed to setup policy webhook: %w", err)
+			}
+		}
+	}
+
+	// Register ToolKillSwitch controller (globally disabled tools)
+	if r.config.KillSwitches {
+		killSwitchReconciler := &controller.ToolKillSwitchReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+			Engine: r.engine,
+		}
+
+		if err := killSwitchReconciler.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup tool kill switch controller: %w", err)
+		}
+	}
+
+	// Run the cross-replica cache invalidation bus
+	if r.bus != nil {
+		if err := r.bus.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup invalidation bus: %w", err)
+		}
+	}
+
+	// Run the replica heartbeat
+	if r.heartbeat != nil {
+		if err := r.heartbeat.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup replica heartbeat: %w", err)
+		}
+	}
+
+	// Apply the policies of the Git source to the cluster
+	if r.config.GitOps != nil && r.config.GitOpsApply {
+		source, err := gitops.NewSource(*r.config.GitOps, gitops.NewClusterTarget(mgr.GetClient(), r.config.GitOps.Name), r.log)
+		if err == nil {
+			err = source.SetupWithManager(mgr)
+		}
+		if err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup Git policy source: %w", err)
+		}
+	}
+
+	// Authenticate agents by their ServiceAccount tokens
+	if r.tokenReview != nil {
+		if err := r.tokenReview.SetupWithManager(mgr); err != nil {
+			r.mu.Lock()
+			r.watching = false
+			r.mu.Unlock()
+			return fmt.Errorf("failed to setup token review: %w", err)
+		}
+	}
+
 	// Start manager in background goroutine
 	go func() {
 		if err := mgr.Start(ctx); err != nil {
 			// Log error but don't crash - the router can still function
 			// with pre-loaded policies
-			fmt.Printf("controller manager error: %v\n", err)
+			r.log.Error("controller manager stopped", "error", err)
 		}
 
 		r.mu.Lock()
@@ -329,6 +959,42 @@ func (r *RouterPolicyIntegration) StartController(ctx context.Context) error {
 	return nil
 }
 
+// StartPolicySource loads the offline policy bundle, if any, and syncs
+// the policies of the Git source, if any, into the engine in a background
+// goroutine until ctx is done. Sources that apply to the cluster are
+// started by StartController instead.
+func (r *RouterPolicyIntegration) StartPolicySource(ctx context.Context) error {
+	if r.config.Offline != nil {
+		if err := r.startOffline(ctx); err != nil {
+			return err
+		}
+	}
+	if r.config.GitOps == nil || r.config.GitOpsApply {
+		return nil
+	}
+	source, err := gitops.NewSource(*r.config.GitOps, gitops.NewEngineTarget(r.engine, r.config.UseOPA, r.log), r.log)
+	if err != nil {
+		return err
+	}
+	go source.Start(ctx)
+	return nil
+}
+
+// addHealthChecks registers the liveness and readiness probes of the
+// manager's health endpoint.
+func (r *RouterPolicyIntegration) addHealthChecks(mgr ctrl.Manager) error {
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("policy-engine", func(*http.Request) error { return r.HealthCheck() }); err != nil {
+		return err
+	}
+	if r.readyz != nil {
+		return mgr.AddReadyzCheck("router", r.readyz)
+	}
+	return nil
+}
+
 // watchPolicies is the legacy method for starting the policy watcher.
 // Deprecated: Use StartController instead.
 func (r *RouterPolicyIntegration) watchPolicies(ctx context.Context) error {
@@ -347,10 +1013,18 @@ func (r *RouterPolicyIntegration) StopWatching() {
 }
 
 // Engine returns the underlying policy engine (for testing and inspection).
+// With TenantPartitions, it is the default partition, whose policies and
+// engine-wide state every tenant partition shares.
 func (r *RouterPolicyIntegration) Engine() *policy.Engine {
 	return r.engine
 }
 
+// TenantEngine returns the tenant partitions of the engine, or nil
+// without TenantPartitions.
+func (r *RouterPolicyIntegration) TenantEngine() *policy.TenantEngine {
+	return r.tenants
+}
+
 // Mode returns the current enforcement mode.
 func (r *RouterPolicyIntegration) Mode() policy.EnforcementMode {
 	return r.engine.Mode()
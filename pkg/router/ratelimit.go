@@ -0,0 +1,169 @@
+package router
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// RateLimitKey selects how the rate limiter identifies clients.
+type RateLimitKey string
+
+const (
+	// RateLimitBySandbox gives each sandbox its own bucket
+	RateLimitBySandbox RateLimitKey = "sandbox"
+
+	// RateLimitByTenant shares a bucket between a tenant's sandboxes
+	RateLimitByTenant RateLimitKey = "tenant"
+)
+
+// RateLimitConfig configures per-client rate limiting of Execute calls.
+// Each client has a token bucket; a call without a token is rejected with
+// RESOURCE_EXHAUSTED before its policy is evaluated, so one runaway agent
+// cannot starve the router. Policies may override the limit for the
+// clients they apply to (AgentPolicy spec.rateLimit).
+type RateLimitConfig struct {
+	// RequestsPerSecond is the default sustained rate of each client.
+	// 0 disables the default limit; policy overrides still apply.
+	RequestsPerSecond float64
+
+	// Burst is the default number of requests a client may make at once
+	// (default: RequestsPerSecond, rounded up)
+	Burst int
+
+	// Key identifies clients by sandbox ID (default) or tenant ID. Clients
+	// without one share a bucket per agent type.
+	Key RateLimitKey
+
+	// MaxClients bounds the number of tracked buckets (default: 10000).
+	// At the bound, buckets that are full again are dropped; a dropped
+	// bucket is indistinguishable from a new one.
+	MaxClients int
+}
+
+// rateLimiter holds the token buckets of clients.
+type rateLimiter struct {
+	config RateLimitConfig
+
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.Key == "" {
+		config.Key = RateLimitBySandbox
+	}
+	if config.MaxClients <= 0 {
+		config.MaxClients = 10000
+	}
+	return &rateLimiter{config: config, buckets: make(map[string]*rate.Limiter)}
+}
+
+// clientKey returns the bucket key of a client.
+func (l *rateLimiter) clientKey(md RequestMetadata) string {
+	switch {
+	case l.config.Key == RateLimitByTenant && md.TenantID != "":
+		return "tenant/" + md.TenantID
+	case l.config.Key == RateLimitBySandbox && md.SandboxID != "":
+		return "sandbox/" + md.SandboxID
+	default:
+		return "agentType/" + md.AgentType
+	}
+}
+
+// allow takes a token from a client's bucket, under the policy's limit if
+// it sets one. If none is available it returns false and how long until
+// one will be.
+func (l *rateLimiter) allow(key string, override *policy.RateLimit) (time.Duration, bool) {
+	limit := policy.RateLimit{RequestsPerSecond: l.config.RequestsPerSecond, Burst: l.config.Burst}
+	if override != nil {
+		limit = *override
+	}
+	if limit.RequestsPerSecond <= 0 {
+		return 0, true
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.RequestsPerSecond))
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.config.MaxClients {
+			l.evictFull(now)
+		}
+		bucket = rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.Burst)
+		l.buckets[key] = bucket
+	}
+	l.mu.Unlock()
+
+	// The limit changes when the client's policy does
+	if bucket.Limit() != rate.Limit(limit.RequestsPerSecond) {
+		bucket.SetLimitAt(now, rate.Limit(limit.RequestsPerSecond))
+	}
+	if bucket.Burst() != limit.Burst {
+		bucket.SetBurstAt(now, limit.Burst)
+	}
+
+	r := bucket.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// evictFull drops the buckets that have refilled. Callers must hold l.mu.
+func (l *rateLimiter) evictFull(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimited takes a token from the bucket of a call's client, under its
+// policy's limit if it sets one. If none is available it returns false,
+// the client's key, and how long until one will be.
+func (s *Server) rateLimited(md RequestMetadata) (string, time.Duration, bool) {
+	var override *policy.RateLimit
+	if compiled, ok := s.policy.Engine().ResolvePolicy(extractAgentIdentity(md)); ok {
+		override = compiled.RateLimit
+	}
+
+	key := s.rateLimiter.clientKey(md)
+	delay, ok := s.rateLimiter.allow(key, override)
+	return key, delay, ok
+}
+
+// checkRateLimit rejects a call whose client is over its rate limit with a
+// RESOURCE_EXHAUSTED status carrying RetryInfo and QuotaFailure details.
+func (s *Server) checkRateLimit(md RequestMetadata) error {
+	key, delay, ok := s.rateLimited(md)
+	if ok {
+		return nil
+	}
+
+	st := status.Newf(codes.ResourceExhausted, "rate limit exceeded for %s", key)
+	withDetails, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     key,
+			Description: fmt.Sprintf("request rate limit exceeded; retry in %s", delay.Round(time.Millisecond)),
+		}}},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestServerRateLimit verifies clients exhaust their own buckets, get
+// RESOURCE_EXHAUSTED with a retry delay, and policies override the limit
+func TestServerRateLimit(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.RateLimit = RateLimitConfig{RequestsPerSecond: 0.001, Burst: 2}
+	server := NewServer(config)
+	server.SetToolExecutor(&sessionExecutor{})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Allow, nil, policy.Enforcing, ""))
+	burstPolicy := policy.CompilePolicy("batch-policy", []string{"batch-agent"}, policy.Allow, nil, policy.Enforcing, "")
+	burstPolicy.RateLimit = &policy.RateLimit{RequestsPerSecond: 0.001, Burst: 4}
+	server.LoadPolicy("batch-agent", burstPolicy)
+
+	execute := func(agentType, sandbox string) error {
+		_, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{ToolName: "file.read",
+			Metadata: &agentpb.RequestMetadata{AgentType: agentType, SandboxId: sandbox}})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := execute("coding-assistant", "sandbox-a"); err != nil {
+			t.Fatalf("call %d: expected success within burst, got %v", i, err)
+		}
+	}
+	err := execute("coding-assistant", "sandbox-a")
+	st, _ := status.FromError(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED beyond burst, got %v", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() <= time.Second {
+		t.Errorf("expected RetryInfo with the refill delay, got %v", st.Details())
+	}
+
+	if err := execute("coding-assistant", "sandbox-b"); err != nil {
+		t.Errorf("expected another sandbox to have its own bucket, got %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := execute("batch-agent", "sandbox-c"); err != nil {
+			t.Fatalf("call %d: expected policy burst to apply, got %v", i, err)
+		}
+	}
+	if err := execute("batch-agent", "sandbox-c"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected RESOURCE_EXHAUSTED beyond policy burst, got %v", err)
+	}
+}
+
+// TestRateLimiterKeys verifies tenant keying, the agent type fallback, and
+// eviction of refilled buckets
+func TestRateLimiterKeys(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{RequestsPerSecond: 0.001, Burst: 1, Key: RateLimitByTenant, MaxClients: 2})
+
+	tests := []struct {
+		md   RequestMetadata
+		want string
+	}{
+		{RequestMetadata{AgentType: "coding-assistant", SandboxID: "sandbox-a", TenantID: "tenant-a"}, "tenant/tenant-a"},
+		{RequestMetadata{AgentType: "coding-assistant", SandboxID: "sandbox-a"}, "agentType/coding-assistant"},
+	}
+	for _, tt := range tests {
+		if got := limiter.clientKey(tt.md); got != tt.want {
+			t.Errorf("expected key %q, got %q", tt.want, got)
+		}
+	}
+
+	if _, ok := limiter.allow("tenant/tenant-a", nil); !ok {
+		t.Fatal("expected first call to be allowed")
+	}
+	if _, ok := limiter.allow("tenant/tenant-a", nil); ok {
+		t.Error("expected tenant bucket to be exhausted")
+	}
+	limiter.allow("tenant/tenant-a", &policy.RateLimit{RequestsPerSecond: 1000, Burst: 1})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := limiter.allow("tenant/tenant-a", &policy.RateLimit{RequestsPerSecond: 1000, Burst: 1}); !ok {
+		t.Error("expected a raised policy limit to apply to the existing bucket")
+	}
+
+	// Full buckets are dropped once MaxClients is reached
+	limiter.allow("tenant/tenant-b", &policy.RateLimit{RequestsPerSecond: 1e9, Burst: 1})
+	time.Sleep(time.Millisecond)
+	limiter.allow("tenant/tenant-c", nil)
+	if _, ok := limiter.buckets["tenant/tenant-b"]; ok || len(limiter.buckets) > 2 {
+		t.Errorf("expected refilled bucket to be evicted, got %d buckets", len(limiter.buckets))
+	}
+}
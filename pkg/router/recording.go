@@ -0,0 +1,390 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/redact"
+)
+
+// RedactedValue replaces the redacted values of recorded parameters and
+// results.
+const RedactedValue = redact.Placeholder
+
+// DefaultRedactKeys are the parameter and result keys whose values are
+// redacted from recordings by default. Keys match case-insensitively and
+// by substring, so "token" also redacts "access_token".
+var DefaultRedactKeys = redact.DefaultKeys
+
+// Recording is one Execute call, as written to a recording: the request,
+// and the response or error it got.
+type Recording struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Request   RecordedRequest `json:"request"`
+
+	// Response is the response of the call, if any. Denials have both a
+	// response and an error.
+	Response *RecordedResponse `json:"response,omitempty"`
+
+	// Code and Error are the gRPC status code and message of calls that
+	// returned an error
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RecordedRequest is a recorded ExecuteRequest. The session token is never
+// recorded: replays of calls made with one carry no identity and fail.
+type RecordedRequest struct {
+	ToolName   string                   `json:"tool_name"`
+	Parameters json.RawMessage          `json:"parameters,omitempty"`
+	Metadata   *agentpb.RequestMetadata `json:"metadata,omitempty"`
+	RequestID  string                   `json:"request_id,omitempty"`
+	File       *agentpb.FileParams      `json:"file,omitempty"`
+	Network    *agentpb.NetworkParams   `json:"network,omitempty"`
+	Exec       *agentpb.ExecParams      `json:"exec,omitempty"`
+
+	// Session is true if the call carried a session token
+	Session bool `json:"session,omitempty"`
+}
+
+// RecordedResponse is a recorded ExecuteResponse.
+type RecordedResponse struct {
+	Status   string          `json:"status"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Decision string          `json:"decision,omitempty"`
+	Policy   string          `json:"policy,omitempty"`
+}
+
+// ExecuteRequest returns the request to replay the recorded call with.
+func (r *RecordedRequest) ExecuteRequest() *agentpb.ExecuteRequest {
+	req := &agentpb.ExecuteRequest{
+		ToolName:   r.ToolName,
+		Parameters: r.Parameters,
+		Metadata:   r.Metadata,
+		RequestId:  r.RequestID,
+	}
+	switch {
+	case r.File != nil:
+		req.TypedParameters = &agentpb.ExecuteRequest_File{File: r.File}
+	case r.Network != nil:
+		req.TypedParameters = &agentpb.ExecuteRequest_Network{Network: r.Network}
+	case r.Exec != nil:
+		req.TypedParameters = &agentpb.ExecuteRequest_Exec{Exec: r.Exec}
+	}
+	return req
+}
+
+// RecordSink stores recordings, such as in a file or an object store.
+// It must be safe for concurrent use.
+type RecordSink interface {
+	WriteRecording(rec *Recording) error
+}
+
+// RecordWriter is a RecordSink that writes recordings as JSON lines.
+type RecordWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File
+}
+
+// NewRecordWriter returns a RecordSink that writes recordings to w.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{w: w}
+}
+
+// NewRecordFile returns a RecordSink that appends recordings to the file
+// at path, creating it if needed. Recordings may contain sensitive data
+// that redaction missed; the file is only readable by its owner.
+func NewRecordFile(path string) (*RecordWriter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+	return &RecordWriter{w: f, file: f}, nil
+}
+
+// WriteRecording writes rec as one JSON line.
+func (w *RecordWriter) WriteRecording(rec *Recording) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(line)
+	return err
+}
+
+// Close closes the file of a writer returned by NewRecordFile.
+func (w *RecordWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// Recorder records Execute calls, with sensitive data redacted, for replay
+// testing.
+type Recorder struct {
+	sink     RecordSink
+	redactor *redact.Redactor
+	log      *slog.Logger
+}
+
+// NewRecorder returns a recorder writing to sink that redacts the values
+// of redactKeys from parameters and results, or of DefaultRedactKeys if
+// redactKeys is empty, and the PII and secrets redact.Default finds.
+func NewRecorder(sink RecordSink, redactKeys []string) *Recorder {
+	if len(redactKeys) == 0 {
+		return NewRedactingRecorder(sink, redact.Default())
+	}
+	cfg := redact.DefaultConfig()
+	cfg.Keys = redactKeys
+	redactor, err := redact.New(cfg)
+	if err != nil {
+		// The default patterns compile
+		panic(err)
+	}
+	return NewRedactingRecorder(sink, redactor)
+}
+
+// NewRedactingRecorder returns a recorder writing to sink that redacts
+// parameters and results with redactor.
+func NewRedactingRecorder(sink RecordSink, redactor *redact.Redactor) *Recorder {
+	return &Recorder{sink: sink, redactor: redactor, log: slog.Default()}
+}
+
+// SetRecorder records every Execute call with r. A nil recorder stops
+// recording. Recording errors are logged and never fail calls.
+func (s *Server) SetRecorder(r *Recorder) {
+	s.recorder = r
+}
+
+// Record records a call and its outcome.
+func (r *Recorder) Record(req *agentpb.ExecuteRequest, resp *agentpb.ExecuteResponse, err error) {
+	rec := r.recording(req, resp, err)
+	if werr := r.sink.WriteRecording(rec); werr != nil {
+		r.log.Warn("failed to record call", "tool", req.GetToolName(), "error", werr)
+	}
+}
+
+// recording builds the redacted recording of a call.
+func (r *Recorder) recording(req *agentpb.ExecuteRequest, resp *agentpb.ExecuteResponse, err error) *Recording {
+	rec := &Recording{
+		Timestamp: time.Now(),
+		Request: RecordedRequest{
+			ToolName:   req.GetToolName(),
+			Parameters: r.redact(req.GetParameters()),
+			Metadata:   req.GetMetadata(),
+			RequestID:  req.GetRequestId(),
+			File:       req.GetFile(),
+			Network:    req.GetNetwork(),
+			Exec:       req.GetExec(),
+			Session:    req.GetSessionToken() != "",
+		},
+	}
+	if resp != nil {
+		rec.Response = &RecordedResponse{
+			Status:   resp.GetStatus().String(),
+			Result:   r.redact(resp.GetResult()),
+			Error:    resp.GetError(),
+			Decision: resp.GetPolicyDecision().GetDecision(),
+			Policy:   resp.GetPolicyDecision().GetPolicyName(),
+		}
+	}
+	if err != nil {
+		st := status.Convert(err)
+		rec.Code = st.Code().String()
+		rec.Error = st.Message()
+	}
+	return rec
+}
+
+// redact returns a JSON document with sensitive data redacted. Data that
+// is not JSON is replaced whole. Numbers are kept as written.
+func (r *Recorder) redact(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if !json.Valid(data) {
+		return json.RawMessage(`"` + RedactedValue + `"`)
+	}
+	return r.redactor.JSON(data)
+}
+
+// ReadRecordings parses the JSON lines of a recording.
+func ReadRecordings(r io.Reader) ([]Recording, error) {
+	var recs []Recording
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rec Recording
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+// ReplayTarget executes replayed calls: a Server, with the policies and
+// tools under test.
+type ReplayTarget interface {
+	Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*agentpb.ExecuteResponse, error)
+}
+
+// ReplayOptions configure a replay.
+type ReplayOptions struct {
+	// RedactKeys are redacted from replayed results before they are
+	// compared, and should be the keys the recording was redacted with.
+	// Default: DefaultRedactKeys.
+	RedactKeys []string
+
+	// Redactor, when set, replaces RedactKeys for recordings made with a
+	// custom redactor (see NewRedactingRecorder)
+	Redactor *redact.Redactor
+
+	// IgnoreResults compares only the status, error code, and decision of
+	// calls, for targets whose tools differ from the recorded ones.
+	IgnoreResults bool
+}
+
+// ReplayReport is the outcome of a replay.
+type ReplayReport struct {
+	// Total is the number of calls replayed, and Matched the number that
+	// got the recorded outcome
+	Total   int
+	Matched int
+
+	// Mismatches are the calls whose outcome changed
+	Mismatches []ReplayMismatch
+}
+
+// ReplayMismatch is a replayed call whose outcome changed.
+type ReplayMismatch struct {
+	// Recording is the recorded call
+	Recording Recording
+
+	// Replayed is the outcome of the replay
+	Replayed Recording
+
+	// Differences describes what changed, e.g. "status: SUCCESS -> DENIED"
+	Differences []string
+}
+
+// Replay re-drives recorded calls against target, in order, and reports
+// the calls whose status, error code, policy decision, or result differs
+// from the recording. Recorded parameters are redacted, so calls whose
+// outcome depends on redacted values may differ for that reason alone.
+func Replay(ctx context.Context, target ReplayTarget, recs []Recording, opts ReplayOptions) (*ReplayReport, error) {
+	recorder := NewRecorder(nil, opts.RedactKeys)
+	if opts.Redactor != nil {
+		recorder = NewRedactingRecorder(nil, opts.Redactor)
+	}
+
+	report := &ReplayReport{}
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		req := rec.Request.ExecuteRequest()
+		resp, err := target.Execute(ctx, req)
+		replayed := recorder.recording(req, resp, err)
+		replayed.Request = rec.Request
+
+		report.Total++
+		if diffs := compareRecordings(&rec, replayed, opts.IgnoreResults); len(diffs) > 0 {
+			report.Mismatches = append(report.Mismatches, ReplayMismatch{Recording: rec, Replayed: *replayed, Differences: diffs})
+			continue
+		}
+		report.Matched++
+	}
+	return report, nil
+}
+
+// compareRecordings describes the differences between the outcomes of a
+// recorded and a replayed call.
+func compareRecordings(recorded, replayed *Recording, ignoreResults bool) []string {
+	var diffs []string
+	diff := func(field, old, new string) {
+		if old != new {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", field, orNone(old), orNone(new)))
+		}
+	}
+
+	diff("code", recorded.Code, replayed.Code)
+
+	var was, is RecordedResponse
+	if recorded.Response != nil {
+		was = *recorded.Response
+	}
+	if replayed.Response != nil {
+		is = *replayed.Response
+	}
+	diff("status", was.Status, is.Status)
+	diff("decision", was.Decision, is.Decision)
+	diff("policy", was.Policy, is.Policy)
+	if !ignoreResults && !equalJSON(was.Result, is.Result) {
+		diffs = append(diffs, "result changed")
+	}
+	return diffs
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// equalJSON reports whether two JSON documents have the same value.
+func equalJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// String summarizes the report, listing every mismatch.
+func (r *ReplayReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Replayed %d calls: %d matched, %d changed\n", r.Total, r.Matched, len(r.Mismatches))
+	for _, m := range r.Mismatches {
+		agentType := m.Recording.Request.Metadata.GetAgentType()
+		fmt.Fprintf(&b, "  %s %s %s: %s\n",
+			m.Recording.Timestamp.Format(time.RFC3339), orNone(agentType), m.Recording.Request.ToolName,
+			strings.Join(m.Differences, ", "))
+	}
+	return b.String()
+}
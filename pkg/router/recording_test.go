@@ -0,0 +1,87 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestRecordAndReplay tests that recorded calls are redacted, and that a
+// replay against a changed policy reports the calls whose outcome changed.
+func TestRecordAndReplay(t *testing.T) {
+	newServer := func(networkAction policy.Decision) *Server {
+		config := DefaultServerConfig()
+		config.PolicyConfig.Mode = policy.Enforcing
+		server := NewServer(config)
+		server.SetToolExecutor(&mockToolExecutor{result: map[string]interface{}{"ok": true, "session_token": "abc"}})
+		server.LoadPolicy("coding-assistant", policy.CompilePolicy(
+			"recording-policy",
+			[]string{"coding-assistant"},
+			policy.Deny,
+			[]policy.ToolPermission{
+				{Tool: "file.read", Action: policy.Allow},
+				{Tool: "network.fetch", Action: networkAction},
+			},
+			policy.Enforcing,
+			"",
+		))
+		return server
+	}
+
+	var out bytes.Buffer
+	server := newServer(policy.Allow)
+	server.SetRecorder(NewRecorder(NewRecordWriter(&out), nil))
+
+	for _, tool := range []string{"file.read", "network.fetch", "shell.exec"} {
+		server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   tool,
+			Parameters: []byte(`{"path": "/workspace/a", "size": 12345678901234567890, "headers": {"Authorization": "Bearer xyz"}}`),
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+			RequestId:  "req-" + tool,
+		})
+	}
+
+	if strings.Contains(out.String(), "xyz") || strings.Contains(out.String(), "abc") {
+		t.Errorf("expected credentials to be redacted, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), "12345678901234567890") {
+		t.Errorf("expected numbers to be recorded as written, got %s", out.String())
+	}
+
+	recs, err := ReadRecordings(&out)
+	if err != nil {
+		t.Fatalf("ReadRecordings: %v", err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 recordings, got %d", len(recs))
+	}
+	if recs[2].Response.Status != "DENIED" || recs[2].Code != "PermissionDenied" {
+		t.Errorf("expected shell.exec to be recorded as denied, got %+v %s", recs[2].Response, recs[2].Code)
+	}
+
+	// Against the same policy every call matches
+	report, err := Replay(context.Background(), newServer(policy.Allow), recs, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Total != 3 || report.Matched != 3 {
+		t.Errorf("expected 3 matching calls, got %s", report)
+	}
+
+	// Denying network.fetch changes its outcome
+	report, err = Replay(context.Background(), newServer(policy.Deny), recs, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Matched != 2 || len(report.Mismatches) != 1 {
+		t.Fatalf("expected 1 changed call, got %s", report)
+	}
+	m := report.Mismatches[0]
+	if m.Recording.Request.ToolName != "network.fetch" || !strings.Contains(strings.Join(m.Differences, ", "), "status: SUCCESS -> DENIED") {
+		t.Errorf("expected network.fetch to flip to DENIED, got %s", report)
+	}
+}
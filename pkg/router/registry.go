@@ -0,0 +1,282 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// ErrUnknownTool is returned for calls to a tool the router has no handler
+// for. Such calls are rejected before policy evaluation, so they are never
+// reported as denials.
+var ErrUnknownTool = errors.New("unknown tool")
+
+// ToolHandler executes calls to one registered tool.
+type ToolHandler interface {
+	Handle(ctx context.Context, call *ToolCall) (interface{}, error)
+}
+
+// ToolHandlerFunc adapts a function to the ToolHandler interface.
+type ToolHandlerFunc func(ctx context.Context, call *ToolCall) (interface{}, error)
+
+// Handle calls f.
+func (f ToolHandlerFunc) Handle(ctx context.Context, call *ToolCall) (interface{}, error) {
+	return f(ctx, call)
+}
+
+// ToolMiddleware wraps the handler of a tool, such as to log, bound, or
+// rewrite its calls.
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+// ParameterType is the JSON type of a tool parameter.
+type ParameterType string
+
+const (
+	ParameterString  ParameterType = "string"
+	ParameterNumber  ParameterType = "number"
+	ParameterInteger ParameterType = "integer"
+	ParameterBoolean ParameterType = "boolean"
+	ParameterObject  ParameterType = "object"
+	ParameterArray   ParameterType = "array"
+)
+
+// ParameterSchema describes the parameters of a tool. Calls are validated
+// against it after policy approval, with the parameters the tool would
+// execute with, and fail as INVALID without executing if they do not match.
+type ParameterSchema struct {
+	// Properties are the types of the tool's parameters
+	Properties map[string]ParameterType
+
+	// Required are the parameters every call must set
+	Required []string
+
+	// AdditionalProperties allows parameters not in Properties
+	AdditionalProperties bool
+}
+
+// Validate returns an error describing the first parameter of params that
+// does not match the schema, in name order.
+func (s *ParameterSchema) Validate(params map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("missing required parameter %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		want, ok := s.Properties[name]
+		if !ok {
+			if !s.AdditionalProperties {
+				return fmt.Errorf("unexpected parameter %q", name)
+			}
+			continue
+		}
+		if !matchesType(params[name], want) {
+			return fmt.Errorf("parameter %q must be of type %s", name, want)
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether a decoded parameter value is of type t.
+// Typed request parameters are Go values rather than JSON ones, so kinds
+// are matched rather than types.
+func matchesType(v interface{}, t ParameterType) bool {
+	rv := reflect.ValueOf(v)
+	switch t {
+	case ParameterString:
+		return rv.Kind() == reflect.String
+	case ParameterBoolean:
+		return rv.Kind() == reflect.Bool
+	case ParameterNumber:
+		return isNumber(rv)
+	case ParameterInteger:
+		if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+			f := rv.Float()
+			return f == math.Trunc(f) && !math.IsInf(f, 0)
+		}
+		return isNumber(rv)
+	case ParameterObject:
+		return rv.Kind() == reflect.Map
+	case ParameterArray:
+		return rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array
+	default:
+		return false
+	}
+}
+
+func isNumber(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// ToolRegistry holds the handlers of the tools a server executes, with
+// their parameter schemas and middleware.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+// registeredTool is a tool's handler, wrapped in its middleware, and its
+// schema.
+type registeredTool struct {
+	handler ToolHandler
+	schema  *ParameterSchema
+}
+
+// NewToolRegistry returns an empty registry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// RegisterTool registers the handler of a tool, replacing any previous
+// one. Calls are validated against schema, if not nil, and run through the
+// middleware, the first outermost. A nil handler unregisters the tool.
+func (r *ToolRegistry) RegisterTool(name string, handler ToolHandler, schema *ParameterSchema, middleware ...ToolMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handler == nil {
+		delete(r.tools, name)
+		return
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	r.tools[name] = registeredTool{handler: handler, schema: schema}
+}
+
+// Tools returns the names of the registered tools, sorted.
+func (r *ToolRegistry) Tools() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the registration of a tool.
+func (r *ToolRegistry) lookup(name string) (registeredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// empty reports whether no tool is registered.
+func (r *ToolRegistry) empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools) == 0
+}
+
+// RegisterTool registers the handler of a tool with the server's registry
+// (see ToolRegistry.RegisterTool). Registered tools are executed by their
+// handler; other tools by the executor set with SetToolExecutor, if any,
+// or else rejected as UNKNOWN_TOOL. Obligations of registered tools must
+// be fulfilled by obligation handlers: they are never delegated to the
+// executor.
+func (s *Server) RegisterTool(name string, handler ToolHandler, schema *ParameterSchema, middleware ...ToolMiddleware) {
+	s.tools.RegisterTool(name, handler, schema, middleware...)
+}
+
+// servesTool reports whether the server can execute a tool. A server with
+// neither registered tools nor an executor serves every tool, answering
+// allowed calls with a placeholder result.
+func (s *Server) servesTool(name string) bool {
+	if s.toolExecutor != nil || s.tools.empty() {
+		return true
+	}
+	_, ok := s.tools.lookup(name)
+	return ok
+}
+
+// validateParameters checks the parameters of a call against the schema
+// of its tool, if it is registered with one.
+func (s *Server) validateParameters(name string, params map[string]interface{}) error {
+	tool, ok := s.tools.lookup(name)
+	if !ok || tool.schema == nil {
+		return nil
+	}
+	return tool.schema.Validate(params)
+}
+
+// LoggingMiddleware logs every call of a tool to logger: at Debug when it
+// succeeds, and at Info with the error when it fails.
+func LoggingMiddleware(logger *slog.Logger) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return ToolHandlerFunc(func(ctx context.Context, call *ToolCall) (interface{}, error) {
+			start := time.Now()
+			result, err := next.Handle(ctx, call)
+
+			level, msg := slog.LevelDebug, "tool executed"
+			attrs := []slog.Attr{
+				slog.String(policy.LogKeyTool, call.ToolName),
+				slog.String(policy.LogKeyAgentType, call.Metadata.AgentType),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if call.RequestID != "" {
+				attrs = append(attrs, slog.String(policy.LogKeyRequestID, call.RequestID))
+			}
+			if err != nil {
+				level, msg = slog.LevelInfo, "tool failed"
+				attrs = append(attrs, slog.Any("error", err))
+			}
+			logger.LogAttrs(ctx, level, msg, attrs...)
+			return result, err
+		})
+	}
+}
+
+// TimeoutMiddleware bounds every call of a tool to timeout, in addition to
+// the timeout constraint of the policy's tool rule.
+func TimeoutMiddleware(timeout time.Duration) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return ToolHandlerFunc(func(ctx context.Context, call *ToolCall) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next.Handle(ctx, call)
+		})
+	}
+}
+
+// MutationMiddleware rewrites the parameters of every call of a tool with
+// mutate before the handler runs; an error from mutate fails the call.
+// The parameters passed to mutate must not be modified in place.
+func MutationMiddleware(mutate func(params map[string]interface{}) (map[string]interface{}, error)) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return ToolHandlerFunc(func(ctx context.Context, call *ToolCall) (interface{}, error) {
+			params, err := mutate(call.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("parameter mutation failed: %w", err)
+			}
+			mutated := *call
+			mutated.Parameters = params
+			return next.Handle(ctx, &mutated)
+		})
+	}
+}
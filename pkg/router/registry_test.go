@@ -0,0 +1,157 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestParameterSchema tests parameter validation against a tool schema.
+func TestParameterSchema(t *testing.T) {
+	schema := &ParameterSchema{
+		Properties: map[string]ParameterType{
+			"path":  ParameterString,
+			"limit": ParameterInteger,
+			"ratio": ParameterNumber,
+			"tags":  ParameterArray,
+			"opts":  ParameterObject,
+			"force": ParameterBoolean,
+		},
+		Required: []string{"path"},
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"path": "/workspace/a", "limit": float64(10), "ratio": 0.5, "tags": []interface{}{"x"}, "opts": map[string]interface{}{}, "force": true}, ""},
+		{"typed values", map[string]interface{}{"path": "/workspace/a", "limit": int64(10), "tags": []string{"x"}}, ""},
+		{"missing required", map[string]interface{}{"limit": float64(1)}, `missing required parameter "path"`},
+		{"wrong type", map[string]interface{}{"path": 1.0}, `parameter "path" must be of type string`},
+		{"fractional integer", map[string]interface{}{"path": "a", "limit": 1.5}, `parameter "limit" must be of type integer`},
+		{"unexpected", map[string]interface{}{"path": "a", "mode": "x"}, `unexpected parameter "mode"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	schema.AdditionalProperties = true
+	if err := schema.Validate(map[string]interface{}{"path": "a", "mode": "x"}); err != nil {
+		t.Errorf("expected additional parameters to be allowed, got %v", err)
+	}
+}
+
+// TestServerToolRegistry tests execution of registered tools: unknown
+// tools, schema validation, middleware, and the fallback executor.
+func TestServerToolRegistry(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy(
+		"registry-policy",
+		[]string{"coding-assistant"},
+		policy.Allow,
+		[]policy.ToolPermission{{Tool: "shell.exec", Action: policy.Deny}},
+		policy.Enforcing,
+		"",
+	))
+
+	var order []string
+	trace := func(name string) ToolMiddleware {
+		return func(next ToolHandler) ToolHandler {
+			return ToolHandlerFunc(func(ctx context.Context, call *ToolCall) (interface{}, error) {
+				order = append(order, name)
+				return next.Handle(ctx, call)
+			})
+		}
+	}
+	upper := MutationMiddleware(func(params map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"path": strings.ToUpper(params["path"].(string))}, nil
+	})
+
+	server.RegisterTool("file.read", ToolHandlerFunc(func(ctx context.Context, call *ToolCall) (interface{}, error) {
+		order = append(order, "handler")
+		return map[string]interface{}{"read": call.Parameters["path"]}, nil
+	}), &ParameterSchema{
+		Properties: map[string]ParameterType{"path": ParameterString},
+		Required:   []string{"path"},
+	}, trace("outer"), trace("inner"), upper)
+
+	execute := func(tool, params string) *agentpb.ExecuteResponse {
+		resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   tool,
+			Parameters: []byte(params),
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+		})
+		// Denials return a response with the error
+		if resp == nil {
+			t.Fatalf("%s: unexpected error: %v", tool, err)
+		}
+		return resp
+	}
+
+	resp := execute("file.read", `{"path": "/workspace/a"}`)
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("file.read: expected SUCCESS, got %v (%s)", resp.Status, resp.Error)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result["read"] != "/WORKSPACE/A" {
+		t.Errorf("expected the mutated path, got %v", result["read"])
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("expected middleware to run in order, got %s", got)
+	}
+
+	resp = execute("file.read", `{"path": 1}`)
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID {
+		t.Errorf("file.read with a bad path: expected INVALID, got %v", resp.Status)
+	}
+
+	// Unknown tools are not denials, even where the policy denies them
+	for _, tool := range []string{"code.run", "shell.exec"} {
+		resp = execute(tool, `{}`)
+		if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_UNKNOWN_TOOL {
+			t.Errorf("%s: expected UNKNOWN_TOOL, got %v (%s)", tool, resp.Status, resp.Error)
+		}
+	}
+
+	// Unregistered tools go to the executor, if one is set
+	server.SetToolExecutor(&mockToolExecutor{result: "executed"})
+	resp = execute("code.run", `{}`)
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS || string(resp.Result) != `"executed"` {
+		t.Errorf("code.run: expected the executor's result, got %v %s", resp.Status, resp.Result)
+	}
+	resp = execute("shell.exec", `{}`)
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED {
+		t.Errorf("shell.exec: expected DENIED, got %v", resp.Status)
+	}
+
+	if tools := server.tools.Tools(); len(tools) != 1 || tools[0] != "file.read" {
+		t.Errorf("expected [file.read] to be registered, got %v", tools)
+	}
+	server.RegisterTool("file.read", nil, nil)
+	if tools := server.tools.Tools(); len(tools) != 0 {
+		t.Errorf("expected file.read to be unregistered, got %v", tools)
+	}
+}
@@ -18,17 +18,28 @@ package router
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 
 	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
 	"github.com/golden-agent/golden-agent/pkg/policy"
+	policyerrors "github.com/golden-agent/golden-agent/pkg/policy/errors"
+	"github.com/golden-agent/golden-agent/pkg/redact"
 )
 
 // Server implements the AgentService gRPC server.
@@ -39,15 +50,67 @@ type Server struct {
 	// policy is the embedded policy integration layer.
 	policy *RouterPolicyIntegration
 
-	// toolExecutor executes tool calls after policy approval.
+	// tools holds the handlers of registered tools, and toolExecutor
+	// executes calls to other tools, after policy approval.
+	tools        *ToolRegistry
 	toolExecutor ToolExecutor
 
+	// obligationHandlers fulfil decision obligations by type.
+	obligationsMu      sync.RWMutex
+	obligationHandlers map[string]ObligationHandler
+
+	// peerIdentity attests the sandbox ID of Unix socket callers (optional).
+	peerIdentity PeerIdentityMapper
+
+	// identity authenticates the caller of each call (optional).
+	identity SessionAuthenticator
+
+	// sessions issues and verifies OpenSession tokens.
+	sessions       *sessionManager
+	sessionAuth    SessionAuthenticator
+	requireSession bool
+
+	// plans issues and verifies PreAuthorize tokens.
+	plans *planManager
+
+	// rateLimiter holds the per-client token buckets.
+	rateLimiter *rateLimiter
+
+	// inflight counts executions in flight, for maxConcurrent constraints.
+	inflight *inflightTracker
+
+	// breakers short-circuit calls to failing tools (nil if disabled).
+	breakers *circuitBreakers
+
+	// idempotency remembers executed calls by request ID (nil if disabled).
+	idempotency *idempotencyCache
+
+	// operations keeps the operations of ExecuteAsync calls.
+	operations *operationStore
+
+	// callbackHosts are the hosts operations may be POSTed to when done.
+	callbackHosts map[string]bool
+
+	// limits bound the parameters of Execute calls.
+	limits RequestLimits
+
+	// responseRedactor redacts tool results (optional).
+	responseRedactor *redact.Redactor
+
+	// recorder records Execute calls for replay testing (optional).
+	recorder *Recorder
+
+	// drain tracks in-flight calls for Drain
+	drainMu sync.Mutex
+	drain   drainState
+
 	// grpcServer is the underlying gRPC server.
 	grpcServer *grpc.Server
 }
 
 // ToolExecutor is the interface for executing tool calls.
 // Implementations handle the actual tool logic (file I/O, code execution, etc.).
+// Tools can instead be registered one by one with Server.RegisterTool.
 type ToolExecutor interface {
 	// Execute runs a tool and returns the result.
 	Execute(ctx context.Context, toolName string, parameters map[string]interface{}) (interface{}, error)
@@ -63,6 +126,79 @@ type ServerConfig struct {
 
 	// MaxSendMsgSize is the maximum send message size in bytes (default: 4MB).
 	MaxSendMsgSize int
+
+	// PeerIdentity, when set, identifies callers on a Unix socket (see
+	// ServeUnix) by their kernel-attested peer credentials: the sandbox ID
+	// it maps them to replaces the one in their request metadata, and
+	// callers it does not map are rejected with UNAUTHENTICATED. Callers
+	// on other transports are not affected.
+	PeerIdentity PeerIdentityMapper
+
+	// TLS, when set, serves TCP listeners over TLS; set ClientAuth and
+	// ClientCAs to require client certificates. Unix socket listeners are
+	// not affected.
+	TLS *tls.Config
+
+	// SessionKey signs OpenSession tokens. Replicas sharing it accept each
+	// other's tokens, so sessions survive failover; if unset, a random key
+	// is generated and tokens are only accepted by the issuing replica.
+	SessionKey []byte
+
+	// SessionTTL is the default session lifetime (default: 1h), and
+	// MaxSessionTTL caps the lifetime agents may request (default: 24h).
+	SessionTTL    time.Duration
+	MaxSessionTTL time.Duration
+
+	// PlanTTL caps the lifetime of PreAuthorize plan tokens, and is the
+	// lifetime of those whose request sets none (default: 5m). Plan tokens
+	// are signed with SessionKey.
+	PlanTTL time.Duration
+
+	// SessionAuthenticator authenticates agents opening sessions (optional).
+	SessionAuthenticator SessionAuthenticator
+
+	// IdentityAuthenticator authenticates the caller of every call that
+	// carries request metadata (calls with a session token were
+	// authenticated when the session was opened), after peer attestation,
+	// and returns the identity to evaluate it as. Errors without a gRPC
+	// status are returned as UNAUTHENTICATED. Default: the TokenReview
+	// authenticator if PolicyConfig.TokenReview is set, or else none.
+	IdentityAuthenticator SessionAuthenticator
+
+	// RequireSession rejects Execute calls that carry no session token, so
+	// that agent identity can only come from an authenticated session.
+	RequireSession bool
+
+	// RateLimit configures per-client rate limiting of Execute calls
+	// (default: no limit unless a policy sets one).
+	RateLimit RateLimitConfig
+
+	// RequestLimits bound the size and shape of Execute parameters.
+	RequestLimits RequestLimits
+
+	// ResponseRedactor, when set, redacts PII and secrets from tool
+	// results before they are returned to agents (and recorded).
+	ResponseRedactor *redact.Redactor
+
+	// CircuitBreaker configures the circuit breakers of tool executions
+	// (default: disabled). Calls to a tool whose executions keep failing
+	// are rejected with UNAVAILABLE until its cooldown elapses.
+	CircuitBreaker CircuitBreakerConfig
+
+	// IdempotencyWindow is how long the outcome of an executed call is
+	// remembered by its request ID (default: disabled). A call carrying the
+	// request ID of one executed within the window, such as a client's
+	// retry, gets its outcome instead of executing again.
+	IdempotencyWindow time.Duration
+
+	// OperationTTL is how long the operations of ExecuteAsync calls are
+	// kept once done (default: DefaultOperationTTL).
+	OperationTTL time.Duration
+
+	// CallbackHosts are the hosts ExecuteAsync calls may have their
+	// operations POSTed to when done (default: none, which disables
+	// callbacks).
+	CallbackHosts []string
 }
 
 // DefaultServerConfig returns a ServerConfig with sensible defaults.
@@ -71,6 +207,7 @@ func DefaultServerConfig() ServerConfig {
 		PolicyConfig:   DefaultPolicyConfig(),
 		MaxRecvMsgSize: 4 * 1024 * 1024, // 4MB
 		MaxSendMsgSize: 4 * 1024 * 1024, // 4MB
+		RequestLimits:  DefaultRequestLimits(),
 	}
 }
 
@@ -80,10 +217,45 @@ func NewServer(config ServerConfig) *Server {
 		grpc.MaxRecvMsgSize(config.MaxRecvMsgSize),
 		grpc.MaxSendMsgSize(config.MaxSendMsgSize),
 	}
+	if config.PeerIdentity != nil || config.TLS != nil {
+		var transport peerTransport
+		if config.TLS != nil {
+			transport.tls = credentials.NewTLS(config.TLS)
+		}
+		opts = append(opts, grpc.Creds(transport))
+	}
 
 	s := &Server{
-		policy:     NewRouterPolicyIntegration(config.PolicyConfig),
-		grpcServer: grpc.NewServer(opts...),
+		policy:             NewRouterPolicyIntegration(config.PolicyConfig),
+		grpcServer:         grpc.NewServer(opts...),
+		tools:              NewToolRegistry(),
+		obligationHandlers: make(map[string]ObligationHandler),
+		peerIdentity:       config.PeerIdentity,
+		identity:           config.IdentityAuthenticator,
+		sessions:           newSessionManager(config.SessionKey, config.SessionTTL, config.MaxSessionTTL),
+		sessionAuth:        config.SessionAuthenticator,
+		requireSession:     config.RequireSession,
+		rateLimiter:        newRateLimiter(config.RateLimit),
+		inflight:           newInflightTracker(),
+		idempotency:        newIdempotencyCache(config.IdempotencyWindow),
+		operations:         newOperationStore(config.OperationTTL),
+		callbackHosts:      make(map[string]bool, len(config.CallbackHosts)),
+		limits:             config.RequestLimits,
+		responseRedactor:   config.ResponseRedactor,
+		drain:              drainState{done: make(chan struct{}), idle: make(chan struct{})},
+	}
+
+	s.plans = newPlanManager(s.sessions.sign, config.PlanTTL)
+	for _, host := range config.CallbackHosts {
+		s.callbackHosts[strings.ToLower(host)] = true
+	}
+	s.breakers = newCircuitBreakers(config.CircuitBreaker, s.policy.log)
+	if s.breakers != nil {
+		s.policy.collectors = append(s.policy.collectors, &breakerCollector{breakers: s.breakers})
+	}
+
+	if s.identity == nil && s.policy.tokenReview != nil {
+		s.identity = tokenReviewIdentity{s.policy.tokenReview}
 	}
 
 	// Register the AgentService with the gRPC server
@@ -92,7 +264,8 @@ func NewServer(config ServerConfig) *Server {
 	return s
 }
 
-// SetToolExecutor sets the tool executor for handling approved requests.
+// SetToolExecutor sets the tool executor for handling approved requests to
+// tools not registered with RegisterTool.
 func (s *Server) SetToolExecutor(executor ToolExecutor) {
 	s.toolExecutor = executor
 }
@@ -102,12 +275,28 @@ func (s *Server) LoadPolicy(agentType string, compiled *policy.CompiledPolicy) {
 	s.policy.LoadPolicy(agentType, compiled)
 }
 
+// StartController starts the embedded Kubernetes controller (see
+// RouterPolicyIntegration.StartController), with the server's readiness
+// served on the manager's readiness probe.
+func (s *Server) StartController(ctx context.Context) error {
+	s.policy.readyz = func(*http.Request) error { return s.Ready() }
+	return s.policy.StartController(ctx)
+}
+
+// StartPolicySource loads the offline policy bundle and starts the Git
+// policy source that loads into the engine, if configured (see
+// RouterPolicyIntegration.StartPolicySource).
+func (s *Server) StartPolicySource(ctx context.Context) error {
+	return s.policy.StartPolicySource(ctx)
+}
+
 // Serve starts the gRPC server on the given listener.
 func (s *Server) Serve(lis net.Listener) error {
 	return s.grpcServer.Serve(lis)
 }
 
-// GracefulStop stops the server gracefully.
+// GracefulStop stops the server gracefully. It waits for WatchPolicy
+// streams indefinitely; use Drain to end them.
 func (s *Server) GracefulStop() {
 	s.grpcServer.GracefulStop()
 }
@@ -117,11 +306,34 @@ func (s *Server) GracefulStop() {
 //
 // The flow is:
 //  1. Decode the protobuf request
-//  2. Extract agent identity from metadata
-//  3. Evaluate the request against policy
-//  4. On Deny: return gRPC PERMISSION_DENIED
-//  5. On Allow: execute the tool and return the result
+//  2. Extract agent identity from the session or metadata (attested for
+//     Unix socket callers)
+//  3. Reject clients over their rate limit with RESOURCE_EXHAUSTED,
+//     parameters over the request limits as INVALID, and tools the server
+//     has no handler for as UNKNOWN_TOOL
+//  4. Evaluate the request against policy, unless a plan token
+//     preauthorizes it (see PreAuthorize)
+//  5. On Deny: return gRPC PERMISSION_DENIED
+//  6. On Allow: validate the parameters against the tool's schema, and
+//     fulfil the decision's obligations, or fail if any cannot be
+//  7. Execute the tool and return the result
+//
+// Calls are recorded, once answered, if a recorder is set.
 func (s *Server) Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*agentpb.ExecuteResponse, error) {
+	resp, err := s.execute(ctx, req)
+	if s.recorder != nil {
+		s.recorder.Record(req, resp, err)
+	}
+	return resp, err
+}
+
+// execute implements Execute.
+func (s *Server) execute(ctx context.Context, req *agentpb.ExecuteRequest) (*agentpb.ExecuteResponse, error) {
+	if !s.beginCall() {
+		return nil, drainingError()
+	}
+	defer s.endCall()
+
 	startTime := time.Now()
 
 	// Validate request
@@ -133,26 +345,44 @@ func (s *Server) Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*age
 		}, nil
 	}
 
-	if req.GetMetadata() == nil {
+	if req.GetMetadata() == nil && req.GetSessionToken() == "" {
 		return &agentpb.ExecuteResponse{
 			Status:    agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID,
-			Error:     "metadata is required",
+			Error:     "metadata or session_token is required",
 			RequestId: req.GetRequestId(),
 		}, nil
 	}
 
-	// Convert protobuf metadata to internal format
-	metadata := RequestMetadata{
-		AgentType: req.GetMetadata().GetAgentType(),
-		SandboxID: req.GetMetadata().GetSandboxId(),
-		TenantID:  req.GetMetadata().GetTenantId(),
-		SessionID: req.GetMetadata().GetSessionId(),
-		MTSLabel:  req.GetMetadata().GetMtsLabel(),
+	// Resolve the caller's identity: the session's, or the request
+	// metadata with the attested sandbox ID of Unix socket callers
+	metadata, session, err := s.requestIdentity(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		session.calls.Add(1)
+		ctx = context.WithValue(ctx, sessionContextKey{}, session)
+	}
+
+	// Rate limit the client before spending any work on its request
+	if err := s.checkRateLimit(metadata); err != nil {
+		return nil, err
+	}
+
+	// Bound the parameters before decoding them
+	if err := s.limits.check(req.GetParameters()); err != nil {
+		s.auditInvalid(metadata, req.GetToolName(), err.Error(), req.GetRequestId())
+		return &agentpb.ExecuteResponse{
+			Status:    agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID,
+			Error:     err.Error(),
+			RequestId: req.GetRequestId(),
+		}, nil
 	}
 
 	// Decode parameters from JSON bytes
 	params, err := req.GetParametersMap()
 	if err != nil {
+		s.auditInvalid(metadata, req.GetToolName(), err.Error(), req.GetRequestId())
 		return &agentpb.ExecuteResponse{
 			Status:    agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID,
 			Error:     fmt.Sprintf("invalid parameters JSON: %v", err),
@@ -160,37 +390,72 @@ func (s *Server) Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*age
 		}, nil
 	}
 
+	// Calls to tools the server cannot execute fail before evaluation, so
+	// that agents can tell them from denials
+	if !s.servesTool(req.GetToolName()) {
+		s.auditInvalid(metadata, req.GetToolName(), ErrUnknownTool.Error(), req.GetRequestId())
+		return &agentpb.ExecuteResponse{
+			Status:    agentpb.ExecutionStatus_EXECUTION_STATUS_UNKNOWN_TOOL,
+			Error:     fmt.Sprintf("%v: %q", ErrUnknownTool, req.GetToolName()),
+			RequestId: req.GetRequestId(),
+		}, nil
+	}
+
 	// ============================================================
 	// POLICY ENFORCEMENT HOOK
 	// This is where Mandatory Access Control is enforced.
 	// Every tool request passes through this check.
 	// ============================================================
 
-	decision, err := s.policy.Evaluate(ctx, metadata, req.GetToolName(), params)
+	// The engine audits the call under the agent's request ID, if any
+	if req.GetRequestId() != "" {
+		ctx = policy.ContextWithRequestID(ctx, req.GetRequestId())
+	}
+
+	toolReq := toToolRequest(req, params)
+	evaluation, err := s.preauthorized(ctx, metadata, req, toolReq)
+	if err != nil {
+		return nil, err
+	}
+	preauthorized := evaluation != nil
+	if !preauthorized {
+		evaluation, err = s.policy.EvaluateWithResult(ctx, metadata, req.GetToolName(), toolReq)
+	}
 	evalTime := time.Since(startTime)
 
 	if err != nil {
 		// Policy evaluation error - fail closed (deny)
-		return nil, status.Errorf(codes.Internal, "policy evaluation failed: %v", err)
+		s.logCall(ctx, slog.LevelWarn, "policy evaluation failed", metadata, req, err)
+		return nil, evaluationError(err)
 	}
 
 	// Build policy decision for response
 	policyDecision := &agentpb.PolicyDecision{
-		Decision:         decision.String(),
+		Decision:         evaluation.Decision.String(),
+		PolicyName:       evaluation.Policy,
 		EvaluationTimeNs: evalTime.Nanoseconds(),
+		CacheHit:         evaluation.Cached,
+		Message:          evaluation.Message,
+		MessageLocale:    evaluation.MessageLocale,
+		Mutations:        evaluation.Mutations,
+		Obligations:      obligationsToProto(evaluation.Obligations),
+	}
+	if m := evaluation.OPAMetrics; m != nil {
+		policyDecision.OpaEvalTimeNs = m.EvalTime.Nanoseconds()
+		policyDecision.OpaOperations = m.Operations
 	}
+	policyDecision.Trace = evaluation.Trace
+	policyDecision.Preauthorized = preauthorized
 
 	// Check the policy decision
-	if decision == policy.Deny {
+	if evaluation.Decision == policy.Deny {
 		// Policy denied the request - return PERMISSION_DENIED
 		return &agentpb.ExecuteResponse{
 			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED,
 			Error:          fmt.Sprintf("tool %q denied by policy for agent type %q", req.GetToolName(), metadata.AgentType),
 			RequestId:      req.GetRequestId(),
 			PolicyDecision: policyDecision,
-		}, status.Errorf(codes.PermissionDenied,
-			"tool %q denied by policy for agent type %q",
-			req.GetToolName(), metadata.AgentType)
+		}, denyError(req.GetToolName(), metadata.AgentType, evaluation)
 	}
 
 	// ============================================================
@@ -198,44 +463,557 @@ func (s *Server) Execute(ctx context.Context, req *agentpb.ExecuteRequest) (*age
 	// At this point, the request has been authorized by policy.
 	// ============================================================
 
-	if s.toolExecutor == nil {
-		// No executor configured - return success with placeholder
+	// Execute the tool with the parameters the policy approved, which
+	// include any rewrites made by the tool rule's mutators
+	execParams := toolReq.ParameterMap()
+	if evaluation.Parameters != nil {
+		execParams = evaluation.Parameters
+	}
+
+	if err := s.validateParameters(req.GetToolName(), execParams); err != nil {
 		return &agentpb.ExecuteResponse{
-			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS,
-			Result:         []byte(`{"message":"policy allowed, tool executor not configured"}`),
+			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID,
+			Error:          fmt.Sprintf("invalid parameters: %v", err),
 			RequestId:      req.GetRequestId(),
 			PolicyDecision: policyDecision,
 		}, nil
 	}
 
-	// Execute the tool
-	result, err := s.toolExecutor.Execute(ctx, req.GetToolName(), params)
+	// A call retried with the request ID of a call already executed gets
+	// its outcome instead of executing again
+	once, replay, err := s.idempotency.begin(ctx, metadata, req, toolReq)
+	if err != nil {
+		return nil, err
+	}
+	if replay != nil {
+		resp := s.executionResponse(req, policyDecision, *replay)
+		resp.Replayed = true
+		return resp, nil
+	}
+
+	// Calls to failing tools, and past the tool's concurrency bound, are
+	// rejected before their obligations are fulfilled
+	ticket, retry, ok := s.breakers.allow(req.GetToolName())
+	if !ok {
+		once.abandon()
+		s.logCall(ctx, slog.LevelWarn, "tool circuit open", metadata, req, ErrToolCircuitOpen)
+		return nil, circuitOpenError(req.GetToolName(), retry)
+	}
+	release, err := s.acquireExecution(metadata, req.GetToolName(), evaluation.MaxConcurrent)
+	if err != nil {
+		ticket.release()
+		once.abandon()
+		return nil, err
+	}
+	admitted := &admittedCall{
+		metadata:   metadata,
+		req:        req,
+		params:     execParams,
+		evaluation: evaluation,
+		decision:   policyDecision,
+		once:       once,
+		ticket:     ticket,
+		release:    release,
+	}
+
+	// Calls submitted with ExecuteAsync execute in the background from
+	// here, keeping their admission until they are done
+	if submission := submissionFromContext(ctx); submission != nil {
+		if err := s.startOperation(ctx, submission, admitted); err != nil {
+			admitted.close()
+			return nil, status.Errorf(codes.Internal, "failed to start operation: %v", err)
+		}
+		return &agentpb.ExecuteResponse{RequestId: req.GetRequestId(), PolicyDecision: policyDecision}, nil
+	}
+	defer admitted.close()
+	return s.executeAdmitted(ctx, admitted), nil
+}
+
+// admittedCall is an allowed call admitted for execution past its
+// idempotency check, circuit breaker, and concurrency bound.
+type admittedCall struct {
+	metadata   RequestMetadata
+	req        *agentpb.ExecuteRequest
+	params     map[string]interface{}
+	evaluation *policy.EvaluationResult
+	decision   *agentpb.PolicyDecision
+
+	once    *idempotentCall
+	ticket  *breakerTicket
+	release func()
+}
+
+// close returns the call's admission once it is done.
+func (a *admittedCall) close() {
+	a.release()
+	a.ticket.release()
+	a.once.abandon()
+}
+
+// executeAdmitted fulfils the obligations of an admitted call and
+// executes it.
+func (s *Server) executeAdmitted(ctx context.Context, a *admittedCall) *agentpb.ExecuteResponse {
+	req, policyDecision := a.req, a.decision
+
+	// Obligations are fulfilled before execution; one that cannot be
+	// fulfilled fails the call (fail closed)
+	call := &ToolCall{
+		ToolName:   req.GetToolName(),
+		Parameters: a.params,
+		Metadata:   a.metadata,
+		RequestID:  req.GetRequestId(),
+	}
+	delegated, err := s.fulfillObligations(ctx, call, a.evaluation.Obligations)
 	if err != nil {
+		s.logCall(ctx, slog.LevelWarn, "obligation not fulfilled", a.metadata, req, err)
 		return &agentpb.ExecuteResponse{
 			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR,
 			Error:          err.Error(),
 			RequestId:      req.GetRequestId(),
 			PolicyDecision: policyDecision,
-		}, nil
+		}
 	}
 
-	// Encode result as JSON
-	resultBytes, err := json.Marshal(result)
-	if err != nil {
+	if s.toolExecutor == nil && s.tools.empty() {
+		// No executor configured - return success with placeholder
 		return &agentpb.ExecuteResponse{
-			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR,
-			Error:          fmt.Sprintf("failed to encode result: %v", err),
+			Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS,
+			Result:         []byte(`{"message":"policy allowed, tool executor not configured"}`),
 			RequestId:      req.GetRequestId(),
 			PolicyDecision: policyDecision,
-		}, nil
+		}
+	}
+
+	outcome := s.executeWithRetry(ctx, call, delegated, a.evaluation.Timeout, a.evaluation.Retry)
+	if ctx.Err() == nil {
+		// Calls the caller gave up on say nothing of the tool
+		a.ticket.record(outcome.err, outcome.elapsed)
+	}
+	a.once.complete(outcome)
+	if outcome.err != nil {
+		s.logCall(ctx, slog.LevelInfo, "tool execution failed", a.metadata, req, outcome.err)
+	}
+	resp := s.executionResponse(req, policyDecision, outcome)
+	s.auditExecution(a.metadata, req, resp, outcome)
+	return resp
+}
+
+// auditExecution audits the outcome of an executed call, following the
+// call's decision event with the same request ID.
+func (s *Server) auditExecution(md RequestMetadata, req *agentpb.ExecuteRequest, resp *agentpb.ExecuteResponse, outcome executionOutcome) {
+	record := policy.ExecutionRecord{
+		Status:      policy.ExecutionSucceeded,
+		Duration:    outcome.elapsed,
+		ResultBytes: len(resp.GetResult()),
+		Attempts:    outcome.attempts,
+		Error:       resp.GetError(),
+	}
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		record.Status = policy.ExecutionFailed
+	}
+	s.policy.Engine().AuditExecution(extractAgentIdentity(md), req.GetToolName(), req.GetRequestId(), record)
+}
+
+// executionResponse returns the response to an executed call.
+func (s *Server) executionResponse(req *agentpb.ExecuteRequest, policyDecision *agentpb.PolicyDecision, outcome executionOutcome) *agentpb.ExecuteResponse {
+	resp := &agentpb.ExecuteResponse{
+		Status:          agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR,
+		RequestId:       req.GetRequestId(),
+		PolicyDecision:  policyDecision,
+		ExecutionTimeNs: outcome.elapsed.Nanoseconds(),
+		Attempts:        int32(outcome.attempts),
+	}
+	if outcome.err != nil {
+		resp.Error = outcome.err.Error()
+		return resp
+	}
+
+	// Encode result as JSON
+	resultBytes, err := json.Marshal(outcome.result)
+	if err != nil {
+		resp.Error = fmt.Sprintf("failed to encode result: %v", err)
+		return resp
+	}
+	if s.responseRedactor != nil {
+		resultBytes = s.responseRedactor.JSON(resultBytes)
+	}
+	resp.Status = agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS
+	resp.Result = resultBytes
+	return resp
+}
+
+// executeTool runs an allowed call on its registered handler, or on the
+// tool executor with the obligations delegated to it, and returns how
+// long it ran. A non-zero
+// timeout, from the tool rule's constraints, is the deadline of the
+// executor's context; a call still running at the deadline fails with a
+// timeout error, and its result, if the executor returns one anyway, is
+// discarded.
+func (s *Server) executeTool(ctx context.Context, call *ToolCall, delegated []policy.Obligation, timeout time.Duration) (interface{}, time.Duration, error) {
+	execCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var result interface{}
+	var err error
+	if tool, ok := s.tools.lookup(call.ToolName); ok {
+		result, err = tool.handler.Handle(execCtx, call)
+	} else if len(delegated) > 0 {
+		result, err = s.toolExecutor.(ObligationExecutor).ExecuteWithObligations(execCtx, call.ToolName, call.Parameters, delegated)
+	} else {
+		result, err = s.toolExecutor.Execute(execCtx, call.ToolName, call.Parameters)
+	}
+	elapsed := time.Since(start)
+
+	// The tool's deadline passed, not the caller's
+	if timeout > 0 && errors.Is(execCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, elapsed, fmt.Errorf("tool %q timed out after %s", call.ToolName, timeout)
+	}
+	return result, elapsed, err
+}
+
+// WatchPolicy implements the AgentService.WatchPolicy RPC.
+// It streams the agent's effective policy: an INITIAL snapshot first, then
+// an event whenever the resolved policy's hash or the enforcement mode
+// changes. The stream ends when the client cancels.
+func (s *Server) WatchPolicy(req *agentpb.WatchPolicyRequest, stream agentpb.AgentService_WatchPolicyServer) error {
+	if req.GetMetadata().GetAgentType() == "" {
+		return status.Error(codes.InvalidArgument, "metadata.agent_type is required")
+	}
+
+	metadata, err := s.identify(stream.Context(), metadataFromProto(req.GetMetadata()))
+	if err != nil {
+		return err
+	}
+	agent, err := s.policy.agentContext(stream.Context(), metadata)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	engine := s.policy.Engine()
+
+	// Subscribe before taking the snapshot so no change is missed
+	changes, cancel := engine.Subscribe()
+	defer cancel()
+
+	last := policySnapshot(engine, agent)
+	last.ChangeType = agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_INITIAL
+	if err := stream.Send(last); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.drain.done:
+			return drainingError()
+		case <-changes:
+		}
+
+		current := policySnapshot(engine, agent)
+		if current.PolicyHash == last.PolicyHash && current.Mode == last.Mode {
+			continue
+		}
+
+		current.ChangeType = agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_UPDATED
+		if current.PolicyHash == "" {
+			current.ChangeType = agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_REMOVED
+		}
+		if err := stream.Send(current); err != nil {
+			return err
+		}
+		last = current
+	}
+}
+
+// ListAllowedTools implements the AgentService.ListAllowedTools RPC.
+// It reports the tools the agent's effective policy explicitly allows, with
+// their constraint summaries and, with a ToolCatalog, what the catalog
+// declares of them. If no policy applies, the list is empty and the
+// default action is deny.
+func (s *Server) ListAllowedTools(ctx context.Context, req *agentpb.ListAllowedToolsRequest) (*agentpb.ListAllowedToolsResponse, error) {
+	if req.GetMetadata().GetAgentType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metadata.agent_type is required")
+	}
+
+	metadata, err := s.identify(ctx, metadataFromProto(req.GetMetadata()))
+	if err != nil {
+		return nil, err
+	}
+	agent, err := s.policy.agentContext(ctx, metadata)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	engine := s.policy.Engine()
+
+	resp := &agentpb.ListAllowedToolsResponse{
+		DefaultAction: policy.Deny.String(),
+		Mode:          engine.Mode().String(),
+	}
+
+	compiled, tools, ok := engine.AllowedTools(agent)
+	if !ok {
+		return resp, nil
+	}
+
+	resp.PolicyName = compiled.Name
+	resp.PolicyHash = compiled.Fingerprint()
+	resp.DefaultAction = compiled.DefaultAction.String()
+	for _, perm := range tools {
+		tool := &agentpb.AllowedTool{
+			Name:        perm.Tool,
+			Constraints: constraintSummary(perm.Constraints),
+		}
+		describeTool(tool, s.policy.config.ToolCatalog)
+		tool.RiskLevel = string(engine.RiskLevel(compiled, perm.Tool))
+		resp.Tools = append(resp.Tools, tool)
+	}
+
+	return resp, nil
+}
+
+// describeTool sets what catalog declares of a tool, if anything, but its
+// risk level, which policies may raise.
+func describeTool(tool *agentpb.AllowedTool, catalog *policy.ToolCatalog) {
+	if catalog == nil {
+		return
+	}
+	entry, ok := catalog.Lookup(tool.Name)
+	if !ok {
+		return
+	}
+	tool.Description = entry.Description
+	tool.Category = entry.Category
+	if entry.Parameters != nil {
+		if schema, err := json.Marshal(entry.Parameters); err == nil {
+			tool.ParametersSchema = string(schema)
+		}
+	}
+}
+
+// constraintSummary converts policy constraints to their protobuf summary.
+// Returns nil if the tool has no parameter constraints.
+func constraintSummary(c *policy.ToolConstraints) *agentpb.ToolConstraintSummary {
+	if c == nil {
+		return nil
+	}
+
+	summary := &agentpb.ToolConstraintSummary{
+		PathPatterns:   c.PathPatterns,
+		AllowedDomains: c.AllowedDomains,
+		DeniedDomains:  c.DeniedDomains,
+		MaxSizeBytes:   c.MaxSizeBytes,
+		TimeoutMs:      c.Timeout.Milliseconds(),
+
+		AllowedContentHashes: c.AllowedContentHashes,
+	}
+	for _, port := range c.AllowedPorts {
+		summary.AllowedPorts = append(summary.AllowedPorts, int32(port))
+	}
+
+	if len(summary.PathPatterns) == 0 && len(summary.AllowedDomains) == 0 &&
+		len(summary.DeniedDomains) == 0 && len(summary.AllowedPorts) == 0 &&
+		summary.MaxSizeBytes == 0 && summary.TimeoutMs == 0 &&
+		len(summary.AllowedContentHashes) == 0 {
+		return nil
+	}
+	return summary
+}
+
+// policySnapshot describes the policy currently in effect for an agent.
+func policySnapshot(engine *policy.Engine, agent policy.AgentContext) *agentpb.PolicyChangeEvent {
+	event := &agentpb.PolicyChangeEvent{
+		AgentType:         agent.AgentType,
+		Mode:              engine.Mode().String(),
+		TimestampUnixNano: time.Now().UnixNano(),
+	}
+
+	if compiled, ok := engine.ResolvePolicy(agent); ok {
+		event.PolicyName = compiled.Name
+		event.PolicyHash = compiled.Fingerprint()
+		event.DefaultAction = compiled.DefaultAction.String()
+	}
+
+	return event
+}
+
+// metadataFromProto converts protobuf request metadata to the internal format.
+func metadataFromProto(md *agentpb.RequestMetadata) RequestMetadata {
+	parent, lineage := lineageFromProto(md)
+	return RequestMetadata{
+		AgentType: md.GetAgentType(),
+		SandboxID: md.GetSandboxId(),
+		TenantID:  md.GetTenantId(),
+		SessionID: md.GetSessionId(),
+		MTSLabel:  md.GetMtsLabel(),
+		Labels:    md.GetLabels(),
+		Locale:    md.GetLocale(),
+		Trace:     md.GetTrace(),
+
+		ParentSessionID: parent,
+		Lineage:         lineage,
+	}
+}
+
+// lineageFromProto returns the parent session and lineage of request
+// metadata, with the parent last in the lineage: agents may set either,
+// or both.
+func lineageFromProto(md *agentpb.RequestMetadata) (string, []string) {
+	lineage := md.GetLineage()
+	parent := md.GetParentSessionId()
+	if parent != "" && (len(lineage) == 0 || lineage[len(lineage)-1] != parent) {
+		lineage = append(append([]string(nil), lineage...), parent)
+	}
+	if len(lineage) == 0 {
+		return "", nil
+	}
+	return lineage[len(lineage)-1], lineage
+}
+
+// obligationsToProto converts decision obligations to their protobuf form.
+func obligationsToProto(obligations []policy.Obligation) []*agentpb.Obligation {
+	if len(obligations) == 0 {
+		return nil
+	}
+	out := make([]*agentpb.Obligation, len(obligations))
+	for i, o := range obligations {
+		out[i] = &agentpb.Obligation{Type: o.Type, Params: o.Params}
+	}
+	return out
+}
+
+// Causes of denials, reported in the "cause" ErrorInfo metadata of denied
+// calls for the typed errors of policy evaluation (see policy/errors).
+const (
+	causeNoPolicy            = "NO_POLICY"
+	causeConstraintViolation = "CONSTRAINT_VIOLATION"
+	causeMTSViolation        = "MTS_VIOLATION"
+	causeSandboxMismatch     = "SANDBOX_MISMATCH"
+	causeOPAEvaluation       = "OPA_EVALUATION"
+)
+
+// denyError builds the status for a denied tool call: PERMISSION_DENIED,
+// or INTERNAL if the policy failed closed because OPA could not evaluate
+// it. The status message stays generic; the deciding policy and the typed
+// cause of the denial travel in an ErrorInfo detail and the rule's
+// user-facing message, if any, in a LocalizedMessage detail, so clients
+// can show it without parsing.
+func denyError(toolName, agentType string, result *policy.EvaluationResult) error {
+	code := codes.PermissionDenied
+	metadata := map[string]string{
+		"tool":       toolName,
+		"agent_type": agentType,
+		"policy":     result.Policy,
+	}
+
+	var violation *policyerrors.ErrConstraintViolation
+	switch {
+	case errors.Is(result.Err, policyerrors.ErrNoPolicy):
+		metadata["cause"] = causeNoPolicy
+	case errors.As(result.Err, &violation):
+		metadata["cause"] = causeConstraintViolation
+		metadata["constraint"] = violation.Constraint
+		if violation.Value != nil {
+			metadata["value"] = fmt.Sprint(violation.Value)
+		}
+	case errors.Is(result.Err, policyerrors.ErrMTSViolation):
+		metadata["cause"] = causeMTSViolation
+	case errors.Is(result.Err, policyerrors.ErrSandboxMismatch):
+		metadata["cause"] = causeSandboxMismatch
+	case errors.Is(result.Err, policyerrors.ErrOPAEvaluation):
+		code = codes.Internal
+		metadata["cause"] = causeOPAEvaluation
+	}
+
+	st := status.Newf(code,
+		"tool %q denied by policy for agent type %q", toolName, agentType)
+
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   "POLICY_DENIED",
+		Domain:   agentsv1alpha1.GroupVersion.Group,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	if result.Message != "" {
+		withMessage, err := withDetails.WithDetails(&errdetails.LocalizedMessage{
+			Locale:  result.MessageLocale,
+			Message: result.Message,
+		})
+		if err == nil {
+			withDetails = withMessage
+		}
+	}
+	return withDetails.Err()
+}
+
+// evaluationError builds the status of a failed policy evaluation: the
+// call is not executed either way, but an evaluation cut short by the
+// caller's cancellation or a deadline is reported as such, not as an
+// internal error.
+func evaluationError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "policy evaluation failed: %v", err)
+	case errors.Is(err, policy.ErrEvaluationCancelled):
+		return status.Errorf(codes.Canceled, "policy evaluation failed: %v", err)
+	case errors.Is(err, ErrTraceNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "policy evaluation failed: %v", err)
+	}
+}
+
+// logCall logs an Execute call that failed after its caller was
+// identified.
+func (s *Server) logCall(ctx context.Context, level slog.Level, msg string, md RequestMetadata, req *agentpb.ExecuteRequest, err error) {
+	s.policy.log.LogAttrs(ctx, level, msg,
+		slog.String(policy.LogKeyRequestID, req.GetRequestId()),
+		slog.String(policy.LogKeyAgentType, md.AgentType),
+		slog.String(policy.LogKeyTool, req.GetToolName()),
+		slog.Any("error", err),
+	)
+}
+
+// toToolRequest combines the JSON-decoded parameters with the typed
+// parameter oneof so constraint checks can operate on typed values.
+func toToolRequest(req *agentpb.ExecuteRequest, params map[string]interface{}) *policy.ToolRequest {
+	toolReq := &policy.ToolRequest{Parameters: params}
+
+	if f := req.GetFile(); f != nil {
+		toolReq.File = &policy.FileParams{
+			Path: f.GetPath(),
+			Size: f.GetSize(),
+		}
+	}
+
+	if n := req.GetNetwork(); n != nil {
+		toolReq.Network = &policy.NetworkParams{
+			Domain: n.GetDomain(),
+			Port:   int(n.GetPort()),
+			URL:    n.GetUrl(),
+			Method: n.GetMethod(),
+		}
+	}
+
+	if x := req.GetExec(); x != nil {
+		toolReq.Exec = &policy.ExecParams{
+			Command:    x.GetCommand(),
+			Args:       x.GetArgs(),
+			WorkingDir: x.GetWorkingDir(),
+			Timeout:    time.Duration(x.GetTimeoutMs()) * time.Millisecond,
+
+			ContentSHA256: x.GetContentSha256(),
+		}
 	}
 
-	return &agentpb.ExecuteResponse{
-		Status:         agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS,
-		Result:         resultBytes,
-		RequestId:      req.GetRequestId(),
-		PolicyDecision: policyDecision,
-	}, nil
+	return toolReq
 }
 
 // PolicyStats returns statistics about policy enforcement.
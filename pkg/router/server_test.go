@@ -3,8 +3,15 @@ package router
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -25,9 +32,13 @@ import (
 type mockToolExecutor struct {
 	result interface{}
 	err    error
+
+	// params records the parameters of the last call
+	params map[string]interface{}
 }
 
 func (m *mockToolExecutor) Execute(ctx context.Context, toolName string, params map[string]interface{}) (interface{}, error) {
+	m.params = params
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -310,6 +321,7 @@ func TestExecutionStatusString(t *testing.T) {
 		{agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED, "DENIED"},
 		{agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR, "ERROR"},
 		{agentpb.ExecutionStatus_EXECUTION_STATUS_INVALID, "INVALID"},
+		{agentpb.ExecutionStatus_EXECUTION_STATUS_UNKNOWN_TOOL, "UNKNOWN_TOOL"},
 		{agentpb.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED, "UNSPECIFIED"},
 	}
 
@@ -376,3 +388,721 @@ func TestServerWithExecutor(t *testing.T) {
 		t.Errorf("expected 'test data', got %v", result["data"])
 	}
 }
+
+// TestServerTypedParameters tests constraint checks on typed parameter messages.
+func TestServerTypedParameters(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	writePolicy := policy.CompilePolicy(
+		"writer-policy",
+		[]string{"writer-agent"},
+		policy.Deny,
+		[]policy.ToolPermission{
+			{
+				Tool:   "file.write",
+				Action: policy.Allow,
+				Constraints: &policy.ToolConstraints{
+					PathPatterns: []string{"/workspace/**"},
+					MaxSizeBytes: 1024,
+				},
+			},
+		},
+		policy.Enforcing,
+		"",
+	)
+	server.LoadPolicy("writer-agent", writePolicy)
+
+	tests := []struct {
+		name     string
+		file     *agentpb.FileParams
+		expected agentpb.ExecutionStatus
+	}{
+		{"within limits", &agentpb.FileParams{Path: "/workspace/out.txt", Size: 512}, agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS},
+		{"too large", &agentpb.FileParams{Path: "/workspace/out.txt", Size: 4096}, agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED},
+		{"outside workspace", &agentpb.FileParams{Path: "/etc/passwd", Size: 10}, agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.policy.Engine().Cache().InvalidateAll()
+
+			resp, _ := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+				ToolName:        "file.write",
+				Metadata:        &agentpb.RequestMetadata{AgentType: "writer-agent"},
+				TypedParameters: &agentpb.ExecuteRequest_File{File: tt.file},
+			})
+
+			if resp.Status != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, resp.Status)
+			}
+		})
+	}
+}
+
+// TestServerJSONNumericParameters tests size constraints on JSON-encoded parameters.
+// JSON numbers decode as float64, which must still be compared against MaxSizeBytes.
+func TestServerJSONNumericParameters(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	writePolicy := policy.CompilePolicy(
+		"writer-policy",
+		[]string{"writer-agent"},
+		policy.Deny,
+		[]policy.ToolPermission{
+			{
+				Tool:        "file.write",
+				Action:      policy.Allow,
+				Constraints: &policy.ToolConstraints{MaxSizeBytes: 1024},
+			},
+		},
+		policy.Enforcing,
+		"",
+	)
+	server.LoadPolicy("writer-agent", writePolicy)
+
+	tests := []struct {
+		name     string
+		params   string
+		expected agentpb.ExecutionStatus
+	}{
+		{"small number", `{"path": "/workspace/a.txt", "size": 100}`, agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS},
+		{"large number", `{"path": "/workspace/a.txt", "size": 1048576}`, agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED},
+		{"large string", `{"path": "/workspace/a.txt", "size": "1048576"}`, agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.policy.Engine().Cache().InvalidateAll()
+
+			resp, _ := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+				ToolName:   "file.write",
+				Parameters: []byte(tt.params),
+				Metadata:   &agentpb.RequestMetadata{AgentType: "writer-agent"},
+			})
+
+			if resp.Status != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, resp.Status)
+			}
+		})
+	}
+}
+
+// fakeWatchStream implements agentpb.AgentService_WatchPolicyServer for testing.
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *agentpb.PolicyChangeEvent
+}
+
+func (f *fakeWatchStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeWatchStream) Send(event *agentpb.PolicyChangeEvent) error {
+	f.events <- event
+	return nil
+}
+
+func (f *fakeWatchStream) next(t *testing.T) *agentpb.PolicyChangeEvent {
+	t.Helper()
+	select {
+	case event := <-f.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for policy change event")
+		return nil
+	}
+}
+
+// TestServerWatchPolicy tests policy change notifications.
+func TestServerWatchPolicy(t *testing.T) {
+	server := NewServer(DefaultServerConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeWatchStream{ctx: ctx, events: make(chan *agentpb.PolicyChangeEvent, 4)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.WatchPolicy(&agentpb.WatchPolicyRequest{
+			Metadata: &agentpb.RequestMetadata{AgentType: "watch-agent"},
+		}, stream)
+	}()
+
+	initial := stream.next(t)
+	if initial.ChangeType != agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_INITIAL {
+		t.Errorf("expected INITIAL, got %v", initial.ChangeType)
+	}
+	if initial.PolicyHash != "" {
+		t.Errorf("expected no policy hash before load, got %q", initial.PolicyHash)
+	}
+
+	compiled := policy.CompilePolicy("watch-policy", []string{"watch-agent"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, "")
+	server.LoadPolicy("watch-agent", compiled)
+
+	updated := stream.next(t)
+	if updated.ChangeType != agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_UPDATED {
+		t.Errorf("expected UPDATED, got %v", updated.ChangeType)
+	}
+	if updated.PolicyName != "watch-policy" || updated.PolicyHash != compiled.Fingerprint() {
+		t.Errorf("unexpected event: %v", updated)
+	}
+
+	// Policies for other agent types do not notify this watcher
+	server.LoadPolicy("other-agent", policy.CompilePolicy("other-policy", []string{"other-agent"},
+		policy.Deny, nil, policy.Enforcing, ""))
+
+	server.policy.SetMode(policy.Enforcing)
+	modeChange := stream.next(t)
+	if modeChange.Mode != policy.Enforcing.String() || modeChange.PolicyHash != updated.PolicyHash {
+		t.Errorf("expected mode change event, got %v", modeChange)
+	}
+
+	server.policy.RemovePolicy("watch-agent")
+	removed := stream.next(t)
+	if removed.ChangeType != agentpb.PolicyChangeType_POLICY_CHANGE_TYPE_REMOVED {
+		t.Errorf("expected REMOVED, got %v", removed.ChangeType)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchPolicy returned error: %v", err)
+	}
+}
+
+// TestServerWatchPolicyValidation tests that an agent type is required.
+func TestServerWatchPolicyValidation(t *testing.T) {
+	server := NewServer(DefaultServerConfig())
+
+	err := server.WatchPolicy(&agentpb.WatchPolicyRequest{}, &fakeWatchStream{ctx: context.Background()})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// TestServerListAllowedTools tests capability discovery from the loaded policy.
+func TestServerListAllowedTools(t *testing.T) {
+	server := NewServer(DefaultServerConfig())
+
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy(
+		"coding-policy",
+		[]string{"coding-assistant"},
+		policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.write", Action: policy.Allow, Constraints: &policy.ToolConstraints{
+				PathPatterns: []string{"/workspace/**"},
+				MaxSizeBytes: 1024,
+			}},
+			{Tool: "file.read", Action: policy.Allow},
+			{Tool: "shell.exec", Action: policy.Deny},
+			{Tool: "deploy.run", Action: policy.Allow, Constraints: &policy.ToolConstraints{
+				RequiredAgentLabels: map[string]string{"env": "prod"},
+			}},
+		},
+		policy.Enforcing,
+		"",
+	))
+
+	resp, err := server.ListAllowedTools(context.Background(), &agentpb.ListAllowedToolsRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", Labels: map[string]string{"env": "dev"}},
+	})
+	if err != nil {
+		t.Fatalf("ListAllowedTools failed: %v", err)
+	}
+
+	if resp.PolicyName != "coding-policy" || resp.DefaultAction != "DENY" || resp.PolicyHash == "" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	// shell.exec is denied and deploy.run requires env=prod
+	if len(resp.Tools) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(resp.Tools))
+	}
+	if resp.Tools[0].GetName() != "file.read" || resp.Tools[0].GetConstraints() != nil {
+		t.Errorf("unexpected first tool: %v", resp.Tools[0])
+	}
+	write := resp.Tools[1]
+	if write.GetName() != "file.write" || write.GetConstraints().GetMaxSizeBytes() != 1024 ||
+		len(write.GetConstraints().GetPathPatterns()) != 1 {
+		t.Errorf("unexpected second tool: %v", write)
+	}
+
+	// Unknown agent types get an empty, deny-by-default answer
+	resp, err = server.ListAllowedTools(context.Background(), &agentpb.ListAllowedToolsRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "unknown-agent"},
+	})
+	if err != nil {
+		t.Fatalf("ListAllowedTools failed: %v", err)
+	}
+	if len(resp.Tools) != 0 || resp.DefaultAction != "DENY" || resp.PolicyName != "" {
+		t.Errorf("expected empty deny response, got %v", resp)
+	}
+
+	_, err = server.ListAllowedTools(context.Background(), &agentpb.ListAllowedToolsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// TestServerListAllowedToolsCatalog tests that discovered tools are
+// described by the tool catalog.
+func TestServerListAllowedToolsCatalog(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.ToolCatalog = policy.NewToolCatalog()
+	config.PolicyConfig.ToolCatalog.Replace([]policy.ToolCatalogSet{{Source: "platform", Tools: []policy.CatalogTool{{
+		Name: "file.read", Description: "Reads a file", Category: "filesystem", RiskLevel: policy.RiskLow,
+		Parameters: map[string]interface{}{"type": "object"},
+	}}}})
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}, {Tool: "file.write", Action: policy.Allow}},
+		policy.Enforcing, ""))
+
+	resp, err := server.ListAllowedTools(context.Background(), &agentpb.ListAllowedToolsRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tools) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(resp.Tools))
+	}
+	read, write := resp.Tools[0], resp.Tools[1]
+	if read.GetDescription() != "Reads a file" || read.GetCategory() != "filesystem" || read.GetRiskLevel() != "low" ||
+		read.GetParametersSchema() != `{"type":"object"}` {
+		t.Errorf("expected file.read to be described by the catalog, got %+v", read)
+	}
+	if write.GetCategory() != "" || write.GetParametersSchema() != "" {
+		t.Errorf("expected file.write, which the catalog does not declare, to be undescribed, got %+v", write)
+	}
+}
+
+// TestServerDenyMessage tests that rule deny messages reach the response
+// and the gRPC status details, separate from the status message.
+func TestServerDenyMessage(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	server.LoadPolicy("writer-agent", policy.CompilePolicy(
+		"writer-policy",
+		[]string{"writer-agent"},
+		policy.Deny,
+		[]policy.ToolPermission{{
+			Tool:                  "file.write",
+			Action:                policy.Allow,
+			Constraints:           &policy.ToolConstraints{PathPatterns: []string{"/workspace/**"}},
+			DenyMessage:           "You can only edit files under /workspace, not {path}",
+			LocalizedDenyMessages: map[string]string{"de": "Nur Dateien unter /workspace, nicht {path}"},
+		}},
+		policy.Enforcing,
+		"",
+	))
+
+	resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+		ToolName:        "file.write",
+		Metadata:        &agentpb.RequestMetadata{AgentType: "writer-agent", Locale: "de"},
+		TypedParameters: &agentpb.ExecuteRequest_File{File: &agentpb.FileParams{Path: "/etc/passwd"}},
+	})
+
+	want := "Nur Dateien unter /workspace, nicht /etc/passwd"
+	if resp.GetPolicyDecision().GetMessage() != want || resp.GetPolicyDecision().GetMessageLocale() != "de" {
+		t.Errorf("unexpected policy decision: %+v", resp.GetPolicyDecision())
+	}
+	if resp.GetPolicyDecision().GetPolicyName() != "writer-policy" {
+		t.Errorf("expected policy name, got %q", resp.GetPolicyDecision().GetPolicyName())
+	}
+
+	st, _ := status.FromError(err)
+	if st.Code() != codes.PermissionDenied || strings.Contains(st.Message(), "/workspace") {
+		t.Errorf("expected generic PermissionDenied status, got %v", st)
+	}
+
+	var gotInfo, gotMessage bool
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			gotInfo = d.GetReason() == "POLICY_DENIED" && d.GetMetadata()["policy"] == "writer-policy" &&
+				d.GetMetadata()["cause"] == causeConstraintViolation && d.GetMetadata()["constraint"] == "pathPatterns" &&
+				d.GetMetadata()["value"] == "/etc/passwd"
+		case *errdetails.LocalizedMessage:
+			gotMessage = d.GetMessage() == want && d.GetLocale() == "de"
+		}
+	}
+	if !gotInfo || !gotMessage {
+		t.Errorf("expected ErrorInfo and LocalizedMessage details, got %v", st.Details())
+	}
+}
+
+// TestServerMutators verifies the executor receives the parameters rewritten
+// by the policy's mutators and the response reports the rewrites.
+func TestServerMutators(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	executor := &mockToolExecutor{result: "ok"}
+	server.SetToolExecutor(executor)
+
+	server.LoadPolicy("fetch-agent", policy.CompilePolicy(
+		"fetch-policy",
+		[]string{"fetch-agent"},
+		policy.Deny,
+		[]policy.ToolPermission{{
+			Tool:   "network.fetch",
+			Action: policy.Allow,
+			Mutators: []policy.ParameterMutator{
+				{Type: policy.MutateRemoveKey, Param: "headers", Key: "Authorization"},
+			},
+		}},
+		policy.Enforcing,
+		"",
+	))
+
+	resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+		ToolName:   "network.fetch",
+		Metadata:   &agentpb.RequestMetadata{AgentType: "fetch-agent"},
+		Parameters: []byte(`{"url":"https://example.com","headers":{"Authorization":"Bearer secret","Accept":"*/*"}}`),
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("expected success, got %v", resp.GetStatus())
+	}
+
+	headers, _ := executor.params["headers"].(map[string]interface{})
+	if _, ok := headers["Authorization"]; ok || headers["Accept"] != "*/*" {
+		t.Errorf("expected Authorization to be stripped, got %v", executor.params)
+	}
+	if executor.params["url"] != "https://example.com" {
+		t.Errorf("expected other parameters to be kept, got %v", executor.params)
+	}
+	if len(resp.GetPolicyDecision().GetMutations()) != 1 {
+		t.Errorf("expected one mutation, got %v", resp.GetPolicyDecision().GetMutations())
+	}
+}
+
+// sandboxExecutor is a mockToolExecutor that can run tools read-only.
+type sandboxExecutor struct {
+	mockToolExecutor
+
+	// obligations records the obligations of the last call
+	obligations []policy.Obligation
+}
+
+func (s *sandboxExecutor) SupportsObligation(obligationType string) bool {
+	return obligationType == policy.ObligationReadOnlySandbox
+}
+
+func (s *sandboxExecutor) ExecuteWithObligations(ctx context.Context, toolName string, params map[string]interface{}, obligations []policy.Obligation) (interface{}, error) {
+	s.obligations = obligations
+	return s.Execute(ctx, toolName, params)
+}
+
+// TestServerObligations tests that obligations are fulfilled by handlers or
+// the executor before execution, and that unfulfillable ones fail closed.
+func TestServerObligations(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+
+	executor := &sandboxExecutor{mockToolExecutor: mockToolExecutor{result: "ok"}}
+	server.SetToolExecutor(executor)
+
+	var payloads strings.Builder
+	server.SetObligationHandler(policy.ObligationLogPayload, NewPayloadLogHandler(&payloads))
+
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy(
+		"obligation-policy",
+		[]string{"coding-assistant"},
+		policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "file.write", Action: policy.Allow, Obligations: []policy.Obligation{
+				{Type: policy.ObligationLogPayload},
+				{Type: policy.ObligationReadOnlySandbox},
+			}},
+			{Tool: "network.fetch", Action: policy.Allow, Obligations: []policy.Obligation{
+				{Type: policy.ObligationNotify, Params: map[string]string{"channel": "#security-alerts"}},
+			}},
+		},
+		policy.Enforcing,
+		"",
+	))
+
+	execute := func(tool string) *agentpb.ExecuteResponse {
+		t.Helper()
+		resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   tool,
+			RequestId:  "req-1",
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+			Parameters: []byte(`{"path":"/workspace/a.txt"}`),
+		})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := execute("file.write")
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("expected success, got %v: %s", resp.GetStatus(), resp.GetError())
+	}
+	if !strings.Contains(payloads.String(), `"request_id":"req-1"`) || !strings.Contains(payloads.String(), `"path":"/workspace/a.txt"`) {
+		t.Errorf("expected payload to be logged, got %q", payloads.String())
+	}
+	if len(executor.obligations) != 1 || executor.obligations[0].Type != policy.ObligationReadOnlySandbox {
+		t.Errorf("expected read-only sandbox obligation to reach the executor, got %v", executor.obligations)
+	}
+	if got := resp.GetPolicyDecision().GetObligations(); len(got) != 2 || got[0].GetType() != policy.ObligationLogPayload {
+		t.Errorf("expected obligations in the policy decision, got %v", got)
+	}
+
+	executor.params = nil
+	resp = execute("network.fetch")
+	if resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR || !strings.Contains(resp.GetError(), "cannot be fulfilled") {
+		t.Errorf("expected unfulfillable obligation to fail the call, got %v: %s", resp.GetStatus(), resp.GetError())
+	}
+	if executor.params != nil {
+		t.Error("tool must not execute when an obligation cannot be fulfilled")
+	}
+}
+
+// slowExecutor implements ToolExecutor with a call that takes delay, or
+// until its context is done.
+type slowExecutor struct {
+	delay time.Duration
+}
+
+func (s *slowExecutor) Execute(ctx context.Context, toolName string, params map[string]interface{}) (interface{}, error) {
+	select {
+	case <-time.After(s.delay):
+		return "done", nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TestServerExecutionTimeout tests that the timeout constraint of a tool
+// rule bounds the tool's execution.
+func TestServerExecutionTimeout(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	server := NewServer(config)
+	server.SetToolExecutor(&slowExecutor{delay: 200 * time.Millisecond})
+
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy(
+		"timeout-policy",
+		[]string{"coding-assistant"},
+		policy.Deny,
+		[]policy.ToolPermission{
+			{Tool: "code.execute", Action: policy.Allow, Constraints: &policy.ToolConstraints{Timeout: 20 * time.Millisecond}},
+			{Tool: "file.read", Action: policy.Allow},
+		},
+		policy.Enforcing,
+		"",
+	))
+
+	execute := func(tool string) *agentpb.ExecuteResponse {
+		resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   tool,
+			Parameters: []byte(`{}`),
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+			RequestId:  "req-" + tool,
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tool, err)
+		}
+		return resp
+	}
+
+	resp := execute("code.execute")
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_ERROR {
+		t.Fatalf("code.execute: expected ERROR, got %v", resp.Status)
+	}
+	if !strings.Contains(resp.Error, "timed out after 20ms") {
+		t.Errorf("code.execute: expected a timeout error, got %q", resp.Error)
+	}
+	if d := time.Duration(resp.GetExecutionTimeNs()); d < 20*time.Millisecond || d >= 200*time.Millisecond {
+		t.Errorf("code.execute: expected an execution time of about 20ms, got %v", d)
+	}
+
+	// Without a timeout constraint the call runs to completion
+	resp = execute("file.read")
+	if resp.Status != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+		t.Fatalf("file.read: expected SUCCESS, got %v (%s)", resp.Status, resp.Error)
+	}
+	if d := time.Duration(resp.GetExecutionTimeNs()); d < 200*time.Millisecond {
+		t.Errorf("file.read: expected an execution time of at least 200ms, got %v", d)
+	}
+}
+
+// TestServerExecutionAudit tests that executed calls are audited twice,
+// for their decision and for their execution's outcome, under the same
+// request ID.
+func TestServerExecutionAudit(t *testing.T) {
+	sink := policy.NewChannelAuditSink(10)
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.AuditSink = sink
+	server := NewServer(config)
+	server.SetToolExecutor(&slowExecutor{delay: 10 * time.Millisecond})
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("audit-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "code.execute", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	for _, tool := range []string{"code.execute", "network.fetch"} {
+		_, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:  tool,
+			RequestId: "req-" + tool,
+			Metadata:  &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-1"},
+		})
+		if err != nil && status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("%s: unexpected error: %v", tool, err)
+		}
+	}
+
+	var events []*policy.AuditEvent
+	for len(sink.Events()) > 0 {
+		events = append(events, <-sink.Events())
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected a decision and an execution event for the allowed call and a decision event for the denied one, got %d", len(events))
+	}
+	decision, execution := events[0], events[1]
+	if decision.Execution != nil || decision.RequestID != "req-code.execute" {
+		t.Errorf("expected the decision event first, got %+v", decision)
+	}
+	if x := execution.Execution; x == nil || execution.RequestID != decision.RequestID || execution.Agent.SandboxID != "sandbox-1" ||
+		x.Status != policy.ExecutionSucceeded || x.Duration < 10*time.Millisecond || x.ResultBytes != len(`"done"`) || x.Attempts != 1 {
+		t.Errorf("expected the execution's outcome under the decision's request ID, got %+v (%+v)", execution, execution.Execution)
+	}
+	if events[2].Execution != nil || events[2].Decision != policy.Deny {
+		t.Errorf("expected the denied call not to be executed, got %+v", events[2])
+	}
+}
+
+// TestServerTenantPartitions tests that with TenantPartitions each
+// tenant's calls are cached in a partition of their own, and that kill
+// switches and policy updates of the engine reach the partitions.
+func TestServerTenantPartitions(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.TenantPartitions = true
+	config.PolicyConfig.MaxTenants = 1
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("tenants", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	evaluate := func(tenant string) policy.Decision {
+		t.Helper()
+		decision, err := server.policy.Evaluate(context.Background(), RequestMetadata{AgentType: "coding-assistant", TenantID: tenant}, "file.read", nil)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		return decision
+	}
+
+	if evaluate("tenant-a") != policy.Allow || evaluate("tenant-b") != policy.Allow {
+		t.Fatal("expected the engine's policy to apply to every tenant")
+	}
+	tenants := server.policy.TenantEngine()
+	if got := tenants.Tenants(); len(got) != 1 || got[0] != "tenant-a" {
+		t.Errorf("expected a partition for tenant-a only, past MaxTenants, got %v", got)
+	}
+	if stats, _ := tenants.TenantStats("tenant-a"); stats.CacheEntries != 1 {
+		t.Errorf("expected tenant-a's decision in its partition, got %+v", stats)
+	}
+	if stats, _ := tenants.TenantStats(""); stats.CacheEntries != 1 {
+		t.Errorf("expected tenant-b's decision in the default partition, got %+v", stats)
+	}
+
+	server.policy.Engine().KillTool(policy.KillSwitch{Source: "incident", Tool: "file.read"})
+	if evaluate("tenant-a") != policy.Deny {
+		t.Error("expected the kill switch to apply to tenant-a's partition")
+	}
+	server.policy.Engine().LiftKill("incident")
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("tenants-v2", []string{"coding-assistant"}, policy.Deny, nil, policy.Enforcing, ""))
+	if evaluate("tenant-a") != policy.Deny {
+		t.Error("expected the updated policy to apply to tenant-a's partition")
+	}
+}
+
+// TestServerTenantWouldDenyMetric tests that the would-deny metric counts
+// the calls of every tenant partition.
+func TestServerTenantWouldDenyMetric(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Permissive
+	config.PolicyConfig.TenantPartitions = true
+	server := NewServer(config)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("tenants", []string{"coding-assistant"}, policy.Deny, nil, policy.Permissive, ""))
+
+	for _, tenant := range []string{"", "tenant-a", "tenant-b"} {
+		decision, err := server.policy.Evaluate(context.Background(), RequestMetadata{AgentType: "coding-assistant", TenantID: tenant}, "file.read", nil)
+		if err != nil || decision != policy.Allow {
+			t.Fatalf("expected %q's call to be allowed in permissive mode, got %v, %v", tenant, decision, err)
+		}
+	}
+	if got := server.policy.TenantEngine().Tenants(); len(got) != 2 {
+		t.Fatalf("expected partitions for tenant-a and tenant-b, got %v", got)
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := server.policy.registerMetrics(registry); err != nil {
+		t.Fatalf("registerMetrics failed: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	var wouldDeny float64
+	for _, family := range families {
+		if family.GetName() == "agentpolicy_would_deny_total" {
+			wouldDeny = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if wouldDeny != 3 {
+		t.Errorf("expected 3 would-deny calls across partitions, got %v", wouldDeny)
+	}
+}
+
+// TestServerFaults tests that the router fails closed under injected
+// engine faults.
+func TestServerFaults(t *testing.T) {
+	faults := policy.NewFaultInjector()
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.UseOPA = true
+	config.PolicyConfig.EvaluationTimeout = 20 * time.Millisecond
+	config.PolicyConfig.Faults = faults
+	server := NewServer(config)
+	server.SetToolExecutor(&mockToolExecutor{result: "ok"})
+
+	compiled := policy.CompilePolicy("opa-policy", []string{"coding-assistant"}, policy.Allow, nil, policy.Enforcing, "")
+	compiled.OPAEnabled = true
+	compiled.PreparedQuery = &rego.PreparedEvalQuery{}
+	server.LoadPolicy("coding-assistant", compiled)
+
+	execute := func(tool string) (*agentpb.ExecuteResponse, error) {
+		return server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:   tool,
+			Parameters: []byte(`{}`),
+			Metadata:   &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+		})
+	}
+
+	faults.Set(policy.FaultOPAEval, policy.Fault{Err: errors.New("query failed")})
+	resp, err := execute("file.read")
+	if status.Code(err) != codes.Internal || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_DENIED {
+		t.Errorf("failed query: expected a denial with INTERNAL, got %v (%v)", resp.GetStatus(), err)
+	}
+
+	faults.Set(policy.FaultOPAEval, policy.Fault{Delay: time.Hour})
+	resp, err = execute("file.write")
+	if status.Code(err) != codes.DeadlineExceeded || resp != nil {
+		t.Errorf("slow query: expected DEADLINE_EXCEEDED, got %v (%v)", resp.GetStatus(), err)
+	}
+}
@@ -0,0 +1,315 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+)
+
+// Session is an agent session opened with OpenSession. Its identity is
+// bound when it is opened; Execute calls carrying its token use that
+// identity instead of their request metadata.
+type Session struct {
+	// ID identifies the session; it is the SessionID of the session's calls
+	ID string
+
+	// Metadata is the identity bound to the session
+	Metadata RequestMetadata
+
+	// ExpiresAt is when the session's token stops being accepted
+	ExpiresAt time.Time
+
+	calls atomic.Uint64
+}
+
+// Calls returns the number of Execute calls made in the session on this
+// replica, including the current one. Executors and obligation handlers
+// can use it, with SessionFromContext, for session-scoped sequencing.
+func (s *Session) Calls() uint64 {
+	return s.calls.Load()
+}
+
+type sessionContextKey struct{}
+
+// SessionFromContext returns the session of the Execute call being
+// handled, if the call carried a session token.
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return s, ok
+}
+
+// SessionAuthenticator authenticates an agent opening a session, e.g. by
+// verifying a workload credential, and returns the identity to bind to the
+// session. Returning an error refuses the session.
+type SessionAuthenticator interface {
+	Authenticate(ctx context.Context, md RequestMetadata) (RequestMetadata, error)
+}
+
+// SessionAuthenticatorFunc adapts a function to the SessionAuthenticator
+// interface.
+type SessionAuthenticatorFunc func(ctx context.Context, md RequestMetadata) (RequestMetadata, error)
+
+// Authenticate calls f.
+func (f SessionAuthenticatorFunc) Authenticate(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+	return f(ctx, md)
+}
+
+// sessionClaims are the identity and expiry signed into a session token.
+// Tokens are self-contained, so any replica holding the signing key can
+// verify them.
+type sessionClaims struct {
+	ID        string            `json:"sid"`
+	AgentType string            `json:"agt"`
+	SandboxID string            `json:"sbx,omitempty"`
+	TenantID  string            `json:"tnt,omitempty"`
+	MTSLabel  string            `json:"mts,omitempty"`
+	Labels    map[string]string `json:"lbl,omitempty"`
+	Lineage   []string          `json:"lin,omitempty"`
+	ExpiresAt int64             `json:"exp"`
+}
+
+// sessionManager issues and verifies session tokens, and keeps the
+// replica-local state of live sessions.
+type sessionManager struct {
+	key        []byte
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+
+	mu        sync.Mutex
+	sessions  map[string]*Session
+	nextSweep time.Time
+}
+
+func newSessionManager(key []byte, defaultTTL, maxTTL time.Duration) *sessionManager {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(fmt.Sprintf("failed to generate session key: %v", err))
+		}
+	}
+	if defaultTTL <= 0 {
+		defaultTTL = time.Hour
+	}
+	if maxTTL <= 0 {
+		maxTTL = 24 * time.Hour
+	}
+	if defaultTTL > maxTTL {
+		defaultTTL = maxTTL
+	}
+	return &sessionManager{
+		key:        key,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+		sessions:   make(map[string]*Session),
+	}
+}
+
+// open starts a session for an identity and returns it with its token.
+func (m *sessionManager) open(md RequestMetadata, ttl time.Duration) (*Session, string, error) {
+	if ttl <= 0 {
+		ttl = m.defaultTTL
+	}
+	if ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	md.SessionID = hex.EncodeToString(id)
+	session := &Session{ID: md.SessionID, Metadata: md, ExpiresAt: now.Add(ttl)}
+
+	payload, err := json.Marshal(sessionClaims{
+		ID:        session.ID,
+		AgentType: md.AgentType,
+		SandboxID: md.SandboxID,
+		TenantID:  md.TenantID,
+		MTSLabel:  md.MTSLabel,
+		Labels:    md.Labels,
+		Lineage:   md.Lineage,
+		ExpiresAt: session.ExpiresAt.UnixNano(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := "v1." + encoded + "." + base64.RawURLEncoding.EncodeToString(m.sign(encoded))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	m.sessions[session.ID] = session
+	return session, token, nil
+}
+
+// resolve verifies a token and returns its session. Sessions opened on
+// other replicas sharing the key get local state on first use.
+func (m *sessionManager) resolve(token string) (*Session, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != "v1" {
+		return nil, errors.New("malformed session token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, m.sign(parts[1])) {
+		return nil, errors.New("invalid session token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed session token")
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed session token")
+	}
+
+	now := time.Now()
+	expiresAt := time.Unix(0, claims.ExpiresAt)
+	if !now.Before(expiresAt) {
+		return nil, errors.New("session expired")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[claims.ID]; ok {
+		return session, nil
+	}
+	m.sweep(now)
+	session := &Session{
+		ID: claims.ID,
+		Metadata: RequestMetadata{
+			AgentType: claims.AgentType,
+			SandboxID: claims.SandboxID,
+			TenantID:  claims.TenantID,
+			SessionID: claims.ID,
+			MTSLabel:  claims.MTSLabel,
+			Labels:    claims.Labels,
+			Lineage:   claims.Lineage,
+		},
+		ExpiresAt: expiresAt,
+	}
+	if n := len(claims.Lineage); n > 0 {
+		session.Metadata.ParentSessionID = claims.Lineage[n-1]
+	}
+	m.sessions[claims.ID] = session
+	return session, nil
+}
+
+func (m *sessionManager) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// sweep drops the state of expired sessions, at most every tenth of the
+// default session lifetime. It runs as sessions are added, whether opened
+// here or on other replicas. Callers must hold m.mu.
+func (m *sessionManager) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(m.defaultTTL / 10)
+	for id, session := range m.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// OpenSession implements the AgentService.OpenSession RPC. It
+// authenticates the agent once (peer attestation for Unix socket callers,
+// then the IdentityAuthenticator and the SessionAuthenticator, if any) and
+// issues a token binding the resulting identity.
+func (s *Server) OpenSession(ctx context.Context, req *agentpb.OpenSessionRequest) (*agentpb.OpenSessionResponse, error) {
+	if req.GetMetadata().GetAgentType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metadata.agent_type is required")
+	}
+
+	md, err := s.identify(ctx, metadataFromProto(req.GetMetadata()))
+	if err != nil {
+		return nil, err
+	}
+	if s.sessionAuth != nil {
+		if md, err = s.sessionAuth.Authenticate(ctx, md); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.Unauthenticated, "session refused: %v", err)
+		}
+	}
+
+	session, token, err := s.sessions.open(md, time.Duration(req.GetTtlSeconds())*time.Second)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to open session: %v", err)
+	}
+	return &agentpb.OpenSessionResponse{
+		SessionToken:    token,
+		SessionId:       session.ID,
+		ExpiresUnixNano: session.ExpiresAt.UnixNano(),
+	}, nil
+}
+
+// requestIdentity returns the identity of an Execute call: its session's if
+// it carries a token, or its (attested) request metadata otherwise. Any
+// identity fields the metadata sets alongside a token must match the
+// session; only the locale may vary per call.
+func (s *Server) requestIdentity(ctx context.Context, req *agentpb.ExecuteRequest) (RequestMetadata, *Session, error) {
+	token := req.GetSessionToken()
+	if token == "" {
+		if s.requireSession {
+			return RequestMetadata{}, nil, status.Error(codes.Unauthenticated, "session token required (call OpenSession)")
+		}
+		md, err := s.identify(ctx, metadataFromProto(req.GetMetadata()))
+		return md, nil, err
+	}
+
+	session, err := s.sessions.resolve(token)
+	if err != nil {
+		return RequestMetadata{}, nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	md := session.Metadata
+
+	// A stolen token must not be usable from another sandbox's socket
+	if attested, err := s.attestPeer(ctx, md); err != nil {
+		return RequestMetadata{}, nil, err
+	} else if attested.SandboxID != md.SandboxID {
+		return RequestMetadata{}, nil, status.Error(codes.PermissionDenied, "session belongs to another sandbox")
+	}
+
+	if claimed := req.GetMetadata(); claimed != nil {
+		for _, f := range []struct{ name, claimed, bound string }{
+			{"agent_type", claimed.GetAgentType(), md.AgentType},
+			{"sandbox_id", claimed.GetSandboxId(), md.SandboxID},
+			{"tenant_id", claimed.GetTenantId(), md.TenantID},
+			{"session_id", claimed.GetSessionId(), md.SessionID},
+			{"mts_label", claimed.GetMtsLabel(), md.MTSLabel},
+		} {
+			if f.claimed != "" && f.claimed != f.bound {
+				return RequestMetadata{}, nil, status.Errorf(codes.PermissionDenied, "metadata.%s does not match the session", f.name)
+			}
+		}
+		if _, lineage := lineageFromProto(claimed); len(lineage) > 0 && strings.Join(lineage, "/") != strings.Join(md.Lineage, "/") {
+			return RequestMetadata{}, nil, status.Error(codes.PermissionDenied, "metadata.lineage does not match the session")
+		}
+		if claimed.GetLocale() != "" {
+			md.Locale = claimed.GetLocale()
+		}
+	}
+	return md, session, nil
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// sessionExecutor records the session of each call it executes.
+type sessionExecutor struct {
+	session *Session
+}
+
+func (e *sessionExecutor) Execute(ctx context.Context, toolName string, params map[string]interface{}) (interface{}, error) {
+	e.session, _ = SessionFromContext(ctx)
+	return "ok", nil
+}
+
+// TestServerSessions verifies session tokens carry the bound identity and
+// cannot be combined with a different claimed identity
+func TestServerSessions(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.RequireSession = true
+	server := NewServer(config)
+	executor := &sessionExecutor{}
+	server.SetToolExecutor(executor)
+	server.LoadPolicy("coding-assistant", policy.CompilePolicy("coding-policy", []string{"coding-assistant"}, policy.Deny,
+		[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+
+	opened, err := server.OpenSession(context.Background(), &agentpb.OpenSessionRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", TenantId: "tenant-a", SessionId: "client-chosen"},
+	})
+	if err != nil {
+		t.Fatalf("OpenSession failed: %v", err)
+	}
+	if opened.GetSessionId() == "client-chosen" || opened.GetExpiresUnixNano() <= time.Now().UnixNano() {
+		t.Errorf("expected router-assigned session with future expiry, got %v", opened)
+	}
+
+	for i := 1; i <= 2; i++ {
+		resp, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{ToolName: "file.read", SessionToken: opened.GetSessionToken()})
+		if err != nil || resp.GetStatus() != agentpb.ExecutionStatus_EXECUTION_STATUS_SUCCESS {
+			t.Fatalf("expected session call to succeed, got %v (%v)", resp, err)
+		}
+		if executor.session == nil || executor.session.Calls() != uint64(i) || executor.session.Metadata.TenantID != "tenant-a" {
+			t.Errorf("expected session state for call %d, got %+v", i, executor.session)
+		}
+	}
+
+	tests := []struct {
+		name string
+		req  *agentpb.ExecuteRequest
+		code codes.Code
+	}{
+		{"no token", &agentpb.ExecuteRequest{ToolName: "file.read", Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"}}, codes.Unauthenticated},
+		{"forged token", &agentpb.ExecuteRequest{ToolName: "file.read", SessionToken: opened.GetSessionToken() + "x"}, codes.Unauthenticated},
+		{"conflicting metadata", &agentpb.ExecuteRequest{ToolName: "file.read", SessionToken: opened.GetSessionToken(),
+			Metadata: &agentpb.RequestMetadata{AgentType: "admin-agent"}}, codes.PermissionDenied},
+		{"conflicting lineage", &agentpb.ExecuteRequest{ToolName: "file.read", SessionToken: opened.GetSessionToken(),
+			Metadata: &agentpb.RequestMetadata{ParentSessionId: "other-task"}}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		if _, err := server.Execute(context.Background(), tt.req); status.Code(err) != tt.code {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.code, err)
+		}
+	}
+}
+
+// TestSessionTokens verifies tokens are accepted by replicas sharing the
+// key, and rejected once expired or under another key
+func TestSessionTokens(t *testing.T) {
+	key := []byte("shared-session-key")
+	issuer := newSessionManager(key, time.Minute, time.Hour)
+	_, token, err := issuer.open(RequestMetadata{AgentType: "coding-assistant", MTSLabel: "s0:c1"}, 0)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	session, err := newSessionManager(key, 0, 0).resolve(token)
+	if err != nil || session.Metadata.AgentType != "coding-assistant" || session.Metadata.MTSLabel != "s0:c1" {
+		t.Errorf("expected replica sharing the key to accept the token, got %+v (%v)", session, err)
+	}
+	_, delegated, _ := issuer.open(RequestMetadata{AgentType: "coding-assistant", ParentSessionID: "parent", Lineage: []string{"root", "parent"}}, 0)
+	if session, err := newSessionManager(key, 0, 0).resolve(delegated); err != nil || session.Metadata.ParentSessionID != "parent" || len(session.Metadata.Lineage) != 2 {
+		t.Errorf("expected the token to carry the session's lineage, got %+v (%v)", session, err)
+	}
+	if _, err := newSessionManager([]byte("other-key"), 0, 0).resolve(token); err == nil {
+		t.Error("expected token to be rejected under another key")
+	}
+
+	_, expired, _ := issuer.open(RequestMetadata{AgentType: "coding-assistant"}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := issuer.resolve(expired); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired token to be rejected, got %v", err)
+	}
+
+	// The state of expired sessions opened on other replicas is dropped
+	replica := newSessionManager(key, time.Millisecond, time.Hour)
+	_, shortLived, _ := issuer.open(RequestMetadata{AgentType: "coding-assistant"}, 10*time.Millisecond)
+	if _, err := replica.resolve(shortLived); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := replica.resolve(token); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if n := len(replica.sessions); n != 1 {
+		t.Errorf("expected the expired session to be swept, got %d sessions", n)
+	}
+
+	// Requested lifetimes are capped
+	if session, _, _ := issuer.open(RequestMetadata{AgentType: "coding-assistant"}, 48*time.Hour); time.Until(session.ExpiresAt) > time.Hour {
+		t.Errorf("expected TTL capped at 1h, got %v", time.Until(session.ExpiresAt))
+	}
+}
+
+// TestOpenSessionAuthenticator verifies the authenticator can refuse
+// sessions and rewrite the bound identity
+func TestOpenSessionAuthenticator(t *testing.T) {
+	config := DefaultServerConfig()
+	config.SessionAuthenticator = SessionAuthenticatorFunc(func(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+		if md.SandboxID == "" {
+			return md, errors.New("sandbox identity required")
+		}
+		md.TenantID = "tenant-of-" + md.SandboxID
+		return md, nil
+	})
+	server := NewServer(config)
+
+	if _, err := server.OpenSession(context.Background(), &agentpb.OpenSessionRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant"},
+	}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+
+	opened, err := server.OpenSession(context.Background(), &agentpb.OpenSessionRequest{
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sb-1", TenantId: "spoofed"},
+	})
+	if err != nil {
+		t.Fatalf("OpenSession failed: %v", err)
+	}
+	session, _ := server.sessions.resolve(opened.GetSessionToken())
+	if session.Metadata.TenantID != "tenant-of-sb-1" {
+		t.Errorf("expected authenticated tenant, got %q", session.Metadata.TenantID)
+	}
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// RestoreSnapshot loads the engine snapshot at PolicyConfig.SnapshotFrom,
+// if set, recompiling its policies, so that a new replica of a blue/green
+// deploy starts serving with the policies of the replica it replaces.
+// Call it before StartController and StartPolicySource, whose policies
+// then replace the restored ones as they sync.
+func (s *Server) RestoreSnapshot(ctx context.Context) error {
+	return s.policy.RestoreSnapshot(ctx)
+}
+
+// RestoreSnapshot loads the engine snapshot at PolicyConfig.SnapshotFrom,
+// if set.
+func (r *RouterPolicyIntegration) RestoreSnapshot(ctx context.Context) error {
+	from := r.config.SnapshotFrom
+	if from == "" {
+		return nil
+	}
+	data, err := readSnapshot(ctx, from)
+	if err != nil {
+		return fmt.Errorf("failed to read engine snapshot from %s: %w", from, err)
+	}
+	var snapshot policy.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid engine snapshot from %s: %w", from, err)
+	}
+	useOPA := r.config.UseOPA
+	err = r.engine.RestoreSnapshot(&snapshot, func(source []byte) (*policy.CompiledPolicy, error) {
+		return compile.Manifest(source, useOPA)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore engine snapshot from %s: %w", from, err)
+	}
+	return nil
+}
+
+// readSnapshot reads a snapshot from an http(s) URL or a file.
+func readSnapshot(ctx context.Context, from string) ([]byte, error) {
+	if !strings.HasPrefix(from, "http://") && !strings.HasPrefix(from, "https://") {
+		return os.ReadFile(from)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, from, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// serveInspectSnapshot serves the engine snapshot as JSON, with the cached
+// decisions if the cache parameter is true.
+func (s *Server) serveInspectSnapshot(w http.ResponseWriter, r *http.Request) {
+	cache := r.URL.Query().Get("cache") == "true"
+	snapshot, err := s.policy.Engine().Snapshot(cache)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		s.policy.log.Error("failed to write engine snapshot", "error", err)
+	}
+}
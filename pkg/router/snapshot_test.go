@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/golden-agent/golden-agent/api/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+	"github.com/golden-agent/golden-agent/pkg/policy/compile"
+)
+
+// TestRestoreSnapshot tests that a new router restores the policies, kill
+// switches, tenant modes, and cached decisions of a running router from
+// its /snapshot page before it serves.
+func TestRestoreSnapshot(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	running := NewServer(config)
+	result, err := compile.AgentPolicy(&agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy", Namespace: "agents", UID: "6f1c2a"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:      []string{"coding-assistant"},
+			DefaultAction:   agentsv1alpha1.DecisionDeny,
+			Mode:            agentsv1alpha1.EnforcementModeEnforcing,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{Tool: "file.read", Action: agentsv1alpha1.DecisionAllow}},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := running.policy.Engine()
+	engine.LoadPolicy("coding-assistant", result.Policy)
+	engine.LoadPolicy("coding-*", result.Policy)
+	engine.KillTool(policy.KillSwitch{Source: "incident", Tool: "network.fetch", Reason: "INC-1"})
+	engine.SetTenantMode("tenant-b", policy.Permissive)
+	agent := policy.AgentContext{AgentType: "coding-assistant"}
+	if decision, _ := engine.Evaluate(context.Background(), agent, "file.read", nil); decision != policy.Allow {
+		t.Fatalf("expected file.read to be allowed, got %s", decision)
+	}
+
+	diag := httptest.NewServer(running.InspectHandler())
+	defer diag.Close()
+
+	config.PolicyConfig.SnapshotFrom = diag.URL + "/snapshot?cache=true"
+	standby := NewServer(config)
+	if err := standby.RestoreSnapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	restored := standby.policy.Engine()
+	for _, key := range []string{"coding-assistant", "coding-*"} {
+		if p, ok := restored.GetPolicy(key); !ok || p.UID != "6f1c2a" {
+			t.Errorf("expected coding-policy restored under %s, got %v", key, p)
+		}
+	}
+	if hits, _, _ := restored.CacheStats(); hits != 0 {
+		t.Fatalf("unexpected cache hits %d before evaluating", hits)
+	}
+	if decision, _ := restored.Evaluate(context.Background(), agent, "file.read", nil); decision != policy.Allow {
+		t.Errorf("expected file.read to be allowed, got %s", decision)
+	}
+	if hits, _, _ := restored.CacheStats(); hits != 1 {
+		t.Errorf("expected the restored cached decision to be hit, got %d hits", hits)
+	}
+	if switches := restored.KillSwitches(); len(switches) != 1 || switches[0].Tool != "network.fetch" {
+		t.Errorf("expected the kill switch to be restored, got %v", switches)
+	}
+	if mode := restored.EffectiveMode(policy.AgentContext{TenantID: "tenant-b"}); mode != policy.Permissive {
+		t.Errorf("expected tenant-b to be restored permissive, got %s", mode)
+	}
+
+	// Policies not compiled from a manifest cannot be snapshotted
+	engine.LoadPolicy("other", policy.CompilePolicy("other", []string{"other"}, policy.Deny, nil, policy.Enforcing, ""))
+	rec := httptest.NewRecorder()
+	running.InspectHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected a conflict, got %d: %s", rec.Code, rec.Body)
+	}
+
+	config.PolicyConfig.SnapshotFrom = diag.URL + "/missing"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := NewServer(config).RestoreSnapshot(ctx); err == nil {
+		t.Error("expected restoring from a missing snapshot to fail")
+	}
+}
+
+// TestInspectSecretValues tests that the constraint values a policy read
+// from Secrets are neither served by /snapshot nor shown on /policy and
+// traced simulations, and that restoring the snapshot leaves the policy
+// to the controller.
+func TestInspectSecretValues(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.UseOPA = true
+	running := NewServer(config)
+
+	ap := &agentsv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "coding-policy", Namespace: "agents", UID: "6f1c2a"},
+		Spec: agentsv1alpha1.AgentPolicySpec{
+			AgentTypes:    []string{"coding-assistant"},
+			DefaultAction: agentsv1alpha1.DecisionDeny,
+			ToolPermissions: []agentsv1alpha1.ToolPermission{{
+				Tool:   "network.fetch",
+				Action: agentsv1alpha1.DecisionAllow,
+				Constraints: &agentsv1alpha1.ToolConstraints{
+					AllowedDomains: []string{"*.corp.example"},
+					ValuesFrom: []agentsv1alpha1.ConstraintValuesSource{
+						{Field: "deniedDomains", SecretKeyRef: &agentsv1alpha1.ValuesKeyRef{Name: "netsec", Key: "denied"}},
+					},
+				},
+			}},
+		},
+	}
+	resolved, secret, err := compile.ResolveValuesFrom(ap, func(kind, name, key string) (string, bool, error) {
+		return "vault.corp.example", true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := compile.AgentPolicy(resolved, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compile.WithholdSecrets(result.Policy, ap, secret); err != nil {
+		t.Fatal(err)
+	}
+	running.policy.Engine().LoadPolicy("coding-assistant", result.Policy)
+	handler := running.InspectHandler()
+
+	q := url.Values{"agentType": {"coding-assistant"}, "tool": {"network.fetch"}, "params": {`{"domain": "wiki.corp.example"}`}, "trace": {"on"}}
+	for _, path := range []string{"/snapshot?cache=true", "/policy?agentType=coding-assistant", "/simulate?" + q.Encode()} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || strings.Contains(body, "vault.corp.example") {
+			t.Errorf("%s: expected the Secret's value to be withheld, got %d:\n%s", path, rec.Code, body)
+		}
+	}
+
+	diag := httptest.NewServer(handler)
+	defer diag.Close()
+	config.PolicyConfig.SnapshotFrom = diag.URL + "/snapshot?cache=true"
+	standby := NewServer(config)
+	if err := standby.RestoreSnapshot(context.Background()); err != nil {
+		t.Fatalf("expected the snapshot to be restored, got %v", err)
+	}
+	if _, ok := standby.policy.Engine().GetPolicy("coding-assistant"); ok {
+		t.Error("expected the policy reading Secrets to be left to the controller")
+	}
+}
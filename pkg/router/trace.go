@@ -0,0 +1,22 @@
+package router
+
+import (
+	"errors"
+	"path"
+)
+
+// ErrTraceNotAllowed is returned for calls that ask for the trace of their
+// evaluation when their agent type may not (see
+// PolicyConfig.TraceAgentTypes).
+var ErrTraceNotAllowed = errors.New("agent type may not trace policy evaluation")
+
+// traceAllowed reports whether the calls of agentType may ask for the
+// trace of their evaluation.
+func (r *RouterPolicyIntegration) traceAllowed(agentType string) bool {
+	for _, pattern := range r.config.TraceAgentTypes {
+		if match, _ := path.Match(pattern, agentType); match {
+			return true
+		}
+	}
+	return false
+}
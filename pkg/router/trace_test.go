@@ -0,0 +1,51 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+	"github.com/golden-agent/golden-agent/pkg/policy"
+)
+
+// TestServerTrace tests that only the agent types allowed to trace may ask
+// for the trace of a call, and that traced calls bypass the decision
+// cache.
+func TestServerTrace(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PolicyConfig.Mode = policy.Enforcing
+	config.PolicyConfig.TraceAgentTypes = []string{"coding-*"}
+	server := NewServer(config)
+	for _, agentType := range []string{"coding-assistant", "data-analyst"} {
+		server.LoadPolicy(agentType, policy.CompilePolicy(agentType+"-policy", []string{agentType}, policy.Deny,
+			[]policy.ToolPermission{{Tool: "file.read", Action: policy.Allow}}, policy.Enforcing, ""))
+	}
+
+	execute := func(agentType string, trace bool) error {
+		_, err := server.Execute(context.Background(), &agentpb.ExecuteRequest{
+			ToolName:        "file.read",
+			Metadata:        &agentpb.RequestMetadata{AgentType: agentType, Trace: trace},
+			TypedParameters: &agentpb.ExecuteRequest_File{File: &agentpb.FileParams{Path: "/workspace/main.go"}},
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := execute("coding-assistant", true); err != nil {
+			t.Fatalf("expected a traced call to be allowed, got %v", err)
+		}
+	}
+	if hits, _, _ := server.policy.Engine().CacheStats(); hits != 0 {
+		t.Errorf("expected traced calls to bypass the cache, got %d hits", hits)
+	}
+
+	if st, _ := status.FromError(execute("data-analyst", true)); st.Code() != codes.PermissionDenied {
+		t.Errorf("expected a trace of data-analyst to be denied, got %v", st)
+	}
+	if err := execute("data-analyst", false); err != nil {
+		t.Errorf("expected an untraced call to be allowed, got %v", err)
+	}
+}
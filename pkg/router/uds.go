@@ -0,0 +1,187 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// PeerCredentials are the kernel-attested credentials of the process at the
+// other end of a Unix socket connection (SO_PEERCRED). Unlike request
+// metadata, the caller cannot forge them.
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+
+	// Cgroup is the peer's cgroup path: its cgroup v2 path, or the first
+	// hierarchy's on cgroup v1 (e.g.,
+	// "/kubepods.slice/kubepods-pod1234.slice/cri-containerd-abcd.scope").
+	// Empty if it could not be read.
+	Cgroup string
+}
+
+// PeerAuthInfo is the gRPC AuthInfo of a Unix socket connection.
+type PeerAuthInfo struct {
+	credentials.CommonAuthInfo
+
+	Credentials PeerCredentials
+}
+
+// AuthType implements credentials.AuthInfo.
+func (PeerAuthInfo) AuthType() string {
+	return "unix-peercred"
+}
+
+// PeerIdentityMapper maps the credentials of a Unix socket peer to the ID
+// of the sandbox it runs in.
+type PeerIdentityMapper interface {
+	SandboxID(cred PeerCredentials) (string, bool)
+}
+
+// PeerIdentityFunc adapts a function to the PeerIdentityMapper interface.
+type PeerIdentityFunc func(cred PeerCredentials) (string, bool)
+
+// SandboxID calls f.
+func (f PeerIdentityFunc) SandboxID(cred PeerCredentials) (string, bool) {
+	return f(cred)
+}
+
+// UIDSandboxMapper maps peer UIDs to sandbox IDs, for runtimes that run
+// each sandbox as its own user.
+type UIDSandboxMapper map[uint32]string
+
+// SandboxID implements PeerIdentityMapper.
+func (m UIDSandboxMapper) SandboxID(cred PeerCredentials) (string, bool) {
+	id, ok := m[cred.UID]
+	return id, ok
+}
+
+// CgroupSandboxMapper maps cgroup path prefixes to sandbox IDs, for
+// runtimes that place each sandbox in its own cgroup, such as a pod's. A
+// prefix matches its cgroup and the cgroups below it, by whole path
+// segments: "/pod12" does not match "/pod1234". The longest matching
+// prefix wins.
+type CgroupSandboxMapper map[string]string
+
+// SandboxID implements PeerIdentityMapper.
+func (m CgroupSandboxMapper) SandboxID(cred PeerCredentials) (string, bool) {
+	if cred.Cgroup == "" {
+		return "", false
+	}
+	best, id := -1, ""
+	for prefix, sandboxID := range m {
+		dir := strings.TrimSuffix(prefix, "/")
+		within := cred.Cgroup == dir || strings.HasPrefix(cred.Cgroup, dir+"/")
+		if within && len(dir) > best {
+			best, id = len(dir), sandboxID
+		}
+	}
+	return id, best >= 0
+}
+
+// ServeUnix serves the AgentService on a Unix socket at path, replacing a
+// stale socket left by a previous run. Node-local routers co-located with
+// their sandboxes use it to avoid exposing a TCP port; who may connect is
+// governed by the socket's file permissions, and which sandbox a caller is
+// by ServerConfig.PeerIdentity.
+func (s *Server) ServeUnix(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// attestPeer replaces the claimed sandbox ID of a Unix socket caller with
+// the one its peer credentials map to. Callers on other transports keep
+// their metadata. A Unix socket caller that maps to no sandbox is rejected.
+func (s *Server) attestPeer(ctx context.Context, md RequestMetadata) (RequestMetadata, error) {
+	if s.peerIdentity == nil {
+		return md, nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return md, nil
+	}
+	info, ok := p.AuthInfo.(PeerAuthInfo)
+	if !ok {
+		return md, nil
+	}
+
+	sandboxID, ok := s.peerIdentity.SandboxID(info.Credentials)
+	if !ok {
+		return md, status.Errorf(codes.Unauthenticated,
+			"unix socket peer (pid %d, uid %d) is not a known sandbox", info.Credentials.PID, info.Credentials.UID)
+	}
+	md.SandboxID = sandboxID
+	return md, nil
+}
+
+// peerTransport is the gRPC transport credentials of a server with
+// PeerIdentity or TLS: Unix socket connections carry their peer
+// credentials as PeerAuthInfo, and other connections use TLS if
+// configured, or else pass through unauthenticated, as with insecure
+// credentials.
+type peerTransport struct {
+	tls credentials.TransportCredentials
+}
+
+// insecureAuthInfo is the AuthInfo of connections without peer credentials.
+type insecureAuthInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (insecureAuthInfo) AuthType() string {
+	return "insecure"
+}
+
+func (peerTransport) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, insecureAuthInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+}
+
+// ServerHandshake reads the peer credentials of Unix socket connections.
+// It fails the connection if they cannot be read, rather than let the
+// caller in without an attested identity.
+func (t peerTransport) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok && t.tls != nil {
+		return t.tls.ServerHandshake(conn)
+	}
+	if !ok {
+		return conn, insecureAuthInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+	}
+	cred, err := peerCredentials(uc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read peer credentials: %w", err)
+	}
+	return conn, PeerAuthInfo{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity},
+		Credentials:    cred,
+	}, nil
+}
+
+func (peerTransport) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "unix-peercred"}
+}
+
+func (t peerTransport) Clone() credentials.TransportCredentials {
+	if t.tls != nil {
+		t.tls = t.tls.Clone()
+	}
+	return t
+}
+
+func (peerTransport) OverrideServerName(string) error {
+	return nil
+}
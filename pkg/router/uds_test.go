@@ -0,0 +1,124 @@
+package router
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/golden-agent/golden-agent/api/proto/v1alpha1"
+)
+
+// TestPeerIdentityMappers verifies UID and cgroup mapping to sandbox IDs
+func TestPeerIdentityMappers(t *testing.T) {
+	uids := UIDSandboxMapper{1001: "sandbox-a"}
+	if id, ok := uids.SandboxID(PeerCredentials{UID: 1001}); !ok || id != "sandbox-a" {
+		t.Errorf("expected sandbox-a for uid 1001, got %q %v", id, ok)
+	}
+	if _, ok := uids.SandboxID(PeerCredentials{UID: 0}); ok {
+		t.Error("expected unmapped uid to fail")
+	}
+
+	cgroups := CgroupSandboxMapper{
+		"/kubepods.slice/":                            "node",
+		"/kubepods.slice/kubepods-pod1234.slice/":     "sandbox-a",
+		"/kubepods.slice/kubepods-pod1234.slice/x/y/": "sandbox-b",
+		"/kubepods.slice/kubepods-pod42":              "sandbox-c",
+	}
+	tests := []struct {
+		cgroup string
+		want   string
+		ok     bool
+	}{
+		{"/kubepods.slice/kubepods-pod1234.slice/cri-containerd-abcd.scope", "sandbox-a", true},
+		{"/kubepods.slice/kubepods-pod1234.slice/x/y/z", "sandbox-b", true},
+		{"/kubepods.slice/kubepods-pod9999.slice/cri-containerd-ef01.scope", "node", true},
+		{"/kubepods.slice/kubepods-pod1234.slice", "sandbox-a", true},
+		{"/kubepods.slice/kubepods-pod1234.slice-evil/x", "node", true},
+		{"/kubepods.slice/kubepods-pod42", "sandbox-c", true},
+		{"/kubepods.slice/kubepods-pod42/cri-containerd-ef01.scope", "sandbox-c", true},
+		{"/kubepods.slice/kubepods-pod4242/cri-containerd-ef01.scope", "node", true},
+		{"/kubepods.slice", "node", true},
+		{"/kubepods.slicex/y", "", false},
+		{"/system.slice/sshd.service", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if id, ok := cgroups.SandboxID(PeerCredentials{Cgroup: tt.cgroup}); id != tt.want || ok != tt.ok {
+			t.Errorf("%q: expected %q %v, got %q %v", tt.cgroup, tt.want, tt.ok, id, ok)
+		}
+	}
+}
+
+// TestServerPeerAttestation verifies Unix socket callers get their
+// attested sandbox ID and unknown peers are rejected
+func TestServerPeerAttestation(t *testing.T) {
+	config := DefaultServerConfig()
+	config.PeerIdentity = UIDSandboxMapper{1001: "sandbox-a"}
+	server := NewServer(config)
+
+	unixPeer := func(uid uint32) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: PeerAuthInfo{Credentials: PeerCredentials{PID: 42, UID: uid}},
+		})
+	}
+
+	md, err := server.attestPeer(unixPeer(1001), RequestMetadata{AgentType: "coding-assistant", SandboxID: "spoofed"})
+	if err != nil || md.SandboxID != "sandbox-a" || md.AgentType != "coding-assistant" {
+		t.Errorf("expected attested sandbox-a, got %+v (%v)", md, err)
+	}
+
+	// Callers on other transports keep their metadata
+	md, err = server.attestPeer(context.Background(), RequestMetadata{SandboxID: "sandbox-tcp"})
+	if err != nil || md.SandboxID != "sandbox-tcp" {
+		t.Errorf("expected non-UDS metadata to pass through, got %+v (%v)", md, err)
+	}
+
+	_, err = server.Execute(unixPeer(0), &agentpb.ExecuteRequest{
+		ToolName: "file.read",
+		Metadata: &agentpb.RequestMetadata{AgentType: "coding-assistant", SandboxId: "sandbox-a"},
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected UNAUTHENTICATED for unknown peer, got %v", err)
+	}
+}
+
+// TestPeerCredentials verifies SO_PEERCRED credentials are read from a
+// Unix socket connection
+func TestPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+
+	lis, err := net.Listen("unix", filepath.Join(t.TempDir(), "router.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer lis.Close()
+
+	client, err := net.Dial("unix", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer conn.Close()
+
+	_, info, err := peerTransport{}.ServerHandshake(conn)
+	if err != nil {
+		t.Fatalf("ServerHandshake failed: %v", err)
+	}
+	cred := info.(PeerAuthInfo).Credentials
+	if int(cred.PID) != os.Getpid() || int(cred.UID) != os.Getuid() || int(cred.GID) != os.Getgid() {
+		t.Errorf("expected own credentials, got %+v", cred)
+	}
+}